@@ -3,11 +3,13 @@ package benchclient
 import (
 	"fmt"
 
+	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/match"
 )
 
-// Register registers for an account
-func (cl *BenchClient) Register(signature []byte) (registerReply *cxrpc.RegisterReply, err error) {
+// Register registers for an account, with a signature of a register challenge from GetChallenge
+func (cl *BenchClient) Register(challenge [32]byte, signature []byte) (registerReply *cxrpc.RegisterReply, err error) {
 
 	if cl.PrivKey == nil {
 		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
@@ -19,6 +21,7 @@ func (cl *BenchClient) Register(signature []byte) (registerReply *cxrpc.Register
 	// now send it back to prove your knowledge of discrete logarithm of your public key, AKA Prove you know your privkey by signing this message
 	registerReply = new(cxrpc.RegisterReply)
 	registerArgs := &cxrpc.RegisterArgs{
+		Challenge: challenge,
 		Signature: signature,
 	}
 
@@ -29,15 +32,40 @@ func (cl *BenchClient) Register(signature []byte) (registerReply *cxrpc.Register
 	return
 }
 
-// GetRegistrationString gets the registration string that needs to be signed in order to be registered on the exchange
-func (cl *BenchClient) GetRegistrationString() (getRegistrationStringReply *cxrpc.GetRegistrationStringReply, err error) {
+// GetChallenge gets a challenge for action that needs to be signed, to register or to read the client's
+// orders, fills, balances, or deposit addresses
+func (cl *BenchClient) GetChallenge(action match.ChallengeAction) (getChallengeReply *cxrpc.GetChallengeReply, err error) {
 
-	getRegistrationStringReply = new(cxrpc.GetRegistrationStringReply)
-	getRegistrationStringArgs := &cxrpc.GetRegistrationStringArgs{}
+	getChallengeReply = new(cxrpc.GetChallengeReply)
+	getChallengeArgs := &cxrpc.GetChallengeArgs{
+		Action: action,
+	}
+
+	if err = cl.Call("OpencxRPC.GetChallenge", getChallengeArgs, getChallengeReply); err != nil {
+		return
+	}
+
+	return
+}
+
+// answerChallenge gets a challenge for action and signs it, returning the nonce and signature to send
+// with the command. Over noise the connection is authenticated with the client's key already, so there's
+// no challenge to answer and the nonce and signature are empty.
+func (cl *BenchClient) answerChallenge(action match.ChallengeAction) (nonce [32]byte, signature []byte, err error) {
+	if cl.noise {
+		return
+	}
+
+	var getChallengeReply *cxrpc.GetChallengeReply
+	if getChallengeReply, err = cl.GetChallenge(action); err != nil {
+		err = fmt.Errorf("Error calling 'GetChallenge' service method:\n%s", err)
+		return
+	}
 
-	if err = cl.Call("OpencxRPC.GetRegistrationString", getRegistrationStringArgs, getRegistrationStringReply); err != nil {
+	if signature, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, getChallengeReply.Challenge.SigHash(), false); err != nil {
 		return
 	}
 
+	nonce = getChallengeReply.Challenge.Nonce
 	return
 }
@@ -0,0 +1,41 @@
+package benchclient
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/match"
+)
+
+// AddPair adds a trading pair to the exchange. The client has to be connected over noise with the
+// exchange's key.
+func (cl *BenchClient) AddPair(pair *match.Pair) (addPairReply *cxrpc.AddPairReply, err error) {
+	addPairReply = new(cxrpc.AddPairReply)
+	addPairArgs := &cxrpc.AddPairArgs{
+		Pair: pair,
+	}
+
+	if err = cl.Call("OpencxRPC.AddPair", addPairArgs, addPairReply); err != nil {
+		err = fmt.Errorf("Error calling 'AddPair' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// SetPairStatus halts, resumes, or delists a trading pair, or makes it cancel-only or auction-only. The
+// client has to be connected over noise with the exchange's key.
+func (cl *BenchClient) SetPairStatus(pair *match.Pair, status match.PairStatus) (setPairStatusReply *cxrpc.SetPairStatusReply, err error) {
+	setPairStatusReply = new(cxrpc.SetPairStatusReply)
+	setPairStatusArgs := &cxrpc.SetPairStatusArgs{
+		Pair:   pair,
+		Status: status,
+	}
+
+	if err = cl.Call("OpencxRPC.SetPairStatus", setPairStatusArgs, setPairStatusReply); err != nil {
+		err = fmt.Errorf("Error calling 'SetPairStatus' service method:\n%s", err)
+		return
+	}
+
+	return
+}
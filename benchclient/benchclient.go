@@ -1,6 +1,8 @@
 package benchclient
 
 import (
+	"sync"
+
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/cxrpc"
 )
@@ -11,6 +13,15 @@ type BenchClient struct {
 	port      uint16
 	RPCClient cxrpc.OpencxClient
 	PrivKey   *koblitz.PrivateKey
+
+	// exchangePubkey is the pubkey that order envelopes are for, which is only asked for once. It's
+	// protected by exchangeMtx.
+	exchangePubkey *[33]byte
+	exchangeMtx    sync.Mutex
+
+	// noise is true if the connection is authenticated with noise with PrivKey, so commands that can be
+	// authorized by the connection don't need signatures
+	noise bool
 }
 
 // SetupBenchClient creates a new BenchClient for use as an RPC Client
@@ -40,6 +51,7 @@ func (cl *BenchClient) SetupBenchNoiseClient(server string, port uint16) (err er
 	cl.RPCClient = noiseClient
 	cl.hostname = server
 	cl.port = port
+	cl.noise = true
 
 	// we set the privkey here because we aren't using a command line to send orders
 	if err = cl.RPCClient.SetupConnection(server, port); err != nil {
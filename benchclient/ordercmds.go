@@ -1,10 +1,12 @@
 package benchclient
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"time"
 
 	"github.com/mit-dci/lit/crypto/koblitz"
-	"golang.org/x/crypto/sha3"
 
 	"github.com/mit-dci/opencx/cxauctionrpc"
 	"github.com/mit-dci/opencx/cxrpc"
@@ -43,8 +45,7 @@ func (cl *BenchClient) OrderAsync(pubkey *koblitz.PublicKey, side match.Side, pa
 	errChan <- func() (err error) {
 		// TODO: this can be refactored to look more like the rest of the code, it's just using channels and works really well so I don't want to mess with it rn
 
-		orderArgs := new(cxrpc.SubmitOrderArgs)
-		orderReply := new(cxrpc.SubmitOrderReply)
+		var orderReply *cxrpc.SubmitOrderReply
 		var newOrder match.LimitOrder
 
 		copy(newOrder.Pubkey[:], pubkey.SerializeCompressed())
@@ -59,36 +60,211 @@ func (cl *BenchClient) OrderAsync(pubkey *koblitz.PublicKey, side match.Side, pa
 		newOrder.AmountHave = amountHave
 		newOrder.AmountWant = uint64(price * float64(amountHave))
 
-		var newOrderBytes []byte
-		if newOrderBytes, err = newOrder.Serialize(); err != nil {
-			err = fmt.Errorf("Error serializing new order: %s", err)
+		if orderReply, err = cl.SubmitOrder(&newOrder); err != nil {
 			return
 		}
 
-		// create e = hash(m)
-		sha3 := sha3.New256()
-		sha3.Write(newOrderBytes)
-		e := sha3.Sum(nil)
+		replyChan <- orderReply
 
-		// Sign order
-		var compactSig []byte
-		if compactSig, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, e, false); err != nil {
-			return
-		}
+		return
+	}()
 
-		orderArgs.Signature = compactSig
-		orderArgs.Order = &newOrder
+	return
+}
 
-		if err = cl.Call("OpencxRPC.SubmitOrder", orderArgs, orderReply); err != nil {
-			err = fmt.Errorf("Error calling 'SubmitOrder' service method:\n%s", err)
-			return
-		}
+// TimeInForceOrderCommand submits an order with a time in force, so the order can be immediate-or-cancel
+// or fill-or-kill instead of resting on the book
+func (cl *BenchClient) TimeInForceOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, price float64, timeInForce match.TimeInForce) (reply *cxrpc.SubmitOrderReply, err error) {
+	newOrder := &match.LimitOrder{
+		Side:        side,
+		AmountHave:  amountHave,
+		AmountWant:  uint64(price * float64(amountHave)),
+		TimeInForce: timeInForce,
+	}
+	copy(newOrder.Pubkey[:], pubkey.SerializeCompressed())
 
-		replyChan <- orderReply
+	if err = newOrder.TradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
 
+	if reply, err = cl.SubmitOrder(newOrder); err != nil {
 		return
-	}()
+	}
+
+	return
+}
+
+// SelfTradeOrderCommand submits an order with a time in force and a self-trade prevention mode, which
+// decides what happens if the order would match another order from the same pubkey
+func (cl *BenchClient) SelfTradeOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, price float64, timeInForce match.TimeInForce, selfTrade match.SelfTradePrevention) (reply *cxrpc.SubmitOrderReply, err error) {
+	newOrder := &match.LimitOrder{
+		Side:        side,
+		AmountHave:  amountHave,
+		AmountWant:  uint64(price * float64(amountHave)),
+		TimeInForce: timeInForce,
+		SelfTrade:   selfTrade,
+	}
+	copy(newOrder.Pubkey[:], pubkey.SerializeCompressed())
+
+	if err = newOrder.TradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
+
+	if reply, err = cl.SubmitOrder(newOrder); err != nil {
+		return
+	}
+
+	return
+}
+
+// ExpiringOrderCommand submits a good till cancel order that is cancelled and refunded if it's still on the
+// book at expiry
+func (cl *BenchClient) ExpiringOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, price float64, expiry time.Time) (reply *cxrpc.SubmitOrderReply, err error) {
+	newOrder := &match.LimitOrder{
+		Side:       side,
+		AmountHave: amountHave,
+		AmountWant: uint64(price * float64(amountHave)),
+		Expiry:     uint64(expiry.Unix()),
+	}
+	copy(newOrder.Pubkey[:], pubkey.SerializeCompressed())
+
+	if err = newOrder.TradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
+
+	if reply, err = cl.SubmitOrder(newOrder); err != nil {
+		return
+	}
+
+	return
+}
+
+// MarketOrderCommand submits a market order, which is an immediate-or-cancel order priced at the best price
+// on the other side of the book, moved by the slippage in basis points. Whatever isn't matched within the
+// slippage is refunded.
+func (cl *BenchClient) MarketOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, slippage uint64) (reply *cxrpc.SubmitOrderReply, err error) {
+	var tradingPair match.Pair
+	if err = tradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
+
+	// Only the best level on the other side is needed
+	var depthReply *cxrpc.GetDepthReply
+	if depthReply, err = cl.GetDepth(pair, 1); err != nil {
+		err = fmt.Errorf("Error getting depth for market order: %s", err)
+		return
+	}
+
+	oppositeSide := match.Buy
+	if side == match.Buy {
+		oppositeSide = match.Sell
+	}
+
+	var bestOpposite *match.Price
+	if bestOpposite, err = depthReply.Depth.BestPrice(oppositeSide); err != nil {
+		err = fmt.Errorf("Error getting best price for market order: %s", err)
+		return
+	}
+
+	var pubkeyBytes [33]byte
+	copy(pubkeyBytes[:], pubkey.SerializeCompressed())
+
+	var newOrder *match.LimitOrder
+	if newOrder, err = match.NewMarketOrder(pubkeyBytes, side, tradingPair, amountHave, bestOpposite, slippage); err != nil {
+		err = fmt.Errorf("Error creating market order: %s", err)
+		return
+	}
+
+	if reply, err = cl.SubmitOrder(newOrder); err != nil {
+		return
+	}
+
+	return
+}
+
+// envelopeLifetime is how long the envelopes the client signs can be used for
+const envelopeLifetime = time.Hour
+
+// SubmitOrder signs an envelope for an order and submits it, with a random nonce
+func (cl *BenchClient) SubmitOrder(order *match.LimitOrder) (reply *cxrpc.SubmitOrderReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	var exchangePubkey [33]byte
+	if exchangePubkey, err = cl.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey for order envelope: %s", err)
+		return
+	}
+
+	var nonce uint64
+	if nonce, err = newEnvelopeNonce(); err != nil {
+		return
+	}
+
+	var envelope *match.OrderEnvelope
+	if envelope, err = match.NewLimitOrderEnvelope(order, exchangePubkey, nonce, uint64(time.Now().Add(envelopeLifetime).Unix())); err != nil {
+		err = fmt.Errorf("Error creating envelope for new order: %s", err)
+		return
+	}
+
+	// Sign envelope
+	var compactSig []byte
+	if compactSig, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, envelope.SigHash(), false); err != nil {
+		return
+	}
+
+	orderArgs := &cxrpc.SubmitOrderArgs{
+		Order:     order,
+		Envelope:  envelope,
+		Signature: compactSig,
+	}
+	reply = new(cxrpc.SubmitOrderReply)
+	if err = cl.Call("OpencxRPC.SubmitOrder", orderArgs, reply); err != nil {
+		err = fmt.Errorf("Error calling 'SubmitOrder' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetExchangePubkey returns the pubkey that order envelopes have to be for, asking the exchange for it
+// the first time
+func (cl *BenchClient) GetExchangePubkey() (pubkey [33]byte, err error) {
+	cl.exchangeMtx.Lock()
+	if cl.exchangePubkey != nil {
+		pubkey = *cl.exchangePubkey
+		cl.exchangeMtx.Unlock()
+		return
+	}
+
+	getExchangePubkeyReply := new(cxrpc.GetExchangePubkeyReply)
+	if err = cl.Call("OpencxRPC.GetExchangePubkey", &cxrpc.GetExchangePubkeyArgs{}, getExchangePubkeyReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetExchangePubkey' service method:\n%s", err)
+		cl.exchangeMtx.Unlock()
+		return
+	}
+
+	pubkey = getExchangePubkeyReply.Pubkey
+	cl.exchangePubkey = &pubkey
+	cl.exchangeMtx.Unlock()
+	return
+}
 
+// newEnvelopeNonce returns a random nonce for an order envelope, so the client doesn't have to remember
+// which ones it used
+func newEnvelopeNonce() (nonce uint64, err error) {
+	var nonceBytes [8]byte
+	if _, err = rand.Read(nonceBytes[:]); err != nil {
+		err = fmt.Errorf("Error reading random bytes for envelope nonce: %s", err)
+		return
+	}
+	nonce = binary.LittleEndian.Uint64(nonceBytes[:])
 	return
 }
 
@@ -111,7 +287,8 @@ func (cl *BenchClient) GetPrice(assetString string) (getPriceReply *cxrpc.GetPri
 	return
 }
 
-// ViewOrderbook returns the orderbook
+// ViewOrderbook returns the orderbook with every order on it. Only the exchange's key can do this, everyone
+// else should use GetDepth.
 func (cl *BenchClient) ViewOrderbook(assetPair string) (viewOrderbookReply *cxrpc.ViewOrderBookReply, err error) {
 	viewOrderbookReply = new(cxrpc.ViewOrderBookReply)
 	viewOrderBookArgs := &cxrpc.ViewOrderBookArgs{
@@ -131,30 +308,34 @@ func (cl *BenchClient) ViewOrderbook(assetPair string) (viewOrderbookReply *cxrp
 	return
 }
 
-// CancelOrder calls the cancel order rpc command
+// CancelOrder calls the cancel order rpc command, signing an envelope for the cancel so it can't be sent
+// again
 func (cl *BenchClient) CancelOrder(orderID string) (cancelOrderReply *cxrpc.CancelOrderReply, err error) {
-
 	if cl.PrivKey == nil {
 		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
 		return
 	}
 
-	cancelOrderReply = new(cxrpc.CancelOrderReply)
+	// The envelope commits to the order's pair, so get the order first
+	var getOrderReply *cxrpc.GetOrderReply
+	if getOrderReply, err = cl.GetOrder(orderID); err != nil {
+		err = fmt.Errorf("Error getting order to cancel: %s", err)
+		return
+	}
+
 	cancelOrderArgs := &cxrpc.CancelOrderArgs{
 		OrderID: orderID,
 	}
 
-	// create e = hash(m)
-	sha3 := sha3.New256()
-	sha3.Write([]byte(cancelOrderArgs.OrderID))
-	e := sha3.Sum(nil)
-
-	// Sign order
-	compactSig, err := koblitz.SignCompact(koblitz.S256(), cl.PrivKey, e, false)
-
-	cancelOrderArgs.Signature = compactSig
+	cancel := &match.CancelRequest{OrderID: *getOrderReply.Order.OrderID}
+	if cancelOrderArgs.Envelope, cancelOrderArgs.Signature, err = cl.signEnvelope(func(exchangePubkey [33]byte, nonce uint64, expiry uint64) (*match.OrderEnvelope, error) {
+		return match.NewCancelEnvelope(cancel, false, getOrderReply.Order.Order.TradingPair, exchangePubkey, nonce, expiry)
+	}); err != nil {
+		return
+	}
 
 	// Actually use the RPC Client to call the method
+	cancelOrderReply = new(cxrpc.CancelOrderReply)
 	if err = cl.Call("OpencxRPC.CancelOrder", cancelOrderArgs, cancelOrderReply); err != nil {
 		return
 	}
@@ -162,6 +343,93 @@ func (cl *BenchClient) CancelOrder(orderID string) (cancelOrderReply *cxrpc.Canc
 	return
 }
 
+// ReplaceOrder calls the replace order rpc command, amending the amounts of the order with orderID
+func (cl *BenchClient) ReplaceOrder(orderID string, amountHave uint64, amountWant uint64) (replaceOrderReply *cxrpc.ReplaceOrderReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	// The envelope commits to the order's pair, so get the order first
+	var getOrderReply *cxrpc.GetOrderReply
+	if getOrderReply, err = cl.GetOrder(orderID); err != nil {
+		err = fmt.Errorf("Error getting order to replace: %s", err)
+		return
+	}
+
+	replaceOrderArgs := &cxrpc.ReplaceOrderArgs{
+		OrderID:    orderID,
+		AmountHave: amountHave,
+		AmountWant: amountWant,
+	}
+
+	replace := &match.ReplaceRequest{
+		OrderID:    *getOrderReply.Order.OrderID,
+		AmountHave: amountHave,
+		AmountWant: amountWant,
+	}
+	if replaceOrderArgs.Envelope, replaceOrderArgs.Signature, err = cl.signEnvelope(func(exchangePubkey [33]byte, nonce uint64, expiry uint64) (*match.OrderEnvelope, error) {
+		return match.NewReplaceEnvelope(replace, getOrderReply.Order.Order.TradingPair, exchangePubkey, nonce, expiry)
+	}); err != nil {
+		return
+	}
+
+	replaceOrderReply = new(cxrpc.ReplaceOrderReply)
+	if err = cl.Call("OpencxRPC.ReplaceOrder", replaceOrderArgs, replaceOrderReply); err != nil {
+		err = fmt.Errorf("Error calling 'ReplaceOrder' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetOrder calls the get order rpc command, answering a read challenge to show the order is the client's
+func (cl *BenchClient) GetOrder(orderID string) (getOrderReply *cxrpc.GetOrderReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	getOrderArgs := &cxrpc.GetOrderArgs{
+		OrderID: orderID,
+	}
+	if getOrderArgs.Challenge, getOrderArgs.Signature, err = cl.answerChallenge(match.ReadChallenge); err != nil {
+		return
+	}
+
+	getOrderReply = new(cxrpc.GetOrderReply)
+	if err = cl.Call("OpencxRPC.GetOrder", getOrderArgs, getOrderReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetOrder' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// signEnvelope creates an envelope with newEnvelope for this exchange, with a random nonce, and signs it
+func (cl *BenchClient) signEnvelope(newEnvelope func(exchangePubkey [33]byte, nonce uint64, expiry uint64) (*match.OrderEnvelope, error)) (envelope *match.OrderEnvelope, signature []byte, err error) {
+	var exchangePubkey [33]byte
+	if exchangePubkey, err = cl.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey for envelope: %s", err)
+		return
+	}
+
+	var nonce uint64
+	if nonce, err = newEnvelopeNonce(); err != nil {
+		return
+	}
+
+	if envelope, err = newEnvelope(exchangePubkey, nonce, uint64(time.Now().Add(envelopeLifetime).Unix())); err != nil {
+		err = fmt.Errorf("Error creating envelope: %s", err)
+		return
+	}
+
+	if signature, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, envelope.SigHash(), false); err != nil {
+		return
+	}
+	return
+}
+
 // GetPairs gets the available trading pairs
 func (cl *BenchClient) GetPairs() (getPairsReply *cxrpc.GetPairsReply, err error) {
 	getPairsReply = new(cxrpc.GetPairsReply)
@@ -174,11 +442,24 @@ func (cl *BenchClient) GetPairs() (getPairsReply *cxrpc.GetPairsReply, err error
 	return
 }
 
-// AuctionOrderCommand submits an order synchronously. Uses asynchronous order function
-func (cl *BenchClient) AuctionOrderCommand(pubkey *koblitz.PublicKey, side string, pair string, amountHave uint64, price float64, t uint64, auctionID [32]byte) (reply *cxauctionrpc.SubmitPuzzledOrderReply, err error) {
+// GetFeeRevenue gets how much of each asset the exchange has been paid in trading fees
+func (cl *BenchClient) GetFeeRevenue() (getFeeRevenueReply *cxrpc.GetFeeRevenueReply, err error) {
+	getFeeRevenueReply = new(cxrpc.GetFeeRevenueReply)
+	getFeeRevenueArgs := new(cxrpc.GetFeeRevenueArgs)
+
+	if err = cl.Call("OpencxRPC.GetFeeRevenue", getFeeRevenueArgs, getFeeRevenueReply); err != nil {
+		return
+	}
+
+	return
+}
+
+// AuctionOrderCommand submits an order synchronously. Uses asynchronous order function.
+// The order's envelope is signed for exchangePubkey, which is in the exchange's public parameters.
+func (cl *BenchClient) AuctionOrderCommand(pubkey *koblitz.PublicKey, side string, pair string, amountHave uint64, price float64, t uint64, auctionID [32]byte, exchangePubkey [33]byte) (reply *cxauctionrpc.SubmitPuzzledOrderReply, err error) {
 	errorChannel := make(chan error, 1)
 	replyChannel := make(chan *cxauctionrpc.SubmitPuzzledOrderReply, 1)
-	go cl.AuctionOrderAsync(pubkey, side, pair, amountHave, price, t, auctionID, replyChannel, errorChannel)
+	go cl.AuctionOrderAsync(pubkey, side, pair, amountHave, price, t, auctionID, exchangePubkey, replyChannel, errorChannel)
 	// wait on either the reply or error, whichever comes first. If error is nil wait for reply. That's why the for loop is there. We don't care if the reply is nil, it shouldn't be, but that's sort of just so go-vet doesn't yell at us for having an unreachable return.
 	for reply == nil {
 		select {
@@ -195,7 +476,7 @@ func (cl *BenchClient) AuctionOrderCommand(pubkey *koblitz.PublicKey, side strin
 }
 
 // AuctionOrderAsync is supposed to be run in a separate goroutine, AuctionOrderCommand makes this synchronous however
-func (cl *BenchClient) AuctionOrderAsync(pubkey *koblitz.PublicKey, side string, pair string, amountHave uint64, price float64, t uint64, auctionID [32]byte, replyChan chan *cxauctionrpc.SubmitPuzzledOrderReply, errChan chan error) {
+func (cl *BenchClient) AuctionOrderAsync(pubkey *koblitz.PublicKey, side string, pair string, amountHave uint64, price float64, t uint64, auctionID [32]byte, exchangePubkey [33]byte, replyChan chan *cxauctionrpc.SubmitPuzzledOrderReply, errChan chan error) {
 
 	if cl.PrivKey == nil {
 		errChan <- fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
@@ -231,14 +512,20 @@ func (cl *BenchClient) AuctionOrderAsync(pubkey *koblitz.PublicKey, side string,
 
 		newAuctionOrder.SetAmountWant(price)
 
-		// create e = hash(m)
-		sha3 := sha3.New256()
-		sha3.Write(newAuctionOrder.SerializeSignable())
-		e := sha3.Sum(nil)
+		var nonce uint64
+		if nonce, err = newEnvelopeNonce(); err != nil {
+			return
+		}
 
-		// Sign order
+		// The envelope goes in the puzzle with the order, so it has to last until the auction is over
+		if newAuctionOrder.Envelope, err = match.NewAuctionOrderEnvelope(&newAuctionOrder, exchangePubkey, nonce, uint64(time.Now().Add(envelopeLifetime).Unix())); err != nil {
+			err = fmt.Errorf("Error creating envelope for auction order: %s", err)
+			return
+		}
+
+		// Sign envelope
 		var compactSig []byte
-		if compactSig, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, e, false); err != nil {
+		if compactSig, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, newAuctionOrder.Envelope.SigHash(), false); err != nil {
 			return
 		}
 
@@ -268,3 +555,22 @@ func (cl *BenchClient) AuctionOrderAsync(pubkey *koblitz.PublicKey, side string,
 
 	return
 }
+
+// GetDepth returns the orderbook for a pair added up by price, with at most levels prices on each side
+func (cl *BenchClient) GetDepth(assetPair string, levels uint64) (getDepthReply *cxrpc.GetDepthReply, err error) {
+	getDepthReply = new(cxrpc.GetDepthReply)
+	getDepthArgs := &cxrpc.GetDepthArgs{
+		TradingPair: new(match.Pair),
+		Levels:      levels,
+	}
+
+	if err = getDepthArgs.TradingPair.FromString(assetPair); err != nil {
+		return
+	}
+
+	if err = cl.Call("OpencxRPC.GetDepth", getDepthArgs, getDepthReply); err != nil {
+		return
+	}
+
+	return
+}
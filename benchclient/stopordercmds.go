@@ -0,0 +1,180 @@
+package benchclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/match"
+)
+
+// StopLimitOrderCommand submits a stop-limit order, which places a limit order at price once the last trade
+// price reaches stopPrice
+func (cl *BenchClient) StopLimitOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, stopPrice float64, price float64, timeInForce match.TimeInForce) (reply *cxrpc.SubmitStopOrderReply, err error) {
+	stop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        side,
+			AmountHave:  amountHave,
+			AmountWant:  uint64(price * float64(amountHave)),
+			TimeInForce: timeInForce,
+		},
+		StopPrice: (&match.Price{AmountWant: uint64(stopPrice * float64(amountHave)), AmountHave: amountHave}).Reduce(),
+	}
+	copy(stop.Order.Pubkey[:], pubkey.SerializeCompressed())
+
+	if err = stop.Order.TradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
+
+	if reply, err = cl.SubmitStopOrder(stop); err != nil {
+		return
+	}
+
+	return
+}
+
+// StopLossOrderCommand submits a stop-loss order, which places a market order with the slippage, in basis
+// points, once the last trade price reaches stopPrice
+func (cl *BenchClient) StopLossOrderCommand(pubkey *koblitz.PublicKey, side match.Side, pair string, amountHave uint64, stopPrice float64, slippage uint64) (reply *cxrpc.SubmitStopOrderReply, err error) {
+	stop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:       side,
+			AmountHave: amountHave,
+		},
+		StopPrice: (&match.Price{AmountWant: uint64(stopPrice * float64(amountHave)), AmountHave: amountHave}).Reduce(),
+		Market:    true,
+		Slippage:  slippage,
+	}
+	copy(stop.Order.Pubkey[:], pubkey.SerializeCompressed())
+
+	if err = stop.Order.TradingPair.FromString(pair); err != nil {
+		err = fmt.Errorf("Error getting asset pair from string: \n%s", err)
+		return
+	}
+
+	if reply, err = cl.SubmitStopOrder(stop); err != nil {
+		return
+	}
+
+	return
+}
+
+// SubmitStopOrder signs an envelope for a stop order and submits it, with a random nonce
+func (cl *BenchClient) SubmitStopOrder(stop *match.StopOrder) (reply *cxrpc.SubmitStopOrderReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	var exchangePubkey [33]byte
+	if exchangePubkey, err = cl.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey for stop order envelope: %s", err)
+		return
+	}
+
+	var nonce uint64
+	if nonce, err = newEnvelopeNonce(); err != nil {
+		return
+	}
+
+	var envelope *match.OrderEnvelope
+	if envelope, err = match.NewStopOrderEnvelope(stop, exchangePubkey, nonce, uint64(time.Now().Add(envelopeLifetime).Unix())); err != nil {
+		err = fmt.Errorf("Error creating envelope for new stop order: %s", err)
+		return
+	}
+
+	// Sign envelope
+	var compactSig []byte
+	if compactSig, err = koblitz.SignCompact(koblitz.S256(), cl.PrivKey, envelope.SigHash(), false); err != nil {
+		return
+	}
+
+	stopArgs := &cxrpc.SubmitStopOrderArgs{
+		Order:     stop,
+		Envelope:  envelope,
+		Signature: compactSig,
+	}
+	reply = new(cxrpc.SubmitStopOrderReply)
+	if err = cl.Call("OpencxRPC.SubmitStopOrder", stopArgs, reply); err != nil {
+		err = fmt.Errorf("Error calling 'SubmitStopOrder' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetStopOrders gets the stop orders for the client's key that haven't been triggered or cancelled
+func (cl *BenchClient) GetStopOrders() (getStopOrdersReply *cxrpc.GetStopOrdersReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	getStopOrdersArgs := new(cxrpc.GetStopOrdersArgs)
+	if getStopOrdersArgs.Challenge, getStopOrdersArgs.Signature, err = cl.answerChallenge(match.ReadChallenge); err != nil {
+		return
+	}
+
+	getStopOrdersReply = new(cxrpc.GetStopOrdersReply)
+	if err = cl.Call("OpencxRPC.GetStopOrders", getStopOrdersArgs, getStopOrdersReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetStopOrders' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// CancelStopOrder calls the cancel stop order rpc command
+func (cl *BenchClient) CancelStopOrder(orderID string) (cancelStopOrderReply *cxrpc.CancelStopOrderReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	// The envelope commits to the stop order's pair, so find it first
+	var getStopOrdersReply *cxrpc.GetStopOrdersReply
+	if getStopOrdersReply, err = cl.GetStopOrders(); err != nil {
+		err = fmt.Errorf("Error getting stop orders to cancel: %s", err)
+		return
+	}
+
+	var unmarshalledOrderID match.OrderID
+	if err = unmarshalledOrderID.UnmarshalText([]byte(orderID)); err != nil {
+		err = fmt.Errorf("Error unmarshalling stop order ID to cancel: %s", err)
+		return
+	}
+
+	var stop *match.StopOrderIDPair
+	for _, stopOrder := range getStopOrdersReply.Orders {
+		if *stopOrder.OrderID == unmarshalledOrderID {
+			stop = stopOrder
+			break
+		}
+	}
+
+	if stop == nil {
+		err = fmt.Errorf("No stop order with ID %s to cancel", orderID)
+		return
+	}
+
+	cancelStopOrderArgs := &cxrpc.CancelStopOrderArgs{
+		OrderID: orderID,
+	}
+
+	cancel := &match.CancelRequest{OrderID: unmarshalledOrderID}
+	if cancelStopOrderArgs.Envelope, cancelStopOrderArgs.Signature, err = cl.signEnvelope(func(exchangePubkey [33]byte, nonce uint64, expiry uint64) (*match.OrderEnvelope, error) {
+		return match.NewCancelEnvelope(cancel, true, stop.Order.Order.TradingPair, exchangePubkey, nonce, expiry)
+	}); err != nil {
+		return
+	}
+
+	cancelStopOrderReply = new(cxrpc.CancelStopOrderReply)
+	if err = cl.Call("OpencxRPC.CancelStopOrder", cancelStopOrderArgs, cancelStopOrderReply); err != nil {
+		return
+	}
+
+	return
+}
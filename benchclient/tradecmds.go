@@ -0,0 +1,108 @@
+package benchclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/cxauctionrpc"
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetRecentTrades gets the latest trades on a pair, newest first. If limit is zero the exchange decides how
+// many to return.
+func (cl *BenchClient) GetRecentTrades(assetPair string, limit uint64) (getRecentTradesReply *cxrpc.GetRecentTradesReply, err error) {
+	getRecentTradesReply = new(cxrpc.GetRecentTradesReply)
+	getRecentTradesArgs := &cxrpc.GetRecentTradesArgs{
+		TradingPair: new(match.Pair),
+		Limit:       limit,
+	}
+
+	if err = getRecentTradesArgs.TradingPair.FromString(assetPair); err != nil {
+		return
+	}
+
+	if err = cl.Call("OpencxRPC.GetRecentTrades", getRecentTradesArgs, getRecentTradesReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetRecentTrades' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetMyFills gets the trades that the client's orders were in, newest first, and at most limit of them. If
+// cursor isn't nil, it's the Next of the reply for the last page.
+func (cl *BenchClient) GetMyFills(limit uint64, cursor *match.FillsCursor) (getMyFillsReply *cxrpc.GetMyFillsReply, err error) {
+	if cl.PrivKey == nil {
+		err = fmt.Errorf("Private key nonexistent, set or specify private key so the client can sign commands")
+		return
+	}
+
+	getMyFillsArgs := &cxrpc.GetMyFillsArgs{
+		Limit:  limit,
+		Cursor: cursor,
+	}
+	if getMyFillsArgs.Challenge, getMyFillsArgs.Signature, err = cl.answerChallenge(match.ReadChallenge); err != nil {
+		return
+	}
+
+	getMyFillsReply = new(cxrpc.GetMyFillsReply)
+	if err = cl.Call("OpencxRPC.GetMyFills", getMyFillsArgs, getMyFillsReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetMyFills' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetCandles gets the candles for a pair and interval that start at or after start and before end, oldest
+// first. If end is zero there is no end.
+func (cl *BenchClient) GetCandles(assetPair string, interval string, start time.Time, end time.Time) (getCandlesReply *cxrpc.GetCandlesReply, err error) {
+	getCandlesReply = new(cxrpc.GetCandlesReply)
+	getCandlesArgs := &cxrpc.GetCandlesArgs{
+		TradingPair: new(match.Pair),
+		Start:       start,
+		End:         end,
+	}
+
+	if err = getCandlesArgs.TradingPair.FromString(assetPair); err != nil {
+		return
+	}
+
+	if err = getCandlesArgs.Interval.FromString(interval); err != nil {
+		return
+	}
+
+	if err = cl.Call("OpencxRPC.GetCandles", getCandlesArgs, getCandlesReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetCandles' service method:\n%s", err)
+		return
+	}
+
+	return
+}
+
+// GetAuctionCandles gets the candles made from the auctions on a pair, for an interval, that start at or
+// after start and before end, oldest first. If end is zero there is no end.
+func (cl *BenchClient) GetAuctionCandles(assetPair string, interval string, start time.Time, end time.Time) (getCandlesReply *cxauctionrpc.GetCandlesReply, err error) {
+	getCandlesReply = new(cxauctionrpc.GetCandlesReply)
+	getCandlesArgs := &cxauctionrpc.GetCandlesArgs{
+		TradingPair: new(match.Pair),
+		Start:       start,
+		End:         end,
+	}
+
+	if err = getCandlesArgs.TradingPair.FromString(assetPair); err != nil {
+		return
+	}
+
+	if err = getCandlesArgs.Interval.FromString(interval); err != nil {
+		return
+	}
+
+	if err = cl.Call("OpencxAuctionRPC.GetCandles", getCandlesArgs, getCandlesReply); err != nil {
+		err = fmt.Errorf("Error calling 'GetCandles' service method:\n%s", err)
+		return
+	}
+
+	return
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"io/ioutil"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/mit-dci/lit/coinparam"
@@ -13,7 +15,10 @@ import (
 	"github.com/mit-dci/opencx/cxauctionrpc"
 	"github.com/mit-dci/opencx/cxauctionserver"
 	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbfile"
 	"github.com/mit-dci/opencx/cxdb/cxdbsql"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/cxwebsocket"
 	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 )
@@ -61,12 +66,30 @@ type frredConfig struct {
 	// auth or unauth rpc?
 	AuthenticatedRPC bool `long:"authrpc" description:"Whether or not to use authenticated RPC"`
 
+	// port to publish market data on
+	PubSubPort uint16 `long:"pubsubport" description:"Port to publish the book, trades, and auction results of each pair on over noise. Market data isn't published if it's 0"`
+
+	// port to serve RPC and market data over websockets on
+	WebSocketPort uint16 `long:"wsport" description:"Port to serve RPC commands as JSON-RPC and push market data over websockets on, on the RPC host, for browsers. Websockets aren't served if it's 0"`
+
 	// support lightning or not to support lightning?
 	LightningSupport bool `long:"lightning" description:"Whether or not to support lightning on the exchange"`
 
 	// Auction server options
 	AuctionTime  uint64 `long:"auctiontime" description:"Time it should take to generate a timelock puzzle protected order"`
 	MaxBatchSize uint64 `long:"maxbatchsize" description:"Maximum number of orders that can go in a batch"`
+
+	// write auction placements and matches to an event log?
+	Journal bool `long:"journal" description:"Whether or not to write auction placements and matches to a hash chained journal in the root directory"`
+
+	// trading fees charged for each pair
+	FeeFile string `long:"feefile" description:"Name of a json file in the root directory with the fee account and the maker and taker fees for each pair. No fees are charged without one"`
+
+	// assets and pairs to support instead of the built in ones
+	AssetFile string `long:"assetfile" description:"Name of a json file in the root directory with the assets to support and the pairs to trade them in. The built in assets and every pair of them are used without one"`
+
+	// tick size, lot size, min value, and max size for each pair
+	PairFile string `long:"pairfile" description:"Name of a json file in the root directory with the rules orders have to follow for each pair. Any order is accepted without one"`
 }
 
 var (
@@ -84,6 +107,12 @@ var (
 	// Yes we want to use noise-rpc
 	defaultAuthenticatedRPC = true
 
+	// Yes we want to publish market data
+	defaultPubSubPort = uint16(12347)
+
+	// Yes we want websockets
+	defaultWebSocketPort = uint16(12349)
+
 	// Yes we want lightning
 	defaultLightningSupport = true
 
@@ -110,6 +139,8 @@ func main() {
 		Lithost:          defaultLithost,
 		Litport:          defaultLitport,
 		AuthenticatedRPC: defaultAuthenticatedRPC,
+		PubSubPort:       defaultPubSubPort,
+		WebSocketPort:    defaultWebSocketPort,
 		LightningSupport: defaultLightningSupport,
 		AuctionTime:      defaultAuctionTime,
 		MaxBatchSize:     defaultMaxBatchSize,
@@ -118,6 +149,23 @@ func main() {
 	// Check and load config params
 	key := opencxSetup(&conf)
 
+	// Assets have to be registered before anything uses them
+	if conf.AssetFile != "" {
+		var assetData []byte
+		if assetData, err = ioutil.ReadFile(filepath.Join(conf.FrredHomeDir, conf.AssetFile)); err != nil {
+			logging.Fatalf("Error reading asset file for frred: %s", err)
+		}
+
+		var registry *match.AssetRegistry
+		if registry, err = match.ParseAssetConfig(assetData); err != nil {
+			logging.Fatalf("Error parsing asset file for frred: %s", err)
+		}
+
+		if err = match.SetAssetRegistry(registry); err != nil {
+			logging.Fatalf("Error setting asset registry for frred: %s", err)
+		}
+	}
+
 	// Generate the coin list based on the parameters we know
 	coinList := generateCoinList(&conf)
 
@@ -152,12 +200,91 @@ func main() {
 		logging.Fatalf("Error creating batcher map: %s", err)
 	}
 
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+		logging.Fatalf("Error creating candle store map: %s", err)
+	}
+
 	// Anyways, here's where we set the server
 	var frredServer *cxauctionserver.OpencxAuctionServer
-	if frredServer, err = cxauctionserver.InitServer(setEngines, mengines, auctionBooks, puzzleStores, batchers, 100, conf.AuctionTime); err != nil {
+	if frredServer, err = cxauctionserver.InitServer(setEngines, mengines, auctionBooks, puzzleStores, batchers, candleStores, 100, conf.AuctionTime); err != nil {
 		logging.Fatalf("Error initializing server: \n%s", err)
 	}
 
+	// Auction orders have to be signed for this exchange's key, and can only be placed once
+	_, exchangePubkey := koblitz.PrivKeyFromBytes(koblitz.S256(), key[:])
+	if err = frredServer.SetExchangePubkey(exchangePubkey); err != nil {
+		logging.Fatalf("Error setting exchange pubkey for frred: %s", err)
+	}
+
+	var nonceStore cxdb.NonceStore
+	if nonceStore, err = cxdbsql.CreateNonceStore(); err != nil {
+		logging.Fatalf("Error creating nonce store for frred: %s", err)
+	}
+
+	if err = frredServer.SetNonceStore(nonceStore); err != nil {
+		logging.Fatalf("Error setting nonce store for frred: %s", err)
+	}
+
+	if conf.FeeFile != "" {
+		var feeData []byte
+		if feeData, err = ioutil.ReadFile(filepath.Join(conf.FrredHomeDir, conf.FeeFile)); err != nil {
+			logging.Fatalf("Error reading fee file for frred: %s", err)
+		}
+
+		var feeAccount [33]byte
+		var feeSchedules map[match.Pair]*match.FeeSchedule
+		if feeAccount, feeSchedules, err = match.ParseFeeConfig(feeData, pairList); err != nil {
+			logging.Fatalf("Error parsing fee file for frred: %s", err)
+		}
+
+		if err = frredServer.SetFees(feeAccount, feeSchedules); err != nil {
+			logging.Fatalf("Error setting fees for frred: %s", err)
+		}
+	}
+
+	if conf.PairFile != "" {
+		var pairData []byte
+		if pairData, err = ioutil.ReadFile(filepath.Join(conf.FrredHomeDir, conf.PairFile)); err != nil {
+			logging.Fatalf("Error reading pair file for frred: %s", err)
+		}
+
+		var pairRules map[match.Pair]*match.PairRules
+		if pairRules, err = match.ParsePairRulesConfig(pairData, pairList); err != nil {
+			logging.Fatalf("Error parsing pair file for frred: %s", err)
+		}
+
+		if err = frredServer.SetPairRules(pairRules); err != nil {
+			logging.Fatalf("Error setting pair rules for frred: %s", err)
+		}
+	}
+
+	if conf.Journal {
+		var journal cxdb.Journal
+		if journal, err = cxdbfile.CreateJournal(filepath.Join(conf.FrredHomeDir, defaultJournalFilename)); err != nil {
+			logging.Fatalf("Error opening journal for frred: %s", err)
+		}
+
+		if err = frredServer.SetJournal(journal); err != nil {
+			logging.Fatalf("Error setting journal for frred: %s", err)
+		}
+	}
+
+	var publisher *cxpubsub.Publisher
+	if conf.PubSubPort != 0 || conf.WebSocketPort != 0 {
+		publisher = cxpubsub.NewPublisher()
+		if err = frredServer.SetPublisher(publisher); err != nil {
+			logging.Fatalf("Error setting publisher for frred: %s", err)
+		}
+	}
+
+	if conf.PubSubPort != 0 {
+		privkey, _ := koblitz.PrivKeyFromBytes(koblitz.S256(), key[:])
+		if err = publisher.NoiseListen(privkey, conf.PubSubPort); err != nil {
+			logging.Fatalf("Error listening for market data connections for frred: %s", err)
+		}
+	}
+
 	if err = frredServer.StartClockRandomAuction(); err != nil {
 		logging.Fatalf("Error starting clock: %s", err)
 	}
@@ -168,6 +295,18 @@ func main() {
 		logging.Fatalf("Error creating rpc caller for server: %s", err)
 	}
 
+	// Serve RPC commands and market data over websockets, for browsers
+	var wsGateway *cxwebsocket.Gateway
+	if conf.WebSocketPort != 0 {
+		if wsGateway, err = cxwebsocket.CreateGateway(&cxauctionrpc.OpencxAuctionRPC{Server: frredServer}, publisher); err != nil {
+			logging.Fatalf("Error creating websocket gateway for server: %s", err)
+		}
+
+		if err = wsGateway.WebSocketListen(conf.Rpchost, conf.WebSocketPort); err != nil {
+			logging.Fatalf("Error listening for websocket connections for server: %s", err)
+		}
+	}
+
 	// SIGINT and SIGTERM and SIGQUIT handler for CTRL-c, KILL, CTRL-/, etc.
 	go func() {
 		logging.Infof("Notifying signals")
@@ -179,6 +318,13 @@ func main() {
 			signal := <-sigs
 			logging.Infof("Received %s signal, Stopping server gracefully...", signal.String())
 
+			// stop websocket gateway
+			if wsGateway != nil {
+				if err = wsGateway.Stop(); err != nil {
+					logging.Errorf("Error stopping websocket gateway: %s", err)
+				}
+			}
+
 			// send off button to off button
 			if err = rpcListener.KillServerNoWait(); err != nil {
 				logging.Fatalf("Error killing server: %s", err)
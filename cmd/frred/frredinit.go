@@ -12,16 +12,18 @@ import (
 	"github.com/mit-dci/lit/lnutil"
 	litLogging "github.com/mit-dci/lit/logging"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 )
 
 var (
 
 	// used in init file, so separate
-	defaultLogLevel       = 0
-	defaultLitLogLevel    = 0
-	defaultConfigFilename = "frred.conf"
-	defaultLogFilename    = "dblog.txt"
-	defaultKeyFileName    = "privkey.hex"
+	defaultLogLevel        = 0
+	defaultLitLogLevel     = 0
+	defaultConfigFilename  = "frred.conf"
+	defaultLogFilename     = "dblog.txt"
+	defaultKeyFileName     = "privkey.hex"
+	defaultJournalFilename = "journal.dat"
 )
 
 // createDefaultConfigFile creates a config file  -- only call this if the
@@ -151,5 +153,22 @@ func generateHostParams(conf *frredConfig) (hostParamList []*util.HostParams) {
 	hostParamList = append(hostParamList, &util.HostParams{Param: &coinparam.RegressionNetParams, Host: conf.Reghost})
 	hostParamList = append(hostParamList, &util.HostParams{Param: &coinparam.VertcoinRegTestParams, Host: conf.Rtvtchost})
 	hostParamList = append(hostParamList, &util.HostParams{Param: &coinparam.LiteRegNetParams, Host: conf.Litereghost})
+
+	// Assets from the asset file can have a host, for chains that don't have a flag
+	for _, asset := range match.GetAssetRegistry().Assets() {
+		if asset.Host == "" {
+			continue
+		}
+
+		hasHost := false
+		for _, hostParam := range hostParamList {
+			if hostParam.Param == asset.Params && hostParam.Host != "" {
+				hasHost = true
+			}
+		}
+		if !hasHost {
+			hostParamList = append(hostParamList, &util.HostParams{Param: asset.Params, Host: asset.Host})
+		}
+	}
 	return
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/mit-dci/lit/lnutil"
 	"github.com/mit-dci/opencx/cxrpc"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 )
 
 var registerCommand = &Command{
@@ -22,19 +23,19 @@ func (cl *ocxClient) Register(args []string) (err error) {
 	if err = cl.UnlockKey(); err != nil {
 		logging.Fatalf("Could not unlock key! Fatal!")
 	}
-	var regStringReply *cxrpc.GetRegistrationStringReply
-	if regStringReply, err = cl.RPCClient.GetRegistrationString(); err != nil {
+	var challengeReply *cxrpc.GetChallengeReply
+	if challengeReply, err = cl.RPCClient.GetChallenge(match.RegisterChallenge); err != nil {
 		return
 	}
 
 	var sig []byte
-	if sig, err = cl.SignBytes([]byte(regStringReply.RegistrationString)); err != nil {
+	if sig, err = cl.SignBytes(challengeReply.Challenge.Serialize()); err != nil {
 		return
 	}
 
 	// if there is ever a reply for register uncomment this and replace the _
 	// var registerReply *cxrpc.RegisterReply
-	if _, err = cl.RPCClient.Register(sig); err != nil {
+	if _, err = cl.RPCClient.Register(challengeReply.Challenge.Nonce, sig); err != nil {
 		return
 	}
 
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/lnutil"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+var addPairCommand = &Command{
+	Format: fmt.Sprintf("%s%s\n", lnutil.Red("addpair"), lnutil.ReqColor("pair")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Add trading pair \"asset1\"/\"asset2\" while the exchange is running. Both assets have to be supported by the exchange.",
+		"This is an admin command, so ocx has to use the exchange's key and authenticated RPC.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Add a trading pair to the exchange (admin)."),
+}
+
+// AddPair calls the add pair rpc command
+func (cl *ocxClient) AddPair(args []string) (err error) {
+	pair := new(match.Pair)
+	if err = pair.FromString(args[0]); err != nil {
+		err = fmt.Errorf("Error parsing pair, please enter something valid: %s", err)
+		return
+	}
+
+	if _, err = cl.RPCClient.AddPair(pair); err != nil {
+		return
+	}
+
+	logging.Infof("Added pair %s successfully", pair.PrettyString())
+	return
+}
+
+var setPairStatusCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s\n", lnutil.Red("setpairstatus"), lnutil.ReqColor("pair"), lnutil.ReqColor("status")),
+	Description: fmt.Sprintf("%s\n%s\n%s\n",
+		"Set the status of trading pair \"asset1\"/\"asset2\" to \"trading\", \"halted\", \"cancelonly\", \"auctiononly\", or \"delisted\".",
+		"Halted pairs don't accept or cancel orders, and auction-only pairs accept orders but only match them once they are set back to trading. Delisting a pair cancels and refunds every order on it.",
+		"This is an admin command, so ocx has to use the exchange's key and authenticated RPC.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Halt, resume, or delist a trading pair (admin)."),
+}
+
+// SetPairStatus calls the set pair status rpc command
+func (cl *ocxClient) SetPairStatus(args []string) (err error) {
+	pair := new(match.Pair)
+	if err = pair.FromString(args[0]); err != nil {
+		err = fmt.Errorf("Error parsing pair, please enter something valid: %s", err)
+		return
+	}
+
+	var status match.PairStatus
+	if err = status.FromString(args[1]); err != nil {
+		err = fmt.Errorf("Error parsing status, please enter something valid: %s", err)
+		return
+	}
+
+	if _, err = cl.RPCClient.SetPairStatus(pair, status); err != nil {
+		return
+	}
+
+	logging.Infof("Set status of pair %s to %s successfully", pair.PrettyString(), status.String())
+	return
+}
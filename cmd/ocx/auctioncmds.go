@@ -60,7 +60,7 @@ func (cl *ocxClient) AuctionOrderCommand(args []string) (err error) {
 
 	// we ignore reply because there's nothing in it and we don't use it
 	// var reply *cxauctionrpc.SubmitPuzzledOrderReply
-	if _, err = cl.RPCClient.AuctionOrderCommand(pubkey, side, pair, amountHave, price, paramreply.AuctionTime, paramreply.AuctionID); err != nil {
+	if _, err = cl.RPCClient.AuctionOrderCommand(pubkey, side, pair, amountHave, price, paramreply.AuctionTime, paramreply.AuctionID, paramreply.ExchangePubkey); err != nil {
 		return
 	}
 
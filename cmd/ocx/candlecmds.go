@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/lit/lnutil"
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+	"github.com/olekukonko/tablewriter"
+)
+
+// defaultCandles is how many intervals back getcandles goes if no duration is given
+const defaultCandles = 60
+
+var getCandlesCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s\n", lnutil.Red("getcandles"), lnutil.ReqColor("pair"), lnutil.ReqColor("interval"), lnutil.OptColor("duration")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Show the open, high, low, and close price and the volume of trading pair \"asset1\"/\"asset2\" for every \"1m\", \"5m\", \"1h\", or \"1d\" interval, oldest first.",
+		"Candles from the last duration (for example 30m or 24h) are shown, which is 60 intervals if it isn't specified.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Show the candles for a pair."),
+}
+
+// GetCandles prints the candles for a pair
+func (cl *ocxClient) GetCandles(args []string) (err error) {
+	var interval match.CandleInterval
+	if err = interval.FromString(args[1]); err != nil {
+		err = fmt.Errorf("Error parsing interval, please enter something valid: %s", err)
+		return
+	}
+
+	duration := defaultCandles * interval.Duration()
+	if len(args) == 3 {
+		if duration, err = time.ParseDuration(args[2]); err != nil {
+			err = fmt.Errorf("Error parsing duration, please enter something valid: %s", err)
+			return
+		}
+	}
+
+	var getCandlesReply *cxrpc.GetCandlesReply
+	if getCandlesReply, err = cl.RPCClient.GetCandles(args[0], args[1], time.Now().Add(-duration), time.Time{}); err != nil {
+		return
+	}
+
+	var data [][]string
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"start", "open", "high", "low", "close", "volume", "quote volume", "executions"})
+
+	for _, candle := range getCandlesReply.Candles {
+		row := []string{candle.Start.Format(time.RFC3339)}
+		for _, price := range []match.Price{candle.Open, candle.High, candle.Low, candle.Close} {
+			var floatPrice float64
+			if floatPrice, err = price.ToFloat(); err != nil {
+				err = fmt.Errorf("Error converting price %s to float: %s", price.String(), err)
+				return
+			}
+			row = append(row, fmt.Sprintf("%f", floatPrice))
+		}
+		row = append(row, fmt.Sprintf("%d", candle.Volume), fmt.Sprintf("%d", candle.QuoteVolume), fmt.Sprintf("%d", candle.Executions))
+		data = append(data, row)
+	}
+
+	// render the table
+	table.AppendBulk(data)
+	table.Render()
+
+	// actually print out table stored in buffer
+	logging.Infof("\n%s\n", buf.String())
+	return
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/rand"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/mit-dci/lit/lnutil"
 	flags "github.com/jessevdk/go-flags"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 )
 
 type ocxClient struct {
@@ -55,6 +57,9 @@ type ocxConfig struct {
 
 	// auth or unauth rpc?
 	AuthenticatedRPC bool `long:"authrpc" description:"Whether or not to use authenticated RPC"`
+
+	// assets the exchange supports, if it doesn't use the built in ones
+	AssetFile string `long:"assetfile" description:"Name of a json file in the root directory with the assets and pairs the exchange supports, if the exchange uses one"`
 }
 
 // Let these be turned into config things at some point
@@ -95,6 +100,23 @@ func main() {
 		return
 	}
 
+	// Pairs and assets can only be parsed once they're registered
+	if conf.AssetFile != "" {
+		var assetData []byte
+		if assetData, err = ioutil.ReadFile(filepath.Join(conf.OcxHomeDir, conf.AssetFile)); err != nil {
+			logging.Fatalf("Error reading asset file for ocx: %s", err)
+		}
+
+		var registry *match.AssetRegistry
+		if registry, err = match.ParseAssetConfig(assetData); err != nil {
+			logging.Fatalf("Error parsing asset file for ocx: %s", err)
+		}
+
+		if err = match.SetAssetRegistry(registry); err != nil {
+			logging.Fatalf("Error setting asset registry for ocx: %s", err)
+		}
+	}
+
 	if len(os.Args) < 2 {
 		logging.Fatalf("Please enter arguments to the command line tool")
 		return
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/lit/lnutil"
@@ -16,9 +17,11 @@ import (
 )
 
 var placeOrderCommand = &Command{
-	Format: fmt.Sprintf("%s%s%s%s%s\n", lnutil.Red("placeorder"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("price")),
-	Description: fmt.Sprintf("%s\n%s\n",
+	Format: fmt.Sprintf("%s%s%s%s%s%s%s\n", lnutil.Red("placeorder"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("price"), lnutil.OptColor("timeinforce"), lnutil.OptColor("selftrade")),
+	Description: fmt.Sprintf("%s\n%s\n%s\n%s\n",
 		"Submit a order with side \"buy\" or side \"sell\", for pair \"asset1\"/\"asset2\", where you give up amounthave of \"asset1\" (if on buy side) or \"asset2\" if on sell side, for the other token at a specific price.",
+		"The time in force can be \"gtc\" (good till cancel, the default), \"ioc\" (immediate or cancel), or \"fok\" (fill or kill). Whatever is left of an ioc or fok order after it's placed is cancelled and refunded.",
+		"The self-trade prevention mode decides what happens if the order would match one of your own orders, and can be \"cn\" (cancel newest, the default), \"co\" (cancel oldest), \"cb\" (cancel both), or \"dc\" (decrement the larger order by the smaller one, and cancel the smaller one).",
 		"This will return an order ID which can be used as input to cancelorder, or getorder.",
 	),
 	ShortDescription: fmt.Sprintf("%s\n", "Place an order on the exchange."),
@@ -54,8 +57,86 @@ func (cl *ocxClient) OrderCommand(args []string) (err error) {
 		return
 	}
 
+	var timeInForce match.TimeInForce
+	if len(args) > 4 {
+		if err = timeInForce.FromString(args[4]); err != nil {
+			err = fmt.Errorf("Error getting time in force from string for OrderCommand: %s", err)
+			return
+		}
+	}
+
+	var selfTrade match.SelfTradePrevention
+	if len(args) > 5 {
+		if err = selfTrade.FromString(args[5]); err != nil {
+			err = fmt.Errorf("Error getting self-trade prevention from string for OrderCommand: %s", err)
+			return
+		}
+	}
+
+	var reply *cxrpc.SubmitOrderReply
+	if reply, err = cl.RPCClient.SelfTradeOrderCommand(pubkey, *orderSide, pair, amountHave, price, timeInForce, selfTrade); err != nil {
+		return
+	}
+
+	var text []byte
+	if text, err = reply.OrderID.MarshalText(); err != nil {
+		err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+		return
+	}
+
+	logging.Infof("Submitted order successfully, orderID: %s", text)
+	return nil
+}
+
+var placeOrderUntilCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s%s%s\n", lnutil.Red("placeorderuntil"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("price"), lnutil.ReqColor("duration")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Submit a order with side \"buy\" or side \"sell\", for pair \"asset1\"/\"asset2\", where you give up amounthave of \"asset1\" (if on buy side) or \"asset2\" if on sell side, for the other token at a specific price.",
+		"If the order is still on the book after duration, for example \"30m\" or \"24h\", it is cancelled and whatever is left of it is refunded.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Place an order on the exchange that expires."),
+}
+
+// OrderUntilCommand submits an order that expires after a duration
+func (cl *ocxClient) OrderUntilCommand(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	side := args[0]
+	pair := args[1]
+
+	var amountHave uint64
+	if amountHave, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing amountHave, please enter something valid:\n%s", err)
+		return
+	}
+
+	var price float64
+	if price, err = strconv.ParseFloat(args[3], 64); err != nil {
+		err = fmt.Errorf("Error parsing price: \n%s", err)
+		return
+	}
+
+	var duration time.Duration
+	if duration, err = time.ParseDuration(args[4]); err != nil {
+		err = fmt.Errorf("Error parsing duration, please enter something like 30m or 24h:\n%s", err)
+		return
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.RetrievePublicKey(); err != nil {
+		return
+	}
+
+	var orderSide *match.Side = new(match.Side)
+	if err = orderSide.FromString(side); err != nil {
+		err = fmt.Errorf("Error getting side from string for OrderUntilCommand: %s", err)
+		return
+	}
+
 	var reply *cxrpc.SubmitOrderReply
-	if reply, err = cl.RPCClient.OrderCommand(pubkey, *orderSide, pair, amountHave, price); err != nil {
+	if reply, err = cl.RPCClient.ExpiringOrderCommand(pubkey, *orderSide, pair, amountHave, price, time.Now().Add(duration)); err != nil {
 		return
 	}
 
@@ -69,6 +150,62 @@ func (cl *ocxClient) OrderCommand(args []string) (err error) {
 	return nil
 }
 
+var marketOrderCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s%s\n", lnutil.Red("marketorder"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("slippage")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Submit a market order with side \"buy\" or side \"sell\", for pair \"asset1\"/\"asset2\", where you give up amounthave of \"asset1\" (if on buy side) or \"asset2\" if on sell side, for the other token at the best prices on the book.",
+		"The order is matched against the book up to slippage basis points away from the best price, and whatever is left is cancelled and refunded.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Place a market order on the exchange."),
+}
+
+// MarketOrderCommand submits a market order
+func (cl *ocxClient) MarketOrderCommand(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	side := args[0]
+	pair := args[1]
+
+	var amountHave uint64
+	if amountHave, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing amountHave, please enter something valid:\n%s", err)
+		return
+	}
+
+	var slippage uint64
+	if slippage, err = strconv.ParseUint(args[3], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing slippage, please enter a number of basis points:\n%s", err)
+		return
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.RetrievePublicKey(); err != nil {
+		return
+	}
+
+	var orderSide *match.Side = new(match.Side)
+	if err = orderSide.FromString(side); err != nil {
+		err = fmt.Errorf("Error getting side from string for MarketOrderCommand: %s", err)
+		return
+	}
+
+	var reply *cxrpc.SubmitOrderReply
+	if reply, err = cl.RPCClient.MarketOrderCommand(pubkey, *orderSide, pair, amountHave, slippage); err != nil {
+		return
+	}
+
+	var text []byte
+	if text, err = reply.OrderID.MarshalText(); err != nil {
+		err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+		return
+	}
+
+	logging.Infof("Submitted market order successfully, orderID: %s", text)
+	return nil
+}
+
 var getPriceCommand = &Command{
 	Format: fmt.Sprintf("%s%s\n", lnutil.Red("getprice"), lnutil.ReqColor("pair")),
 	Description: fmt.Sprintf("%s\n",
@@ -92,10 +229,11 @@ func (cl *ocxClient) GetPrice(args []string) (err error) {
 
 var viewOrderbookCommand = &Command{
 	Format: fmt.Sprintf("%s%s%s\n", lnutil.Red("vieworderbook"), lnutil.ReqColor("pair"), lnutil.OptColor("side")),
-	Description: fmt.Sprintf("%s\n",
-		"View orderbook for pair, with optional side.",
+	Description: fmt.Sprintf("%s\n%s\n",
+		"View every order in the orderbook for pair, with optional side.",
+		"This is an admin command, so ocx has to use the exchange's key and authenticated RPC. Use getdepth otherwise.",
 	),
-	ShortDescription: fmt.Sprintf("%s\n", "View orderbook for pair, with optional side."),
+	ShortDescription: fmt.Sprintf("%s\n", "View every order in the orderbook for pair (admin)."),
 }
 
 // ViewOrderbook prints the orderbook
@@ -129,7 +267,12 @@ func (cl *ocxClient) ViewOrderbook(args []string) (err error) {
 
 			// convert stuff to strings
 			strOrderID := fmt.Sprintf("%x", order.OrderID)
-			strPrice := fmt.Sprintf("%f", order.Price)
+			var floatPrice float64
+			if floatPrice, err = order.Price.ToFloat(); err != nil {
+				err = fmt.Errorf("Error converting price %s to float for ViewOrderbook: %s", order.Price.String(), err)
+				return
+			}
+			strPrice := fmt.Sprintf("%f", floatPrice)
 			strVolume := fmt.Sprintf("%d", order.Order.AmountHave)
 			// append to the table
 			data = append(data, []string{strOrderID, strPrice, strVolume, order.Order.Side.String()})
@@ -145,6 +288,68 @@ func (cl *ocxClient) ViewOrderbook(args []string) (err error) {
 	return
 }
 
+var getDepthCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s\n", lnutil.Red("getdepth"), lnutil.ReqColor("pair"), lnutil.OptColor("levels")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"View the orderbook for pair added up by price, with the best prices on each side first.",
+		"At most levels prices are shown on each side, which is 50 if it isn't specified.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "View the orderbook for pair added up by price."),
+}
+
+// GetDepth prints the orderbook for a pair added up by price
+func (cl *ocxClient) GetDepth(args []string) (err error) {
+	var levels uint64
+	if len(args) == 2 {
+		if levels, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			err = fmt.Errorf("Error parsing levels, please enter something valid: %s", err)
+			return
+		}
+	}
+
+	var getDepthReply *cxrpc.GetDepthReply
+	if getDepthReply, err = cl.RPCClient.GetDepth(args[0], levels); err != nil {
+		return
+	}
+
+	var data [][]string
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"price", "amounthave", "amountwant", "orders", "side"})
+
+	// sells first so the best prices on each side meet in the middle
+	depth := getDepthReply.Depth
+	var sellRows [][]string
+	for _, level := range depth.Sells {
+		var floatPrice float64
+		if floatPrice, err = level.Price.ToFloat(); err != nil {
+			err = fmt.Errorf("Error converting price %s to float for GetDepth: %s", level.Price.String(), err)
+			return
+		}
+		sellRows = append(sellRows, []string{fmt.Sprintf("%f", floatPrice), fmt.Sprintf("%d", level.AmountHave), fmt.Sprintf("%d", level.AmountWant), fmt.Sprintf("%d", level.Orders), match.Sell.String()})
+	}
+	for i := len(sellRows) - 1; i >= 0; i-- {
+		data = append(data, sellRows[i])
+	}
+
+	for _, level := range depth.Buys {
+		var floatPrice float64
+		if floatPrice, err = level.Price.ToFloat(); err != nil {
+			err = fmt.Errorf("Error converting price %s to float for GetDepth: %s", level.Price.String(), err)
+			return
+		}
+		data = append(data, []string{fmt.Sprintf("%f", floatPrice), fmt.Sprintf("%d", level.AmountHave), fmt.Sprintf("%d", level.AmountWant), fmt.Sprintf("%d", level.Orders), match.Buy.String()})
+	}
+
+	// render the table
+	table.AppendBulk(data)
+	table.Render()
+
+	// actually print out table stored in buffer
+	logging.Infof("\n%s\n", buf.String())
+	return
+}
+
 var cancelOrderCommand = &Command{
 	Format: fmt.Sprintf("%s%s\n", lnutil.Red("cancelorder"), lnutil.ReqColor("orderID")),
 	Description: fmt.Sprintf("%s\n",
@@ -170,10 +375,46 @@ func (cl *ocxClient) CancelOrder(args []string) (err error) {
 	return
 }
 
+var replaceOrderCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s\n", lnutil.Red("replaceorder"), lnutil.ReqColor("orderID"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("price")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Change the amount and price of order with orderID, without cancelling it. Only the difference in amounthave is taken from or given back to your balance.",
+		"The order keeps its place in the queue if the price stays the same and amounthave is not increased.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Change the amount and price of order with orderID."),
+}
+
+// ReplaceOrder calls the replace order rpc command
+func (cl *ocxClient) ReplaceOrder(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+	orderID := args[0]
+
+	var amountHave uint64
+	if amountHave, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing amountHave, please enter something valid:\n%s", err)
+		return
+	}
+
+	var price float64
+	if price, err = strconv.ParseFloat(args[2], 64); err != nil {
+		err = fmt.Errorf("Error parsing price: \n%s", err)
+		return
+	}
+
+	if _, err = cl.RPCClient.ReplaceOrder(orderID, amountHave, uint64(price*float64(amountHave))); err != nil {
+		return
+	}
+
+	logging.Infof("Replaced order successfully")
+	return
+}
+
 var getPairsCommand = &Command{
 	Format: fmt.Sprintf("%s\n", lnutil.Red("getpairs")),
 	Description: fmt.Sprintf("%s\n",
-		"Get all available trading pairs.",
+		"Get all available trading pairs, whether each one is trading, halted, cancel-only, auction-only, or delisted, and the tick size, lot size, min value, and max AmountHave that orders on each pair have to follow.",
 	),
 	ShortDescription: fmt.Sprintf("%s\n", "Get all available trading pairs."),
 }
@@ -187,7 +428,37 @@ func (cl *ocxClient) GetPairs() (err error) {
 
 	logging.Infof("List of valid trading pairs: ")
 	for _, pair := range getPairsReply.PairList {
-		logging.Infof("%s", pair)
+		status := getPairsReply.Statuses[pair]
+		rules, ok := getPairsReply.Rules[pair]
+		if !ok || *rules == (match.PairRules{}) {
+			logging.Infof("%s (%s)", pair, status.String())
+			continue
+		}
+
+		logging.Infof("%s (%s) tick size: %s, lot size: %d, min value: %d, max amounthave: %d", pair, status.String(), rules.TickSize.String(), rules.LotSize, rules.MinValue, rules.MaxAmountHave)
+	}
+
+	return
+}
+
+var getFeeRevenueCommand = &Command{
+	Format: fmt.Sprintf("%s\n", lnutil.Red("getfeerevenue")),
+	Description: fmt.Sprintf("%s\n",
+		"Get how much of each asset the exchange has been paid in trading fees. Only the exchange's key can do this.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Get the exchange's fee revenue."),
+}
+
+// GetFeeRevenue gets the fee revenue for each asset
+func (cl *ocxClient) GetFeeRevenue() (err error) {
+	var getFeeRevenueReply *cxrpc.GetFeeRevenueReply
+	if getFeeRevenueReply, err = cl.RPCClient.GetFeeRevenue(); err != nil {
+		return
+	}
+
+	logging.Infof("Fee revenue: ")
+	for asset, amount := range getFeeRevenueReply.Revenue {
+		logging.Infof("%s: %d", asset.String(), amount)
 	}
 
 	return
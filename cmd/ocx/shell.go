@@ -95,14 +95,38 @@ func (cl *ocxClient) parseCommands(commands []string) error {
 		if getHelpForCommand(placeOrderCommand, args) {
 			return nil
 		}
-		if len(args) != 4 {
-			return fmt.Errorf("Must specify 4 arguments: side, pair, amountHave, and price")
+		if len(args) < 4 || len(args) > 6 {
+			return fmt.Errorf("Must specify 4 to 6 arguments: side, pair, amountHave, price, and optionally timeInForce and selfTrade")
 		}
 
 		if err := cl.OrderCommand(args); err != nil {
 			return fmt.Errorf("Error calling order command: \n%s", err)
 		}
 	}
+	if cmd == "placeorderuntil" {
+		if getHelpForCommand(placeOrderUntilCommand, args) {
+			return nil
+		}
+		if len(args) != 5 {
+			return fmt.Errorf("Must specify 5 arguments: side, pair, amountHave, price, and duration")
+		}
+
+		if err := cl.OrderUntilCommand(args); err != nil {
+			return fmt.Errorf("Error calling order until command: \n%s", err)
+		}
+	}
+	if cmd == "marketorder" {
+		if getHelpForCommand(marketOrderCommand, args) {
+			return nil
+		}
+		if len(args) != 4 {
+			return fmt.Errorf("Must specify 4 arguments: side, pair, amountHave, and slippage")
+		}
+
+		if err := cl.MarketOrderCommand(args); err != nil {
+			return fmt.Errorf("Error calling market order command: \n%s", err)
+		}
+	}
 	if cmd == "vieworderbook" {
 		if getHelpForCommand(viewOrderbookCommand, args) {
 			return nil
@@ -115,6 +139,18 @@ func (cl *ocxClient) parseCommands(commands []string) error {
 			return fmt.Errorf("Error calling vieworderbook command: \n%s", err)
 		}
 	}
+	if cmd == "getdepth" {
+		if getHelpForCommand(getDepthCommand, args) {
+			return nil
+		}
+		if len(args) != 1 && len(args) != 2 {
+			return fmt.Errorf("Must specify from 1 to 2 arguments: pair [levels]")
+		}
+
+		if err := cl.GetDepth(args); err != nil {
+			return fmt.Errorf("Error calling getdepth command: \n%s", err)
+		}
+	}
 	if cmd == "getprice" {
 		if getHelpForCommand(getPriceCommand, args) {
 			return nil
@@ -163,6 +199,102 @@ func (cl *ocxClient) parseCommands(commands []string) error {
 			return fmt.Errorf("Error calling cancel command: \n%s", err)
 		}
 	}
+	if cmd == "replaceorder" {
+		if getHelpForCommand(replaceOrderCommand, args) {
+			return nil
+		}
+		if len(args) != 3 {
+			return fmt.Errorf("Must specify 3 arguments: orderID, amountHave, and price")
+		}
+
+		if err := cl.ReplaceOrder(args); err != nil {
+			return fmt.Errorf("Error calling replace command: \n%s", err)
+		}
+	}
+	if cmd == "placestop" {
+		if getHelpForCommand(placeStopCommand, args) {
+			return nil
+		}
+		if len(args) != 5 {
+			return fmt.Errorf("Must specify 5 arguments: side, pair, amountHave, stopPrice, and slippage")
+		}
+
+		if err := cl.PlaceStopCommand(args); err != nil {
+			return fmt.Errorf("Error calling stop order command: \n%s", err)
+		}
+	}
+	if cmd == "placestoplimit" {
+		if getHelpForCommand(placeStopLimitCommand, args) {
+			return nil
+		}
+		if len(args) != 5 && len(args) != 6 {
+			return fmt.Errorf("Must specify 5 or 6 arguments: side, pair, amountHave, stopPrice, price, and optionally timeInForce")
+		}
+
+		if err := cl.PlaceStopLimitCommand(args); err != nil {
+			return fmt.Errorf("Error calling stop limit order command: \n%s", err)
+		}
+	}
+	if cmd == "getstoporders" {
+		if getHelpForCommand(getStopOrdersCommand, args) {
+			return nil
+		}
+		if len(args) != 0 {
+			return fmt.Errorf("Don't specify arguments please")
+		}
+
+		if err := cl.GetStopOrders(); err != nil {
+			return fmt.Errorf("Error getting stop orders: \n%s", err)
+		}
+	}
+	if cmd == "gettrades" {
+		if getHelpForCommand(getTradesCommand, args) {
+			return nil
+		}
+		if len(args) != 1 && len(args) != 2 {
+			return fmt.Errorf("Must specify from 1 to 2 arguments: pair [limit]")
+		}
+
+		if err := cl.GetTrades(args); err != nil {
+			return fmt.Errorf("Error calling gettrades command: \n%s", err)
+		}
+	}
+	if cmd == "getcandles" {
+		if getHelpForCommand(getCandlesCommand, args) {
+			return nil
+		}
+		if len(args) != 2 && len(args) != 3 {
+			return fmt.Errorf("Must specify from 2 to 3 arguments: pair interval [duration]")
+		}
+
+		if err := cl.GetCandles(args); err != nil {
+			return fmt.Errorf("Error calling getcandles command: \n%s", err)
+		}
+	}
+	if cmd == "getfills" {
+		if getHelpForCommand(getFillsCommand, args) {
+			return nil
+		}
+		if len(args) != 0 && len(args) != 1 {
+			return fmt.Errorf("Must specify from 0 to 1 arguments: [limit]")
+		}
+
+		if err := cl.GetFills(args); err != nil {
+			return fmt.Errorf("Error getting fills: \n%s", err)
+		}
+	}
+	if cmd == "cancelstop" {
+		if getHelpForCommand(cancelStopCommand, args) {
+			return nil
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("Must specify 1 argument: orderID")
+		}
+
+		if err := cl.CancelStop(args); err != nil {
+			return fmt.Errorf("Error calling cancel stop command: \n%s", err)
+		}
+	}
 	if cmd == "getpairs" {
 		if getHelpForCommand(getPairsCommand, args) {
 			return nil
@@ -175,6 +307,42 @@ func (cl *ocxClient) parseCommands(commands []string) error {
 			return fmt.Errorf("Error getting pairs: \n%s", err)
 		}
 	}
+	if cmd == "getfeerevenue" {
+		if getHelpForCommand(getFeeRevenueCommand, args) {
+			return nil
+		}
+		if len(args) != 0 {
+			return fmt.Errorf("Don't specify arguments please")
+		}
+
+		if err := cl.GetFeeRevenue(); err != nil {
+			return fmt.Errorf("Error getting fee revenue: \n%s", err)
+		}
+	}
+	if cmd == "addpair" {
+		if getHelpForCommand(addPairCommand, args) {
+			return nil
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("Must specify 1 argument: pair")
+		}
+
+		if err := cl.AddPair(args); err != nil {
+			return fmt.Errorf("Error adding pair: \n%s", err)
+		}
+	}
+	if cmd == "setpairstatus" {
+		if getHelpForCommand(setPairStatusCommand, args) {
+			return nil
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("Must specify 2 arguments: pair, status")
+		}
+
+		if err := cl.SetPairStatus(args); err != nil {
+			return fmt.Errorf("Error setting pair status: \n%s", err)
+		}
+	}
 	if cmd == "getlitconnection" {
 		if getHelpForCommand(getLitConnectionCommand, args) {
 			return nil
@@ -212,7 +380,7 @@ func (cl *ocxClient) Help(textArgs []string) error {
 	if len(textArgs) == 0 {
 
 		fmt.Fprintf(color.Output, lnutil.Header("Commands:\n"))
-		listofCommands := []*Command{helpCommand, registerCommand, getBalanceCommand, getDepositAddressCommand, getAllBalancesCommand, withdrawCommand, litWithdrawCommand, getLitConnectionCommand, placeOrderCommand, getPriceCommand, viewOrderbookCommand, cancelOrderCommand, getPairsCommand, placeAuctionOrderCommand}
+		listofCommands := []*Command{helpCommand, registerCommand, getBalanceCommand, getDepositAddressCommand, getAllBalancesCommand, withdrawCommand, litWithdrawCommand, getLitConnectionCommand, placeOrderCommand, placeOrderUntilCommand, marketOrderCommand, getPriceCommand, viewOrderbookCommand, getDepthCommand, cancelOrderCommand, replaceOrderCommand, placeStopCommand, placeStopLimitCommand, getStopOrdersCommand, cancelStopCommand, getTradesCommand, getFillsCommand, getCandlesCommand, getPairsCommand, getFeeRevenueCommand, addPairCommand, setPairStatusCommand, placeAuctionOrderCommand}
 		printHelp(listofCommands)
 		return nil
 	}
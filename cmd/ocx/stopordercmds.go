@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/lit/lnutil"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+
+	"github.com/mit-dci/opencx/cxrpc"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+var placeStopCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s%s%s\n", lnutil.Red("placestop"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("stopprice"), lnutil.ReqColor("slippage")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Submit a stop-loss order with side \"buy\" or side \"sell\", for pair \"asset1\"/\"asset2\", where you give up amounthave of \"asset1\" (if on buy side) or \"asset2\" if on sell side.",
+		"Once the last trade price reaches stopprice, a market order is placed that can be matched up to slippage basis points away from the best price. The funds for the order are held until then.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Place a stop-loss order on the exchange."),
+}
+
+// PlaceStopCommand submits a stop-loss order
+func (cl *ocxClient) PlaceStopCommand(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	side := args[0]
+	pair := args[1]
+
+	var amountHave uint64
+	if amountHave, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing amountHave, please enter something valid:\n%s", err)
+		return
+	}
+
+	var stopPrice float64
+	if stopPrice, err = strconv.ParseFloat(args[3], 64); err != nil {
+		err = fmt.Errorf("Error parsing stop price: \n%s", err)
+		return
+	}
+
+	var slippage uint64
+	if slippage, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing slippage, please enter a number of basis points:\n%s", err)
+		return
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.RetrievePublicKey(); err != nil {
+		return
+	}
+
+	var orderSide *match.Side = new(match.Side)
+	if err = orderSide.FromString(side); err != nil {
+		err = fmt.Errorf("Error getting side from string for PlaceStopCommand: %s", err)
+		return
+	}
+
+	var reply *cxrpc.SubmitStopOrderReply
+	if reply, err = cl.RPCClient.StopLossOrderCommand(pubkey, *orderSide, pair, amountHave, stopPrice, slippage); err != nil {
+		return
+	}
+
+	var text []byte
+	if text, err = reply.OrderID.MarshalText(); err != nil {
+		err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+		return
+	}
+
+	logging.Infof("Submitted stop order successfully, orderID: %s", text)
+	return nil
+}
+
+var placeStopLimitCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s%s%s%s%s\n", lnutil.Red("placestoplimit"), lnutil.ReqColor("side"), lnutil.ReqColor("pair"), lnutil.ReqColor("amounthave"), lnutil.ReqColor("stopprice"), lnutil.ReqColor("price"), lnutil.OptColor("timeinforce")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"Submit a stop-limit order with side \"buy\" or side \"sell\", for pair \"asset1\"/\"asset2\", where you give up amounthave of \"asset1\" (if on buy side) or \"asset2\" if on sell side.",
+		"Once the last trade price reaches stopprice, a limit order is placed at price with the time in force, \"gtc\" by default. The funds for the order are held until then.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Place a stop-limit order on the exchange."),
+}
+
+// PlaceStopLimitCommand submits a stop-limit order
+func (cl *ocxClient) PlaceStopLimitCommand(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	side := args[0]
+	pair := args[1]
+
+	var amountHave uint64
+	if amountHave, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+		err = fmt.Errorf("Error parsing amountHave, please enter something valid:\n%s", err)
+		return
+	}
+
+	var stopPrice float64
+	if stopPrice, err = strconv.ParseFloat(args[3], 64); err != nil {
+		err = fmt.Errorf("Error parsing stop price: \n%s", err)
+		return
+	}
+
+	var price float64
+	if price, err = strconv.ParseFloat(args[4], 64); err != nil {
+		err = fmt.Errorf("Error parsing price: \n%s", err)
+		return
+	}
+
+	var timeInForce match.TimeInForce
+	if len(args) > 5 {
+		if err = timeInForce.FromString(args[5]); err != nil {
+			err = fmt.Errorf("Error getting time in force from string for PlaceStopLimitCommand: %s", err)
+			return
+		}
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.RetrievePublicKey(); err != nil {
+		return
+	}
+
+	var orderSide *match.Side = new(match.Side)
+	if err = orderSide.FromString(side); err != nil {
+		err = fmt.Errorf("Error getting side from string for PlaceStopLimitCommand: %s", err)
+		return
+	}
+
+	var reply *cxrpc.SubmitStopOrderReply
+	if reply, err = cl.RPCClient.StopLimitOrderCommand(pubkey, *orderSide, pair, amountHave, stopPrice, price, timeInForce); err != nil {
+		return
+	}
+
+	var text []byte
+	if text, err = reply.OrderID.MarshalText(); err != nil {
+		err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+		return
+	}
+
+	logging.Infof("Submitted stop order successfully, orderID: %s", text)
+	return nil
+}
+
+var getStopOrdersCommand = &Command{
+	Format: fmt.Sprintf("%s\n", lnutil.Red("getstoporders")),
+	Description: fmt.Sprintf("%s\n",
+		"List your stop orders that haven't been triggered or cancelled yet.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "List your stop orders."),
+}
+
+// GetStopOrders prints the stop orders for the client's key
+func (cl *ocxClient) GetStopOrders() (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	var getStopOrdersReply *cxrpc.GetStopOrdersReply
+	if getStopOrdersReply, err = cl.RPCClient.GetStopOrders(); err != nil {
+		return
+	}
+
+	// Build the table
+	var data [][]string
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"orderID", "pair", "side", "type", "stop price", "volume"})
+
+	for _, stop := range getStopOrdersReply.Orders {
+		var text []byte
+		if text, err = stop.OrderID.MarshalText(); err != nil {
+			err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+			return
+		}
+
+		var floatStopPrice float64
+		if floatStopPrice, err = stop.Order.StopPrice.ToFloat(); err != nil {
+			err = fmt.Errorf("Error converting stop price %s to float for GetStopOrders: %s", stop.Order.StopPrice.String(), err)
+			return
+		}
+
+		stopType := "stop-limit"
+		if stop.Order.Market {
+			stopType = "stop-loss"
+		}
+
+		data = append(data, []string{string(text), stop.Order.Order.TradingPair.String(), stop.Order.Order.Side.String(), stopType, fmt.Sprintf("%f", floatStopPrice), fmt.Sprintf("%d", stop.Order.Order.AmountHave)})
+	}
+
+	// render the table
+	table.AppendBulk(data)
+	table.Render()
+
+	// actually print out table stored in buffer
+	logging.Infof("\n%s\n", buf.String())
+	return
+}
+
+var cancelStopCommand = &Command{
+	Format: fmt.Sprintf("%s%s\n", lnutil.Red("cancelstop"), lnutil.ReqColor("orderID")),
+	Description: fmt.Sprintf("%s\n",
+		"Cancel stop order with orderID, giving back the funds held for it.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "Cancel stop order with orderID."),
+}
+
+// CancelStop calls the cancel stop order rpc command
+func (cl *ocxClient) CancelStop(args []string) (err error) {
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+	orderID := args[0]
+
+	if _, err = cl.RPCClient.CancelStopOrder(orderID); err != nil {
+		return
+	}
+
+	logging.Infof("Cancelled stop order successfully")
+	return
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mit-dci/lit/lnutil"
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+	"github.com/olekukonko/tablewriter"
+)
+
+var getTradesCommand = &Command{
+	Format: fmt.Sprintf("%s%s%s\n", lnutil.Red("gettrades"), lnutil.ReqColor("pair"), lnutil.OptColor("limit")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"List the latest trades on trading pair \"asset1\"/\"asset2\", newest first.",
+		"At most limit trades are listed, which is 50 if it isn't specified.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "List the latest trades on a pair."),
+}
+
+// GetTrades prints the latest trades on a pair
+func (cl *ocxClient) GetTrades(args []string) (err error) {
+	var limit uint64
+	if len(args) == 2 {
+		if limit, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			err = fmt.Errorf("Error parsing limit, please enter something valid: %s", err)
+			return
+		}
+	}
+
+	var getRecentTradesReply *cxrpc.GetRecentTradesReply
+	if getRecentTradesReply, err = cl.RPCClient.GetRecentTrades(args[0], limit); err != nil {
+		return
+	}
+
+	if err = printTrades(getRecentTradesReply.Trades, nil); err != nil {
+		err = fmt.Errorf("Error printing trades for GetTrades: %s", err)
+		return
+	}
+	return
+}
+
+var getFillsCommand = &Command{
+	Format: fmt.Sprintf("%s%s\n", lnutil.Red("getfills"), lnutil.OptColor("limit")),
+	Description: fmt.Sprintf("%s\n%s\n",
+		"List the latest trades your orders were in, on every pair, newest first.",
+		"At most limit trades are listed, which is 50 if it isn't specified.",
+	),
+	ShortDescription: fmt.Sprintf("%s\n", "List the trades your orders were in."),
+}
+
+// GetFills prints the latest trades the client's orders were in
+func (cl *ocxClient) GetFills(args []string) (err error) {
+	var limit uint64
+	if len(args) == 1 {
+		if limit, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+			err = fmt.Errorf("Error parsing limit, please enter something valid: %s", err)
+			return
+		}
+	}
+
+	if err = cl.UnlockKey(); err != nil {
+		logging.Fatalf("Could not unlock key! Fatal!")
+	}
+
+	var getMyFillsReply *cxrpc.GetMyFillsReply
+	if getMyFillsReply, err = cl.RPCClient.GetMyFills(limit, nil); err != nil {
+		return
+	}
+
+	var pubkey [33]byte
+	copy(pubkey[:], cl.RPCClient.PrivKey.PubKey().SerializeCompressed())
+	if err = printTrades(getMyFillsReply.Trades, &pubkey); err != nil {
+		err = fmt.Errorf("Error printing trades for GetFills: %s", err)
+		return
+	}
+	return
+}
+
+// printTrades prints a table of trades. If pubkey isn't nil, the table shows which side of each trade the
+// pubkey was on and whether it was the maker or taker, otherwise it shows the maker's side.
+func printTrades(trades []*match.Trade, pubkey *[33]byte) (err error) {
+	var data [][]string
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	if pubkey == nil {
+		table.SetHeader([]string{"time", "pair", "price", "amountWant", "amountHave", "maker side"})
+	} else {
+		table.SetHeader([]string{"time", "pair", "side", "liquidity", "orderID", "price", "amountWant", "amountHave"})
+	}
+
+	for _, trade := range trades {
+		var floatPrice float64
+		if floatPrice, err = trade.Price.ToFloat(); err != nil {
+			err = fmt.Errorf("Error converting price %s to float: %s", trade.Price.String(), err)
+			return
+		}
+
+		tradeTime := trade.Timestamp.Format(time.RFC3339)
+		if pubkey == nil {
+			data = append(data, []string{tradeTime, trade.Pair.String(), fmt.Sprintf("%f", floatPrice), fmt.Sprintf("%d", trade.AmountWant), fmt.Sprintf("%d", trade.AmountHave), trade.MakerSide.String()})
+			continue
+		}
+
+		side := match.Buy
+		orderID := trade.BuyOrderID
+		if trade.SellPubkey == *pubkey {
+			side = match.Sell
+			orderID = trade.SellOrderID
+		}
+
+		liquidity := "taker"
+		if side == trade.MakerSide {
+			liquidity = "maker"
+		}
+
+		var text []byte
+		if text, err = orderID.MarshalText(); err != nil {
+			err = fmt.Errorf("Could not marshal to text for some reason: %s", err)
+			return
+		}
+
+		data = append(data, []string{tradeTime, trade.Pair.String(), side.String(), liquidity, string(text), fmt.Sprintf("%f", floatPrice), fmt.Sprintf("%d", trade.AmountWant), fmt.Sprintf("%d", trade.AmountHave)})
+	}
+
+	// render the table
+	table.AppendBulk(data)
+	table.Render()
+
+	// actually print out table stored in buffer
+	logging.Infof("\n%s\n", buf.String())
+	return
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbfile"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// ocxreplay verifies the hash chain of an opencxd or frred journal, then rebuilds the orderbooks and
+// balances from the first entry, in memory, and prints them. This can be used to audit the state of an
+// exchange, or to check a journal before starting a fresh node from it.
+type ocxreplayConfig struct {
+	// the journal to replay
+	JournalPath string `long:"journal" short:"j" description:"Path to the journal file to verify and replay" required:"true"`
+
+	// logging and debug parameters
+	LogLevel []bool `short:"v" description:"Set verbosity level to verbose (-v), very verbose (-vv) or very very verbose (-vvv)"`
+}
+
+func main() {
+	var err error
+
+	var conf ocxreplayConfig
+	if _, err = flags.NewParser(&conf, flags.Default).Parse(); err != nil {
+		os.Exit(1)
+	}
+
+	logging.SetLogLevel(len(conf.LogLevel))
+
+	// NOTE: this will remove a partially written record at the end of the journal, like opencxd would
+	var journal cxdb.Journal
+	if journal, err = cxdbfile.CreateJournal(conf.JournalPath); err != nil {
+		logging.Fatalf("Error opening journal for ocxreplay: %s", err)
+	}
+
+	var entries []*cxdb.JournalEntry
+	if entries, err = journal.Entries(); err != nil {
+		logging.Fatalf("Error getting journal entries for ocxreplay: %s", err)
+	}
+
+	var headHash [32]byte
+	if headHash, err = cxdb.VerifyJournal(entries); err != nil {
+		logging.Fatalf("Journal failed verification: %s", err)
+	}
+
+	fmt.Printf("Verified %d entries, head hash %x\n", len(entries), headHash)
+
+	var coinList []*coinparam.Params
+	var pubkeys [][33]byte
+	if coinList, pubkeys, err = journalCoinsAndPubkeys(entries); err != nil {
+		logging.Fatalf("Error getting coins from journal: %s", err)
+	}
+
+	var ocxServer *cxserver.OpencxServer
+	if ocxServer, err = createMemoryServer(coinList); err != nil {
+		logging.Fatalf("Error creating memory server for ocxreplay: %s", err)
+	}
+
+	// This applies every settlement execution, every limit order placement, execution, and cancellation, and
+	// every stop order placement, trigger, and cancellation
+	if err = ocxServer.ReplayJournal(journal); err != nil {
+		logging.Fatalf("Error replaying journal: %s", err)
+	}
+
+	var auctionBooks map[match.Pair]match.AuctionOrderbook
+	if auctionBooks, err = replayAuctionBooks(entries, coinList); err != nil {
+		logging.Fatalf("Error replaying auction orderbooks: %s", err)
+	}
+
+	fmt.Printf("Balances:\n")
+	for _, coin := range coinList {
+		for _, pubkey := range pubkeys {
+			var pk *koblitz.PublicKey
+			if pk, err = koblitz.ParsePubKey(pubkey[:], koblitz.S256()); err != nil {
+				logging.Fatalf("Error parsing pubkey %x from journal: %s", pubkey, err)
+			}
+
+			var balance uint64
+			if balance, err = ocxServer.SettlementStores[coin].GetBalance(pk); err != nil {
+				logging.Fatalf("Error getting %s balance for %x: %s", coin.Name, pubkey, err)
+			}
+
+			if balance != 0 {
+				fmt.Printf("\t%x %s: %d\n", pubkey, coin.Name, balance)
+			}
+		}
+	}
+
+	var feeRevenue map[match.Asset]uint64
+	if feeRevenue, err = ocxServer.GetFeeRevenue(); err != nil {
+		logging.Fatalf("Error getting fee revenue: %s", err)
+	}
+
+	fmt.Printf("Fee revenue:\n")
+	for _, coin := range coinList {
+		var asset match.Asset
+		if asset, err = match.AssetFromCoinParam(coin); err != nil {
+			logging.Fatalf("Error getting asset for %s: %s", coin.Name, err)
+		}
+
+		if feeRevenue[asset] != 0 {
+			fmt.Printf("\t%s: %d\n", coin.Name, feeRevenue[asset])
+		}
+	}
+
+	fmt.Printf("Open limit orders:\n")
+	for pair, book := range ocxServer.Orderbooks {
+		var limitBook map[match.Price][]*match.LimitOrderIDPair
+		if limitBook, err = book.ViewLimitOrderBook(); err != nil {
+			logging.Fatalf("Error viewing limit orderbook for %s: %s", pair.String(), err)
+		}
+
+		for _, orders := range limitBook {
+			for _, order := range orders {
+				fmt.Printf("\t%s %x: %s %d for %d\n", pair.String(), order.OrderID[:], order.Order.Side.String(), order.Order.AmountHave, order.Order.AmountWant)
+			}
+		}
+	}
+
+	fmt.Printf("Stop orders:\n")
+	for _, pubkey := range pubkeys {
+		var pk *koblitz.PublicKey
+		if pk, err = koblitz.ParsePubKey(pubkey[:], koblitz.S256()); err != nil {
+			logging.Fatalf("Error parsing pubkey %x from journal: %s", pubkey, err)
+		}
+
+		var stops []*match.StopOrderIDPair
+		if stops, err = ocxServer.GetStopOrdersForPubkey(pk); err != nil {
+			logging.Fatalf("Error getting stop orders for %x: %s", pubkey, err)
+		}
+
+		for _, stop := range stops {
+			fmt.Printf("\t%s %x: %s %d at stop price %s\n", stop.Order.Order.TradingPair.String(), stop.OrderID[:], stop.Order.Order.Side.String(), stop.Order.Order.AmountHave, stop.Order.StopPrice.String())
+		}
+	}
+
+	fmt.Printf("Auction orders:\n")
+	for pair, book := range auctionBooks {
+		var auctionBook map[match.Price][]*match.AuctionOrderIDPair
+		if auctionBook, err = book.ViewAuctionOrderBook(); err != nil {
+			logging.Fatalf("Error viewing auction orderbook for %s: %s", pair.String(), err)
+		}
+
+		for _, orders := range auctionBook {
+			for _, order := range orders {
+				fmt.Printf("\t%s %x: %s %d for %d\n", pair.String(), order.OrderID[:], order.Order.Side.String(), order.Order.AmountHave, order.Order.AmountWant)
+			}
+		}
+	}
+
+	return
+}
+
+// journalCoinsAndPubkeys returns every coin and every pubkey that the journal entries refer to, so we know
+// what to create engines for and whose balances to print.
+func journalCoinsAndPubkeys(entries []*cxdb.JournalEntry) (coinList []*coinparam.Params, pubkeys [][33]byte, err error) {
+	coinSet := make(map[*coinparam.Params]bool)
+	pubkeySet := make(map[[33]byte]bool)
+	addAsset := func(asset match.Asset) (err error) {
+		var coin *coinparam.Params
+		if coin, err = asset.CoinParamFromAsset(); err != nil {
+			err = fmt.Errorf("Error getting coin param from asset %s: %s", asset.String(), err)
+			return
+		}
+		coinSet[coin] = true
+		return
+	}
+
+	for _, entry := range entries {
+		// settle entries don't have a pair
+		if entry.Type != cxdb.JournalSettle {
+			if err = addAsset(entry.Pair.AssetWant); err != nil {
+				return
+			}
+			if err = addAsset(entry.Pair.AssetHave); err != nil {
+				return
+			}
+		}
+
+		// fee executions are how the fee account gets its balance
+		for _, setExec := range append(append([]*match.SettlementExecution{}, entry.SettlementExecs...), entry.FeeExecs...) {
+			if err = addAsset(setExec.Asset); err != nil {
+				return
+			}
+			pubkeySet[setExec.Pubkey] = true
+		}
+	}
+
+	for coin := range coinSet {
+		coinList = append(coinList, coin)
+	}
+	sort.Slice(coinList, func(i, j int) bool { return coinList[i].Name < coinList[j].Name })
+
+	for pubkey := range pubkeySet {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	sort.Slice(pubkeys, func(i, j int) bool { return string(pubkeys[i][:]) < string(pubkeys[j][:]) })
+	return
+}
+
+// createMemoryServer creates a limit order server with everything in memory, for the journal to be replayed into
+func createMemoryServer(coinList []*coinparam.Params) (server *cxserver.OpencxServer, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		err = fmt.Errorf("Could not generate asset pairs from coin list: %s", err)
+		return
+	}
+
+	var setEngines map[*coinparam.Params]match.SettlementEngine
+	if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating settlement engine map: %s", err)
+		return
+	}
+
+	var mengines map[match.Pair]match.LimitEngine
+	if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating limit engine map: %s", err)
+		return
+	}
+
+	var limBooks map[match.Pair]match.LimitOrderbook
+	if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating limit orderbook map: %s", err)
+		return
+	}
+
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating stop order store map: %s", err)
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating trade store map: %s", err)
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map: %s", err)
+		return
+	}
+
+	var depositStores map[*coinparam.Params]cxdb.DepositStore
+	if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating deposit store map: %s", err)
+		return
+	}
+
+	var setStores map[*coinparam.Params]cxdb.SettlementStore
+	if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating settlement store map: %s", err)
+		return
+	}
+
+	if server, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ""); err != nil {
+		err = fmt.Errorf("Error initializing server: %s", err)
+		return
+	}
+	return
+}
+
+// replayAuctionBooks applies the auction placements and matches in the journal to in memory auction orderbooks.
+// The settlement executions from auction matches are applied by the limit order server, along with everything else.
+func replayAuctionBooks(entries []*cxdb.JournalEntry, coinList []*coinparam.Params) (books map[match.Pair]match.AuctionOrderbook, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		err = fmt.Errorf("Could not generate asset pairs from coin list: %s", err)
+		return
+	}
+
+	if books, err = cxdbmemory.CreateAuctionOrderbookMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating auction orderbook map: %s", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Type != cxdb.JournalAuctionPlace && entry.Type != cxdb.JournalAuctionMatch {
+			continue
+		}
+
+		var currBook match.AuctionOrderbook
+		var ok bool
+		if currBook, ok = books[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find auction orderbook for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		if entry.Type == cxdb.JournalAuctionPlace {
+			if err = currBook.UpdateBookPlace(entry.PlacedAuctionOrder); err != nil {
+				err = fmt.Errorf("Error placing order from entry %d: %s", entry.Sequence, err)
+				return
+			}
+			continue
+		}
+
+		for _, orderExec := range entry.OrderExecs {
+			if err = currBook.UpdateBookExec(orderExec); err != nil {
+				err = fmt.Errorf("Error applying execution from entry %d: %s", entry.Sequence, err)
+				return
+			}
+		}
+	}
+	return
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/hex"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/mit-dci/lit/coinparam"
 	"github.com/mit-dci/lit/crypto/koblitz"
@@ -12,10 +15,13 @@ import (
 	flags "github.com/jessevdk/go-flags"
 	util "github.com/mit-dci/opencx/chainutils"
 	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbfile"
 	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
 	"github.com/mit-dci/opencx/cxdb/cxdbsql"
+	"github.com/mit-dci/opencx/cxpubsub"
 	"github.com/mit-dci/opencx/cxrpc"
 	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/cxwebsocket"
 	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 )
@@ -71,8 +77,35 @@ type opencxConfig struct {
 	// auth or unauth rpc?
 	AuthenticatedRPC bool `long:"authrpc" description:"Whether or not to use authenticated RPC"`
 
+	// port to publish market data on
+	PubSubPort uint16 `long:"pubsubport" description:"Port to publish the book, trades, and auction results of each pair on over noise. Market data isn't published if it's 0"`
+
+	// port to serve RPC and market data over websockets on
+	WebSocketPort uint16 `long:"wsport" description:"Port to serve RPC commands as JSON-RPC and push market data over websockets on, on the RPC host, for browsers. Websockets aren't served if it's 0"`
+
+	// port to serve the REST gateway on
+	RestPort uint16 `long:"restport" description:"Port to serve pairs, the book, prices, orders, balances, and deposit addresses over HTTP with JSON on, on the RPC host. The REST gateway isn't served if it's 0"`
+
 	// support lightning or not to support lightning?
 	LightningSupport bool `long:"lightning" description:"Whether or not to support lightning on the exchange"`
+
+	// keep everything in memory instead of using a database?
+	MemoryDB bool `long:"memorydb" description:"Whether or not to keep the matching engines, orderbooks, balances, deposits, trades, and candles in memory rather than in a database"`
+
+	// journal changes so the in memory state can be rebuilt after a restart?
+	Journal bool `long:"journal" description:"Whether or not to write orders and settlements to a journal in the root directory, and replay it on startup. Without memorydb, the SQL stores are rebuilt from the journal on startup"`
+
+	// how often to cancel orders that have expired
+	ExpirySweep time.Duration `long:"expirysweep" description:"How often to cancel orders that have expired, for example 10s"`
+
+	// trading fees charged for each pair
+	FeeFile string `long:"feefile" description:"Name of a json file in the root directory with the fee account and the maker and taker fees for each pair. No fees are charged without one"`
+
+	// assets and pairs to support instead of the built in ones
+	AssetFile string `long:"assetfile" description:"Name of a json file in the root directory with the assets to support and the pairs to trade them in. The built in assets and every pair of them are used without one"`
+
+	// tick size, lot size, min value, and max size for each pair
+	PairFile string `long:"pairfile" description:"Name of a json file in the root directory with the rules orders have to follow for each pair. Any order is accepted without one"`
 }
 
 var (
@@ -90,8 +123,20 @@ var (
 	// Yes we want to use noise-rpc
 	defaultAuthenticatedRPC = true
 
+	// Yes we want to publish market data
+	defaultPubSubPort = uint16(12347)
+
+	// Yes we want websockets
+	defaultWebSocketPort = uint16(12349)
+
+	// Yes we want the REST gateway
+	defaultRestPort = uint16(12348)
+
 	// Yes we want lightning
 	defaultLightningSupport = true
+
+	// Expired orders can be matched until they're swept
+	defaultExpirySweep = 10 * time.Second
 )
 
 // newConfigParser returns a new command line flags parser.
@@ -112,12 +157,33 @@ func main() {
 		Lithost:          defaultLithost,
 		Litport:          defaultLitport,
 		AuthenticatedRPC: defaultAuthenticatedRPC,
+		PubSubPort:       defaultPubSubPort,
+		WebSocketPort:    defaultWebSocketPort,
+		RestPort:         defaultRestPort,
 		LightningSupport: defaultLightningSupport,
+		ExpirySweep:      defaultExpirySweep,
 	}
 
 	// Check and load config params
 	key := opencxSetup(&conf)
 
+	// Assets have to be registered before anything uses them
+	if conf.AssetFile != "" {
+		var assetData []byte
+		if assetData, err = ioutil.ReadFile(filepath.Join(conf.OpencxHomeDir, conf.AssetFile)); err != nil {
+			logging.Fatalf("Error reading asset file for opencxd: %s", err)
+		}
+
+		var registry *match.AssetRegistry
+		if registry, err = match.ParseAssetConfig(assetData); err != nil {
+			logging.Fatalf("Error parsing asset file for opencxd: %s", err)
+		}
+
+		if err = match.SetAssetRegistry(registry); err != nil {
+			logging.Fatalf("Error setting asset registry for opencxd: %s", err)
+		}
+	}
+
 	// Generate the coin list based on the parameters we know
 	coinList := generateCoinList(&conf)
 
@@ -126,10 +192,50 @@ func main() {
 		logging.Fatalf("Could not generate asset pairs from coin list: %s", err)
 	}
 
+	// The journal is opened before anything is stored, since when it's used with the SQL stores they are
+	// rebuilt from it
+	var journal cxdb.Journal
+	if conf.Journal {
+		if journal, err = cxdbfile.CreateJournal(filepath.Join(conf.OpencxHomeDir, defaultJournalFilename)); err != nil {
+			logging.Fatalf("Error opening journal for opencxd: %s", err)
+		}
+
+		if !conf.MemoryDB {
+			var entries []*cxdb.JournalEntry
+			if entries, err = journal.Entries(); err != nil {
+				logging.Fatalf("Error reading journal for opencxd: %s", err)
+			}
+
+			// If there's nothing in the journal, whatever is in the SQL stores was never journaled and
+			// would be lost
+			if len(entries) == 0 {
+				var empty bool
+				if empty, err = cxdbsql.JournaledStateEmpty(); err != nil {
+					logging.Fatalf("Error checking SQL stores for opencxd: %s", err)
+				}
+
+				if !empty {
+					logging.Fatalf("The SQL stores have balances or orders that are not in the journal, please start with empty SQL stores to use the journal")
+				}
+			}
+
+			logging.Infof("Clearing SQL stores so they can be rebuilt from the journal...")
+			if err = cxdbsql.DropJournaledState(); err != nil {
+				logging.Fatalf("Error clearing SQL stores for opencxd: %s", err)
+			}
+		}
+	}
+
 	logging.Infof("Creating limit engines...")
 	var mengines map[match.Pair]match.LimitEngine
-	if mengines, err = cxdbsql.CreateLimitEngineMap(pairList); err != nil {
-		logging.Fatalf("Error creating limit engine map with coinlist for opencxd: %s", err)
+	if conf.MemoryDB {
+		if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+			logging.Fatalf("Error creating memory limit engine map with coinlist for opencxd: %s", err)
+		}
+	} else {
+		if mengines, err = cxdbsql.CreateLimitEngineMap(pairList); err != nil {
+			logging.Fatalf("Error creating limit engine map with coinlist for opencxd: %s", err)
+		}
 	}
 
 	var setEngines map[*coinparam.Params]match.SettlementEngine
@@ -154,6 +260,11 @@ func main() {
 		if setEngines, err = cxdbmemory.CreatePinkySwearEngineMap(whitelistMap, true); err != nil {
 			logging.Fatalf("Error creating pinky swear settlement engine map for opencxd: %s", err)
 		}
+	} else if conf.MemoryDB {
+		logging.Infof("Creating memory settlement engines...")
+		if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+			logging.Fatalf("Error creating memory settlement engine map for opencxd: %s", err)
+		}
 	} else {
 		logging.Infof("Creating settlement engines...")
 		if setEngines, err = cxdbsql.CreateSettlementEngineMap(coinList); err != nil {
@@ -166,28 +277,195 @@ func main() {
 
 	logging.Infof("Creating limit orderbooks...")
 	var limBooks map[match.Pair]match.LimitOrderbook
-	if limBooks, err = cxdbsql.CreateLimitOrderbookMap(pairList); err != nil {
-		logging.Fatalf("Error creating limit orderbook map for opencxd: %s", err)
+	if conf.MemoryDB {
+		if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+			logging.Fatalf("Error creating memory limit orderbook map for opencxd: %s", err)
+		}
+	} else {
+		if limBooks, err = cxdbsql.CreateLimitOrderbookMap(pairList); err != nil {
+			logging.Fatalf("Error creating limit orderbook map for opencxd: %s", err)
+		}
+	}
+
+	// There is no SQL stop order store, so stop orders are kept in memory. The journal is what keeps them
+	// across restarts, so they can't be placed without it.
+	logging.Infof("Creating stop order stores...")
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		logging.Fatalf("Error creating memory stop order store map for opencxd: %s", err)
+	}
+
+	logging.Infof("Creating trade stores...")
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if conf.MemoryDB {
+		if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+			logging.Fatalf("Error creating memory trade store map for opencxd: %s", err)
+		}
+	} else {
+		if tradeStores, err = cxdbsql.CreateTradeStoreMap(pairList); err != nil {
+			logging.Fatalf("Error creating trade store map for opencxd: %s", err)
+		}
+	}
+
+	logging.Infof("Creating candle stores...")
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if conf.MemoryDB {
+		if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+			logging.Fatalf("Error creating memory candle store map for opencxd: %s", err)
+		}
+	} else {
+		if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+			logging.Fatalf("Error creating candle store map for opencxd: %s", err)
+		}
 	}
 
 	logging.Infof("Creating deposit stores...")
 	var depositStores map[*coinparam.Params]cxdb.DepositStore
-	if depositStores, err = cxdbsql.CreateDepositStoreMap(coinList); err != nil {
-		logging.Fatalf("Error creating deposit store map for opencxd: %s", err)
+	if conf.MemoryDB {
+		if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+			logging.Fatalf("Error creating memory deposit store map for opencxd: %s", err)
+		}
+	} else {
+		if depositStores, err = cxdbsql.CreateDepositStoreMap(coinList); err != nil {
+			logging.Fatalf("Error creating deposit store map for opencxd: %s", err)
+		}
 	}
 
 	logging.Infof("Creating settlement stores...")
 	var setStores map[*coinparam.Params]cxdb.SettlementStore
-	if setStores, err = cxdbsql.CreateSettlementStoreMap(coinList); err != nil {
-		logging.Fatalf("Error creating settlement store map for opencxd: %s", err)
+	if conf.MemoryDB {
+		if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+			logging.Fatalf("Error creating memory settlement store map for opencxd: %s", err)
+		}
+	} else {
+		if setStores, err = cxdbsql.CreateSettlementStoreMap(coinList); err != nil {
+			logging.Fatalf("Error creating settlement store map for opencxd: %s", err)
+		}
 	}
 
 	// Anyways, here's where we set the server
 	var ocxServer *cxserver.OpencxServer
-	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, depositStores, setStores, conf.OpencxHomeDir); err != nil {
+	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, conf.OpencxHomeDir); err != nil {
 		logging.Fatalf("Error initializing server for opencxd: %s", err)
 	}
 
+	// The nonces from order envelopes are in memory by default, where the journal is what keeps them across
+	// restarts
+	if !conf.MemoryDB {
+		var nonceStore cxdb.NonceStore
+		if nonceStore, err = cxdbsql.CreateNonceStore(); err != nil {
+			logging.Fatalf("Error creating nonce store for opencxd: %s", err)
+		}
+
+		if err = ocxServer.SetNonceStore(nonceStore); err != nil {
+			logging.Fatalf("Error setting nonce store for opencxd: %s", err)
+		}
+	}
+
+	// Pairs added while the exchange is running are stored the same way as the pairs it started with. This
+	// has to be set before the journal is replayed, since the journal can have pairs that were added.
+	ocxServer.PairStoreCreator = func(pair *match.Pair) (matchEngine match.LimitEngine, book match.LimitOrderbook, stopStore cxdb.StopOrderStore, tradeStore cxdb.TradeStore, candleStore cxdb.CandleStore, err error) {
+		if conf.MemoryDB {
+			if matchEngine, err = cxdbmemory.CreateLimitEngine(pair); err != nil {
+				return
+			}
+
+			if book, err = cxdbmemory.CreateLimitOrderbook(pair); err != nil {
+				return
+			}
+
+			if tradeStore, err = cxdbmemory.CreateTradeStore(pair); err != nil {
+				return
+			}
+
+			if candleStore, err = cxdbmemory.CreateCandleStore(pair); err != nil {
+				return
+			}
+		} else {
+			if matchEngine, err = cxdbsql.CreateLimitEngine(pair); err != nil {
+				return
+			}
+
+			if book, err = cxdbsql.CreateLimitOrderbook(pair); err != nil {
+				return
+			}
+
+			if tradeStore, err = cxdbsql.CreateTradeStore(pair); err != nil {
+				return
+			}
+
+			if candleStore, err = cxdbsql.CreateCandleStore(pair); err != nil {
+				return
+			}
+		}
+
+		stopStore, err = cxdbmemory.CreateStopOrderStore(pair)
+		return
+	}
+
+	if conf.FeeFile != "" {
+		var feeData []byte
+		if feeData, err = ioutil.ReadFile(filepath.Join(conf.OpencxHomeDir, conf.FeeFile)); err != nil {
+			logging.Fatalf("Error reading fee file for opencxd: %s", err)
+		}
+
+		var feeAccount [33]byte
+		var feeSchedules map[match.Pair]*match.FeeSchedule
+		if feeAccount, feeSchedules, err = match.ParseFeeConfig(feeData, pairList); err != nil {
+			logging.Fatalf("Error parsing fee file for opencxd: %s", err)
+		}
+
+		if err = ocxServer.SetFees(feeAccount, feeSchedules); err != nil {
+			logging.Fatalf("Error setting fees for opencxd: %s", err)
+		}
+	}
+
+	if conf.PairFile != "" {
+		var pairData []byte
+		if pairData, err = ioutil.ReadFile(filepath.Join(conf.OpencxHomeDir, conf.PairFile)); err != nil {
+			logging.Fatalf("Error reading pair file for opencxd: %s", err)
+		}
+
+		var pairRules map[match.Pair]*match.PairRules
+		if pairRules, err = match.ParsePairRulesConfig(pairData, pairList); err != nil {
+			logging.Fatalf("Error parsing pair file for opencxd: %s", err)
+		}
+
+		if err = ocxServer.SetPairRules(pairRules); err != nil {
+			logging.Fatalf("Error setting pair rules for opencxd: %s", err)
+		}
+	}
+
+	// The journal rebuilds everything that was in memory, so we need to do this before anything else can
+	// change balances or orders
+	if journal != nil {
+		if err = ocxServer.ReplayJournal(journal); err != nil {
+			logging.Fatalf("Error replaying journal for opencxd: %s", err)
+		}
+	}
+
+	// Only start cancelling expired orders once the journal has been replayed
+	if conf.ExpirySweep <= 0 {
+		logging.Fatalf("The expiry sweep interval must be positive")
+	}
+	ocxServer.StartExpirySweeper(conf.ExpirySweep)
+
+	// Publish market data once the books have everything from the journal
+	var publisher *cxpubsub.Publisher
+	if conf.PubSubPort != 0 || conf.WebSocketPort != 0 {
+		publisher = cxpubsub.NewPublisher()
+		if err = ocxServer.SetPublisher(publisher); err != nil {
+			logging.Fatalf("Error setting publisher for opencxd: %s", err)
+		}
+	}
+
+	if conf.PubSubPort != 0 {
+		privkey, _ := koblitz.PrivKeyFromBytes(koblitz.S256(), key[:])
+		if err = publisher.NoiseListen(privkey, conf.PubSubPort); err != nil {
+			logging.Fatalf("Error listening for market data connections for opencxd: %s", err)
+		}
+	}
+
 	// For debugging but also it looks nice
 	for _, coin := range coinList {
 		logging.Infof("Coin supported: %s", coin.Name)
@@ -253,6 +531,30 @@ func main() {
 		logging.Fatalf("Error creating rpc caller for server: %s", err)
 	}
 
+	// Serve RPC commands and market data over websockets, for browsers
+	var wsGateway *cxwebsocket.Gateway
+	if conf.WebSocketPort != 0 {
+		if wsGateway, err = cxwebsocket.CreateGateway(&cxrpc.OpencxRPC{Server: ocxServer}, publisher); err != nil {
+			logging.Fatalf("Error creating websocket gateway for server: %s", err)
+		}
+
+		if err = wsGateway.WebSocketListen(conf.Rpchost, conf.WebSocketPort); err != nil {
+			logging.Fatalf("Error listening for websocket connections for server: %s", err)
+		}
+	}
+
+	// Serve the REST gateway alongside RPC, for clients that can't use net/rpc
+	var restGateway *cxrpc.RESTGateway
+	if conf.RestPort != 0 {
+		if restGateway, err = cxrpc.CreateRESTGateway(ocxServer); err != nil {
+			logging.Fatalf("Error creating REST gateway for server: %s", err)
+		}
+
+		if err = restGateway.RESTListen(conf.Rpchost, conf.RestPort); err != nil {
+			logging.Fatalf("Error listening for REST requests for server: %s", err)
+		}
+	}
+
 	// SIGINT and SIGTERM and SIGQUIT handler for CTRL-c, KILL, CTRL-/, etc.
 	go func() {
 		logging.Infof("Notifying signals")
@@ -264,6 +566,20 @@ func main() {
 			signal := <-sigs
 			logging.Infof("Received %s signal, Stopping server gracefully...", signal.String())
 
+			// stop websocket gateway
+			if wsGateway != nil {
+				if err = wsGateway.Stop(); err != nil {
+					logging.Errorf("Error stopping websocket gateway: %s", err)
+				}
+			}
+
+			// stop REST gateway
+			if restGateway != nil {
+				if err = restGateway.Stop(); err != nil {
+					logging.Errorf("Error stopping REST gateway: %s", err)
+				}
+			}
+
 			// stop rpc listener
 			if err = rpcListener.Stop(); err != nil {
 				logging.Fatalf("Error killing server: %s", err)
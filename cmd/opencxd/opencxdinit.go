@@ -13,16 +13,18 @@ import (
 	litLogging "github.com/mit-dci/lit/logging"
 	flags "github.com/jessevdk/go-flags"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 )
 
 var (
 
 	// used in init file, so separate
-	defaultLogLevel       = 0
-	defaultLitLogLevel    = 0
-	defaultConfigFilename = "opencx.conf"
-	defaultLogFilename    = "opencxdlog.txt"
-	defaultKeyFileName    = "privkey.hex"
+	defaultLogLevel        = 0
+	defaultLitLogLevel     = 0
+	defaultConfigFilename  = "opencx.conf"
+	defaultLogFilename     = "opencxdlog.txt"
+	defaultKeyFileName     = "privkey.hex"
+	defaultJournalFilename = "journal.dat"
 )
 
 // createDefaultConfigFile creates a config file  -- only call this if the
@@ -215,5 +217,22 @@ func generateHostParams(conf *opencxConfig) (hostParamList []*util.HostParams) {
 	if conf.Litereghost != "" {
 		hostParamList = append(hostParamList, &util.HostParams{Param: &coinparam.LiteRegNetParams, Host: conf.Litereghost})
 	}
+
+	// Assets from the asset file can have a host, for chains that don't have a flag
+	for _, asset := range match.GetAssetRegistry().Assets() {
+		if asset.Host == "" {
+			continue
+		}
+
+		hasHost := false
+		for _, hostParam := range hostParamList {
+			if hostParam.Param == asset.Params && hostParam.Host != "" {
+				hasHost = true
+			}
+		}
+		if !hasHost {
+			hostParamList = append(hostParamList, &util.HostParams{Param: asset.Params, Host: asset.Host})
+		}
+	}
 	return
 }
@@ -0,0 +1,28 @@
+package cxauctionrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetFeeRevenueArgs holds the args for the getfeerevenue command
+type GetFeeRevenueArgs struct {
+	// empty
+}
+
+// GetFeeRevenueReply holds the reply for the getfeerevenue command
+type GetFeeRevenueReply struct {
+	// Revenue is the total amount of each asset that has been paid to the fee account
+	Revenue map[match.Asset]uint64
+}
+
+// GetFeeRevenue returns how much of each asset the exchange has been paid in trading fees from auctions
+func (cl *OpencxAuctionRPC) GetFeeRevenue(args GetFeeRevenueArgs, reply *GetFeeRevenueReply) (err error) {
+	if reply.Revenue, err = cl.Server.GetFeeRevenue(); err != nil {
+		err = fmt.Errorf("Error getting fee revenue for GetFeeRevenue RPC command: %s", err)
+		return
+	}
+
+	return
+}
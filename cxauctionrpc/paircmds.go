@@ -0,0 +1,37 @@
+package cxauctionrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetPairsArgs holds the args for the GetPairs command
+type GetPairsArgs struct {
+	// empty
+}
+
+// GetPairsReply holds the reply for the GetPairs command
+type GetPairsReply struct {
+	PairList []string
+	// Rules are the rules orders have to follow for each pair in the PairList, keyed by the same string, so
+	// clients can check orders before encrypting and submitting them
+	Rules map[string]*match.PairRules
+}
+
+// GetPairs gets all the pairs that have auctions as nice strings, and the rules for each of them
+func (cl *OpencxAuctionRPC) GetPairs(args GetPairsArgs, reply *GetPairsReply) (err error) {
+	var rules map[match.Pair]*match.PairRules
+	if rules, err = cl.Server.GetPairRules(); err != nil {
+		err = fmt.Errorf("Error getting pair rules for GetPairs RPC command: %s", err)
+		return
+	}
+
+	reply.Rules = make(map[string]*match.PairRules)
+	for pair, pairRules := range rules {
+		reply.PairList = append(reply.PairList, pair.PrettyString())
+		reply.Rules[pair.PrettyString()] = pairRules
+	}
+
+	return
+}
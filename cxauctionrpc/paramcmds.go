@@ -20,9 +20,12 @@ type GetPublicParametersReply struct {
 	// for extra time.
 	AuctionTime uint64
 	StartTime   time.Time
+	// ExchangePubkey is the compressed pubkey that the envelopes of auction orders have to be for
+	ExchangePubkey [33]byte
 }
 
-// GetPublicParameters gets public parameters from the exchange, like time and auctionID
+// GetPublicParameters gets public parameters from the exchange, like time, auctionID, and the pubkey
+// that order envelopes have to be for
 func (cl *OpencxAuctionRPC) GetPublicParameters(args GetPublicParametersArgs, reply *GetPublicParametersReply) (err error) {
 	if reply.AuctionID, reply.StartTime, err = cl.Server.GetIDTimeFromPair(&args.Pair); err != nil {
 		err = fmt.Errorf("Error getting public param auction id: %s", err)
@@ -34,5 +37,10 @@ func (cl *OpencxAuctionRPC) GetPublicParameters(args GetPublicParametersArgs, re
 		return
 	}
 
+	if reply.ExchangePubkey, err = cl.Server.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting public param exchange pubkey: %s", err)
+		return
+	}
+
 	return
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/mit-dci/opencx/cxdb"
 	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
 	"github.com/mit-dci/opencx/cxdb/cxdbsql"
+	"github.com/mit-dci/opencx/cxpubsub"
 	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 	"golang.org/x/text/number"
@@ -26,6 +27,37 @@ type OpencxAuctionServer struct {
 	orderChannel      chan *match.OrderPuzzleResult
 	orderChanMap      map[[32]byte]chan *match.OrderPuzzleResult
 
+	// journal is where placements and matches are written before they are applied, set by SetJournal
+	journal cxdb.Journal
+
+	// FeeSchedules are the fees for each pair, and FeeAccount is the pubkey the fees are paid to. Pairs
+	// without a fee schedule don't charge fees. Set them with SetFees.
+	FeeSchedules map[match.Pair]*match.FeeSchedule
+	FeeAccount   [33]byte
+
+	// PairRules are the rules that auction orders have to follow for each pair, protected by the dbLock.
+	// Orders that break them are rejected when their batch is validated. Set them with SetPairRules.
+	PairRules map[match.Pair]*match.PairRules
+
+	// feeRevenue is the total amount of each asset that has been paid in fees, protected by the dbLock
+	feeRevenue map[match.Asset]uint64
+
+	// CandleStores keep the price and volume each auction cleared at, and the candles made from them,
+	// protected by the dbLock
+	CandleStores map[match.Pair]cxdb.CandleStore
+
+	// publisher is sent the price and volume of each auction as it clears, if it is set. Set it with
+	// SetPublisher.
+	publisher *cxpubsub.Publisher
+
+	// exchangePubkey is the compressed pubkey that the envelopes of auction orders have to be for, protected
+	// by the dbLock. Orders are rejected until it's set with SetExchangePubkey.
+	exchangePubkey *[33]byte
+
+	// nonceStore keeps the nonces each pubkey has used in order envelopes, in memory unless it's set with
+	// SetNonceStore. It's protected by the dbLock.
+	nonceStore cxdb.NonceStore
+
 	// auction params -- we'll store them in here for now
 	t uint64
 
@@ -73,7 +105,13 @@ func InitServerMemoryDefault(coinList []*coinparam.Params, orderChanSize uint64,
 		return
 	}
 
-	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, orderChanSize, standardAuctionTime); err != nil {
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for InitServerMemoryDefault: %s", err)
+		return
+	}
+
+	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, candleStores, orderChanSize, standardAuctionTime); err != nil {
 		err = fmt.Errorf("Error initializing server for InitServerMemoryDefault: %s", err)
 		return
 	}
@@ -120,7 +158,13 @@ func InitServerSQLDefault(coinList []*coinparam.Params, orderChanSize uint64, st
 		return
 	}
 
-	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, orderChanSize, standardAuctionTime); err != nil {
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for InitServerSQLDefault: %s", err)
+		return
+	}
+
+	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, candleStores, orderChanSize, standardAuctionTime); err != nil {
 		err = fmt.Errorf("Error initializing server for createFullServer: %s", err)
 		return
 	}
@@ -128,20 +172,29 @@ func InitServerSQLDefault(coinList []*coinparam.Params, orderChanSize uint64, st
 }
 
 // InitServer creates a new server
-func InitServer(setEngines map[*coinparam.Params]match.SettlementEngine, matchEngines map[match.Pair]match.AuctionEngine, books map[match.Pair]match.AuctionOrderbook, pzengines map[match.Pair]cxdb.PuzzleStore, batchers map[match.Pair]match.AuctionBatcher, orderChanSize uint64, standardAuctionTime uint64) (server *OpencxAuctionServer, err error) {
+func InitServer(setEngines map[*coinparam.Params]match.SettlementEngine, matchEngines map[match.Pair]match.AuctionEngine, books map[match.Pair]match.AuctionOrderbook, pzengines map[match.Pair]cxdb.PuzzleStore, batchers map[match.Pair]match.AuctionBatcher, candleStores map[match.Pair]cxdb.CandleStore, orderChanSize uint64, standardAuctionTime uint64) (server *OpencxAuctionServer, err error) {
 	server = &OpencxAuctionServer{
 		SettlementEngines: setEngines,
 		MatchingEngines:   matchEngines,
 		Orderbooks:        books,
 		PuzzleEngines:     pzengines,
 		OrderBatchers:     batchers,
+		CandleStores:      candleStores,
 		dbLock:            new(sync.Mutex),
+		FeeSchedules:      make(map[match.Pair]*match.FeeSchedule),
+		PairRules:         make(map[match.Pair]*match.PairRules),
+		feeRevenue:        make(map[match.Asset]uint64),
 		orderChannel:      make(chan *match.OrderPuzzleResult, orderChanSize),
 		orderChanMap:      make(map[[32]byte]chan *match.OrderPuzzleResult),
 		t:                 standardAuctionTime,
 		clockOffButton:    make(chan bool, 1),
 	}
 
+	if server.nonceStore, err = cxdbmemory.CreateNonceStore(); err != nil {
+		err = fmt.Errorf("Error creating nonce store for InitServer: %s", err)
+		return
+	}
+
 	return
 }
 
@@ -94,8 +94,14 @@ func createUltraLightAuctionServer(coinList []*coinparam.Params, orderChanSize u
 		return
 	}
 
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for createUltraLightAuctionServer: %s", err)
+		return
+	}
+
 	// orderChanSize = 100 because uh why not?
-	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, orderChanSize, auctionTime); err != nil {
+	if server, err = InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, candleStores, orderChanSize, auctionTime); err != nil {
 		err = fmt.Errorf("Error initializing server for createUltraLightAuctionServer: %s", err)
 		return
 	}
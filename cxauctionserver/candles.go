@@ -0,0 +1,85 @@
+package cxauctionserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetCandles returns the candles for a pair and interval that start at or after start and before end,
+// oldest first. If end is zero there is no end.
+func (s *OpencxAuctionServer) GetCandles(pair *match.Pair, interval match.CandleInterval, start time.Time, end time.Time) (candles []*match.Candle, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot get candles for nil pair, please enter valid input")
+		return
+	}
+
+	s.dbLock.Lock()
+	var currCandleStore cxdb.CandleStore
+	var ok bool
+	if currCandleStore, ok = s.CandleStores[*pair]; !ok {
+		err = fmt.Errorf("Could not find candle store for trading pair for GetCandles")
+		s.dbLock.Unlock()
+		return
+	}
+
+	if candles, err = currCandleStore.GetCandles(interval, start, end); err != nil {
+		err = fmt.Errorf("Error getting candles for server GetCandles: %s", err)
+		s.dbLock.Unlock()
+		return
+	}
+	s.dbLock.Unlock()
+	return
+}
+
+// addAuctionExecution adds an auction execution to the candle store for its pair, and publishes it if the
+// server has a publisher. Nothing is added if the execution is nil, since that means nothing cleared.
+// This is only called when a JournalAuctionMatch entry is applied, so candles are rebuilt when the journal
+// is replayed.
+// This should be called with the dbLock held.
+func (s *OpencxAuctionServer) addAuctionExecution(exec *match.PriceExecution) (err error) {
+	if exec == nil {
+		return
+	}
+
+	var currCandleStore cxdb.CandleStore
+	var ok bool
+	if currCandleStore, ok = s.CandleStores[exec.Pair]; !ok {
+		err = fmt.Errorf("Could not find candle store for trading pair %s", exec.Pair.String())
+		return
+	}
+
+	if err = currCandleStore.AddExecutions([]*match.PriceExecution{exec}); err != nil {
+		err = fmt.Errorf("Error adding auction execution to candle store: %s", err)
+		return
+	}
+
+	if s.publisher == nil {
+		return
+	}
+
+	if err = s.publisher.PublishExecutions(cxpubsub.AuctionChannel, exec.Pair, []*match.PriceExecution{exec}); err != nil {
+		err = fmt.Errorf("Error publishing auction execution: %s", err)
+		return
+	}
+	return
+}
+
+// SetPublisher sets the publisher that the price and volume of each auction are sent to as it clears
+func (s *OpencxAuctionServer) SetPublisher(publisher *cxpubsub.Publisher) (err error) {
+	if publisher == nil {
+		err = fmt.Errorf("Cannot set nil publisher, please enter valid input")
+		return
+	}
+
+	s.dbLock.Lock()
+	for pair := range s.MatchingEngines {
+		publisher.AddPair(pair)
+	}
+	s.publisher = publisher
+	s.dbLock.Unlock()
+	return
+}
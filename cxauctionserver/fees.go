@@ -0,0 +1,73 @@
+package cxauctionserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetFees sets the account that fees are paid to and the fee schedule for each pair. Pairs without a fee
+// schedule don't charge fees.
+func (s *OpencxAuctionServer) SetFees(account [33]byte, schedules map[match.Pair]*match.FeeSchedule) (err error) {
+	for pair, schedule := range schedules {
+		if schedule == nil {
+			err = fmt.Errorf("Fee schedule for %s cannot be nil, please enter valid input", pair.String())
+			return
+		}
+
+		if err = schedule.Valid(); err != nil {
+			err = fmt.Errorf("Invalid fee schedule for %s for SetFees: %s", pair.String(), err)
+			return
+		}
+	}
+
+	s.dbLock.Lock()
+	s.FeeAccount = account
+	s.FeeSchedules = make(map[match.Pair]*match.FeeSchedule)
+	for pair, schedule := range schedules {
+		s.FeeSchedules[pair] = schedule
+	}
+	s.dbLock.Unlock()
+	return
+}
+
+// GetFeeRevenue returns the total amount of each asset that has been paid in fees
+func (s *OpencxAuctionServer) GetFeeRevenue() (revenue map[match.Asset]uint64, err error) {
+	revenue = make(map[match.Asset]uint64)
+
+	s.dbLock.Lock()
+	for asset, amount := range s.feeRevenue {
+		revenue[asset] = amount
+	}
+	s.dbLock.Unlock()
+	return
+}
+
+// feeExecutions returns the settlement executions that charge fees for the settlement executions from
+// matching an auction for a pair, or nothing if the pair has no fee schedule.
+// This should be called with the dbLock held.
+func (s *OpencxAuctionServer) feeExecutions(pair match.Pair, settlementExecs []*match.SettlementExecution) (feeExecs []*match.SettlementExecution, err error) {
+	var schedule *match.FeeSchedule
+	var ok bool
+	if schedule, ok = s.FeeSchedules[pair]; !ok {
+		return
+	}
+
+	if feeExecs, err = schedule.AuctionFeeExecutions(settlementExecs, s.FeeAccount); err != nil {
+		err = fmt.Errorf("Error getting auction fee executions for pair %s: %s", pair.String(), err)
+		return
+	}
+	return
+}
+
+// addFeeRevenue adds every debit in the fee executions to the fee revenue, since those are fees paid to the
+// fee account.
+// This should be called with the dbLock held, after the fee executions are applied.
+func (s *OpencxAuctionServer) addFeeRevenue(feeExecs []*match.SettlementExecution) {
+	for _, feeExec := range feeExecs {
+		if feeExec.Type == match.Debit {
+			s.feeRevenue[feeExec.Asset] += feeExec.Amount
+		}
+	}
+	return
+}
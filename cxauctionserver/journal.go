@@ -0,0 +1,125 @@
+package cxauctionserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetJournal sets the journal that every auction placement and match is written to before it is applied.
+// The journal is hash chained, so it can be audited, or replayed from the first entry with ocxreplay.
+// This does not replay the journal, since the engines and orderbooks could already be persistent.
+func (s *OpencxAuctionServer) SetJournal(journal cxdb.Journal) (err error) {
+	if journal == nil {
+		err = fmt.Errorf("Cannot set nil journal, please enter valid input")
+		return
+	}
+
+	s.dbLock.Lock()
+	s.journal = journal
+	s.dbLock.Unlock()
+	return
+}
+
+// writeJournal durably writes the entry to the journal, if the server has one.
+// This should be called with the dbLock held, before the entry is applied.
+func (s *OpencxAuctionServer) writeJournal(entry *cxdb.JournalEntry) (err error) {
+	if s.journal == nil {
+		return
+	}
+
+	if err = s.journal.AppendEntry(entry); err != nil {
+		err = fmt.Errorf("Error appending %s entry to journal: %s", entry.Type.String(), err)
+		return
+	}
+	return
+}
+
+// applyJournalEntry applies the settlement and fee executions in an entry to the settlement engines, then the auction
+// order's nonce to the nonce store and its placement or executions to the orderbook, and then the auction execution to the candle store.
+// The auction engines are not changed, since they are what the entry came from.
+// This should be called with the dbLock held.
+func (s *OpencxAuctionServer) applyJournalEntry(entry *cxdb.JournalEntry) (err error) {
+	if entry == nil {
+		err = fmt.Errorf("Cannot apply nil journal entry, please enter valid input")
+		return
+	}
+
+	for _, setExec := range append(append([]*match.SettlementExecution{}, entry.SettlementExecs...), entry.FeeExecs...) {
+		var thisCoin *coinparam.Params
+		if thisCoin, err = setExec.Asset.CoinParamFromAsset(); err != nil {
+			err = fmt.Errorf("Error getting coin param from asset to find correct engine: %s", err)
+			return
+		}
+
+		var thisAssetEngine match.SettlementEngine
+		var ok bool
+		if thisAssetEngine, ok = s.SettlementEngines[thisCoin]; !ok {
+			err = fmt.Errorf("Could not find settlement engine for cointype %s", thisCoin.Name)
+			return
+		}
+
+		var valid bool
+		if valid, err = thisAssetEngine.CheckValid(setExec); err != nil {
+			err = fmt.Errorf("Error checking valid settlement exec: %s", err)
+			return
+		}
+
+		if !valid {
+			err = fmt.Errorf("Error, invalid settlement exec: \n%s", setExec.String())
+			return
+		}
+
+		if _, err = thisAssetEngine.ApplySettlementExecution(setExec); err != nil {
+			err = fmt.Errorf("Error applying settlement execution: %s", err)
+			return
+		}
+	}
+	s.addFeeRevenue(entry.FeeExecs)
+
+	switch entry.Type {
+	case cxdb.JournalAuctionPlace:
+		var currOrderbook match.AuctionOrderbook
+		var ok bool
+		if currOrderbook, ok = s.Orderbooks[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find orderbook for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		// The nonce is used first, so the envelope can't place the order again
+		if entry.NonceUse != nil {
+			if err = s.nonceStore.UseNonce(entry.NonceUse); err != nil {
+				err = fmt.Errorf("Error adding nonce to nonce store: %s", err)
+				return
+			}
+		}
+
+		if err = currOrderbook.UpdateBookPlace(entry.PlacedAuctionOrder); err != nil {
+			err = fmt.Errorf("Error placing order on orderbook: %s", err)
+			return
+		}
+	case cxdb.JournalAuctionMatch:
+		var currOrderbook match.AuctionOrderbook
+		var ok bool
+		if currOrderbook, ok = s.Orderbooks[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find orderbook for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		for _, orderExec := range entry.OrderExecs {
+			if err = currOrderbook.UpdateBookExec(orderExec); err != nil {
+				err = fmt.Errorf("Error updating orderbook execution: %s", err)
+				return
+			}
+		}
+
+		if err = s.addAuctionExecution(entry.AuctionExecution); err != nil {
+			err = fmt.Errorf("Error adding auction execution: %s", err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,50 @@
+package cxauctionserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+)
+
+// SetExchangePubkey sets the pubkey that the envelopes of auction orders have to be for, which should be the
+// pubkey the exchange's noise connections are authenticated with. Orders are rejected until it is set.
+func (s *OpencxAuctionServer) SetExchangePubkey(pubkey *koblitz.PublicKey) (err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot set nil exchange pubkey, please enter valid input")
+		return
+	}
+
+	s.dbLock.Lock()
+	s.exchangePubkey = new([33]byte)
+	copy(s.exchangePubkey[:], pubkey.SerializeCompressed())
+	s.dbLock.Unlock()
+	return
+}
+
+// GetExchangePubkey returns the compressed pubkey that the envelopes of auction orders have to be for
+func (s *OpencxAuctionServer) GetExchangePubkey() (pubkey [33]byte, err error) {
+	s.dbLock.Lock()
+	if s.exchangePubkey == nil {
+		err = fmt.Errorf("The exchange pubkey has not been set")
+		s.dbLock.Unlock()
+		return
+	}
+	pubkey = *s.exchangePubkey
+	s.dbLock.Unlock()
+	return
+}
+
+// SetNonceStore sets the store that the nonces used in order envelopes are kept in. The nonces are kept in
+// memory by default, so orders could be replayed after a restart until their envelopes expire.
+func (s *OpencxAuctionServer) SetNonceStore(store cxdb.NonceStore) (err error) {
+	if store == nil {
+		err = fmt.Errorf("Cannot set nil nonce store, please enter valid input")
+		return
+	}
+
+	s.dbLock.Lock()
+	s.nonceStore = store
+	s.dbLock.Unlock()
+	return
+}
@@ -3,9 +3,9 @@ package cxauctionserver
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	"github.com/btcsuite/golangcrypto/sha3"
-	"github.com/mit-dci/lit/coinparam"
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/crypto/rsw"
 	"github.com/mit-dci/opencx/crypto/timelockencoders"
@@ -235,6 +235,8 @@ func (s *OpencxAuctionServer) asyncBatchPlacer(batchChan chan *match.AuctionBatc
 	return
 }
 
+// PlaceBatch places the valid orders in a batch into their auctions, then matches each of those auctions.
+// The placements and the results of matching are written to the journal before they are applied.
 func (s *OpencxAuctionServer) PlaceBatch(batch *match.AuctionBatch) (err error) {
 
 	s.dbLock.Lock()
@@ -242,11 +244,19 @@ func (s *OpencxAuctionServer) PlaceBatch(batch *match.AuctionBatch) (err error)
 	var auctionEngine match.AuctionEngine
 	var ok bool
 
+	// Nonces of expired envelopes can be forgotten, since those orders are rejected anyways
+	if err = s.nonceStore.PruneNonces(uint64(time.Now().Unix())); err != nil {
+		err = fmt.Errorf("Error pruning nonces for PlaceBatch: %s", err)
+		s.dbLock.Unlock()
+		return
+	}
+
 	var batchRes *match.BatchResult = s.validateBatch(batch)
 
 	logging.Infof("Got a batch result for %x! \n\tValid orders: %d\n\tInvalid orders: %d", batchRes.OriginalBatch, len(batchRes.AcceptedResults), len(batchRes.RejectedResults))
 
-	var auctionIDList map[match.AuctionID]bool = make(map[match.AuctionID]bool)
+	// Keep track of the pair for each auction so we match with the right engine
+	var auctionIDList map[match.AuctionID]match.Pair = make(map[match.AuctionID]match.Pair)
 	for _, acceptedOrder := range batchRes.AcceptedResults {
 		if acceptedOrder.Err != nil {
 			err = fmt.Errorf("Accepted order has a non-nil error: %s", acceptedOrder.Err)
@@ -267,7 +277,7 @@ func (s *OpencxAuctionServer) PlaceBatch(batch *match.AuctionBatch) (err error)
 			return
 		}
 
-		auctionIDList[*idStruct] = true
+		auctionIDList[*idStruct] = acceptedOrder.Auction.TradingPair
 
 		var placeRes *match.AuctionOrderIDPair
 		if placeRes, err = auctionEngine.PlaceAuctionOrder(acceptedOrder.Auction, idStruct); err != nil {
@@ -276,23 +286,93 @@ func (s *OpencxAuctionServer) PlaceBatch(batch *match.AuctionBatch) (err error)
 			return
 		}
 
+		placeEntry := &cxdb.JournalEntry{
+			Type:               cxdb.JournalAuctionPlace,
+			Pair:               acceptedOrder.Auction.TradingPair,
+			AuctionID:          *idStruct,
+			PlacedAuctionOrder: placeRes,
+			NonceUse: &cxdb.NonceUse{
+				Pubkey: acceptedOrder.Auction.Pubkey,
+				Nonce:  acceptedOrder.Auction.Envelope.Nonce,
+				Expiry: acceptedOrder.Auction.Envelope.Expiry,
+			},
+		}
+
+		if err = s.writeJournal(placeEntry); err != nil {
+			err = fmt.Errorf("Error writing journal for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
+
+		if err = s.applyJournalEntry(placeEntry); err != nil {
+			err = fmt.Errorf("Error applying journal entry for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
+
 		logging.Infof("Placed order %x for auction %x", placeRes.OrderID[:], acceptedOrder.Auction.AuctionID)
 
 	}
 
 	// Now we're going to match it
 	var currIDPtr *match.AuctionID
-	for id, _ := range auctionIDList {
+	for id, pair := range auctionIDList {
 		// I don't want to reuse the `id` loop var pointer
 		currIDPtr = new(match.AuctionID)
 		*currIDPtr = id
-		// We ignore the Order executions because we're not doing anything about them yet
-		if _, _, err = auctionEngine.MatchAuctionOrders(currIDPtr); err != nil {
+
+		if auctionEngine, ok = s.MatchingEngines[pair]; !ok {
+			err = fmt.Errorf("Could not find matching engine for pair %s", pair.String())
+			s.dbLock.Unlock()
+			return
+		}
+
+		var orderExecs []*match.OrderExecution
+		var settlementExecs []*match.SettlementExecution
+		if orderExecs, settlementExecs, err = auctionEngine.MatchAuctionOrders(currIDPtr); err != nil {
 			err = fmt.Errorf("Error matching orders for PlaceBatch: %s", err)
 			s.dbLock.Unlock()
 			return
 		}
+
+		var feeExecs []*match.SettlementExecution
+		if feeExecs, err = s.feeExecutions(pair, settlementExecs); err != nil {
+			err = fmt.Errorf("Error getting fee executions for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
+
+		var auctionExec *match.PriceExecution
+		if auctionExec, err = match.NewAuctionExecution(pair, id, settlementExecs, time.Now()); err != nil {
+			err = fmt.Errorf("Error creating auction execution for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
+
+		matchEntry := &cxdb.JournalEntry{
+			Type:             cxdb.JournalAuctionMatch,
+			Pair:             pair,
+			AuctionID:        id,
+			SettlementExecs:  settlementExecs,
+			FeeExecs:         feeExecs,
+			OrderExecs:       orderExecs,
+			AuctionExecution: auctionExec,
+		}
+
+		if err = s.writeJournal(matchEntry); err != nil {
+			err = fmt.Errorf("Error writing journal for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
+
+		if err = s.applyJournalEntry(matchEntry); err != nil {
+			err = fmt.Errorf("Error applying journal entry for PlaceBatch: %s", err)
+			s.dbLock.Unlock()
+			return
+		}
 	}
+
+	s.dbLock.Unlock()
 	return
 }
 
@@ -314,9 +394,17 @@ func (s *OpencxAuctionServer) validateEncryptedOrder(order *match.EncryptedAucti
 	return
 }
 
-// validateBatch validates a batch of orders, sorting into accepted and rejected piles using validateOrder
+// batchNonce is a nonce used by a pubkey in a batch
+type batchNonce struct {
+	pubkey [33]byte
+	nonce  uint64
+}
+
+// validateBatch validates a batch of orders, sorting into accepted and rejected piles using validateOrder.
+// Only the first order in the batch with each nonce is accepted.
 func (s *OpencxAuctionServer) validateBatch(auctionBatch *match.AuctionBatch) (batchResult *match.BatchResult) {
 	var err error
+	batchNonces := make(map[batchNonce]bool)
 
 	batchResult = &match.BatchResult{
 		OriginalBatch:   auctionBatch,
@@ -326,23 +414,24 @@ func (s *OpencxAuctionServer) validateBatch(auctionBatch *match.AuctionBatch) (b
 
 	for _, orderPzRes := range auctionBatch.Batch {
 
-		var pr float64
-		if pr, err = orderPzRes.Auction.Price(); err != nil {
+		// Prices are exact fractions, so we only need to make sure there is a price
+		if _, err = orderPzRes.Auction.Price(); err != nil {
 			orderPzRes.Err = fmt.Errorf("Error getting price from order: %s", err)
 		}
-		// TODO: this is to protect the database, this is why switching to a better price system would be a good idea
-		if pr > float64(10000000000000000000000) {
-			orderPzRes.Err = fmt.Errorf("Price too high, complain online if you want the maximum price increased, or lower your price")
-		}
-		if pr < float64(1)/float64(1000000) {
-			orderPzRes.Err = fmt.Errorf("Price too low, complain online if you want the minimum price decreased, or increase your price")
-		}
 		if err = s.validateOrderResult(auctionBatch.AuctionID, orderPzRes); err != nil {
 			orderPzRes.Err = fmt.Errorf("Order invalid: %s", err)
 			batchResult.RejectedResults = append(batchResult.RejectedResults, orderPzRes)
-		} else {
-			batchResult.AcceptedResults = append(batchResult.AcceptedResults, orderPzRes)
+			continue
+		}
+
+		key := batchNonce{pubkey: orderPzRes.Auction.Pubkey, nonce: orderPzRes.Auction.Envelope.Nonce}
+		if batchNonces[key] {
+			orderPzRes.Err = fmt.Errorf("Order invalid: nonce %d was already used by pubkey %x in this batch", key.nonce, key.pubkey)
+			batchResult.RejectedResults = append(batchResult.RejectedResults, orderPzRes)
+			continue
 		}
+		batchNonces[key] = true
+		batchResult.AcceptedResults = append(batchResult.AcceptedResults, orderPzRes)
 	}
 
 	return
@@ -382,6 +471,13 @@ func (s *OpencxAuctionServer) validateOrderResult(claimedAuction [32]byte, resul
 		return
 	}
 
+	if rules, ok := s.PairRules[result.Auction.TradingPair]; ok {
+		if err = rules.CheckAuctionOrder(result.Auction); err != nil {
+			err = fmt.Errorf("Orders that don't follow the rules for their pair are invalid: %s", err)
+			return
+		}
+	}
+
 	// We could use pub key hashes here but there might not be any reason for it
 	var orderPublicKey *koblitz.PublicKey
 	if orderPublicKey, err = koblitz.ParsePubKey(result.Auction.Pubkey[:], koblitz.S256()); err != nil {
@@ -389,109 +485,64 @@ func (s *OpencxAuctionServer) validateOrderResult(claimedAuction [32]byte, resul
 		return
 	}
 
-	// e = h(asset)
-	sha3 := sha3.New256()
-	sha3.Write(result.Auction.SerializeSignable())
-	e := sha3.Sum(nil)
-
-	var recoveredPublickey *koblitz.PublicKey
-	if recoveredPublickey, _, err = koblitz.RecoverCompact(koblitz.S256(), result.Auction.Signature, e); err != nil {
-		err = fmt.Errorf("Orders whose signature cannot be verified with pubkey recovery are invalid: %s", err)
+	// The envelope is what makes sure the order was meant for this exchange, and hasn't been placed before
+	envelope := result.Auction.Envelope
+	if envelope == nil {
+		err = fmt.Errorf("Orders without an envelope are invalid")
 		return
 	}
 
-	if !recoveredPublickey.IsEqual(orderPublicKey) {
-		err = fmt.Errorf("Recovered public key %x does not equal to pubkey %x in order", recoveredPublickey.SerializeCompressed(), orderPublicKey.SerializeCompressed())
+	if s.exchangePubkey == nil {
+		err = fmt.Errorf("Orders cannot be validated until the exchange pubkey is set")
 		return
 	}
 
-	if !bytes.Equal(result.Encrypted.IntendedAuction[:], result.Auction.AuctionID[:]) {
-		err = fmt.Errorf("Auction ID for decrypted and encrypted order must be equal")
+	if envelope.Exchange != *s.exchangePubkey {
+		err = fmt.Errorf("Orders for exchange %x are invalid on this exchange %x", envelope.Exchange, *s.exchangePubkey)
 		return
 	}
 
-	if !bytes.Equal(claimedAuction[:], result.Auction.AuctionID[:]) {
-		err = fmt.Errorf("Auction ID must equal current auction")
+	if err = envelope.CheckAuctionOrder(result.Auction); err != nil {
+		err = fmt.Errorf("Orders whose envelope does not match are invalid: %s", err)
 		return
 	}
 
-	return
-}
-
-func (s *OpencxAuctionServer) runMatching(auctionID *match.AuctionID, pair *match.Pair) (err error) {
-
-	s.dbLock.Lock()
-
-	var matchEngine match.AuctionEngine
-	var ok bool
-	if matchEngine, ok = s.MatchingEngines[*pair]; !ok {
-		err = fmt.Errorf("Error getting correct matching engine for pair %s for runMatching", pair)
-		s.dbLock.Unlock()
+	if err = envelope.CheckExpiry(time.Now()); err != nil {
+		err = fmt.Errorf("Orders with an expired envelope are invalid: %s", err)
 		return
 	}
 
-	// We can now calculate a clearing price and run the matching algorithm
-	var orderExecs []*match.OrderExecution
-	var setExecs []*match.SettlementExecution
-	if orderExecs, setExecs, err = matchEngine.MatchAuctionOrders(auctionID); err != nil {
-		err = fmt.Errorf("Error matching orders for running matching: %s", err)
-		s.dbLock.Unlock()
+	var recoveredPublickey *koblitz.PublicKey
+	if recoveredPublickey, _, err = koblitz.RecoverCompact(koblitz.S256(), result.Auction.Signature, envelope.SigHash()); err != nil {
+		err = fmt.Errorf("Orders whose signature cannot be verified with pubkey recovery are invalid: %s", err)
 		return
 	}
 
-	var orderbook match.AuctionOrderbook
-	if orderbook, ok = s.Orderbooks[*pair]; !ok {
-		err = fmt.Errorf("Error getting correct orderbook for pair %s for runMatching", pair)
-		s.dbLock.Unlock()
+	if !recoveredPublickey.IsEqual(orderPublicKey) {
+		err = fmt.Errorf("Recovered public key %x does not equal to pubkey %x in order", recoveredPublickey.SerializeCompressed(), orderPublicKey.SerializeCompressed())
 		return
 	}
 
-	for _, orderExec := range orderExecs {
-		if err = orderbook.UpdateBookExec(orderExec); err != nil {
-			err = fmt.Errorf("Error updating book for order execution: %s", err)
-			s.dbLock.Unlock()
-			return
-		}
+	var used bool
+	if used, err = s.nonceStore.NonceUsed(result.Auction.Pubkey, envelope.Nonce); err != nil {
+		err = fmt.Errorf("Error checking nonce of order: %s", err)
+		return
 	}
 
-	var setCoinParam *coinparam.Params
-	var setEngine match.SettlementEngine
-	for _, settlementExec := range setExecs {
-		// get coin param for debited
-		if setCoinParam, err = settlementExec.Asset.CoinParamFromAsset(); err != nil {
-			err = fmt.Errorf("Error getting coin param for runMatching: %s", err)
-			s.dbLock.Unlock()
-			return
-		}
-
-		if setEngine, ok = s.SettlementEngines[setCoinParam]; !ok {
-			err = fmt.Errorf("Error getting correct settlement engine for runMatching")
-			s.dbLock.Unlock()
-			return
-		}
-
-		var settleValid bool
-		if settleValid, err = setEngine.CheckValid(settlementExec); err != nil {
-			err = fmt.Errorf("Error checking valid for runMatching: %s", err)
-			return
-		}
-
-		if settleValid {
-
-			if _, err = setEngine.ApplySettlementExecution(settlementExec); err != nil {
-				err = fmt.Errorf("Error applyin settlement execution for runMatching: %s", err)
-				s.dbLock.Unlock()
-				return
-			}
-
-		} else {
-			err = fmt.Errorf("Settlement invalid for some reason, maybe run matching again to see if anything changes")
-			return
-		}
+	if used {
+		err = fmt.Errorf("Orders with a nonce that has already been used are invalid, nonce %d was used by pubkey %x", envelope.Nonce, result.Auction.Pubkey)
+		return
+	}
 
+	if !bytes.Equal(result.Encrypted.IntendedAuction[:], result.Auction.AuctionID[:]) {
+		err = fmt.Errorf("Auction ID for decrypted and encrypted order must be equal")
+		return
 	}
 
-	s.dbLock.Unlock()
+	if !bytes.Equal(claimedAuction[:], result.Auction.AuctionID[:]) {
+		err = fmt.Errorf("Auction ID must equal current auction")
+		return
+	}
 
 	return
 }
@@ -0,0 +1,47 @@
+package cxauctionserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetPairRules sets the rules that auction orders have to follow for each pair. Pairs without rules accept
+// any order.
+func (s *OpencxAuctionServer) SetPairRules(rules map[match.Pair]*match.PairRules) (err error) {
+	for pair, pairRules := range rules {
+		if pairRules == nil {
+			err = fmt.Errorf("Rules for %s cannot be nil, please enter valid input", pair.String())
+			return
+		}
+
+		if err = pairRules.Valid(); err != nil {
+			err = fmt.Errorf("Invalid rules for %s for SetPairRules: %s", pair.String(), err)
+			return
+		}
+	}
+
+	s.dbLock.Lock()
+	s.PairRules = make(map[match.Pair]*match.PairRules)
+	for pair, pairRules := range rules {
+		s.PairRules[pair] = pairRules
+	}
+	s.dbLock.Unlock()
+	return
+}
+
+// GetPairRules returns the rules for every pair the server has a matching engine for. The rules for a pair
+// without any are all zero.
+func (s *OpencxAuctionServer) GetPairRules() (rules map[match.Pair]*match.PairRules, err error) {
+	rules = make(map[match.Pair]*match.PairRules)
+
+	s.dbLock.Lock()
+	for pair := range s.MatchingEngines {
+		rules[pair] = new(match.PairRules)
+		if pairRules, ok := s.PairRules[pair]; ok {
+			*rules[pair] = *pairRules
+		}
+	}
+	s.dbLock.Unlock()
+	return
+}
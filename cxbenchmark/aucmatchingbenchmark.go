@@ -26,22 +26,22 @@ func AuctionPlaceAndFill(client1 *benchclient.BenchClient, client2 *benchclient.
 		if err != nil {
 			logging.Errorf("Error placing and filling auction: %s", err)
 		}
-		go client1.AuctionOrderAsync(client1.PrivKey.PubKey(), "buy", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client1.AuctionOrderAsync(client1.PrivKey.PubKey(), "buy", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 		publicParams, err = client2.GetPublicParameters(pairParam)
 		if err != nil {
 			logging.Errorf("Error placing and filling auction: %s", err)
 		}
-		go client2.AuctionOrderAsync(client2.PrivKey.PubKey(), "sell", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client2.AuctionOrderAsync(client2.PrivKey.PubKey(), "sell", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 		publicParams, err = client1.GetPublicParameters(pairParam)
 		if err != nil {
 			logging.Errorf("Error placing and filling auction: %s", err)
 		}
-		go client1.AuctionOrderAsync(client1.PrivKey.PubKey(), "sell", pair, 2000, 2.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client1.AuctionOrderAsync(client1.PrivKey.PubKey(), "sell", pair, 2000, 2.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 		publicParams, err = client2.GetPublicParameters(pairParam)
 		if err != nil {
 			logging.Errorf("Error placing and filling auction: %s", err)
 		}
-		go client2.AuctionOrderAsync(client2.PrivKey.PubKey(), "buy", pair, 1000, 2.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client2.AuctionOrderAsync(client2.PrivKey.PubKey(), "buy", pair, 1000, 2.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 
 		for i := 0; i < cap(bufErrChan); i++ {
 			select {
@@ -79,7 +79,7 @@ func AuctionPlaceManyBuy(client *benchclient.BenchClient, pair string, howMany i
 		if err != nil {
 			logging.Errorf("Error placing many buy auction: %s", err)
 		}
-		go client.AuctionOrderAsync(client.PrivKey.PubKey(), "buy", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client.AuctionOrderAsync(client.PrivKey.PubKey(), "buy", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 	}
 
 	for i := 0; i < cap(bufErrChan); i++ {
@@ -115,7 +115,7 @@ func AuctionPlaceManySell(client *benchclient.BenchClient, pair string, howMany
 		if err != nil {
 			logging.Errorf("Error placing many sell auction: %s", err)
 		}
-		go client.AuctionOrderAsync(client.PrivKey.PubKey(), "sell", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, orderChan, bufErrChan)
+		go client.AuctionOrderAsync(client.PrivKey.PubKey(), "sell", pair, 1000, 1.0, publicParams.AuctionTime, publicParams.AuctionID, publicParams.ExchangePubkey, orderChan, bufErrChan)
 	}
 
 	for i := 0; i < cap(bufErrChan); i++ {
@@ -0,0 +1,91 @@
+package cxbenchmark
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+)
+
+// disjointPairCoins are the coins used to make pairs that don't share any assets, so orders on different
+// pairs never wait on each other's locks
+var disjointPairCoins = []*coinparam.Params{
+	&coinparam.BitcoinParams,
+	&coinparam.VertcoinParams,
+	&coinparam.TestNet3Params,
+	&coinparam.VertcoinTestNetParams,
+	&coinparam.LiteCoinTestNet4Params,
+	&coinparam.RegressionNetParams,
+	&coinparam.VertcoinRegTestParams,
+	&coinparam.LiteRegNetParams,
+}
+
+// disjointPairs returns up to howMany pairs that don't share any assets
+func disjointPairs(howMany int) (pairs []*match.Pair, err error) {
+	if howMany*2 > len(disjointPairCoins) {
+		err = fmt.Errorf("Can only make %d disjoint pairs, not %d", len(disjointPairCoins)/2, howMany)
+		return
+	}
+
+	for i := 0; i < howMany; i++ {
+		pair := new(match.Pair)
+		if pair.AssetWant, err = match.AssetFromCoinParam(disjointPairCoins[2*i]); err != nil {
+			err = fmt.Errorf("Error getting asset want for disjointPairs: %s", err)
+			return
+		}
+		if pair.AssetHave, err = match.AssetFromCoinParam(disjointPairCoins[2*i+1]); err != nil {
+			err = fmt.Errorf("Error getting asset have for disjointPairs: %s", err)
+			return
+		}
+		pairs = append(pairs, pair)
+	}
+	return
+}
+
+// fundUsers gives every user the amount in every coin the server supports
+func fundUsers(server *cxserver.OpencxServer, pubkeys []*koblitz.PublicKey, amount uint64) (err error) {
+	for _, pubkey := range pubkeys {
+		for _, coin := range disjointPairCoins {
+			if err = server.DebitUser(pubkey, amount, coin); err != nil {
+				err = fmt.Errorf("Error funding user for fundUsers: %s", err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// PlaceCrossingOrders places howMany pairs of buy and sell orders on the pair directly on the server,
+// each of which matches with the one before it.
+func PlaceCrossingOrders(server *cxserver.OpencxServer, pair *match.Pair, buyer *koblitz.PublicKey, seller *koblitz.PublicKey, howMany int) (err error) {
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  1000,
+		AmountWant:  1000,
+	}
+	copy(buyOrder.Pubkey[:], buyer.SerializeCompressed())
+
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  1000,
+		AmountWant:  1000,
+	}
+	copy(sellOrder.Pubkey[:], seller.SerializeCompressed())
+
+	for i := 0; i < howMany; i++ {
+		if _, err = server.PlaceOrder(buyOrder); err != nil {
+			err = fmt.Errorf("Error placing buy order for PlaceCrossingOrders: %s", err)
+			return
+		}
+
+		if _, err = server.PlaceOrder(sellOrder); err != nil {
+			err = fmt.Errorf("Error placing sell order for PlaceCrossingOrders: %s", err)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package cxbenchmark
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+)
+
+// BenchmarkPlaceOrdersPairs places crossing orders on 1, 2, and 4 pairs that don't share assets at the
+// same time. Each pair only takes its own locks, so the time per order should go down as pairs are added.
+func BenchmarkPlaceOrdersPairs(b *testing.B) {
+	for _, numPairs := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("%dpairs", numPairs), func(b *testing.B) {
+			benchmarkPlaceOrdersPairs(b, numPairs)
+		})
+	}
+}
+
+func benchmarkPlaceOrdersPairs(b *testing.B, numPairs int) {
+	var err error
+
+	var server *cxserver.OpencxServer
+	if server, err = createMemoryServer(disjointPairCoins); err != nil {
+		b.Fatalf("Error creating memory server: %s", err)
+	}
+
+	var pairs []*match.Pair
+	if pairs, err = disjointPairs(numPairs); err != nil {
+		b.Fatalf("Error creating pairs: %s", err)
+	}
+
+	var buyerPriv *koblitz.PrivateKey
+	if buyerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		b.Fatalf("Error creating buyer key: %s", err)
+	}
+
+	var sellerPriv *koblitz.PrivateKey
+	if sellerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		b.Fatalf("Error creating seller key: %s", err)
+	}
+
+	// Settlements from matching can credit either asset, so both users get a lot of both
+	if err = fundUsers(server, []*koblitz.PublicKey{buyerPriv.PubKey(), sellerPriv.PubKey()}, 1<<50); err != nil {
+		b.Fatalf("Error funding users: %s", err)
+	}
+
+	// b.N is the number of buy and sell order pairs, split between the trading pairs
+	perPair := b.N / numPairs
+	if perPair == 0 {
+		perPair = 1
+	}
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	errChan := make(chan error, numPairs)
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func(pair *match.Pair) {
+			defer wg.Done()
+			errChan <- PlaceCrossingOrders(server, pair, buyerPriv.PubKey(), sellerPriv.PubKey(), perPair)
+		}(pair)
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	close(errChan)
+	for err = range errChan {
+		if err != nil {
+			b.Fatalf("Error placing orders: %s", err)
+		}
+	}
+	return
+}
@@ -63,6 +63,25 @@ func createLightSettleServer(coinList []*coinparam.Params, whitelist []*koblitz.
 		return
 	}
 
+	// There is no SQL stop order store, so stop orders are kept in memory
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating stop order store map for createFullServer: %s", err)
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbsql.CreateTradeStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating trade store map for createFullServer: %s", err)
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for createFullServer: %s", err)
+		return
+	}
+
 	var depositStores map[*coinparam.Params]cxdb.DepositStore
 	if depositStores, err = cxdbsql.CreateDepositStoreMap(coinList); err != nil {
 		err = fmt.Errorf("Error creating deposit store map for createFullServer: %s", err)
@@ -77,7 +96,7 @@ func createLightSettleServer(coinList []*coinparam.Params, whitelist []*koblitz.
 
 	// TODO: change this root directory nonsense!!!
 	var ocxServer *cxserver.OpencxServer
-	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, depositStores, setStores, ".benchmarkInfo/"); err != nil {
+	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ".benchmarkInfo/"); err != nil {
 		err = fmt.Errorf("Error initializing server for createFullServer: %s", err)
 		return
 	}
@@ -194,13 +213,24 @@ func createLightAuctionServer(coinList []*coinparam.Params, whitelist []*koblitz
 		return
 	}
 
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for createLightAuctionServer: %s", err)
+		return
+	}
+
 	// orderChanSize = 100 because uh why not?
 	var ocxServer *cxauctionserver.OpencxAuctionServer
-	if ocxServer, err = cxauctionserver.InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, 100, auctionTime); err != nil {
+	if ocxServer, err = cxauctionserver.InitServer(setEngines, mengines, aucBooks, pzEngines, batchers, candleStores, 100, auctionTime); err != nil {
 		err = fmt.Errorf("Error initializing server for createLightAuctionServer: %s", err)
 		return
 	}
 
+	if err = ocxServer.SetExchangePubkey(privkey.PubKey()); err != nil {
+		err = fmt.Errorf("Error setting exchange pubkey for auction server: %s", err)
+		return
+	}
+
 	if err = ocxServer.StartClockRandomAuction(); err != nil {
 		err = fmt.Errorf("Error starting clock: %s", err)
 		return
@@ -269,6 +299,25 @@ func createFullServer(coinList []*coinparam.Params, serverhost string, serverpor
 		return
 	}
 
+	// There is no SQL stop order store, so stop orders are kept in memory
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating stop order store map for createFullServer: %s", err)
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbsql.CreateTradeStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating trade store map for createFullServer: %s", err)
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbsql.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for createFullServer: %s", err)
+		return
+	}
+
 	var depositStores map[*coinparam.Params]cxdb.DepositStore
 	if depositStores, err = cxdbsql.CreateDepositStoreMap(coinList); err != nil {
 		err = fmt.Errorf("Error creating deposit store map for createFullServer: %s", err)
@@ -283,7 +332,7 @@ func createFullServer(coinList []*coinparam.Params, serverhost string, serverpor
 
 	// TODO: get rid of this directory nonsense, just figure out a nice way to deal with these things
 	var ocxServer *cxserver.OpencxServer
-	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, depositStores, setStores, ".benchmarkInfo/"); err != nil {
+	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ".benchmarkInfo/"); err != nil {
 		err = fmt.Errorf("Error initializing server for createFullServer: %s", err)
 		return
 	}
@@ -374,6 +423,11 @@ func createFullAuctionServer(coinList []*coinparam.Params, maxBatchSize uint64,
 		return
 	}
 
+	if err = ocxServer.SetExchangePubkey(privkey.PubKey()); err != nil {
+		err = fmt.Errorf("Error setting exchange pubkey for auction server: %s", err)
+		return
+	}
+
 	if err = ocxServer.StartClockRandomAuction(); err != nil {
 		err = fmt.Errorf("Error starting clock: %s", err)
 		return
@@ -423,3 +477,66 @@ func createDefaultLightServerWithKey(privkey *koblitz.PrivateKey, whitelist []*k
 func createDefaultLightAuctionServerWithKey(privkey *koblitz.PrivateKey, whitelist []*koblitz.PublicKey, authrpc bool) (rpcListener *cxauctionrpc.AuctionRPCCaller, err error) {
 	return createLightAuctionServer([]*coinparam.Params{&coinparam.RegressionNetParams, newVTC, &coinparam.LiteRegNetParams}, whitelist, 100, 7000000, "localhost", uint16(12347), privkey, authrpc)
 }
+
+// createMemoryServer creates a server with everything in memory and no wallets or RPC, so it can be called directly
+func createMemoryServer(coinList []*coinparam.Params) (ocxServer *cxserver.OpencxServer, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		err = fmt.Errorf("Could not generate asset pairs from coin list: %s", err)
+		return
+	}
+
+	var mengines map[match.Pair]match.LimitEngine
+	if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating limit engine map with coinlist for createMemoryServer: %s", err)
+		return
+	}
+
+	var setEngines map[*coinparam.Params]match.SettlementEngine
+	if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating settlement engine map for createMemoryServer: %s", err)
+		return
+	}
+
+	var limBooks map[match.Pair]match.LimitOrderbook
+	if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating limit orderbook map for createMemoryServer: %s", err)
+		return
+	}
+
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating stop order store map for createMemoryServer: %s", err)
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating trade store map for createMemoryServer: %s", err)
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		err = fmt.Errorf("Error creating candle store map for createMemoryServer: %s", err)
+		return
+	}
+
+	var depositStores map[*coinparam.Params]cxdb.DepositStore
+	if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating deposit store map for createMemoryServer: %s", err)
+		return
+	}
+
+	var setStores map[*coinparam.Params]cxdb.SettlementStore
+	if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+		err = fmt.Errorf("Error creating settlement store map for createMemoryServer: %s", err)
+		return
+	}
+
+	if ocxServer, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ".benchmarkInfo/"); err != nil {
+		err = fmt.Errorf("Error initializing server for createMemoryServer: %s", err)
+		return
+	}
+	return
+}
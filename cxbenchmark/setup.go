@@ -8,6 +8,7 @@ import (
 	"github.com/mit-dci/opencx/benchclient"
 	"github.com/mit-dci/opencx/cxauctionrpc"
 	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/match"
 )
 
 // Let these be turned into config things at some point
@@ -71,18 +72,18 @@ func signBytes(client *benchclient.BenchClient, bytes []byte) (signature []byte,
 
 func registerClient(client *benchclient.BenchClient) (err error) {
 	// Register the clients
-	var regStringReply *cxrpc.GetRegistrationStringReply
-	if regStringReply, err = client.GetRegistrationString(); err != nil {
+	var challengeReply *cxrpc.GetChallengeReply
+	if challengeReply, err = client.GetChallenge(match.RegisterChallenge); err != nil {
 		return
 	}
 
 	var sig []byte
-	if sig, err = signBytes(client, []byte(regStringReply.RegistrationString)); err != nil {
+	if sig, err = signBytes(client, challengeReply.Challenge.Serialize()); err != nil {
 		return
 	}
 
 	// we don't really care about the reply
-	if _, err = client.Register(sig); err != nil {
+	if _, err = client.Register(challengeReply.Challenge.Nonce, sig); err != nil {
 		return
 	}
 
@@ -1,6 +1,8 @@
 package cxdb
 
 import (
+	"time"
+
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/match"
 )
@@ -24,6 +26,75 @@ type DepositStore interface {
 	GetDepositAddress(pubkey *koblitz.PublicKey) (addr string, err error)
 }
 
+// StopOrderStore holds the stop orders for a pair until they are triggered or cancelled
+type StopOrderStore interface {
+	// AddStopOrder adds a stop order to the store
+	AddStopOrder(stop *match.StopOrderIDPair) (err error)
+	// RemoveStopOrder removes a stop order from the store, once it's triggered or cancelled
+	RemoveStopOrder(id *match.OrderID) (err error)
+	// GetStopOrder gets a stop order by ID
+	GetStopOrder(id *match.OrderID) (stop *match.StopOrderIDPair, err error)
+	// GetStopOrdersForPubkey gets every stop order placed by a pubkey
+	GetStopOrdersForPubkey(pubkey *koblitz.PublicKey) (stops []*match.StopOrderIDPair, err error)
+	// GetTriggeredStopOrders gets every stop order that is triggered by the last trade price, in the order
+	// they were placed
+	GetTriggeredStopOrders(lastPrice *match.Price) (triggered []*match.StopOrderIDPair, err error)
+	// ViewStopOrders gets every stop order in the store, in the order they were placed
+	ViewStopOrders() (stops []*match.StopOrderIDPair, err error)
+}
+
+// TradeStore keeps the trades made on a pair, so users can see what traded
+type TradeStore interface {
+	// AddTrades adds trades to the store, in the order they were made
+	AddTrades(trades []*match.Trade) (err error)
+	// GetRecentTrades gets the latest trades, newest first, and at most limit of them
+	GetRecentTrades(limit uint64) (trades []*match.Trade, err error)
+	// GetTradesForPubkey gets the trades that an order placed by a pubkey was in, newest first, and at
+	// most limit of them. If before isn't zero, only trades made at or before then are returned.
+	GetTradesForPubkey(pubkey *koblitz.PublicKey, before time.Time, limit uint64) (trades []*match.Trade, err error)
+}
+
+// CandleStore keeps the executions on a pair and the candles made from them, for every interval in
+// match.CandleIntervals
+type CandleStore interface {
+	// AddExecutions adds executions to the store, in the order they were made, and updates the candles
+	// they are in. Executions that are already in the store are ignored.
+	AddExecutions(execs []*match.PriceExecution) (err error)
+	// GetCandles gets the candles for an interval that start at or after start and before end, oldest
+	// first. If end is zero there is no end.
+	GetCandles(interval match.CandleInterval, start time.Time, end time.Time) (candles []*match.Candle, err error)
+}
+
+// NonceUse is a nonce that a pubkey used in an order envelope, and the unix time the envelope expires at
+type NonceUse struct {
+	Pubkey [33]byte
+	Nonce  uint64
+	Expiry uint64
+}
+
+// NonceStore keeps the nonces each pubkey has used in order envelopes, so an envelope can't be used to place
+// an order more than once. Nonces only have to be kept until their envelopes expire, since expired envelopes
+// aren't accepted.
+type NonceStore interface {
+	// UseNonce adds a nonce to the store, returning an error if the pubkey has already used it
+	UseNonce(use *NonceUse) (err error)
+	// NonceUsed returns true if the pubkey has used the nonce, and it hasn't been pruned
+	NonceUsed(pubkey [33]byte, nonce uint64) (used bool, err error)
+	// PruneNonces removes the nonces for envelopes that expired at or before the unix time before
+	PruneNonces(before uint64) (err error)
+}
+
+// Journal is a durable, append-only log of the changes the server makes to the settlement engines, matching
+// engines and orderbooks. Entries are written before they are applied, so the state can be rebuilt if the
+// server crashes in the middle of applying one.
+type Journal interface {
+	// AppendEntry durably writes an entry to the end of the journal, setting its sequence number and the
+	// hash of the entry before it.
+	AppendEntry(entry *JournalEntry) (err error)
+	// Entries returns every entry in the journal, in the order they were appended.
+	Entries() (entries []*JournalEntry, err error)
+}
+
 // PuzzleStore is an interface for defining a storage layer for auction order puzzles.
 type PuzzleStore interface {
 	// ViewAuctionPuzzleBook takes in an auction ID, and returns encrypted auction orders, and puzzles.
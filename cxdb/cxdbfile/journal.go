@@ -0,0 +1,259 @@
+package cxdbfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/logging"
+)
+
+// Each record in the journal file is the length of the entry, then a checksum of the entry, then the entry
+const recordHeaderLen = 8
+
+// journalFile is what the journal needs from its file, which is an *os.File everywhere but tests
+type journalFile interface {
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// FileJournal is a journal that is kept in an append-only file. Every entry is synced to disk before
+// AppendEntry returns.
+type FileJournal struct {
+	file    journalFile
+	fileMtx *sync.Mutex
+
+	// failed is set if an append failed and the file couldn't be put back the way it was, so nothing
+	// else can be appended after it
+	failed error
+
+	// the sequence number of the next entry
+	nextSequence uint64
+	// the hash of the last entry, which the next entry will commit to
+	lastHash [32]byte
+}
+
+// CreateJournal opens the journal file at the path, or creates it if it doesn't exist.
+// If the last record in the file was only partially written, which can happen if we crash while
+// appending, it is removed. That entry was never applied, since entries are written before they
+// are applied. A record before the last one that doesn't match its checksum is corruption rather than
+// a partial write, so it is an error, and nothing is removed.
+func CreateJournal(path string) (journal cxdb.Journal, err error) {
+	var file *os.File
+	if file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600); err != nil {
+		err = fmt.Errorf("Error opening journal file for CreateJournal: %s", err)
+		return
+	}
+
+	var records [][]byte
+	var goodLen int64
+	if records, goodLen, err = readRecords(file); err != nil {
+		file.Close()
+		err = fmt.Errorf("Error reading journal file for CreateJournal: %s", err)
+		return
+	}
+
+	var info os.FileInfo
+	if info, err = file.Stat(); err != nil {
+		file.Close()
+		err = fmt.Errorf("Error getting journal file info for CreateJournal: %s", err)
+		return
+	}
+
+	if info.Size() != goodLen {
+		logging.Warnf("Journal %s has a partially written record at the end, removing it", path)
+		if err = file.Truncate(goodLen); err != nil {
+			file.Close()
+			err = fmt.Errorf("Error truncating partial record for CreateJournal: %s", err)
+			return
+		}
+	}
+
+	if _, err = file.Seek(goodLen, io.SeekStart); err != nil {
+		file.Close()
+		err = fmt.Errorf("Error seeking to end of journal for CreateJournal: %s", err)
+		return
+	}
+
+	// Set values
+	fj := &FileJournal{
+		file:         file,
+		fileMtx:      new(sync.Mutex),
+		nextSequence: uint64(len(records)),
+	}
+
+	// The next entry commits to the last one in the file
+	if len(records) > 0 {
+		lastEntry := new(cxdb.JournalEntry)
+		if err = lastEntry.Deserialize(records[len(records)-1]); err != nil {
+			file.Close()
+			err = fmt.Errorf("Error deserializing last entry for CreateJournal: %s", err)
+			return
+		}
+
+		if fj.lastHash, err = lastEntry.Hash(); err != nil {
+			file.Close()
+			err = fmt.Errorf("Error hashing last entry for CreateJournal: %s", err)
+			return
+		}
+	}
+
+	// Now we actually set the journal
+	journal = fj
+	return
+}
+
+// AppendEntry durably writes an entry to the end of the journal, setting its sequence number and previous hash.
+// If the entry can't be written and synced, the file is truncated back to where it was, so the entry isn't
+// in the journal and the next one takes its place.
+func (fj *FileJournal) AppendEntry(entry *cxdb.JournalEntry) (err error) {
+	if entry == nil {
+		err = fmt.Errorf("Cannot append nil entry to journal, please enter valid input")
+		return
+	}
+
+	fj.fileMtx.Lock()
+	defer fj.fileMtx.Unlock()
+
+	if fj.failed != nil {
+		err = fmt.Errorf("Cannot append to journal after it failed to remove a partial entry: %s", fj.failed)
+		return
+	}
+
+	entry.Sequence = fj.nextSequence
+	entry.PrevHash = fj.lastHash
+	var entryBytes []byte
+	if entryBytes, err = entry.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing entry for AppendEntry: %s", err)
+		return
+	}
+
+	var entryHash [32]byte
+	if entryHash, err = entry.Hash(); err != nil {
+		err = fmt.Errorf("Error hashing entry for AppendEntry: %s", err)
+		return
+	}
+
+	record := make([]byte, recordHeaderLen+len(entryBytes))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(entryBytes)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(entryBytes))
+	copy(record[recordHeaderLen:], entryBytes)
+
+	var offset int64
+	if offset, err = fj.file.Seek(0, io.SeekCurrent); err != nil {
+		err = fmt.Errorf("Error getting journal offset for AppendEntry: %s", err)
+		return
+	}
+
+	if _, err = fj.file.Write(record); err != nil {
+		err = fmt.Errorf("Error writing entry to journal for AppendEntry: %s", err)
+		fj.rewind(offset)
+		return
+	}
+
+	if err = fj.file.Sync(); err != nil {
+		err = fmt.Errorf("Error syncing journal for AppendEntry: %s", err)
+		fj.rewind(offset)
+		return
+	}
+
+	fj.nextSequence++
+	fj.lastHash = entryHash
+	return
+}
+
+// rewind removes everything after offset from the file, so an entry that failed to be appended is gone
+// before the next one is written. If that doesn't work the journal is marked as failed.
+func (fj *FileJournal) rewind(offset int64) {
+	var err error
+	if err = fj.file.Truncate(offset); err != nil {
+		fj.failed = fmt.Errorf("Error truncating journal to %d: %s", offset, err)
+		logging.Errorf("%s", fj.failed)
+		return
+	}
+
+	if _, err = fj.file.Seek(offset, io.SeekStart); err != nil {
+		fj.failed = fmt.Errorf("Error seeking journal to %d: %s", offset, err)
+		logging.Errorf("%s", fj.failed)
+		return
+	}
+	return
+}
+
+// Entries returns every entry in the journal, in the order they were appended.
+func (fj *FileJournal) Entries() (entries []*cxdb.JournalEntry, err error) {
+	fj.fileMtx.Lock()
+	defer fj.fileMtx.Unlock()
+
+	var records [][]byte
+	if records, _, err = readRecords(fj.file); err != nil {
+		err = fmt.Errorf("Error reading journal records for Entries: %s", err)
+		return
+	}
+
+	for _, record := range records {
+		entry := new(cxdb.JournalEntry)
+		if err = entry.Deserialize(record); err != nil {
+			err = fmt.Errorf("Error deserializing entry %d for Entries: %s", len(entries), err)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// Close closes the journal file
+func (fj *FileJournal) Close() (err error) {
+	fj.fileMtx.Lock()
+	defer fj.fileMtx.Unlock()
+	if err = fj.file.Close(); err != nil {
+		err = fmt.Errorf("Error closing journal file: %s", err)
+		return
+	}
+	return
+}
+
+// readRecords reads every complete record in the file, and returns the records and the length of the file
+// that they take up. Only the last record can have been partially written, so reading stops there if it is
+// incomplete or doesn't match its checksum. A record that doesn't match its checksum and has more records
+// after it is an error.
+// This does not change the offset that the file is written at.
+func readRecords(file io.ReaderAt) (records [][]byte, goodLen int64, err error) {
+	var contents []byte
+	if contents, err = ioutil.ReadAll(io.NewSectionReader(file, 0, 1<<62)); err != nil {
+		err = fmt.Errorf("Error reading journal file: %s", err)
+		return
+	}
+
+	for int64(len(contents))-goodLen >= recordHeaderLen {
+		header := contents[goodLen : goodLen+recordHeaderLen]
+		entryLen := int64(binary.BigEndian.Uint32(header[0:4]))
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		if int64(len(contents))-goodLen-recordHeaderLen < entryLen {
+			return
+		}
+
+		recordEnd := goodLen + recordHeaderLen + entryLen
+		entryBytes := contents[goodLen+recordHeaderLen : recordEnd]
+		if crc32.ChecksumIEEE(entryBytes) != checksum {
+			if recordEnd != int64(len(contents)) {
+				err = fmt.Errorf("Record %d at offset %d does not match its checksum and is not the last record, the journal is corrupt", len(records), goodLen)
+				return
+			}
+			return
+		}
+
+		records = append(records, entryBytes)
+		goodLen += recordHeaderLen + entryLen
+	}
+	return
+}
@@ -0,0 +1,355 @@
+package cxdbfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestJournalPartialRecord makes sure that entries survive reopening the journal, and that a record
+// that was only partially written is removed.
+func TestJournalPartialRecord(t *testing.T) {
+	var err error
+
+	var dir string
+	if dir, err = ioutil.TempDir("", "journaltest"); err != nil {
+		t.Errorf("Error creating temp dir for TestJournalPartialRecord: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.dat")
+
+	var journal cxdb.Journal
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error creating journal for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	entry := &cxdb.JournalEntry{
+		Type: cxdb.JournalSettle,
+		SettlementExecs: []*match.SettlementExecution{
+			&match.SettlementExecution{
+				Amount: 1000,
+				Asset:  match.BTC,
+				Type:   match.Debit,
+			},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err = journal.AppendEntry(entry); err != nil {
+			t.Errorf("Error appending entry for TestJournalPartialRecord: %s", err)
+			return
+		}
+	}
+
+	if err = journal.(*FileJournal).Close(); err != nil {
+		t.Errorf("Error closing journal for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	// Pretend we crashed while writing the second record
+	var info os.FileInfo
+	if info, err = os.Stat(path); err != nil {
+		t.Errorf("Error getting journal info for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	if err = os.Truncate(path, info.Size()-3); err != nil {
+		t.Errorf("Error truncating journal for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error reopening journal for TestJournalPartialRecord: %s", err)
+		return
+	}
+	defer journal.(*FileJournal).Close()
+
+	if err = journal.AppendEntry(entry); err != nil {
+		t.Errorf("Error appending entry after reopening for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	var entries []*cxdb.JournalEntry
+	if entries, err = journal.Entries(); err != nil {
+		t.Errorf("Error getting entries for TestJournalPartialRecord: %s", err)
+		return
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("Journal should have had 2 entries but had %d", len(entries))
+		return
+	}
+
+	for i, gotEntry := range entries {
+		if gotEntry.Sequence != uint64(i) {
+			t.Errorf("Entry %d should have had sequence %d but had %d", i, i, gotEntry.Sequence)
+			return
+		}
+
+		if len(gotEntry.SettlementExecs) != 1 || gotEntry.SettlementExecs[0].Amount != 1000 || gotEntry.SettlementExecs[0].Type != match.Debit {
+			t.Errorf("Entry %d did not have the settlement exec that was appended", i)
+			return
+		}
+	}
+
+	// The entry appended after reopening should still commit to the entries before it
+	if _, err = cxdb.VerifyJournal(entries); err != nil {
+		t.Errorf("Journal should have verified after reopening: %s", err)
+		return
+	}
+
+	// Changing an entry before the head should break the chain
+	entries[0].SettlementExecs[0].Amount = 2000
+	if _, err = cxdb.VerifyJournal(entries); err == nil {
+		t.Errorf("Journal with a modified entry should not have verified")
+		return
+	}
+
+	return
+}
+
+// TestJournalCorruptRecord makes sure that a record that doesn't match its checksum is only removed if it's
+// the last record, and is an error if there are records after it.
+func TestJournalCorruptRecord(t *testing.T) {
+	var err error
+
+	var dir string
+	if dir, err = ioutil.TempDir("", "journaltest"); err != nil {
+		t.Errorf("Error creating temp dir for TestJournalCorruptRecord: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.dat")
+
+	var journal cxdb.Journal
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error creating journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	entry := &cxdb.JournalEntry{
+		Type: cxdb.JournalSettle,
+		SettlementExecs: []*match.SettlementExecution{
+			&match.SettlementExecution{
+				Amount: 1000,
+				Asset:  match.BTC,
+				Type:   match.Debit,
+			},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if err = journal.AppendEntry(entry); err != nil {
+			t.Errorf("Error appending entry for TestJournalCorruptRecord: %s", err)
+			return
+		}
+	}
+
+	if err = journal.(*FileJournal).Close(); err != nil {
+		t.Errorf("Error closing journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	var contents []byte
+	if contents, err = ioutil.ReadFile(path); err != nil {
+		t.Errorf("Error reading journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	// A bad checksum on the last record is a partial write, so it's removed
+	lastCorrupt := append([]byte{}, contents...)
+	lastCorrupt[len(lastCorrupt)-1] ^= 0xff
+	if err = ioutil.WriteFile(path, lastCorrupt, 0600); err != nil {
+		t.Errorf("Error writing journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error reopening journal with corrupt last record for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	var entries []*cxdb.JournalEntry
+	if entries, err = journal.Entries(); err != nil {
+		t.Errorf("Error getting entries for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	if err = journal.(*FileJournal).Close(); err != nil {
+		t.Errorf("Error closing journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("Journal should have had 2 entries after removing the last record but had %d", len(entries))
+		return
+	}
+
+	// A bad checksum on a record in the middle is corruption, so the journal can't be opened and the
+	// records after it are kept
+	firstRecordLen := recordHeaderLen + int(binary.BigEndian.Uint32(contents[0:4]))
+	middleCorrupt := append([]byte{}, contents...)
+	middleCorrupt[firstRecordLen-1] ^= 0xff
+	if err = ioutil.WriteFile(path, middleCorrupt, 0600); err != nil {
+		t.Errorf("Error writing journal for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	if journal, err = CreateJournal(path); err == nil {
+		journal.(*FileJournal).Close()
+		t.Errorf("Journal with a corrupt record in the middle should not have opened")
+		return
+	}
+
+	var info os.FileInfo
+	if info, err = os.Stat(path); err != nil {
+		t.Errorf("Error getting journal info for TestJournalCorruptRecord: %s", err)
+		return
+	}
+
+	if info.Size() != int64(len(contents)) {
+		t.Errorf("Journal with a corrupt record in the middle should not have been truncated")
+		return
+	}
+
+	return
+}
+
+// failingFile is a journal file that can fail to write, sync, or truncate. A failed write still writes half
+// of what it was given, like a write that was cut off.
+type failingFile struct {
+	*os.File
+	failWrite    bool
+	failSync     bool
+	failTruncate bool
+}
+
+func (ff *failingFile) Write(p []byte) (n int, err error) {
+	if ff.failWrite {
+		if n, err = ff.File.Write(p[:len(p)/2]); err != nil {
+			return
+		}
+		err = fmt.Errorf("write failed")
+		return
+	}
+	return ff.File.Write(p)
+}
+
+func (ff *failingFile) Sync() (err error) {
+	if ff.failSync {
+		err = fmt.Errorf("sync failed")
+		return
+	}
+	return ff.File.Sync()
+}
+
+func (ff *failingFile) Truncate(size int64) (err error) {
+	if ff.failTruncate {
+		err = fmt.Errorf("truncate failed")
+		return
+	}
+	return ff.File.Truncate(size)
+}
+
+// TestJournalFailedAppend makes sure that an entry that fails to be written or synced is removed, so the
+// next entry is appended in its place and the journal still opens and verifies. If it can't be removed,
+// nothing else can be appended.
+func TestJournalFailedAppend(t *testing.T) {
+	var err error
+
+	var dir string
+	if dir, err = ioutil.TempDir("", "journaltest"); err != nil {
+		t.Errorf("Error creating temp dir for TestJournalFailedAppend: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.dat")
+
+	var journal cxdb.Journal
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error creating journal for TestJournalFailedAppend: %s", err)
+		return
+	}
+
+	entry := &cxdb.JournalEntry{
+		Type: cxdb.JournalSettle,
+		SettlementExecs: []*match.SettlementExecution{
+			&match.SettlementExecution{
+				Amount: 1000,
+				Asset:  match.BTC,
+				Type:   match.Debit,
+			},
+		},
+	}
+	if err = journal.AppendEntry(entry); err != nil {
+		t.Errorf("Error appending entry for TestJournalFailedAppend: %s", err)
+		return
+	}
+
+	fj := journal.(*FileJournal)
+	file := fj.file.(*os.File)
+	for _, failing := range []*failingFile{{File: file, failWrite: true}, {File: file, failSync: true}} {
+		fj.file = failing
+		if err = journal.AppendEntry(entry); err == nil {
+			t.Errorf("Appending to a failing file should have failed for TestJournalFailedAppend")
+			return
+		}
+
+		fj.file = file
+		if err = journal.AppendEntry(entry); err != nil {
+			t.Errorf("Error appending entry after a failed append for TestJournalFailedAppend: %s", err)
+			return
+		}
+	}
+
+	if err = fj.Close(); err != nil {
+		t.Errorf("Error closing journal for TestJournalFailedAppend: %s", err)
+		return
+	}
+
+	if journal, err = CreateJournal(path); err != nil {
+		t.Errorf("Error reopening journal for TestJournalFailedAppend: %s", err)
+		return
+	}
+
+	var entries []*cxdb.JournalEntry
+	if entries, err = journal.Entries(); err != nil {
+		t.Errorf("Error getting entries for TestJournalFailedAppend: %s", err)
+		return
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("Journal should have had 3 entries but had %d", len(entries))
+		return
+	}
+
+	if _, err = cxdb.VerifyJournal(entries); err != nil {
+		t.Errorf("Journal should have verified after failed appends: %s", err)
+		return
+	}
+
+	// If the partial entry can't be removed, the journal won't take any more entries
+	fj = journal.(*FileJournal)
+	file = fj.file.(*os.File)
+	defer fj.Close()
+
+	fj.file = &failingFile{File: file, failWrite: true, failTruncate: true}
+	if err = journal.AppendEntry(entry); err == nil {
+		t.Errorf("Appending to a failing file should have failed for TestJournalFailedAppend")
+		return
+	}
+
+	fj.file = file
+	if err = journal.AppendEntry(entry); err == nil {
+		t.Errorf("Appending after the journal failed to remove a partial entry should have failed")
+		return
+	}
+
+	return
+}
@@ -10,7 +10,7 @@ import (
 )
 
 type MemoryAuctionEngine struct {
-	orders     map[match.AuctionID]map[float64][]*match.AuctionOrderIDPair
+	orders     map[match.AuctionID]map[match.Price][]*match.AuctionOrderIDPair
 	auctionMtx *sync.Mutex
 	pair       *match.Pair
 }
@@ -24,7 +24,7 @@ func (me *MemoryAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auct
 	idCopy := *auctionID
 
 	// First get the price of the order, if this errors then that's really bad
-	var pr float64
+	var pr *match.Price
 	if pr, err = order.Price(); err != nil {
 		err = fmt.Errorf("Critical error when placing order for matching engine: %s", err)
 		me.auctionMtx.Unlock()
@@ -39,6 +39,7 @@ func (me *MemoryAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auct
 
 	idRes = &match.AuctionOrderIDPair{
 		OrderID: id,
+		Price:   *pr,
 		Order:   order,
 	}
 
@@ -48,8 +49,8 @@ func (me *MemoryAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auct
 	if _, ok = me.orders[idCopy]; !ok {
 
 		// Since we assume the order is valid, place it in the auction
-		me.orders[idCopy] = map[float64][]*match.AuctionOrderIDPair{
-			pr: []*match.AuctionOrderIDPair{
+		me.orders[idCopy] = map[match.Price][]*match.AuctionOrderIDPair{
+			*pr: []*match.AuctionOrderIDPair{
 				idRes,
 			},
 		}
@@ -57,15 +58,15 @@ func (me *MemoryAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auct
 	}
 
 	// if the map for the auction is there but the price index isn't, create it
-	if _, ok = me.orders[idCopy][pr]; !ok {
-		me.orders[idCopy][pr] = []*match.AuctionOrderIDPair{
+	if _, ok = me.orders[idCopy][*pr]; !ok {
+		me.orders[idCopy][*pr] = []*match.AuctionOrderIDPair{
 			idRes,
 		}
 		return
 	}
 
 	// if both are fine then awesome
-	me.orders[idCopy][pr] = append(me.orders[idCopy][pr], idRes)
+	me.orders[idCopy][*pr] = append(me.orders[idCopy][*pr], idRes)
 
 	me.auctionMtx.Unlock()
 	return
@@ -2,15 +2,17 @@ package cxdbmemory
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/mit-dci/lit/crypto/koblitz"
-	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 )
 
-// MemoryAuctionOrderbook is the representation of a auction orderbook for SQL
+// MemoryAuctionOrderbook is the representation of a auction orderbook that is kept in memory
 type MemoryAuctionOrderbook struct {
-	// TODO: implement this
+	// this is how we'll keep track of the orders
+	orders   map[match.OrderID]*match.AuctionOrderIDPair
+	orderMtx *sync.Mutex
 
 	// this pair
 	pair *match.Pair
@@ -18,9 +20,16 @@ type MemoryAuctionOrderbook struct {
 
 // CreateAuctionOrderbook creates a auction orderbook based on a pair
 func CreateAuctionOrderbook(pair *match.Pair) (book match.AuctionOrderbook, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create auction orderbook with nil pair, please enter valid input")
+		return
+	}
+
 	// Set values for auction engine
 	mo := &MemoryAuctionOrderbook{
-		pair: pair,
+		orders:   make(map[match.OrderID]*match.AuctionOrderIDPair),
+		orderMtx: new(sync.Mutex),
+		pair:     pair,
 	}
 	// We can connect, now set return
 	book = mo
@@ -29,50 +38,153 @@ func CreateAuctionOrderbook(pair *match.Pair) (book match.AuctionOrderbook, err
 
 // UpdateBookExec takes in an order execution and updates the orderbook.
 func (mo *MemoryAuctionOrderbook) UpdateBookExec(exec *match.OrderExecution) (err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+	if exec == nil {
+		err = fmt.Errorf("Cannot update book with nil execution, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	if exec.Filled {
+		delete(mo.orders, exec.OrderID)
+		return
+	}
+
+	if currOrder, ok := mo.orders[exec.OrderID]; ok {
+		currOrder.Order.AmountHave = exec.NewAmountHave
+		currOrder.Order.AmountWant = exec.NewAmountWant
+	}
 	return
 }
 
 // UpdateBookCancel takes in an order cancellation and updates the orderbook.
 func (mo *MemoryAuctionOrderbook) UpdateBookCancel(cancel *match.CancelledOrder) (err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+	if cancel == nil || cancel.OrderID == nil {
+		err = fmt.Errorf("Cannot update book with nil cancel, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	if _, ok := mo.orders[*cancel.OrderID]; !ok {
+		err = fmt.Errorf("Error: Could not find order %x to cancel for UpdateBookCancel", cancel.OrderID[:])
+		return
+	}
+	delete(mo.orders, *cancel.OrderID)
 	return
 }
 
 // UpdateBookPlace takes in an order, ID, auction ID, and adds the order to the orderbook.
 func (mo *MemoryAuctionOrderbook) UpdateBookPlace(auctionIDPair *match.AuctionOrderIDPair) (err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+	if auctionIDPair == nil || auctionIDPair.Order == nil {
+		err = fmt.Errorf("Cannot place nil order into book, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	if _, ok := mo.orders[auctionIDPair.OrderID]; ok {
+		err = fmt.Errorf("Error placing order into book for UpdateBookPlace: order %x already exists", auctionIDPair.OrderID[:])
+		return
+	}
+	mo.orders[auctionIDPair.OrderID] = copyAuctionIDPair(auctionIDPair)
 	return
 }
 
 // GetOrder gets an order from an OrderID
 func (mo *MemoryAuctionOrderbook) GetOrder(orderID *match.OrderID) (aucOrder *match.AuctionOrderIDPair, err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+	if orderID == nil {
+		err = fmt.Errorf("Cannot get order with nil ID, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	var foundOrder *match.AuctionOrderIDPair
+	var ok bool
+	if foundOrder, ok = mo.orders[*orderID]; !ok {
+		err = fmt.Errorf("Could not find order %x for GetOrder", orderID[:])
+		return
+	}
+	aucOrder = copyAuctionIDPair(foundOrder)
 	return
 }
 
 // CalculatePrice takes in a pair and returns the calculated price based on the orderbook.
+// This is the clearing price of the orders in the auction.
 func (mo *MemoryAuctionOrderbook) CalculatePrice(auctionID *match.AuctionID) (price float64, err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+	if auctionID == nil {
+		err = fmt.Errorf("Cannot calculate price for nil auction ID, please enter valid input")
+		return
+	}
+
+	book := make(map[match.Price][]*match.AuctionOrderIDPair)
+	mo.orderMtx.Lock()
+	for _, pair := range mo.orders {
+		if pair.Order.AuctionID == *auctionID {
+			book[pair.Price] = append(book[pair.Price], copyAuctionIDPair(pair))
+		}
+	}
+	mo.orderMtx.Unlock()
+
+	var clearingPrice match.Price
+	if clearingPrice, err = match.CalculateClearingPrice(book); err != nil {
+		err = fmt.Errorf("Error calculating clearing price for auction CalculatePrice: %s", err)
+		return
+	}
+
+	if clearingPrice.AmountHave == 0 {
+		err = fmt.Errorf("Error calculating price for auction CalculatePrice: no orders intersect")
+		return
+	}
+
+	if price, err = clearingPrice.ToFloat(); err != nil {
+		err = fmt.Errorf("Error converting clearing price to float for auction CalculatePrice: %s", err)
+		return
+	}
 	return
 }
 
 // GetOrdersForPubkey gets orders for a specific pubkey.
-func (mo *MemoryAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[float64][]*match.AuctionOrderIDPair, err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+func (mo *MemoryAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[match.Price][]*match.AuctionOrderIDPair, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get orders for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	orders = make(map[match.Price][]*match.AuctionOrderIDPair)
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	for _, pair := range mo.orders {
+		if pair.Order.Pubkey == pkBytes {
+			orders[pair.Price] = append(orders[pair.Price], copyAuctionIDPair(pair))
+		}
+	}
 	return
 }
 
 // ViewAuctionOrderbook takes in a trading pair and returns the orderbook as a map
-func (mo *MemoryAuctionOrderbook) ViewAuctionOrderBook() (book map[float64][]*match.AuctionOrderIDPair, err error) {
-	// TODO: Implement
-	logging.Fatalf("UNIMPLEMENTED!")
+func (mo *MemoryAuctionOrderbook) ViewAuctionOrderBook() (book map[match.Price][]*match.AuctionOrderIDPair, err error) {
+	book = make(map[match.Price][]*match.AuctionOrderIDPair)
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	for _, pair := range mo.orders {
+		book[pair.Price] = append(book[pair.Price], copyAuctionIDPair(pair))
+	}
+	return
+}
+
+// copyAuctionIDPair copies the order ID pair as well as the order it points to
+func copyAuctionIDPair(pair *match.AuctionOrderIDPair) (pairCopy *match.AuctionOrderIDPair) {
+	pairCopy = &match.AuctionOrderIDPair{
+		OrderID: pair.OrderID,
+		Price:   pair.Price,
+		Order:   new(match.AuctionOrder),
+	}
+	*pairCopy.Order = *pair.Order
 	return
 }
 
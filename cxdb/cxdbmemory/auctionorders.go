@@ -34,7 +34,7 @@ func (db *CXDBMemory) PlaceAuctionOrder(order *match.AuctionOrder) (err error) {
 }
 
 // ViewAuctionOrderBook takes in a trading pair and auction ID, and returns auction orders.
-func (db *CXDBMemory) ViewAuctionOrderBook(tradingPair *match.Pair, auctionID [32]byte) (book map[float64][]*match.AuctionOrderIDPair, err error) {
+func (db *CXDBMemory) ViewAuctionOrderBook(tradingPair *match.Pair, auctionID [32]byte) (book map[match.Price][]*match.AuctionOrderIDPair, err error) {
 
 	db.ordersMtx.Lock()
 	var allOrders []*match.AuctionOrder
@@ -44,7 +44,7 @@ func (db *CXDBMemory) ViewAuctionOrderBook(tradingPair *match.Pair, auctionID [3
 		err = fmt.Errorf("Could not find auctionID in the auction orderbook")
 		return
 	}
-	var orderPrice float64
+	var orderPrice *match.Price
 	var thisOrderPair *match.AuctionOrderIDPair
 	for _, order := range allOrders {
 		if order.TradingPair == *tradingPair {
@@ -59,7 +59,8 @@ func (db *CXDBMemory) ViewAuctionOrderBook(tradingPair *match.Pair, auctionID [3
 			hasher.Write(order.SerializeSignable())
 			thisOrderPair = new(match.AuctionOrderIDPair)
 			copy(thisOrderPair.OrderID[:], hasher.Sum(nil))
-			book[orderPrice] = append(book[orderPrice], thisOrderPair)
+			thisOrderPair.Price = *orderPrice
+			book[*orderPrice] = append(book[*orderPrice], thisOrderPair)
 		}
 	}
 
@@ -0,0 +1,162 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// executionKey identifies an execution that has been added to the candle store
+type executionKey struct {
+	id   [32]byte
+	time int64
+}
+
+// MemoryCandleStore keeps the executions and candles for a pair in memory
+type MemoryCandleStore struct {
+	// execs are in the order they were made
+	execs []*match.PriceExecution
+	// added are the executions that have been added already
+	added map[executionKey]bool
+	// candles are the candles for each interval, oldest first
+	candles   map[match.CandleInterval][]*match.Candle
+	candleMtx *sync.Mutex
+
+	// this pair
+	pair *match.Pair
+}
+
+// CreateCandleStore creates a candle store for a specific pair.
+func CreateCandleStore(pair *match.Pair) (store cxdb.CandleStore, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create candle store with nil pair, please enter valid input")
+		return
+	}
+
+	// Set values
+	mc := &MemoryCandleStore{
+		added:     make(map[executionKey]bool),
+		candles:   make(map[match.CandleInterval][]*match.Candle),
+		candleMtx: new(sync.Mutex),
+		pair:      pair,
+	}
+
+	// Now we actually set what we want
+	store = mc
+	return
+}
+
+// AddExecutions adds executions to the store, in the order they were made, and updates the candles they
+// are in. Executions that are already in the store are ignored.
+func (mc *MemoryCandleStore) AddExecutions(execs []*match.PriceExecution) (err error) {
+	for _, exec := range execs {
+		if exec == nil {
+			err = fmt.Errorf("Cannot add nil execution, please enter valid input")
+			return
+		}
+
+		if exec.Pair != *mc.pair {
+			err = fmt.Errorf("Cannot add execution for pair %s to store for pair %s", exec.Pair.String(), mc.pair.String())
+			return
+		}
+	}
+
+	mc.candleMtx.Lock()
+	for _, exec := range execs {
+		key := executionKey{id: exec.ID, time: exec.Timestamp.UnixNano()}
+		if mc.added[key] {
+			continue
+		}
+
+		for _, interval := range match.CandleIntervals {
+			if err = mc.addToCandle(interval, exec); err != nil {
+				err = fmt.Errorf("Error adding execution to %s candle: %s", interval.String(), err)
+				mc.candleMtx.Unlock()
+				return
+			}
+		}
+
+		execCopy := new(match.PriceExecution)
+		*execCopy = *exec
+		mc.execs = append(mc.execs, execCopy)
+		mc.added[key] = true
+	}
+	mc.candleMtx.Unlock()
+
+	return
+}
+
+// addToCandle adds an execution to the candle it's in for an interval, creating the candle if it doesn't
+// exist yet. This assumes the candle mutex is held.
+func (mc *MemoryCandleStore) addToCandle(interval match.CandleInterval, exec *match.PriceExecution) (err error) {
+	start := interval.Start(exec.Timestamp)
+	intervalCandles := mc.candles[interval]
+
+	// Executions almost always go in the latest candle, but one could be a little late
+	i := sort.Search(len(intervalCandles), func(j int) bool {
+		return !intervalCandles[j].Start.Before(start)
+	})
+
+	if i < len(intervalCandles) && intervalCandles[i].Start.Equal(start) {
+		err = intervalCandles[i].AddExecution(exec)
+		return
+	}
+
+	var candle *match.Candle
+	if candle, err = match.NewCandle(interval, exec); err != nil {
+		return
+	}
+
+	intervalCandles = append(intervalCandles, nil)
+	copy(intervalCandles[i+1:], intervalCandles[i:])
+	intervalCandles[i] = candle
+	mc.candles[interval] = intervalCandles
+	return
+}
+
+// GetCandles gets the candles for an interval that start at or after start and before end, oldest first.
+// If end is zero there is no end.
+func (mc *MemoryCandleStore) GetCandles(interval match.CandleInterval, start time.Time, end time.Time) (candles []*match.Candle, err error) {
+	if interval.Duration() == 0 {
+		err = fmt.Errorf("Cannot get candles for invalid interval %d", interval)
+		return
+	}
+
+	mc.candleMtx.Lock()
+	for _, candle := range mc.candles[interval] {
+		if candle.Start.Before(start) {
+			continue
+		}
+
+		if !end.IsZero() && !candle.Start.Before(end) {
+			break
+		}
+
+		candleCopy := new(match.Candle)
+		*candleCopy = *candle
+		candles = append(candles, candleCopy)
+	}
+	mc.candleMtx.Unlock()
+
+	return
+}
+
+// CreateCandleStoreMap creates a map of pair to candle store, given a list of pairs.
+func CreateCandleStoreMap(pairList []*match.Pair) (storeMap map[match.Pair]cxdb.CandleStore, err error) {
+
+	storeMap = make(map[match.Pair]cxdb.CandleStore)
+	var curCandleStore cxdb.CandleStore
+	for _, pair := range pairList {
+		if curCandleStore, err = CreateCandleStore(pair); err != nil {
+			err = fmt.Errorf("Error creating single candle store while creating candle store map: %s", err)
+			return
+		}
+		storeMap[*pair] = curCandleStore
+	}
+
+	return
+}
@@ -0,0 +1,95 @@
+package cxdbmemory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestCandleStoreAddExecutions adds executions to a candle store, including one that is a little late and
+// ones that were already added, and makes sure every interval has the right candles.
+func TestCandleStoreAddExecutions(t *testing.T) {
+	var err error
+
+	pair := &match.Pair{AssetWant: match.Asset(1), AssetHave: match.Asset(0)}
+
+	var cs cxdb.CandleStore
+	if cs, err = CreateCandleStore(pair); err != nil {
+		t.Errorf("Error creating candle store for TestCandleStoreAddExecutions: %s", err)
+		return
+	}
+
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	execs := []*match.PriceExecution{
+		{ID: [32]byte{0x1}, Pair: *pair, Price: match.Price{AmountWant: 1, AmountHave: 1}, AmountWant: 100, AmountHave: 100, Timestamp: start},
+		{ID: [32]byte{0x2}, Pair: *pair, Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountWant: 200, AmountHave: 100, Timestamp: start.Add(6 * time.Minute)},
+		// This one is late, so it goes in the first minute's candle
+		{ID: [32]byte{0x3}, Pair: *pair, Price: match.Price{AmountWant: 3, AmountHave: 1}, AmountWant: 300, AmountHave: 100, Timestamp: start.Add(30 * time.Second)},
+	}
+
+	if err = cs.AddExecutions(execs); err != nil {
+		t.Errorf("Error adding executions for TestCandleStoreAddExecutions: %s", err)
+		return
+	}
+
+	// Adding them again shouldn't change anything
+	if err = cs.AddExecutions(execs); err != nil {
+		t.Errorf("Error adding executions again for TestCandleStoreAddExecutions: %s", err)
+		return
+	}
+
+	var tests = []struct {
+		interval   match.CandleInterval
+		executions []uint64
+	}{
+		{match.OneMinute, []uint64{2, 1}},
+		{match.FiveMinutes, []uint64{2, 1}},
+		{match.OneHour, []uint64{3}},
+		{match.OneDay, []uint64{3}},
+	}
+
+	for _, tt := range tests {
+		var candles []*match.Candle
+		// The hour and day candles start before the first execution
+		if candles, err = cs.GetCandles(tt.interval, time.Time{}, time.Time{}); err != nil {
+			t.Errorf("Error getting %s candles for TestCandleStoreAddExecutions: %s", tt.interval.String(), err)
+			return
+		}
+
+		if len(candles) != len(tt.executions) {
+			t.Errorf("There should have been %d %s candles but there were %d", len(tt.executions), tt.interval.String(), len(candles))
+			return
+		}
+
+		for i, candle := range candles {
+			if candle.Executions != tt.executions[i] {
+				t.Errorf("%s candle %d should have had %d executions but had %d", tt.interval.String(), i, tt.executions[i], candle.Executions)
+				return
+			}
+		}
+	}
+
+	var candles []*match.Candle
+	if candles, err = cs.GetCandles(match.OneMinute, start, time.Time{}); err != nil {
+		t.Errorf("Error getting candles for TestCandleStoreAddExecutions: %s", err)
+		return
+	}
+
+	// The late execution is the close of the first candle, since it was added last
+	if candles[0].Open != execs[0].Price || candles[0].Close != execs[2].Price || candles[0].High != execs[2].Price {
+		t.Errorf("First minute candle had the wrong prices: %s", candles[0].String())
+		return
+	}
+
+	if candles, err = cs.GetCandles(match.OneMinute, start.Add(time.Minute), start.Add(6*time.Minute)); err != nil {
+		t.Errorf("Error getting candles in range for TestCandleStoreAddExecutions: %s", err)
+		return
+	}
+
+	if len(candles) != 0 {
+		t.Errorf("There should have been no candles between the first and the sixth minute but there were %d", len(candles))
+		return
+	}
+}
@@ -0,0 +1,178 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// memPendingDeposit is a deposit that has not been confirmed yet
+type memPendingDeposit struct {
+	pubkey        [33]byte
+	depositHeight uint64
+	amount        uint64
+	txid          string
+}
+
+// MemoryDepositStore keeps track of deposit addresses and pending deposits in memory
+type MemoryDepositStore struct {
+	// deposit addresses, address to pubkey
+	depositAddrs map[string]*koblitz.PublicKey
+	// pubkey to deposit address
+	pubkeyAddrs map[[33]byte]string
+	addrMtx     *sync.Mutex
+
+	// pending deposits, keyed by the height they are expected to confirm at
+	pendingDeposits map[uint64][]*memPendingDeposit
+	pendingMtx      *sync.Mutex
+
+	// this coin
+	coin *coinparam.Params
+}
+
+// CreateDepositStore creates a deposit store for a specific coin.
+func CreateDepositStore(coin *coinparam.Params) (store cxdb.DepositStore, err error) {
+	if coin == nil {
+		err = fmt.Errorf("Cannot create deposit store with nil coin, please enter valid input")
+		return
+	}
+
+	// Set values
+	ds := &MemoryDepositStore{
+		depositAddrs:    make(map[string]*koblitz.PublicKey),
+		pubkeyAddrs:     make(map[[33]byte]string),
+		addrMtx:         new(sync.Mutex),
+		pendingDeposits: make(map[uint64][]*memPendingDeposit),
+		pendingMtx:      new(sync.Mutex),
+		coin:            coin,
+	}
+
+	// Now we actually set what we want
+	store = ds
+	return
+}
+
+// UpdateDeposits updates the deposits when a block comes in, and returns execs for deposits that are
+// now confirmed
+func (ds *MemoryDepositStore) UpdateDeposits(deposits []match.Deposit, blockheight uint64) (depositExecs []*match.SettlementExecution, err error) {
+	// first get debit asset
+	var depositAsset match.Asset
+	if depositAsset, err = match.AssetFromCoinParam(ds.coin); err != nil {
+		err = fmt.Errorf("Error getting asset from coin param for UpdateDeposits: %s", err)
+		return
+	}
+
+	ds.pendingMtx.Lock()
+	defer ds.pendingMtx.Unlock()
+
+	// First we add these deposits
+	for _, deposit := range deposits {
+		if deposit.Pubkey == nil {
+			err = fmt.Errorf("Error adding deposit with nil pubkey for UpdateDeposits")
+			return
+		}
+		pending := &memPendingDeposit{
+			depositHeight: deposit.BlockHeightReceived,
+			amount:        deposit.Amount,
+			txid:          deposit.Txid,
+		}
+		copy(pending.pubkey[:], deposit.Pubkey.SerializeCompressed())
+		expectedConfirm := deposit.BlockHeightReceived + deposit.Confirmations
+		ds.pendingDeposits[expectedConfirm] = append(ds.pendingDeposits[expectedConfirm], pending)
+	}
+
+	// Now we get the ones where expectedConfirm EQUALS the current height.
+	// Just like the SQL deposit store, if a reorg were to happen then users could be credited twice.
+	for _, pending := range ds.pendingDeposits[blockheight] {
+		// A confirmed deposit is a debit for the deposit store's asset
+		depositExecs = append(depositExecs, &match.SettlementExecution{
+			Pubkey: pending.pubkey,
+			Amount: pending.amount,
+			Asset:  depositAsset,
+			Type:   match.Debit,
+		})
+	}
+
+	// We don't need these anymore
+	delete(ds.pendingDeposits, blockheight)
+
+	return
+}
+
+// GetDepositAddressMap gets a map of the deposit addresses we own to pubkeys
+func (ds *MemoryDepositStore) GetDepositAddressMap() (depAddrMap map[string]*koblitz.PublicKey, err error) {
+	depAddrMap = make(map[string]*koblitz.PublicKey)
+
+	ds.addrMtx.Lock()
+	for addr, pubkey := range ds.depositAddrs {
+		depAddrMap[addr] = pubkey
+	}
+	ds.addrMtx.Unlock()
+
+	return
+}
+
+// GetDepositAddress gets the deposit address for a pubkey and an asset.
+func (ds *MemoryDepositStore) GetDepositAddress(pubkey *koblitz.PublicKey) (addr string, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get deposit address for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	ds.addrMtx.Lock()
+	defer ds.addrMtx.Unlock()
+	var ok bool
+	if addr, ok = ds.pubkeyAddrs[pkBytes]; !ok {
+		err = fmt.Errorf("Could not find deposit address for pubkey %x", pkBytes)
+		return
+	}
+
+	return
+}
+
+// RegisterUser takes in a pubkey, and an address for the pubkey, and puts the deposit address as the
+// value for the user's pubkey key
+func (ds *MemoryDepositStore) RegisterUser(pubkey *koblitz.PublicKey, address string) (err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot register nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	ds.addrMtx.Lock()
+	defer ds.addrMtx.Unlock()
+	if _, ok := ds.pubkeyAddrs[pkBytes]; ok {
+		err = fmt.Errorf("Error registering user: pubkey %x already has a deposit address", pkBytes)
+		return
+	}
+
+	ds.pubkeyAddrs[pkBytes] = address
+	ds.depositAddrs[address] = pubkey
+
+	return
+}
+
+// CreateDepositStoreMap creates a map of coin to deposit store, given a list of coins.
+func CreateDepositStoreMap(coinList []*coinparam.Params) (depositMap map[*coinparam.Params]cxdb.DepositStore, err error) {
+
+	depositMap = make(map[*coinparam.Params]cxdb.DepositStore)
+	var curDepositStore cxdb.DepositStore
+	for _, coin := range coinList {
+		if curDepositStore, err = CreateDepositStore(coin); err != nil {
+			err = fmt.Errorf("Error creating single deposit store while creating deposit store map: %s", err)
+			return
+		}
+		depositMap[coin] = curDepositStore
+	}
+
+	return
+}
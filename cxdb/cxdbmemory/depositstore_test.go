@@ -0,0 +1,80 @@
+package cxdbmemory
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+func TestDepositConfirmsAtExpectedHeight(t *testing.T) {
+	var err error
+
+	var privkey *koblitz.PrivateKey
+	if privkey, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating private key for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+	pubkey := privkey.PubKey()
+
+	var ds cxdb.DepositStore
+	if ds, err = CreateDepositStore(&coinparam.BitcoinParams); err != nil {
+		t.Errorf("Error creating deposit store for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+
+	if err = ds.RegisterUser(pubkey, "testaddress"); err != nil {
+		t.Errorf("Error registering user for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+
+	var addr string
+	if addr, err = ds.GetDepositAddress(pubkey); err != nil {
+		t.Errorf("Error getting deposit address for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+
+	if addr != "testaddress" {
+		t.Errorf("Deposit address should have been testaddress but was %s", addr)
+		return
+	}
+
+	deposit := match.Deposit{
+		Pubkey:              pubkey,
+		Address:             addr,
+		Amount:              1000,
+		CoinType:            &coinparam.BitcoinParams,
+		BlockHeightReceived: 10,
+		Confirmations:       6,
+	}
+
+	var depositExecs []*match.SettlementExecution
+	if depositExecs, err = ds.UpdateDeposits([]match.Deposit{deposit}, 10); err != nil {
+		t.Errorf("Error updating deposits for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+
+	if len(depositExecs) != 0 {
+		t.Errorf("Deposit should not have confirmed at height 10, but there were %d execs", len(depositExecs))
+		return
+	}
+
+	if depositExecs, err = ds.UpdateDeposits([]match.Deposit{}, 16); err != nil {
+		t.Errorf("Error updating deposits for TestDepositConfirmsAtExpectedHeight: %s", err)
+		return
+	}
+
+	if len(depositExecs) != 1 {
+		t.Errorf("Deposit should have confirmed at height 16, but there were %d execs", len(depositExecs))
+		return
+	}
+
+	if depositExecs[0].Amount != deposit.Amount || depositExecs[0].Type != match.Debit || depositExecs[0].Asset != btc {
+		t.Errorf("Deposit exec should have been a debit of %d %s but was %s", deposit.Amount, btc, depositExecs[0])
+		return
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/opencx/cxdb"
+)
+
+// MemoryJournal is a journal that is kept in memory. This is not durable, so it is only really useful for
+// testing replay, or for running an exchange that does not need to survive a restart.
+type MemoryJournal struct {
+	// entries are kept serialized so nobody can modify them after they are appended
+	entries    [][]byte
+	entriesMtx *sync.Mutex
+
+	// the hash of the last entry, which the next entry will commit to
+	lastHash [32]byte
+}
+
+// CreateJournal creates a journal that is kept in memory
+func CreateJournal() (journal cxdb.Journal, err error) {
+	// Set values
+	mj := &MemoryJournal{
+		entriesMtx: new(sync.Mutex),
+	}
+
+	// Now we actually set the journal
+	journal = mj
+	return
+}
+
+// AppendEntry writes an entry to the end of the journal, setting its sequence number and previous hash.
+func (mj *MemoryJournal) AppendEntry(entry *cxdb.JournalEntry) (err error) {
+	if entry == nil {
+		err = fmt.Errorf("Cannot append nil entry to journal, please enter valid input")
+		return
+	}
+
+	mj.entriesMtx.Lock()
+	defer mj.entriesMtx.Unlock()
+
+	entry.Sequence = uint64(len(mj.entries))
+	entry.PrevHash = mj.lastHash
+	var entryBytes []byte
+	if entryBytes, err = entry.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing entry for AppendEntry: %s", err)
+		return
+	}
+
+	var entryHash [32]byte
+	if entryHash, err = entry.Hash(); err != nil {
+		err = fmt.Errorf("Error hashing entry for AppendEntry: %s", err)
+		return
+	}
+
+	mj.entries = append(mj.entries, entryBytes)
+	mj.lastHash = entryHash
+	return
+}
+
+// Entries returns every entry in the journal, in the order they were appended.
+func (mj *MemoryJournal) Entries() (entries []*cxdb.JournalEntry, err error) {
+	mj.entriesMtx.Lock()
+	defer mj.entriesMtx.Unlock()
+
+	for _, entryBytes := range mj.entries {
+		entry := new(cxdb.JournalEntry)
+		if err = entry.Deserialize(entryBytes); err != nil {
+			err = fmt.Errorf("Error deserializing entry %d for Entries: %s", len(entries), err)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
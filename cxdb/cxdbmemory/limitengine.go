@@ -0,0 +1,250 @@
+package cxdbmemory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencx/match"
+	"golang.org/x/crypto/sha3"
+)
+
+// MemoryLimitEngine is a limit matching engine that keeps all of its orders in memory.
+// It is meant to have the same behavior as the SQL limit engine, without needing a database.
+type MemoryLimitEngine struct {
+	orders   map[match.OrderID]*match.LimitOrderIDPair
+	orderMtx *sync.Mutex
+
+	// this pair
+	pair *match.Pair
+}
+
+// CreateLimitEngine creates a limit matching engine that keeps its orders in memory
+func CreateLimitEngine(pair *match.Pair) (engine match.LimitEngine, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create limit engine with nil pair, please enter valid input")
+		return
+	}
+
+	// Set values
+	me := &MemoryLimitEngine{
+		orders:   make(map[match.OrderID]*match.LimitOrderIDPair),
+		orderMtx: new(sync.Mutex),
+		pair:     pair,
+	}
+
+	// Now we actually set the engine
+	engine = me
+	return
+}
+
+// PlaceLimitOrder places an order in the limit matching engine.
+// This assumes that the order is valid and is for the same pair as the matching engine
+func (me *MemoryLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match.LimitOrderIDPair, err error) {
+	if order == nil {
+		err = fmt.Errorf("Cannot place nil order, please enter valid input")
+		return
+	}
+
+	// calculate price, this will also reject orders where either amount is zero
+	var price *match.Price
+	if price, err = order.Price(); err != nil {
+		err = fmt.Errorf("Error getting price from order while placing order: %s", err)
+		return
+	}
+
+	placementTime := time.Now()
+
+	var orderBytes []byte
+	if orderBytes, err = order.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing while placing order: %s", err)
+		return
+	}
+
+	// The placement time is hashed in as well, since the same order can be placed more than once
+	var timeBytes [8]byte
+	binary.LittleEndian.PutUint64(timeBytes[:], uint64(placementTime.UnixNano()))
+
+	hasher := sha3.New256()
+	hasher.Write(orderBytes)
+	hasher.Write(timeBytes[:])
+
+	idRes = &match.LimitOrderIDPair{
+		OrderID:   new(match.OrderID),
+		Order:     order,
+		Price:     *price,
+		Timestamp: placementTime,
+	}
+
+	if err = idRes.OrderID.UnmarshalBinary(hasher.Sum(nil)); err != nil {
+		err = fmt.Errorf("Could not unmarshal order id for PlaceLimitOrder: %s", err)
+		return
+	}
+
+	me.orderMtx.Lock()
+	if _, ok := me.orders[*idRes.OrderID]; ok {
+		err = fmt.Errorf("Order with ID %x already exists in the limit engine", idRes.OrderID[:])
+		me.orderMtx.Unlock()
+		return
+	}
+	// The engine keeps its own copy so nothing outside can modify the state of the engine
+	me.orders[*idRes.OrderID] = copyLimitIDPair(idRes)
+	me.orderMtx.Unlock()
+
+	return
+}
+
+// CancelLimitOrder cancels a limit order, returning the settlement that refunds the remaining AmountHave.
+func (me *MemoryLimitEngine) CancelLimitOrder(id *match.OrderID) (cancelled *match.CancelledOrder, cancelSettlement *match.SettlementExecution, err error) {
+	if id == nil {
+		err = fmt.Errorf("Cannot cancel order with nil ID, please enter valid input")
+		return
+	}
+
+	me.orderMtx.Lock()
+	var deletedOrder *match.LimitOrderIDPair
+	var ok bool
+	if deletedOrder, ok = me.orders[*id]; !ok {
+		err = fmt.Errorf("Could not find order %x to cancel for CancelLimitOrder", id[:])
+		me.orderMtx.Unlock()
+		return
+	}
+	delete(me.orders, *id)
+	me.orderMtx.Unlock()
+
+	var debitAsset match.Asset
+	if deletedOrder.Order.Side == match.Buy {
+		debitAsset = me.pair.AssetHave
+	} else {
+		debitAsset = me.pair.AssetWant
+	}
+
+	cancelled = &match.CancelledOrder{
+		OrderID: id,
+	}
+	cancelSettlement = &match.SettlementExecution{
+		Pubkey: deletedOrder.Order.Pubkey,
+		Amount: deletedOrder.Order.AmountHave,
+		Asset:  debitAsset,
+		Type:   match.Debit,
+	}
+	return
+}
+
+// MatchLimitOrders matches limit orders based on price/time priority, cancelling whatever is left of
+// immediate-or-cancel and fill-or-kill orders, and preventing self-trades
+func (me *MemoryLimitEngine) MatchLimitOrders() (orderExecs []*match.OrderExecution, settlementExecs []*match.SettlementExecution, cancelled []*match.CancelledOrder, trades []*match.Trade, err error) {
+	me.orderMtx.Lock()
+	defer me.orderMtx.Unlock()
+
+	// The matching algorithm makes its own copies, so the engine state only changes below
+	book := make(map[match.Price][]*match.LimitOrderIDPair)
+	for _, pair := range me.orders {
+		book[pair.Price] = append(book[pair.Price], pair)
+	}
+
+	if orderExecs, settlementExecs, cancelled, trades, err = match.MatchLimitBook(book); err != nil {
+		err = fmt.Errorf("Error matching book for MatchLimitOrders: %s", err)
+		return
+	}
+
+	// Update the matching engine with the new state because that's what we do
+	for _, orderExec := range orderExecs {
+		applyOrderExecution(me.orders, orderExec)
+	}
+
+	for _, cancelledOrder := range cancelled {
+		delete(me.orders, *cancelledOrder.OrderID)
+	}
+
+	return
+}
+
+// RestoreLimitOrder puts an order that was already placed back into the engine, keeping its ID, price,
+// timestamp and amounts. This does not match orders.
+func (me *MemoryLimitEngine) RestoreLimitOrder(idPair *match.LimitOrderIDPair) (err error) {
+	if idPair == nil || idPair.OrderID == nil || idPair.Order == nil {
+		err = fmt.Errorf("Cannot restore nil order, please enter valid input")
+		return
+	}
+
+	me.orderMtx.Lock()
+	defer me.orderMtx.Unlock()
+	if _, ok := me.orders[*idPair.OrderID]; ok {
+		err = fmt.Errorf("Order with ID %x already exists in the limit engine", idPair.OrderID[:])
+		return
+	}
+	me.orders[*idPair.OrderID] = copyLimitIDPair(idPair)
+	return
+}
+
+// ReplaceLimitOrder amends the amounts of an order in the engine, keeping its ID. The order keeps its time
+// priority if only its size is reduced. This does not match orders.
+func (me *MemoryLimitEngine) ReplaceLimitOrder(id *match.OrderID, amountHave uint64, amountWant uint64) (replaced *match.LimitOrderIDPair, reserveChange *match.SettlementExecution, err error) {
+	if id == nil {
+		err = fmt.Errorf("Cannot replace order with nil ID, please enter valid input")
+		return
+	}
+
+	me.orderMtx.Lock()
+	defer me.orderMtx.Unlock()
+	var currOrder *match.LimitOrderIDPair
+	var ok bool
+	if currOrder, ok = me.orders[*id]; !ok {
+		err = fmt.Errorf("Could not find order %x to replace for ReplaceLimitOrder", id[:])
+		return
+	}
+
+	if replaced, reserveChange, err = currOrder.Replace(amountHave, amountWant, time.Now()); err != nil {
+		err = fmt.Errorf("Error replacing order for ReplaceLimitOrder: %s", err)
+		return
+	}
+
+	me.orders[*id] = copyLimitIDPair(replaced)
+	return
+}
+
+// applyOrderExecution deletes the order if the execution filled it, and otherwise updates
+// the amounts of the order.
+func applyOrderExecution(orders map[match.OrderID]*match.LimitOrderIDPair, orderExec *match.OrderExecution) {
+	if orderExec.Filled {
+		delete(orders, orderExec.OrderID)
+		return
+	}
+
+	if currOrder, ok := orders[orderExec.OrderID]; ok {
+		currOrder.Order.AmountHave = orderExec.NewAmountHave
+		currOrder.Order.AmountWant = orderExec.NewAmountWant
+	}
+	return
+}
+
+// copyLimitIDPair copies the order ID pair as well as the order and order ID it points to
+func copyLimitIDPair(pair *match.LimitOrderIDPair) (pairCopy *match.LimitOrderIDPair) {
+	pairCopy = &match.LimitOrderIDPair{
+		Timestamp: pair.Timestamp,
+		Price:     pair.Price,
+		OrderID:   new(match.OrderID),
+		Order:     new(match.LimitOrder),
+	}
+	*pairCopy.OrderID = *pair.OrderID
+	*pairCopy.Order = *pair.Order
+	return
+}
+
+// CreateLimitEngineMap creates a map of pair to limit engine, given a list of pairs.
+func CreateLimitEngineMap(pairList []*match.Pair) (limMap map[match.Pair]match.LimitEngine, err error) {
+
+	limMap = make(map[match.Pair]match.LimitEngine)
+	var curLimEng match.LimitEngine
+	for _, pair := range pairList {
+		if curLimEng, err = CreateLimitEngine(pair); err != nil {
+			err = fmt.Errorf("Error creating single limit engine while creating limit engine map: %s", err)
+			return
+		}
+		limMap[*pair] = curLimEng
+	}
+
+	return
+}
@@ -0,0 +1,268 @@
+package cxdbmemory
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/match"
+)
+
+var (
+	vtc, _        = match.AssetFromCoinParam(&coinparam.VertcoinParams)
+	testLimitPair = &match.Pair{
+		AssetWant: btc,
+		AssetHave: vtc,
+	}
+	testBuyOrder = &match.LimitOrder{
+		Pubkey:      [33]byte{0x02, 0x01},
+		Side:        match.Buy,
+		TradingPair: *testLimitPair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	testSellOrder = &match.LimitOrder{
+		Pubkey:      [33]byte{0x03, 0x02},
+		Side:        match.Sell,
+		TradingPair: *testLimitPair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+)
+
+func TestPlaceCancelLimitOrder(t *testing.T) {
+	var err error
+
+	var engine match.LimitEngine
+	if engine, err = CreateLimitEngine(testLimitPair); err != nil {
+		t.Errorf("Error creating limit engine for TestPlaceCancelLimitOrder: %s", err)
+		return
+	}
+
+	var idPair *match.LimitOrderIDPair
+	if idPair, err = engine.PlaceLimitOrder(testBuyOrder); err != nil {
+		t.Errorf("Error placing order for TestPlaceCancelLimitOrder: %s", err)
+		return
+	}
+
+	var cancelExec *match.SettlementExecution
+	if _, cancelExec, err = engine.CancelLimitOrder(idPair.OrderID); err != nil {
+		t.Errorf("Error cancelling order for TestPlaceCancelLimitOrder: %s", err)
+		return
+	}
+
+	if cancelExec.Type != match.Debit || cancelExec.Amount != testBuyOrder.AmountHave || cancelExec.Asset != vtc {
+		t.Errorf("Cancel should have debited %d of %s but debited %d of %s", testBuyOrder.AmountHave, vtc, cancelExec.Amount, cancelExec.Asset)
+		return
+	}
+
+	// Cancelling twice should not work
+	if _, _, err = engine.CancelLimitOrder(idPair.OrderID); err == nil {
+		t.Errorf("Cancelling an order twice should have returned an error")
+		return
+	}
+
+	return
+}
+
+func TestMatchPartialFill(t *testing.T) {
+	var err error
+
+	var engine match.LimitEngine
+	if engine, err = CreateLimitEngine(testLimitPair); err != nil {
+		t.Errorf("Error creating limit engine for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	var book match.LimitOrderbook
+	if book, err = CreateLimitOrderbook(testLimitPair); err != nil {
+		t.Errorf("Error creating limit orderbook for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	var buyPair *match.LimitOrderIDPair
+	if buyPair, err = engine.PlaceLimitOrder(testBuyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestMatchPartialFill: %s", err)
+		return
+	}
+	if err = book.UpdateBookPlace(buyPair); err != nil {
+		t.Errorf("Error placing buy order into book for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	var sellPair *match.LimitOrderIDPair
+	if sellPair, err = engine.PlaceLimitOrder(testSellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestMatchPartialFill: %s", err)
+		return
+	}
+	if err = book.UpdateBookPlace(sellPair); err != nil {
+		t.Errorf("Error placing sell order into book for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	var orderExecs []*match.OrderExecution
+	if orderExecs, _, _, _, err = engine.MatchLimitOrders(); err != nil {
+		t.Errorf("Error matching orders for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	if len(orderExecs) != 2 {
+		t.Errorf("There should have been 2 order executions but there were %d", len(orderExecs))
+		return
+	}
+
+	for _, orderExec := range orderExecs {
+		if err = book.UpdateBookExec(orderExec); err != nil {
+			t.Errorf("Error updating book with exec for TestMatchPartialFill: %s", err)
+			return
+		}
+	}
+
+	// The sell should be filled, and the buy should be left with half of what it had
+	if _, err = book.GetOrder(sellPair.OrderID); err == nil {
+		t.Errorf("Sell order should have been filled and removed from the book")
+		return
+	}
+
+	var remaining *match.LimitOrderIDPair
+	if remaining, err = book.GetOrder(buyPair.OrderID); err != nil {
+		t.Errorf("Error getting partially filled buy order for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	if remaining.Order.AmountHave != 50 || remaining.Order.AmountWant != 50 {
+		t.Errorf("Buy order should have 50 have and 50 want left, instead has %d have and %d want", remaining.Order.AmountHave, remaining.Order.AmountWant)
+		return
+	}
+
+	// The engine should not match anything else
+	if orderExecs, _, _, _, err = engine.MatchLimitOrders(); err != nil {
+		t.Errorf("Error matching orders again for TestMatchPartialFill: %s", err)
+		return
+	}
+
+	if len(orderExecs) != 0 {
+		t.Errorf("There should be nothing left to match but there were %d executions", len(orderExecs))
+		return
+	}
+
+	// no sells left so there should be no price
+	if _, err = book.CalculatePrice(); err == nil {
+		t.Errorf("CalculatePrice should return an error when there are no sell orders")
+		return
+	}
+
+	return
+}
+
+func TestMatchImmediateOrCancel(t *testing.T) {
+	var err error
+
+	var engine match.LimitEngine
+	if engine, err = CreateLimitEngine(testLimitPair); err != nil {
+		t.Errorf("Error creating limit engine for TestMatchImmediateOrCancel: %s", err)
+		return
+	}
+
+	if _, err = engine.PlaceLimitOrder(testSellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestMatchImmediateOrCancel: %s", err)
+		return
+	}
+
+	iocBuyOrder := *testBuyOrder
+	iocBuyOrder.TimeInForce = match.ImmediateOrCancel
+
+	var buyPair *match.LimitOrderIDPair
+	if buyPair, err = engine.PlaceLimitOrder(&iocBuyOrder); err != nil {
+		t.Errorf("Error placing ioc buy order for TestMatchImmediateOrCancel: %s", err)
+		return
+	}
+
+	var orderExecs []*match.OrderExecution
+	var setExecs []*match.SettlementExecution
+	var cancelled []*match.CancelledOrder
+	if orderExecs, setExecs, cancelled, _, err = engine.MatchLimitOrders(); err != nil {
+		t.Errorf("Error matching orders for TestMatchImmediateOrCancel: %s", err)
+		return
+	}
+
+	if len(orderExecs) != 2 {
+		t.Errorf("There should have been 2 order executions but there were %d", len(orderExecs))
+		return
+	}
+
+	// The half of the buy that couldn't be matched should be cancelled and refunded
+	if len(cancelled) != 1 || *cancelled[0].OrderID != *buyPair.OrderID {
+		t.Errorf("The ioc buy order should have been the only cancelled order, instead %d orders were cancelled", len(cancelled))
+		return
+	}
+
+	refund := setExecs[len(setExecs)-1]
+	if refund.Type != match.Debit || refund.Amount != 50 || refund.Asset != vtc || refund.Pubkey != iocBuyOrder.Pubkey {
+		t.Errorf("Refund should have debited 50 of %s but debited %d of %s", vtc, refund.Amount, refund.Asset)
+		return
+	}
+
+	// The ioc order should not be left in the engine
+	if _, _, err = engine.CancelLimitOrder(buyPair.OrderID); err == nil {
+		t.Errorf("Cancelling an ioc order after matching should have returned an error")
+		return
+	}
+
+	return
+}
+
+func TestMatchFillOrKill(t *testing.T) {
+	var err error
+
+	var engine match.LimitEngine
+	if engine, err = CreateLimitEngine(testLimitPair); err != nil {
+		t.Errorf("Error creating limit engine for TestMatchFillOrKill: %s", err)
+		return
+	}
+
+	var sellPair *match.LimitOrderIDPair
+	if sellPair, err = engine.PlaceLimitOrder(testSellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestMatchFillOrKill: %s", err)
+		return
+	}
+
+	fokBuyOrder := *testBuyOrder
+	fokBuyOrder.TimeInForce = match.FillOrKill
+
+	if _, err = engine.PlaceLimitOrder(&fokBuyOrder); err != nil {
+		t.Errorf("Error placing fok buy order for TestMatchFillOrKill: %s", err)
+		return
+	}
+
+	var orderExecs []*match.OrderExecution
+	var setExecs []*match.SettlementExecution
+	var cancelled []*match.CancelledOrder
+	if orderExecs, setExecs, cancelled, _, err = engine.MatchLimitOrders(); err != nil {
+		t.Errorf("Error matching orders for TestMatchFillOrKill: %s", err)
+		return
+	}
+
+	// There's only 50 to sell, so the buy for 100 can't be filled and nothing should match
+	if len(orderExecs) != 0 {
+		t.Errorf("The fok order should have been killed but there were %d order executions", len(orderExecs))
+		return
+	}
+
+	if len(cancelled) != 1 || len(setExecs) != 1 {
+		t.Errorf("There should have been 1 cancelled order and 1 refund, instead there were %d and %d", len(cancelled), len(setExecs))
+		return
+	}
+
+	if setExecs[0].Type != match.Debit || setExecs[0].Amount != fokBuyOrder.AmountHave || setExecs[0].Asset != vtc {
+		t.Errorf("Refund should have debited %d of %s but debited %d of %s", fokBuyOrder.AmountHave, vtc, setExecs[0].Amount, setExecs[0].Asset)
+		return
+	}
+
+	// The sell order should still be resting
+	if _, _, err = engine.CancelLimitOrder(sellPair.OrderID); err != nil {
+		t.Errorf("Sell order should still be in the engine after the fok order was killed: %s", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,206 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/match"
+)
+
+// MemoryLimitOrderbook is the representation of a limit orderbook that is kept in memory
+type MemoryLimitOrderbook struct {
+	// this is how we'll keep track of the orders
+	orders   map[match.OrderID]*match.LimitOrderIDPair
+	orderMtx *sync.Mutex
+
+	// this pair
+	pair *match.Pair
+}
+
+// CreateLimitOrderbook creates a limit orderbook based on a pair
+func CreateLimitOrderbook(pair *match.Pair) (book match.LimitOrderbook, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create limit orderbook with nil pair, please enter valid input")
+		return
+	}
+
+	// Set values
+	mo := &MemoryLimitOrderbook{
+		orders:   make(map[match.OrderID]*match.LimitOrderIDPair),
+		orderMtx: new(sync.Mutex),
+		pair:     pair,
+	}
+
+	// Now we actually set the book
+	book = mo
+	return
+}
+
+// UpdateBookExec takes in an order execution and updates the orderbook.
+func (mo *MemoryLimitOrderbook) UpdateBookExec(orderExec *match.OrderExecution) (err error) {
+	if orderExec == nil {
+		err = fmt.Errorf("Cannot update book with nil execution, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	applyOrderExecution(mo.orders, orderExec)
+	mo.orderMtx.Unlock()
+	return
+}
+
+// UpdateBookCancel takes in an order cancellation and updates the orderbook.
+func (mo *MemoryLimitOrderbook) UpdateBookCancel(cancel *match.CancelledOrder) (err error) {
+	if cancel == nil || cancel.OrderID == nil {
+		err = fmt.Errorf("Cannot update book with nil cancel, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	if _, ok := mo.orders[*cancel.OrderID]; !ok {
+		err = fmt.Errorf("Error: Could not find order %x to cancel for UpdateBookCancel", cancel.OrderID[:])
+		return
+	}
+	delete(mo.orders, *cancel.OrderID)
+	return
+}
+
+// UpdateBookPlace takes in an order, ID, timestamp, and adds the order to the orderbook.
+func (mo *MemoryLimitOrderbook) UpdateBookPlace(limitIDPair *match.LimitOrderIDPair) (err error) {
+	if limitIDPair == nil || limitIDPair.OrderID == nil || limitIDPair.Order == nil {
+		err = fmt.Errorf("Cannot place nil order into book, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	if _, ok := mo.orders[*limitIDPair.OrderID]; ok {
+		err = fmt.Errorf("Error placing order into book for UpdateBookPlace: order %x already exists", limitIDPair.OrderID[:])
+		return
+	}
+	mo.orders[*limitIDPair.OrderID] = copyLimitIDPair(limitIDPair)
+	return
+}
+
+// GetOrder gets an order from an OrderID
+func (mo *MemoryLimitOrderbook) GetOrder(orderID *match.OrderID) (limOrder *match.LimitOrderIDPair, err error) {
+	// Like the other orderbooks, this is not nil even when the order can't be found, since
+	// the server looks through every book for the order
+	limOrder = new(match.LimitOrderIDPair)
+	limOrder.Order = new(match.LimitOrder)
+	if orderID == nil {
+		err = fmt.Errorf("Cannot get order with nil ID, please enter valid input")
+		return
+	}
+
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	var foundOrder *match.LimitOrderIDPair
+	var ok bool
+	if foundOrder, ok = mo.orders[*orderID]; !ok {
+		err = fmt.Errorf("Could not find order %x for GetOrder", orderID[:])
+		return
+	}
+	limOrder = copyLimitIDPair(foundOrder)
+	return
+}
+
+// CalculatePrice takes in a pair and returns the calculated price based on the orderbook. This is based on the midpoint of the spread.
+func (mo *MemoryLimitOrderbook) CalculatePrice() (price float64, err error) {
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+
+	var maxSell match.Price
+	var minBuy match.Price
+	var foundSell bool
+	var foundBuy bool
+	for _, pair := range mo.orders {
+		if pair.Order.Side == match.Buy {
+			if !foundBuy || pair.Price.Cmp(&minBuy) < 0 {
+				minBuy = pair.Price
+				foundBuy = true
+			}
+		} else {
+			if !foundSell || pair.Price.Cmp(&maxSell) > 0 {
+				maxSell = pair.Price
+				foundSell = true
+			}
+		}
+	}
+
+	if !foundSell {
+		err = fmt.Errorf("Error calculating price for limit CalculatePrice: no sell orders on the book")
+		return
+	}
+
+	if !foundBuy {
+		err = fmt.Errorf("Error calculating price for limit CalculatePrice: no buy orders on the book")
+		return
+	}
+
+	// The midpoint is only used to show a price, so a float is fine here
+	var minBuyFloat float64
+	if minBuyFloat, err = minBuy.ToFloat(); err != nil {
+		err = fmt.Errorf("Error converting min buy price to float for limit CalculatePrice: %s", err)
+		return
+	}
+
+	var maxSellFloat float64
+	if maxSellFloat, err = maxSell.ToFloat(); err != nil {
+		err = fmt.Errorf("Error converting max sell price to float for limit CalculatePrice: %s", err)
+		return
+	}
+
+	price = (minBuyFloat + maxSellFloat) / 2
+	return
+}
+
+// GetOrdersForPubkey gets orders for a specific pubkey.
+func (mo *MemoryLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[match.Price][]*match.LimitOrderIDPair, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get orders for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	orders = make(map[match.Price][]*match.LimitOrderIDPair)
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	for _, pair := range mo.orders {
+		if pair.Order.Pubkey == pkBytes {
+			orders[pair.Price] = append(orders[pair.Price], copyLimitIDPair(pair))
+		}
+	}
+	return
+}
+
+// ViewLimitOrderBook takes in a trading pair and returns the orderbook as a map
+func (mo *MemoryLimitOrderbook) ViewLimitOrderBook() (book map[match.Price][]*match.LimitOrderIDPair, err error) {
+	book = make(map[match.Price][]*match.LimitOrderIDPair)
+	mo.orderMtx.Lock()
+	defer mo.orderMtx.Unlock()
+	for _, pair := range mo.orders {
+		book[pair.Price] = append(book[pair.Price], copyLimitIDPair(pair))
+	}
+	return
+}
+
+// CreateLimitOrderbookMap creates a map of pair to limit orderbook, given a list of pairs.
+func CreateLimitOrderbookMap(pairList []*match.Pair) (bookMap map[match.Pair]match.LimitOrderbook, err error) {
+
+	bookMap = make(map[match.Pair]match.LimitOrderbook)
+	var curLimBook match.LimitOrderbook
+	for _, pair := range pairList {
+		if curLimBook, err = CreateLimitOrderbook(pair); err != nil {
+			err = fmt.Errorf("Error creating single limit orderbook while creating limit orderbook map: %s", err)
+			return
+		}
+		bookMap[*pair] = curLimBook
+	}
+
+	return
+}
@@ -0,0 +1,70 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/opencx/cxdb"
+)
+
+// nonceKey identifies a nonce used by a pubkey
+type nonceKey struct {
+	pubkey [33]byte
+	nonce  uint64
+}
+
+// MemoryNonceStore keeps the nonces used in order envelopes in memory
+type MemoryNonceStore struct {
+	// nonces are the expiry of the envelope each nonce was used in
+	nonces   map[nonceKey]uint64
+	nonceMtx *sync.Mutex
+}
+
+// CreateNonceStore creates a nonce store for every pubkey.
+func CreateNonceStore() (store cxdb.NonceStore, err error) {
+	store = &MemoryNonceStore{
+		nonces:   make(map[nonceKey]uint64),
+		nonceMtx: new(sync.Mutex),
+	}
+	return
+}
+
+// UseNonce adds a nonce to the store, returning an error if the pubkey has already used it
+func (mn *MemoryNonceStore) UseNonce(use *cxdb.NonceUse) (err error) {
+	if use == nil {
+		err = fmt.Errorf("Cannot use nil nonce, please enter valid input")
+		return
+	}
+
+	key := nonceKey{pubkey: use.Pubkey, nonce: use.Nonce}
+
+	mn.nonceMtx.Lock()
+	if _, ok := mn.nonces[key]; ok {
+		err = fmt.Errorf("Nonce %d has already been used by pubkey %x", use.Nonce, use.Pubkey)
+		mn.nonceMtx.Unlock()
+		return
+	}
+	mn.nonces[key] = use.Expiry
+	mn.nonceMtx.Unlock()
+	return
+}
+
+// NonceUsed returns true if the pubkey has used the nonce, and it hasn't been pruned
+func (mn *MemoryNonceStore) NonceUsed(pubkey [33]byte, nonce uint64) (used bool, err error) {
+	mn.nonceMtx.Lock()
+	_, used = mn.nonces[nonceKey{pubkey: pubkey, nonce: nonce}]
+	mn.nonceMtx.Unlock()
+	return
+}
+
+// PruneNonces removes the nonces for envelopes that expired at or before the unix time before
+func (mn *MemoryNonceStore) PruneNonces(before uint64) (err error) {
+	mn.nonceMtx.Lock()
+	for key, expiry := range mn.nonces {
+		if expiry <= before {
+			delete(mn.nonces, key)
+		}
+	}
+	mn.nonceMtx.Unlock()
+	return
+}
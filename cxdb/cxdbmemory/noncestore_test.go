@@ -0,0 +1,72 @@
+package cxdbmemory
+
+import (
+	"testing"
+
+	"github.com/mit-dci/opencx/cxdb"
+)
+
+func TestNonceStore(t *testing.T) {
+	var err error
+
+	var store cxdb.NonceStore
+	if store, err = CreateNonceStore(); err != nil {
+		t.Errorf("Error creating nonce store: %s", err)
+		return
+	}
+
+	use := &cxdb.NonceUse{Pubkey: [33]byte{0x02}, Nonce: 1, Expiry: 100}
+	if err = store.UseNonce(use); err != nil {
+		t.Errorf("Error using nonce: %s", err)
+		return
+	}
+
+	if err = store.UseNonce(use); err == nil {
+		t.Errorf("Nonce should not have been usable twice")
+		return
+	}
+
+	var used bool
+	if used, err = store.NonceUsed(use.Pubkey, use.Nonce+1); err != nil {
+		t.Errorf("Error checking unused nonce: %s", err)
+		return
+	}
+
+	if used {
+		t.Errorf("Nonce that was never used should not be used")
+		return
+	}
+
+	// Nonces are only pruned once their envelope expires
+	if err = store.PruneNonces(use.Expiry - 1); err != nil {
+		t.Errorf("Error pruning nonces: %s", err)
+		return
+	}
+
+	if used, err = store.NonceUsed(use.Pubkey, use.Nonce); err != nil {
+		t.Errorf("Error checking used nonce: %s", err)
+		return
+	}
+
+	if !used {
+		t.Errorf("Nonce should not have been pruned before its envelope expired")
+		return
+	}
+
+	if err = store.PruneNonces(use.Expiry); err != nil {
+		t.Errorf("Error pruning nonces: %s", err)
+		return
+	}
+
+	if used, err = store.NonceUsed(use.Pubkey, use.Nonce); err != nil {
+		t.Errorf("Error checking pruned nonce: %s", err)
+		return
+	}
+
+	if used {
+		t.Errorf("Nonce should have been pruned once its envelope expired")
+		return
+	}
+
+	return
+}
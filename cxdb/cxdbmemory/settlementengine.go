@@ -73,7 +73,7 @@ func (me *MemorySettlementEngine) CheckValid(setExec *match.SettlementExecution)
 	me.balancesMtx.Lock()
 	curBal := me.balances[setExec.Pubkey]
 	me.balancesMtx.Unlock()
-	valid = setExec.Amount > curBal
+	valid = setExec.Amount <= curBal
 	return
 }
 
@@ -0,0 +1,93 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// The MemorySettlementStore handles all client-viewable balances relating to settlement, in memory.
+// Like the SQL settlement store, this is meant to be updated when the settlement engine returns.
+type MemorySettlementStore struct {
+	// Balances
+	balances    map[[33]byte]uint64
+	balancesMtx *sync.Mutex
+
+	// this coin
+	coin *coinparam.Params
+}
+
+// CreateSettlementStore creates a settlement store for a specific coin.
+func CreateSettlementStore(coin *coinparam.Params) (store cxdb.SettlementStore, err error) {
+	if coin == nil {
+		err = fmt.Errorf("Cannot create settlement store with nil coin, please enter valid input")
+		return
+	}
+
+	// Set values
+	ss := &MemorySettlementStore{
+		balances:    make(map[[33]byte]uint64),
+		balancesMtx: new(sync.Mutex),
+		coin:        coin,
+	}
+
+	// Now we actually set what we want
+	store = ss
+	return
+}
+
+// UpdateBalances updates the balances from the settlement executions
+func (ss *MemorySettlementStore) UpdateBalances(settlementResults []*match.SettlementResult) (err error) {
+	ss.balancesMtx.Lock()
+	defer ss.balancesMtx.Unlock()
+	for _, setResult := range settlementResults {
+		if setResult == nil || setResult.SuccessfulExec == nil {
+			err = fmt.Errorf("Error updating balances, settlement result or its execution is nil")
+			return
+		}
+		ss.balances[setResult.SuccessfulExec.Pubkey] = setResult.NewBal
+	}
+
+	return
+}
+
+// GetBalance gets the balance for a pubkey and an asset.
+func (ss *MemorySettlementStore) GetBalance(pubkey *koblitz.PublicKey) (balance uint64, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get balance for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	ss.balancesMtx.Lock()
+	defer ss.balancesMtx.Unlock()
+	var ok bool
+	if balance, ok = ss.balances[pkBytes]; !ok {
+		err = fmt.Errorf("Error getting balance: no balance for pubkey %x", pkBytes)
+		return
+	}
+
+	return
+}
+
+// CreateSettlementStoreMap creates a map of coin to settlement store, given a list of coins.
+func CreateSettlementStoreMap(coins []*coinparam.Params) (setMap map[*coinparam.Params]cxdb.SettlementStore, err error) {
+
+	setMap = make(map[*coinparam.Params]cxdb.SettlementStore)
+	var curSetStore cxdb.SettlementStore
+	for _, coin := range coins {
+		if curSetStore, err = CreateSettlementStore(coin); err != nil {
+			err = fmt.Errorf("Error creating single settlement store while creating settlement store map: %s", err)
+			return
+		}
+		setMap[coin] = curSetStore
+	}
+
+	return
+}
@@ -0,0 +1,180 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// MemoryStopOrderStore holds the stop orders for a pair in memory
+type MemoryStopOrderStore struct {
+	stops   map[match.OrderID]*match.StopOrderIDPair
+	stopMtx *sync.Mutex
+
+	// this pair
+	pair *match.Pair
+}
+
+// CreateStopOrderStore creates a stop order store for a specific pair.
+func CreateStopOrderStore(pair *match.Pair) (store cxdb.StopOrderStore, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create stop order store with nil pair, please enter valid input")
+		return
+	}
+
+	// Set values
+	ms := &MemoryStopOrderStore{
+		stops:   make(map[match.OrderID]*match.StopOrderIDPair),
+		stopMtx: new(sync.Mutex),
+		pair:    pair,
+	}
+
+	// Now we actually set what we want
+	store = ms
+	return
+}
+
+// AddStopOrder adds a stop order to the store
+func (ms *MemoryStopOrderStore) AddStopOrder(stop *match.StopOrderIDPair) (err error) {
+	if stop == nil || stop.OrderID == nil || stop.Order == nil {
+		err = fmt.Errorf("Cannot add nil stop order, please enter valid input")
+		return
+	}
+
+	if stop.Order.Order.TradingPair != *ms.pair {
+		err = fmt.Errorf("Cannot add stop order for pair %s to store for pair %s", stop.Order.Order.TradingPair.String(), ms.pair.String())
+		return
+	}
+
+	ms.stopMtx.Lock()
+	defer ms.stopMtx.Unlock()
+	if _, ok := ms.stops[*stop.OrderID]; ok {
+		err = fmt.Errorf("Stop order with ID %x already exists in the store", stop.OrderID[:])
+		return
+	}
+	ms.stops[*stop.OrderID] = stop
+
+	return
+}
+
+// RemoveStopOrder removes a stop order from the store, once it's triggered or cancelled
+func (ms *MemoryStopOrderStore) RemoveStopOrder(id *match.OrderID) (err error) {
+	if id == nil {
+		err = fmt.Errorf("Cannot remove stop order with nil ID, please enter valid input")
+		return
+	}
+
+	ms.stopMtx.Lock()
+	defer ms.stopMtx.Unlock()
+	if _, ok := ms.stops[*id]; !ok {
+		err = fmt.Errorf("Stop order with ID %x does not exist in the store", id[:])
+		return
+	}
+	delete(ms.stops, *id)
+
+	return
+}
+
+// GetStopOrder gets a stop order by ID
+func (ms *MemoryStopOrderStore) GetStopOrder(id *match.OrderID) (stop *match.StopOrderIDPair, err error) {
+	if id == nil {
+		err = fmt.Errorf("Cannot get stop order with nil ID, please enter valid input")
+		return
+	}
+
+	ms.stopMtx.Lock()
+	defer ms.stopMtx.Unlock()
+	var ok bool
+	if stop, ok = ms.stops[*id]; !ok {
+		err = fmt.Errorf("Stop order with ID %x does not exist in the store", id[:])
+		return
+	}
+
+	return
+}
+
+// GetStopOrdersForPubkey gets every stop order placed by a pubkey, in the order they were placed
+func (ms *MemoryStopOrderStore) GetStopOrdersForPubkey(pubkey *koblitz.PublicKey) (stops []*match.StopOrderIDPair, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get stop orders for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	ms.stopMtx.Lock()
+	for _, stop := range ms.stops {
+		if stop.Order.Order.Pubkey == pkBytes {
+			stops = append(stops, stop)
+		}
+	}
+	ms.stopMtx.Unlock()
+
+	sortStopOrders(stops)
+	return
+}
+
+// GetTriggeredStopOrders gets every stop order that is triggered by the last trade price, in the order
+// they were placed
+func (ms *MemoryStopOrderStore) GetTriggeredStopOrders(lastPrice *match.Price) (triggered []*match.StopOrderIDPair, err error) {
+	if lastPrice == nil {
+		err = fmt.Errorf("Cannot get triggered stop orders for nil price, please enter valid input")
+		return
+	}
+
+	ms.stopMtx.Lock()
+	for _, stop := range ms.stops {
+		if stop.Order.Triggered(lastPrice) {
+			triggered = append(triggered, stop)
+		}
+	}
+	ms.stopMtx.Unlock()
+
+	sortStopOrders(triggered)
+	return
+}
+
+// ViewStopOrders gets every stop order in the store, in the order they were placed
+func (ms *MemoryStopOrderStore) ViewStopOrders() (stops []*match.StopOrderIDPair, err error) {
+	ms.stopMtx.Lock()
+	for _, stop := range ms.stops {
+		stops = append(stops, stop)
+	}
+	ms.stopMtx.Unlock()
+
+	sortStopOrders(stops)
+	return
+}
+
+// sortStopOrders sorts stop orders by the time they were placed, and then by ID so the order is always
+// the same
+func sortStopOrders(stops []*match.StopOrderIDPair) {
+	sort.Slice(stops, func(i, j int) bool {
+		if !stops[i].Timestamp.Equal(stops[j].Timestamp) {
+			return stops[i].Timestamp.Before(stops[j].Timestamp)
+		}
+		return string(stops[i].OrderID[:]) < string(stops[j].OrderID[:])
+	})
+	return
+}
+
+// CreateStopOrderStoreMap creates a map of pair to stop order store, given a list of pairs.
+func CreateStopOrderStoreMap(pairList []*match.Pair) (storeMap map[match.Pair]cxdb.StopOrderStore, err error) {
+
+	storeMap = make(map[match.Pair]cxdb.StopOrderStore)
+	var curStopStore cxdb.StopOrderStore
+	for _, pair := range pairList {
+		if curStopStore, err = CreateStopOrderStore(pair); err != nil {
+			err = fmt.Errorf("Error creating single stop order store while creating stop order store map: %s", err)
+			return
+		}
+		storeMap[*pair] = curStopStore
+	}
+
+	return
+}
@@ -0,0 +1,121 @@
+package cxdbmemory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// MemoryTradeStore keeps the trades for a pair in memory
+type MemoryTradeStore struct {
+	// trades are in the order they were made
+	trades   []*match.Trade
+	tradeMtx *sync.Mutex
+
+	// this pair
+	pair *match.Pair
+}
+
+// CreateTradeStore creates a trade store for a specific pair.
+func CreateTradeStore(pair *match.Pair) (store cxdb.TradeStore, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create trade store with nil pair, please enter valid input")
+		return
+	}
+
+	// Set values
+	mt := &MemoryTradeStore{
+		tradeMtx: new(sync.Mutex),
+		pair:     pair,
+	}
+
+	// Now we actually set what we want
+	store = mt
+	return
+}
+
+// AddTrades adds trades to the store, in the order they were made
+func (mt *MemoryTradeStore) AddTrades(trades []*match.Trade) (err error) {
+	for _, trade := range trades {
+		if trade == nil {
+			err = fmt.Errorf("Cannot add nil trade, please enter valid input")
+			return
+		}
+
+		if trade.Pair != *mt.pair {
+			err = fmt.Errorf("Cannot add trade for pair %s to store for pair %s", trade.Pair.String(), mt.pair.String())
+			return
+		}
+	}
+
+	mt.tradeMtx.Lock()
+	for _, trade := range trades {
+		tradeCopy := new(match.Trade)
+		*tradeCopy = *trade
+		mt.trades = append(mt.trades, tradeCopy)
+	}
+	mt.tradeMtx.Unlock()
+
+	return
+}
+
+// GetRecentTrades gets the latest trades, newest first, and at most limit of them
+func (mt *MemoryTradeStore) GetRecentTrades(limit uint64) (trades []*match.Trade, err error) {
+	mt.tradeMtx.Lock()
+	for i := len(mt.trades) - 1; i >= 0 && uint64(len(trades)) < limit; i-- {
+		tradeCopy := new(match.Trade)
+		*tradeCopy = *mt.trades[i]
+		trades = append(trades, tradeCopy)
+	}
+	mt.tradeMtx.Unlock()
+
+	return
+}
+
+// GetTradesForPubkey gets the trades that an order placed by a pubkey was in, newest first, and at most
+// limit of them. If before isn't zero, only trades made at or before then are returned.
+func (mt *MemoryTradeStore) GetTradesForPubkey(pubkey *koblitz.PublicKey, before time.Time, limit uint64) (trades []*match.Trade, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get trades for nil pubkey, please enter valid input")
+		return
+	}
+
+	var pkBytes [33]byte
+	copy(pkBytes[:], pubkey.SerializeCompressed())
+
+	mt.tradeMtx.Lock()
+	for i := len(mt.trades) - 1; i >= 0 && uint64(len(trades)) < limit; i-- {
+		if !before.IsZero() && mt.trades[i].Timestamp.After(before) {
+			continue
+		}
+
+		if mt.trades[i].HasPubkey(pkBytes) {
+			tradeCopy := new(match.Trade)
+			*tradeCopy = *mt.trades[i]
+			trades = append(trades, tradeCopy)
+		}
+	}
+	mt.tradeMtx.Unlock()
+
+	return
+}
+
+// CreateTradeStoreMap creates a map of pair to trade store, given a list of pairs.
+func CreateTradeStoreMap(pairList []*match.Pair) (storeMap map[match.Pair]cxdb.TradeStore, err error) {
+
+	storeMap = make(map[match.Pair]cxdb.TradeStore)
+	var curTradeStore cxdb.TradeStore
+	for _, pair := range pairList {
+		if curTradeStore, err = CreateTradeStore(pair); err != nil {
+			err = fmt.Errorf("Error creating single trade store while creating trade store map: %s", err)
+			return
+		}
+		storeMap[*pair] = curTradeStore
+	}
+
+	return
+}
@@ -32,7 +32,7 @@ type SQLAuctionEngine struct {
 
 // The schema for the auction orderbook
 const (
-	auctionEngineSchema = "pubkey VARBINARY(66), side TEXT, price DOUBLE(30, 2) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), auctionID VARBINARY(64), nonce VARBINARY(4), sig BLOB, hashedOrder VARBINARY(64), PRIMARY KEY (hashedOrder)"
+	auctionEngineSchema = "pubkey VARBINARY(66), side TEXT, priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), auctionID VARBINARY(64), nonce VARBINARY(4), sig BLOB, hashedOrder VARBINARY(64), PRIMARY KEY (hashedOrder)"
 )
 
 // CreateAuctionEngineWithConf creates an auction engine, sets up the connection and tables, and returns the auctionengine interface.
@@ -186,7 +186,7 @@ func (ae *SQLAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auction
 	// Do these two things beforehand so we don't have to rollback any tx's
 
 	// calculate price
-	var price float64
+	var price *match.Price
 	if price, err = order.Price(); err != nil {
 		err = fmt.Errorf("Error getting price from order while placing order: %s", err)
 		return
@@ -220,7 +220,7 @@ func (ae *SQLAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auction
 
 	logging.Infof("Placing order %s!", order)
 
-	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%s', %f, %d, %d, '%x', '%x', '%x', '%x');", ae.pair.String(), order.Pubkey, order.Side, price, order.AmountHave, order.AmountWant, order.AuctionID, order.Nonce, order.Signature, hashedOrder)
+	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%s', %d, %d, %d, %d, '%x', '%x', '%x', '%x');", ae.pair.String(), order.Pubkey, order.Side, price.AmountWant, price.AmountHave, order.AmountHave, order.AmountWant, order.AuctionID, order.Nonce, order.Signature, hashedOrder)
 	if _, err = tx.Exec(insertOrderQuery); err != nil {
 		logging.Errorf("Bad query run: %s", insertOrderQuery)
 		err = fmt.Errorf("Error placing order into db for placeauctionorder: %s", err)
@@ -232,7 +232,7 @@ func (ae *SQLAuctionEngine) PlaceAuctionOrder(order *match.AuctionOrder, auction
 	// Finally, set the auction order / id pair
 	idRes = &match.AuctionOrderIDPair{
 		Order: order,
-		Price: price,
+		Price: *price,
 	}
 	copy(idRes.OrderID[:], hashedOrder)
 
@@ -348,7 +348,7 @@ func (ae *SQLAuctionEngine) MatchAuctionOrders(auctionID *match.AuctionID) (orde
 	}()
 
 	// map representation of orderbook
-	var book map[float64][]*match.AuctionOrderIDPair
+	var book map[match.Price][]*match.AuctionOrderIDPair
 	if book, err = ae.getOrdersTx(auctionID, tx); err != nil {
 		err = fmt.Errorf("Error viewing orderbook tx for clearing matching algorithm tx: %s", err)
 		return
@@ -375,20 +375,20 @@ func (ae *SQLAuctionEngine) MatchAuctionOrders(auctionID *match.AuctionID) (orde
 }
 
 // getOrdersTx gets all of the orders for the auction ID
-func (ae *SQLAuctionEngine) getOrdersTx(auctionID *match.AuctionID, tx *sql.Tx) (orderbook map[float64][]*match.AuctionOrderIDPair, err error) {
+func (ae *SQLAuctionEngine) getOrdersTx(auctionID *match.AuctionID, tx *sql.Tx) (orderbook map[match.Price][]*match.AuctionOrderIDPair, err error) {
 	if ae.DBHandler == nil {
 		err = fmt.Errorf("Error, cannot get orders for nil dbhandler, please set up auction engine correctly")
 		return
 	}
 
-	orderbook = make(map[float64][]*match.AuctionOrderIDPair)
+	orderbook = make(map[match.Price][]*match.AuctionOrderIDPair)
 	if _, err = tx.Exec("USE " + ae.auctionOrderSchema + ";"); err != nil {
 		err = fmt.Errorf("Error using auction schema for viewauctionorderbook: %s", err)
 		return
 	}
 
 	var rows *sql.Rows
-	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, price, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE auctionID = '%x';", ae.pair, auctionID)
+	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE auctionID = '%x';", ae.pair, auctionID)
 	if rows, err = tx.Query(selectOrderQuery); err != nil {
 		err = fmt.Errorf("Error getting orders from db for viewauctionorderbook: %s", err)
 		return
@@ -414,13 +414,13 @@ func (ae *SQLAuctionEngine) getOrdersTx(auctionID *match.AuctionID, tx *sql.Tx)
 	var nonceBytes []byte
 	var sigBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 
 	for rows.Next() {
 		// scan the things we can into this order
 		thisOrder = new(match.AuctionOrder)
 		thisOrderPair = new(match.AuctionOrderIDPair)
-		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
+		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice.AmountWant, &thisPrice.AmountHave, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
 			err = fmt.Errorf("Error scanning into order for viewauctionorderbook: %s", err)
 			return
 		}
@@ -31,7 +31,7 @@ type SQLAuctionOrderbook struct {
 
 // The schema for the auction orderbook
 const (
-	auctionOrderbookSchema = "pubkey VARBINARY(66), side TEXT, price DOUBLE(30, 2) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), auctionID VARBINARY(64), nonce VARBINARY(4), sig BLOB, hashedOrder VARBINARY(64), PRIMARY KEY (hashedOrder)"
+	auctionOrderbookSchema = "pubkey VARBINARY(66), side TEXT, priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), auctionID VARBINARY(64), nonce VARBINARY(4), sig BLOB, hashedOrder VARBINARY(64), PRIMARY KEY (hashedOrder)"
 )
 
 // CreateAuctionOrderbook creates a auction orderbook based on a pair
@@ -272,7 +272,7 @@ func (ao *SQLAuctionOrderbook) UpdateBookPlace(auctionIDPair *match.AuctionOrder
 
 	logging.Infof("Placing order in orderbook: \n%s", auctionIDPair.Order)
 
-	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%s', %f, %d, %d, '%x', '%x', '%x', '%x');", ao.pair.String(), auctionIDPair.Order.Pubkey, auctionIDPair.Order.Side, auctionIDPair.Price, auctionIDPair.Order.AmountHave, auctionIDPair.Order.AmountWant, auctionIDPair.Order.AuctionID, auctionIDPair.Order.Nonce, auctionIDPair.Order.Signature, auctionIDPair.OrderID)
+	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%s', %d, %d, %d, %d, '%x', '%x', '%x', '%x');", ao.pair.String(), auctionIDPair.Order.Pubkey, auctionIDPair.Order.Side, auctionIDPair.Price.AmountWant, auctionIDPair.Price.AmountHave, auctionIDPair.Order.AmountHave, auctionIDPair.Order.AmountWant, auctionIDPair.Order.AuctionID, auctionIDPair.Order.Nonce, auctionIDPair.Order.Signature, auctionIDPair.OrderID)
 	if _, err = tx.Exec(insertOrderQuery); err != nil {
 		err = fmt.Errorf("Error placing order into db for UpdateBookPlace: %s", err)
 		return
@@ -311,7 +311,7 @@ func (ao *SQLAuctionOrderbook) GetOrder(orderID *match.OrderID) (aucOrder *match
 
 	// This is just a modified GetOrdersForPubkey
 	var row *sql.Row
-	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, price, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE hashedOrder='%x';", ao.pair, orderID)
+	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE hashedOrder='%x';", ao.pair, orderID)
 	// Remember: errors for this are deferred to scan
 	row = tx.QueryRow(selectOrderQuery)
 
@@ -323,7 +323,7 @@ func (ao *SQLAuctionOrderbook) GetOrder(orderID *match.OrderID) (aucOrder *match
 	var hashedOrderBytes []byte
 
 	// scan the things we can into this order
-	if err = row.Scan(&pkBytes, &aucOrder.Order.Side, &aucOrder.Price, &aucOrder.Order.AmountHave, &aucOrder.Order.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
+	if err = row.Scan(&pkBytes, &aucOrder.Order.Side, &aucOrder.Price.AmountWant, &aucOrder.Price.AmountHave, &aucOrder.Order.AmountHave, &aucOrder.Order.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
 		err = fmt.Errorf("Error scanning into order for GetOrdersForPubkey: %s", err)
 		return
 	}
@@ -382,7 +382,7 @@ func (ao *SQLAuctionOrderbook) CalculatePrice(auctionID *match.AuctionID) (price
 	*buySide = match.Buy
 	// First get the max buy price and max sell price
 	var maxSellRow *sql.Row
-	getMaxSellPrice := fmt.Sprintf("SELECT MAX(price) FROM %s WHERE side='%s' AND auctionID='%x';", ao.pair.String(), sellSide.String(), auctionID)
+	getMaxSellPrice := fmt.Sprintf("SELECT MAX(priceWant / priceHave) FROM %s WHERE side='%s' AND auctionID='%x';", ao.pair.String(), sellSide.String(), auctionID)
 	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
 	maxSellRow = tx.QueryRow(getMaxSellPrice)
 
@@ -393,7 +393,7 @@ func (ao *SQLAuctionOrderbook) CalculatePrice(auctionID *match.AuctionID) (price
 	}
 
 	var minBuyRow *sql.Row
-	getminBuyPrice := fmt.Sprintf("SELECT MIN(price) FROM %s WHERE side='%s' AND auctionID='%x';", ao.pair.String(), buySide.String(), auctionID)
+	getminBuyPrice := fmt.Sprintf("SELECT MIN(priceWant / priceHave) FROM %s WHERE side='%s' AND auctionID='%x';", ao.pair.String(), buySide.String(), auctionID)
 	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
 	minBuyRow = tx.QueryRow(getminBuyPrice)
 
@@ -408,9 +408,9 @@ func (ao *SQLAuctionOrderbook) CalculatePrice(auctionID *match.AuctionID) (price
 }
 
 // GetOrdersForPubkey gets orders for a specific pubkey.
-func (ao *SQLAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[float64][]*match.AuctionOrderIDPair, err error) {
+func (ao *SQLAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[match.Price][]*match.AuctionOrderIDPair, err error) {
 	// Make the book!!!!
-	orders = make(map[float64][]*match.AuctionOrderIDPair)
+	orders = make(map[match.Price][]*match.AuctionOrderIDPair)
 
 	// Transaction so we're acid
 	var tx *sql.Tx
@@ -435,7 +435,7 @@ func (ao *SQLAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (or
 
 	// This is just a modified viewauctionorderbook
 	var rows *sql.Rows
-	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, price, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE pubkey='%x';", ao.pair, pubkey.SerializeCompressed())
+	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s WHERE pubkey='%x';", ao.pair, pubkey.SerializeCompressed())
 	if rows, err = tx.Query(selectOrderQuery); err != nil {
 		err = fmt.Errorf("Error getting orders from db for GetOrdersForPubkey: %s", err)
 		return
@@ -451,13 +451,13 @@ func (ao *SQLAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (or
 	var nonceBytes []byte
 	var sigBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 
 	for rows.Next() {
 		// scan the things we can into this order
 		thisOrder = new(match.AuctionOrder)
 		thisOrderPair = new(match.AuctionOrderIDPair)
-		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
+		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice.AmountWant, &thisPrice.AmountHave, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
 			err = fmt.Errorf("Error scanning into order for GetOrdersForPubkey: %s", err)
 			return
 		}
@@ -496,9 +496,9 @@ func (ao *SQLAuctionOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (or
 }
 
 // ViewAuctionOrderbook takes in a trading pair and returns the orderbook as a map
-func (ao *SQLAuctionOrderbook) ViewAuctionOrderBook() (book map[float64][]*match.AuctionOrderIDPair, err error) {
+func (ao *SQLAuctionOrderbook) ViewAuctionOrderBook() (book map[match.Price][]*match.AuctionOrderIDPair, err error) {
 	// Make the book!!!!
-	book = make(map[float64][]*match.AuctionOrderIDPair)
+	book = make(map[match.Price][]*match.AuctionOrderIDPair)
 
 	// Transaction so we're acid
 	var tx *sql.Tx
@@ -522,7 +522,7 @@ func (ao *SQLAuctionOrderbook) ViewAuctionOrderBook() (book map[float64][]*match
 	}
 
 	var rows *sql.Rows
-	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, price, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s;", ao.pair)
+	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, amountHave, amountWant, auctionID, nonce, sig, hashedOrder FROM %s;", ao.pair)
 	if rows, err = tx.Query(selectOrderQuery); err != nil {
 		err = fmt.Errorf("Error getting orders from db for viewauctionorderbook: %s", err)
 		return
@@ -538,13 +538,13 @@ func (ao *SQLAuctionOrderbook) ViewAuctionOrderBook() (book map[float64][]*match
 	var nonceBytes []byte
 	var sigBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 
 	for rows.Next() {
 		// scan the things we can into this order
 		thisOrder = new(match.AuctionOrder)
 		thisOrderPair = new(match.AuctionOrderIDPair)
-		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
+		if err = rows.Scan(&pkBytes, &thisOrder.Side, &thisPrice.AmountWant, &thisPrice.AmountHave, &thisOrder.AmountHave, &thisOrder.AmountWant, &auctionIDBytes, &nonceBytes, &sigBytes, &hashedOrderBytes); err != nil {
 			err = fmt.Errorf("Error scanning into order for viewauctionorderbook: %s", err)
 			return
 		}
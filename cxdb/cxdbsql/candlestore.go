@@ -0,0 +1,360 @@
+package cxdbsql
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SQLCandleStore keeps the executions for a pair and the candles made from them in the trade schema, next
+// to the trades, so they are still there after the exchange restarts
+type SQLCandleStore struct {
+	DBHandler *sql.DB
+
+	// db username and password
+	dbUsername string
+	dbPassword string
+
+	// db host and port
+	dbAddr net.Addr
+
+	// trade schema name, which the candles are kept in
+	tradeSchema string
+
+	// this pair
+	pair *match.Pair
+}
+
+// The schemas for the executions and candles. Times are in nanoseconds, and the unique key keeps an
+// execution from being added to the candles twice when the journal is replayed. Interval is a keyword, so
+// the interval column is candleInterval.
+const (
+	executionStoreSchema = "seq BIGINT(64) UNSIGNED NOT NULL AUTO_INCREMENT, id VARBINARY(64), priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountWant BIGINT(64) UNSIGNED, amountHave BIGINT(64) UNSIGNED, time BIGINT(64), PRIMARY KEY (seq), UNIQUE KEY execution (id, time)"
+	candleStoreSchema    = "candleInterval VARCHAR(8), start BIGINT(64), openWant BIGINT(64) UNSIGNED, openHave BIGINT(64) UNSIGNED, highWant BIGINT(64) UNSIGNED, highHave BIGINT(64) UNSIGNED, lowWant BIGINT(64) UNSIGNED, lowHave BIGINT(64) UNSIGNED, closeWant BIGINT(64) UNSIGNED, closeHave BIGINT(64) UNSIGNED, volume BIGINT(64) UNSIGNED, quoteVolume BIGINT(64) UNSIGNED, executions BIGINT(64) UNSIGNED, PRIMARY KEY (candleInterval, start)"
+	candleColumns        = "candleInterval, start, openWant, openHave, highWant, highHave, lowWant, lowHave, closeWant, closeHave, volume, quoteVolume, executions"
+)
+
+// CreateCandleStoreWithConf creates a candle store for a specific pair, with a config.
+func CreateCandleStoreWithConf(pair *match.Pair, conf *dbsqlConfig) (store cxdb.CandleStore, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create candle store with nil pair, please enter valid input")
+		return
+	}
+
+	// Set the default conf
+	dbConfigSetup(conf)
+
+	// Resolve new address
+	var addr net.Addr
+	if addr, err = net.ResolveTCPAddr("tcp", net.JoinHostPort(conf.DBHost, fmt.Sprintf("%d", conf.DBPort))); err != nil {
+		err = fmt.Errorf("Couldn't resolve db address for CreateCandleStoreWithConf: %s", err)
+		return
+	}
+
+	// Set values
+	cs := &SQLCandleStore{
+		dbUsername:  conf.DBUsername,
+		dbPassword:  conf.DBPassword,
+		tradeSchema: conf.TradeSchemaName,
+		dbAddr:      addr,
+		pair:        pair,
+	}
+
+	if err = cs.setupCandleStoreTables(); err != nil {
+		err = fmt.Errorf("Error setting up candle store tables while creating store: %s", err)
+		return
+	}
+
+	// Now connect to the database and create the schemas / tables
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", cs.dbUsername, cs.dbPassword, cs.dbAddr.Network(), cs.dbAddr.String())
+	if cs.DBHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for CreateCandleStoreWithConf: %s", err)
+		return
+	}
+
+	// Make sure we can actually connect
+	if err = cs.DBHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// Now we actually set what we want
+	store = cs
+	return
+}
+
+// CreateCandleStore creates a candle store for a specific pair.
+func CreateCandleStore(pair *match.Pair) (store cxdb.CandleStore, err error) {
+
+	conf := new(dbsqlConfig)
+	*conf = *defaultConf
+
+	if store, err = CreateCandleStoreWithConf(pair, conf); err != nil {
+		err = fmt.Errorf("Error creating candle store with conf for CreateCandleStore: %s", err)
+		return
+	}
+	return
+}
+
+// setupCandleStoreTables sets up the tables needed for the candle store.
+// This assumes everything else is set
+func (cs *SQLCandleStore) setupCandleStoreTables() (err error) {
+
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", cs.dbUsername, cs.dbPassword, cs.dbAddr.Network(), cs.dbAddr.String())
+	var rootHandler *sql.DB
+	if rootHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for setup candle store tables: %s", err)
+		return
+	}
+
+	// when we're done close please
+	defer rootHandler.Close()
+
+	if err = rootHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// We do this in a transaction because it's more than one operation
+	var tx *sql.Tx
+	if tx, err = rootHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error when beginning transaction for setup candle store tables: %s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while creating candle store tables: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Now create the schema
+	if _, err = tx.Exec("CREATE SCHEMA IF NOT EXISTS " + cs.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error creating schema for setup candle store tables: %s", err)
+		return
+	}
+
+	// use the schema
+	if _, err = tx.Exec("USE " + cs.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Could not use %s schema: %s", cs.tradeSchema, err)
+		return
+	}
+
+	createTableQuery := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_executions (%s);", cs.pair.String(), executionStoreSchema)
+	if _, err = tx.Exec(createTableQuery); err != nil {
+		err = fmt.Errorf("Error creating execution table: %s", err)
+		return
+	}
+
+	createTableQuery = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_candles (%s);", cs.pair.String(), candleStoreSchema)
+	if _, err = tx.Exec(createTableQuery); err != nil {
+		err = fmt.Errorf("Error creating candle table: %s", err)
+		return
+	}
+	return
+}
+
+// AddExecutions adds executions to the store, in the order they were made, and updates the candles they
+// are in. Executions that are already in the store are ignored.
+func (cs *SQLCandleStore) AddExecutions(execs []*match.PriceExecution) (err error) {
+	for _, exec := range execs {
+		if exec == nil {
+			err = fmt.Errorf("Cannot add nil execution, please enter valid input")
+			return
+		}
+
+		if exec.Pair != *cs.pair {
+			err = fmt.Errorf("Cannot add execution for pair %s to store for pair %s", exec.Pair.String(), cs.pair.String())
+			return
+		}
+	}
+
+	var tx *sql.Tx
+	if tx, err = cs.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while adding executions: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while adding executions: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + cs.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using trade schema for AddExecutions: %s", err)
+		return
+	}
+
+	for _, exec := range execs {
+		insertExecQuery := fmt.Sprintf("INSERT IGNORE INTO %s_executions (id, priceWant, priceHave, amountWant, amountHave, time) VALUES ('%x', %d, %d, %d, %d, %d);", cs.pair.String(), exec.ID, exec.Price.AmountWant, exec.Price.AmountHave, exec.AmountWant, exec.AmountHave, exec.Timestamp.UnixNano())
+		var res sql.Result
+		if res, err = tx.Exec(insertExecQuery); err != nil {
+			err = fmt.Errorf("Error inserting execution for AddExecutions: %s", err)
+			return
+		}
+
+		// If nothing was inserted then the execution is already in the candles
+		var inserted int64
+		if inserted, err = res.RowsAffected(); err != nil {
+			err = fmt.Errorf("Error getting rows affected for AddExecutions: %s", err)
+			return
+		}
+
+		if inserted == 0 {
+			continue
+		}
+
+		for _, interval := range match.CandleIntervals {
+			if err = cs.addToCandleTx(interval, exec, tx); err != nil {
+				err = fmt.Errorf("Error adding execution to %s candle: %s", interval.String(), err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// addToCandleTx adds an execution to the candle it's in for an interval, creating the candle if it doesn't
+// exist yet
+func (cs *SQLCandleStore) addToCandleTx(interval match.CandleInterval, exec *match.PriceExecution, tx *sql.Tx) (err error) {
+	start := interval.Start(exec.Timestamp)
+	getCandleQuery := fmt.Sprintf("SELECT %s FROM %s_candles WHERE candleInterval='%s' AND start=%d FOR UPDATE;", candleColumns, cs.pair.String(), interval.String(), start.UnixNano())
+
+	var candles []*match.Candle
+	if candles, err = cs.queryCandlesTx(getCandleQuery, tx); err != nil {
+		err = fmt.Errorf("Error querying candle for addToCandleTx: %s", err)
+		return
+	}
+
+	var candle *match.Candle
+	if len(candles) == 0 {
+		if candle, err = match.NewCandle(interval, exec); err != nil {
+			return
+		}
+
+		insertCandleQuery := fmt.Sprintf("INSERT INTO %s_candles (%s) VALUES ('%s', %d, %d, %d, %d, %d, %d, %d, %d, %d, %d, %d, %d);", cs.pair.String(), candleColumns, interval.String(), start.UnixNano(), candle.Open.AmountWant, candle.Open.AmountHave, candle.High.AmountWant, candle.High.AmountHave, candle.Low.AmountWant, candle.Low.AmountHave, candle.Close.AmountWant, candle.Close.AmountHave, candle.Volume, candle.QuoteVolume, candle.Executions)
+		if _, err = tx.Exec(insertCandleQuery); err != nil {
+			err = fmt.Errorf("Error inserting candle for addToCandleTx: %s", err)
+			return
+		}
+		return
+	}
+
+	candle = candles[0]
+	if err = candle.AddExecution(exec); err != nil {
+		return
+	}
+
+	updateCandleQuery := fmt.Sprintf("UPDATE %s_candles SET highWant=%d, highHave=%d, lowWant=%d, lowHave=%d, closeWant=%d, closeHave=%d, volume=%d, quoteVolume=%d, executions=%d WHERE candleInterval='%s' AND start=%d;", cs.pair.String(), candle.High.AmountWant, candle.High.AmountHave, candle.Low.AmountWant, candle.Low.AmountHave, candle.Close.AmountWant, candle.Close.AmountHave, candle.Volume, candle.QuoteVolume, candle.Executions, interval.String(), start.UnixNano())
+	if _, err = tx.Exec(updateCandleQuery); err != nil {
+		err = fmt.Errorf("Error updating candle for addToCandleTx: %s", err)
+		return
+	}
+	return
+}
+
+// GetCandles gets the candles for an interval that start at or after start and before end, oldest first.
+// If end is zero there is no end.
+func (cs *SQLCandleStore) GetCandles(interval match.CandleInterval, start time.Time, end time.Time) (candles []*match.Candle, err error) {
+	if interval.Duration() == 0 {
+		err = fmt.Errorf("Cannot get candles for invalid interval %d", interval)
+		return
+	}
+
+	getCandlesQuery := fmt.Sprintf("SELECT %s FROM %s_candles WHERE candleInterval='%s' AND start>=%d", candleColumns, cs.pair.String(), interval.String(), start.UnixNano())
+	if !end.IsZero() {
+		getCandlesQuery += fmt.Sprintf(" AND start<%d", end.UnixNano())
+	}
+	getCandlesQuery += " ORDER BY start ASC;"
+
+	var tx *sql.Tx
+	if tx, err = cs.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while getting candles: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while getting candles: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + cs.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using trade schema for GetCandles: %s", err)
+		return
+	}
+
+	if candles, err = cs.queryCandlesTx(getCandlesQuery, tx); err != nil {
+		err = fmt.Errorf("Error querying candles for GetCandles: %s", err)
+		return
+	}
+	return
+}
+
+// queryCandlesTx runs a query that selects candleColumns from the candle table and scans them
+func (cs *SQLCandleStore) queryCandlesTx(query string, tx *sql.Tx) (candles []*match.Candle, err error) {
+	var rows *sql.Rows
+	if rows, err = tx.Query(query); err != nil {
+		err = fmt.Errorf("Error querying for candles: %s", err)
+		return
+	}
+
+	for rows.Next() {
+		var intervalString string
+		var candleStart int64
+		candle := &match.Candle{
+			Pair: *cs.pair,
+		}
+		if err = rows.Scan(&intervalString, &candleStart, &candle.Open.AmountWant, &candle.Open.AmountHave, &candle.High.AmountWant, &candle.High.AmountHave, &candle.Low.AmountWant, &candle.Low.AmountHave, &candle.Close.AmountWant, &candle.Close.AmountHave, &candle.Volume, &candle.QuoteVolume, &candle.Executions); err != nil {
+			err = fmt.Errorf("Error scanning candle rows: %s", err)
+			rows.Close()
+			return
+		}
+
+		if err = candle.Interval.FromString(intervalString); err != nil {
+			err = fmt.Errorf("Error getting candle interval from string: %s", err)
+			rows.Close()
+			return
+		}
+
+		candle.Start = time.Unix(0, candleStart).UTC()
+		candles = append(candles, candle)
+	}
+
+	if err = rows.Close(); err != nil {
+		err = fmt.Errorf("Error closing candle rows: %s", err)
+		return
+	}
+	return
+}
+
+// CreateCandleStoreMap creates a map of pair to candle store, given a list of pairs.
+func CreateCandleStoreMap(pairList []*match.Pair) (storeMap map[match.Pair]cxdb.CandleStore, err error) {
+
+	storeMap = make(map[match.Pair]cxdb.CandleStore)
+	var curCandleStore cxdb.CandleStore
+	for _, pair := range pairList {
+		if curCandleStore, err = CreateCandleStore(pair); err != nil {
+			err = fmt.Errorf("Error creating single candle store while creating candle store map: %s", err)
+			return
+		}
+		storeMap[*pair] = curCandleStore
+	}
+
+	return
+}
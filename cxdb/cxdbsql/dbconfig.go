@@ -34,11 +34,14 @@ type dbsqlConfig struct {
 	AuctionOrderSchemaName    string `long:"auctionorderschema" description:"Name of schema for auction orderbook"`
 	OrderSchemaName           string `long:"orderschema" description:"Name of schema for limit orderbook"`
 	PeerSchemaName            string `long:"peerschema" description:"Name of schema for peer storage"`
+	TradeSchemaName           string `long:"tradeschema" description:"Name of schema for trade history"`
+	NonceSchemaName           string `long:"nonceschema" description:"Name of schema for nonces used in order envelopes"`
 
 	// database table names
 	PuzzleTableName       string `long:"puzzletable" description:"Name of table for puzzle orderbooks"`
 	AuctionOrderTableName string `long:"auctionordertable" description:"Name of table for auction orders"`
 	PeerTableName         string `long:"peertable" description:"Name of table for peer storage"`
+	NonceTableName        string `long:"noncetable" description:"Name of table for nonces used in order envelopes"`
 }
 
 // Let these be turned into config things at some point
@@ -63,11 +66,14 @@ var (
 	defaultAuctionOrderSchema    = "auctionorder"
 	defaultOrderSchema           = "orders"
 	defaultPeerSchema            = "peers"
+	defaultTradeSchema           = "trades"
+	defaultNonceSchema           = "nonces"
 
 	// tables
 	defaultAuctionOrderTable = "auctionorders"
 	defaultPuzzleTable       = "puzzles"
 	defaultPeerTable         = "opencxpeers"
+	defaultNonceTable        = "usednonces"
 
 	// Set defaults
 	defaultConf = &dbsqlConfig{
@@ -92,11 +98,14 @@ var (
 		AuctionOrderSchemaName:    defaultAuctionOrderSchema,
 		OrderSchemaName:           defaultOrderSchema,
 		PeerSchemaName:            defaultPeerSchema,
+		TradeSchemaName:           defaultTradeSchema,
+		NonceSchemaName:           defaultNonceSchema,
 
 		// tables
 		PuzzleTableName:       defaultPuzzleTable,
 		AuctionOrderTableName: defaultAuctionOrderTable,
 		PeerTableName:         defaultPeerTable,
+		NonceTableName:        defaultNonceTable,
 	}
 )
 
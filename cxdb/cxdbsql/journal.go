@@ -0,0 +1,136 @@
+package cxdbsql
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// When the exchange uses a journal, the journal is the record of everything that happened, and the SQL
+// schemas for what it records are rebuilt from it every time the exchange starts. That way a crash in the
+// middle of applying an entry to the different schemas can't leave them disagreeing, since what was
+// applied is thrown away and the entry is applied again from the journal.
+
+// journaledSchemas returns the schemas for the balances, orders, trades, candles, and nonces that the
+// journal records. Deposit addresses and pending deposits are not in the journal.
+func journaledSchemas(conf *dbsqlConfig) (schemas []string) {
+	schemas = []string{
+		conf.BalanceSchemaName,
+		conf.ReadOnlyBalanceSchemaName,
+		conf.OrderSchemaName,
+		conf.ReadOnlyOrderSchemaName,
+		conf.TradeSchemaName,
+		conf.NonceSchemaName,
+	}
+	return
+}
+
+// openRootHandler opens a connection to the database without a schema, making sure it can connect
+func openRootHandler(conf *dbsqlConfig) (rootHandler *sql.DB, err error) {
+	// Resolve new address
+	var addr net.Addr
+	if addr, err = net.ResolveTCPAddr("tcp", net.JoinHostPort(conf.DBHost, fmt.Sprintf("%d", conf.DBPort))); err != nil {
+		err = fmt.Errorf("Couldn't resolve db address for openRootHandler: %s", err)
+		return
+	}
+
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", conf.DBUsername, conf.DBPassword, addr.Network(), addr.String())
+	if rootHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for openRootHandler: %s", err)
+		return
+	}
+
+	if err = rootHandler.Ping(); err != nil {
+		rootHandler.Close()
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+	return
+}
+
+// JournaledStateEmpty returns true if none of the tables for what the journal records have any rows. If
+// they do and the journal is empty, they have state that was never journaled, which would be lost if they
+// were rebuilt from the journal.
+func JournaledStateEmpty() (empty bool, err error) {
+	conf := new(dbsqlConfig)
+	*conf = *defaultConf
+
+	// set the default conf
+	dbConfigSetup(conf)
+
+	var rootHandler *sql.DB
+	if rootHandler, err = openRootHandler(conf); err != nil {
+		err = fmt.Errorf("Error opening database for JournaledStateEmpty: %s", err)
+		return
+	}
+
+	// when we're done close please
+	defer rootHandler.Close()
+
+	for _, schema := range journaledSchemas(conf) {
+		var rows *sql.Rows
+		if rows, err = rootHandler.Query("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA=?;", schema); err != nil {
+			err = fmt.Errorf("Error getting tables in %s for JournaledStateEmpty: %s", schema, err)
+			return
+		}
+
+		var tables []string
+		for rows.Next() {
+			var table string
+			if err = rows.Scan(&table); err != nil {
+				rows.Close()
+				err = fmt.Errorf("Error scanning table name for JournaledStateEmpty: %s", err)
+				return
+			}
+			tables = append(tables, table)
+		}
+		rows.Close()
+
+		for _, table := range tables {
+			var hasRows bool
+			if err = rootHandler.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s.%s);", schema, table)).Scan(&hasRows); err != nil {
+				err = fmt.Errorf("Error checking for rows in %s.%s for JournaledStateEmpty: %s", schema, table, err)
+				return
+			}
+
+			if hasRows {
+				return
+			}
+		}
+	}
+
+	empty = true
+	return
+}
+
+// DropJournaledState drops the schemas for everything the journal records, so they can be rebuilt by
+// replaying the journal. This has to be called before any of the stores for them are created, since
+// creating them creates the schemas again.
+func DropJournaledState() (err error) {
+	conf := new(dbsqlConfig)
+	*conf = *defaultConf
+
+	// set the default conf
+	dbConfigSetup(conf)
+
+	var rootHandler *sql.DB
+	if rootHandler, err = openRootHandler(conf); err != nil {
+		err = fmt.Errorf("Error opening database for DropJournaledState: %s", err)
+		return
+	}
+
+	// when we're done close please
+	defer rootHandler.Close()
+
+	// Schemas can't be dropped in a transaction, but dropping one that's already gone is fine, so this can
+	// just be run again if it fails
+	for _, schema := range journaledSchemas(conf) {
+		if _, err = rootHandler.Exec("DROP SCHEMA IF EXISTS " + schema + ";"); err != nil {
+			err = fmt.Errorf("Error dropping %s schema for DropJournaledState: %s", schema, err)
+			return
+		}
+	}
+	return
+}
@@ -5,12 +5,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
-	"strconv"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
-	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 	"golang.org/x/crypto/sha3"
 )
@@ -35,7 +33,7 @@ type SQLLimitEngine struct {
 
 // The schema for the limit orderbook -- TODO: THE PRICE SCHEMA SHOULD BE CONFIGURED BASED ON DESIRED PRECISION, WHICH SHOULD BE ENFORCED BY OUR TYPES AS WELL
 const (
-	limitEngineSchema = "pubkey VARBINARY(66), orderID VARBINARY(64), side TEXT, price DOUBLE(32,16) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), time TIMESTAMP"
+	limitEngineSchema = "pubkey VARBINARY(66), orderID VARBINARY(64), side TEXT, priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), time TIMESTAMP, timeInForce TEXT, expiry BIGINT(64) UNSIGNED, selfTrade TEXT"
 	sqlTimeFormat     = "2006-01-02 15:04:05"
 )
 
@@ -207,8 +205,8 @@ func (le *SQLLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match
 	hasher.Write(orderBytes)
 	hashedOrder := hasher.Sum(nil)
 
-	// calculate price
-	var price float64
+	// calculate price, this will also reject orders where either amount is zero
+	var price *match.Price
 	if price, err = order.Price(); err != nil {
 		err = fmt.Errorf("Error getting price from order while placing order: %s", err)
 		return
@@ -218,7 +216,7 @@ func (le *SQLLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match
 	loid := &match.LimitOrderIDPair{
 		OrderID:   new(match.OrderID),
 		Order:     order,
-		Price:     price,
+		Price:     *price,
 		Timestamp: placementTime,
 	}
 
@@ -227,20 +225,6 @@ func (le *SQLLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match
 		return
 	}
 
-	// TODO: JANK ALERT, converting to string and back because that's how we insert our prices,
-	// by doing a janky sprintf. This is our janky way of making sure our janky insert is never broken.
-	var zeroCheckFloat float64
-	if zeroCheckFloat, err = strconv.ParseFloat(fmt.Sprintf("%.16f", price), 64); err != nil {
-		err = fmt.Errorf("Error parsing float: %s", err)
-		return
-	}
-
-	// if the price is less than a certain amount, auto-cancel the order
-	if zeroCheckFloat == float64(0) {
-		err = fmt.Errorf("Placing 0-valued order is not allowed")
-		return
-	}
-
 	var tx *sql.Tx
 	if tx, err = le.DBHandler.Begin(); err != nil {
 		err = fmt.Errorf("Error beginning transaction while placing order: \n%s", err)
@@ -261,7 +245,7 @@ func (le *SQLLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match
 		return
 	}
 
-	placeOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%x', '%s', %f, %d, %d, '%s');", le.pair.String(), order.Pubkey[:], hashedOrder, order.Side.String(), price, order.AmountHave, order.AmountWant, placementTimeFormatted)
+	placeOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%x', '%s', %d, %d, %d, %d, '%s', '%s', %d, '%s');", le.pair.String(), order.Pubkey[:], hashedOrder, order.Side.String(), price.AmountWant, price.AmountHave, order.AmountHave, order.AmountWant, placementTimeFormatted, order.TimeInForce.String(), order.Expiry, order.SelfTrade.String())
 	if _, err = tx.Exec(placeOrderQuery); err != nil {
 		err = fmt.Errorf("Error placing order into db for PlaceLimitOrder: %s", err)
 		return
@@ -271,6 +255,49 @@ func (le *SQLLimitEngine) PlaceLimitOrder(order *match.LimitOrder) (idRes *match
 	return
 }
 
+// RestoreLimitOrder puts an order that was already placed back into the engine, keeping its ID, price,
+// timestamp and amounts. This does not match orders.
+func (le *SQLLimitEngine) RestoreLimitOrder(idPair *match.LimitOrderIDPair) (err error) {
+	if idPair == nil || idPair.OrderID == nil || idPair.Order == nil {
+		err = fmt.Errorf("Cannot restore nil order, please enter valid input")
+		return
+	}
+
+	if le.DBHandler == nil {
+		err = fmt.Errorf("Cannot restore order with nil DBHandler, please set up limit engine correctly")
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = le.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while restoring order: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while restoring order: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + le.orderSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using order schema while restoring limit order: %s", err)
+		return
+	}
+
+	order := idPair.Order
+	restoreOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%x', '%s', %d, %d, %d, %d, '%s', '%s', %d, '%s');", le.pair.String(), order.Pubkey[:], idPair.OrderID[:], order.Side.String(), idPair.Price.AmountWant, idPair.Price.AmountHave, order.AmountHave, order.AmountWant, idPair.Timestamp.Format(sqlTimeFormat), order.TimeInForce.String(), order.Expiry, order.SelfTrade.String())
+	if _, err = tx.Exec(restoreOrderQuery); err != nil {
+		err = fmt.Errorf("Error inserting order into db for RestoreLimitOrder: %s", err)
+		return
+	}
+
+	return
+}
+
 // CancelLimitOrder cancels an auction order, this assumes that the auction order actually exists
 func (le *SQLLimitEngine) CancelLimitOrder(orderID *match.OrderID) (cancelled *match.CancelledOrder, cancelSettlement *match.SettlementExecution, err error) {
 
@@ -352,23 +379,24 @@ func (le *SQLLimitEngine) CancelLimitOrder(orderID *match.OrderID) (cancelled *m
 	return
 }
 
-// MatchLimitOrders matches limit orders based on price/time priority
-func (le *SQLLimitEngine) MatchLimitOrders() (orderExecs []*match.OrderExecution, settlementExecs []*match.SettlementExecution, err error) {
-	if le.DBHandler == nil {
-		err = fmt.Errorf("Cannot match orders for nil handler, please recreate engine")
+// ReplaceLimitOrder amends the amounts of an order in the engine, keeping its ID. The order keeps its time
+// priority if only its size is reduced. This does not match orders.
+func (le *SQLLimitEngine) ReplaceLimitOrder(orderID *match.OrderID, amountHave uint64, amountWant uint64) (replaced *match.LimitOrderIDPair, reserveChange *match.SettlementExecution, err error) {
+	if orderID == nil {
+		err = fmt.Errorf("Cannot replace order with nil ID, please enter valid input")
 		return
 	}
 
 	var tx *sql.Tx
 	if tx, err = le.DBHandler.Begin(); err != nil {
-		err = fmt.Errorf("Error when beginning transaction for MatchLimitOrders: %s", err)
+		err = fmt.Errorf("Error when beginning transaction for ReplaceLimitOrder: %s", err)
 		return
 	}
 
 	defer func() {
 		if err != nil {
 			tx.Rollback()
-			err = fmt.Errorf("Error for MatchLimitOrders: \n%s", err)
+			err = fmt.Errorf("Error for ReplaceLimitOrder: \n%s", err)
 			return
 		}
 		err = tx.Commit()
@@ -376,174 +404,171 @@ func (le *SQLLimitEngine) MatchLimitOrders() (orderExecs []*match.OrderExecution
 	}()
 
 	if _, err = tx.Exec("USE " + le.orderSchema + ";"); err != nil {
-		err = fmt.Errorf("Error using order schema while matching limit orders: %s", err)
+		err = fmt.Errorf("Error using order schema while replacing limit order: %s", err)
 		return
 	}
 
-	sellSide := new(match.Side)
-	buySide := new(match.Side)
-	*sellSide = match.Sell
-	*buySide = match.Buy
-	// First get the max buy price and max sell price
-	var maxSellRow *sql.Row
-	getMaxSellPrice := fmt.Sprintf("SELECT MAX(price) FROM %s WHERE side='%s' FOR UPDATE;", le.pair.String(), sellSide.String())
-	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
-	logging.Infof("maxsell: %s", sellSide.String())
-	maxSellRow = tx.QueryRow(getMaxSellPrice)
+	currOrder := &match.LimitOrderIDPair{
+		Order:   new(match.LimitOrder),
+		OrderID: new(match.OrderID),
+	}
+	*currOrder.OrderID = *orderID
 
-	var maxSell float64
-	var maxSellSqlNullable sql.NullFloat64
-	if err = maxSellRow.Scan(&maxSellSqlNullable); err != nil && err != sql.ErrNoRows {
-		err = fmt.Errorf("Error scanning max sell row: %s", err)
-		return
-	} else if err != nil && err == sql.ErrNoRows {
-		// this should never happen but hey if it does we should exit, and not error because that would mean no max
+	var pubkeyBytes []byte
+	var sideString string
+	var timeString string
+	var timeInForceString string
+	var selfTradeString string
+	selectOrderQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, amountHave, amountWant, time, timeInForce, expiry, selfTrade FROM %s WHERE orderID = '%x' FOR UPDATE;", le.pair.String(), orderID[:])
+	if err = tx.QueryRow(selectOrderQuery).Scan(&pubkeyBytes, &sideString, &currOrder.Price.AmountWant, &currOrder.Price.AmountHave, &currOrder.Order.AmountHave, &currOrder.Order.AmountWant, &timeString, &timeInForceString, &currOrder.Order.Expiry, &selfTradeString); err != nil {
+		err = fmt.Errorf("Error getting order from db for ReplaceLimitOrder: %s", err)
 		return
 	}
 
-	// if nothing came back (no max) then what are we gonna do
-	if !maxSellSqlNullable.Valid {
+	if currOrder.Timestamp, err = time.Parse(sqlTimeFormat, timeString); err != nil {
+		err = fmt.Errorf("Error parsing timestamp for ReplaceLimitOrder: %s", err)
 		return
-	} else {
-		maxSell = maxSellSqlNullable.Float64
 	}
 
-	var minBuyRow *sql.Row
-	getminBuyPrice := fmt.Sprintf("SELECT MIN(price) FROM %s WHERE side='%s' FOR UPDATE;", le.pair.String(), buySide.String())
-	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
-	minBuyRow = tx.QueryRow(getminBuyPrice)
-
-	var minBuy float64
-	var minBuySqlNullable sql.NullFloat64
-	if err = minBuyRow.Scan(&minBuySqlNullable); err != nil && err != sql.ErrNoRows {
-		err = fmt.Errorf("Error scanning min buy row: %s", err)
+	if err = currOrder.Order.Side.FromString(sideString); err != nil {
+		err = fmt.Errorf("Error getting side from string for ReplaceLimitOrder: %s", err)
 		return
-	} else if err != nil && err == sql.ErrNoRows {
-		// this should never happen but hey if it does we should exit, and not error because that would mean no min
+	}
+
+	if err = currOrder.Order.TimeInForce.FromString(timeInForceString); err != nil {
+		err = fmt.Errorf("Error getting time in force from string for ReplaceLimitOrder: %s", err)
 		return
 	}
 
-	// if nothing came back (no min) then what are we gonna do
-	if !minBuySqlNullable.Valid {
+	if err = currOrder.Order.SelfTrade.FromString(selfTradeString); err != nil {
+		err = fmt.Errorf("Error getting self-trade prevention from string for ReplaceLimitOrder: %s", err)
 		return
-	} else {
-		minBuy = minBuySqlNullable.Float64
 	}
 
-	// In our prices, if the min buy < max sell, we start to match orders. Otherwise, we can just quit.
-	if minBuy > maxSell {
+	// decode them all weirdly because of the way mysql may store the bytes
+	if pubkeyBytes, err = hex.DecodeString(string(pubkeyBytes)); err != nil {
+		err = fmt.Errorf("Error decoding pubkey for ReplaceLimitOrder: %s", err)
 		return
 	}
+	copy(currOrder.Order.Pubkey[:], pubkeyBytes)
+	currOrder.Order.TradingPair = *le.pair
 
-	// TODO: these two decoding / sorting routines may be able to be done concurrently?
+	if replaced, reserveChange, err = currOrder.Replace(amountHave, amountWant, time.Now()); err != nil {
+		err = fmt.Errorf("Error replacing order for ReplaceLimitOrder: %s", err)
+		return
+	}
 
-	// this will select all sell side, ordered by price descending and time ascending.
-	// this means that the sell orders will be sorted by price first, so the best prices will match first,
-	// and within the best price the earliest prices will match first.
-	var sellRows *sql.Rows
-	getSellSideQuery := fmt.Sprintf("SELECT pubkey, price, orderID, amountHave, amountWant, time FROM %s WHERE price>=%f AND side='%s' ORDER BY price DESC, time ASC FOR UPDATE;", le.pair.String(), minBuy, sellSide.String())
-	if sellRows, err = tx.Query(getSellSideQuery); err != nil {
-		err = fmt.Errorf("Error querying for sell orders for MatchLimitOrders: %s", err)
+	updateOrderQuery := fmt.Sprintf("UPDATE %s SET priceWant=%d, priceHave=%d, amountHave=%d, amountWant=%d, time='%s' WHERE orderID='%x';", le.pair.String(), replaced.Price.AmountWant, replaced.Price.AmountHave, replaced.Order.AmountHave, replaced.Order.AmountWant, replaced.Timestamp.Format(sqlTimeFormat), orderID[:])
+	if _, err = tx.Exec(updateOrderQuery); err != nil {
+		err = fmt.Errorf("Error updating order for ReplaceLimitOrder: %s", err)
 		return
 	}
 
-	// First let's get all the sell orders sorted
-	var sellOrders []*match.LimitOrderIDPair
-	for sellRows.Next() {
-		var pubkeyBytes []byte
-		var orderIDBytes []byte
-		var timeString string
-		sellOrderIDPair := &match.LimitOrderIDPair{
-			Order:   new(match.LimitOrder),
-			OrderID: new(match.OrderID),
-		}
-		if err = sellRows.Scan(&pubkeyBytes, &sellOrderIDPair.Price, &orderIDBytes, &sellOrderIDPair.Order.AmountHave, &sellOrderIDPair.Order.AmountWant, &timeString); err != nil {
-			err = fmt.Errorf("Error scanning sell rows for MatchLimitOrders: %s", err)
-			return
-		}
+	return
+}
 
-		if sellOrderIDPair.Timestamp, err = time.Parse(sqlTimeFormat, timeString); err != nil {
-			err = fmt.Errorf("Error parsing timestamp for MatchLimitOrders: %s", err)
-			return
-		}
+// MatchLimitOrders matches limit orders based on price/time priority, cancelling whatever is left of
+// immediate-or-cancel and fill-or-kill orders, and preventing self-trades
+func (le *SQLLimitEngine) MatchLimitOrders() (orderExecs []*match.OrderExecution, settlementExecs []*match.SettlementExecution, cancelled []*match.CancelledOrder, trades []*match.Trade, err error) {
+	if le.DBHandler == nil {
+		err = fmt.Errorf("Cannot match orders for nil handler, please recreate engine")
+		return
+	}
 
-		// we have to do this because ugh they return my byte arrays as hex strings...
-		if pubkeyBytes, err = hex.DecodeString(string(pubkeyBytes)); err != nil {
-			err = fmt.Errorf("Error decoding hex for sell pubkey for MatchLimitOrders: %s", err)
-			return
-		}
+	var tx *sql.Tx
+	if tx, err = le.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error when beginning transaction for MatchLimitOrders: %s", err)
+		return
+	}
 
-		// We prepared for this and made a type that knows what's coming with SQL, so we don't
-		// have to do the above
-		if err = sellOrderIDPair.OrderID.UnmarshalText(orderIDBytes); err != nil {
-			err = fmt.Errorf("Error unmarshalling sellorder id for MatchLimitOrders: %s", err)
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error for MatchLimitOrders: \n%s", err)
 			return
 		}
+		err = tx.Commit()
+		return
+	}()
 
-		sellOrderIDPair.Order.TradingPair = *le.pair
-		sellOrderIDPair.Order.Side = match.Sell
-		copy(sellOrderIDPair.Order.Pubkey[:], pubkeyBytes)
-		sellOrders = append(sellOrders, sellOrderIDPair)
-	}
-	if err = sellRows.Close(); err != nil {
-		err = fmt.Errorf("Error closing sell rows for MatchLimitOrders: %s", err)
+	if _, err = tx.Exec("USE " + le.orderSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using order schema while matching limit orders: %s", err)
 		return
 	}
 
-	// this will select all buy side, ordered by price ascending and time ascending.
-	// this means that the buy orders will be sorted by price first, so the best prices will match first,
-	// and within the best price the earliest prices will match first.
-	var buyRows *sql.Rows
-	getBuySideQuery := fmt.Sprintf("SELECT pubkey, price, orderID, amountHave, amountWant, time FROM %s WHERE price<=%f AND side='%s' ORDER BY price ASC, time ASC FOR UPDATE;", le.pair.String(), maxSell, buySide.String())
-	if buyRows, err = tx.Query(getBuySideQuery); err != nil {
-		err = fmt.Errorf("Error querying for buy orders for MatchLimitOrders: %s", err)
+	// Prices are fractions, which SQL can't compare exactly, so we get all of the orders and then prioritize
+	// them by comparing the prices exactly.
+	var rows *sql.Rows
+	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, orderID, amountHave, amountWant, time, timeInForce, expiry, selfTrade FROM %s FOR UPDATE;", le.pair.String())
+	if rows, err = tx.Query(getOrdersQuery); err != nil {
+		err = fmt.Errorf("Error querying for orders for MatchLimitOrders: %s", err)
 		return
 	}
 
-	// Now let's get all the buy orders sorted
-	var buyOrders []*match.LimitOrderIDPair
-	for buyRows.Next() {
+	book := make(map[match.Price][]*match.LimitOrderIDPair)
+	for rows.Next() {
 		var pubkeyBytes []byte
 		var orderIDBytes []byte
+		var sideString string
 		var timeString string
-		buyOrderIDPair := &match.LimitOrderIDPair{
+		var timeInForceString string
+		var selfTradeString string
+		orderIDPair := &match.LimitOrderIDPair{
 			Order:   new(match.LimitOrder),
 			OrderID: new(match.OrderID),
 		}
-		if err = buyRows.Scan(&pubkeyBytes, &buyOrderIDPair.Price, &orderIDBytes, &buyOrderIDPair.Order.AmountHave, &buyOrderIDPair.Order.AmountWant, &timeString); err != nil {
-			err = fmt.Errorf("Error scanning buy rows for MatchLimitOrders: %s", err)
+		if err = rows.Scan(&pubkeyBytes, &sideString, &orderIDPair.Price.AmountWant, &orderIDPair.Price.AmountHave, &orderIDBytes, &orderIDPair.Order.AmountHave, &orderIDPair.Order.AmountWant, &timeString, &timeInForceString, &orderIDPair.Order.Expiry, &selfTradeString); err != nil {
+			err = fmt.Errorf("Error scanning order rows for MatchLimitOrders: %s", err)
 			return
 		}
 
-		if buyOrderIDPair.Timestamp, err = time.Parse(sqlTimeFormat, timeString); err != nil {
+		if err = orderIDPair.Order.TimeInForce.FromString(timeInForceString); err != nil {
+			err = fmt.Errorf("Error getting time in force from string for MatchLimitOrders: %s", err)
+			return
+		}
+
+		if err = orderIDPair.Order.SelfTrade.FromString(selfTradeString); err != nil {
+			err = fmt.Errorf("Error getting self-trade prevention from string for MatchLimitOrders: %s", err)
+			return
+		}
+
+		if orderIDPair.Timestamp, err = time.Parse(sqlTimeFormat, timeString); err != nil {
 			err = fmt.Errorf("Error parsing timestamp for MatchLimitOrders: %s", err)
 			return
 		}
 
+		if err = orderIDPair.Order.Side.FromString(sideString); err != nil {
+			err = fmt.Errorf("Error getting side from string for MatchLimitOrders: %s", err)
+			return
+		}
+
 		// we have to do this because ugh they return my byte arrays as hex strings...
 		if pubkeyBytes, err = hex.DecodeString(string(pubkeyBytes)); err != nil {
-			err = fmt.Errorf("Error decoding hex for buy pubkey for MatchLimitOrders: %s", err)
+			err = fmt.Errorf("Error decoding hex for pubkey for MatchLimitOrders: %s", err)
 			return
 		}
+
 		// We prepared for this and made a type that knows what's coming with SQL, so we don't
 		// have to do the above
-		if err = buyOrderIDPair.OrderID.UnmarshalText(orderIDBytes); err != nil {
-			err = fmt.Errorf("Error unmarshalling buyorder id for MatchLimitOrders: %s", err)
+		if err = orderIDPair.OrderID.UnmarshalText(orderIDBytes); err != nil {
+			err = fmt.Errorf("Error unmarshalling order id for MatchLimitOrders: %s", err)
 			return
 		}
 
-		buyOrderIDPair.Order.TradingPair = *le.pair
-		buyOrderIDPair.Order.Side = match.Buy
-		copy(buyOrderIDPair.Order.Pubkey[:], pubkeyBytes)
-		buyOrders = append(buyOrders, buyOrderIDPair)
+		orderIDPair.Order.TradingPair = *le.pair
+		copy(orderIDPair.Order.Pubkey[:], pubkeyBytes)
+		book[orderIDPair.Price] = append(book[orderIDPair.Price], orderIDPair)
 	}
-	if err = buyRows.Close(); err != nil {
-		err = fmt.Errorf("Error closing buy rows for MatchLimitOrders: %s", err)
+	if err = rows.Close(); err != nil {
+		err = fmt.Errorf("Error closing order rows for MatchLimitOrders: %s", err)
 		return
 	}
 
-	if orderExecs, settlementExecs, err = match.MatchPrioritizedOrders(buyOrders, sellOrders); err != nil {
-		err = fmt.Errorf("Error matching prioritized orders for MatchLimitOrders: %s", err)
+	// this will sort the sell side by price descending and time ascending, and the buy side by price
+	// ascending and time ascending. This means that the best prices will match first, and within the best
+	// price the earliest orders will match first.
+	if orderExecs, settlementExecs, cancelled, trades, err = match.MatchLimitBook(book); err != nil {
+		err = fmt.Errorf("Error matching book for MatchLimitOrders: %s", err)
 		return
 	}
 
@@ -564,6 +589,14 @@ func (le *SQLLimitEngine) MatchLimitOrders() (orderExecs []*match.OrderExecution
 		}
 	}
 
+	for _, cancelledOrder := range cancelled {
+		cancelOrderQuery := fmt.Sprintf("DELETE FROM %s WHERE orderID='%x';", le.pair.String(), cancelledOrder.OrderID[:])
+		if _, err = tx.Exec(cancelOrderQuery); err != nil {
+			err = fmt.Errorf("Error deleting cancelled order for MatchLimitOrders: %s", err)
+			return
+		}
+	}
+
 	return
 }
 
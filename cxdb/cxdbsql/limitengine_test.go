@@ -272,7 +272,7 @@ func MatchNLimitOrders(howMany uint64, b *testing.B) {
 	// Start it back up again, let's time this
 	b.ResetTimer()
 
-	if _, _, err = engine.MatchLimitOrders(); err != nil {
+	if _, _, _, _, err = engine.MatchLimitOrders(); err != nil {
 		b.Errorf("Error matching limit orders: %s", err)
 	}
 
@@ -320,7 +320,7 @@ func PlaceMatchNLimitOrders(howMany uint64, b *testing.B) {
 		if _, err = engine.PlaceLimitOrder(order); err != nil {
 			b.Errorf("Error placing limit order: %s", err)
 		}
-		if _, _, err = engine.MatchLimitOrders(); err != nil {
+		if _, _, _, _, err = engine.MatchLimitOrders(); err != nil {
 			b.Errorf("Error matching limit orders: %s", err)
 		}
 	}
@@ -375,7 +375,7 @@ func PlaceMatchNLimitOrdersTest(howMany uint64, t *testing.T) {
 		if _, err = engine.PlaceLimitOrder(order); err != nil {
 			t.Errorf("Error placing limit order: %s", err)
 		}
-		if _, _, err = engine.MatchLimitOrders(); err != nil {
+		if _, _, _, _, err = engine.MatchLimitOrders(); err != nil {
 			t.Errorf("Error matching limit orders: %s", err)
 		}
 	}
@@ -31,7 +31,7 @@ type SQLLimitOrderbook struct {
 
 // The schema for the limit orderbook
 const (
-	limitOrderbookSchema = "pubkey VARBINARY(66), orderID VARBINARY(64), side TEXT, price DOUBLE(30,2) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), time TIMESTAMP"
+	limitOrderbookSchema = "pubkey VARBINARY(66), orderID VARBINARY(64), side TEXT, priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountHave BIGINT(64), amountWant BIGINT(64), time TIMESTAMP, expiry BIGINT(64) UNSIGNED"
 )
 
 // CreateLimitOrderbook creates a limit orderbook based on a pair
@@ -129,7 +129,7 @@ func (lo *SQLLimitOrderbook) setupLimitOrderbookTables() (err error) {
 		return
 	}
 
-	createTableQuery := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", lo.pair.String(), limitEngineSchema)
+	createTableQuery := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", lo.pair.String(), limitOrderbookSchema)
 	if _, err = tx.Exec(createTableQuery); err != nil {
 		err = fmt.Errorf("Error creating limit orderbook table: %s", err)
 		return
@@ -270,7 +270,7 @@ func (lo *SQLLimitOrderbook) UpdateBookPlace(limitIDPair *match.LimitOrderIDPair
 		return
 	}
 
-	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%x', '%s', %f, %d, %d, '%s');", lo.pair.String(), limitIDPair.Order.Pubkey, limitIDPair.OrderID[:], limitIDPair.Order.Side.String(), limitIDPair.Price, limitIDPair.Order.AmountHave, limitIDPair.Order.AmountWant, limitIDPair.Timestamp.Format(sqlTimeFormat))
+	insertOrderQuery := fmt.Sprintf("INSERT INTO %s VALUES ('%x', '%x', '%s', %d, %d, %d, %d, '%s', %d);", lo.pair.String(), limitIDPair.Order.Pubkey, limitIDPair.OrderID[:], limitIDPair.Order.Side.String(), limitIDPair.Price.AmountWant, limitIDPair.Price.AmountHave, limitIDPair.Order.AmountHave, limitIDPair.Order.AmountWant, limitIDPair.Timestamp.Format(sqlTimeFormat), limitIDPair.Order.Expiry)
 	if _, err = tx.Exec(insertOrderQuery); err != nil {
 		err = fmt.Errorf("Error placing order into db for UpdateBookPlace: %s", err)
 		return
@@ -306,17 +306,17 @@ func (lo *SQLLimitOrderbook) GetOrder(orderID *match.OrderID) (limOrder *match.L
 	}
 
 	var row *sql.Row
-	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, price, orderID, amountHave, amountWant, time FROM %s WHERE orderID='%x';", lo.pair.String(), orderID[:])
+	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, orderID, amountHave, amountWant, time, expiry FROM %s WHERE orderID='%x';", lo.pair.String(), orderID[:])
 	row = tx.QueryRow(getOrdersQuery)
 
 	// we create these here so we don't take up a ton of memory allocating space for new intermediate arrays
 	var pkBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 	var sideString string
 	var timeString string
 	// scan the things we can into this order
-	if err = row.Scan(&pkBytes, &sideString, &thisPrice, &hashedOrderBytes, &limOrder.Order.AmountHave, &limOrder.Order.AmountWant, &timeString); err != nil {
+	if err = row.Scan(&pkBytes, &sideString, &thisPrice.AmountWant, &thisPrice.AmountHave, &hashedOrderBytes, &limOrder.Order.AmountHave, &limOrder.Order.AmountWant, &timeString, &limOrder.Order.Expiry); err != nil {
 		err = fmt.Errorf("Error scanning into order for GetOrdersForPubkey: %s", err)
 		return
 	}
@@ -382,7 +382,7 @@ func (lo *SQLLimitOrderbook) CalculatePrice() (price float64, err error) {
 	*buySide = match.Buy
 	// First get the max buy price and max sell price
 	var maxSellRow *sql.Row
-	getMaxSellPrice := fmt.Sprintf("SELECT MAX(price) FROM %s WHERE side='%s';", lo.pair.String(), sellSide.String())
+	getMaxSellPrice := fmt.Sprintf("SELECT MAX(priceWant / priceHave) FROM %s WHERE side='%s';", lo.pair.String(), sellSide.String())
 	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
 	maxSellRow = tx.QueryRow(getMaxSellPrice)
 
@@ -393,7 +393,7 @@ func (lo *SQLLimitOrderbook) CalculatePrice() (price float64, err error) {
 	}
 
 	var minBuyRow *sql.Row
-	getminBuyPrice := fmt.Sprintf("SELECT MIN(price) FROM %s WHERE side='%s';", lo.pair.String(), buySide.String())
+	getminBuyPrice := fmt.Sprintf("SELECT MIN(priceWant / priceHave) FROM %s WHERE side='%s';", lo.pair.String(), buySide.String())
 	// errors for queryrow are deferred until scan -- this is important, that's why we don't err != nil here
 	minBuyRow = tx.QueryRow(getminBuyPrice)
 
@@ -408,9 +408,9 @@ func (lo *SQLLimitOrderbook) CalculatePrice() (price float64, err error) {
 }
 
 // GetOrdersForPubkey gets orders for a specific pubkey.
-func (lo *SQLLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[float64][]*match.LimitOrderIDPair, err error) {
+func (lo *SQLLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[match.Price][]*match.LimitOrderIDPair, err error) {
 	// Make the book!!!!
-	orders = make(map[float64][]*match.LimitOrderIDPair)
+	orders = make(map[match.Price][]*match.LimitOrderIDPair)
 
 	// Transaction so we're acid
 	var tx *sql.Tx
@@ -434,7 +434,7 @@ func (lo *SQLLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orde
 	}
 
 	var rows *sql.Rows
-	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, price, orderID, amountHave, amountWant, time FROM %s WHERE pubkey='%x';", lo.pair.String(), pubkey.SerializeCompressed())
+	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, orderID, amountHave, amountWant, time, expiry FROM %s WHERE pubkey='%x';", lo.pair.String(), pubkey.SerializeCompressed())
 	if rows, err = tx.Query(getOrdersQuery); err != nil {
 		err = fmt.Errorf("Error querying for sell orders for GetOrdersForPubkey: %s", err)
 		return
@@ -447,14 +447,14 @@ func (lo *SQLLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orde
 	// we create these here so we don't take up a ton of memory allocating space for new intermediate arrays
 	var pkBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 	var sideString string
 	var timeString string
 	for rows.Next() {
 		// scan the things we can into this order
 		thisOrder = new(match.LimitOrder)
 		thisOrderPair = new(match.LimitOrderIDPair)
-		if err = rows.Scan(&pkBytes, &sideString, &thisPrice, &hashedOrderBytes, &thisOrder.AmountHave, &thisOrder.AmountWant, &timeString); err != nil {
+		if err = rows.Scan(&pkBytes, &sideString, &thisPrice.AmountWant, &thisPrice.AmountHave, &hashedOrderBytes, &thisOrder.AmountHave, &thisOrder.AmountWant, &timeString, &thisOrder.Expiry); err != nil {
 			err = fmt.Errorf("Error scanning into order for GetOrdersForPubkey: %s", err)
 			return
 		}
@@ -500,9 +500,9 @@ func (lo *SQLLimitOrderbook) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orde
 }
 
 // ViewLimitOrderbook takes in a trading pair and returns the orderbook as a map
-func (lo *SQLLimitOrderbook) ViewLimitOrderBook() (book map[float64][]*match.LimitOrderIDPair, err error) {
+func (lo *SQLLimitOrderbook) ViewLimitOrderBook() (book map[match.Price][]*match.LimitOrderIDPair, err error) {
 	// Make the book!!!!
-	book = make(map[float64][]*match.LimitOrderIDPair)
+	book = make(map[match.Price][]*match.LimitOrderIDPair)
 
 	// Transaction so we're acid
 	var tx *sql.Tx
@@ -526,7 +526,7 @@ func (lo *SQLLimitOrderbook) ViewLimitOrderBook() (book map[float64][]*match.Lim
 	}
 
 	var rows *sql.Rows
-	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, price, orderID, amountHave, amountWant, time FROM %s;", lo.pair.String())
+	getOrdersQuery := fmt.Sprintf("SELECT pubkey, side, priceWant, priceHave, orderID, amountHave, amountWant, time, expiry FROM %s;", lo.pair.String())
 	if rows, err = tx.Query(getOrdersQuery); err != nil {
 		err = fmt.Errorf("Error querying for sell orders for ViewOrderBook: %s", err)
 		return
@@ -539,14 +539,14 @@ func (lo *SQLLimitOrderbook) ViewLimitOrderBook() (book map[float64][]*match.Lim
 	// we create these here so we don't take up a ton of memory allocating space for new intermediate arrays
 	var pkBytes []byte
 	var hashedOrderBytes []byte
-	var thisPrice float64
+	var thisPrice match.Price
 	var sideString string
 	var timeString string
 	for rows.Next() {
 		// scan the things we can into this order
 		thisOrder = new(match.LimitOrder)
 		thisOrderPair = new(match.LimitOrderIDPair)
-		if err = rows.Scan(&pkBytes, &sideString, &thisPrice, &hashedOrderBytes, &thisOrder.AmountHave, &thisOrder.AmountWant, &timeString); err != nil {
+		if err = rows.Scan(&pkBytes, &sideString, &thisPrice.AmountWant, &thisPrice.AmountHave, &hashedOrderBytes, &thisOrder.AmountHave, &thisOrder.AmountWant, &timeString, &thisOrder.Expiry); err != nil {
 			err = fmt.Errorf("Error scanning into order for ViewOrderBook: %s", err)
 			return
 		}
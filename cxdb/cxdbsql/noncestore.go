@@ -0,0 +1,242 @@
+package cxdbsql
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mit-dci/opencx/cxdb"
+)
+
+// SQLNonceStore keeps the nonces used in order envelopes in a table, so orders can't be replayed after the
+// exchange restarts
+type SQLNonceStore struct {
+	DBHandler *sql.DB
+
+	// db username and password
+	dbUsername string
+	dbPassword string
+
+	// db host and port
+	dbAddr net.Addr
+
+	// nonce schema and table name
+	nonceSchema string
+	nonceTable  string
+}
+
+// The schema for used nonces. The primary key keeps a pubkey from using a nonce twice.
+const (
+	nonceStoreSchema = "pubkey VARBINARY(66) NOT NULL, nonce BIGINT(64) UNSIGNED NOT NULL, expiry BIGINT(64) UNSIGNED NOT NULL, PRIMARY KEY (pubkey, nonce), KEY expiry (expiry)"
+)
+
+// CreateNonceStoreWithConf creates a nonce store for every pubkey, with a config.
+func CreateNonceStoreWithConf(conf *dbsqlConfig) (store cxdb.NonceStore, err error) {
+	// Set the default conf
+	dbConfigSetup(conf)
+
+	// Resolve new address
+	var addr net.Addr
+	if addr, err = net.ResolveTCPAddr("tcp", net.JoinHostPort(conf.DBHost, fmt.Sprintf("%d", conf.DBPort))); err != nil {
+		err = fmt.Errorf("Couldn't resolve db address for CreateNonceStoreWithConf: %s", err)
+		return
+	}
+
+	// Set values
+	ns := &SQLNonceStore{
+		dbUsername:  conf.DBUsername,
+		dbPassword:  conf.DBPassword,
+		nonceSchema: conf.NonceSchemaName,
+		nonceTable:  conf.NonceTableName,
+		dbAddr:      addr,
+	}
+
+	if err = ns.setupNonceStoreTables(); err != nil {
+		err = fmt.Errorf("Error setting up nonce store tables while creating store: %s", err)
+		return
+	}
+
+	// Now connect to the database and create the schemas / tables
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", ns.dbUsername, ns.dbPassword, ns.dbAddr.Network(), ns.dbAddr.String())
+	if ns.DBHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for CreateNonceStoreWithConf: %s", err)
+		return
+	}
+
+	// Make sure we can actually connect
+	if err = ns.DBHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// Now we actually set what we want
+	store = ns
+	return
+}
+
+// CreateNonceStore creates a nonce store for every pubkey.
+func CreateNonceStore() (store cxdb.NonceStore, err error) {
+
+	conf := new(dbsqlConfig)
+	*conf = *defaultConf
+
+	if store, err = CreateNonceStoreWithConf(conf); err != nil {
+		err = fmt.Errorf("Error creating nonce store with conf for CreateNonceStore: %s", err)
+		return
+	}
+	return
+}
+
+// setupNonceStoreTables sets up the tables needed for the nonce store.
+// This assumes everything else is set
+func (ns *SQLNonceStore) setupNonceStoreTables() (err error) {
+
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", ns.dbUsername, ns.dbPassword, ns.dbAddr.Network(), ns.dbAddr.String())
+	var rootHandler *sql.DB
+	if rootHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for setup nonce store tables: %s", err)
+		return
+	}
+
+	// when we're done close please
+	defer rootHandler.Close()
+
+	if err = rootHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// We do this in a transaction because it's more than one operation
+	var tx *sql.Tx
+	if tx, err = rootHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error when beginning transaction for setup nonce store tables: %s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while creating nonce store tables: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Now create the schema
+	if _, err = tx.Exec("CREATE SCHEMA IF NOT EXISTS " + ns.nonceSchema + ";"); err != nil {
+		err = fmt.Errorf("Error creating schema for setup nonce store tables: %s", err)
+		return
+	}
+
+	// use the schema
+	if _, err = tx.Exec("USE " + ns.nonceSchema + ";"); err != nil {
+		err = fmt.Errorf("Could not use %s schema: %s", ns.nonceSchema, err)
+		return
+	}
+
+	createTableQuery := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", ns.nonceTable, nonceStoreSchema)
+	if _, err = tx.Exec(createTableQuery); err != nil {
+		err = fmt.Errorf("Error creating nonce store table: %s", err)
+		return
+	}
+	return
+}
+
+// UseNonce adds a nonce to the store, returning an error if the pubkey has already used it
+func (ns *SQLNonceStore) UseNonce(use *cxdb.NonceUse) (err error) {
+	if use == nil {
+		err = fmt.Errorf("Cannot use nil nonce, please enter valid input")
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = ns.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while using nonce: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while using nonce: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + ns.nonceSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using nonce schema for UseNonce: %s", err)
+		return
+	}
+
+	// The primary key makes this fail if the nonce was already used
+	insertNonceQuery := fmt.Sprintf("INSERT INTO %s (pubkey, nonce, expiry) VALUES ('%x', %d, %d);", ns.nonceTable, use.Pubkey, use.Nonce, use.Expiry)
+	if _, err = tx.Exec(insertNonceQuery); err != nil {
+		err = fmt.Errorf("Nonce %d could not be used by pubkey %x: %s", use.Nonce, use.Pubkey, err)
+		return
+	}
+	return
+}
+
+// NonceUsed returns true if the pubkey has used the nonce, and it hasn't been pruned
+func (ns *SQLNonceStore) NonceUsed(pubkey [33]byte, nonce uint64) (used bool, err error) {
+	var tx *sql.Tx
+	if tx, err = ns.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while checking nonce: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while checking nonce: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + ns.nonceSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using nonce schema for NonceUsed: %s", err)
+		return
+	}
+
+	var count uint64
+	countNonceQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE pubkey='%x' AND nonce=%d;", ns.nonceTable, pubkey, nonce)
+	if err = tx.QueryRow(countNonceQuery).Scan(&count); err != nil {
+		err = fmt.Errorf("Error querying nonce for NonceUsed: %s", err)
+		return
+	}
+	used = count != 0
+	return
+}
+
+// PruneNonces removes the nonces for envelopes that expired at or before the unix time before
+func (ns *SQLNonceStore) PruneNonces(before uint64) (err error) {
+	var tx *sql.Tx
+	if tx, err = ns.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while pruning nonces: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while pruning nonces: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + ns.nonceSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using nonce schema for PruneNonces: %s", err)
+		return
+	}
+
+	pruneNonceQuery := fmt.Sprintf("DELETE FROM %s WHERE expiry <= %d;", ns.nonceTable, before)
+	if _, err = tx.Exec(pruneNonceQuery); err != nil {
+		err = fmt.Errorf("Error deleting nonces for PruneNonces: %s", err)
+		return
+	}
+	return
+}
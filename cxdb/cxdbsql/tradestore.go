@@ -0,0 +1,332 @@
+package cxdbsql
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SQLTradeStore keeps the trades for a pair in a table, so they are still there after the exchange restarts
+type SQLTradeStore struct {
+	DBHandler *sql.DB
+
+	// db username and password
+	dbUsername string
+	dbPassword string
+
+	// db host and port
+	dbAddr net.Addr
+
+	// trade schema name
+	tradeSchema string
+
+	// this pair
+	pair *match.Pair
+}
+
+// The schema for the trade history. The time is in nanoseconds so trades in the same second keep their
+// order, and the unique key keeps trades from being added twice when the journal is replayed.
+const (
+	tradeStoreSchema = "seq BIGINT(64) UNSIGNED NOT NULL AUTO_INCREMENT, buyOrderID VARBINARY(64), sellOrderID VARBINARY(64), buyPubkey VARBINARY(66), sellPubkey VARBINARY(66), priceWant BIGINT(64) UNSIGNED, priceHave BIGINT(64) UNSIGNED, amountWant BIGINT(64) UNSIGNED, amountHave BIGINT(64) UNSIGNED, makerSide TEXT, time BIGINT(64), PRIMARY KEY (seq), UNIQUE KEY trade (buyOrderID, sellOrderID, time)"
+)
+
+// CreateTradeStoreWithConf creates a trade store for a specific pair, with a config.
+func CreateTradeStoreWithConf(pair *match.Pair, conf *dbsqlConfig) (store cxdb.TradeStore, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot create trade store with nil pair, please enter valid input")
+		return
+	}
+
+	// Set the default conf
+	dbConfigSetup(conf)
+
+	// Resolve new address
+	var addr net.Addr
+	if addr, err = net.ResolveTCPAddr("tcp", net.JoinHostPort(conf.DBHost, fmt.Sprintf("%d", conf.DBPort))); err != nil {
+		err = fmt.Errorf("Couldn't resolve db address for CreateTradeStoreWithConf: %s", err)
+		return
+	}
+
+	// Set values
+	ts := &SQLTradeStore{
+		dbUsername:  conf.DBUsername,
+		dbPassword:  conf.DBPassword,
+		tradeSchema: conf.TradeSchemaName,
+		dbAddr:      addr,
+		pair:        pair,
+	}
+
+	if err = ts.setupTradeStoreTables(); err != nil {
+		err = fmt.Errorf("Error setting up trade store tables while creating store: %s", err)
+		return
+	}
+
+	// Now connect to the database and create the schemas / tables
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", ts.dbUsername, ts.dbPassword, ts.dbAddr.Network(), ts.dbAddr.String())
+	if ts.DBHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for CreateTradeStoreWithConf: %s", err)
+		return
+	}
+
+	// Make sure we can actually connect
+	if err = ts.DBHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// Now we actually set what we want
+	store = ts
+	return
+}
+
+// CreateTradeStore creates a trade store for a specific pair.
+func CreateTradeStore(pair *match.Pair) (store cxdb.TradeStore, err error) {
+
+	conf := new(dbsqlConfig)
+	*conf = *defaultConf
+
+	if store, err = CreateTradeStoreWithConf(pair, conf); err != nil {
+		err = fmt.Errorf("Error creating trade store with conf for CreateTradeStore: %s", err)
+		return
+	}
+	return
+}
+
+// setupTradeStoreTables sets up the tables needed for the trade store.
+// This assumes everything else is set
+func (ts *SQLTradeStore) setupTradeStoreTables() (err error) {
+
+	openString := fmt.Sprintf("%s:%s@%s(%s)/", ts.dbUsername, ts.dbPassword, ts.dbAddr.Network(), ts.dbAddr.String())
+	var rootHandler *sql.DB
+	if rootHandler, err = sql.Open("mysql", openString); err != nil {
+		err = fmt.Errorf("Error opening database for setup trade store tables: %s", err)
+		return
+	}
+
+	// when we're done close please
+	defer rootHandler.Close()
+
+	if err = rootHandler.Ping(); err != nil {
+		err = fmt.Errorf("Could not ping the database, is it running: %s", err)
+		return
+	}
+
+	// We do this in a transaction because it's more than one operation
+	var tx *sql.Tx
+	if tx, err = rootHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error when beginning transaction for setup trade store tables: %s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while creating trade store tables: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Now create the schema
+	if _, err = tx.Exec("CREATE SCHEMA IF NOT EXISTS " + ts.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error creating schema for setup trade store tables: %s", err)
+		return
+	}
+
+	// use the schema
+	if _, err = tx.Exec("USE " + ts.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Could not use %s schema: %s", ts.tradeSchema, err)
+		return
+	}
+
+	createTableQuery := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", ts.pair.String(), tradeStoreSchema)
+	if _, err = tx.Exec(createTableQuery); err != nil {
+		err = fmt.Errorf("Error creating trade store table: %s", err)
+		return
+	}
+	return
+}
+
+// AddTrades adds trades to the store, in the order they were made. Trades that are already in the store
+// are ignored.
+func (ts *SQLTradeStore) AddTrades(trades []*match.Trade) (err error) {
+	for _, trade := range trades {
+		if trade == nil {
+			err = fmt.Errorf("Cannot add nil trade, please enter valid input")
+			return
+		}
+
+		if trade.Pair != *ts.pair {
+			err = fmt.Errorf("Cannot add trade for pair %s to store for pair %s", trade.Pair.String(), ts.pair.String())
+			return
+		}
+	}
+
+	var tx *sql.Tx
+	if tx, err = ts.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while adding trades: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while adding trades: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + ts.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using trade schema for AddTrades: %s", err)
+		return
+	}
+
+	for _, trade := range trades {
+		insertTradeQuery := fmt.Sprintf("INSERT IGNORE INTO %s (buyOrderID, sellOrderID, buyPubkey, sellPubkey, priceWant, priceHave, amountWant, amountHave, makerSide, time) VALUES ('%x', '%x', '%x', '%x', %d, %d, %d, %d, '%s', %d);", ts.pair.String(), trade.BuyOrderID, trade.SellOrderID, trade.BuyPubkey, trade.SellPubkey, trade.Price.AmountWant, trade.Price.AmountHave, trade.AmountWant, trade.AmountHave, trade.MakerSide.String(), trade.Timestamp.UnixNano())
+		if _, err = tx.Exec(insertTradeQuery); err != nil {
+			err = fmt.Errorf("Error inserting trade for AddTrades: %s", err)
+			return
+		}
+	}
+	return
+}
+
+// GetRecentTrades gets the latest trades, newest first, and at most limit of them
+func (ts *SQLTradeStore) GetRecentTrades(limit uint64) (trades []*match.Trade, err error) {
+	getTradesQuery := fmt.Sprintf("SELECT buyOrderID, sellOrderID, buyPubkey, sellPubkey, priceWant, priceHave, amountWant, amountHave, makerSide, time FROM %s ORDER BY seq DESC LIMIT %d;", ts.pair.String(), limit)
+	if trades, err = ts.queryTrades(getTradesQuery); err != nil {
+		err = fmt.Errorf("Error querying trades for GetRecentTrades: %s", err)
+		return
+	}
+	return
+}
+
+// GetTradesForPubkey gets the trades that an order placed by a pubkey was in, newest first, and at most
+// limit of them. If before isn't zero, only trades made at or before then are returned.
+func (ts *SQLTradeStore) GetTradesForPubkey(pubkey *koblitz.PublicKey, before time.Time, limit uint64) (trades []*match.Trade, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get trades for nil pubkey, please enter valid input")
+		return
+	}
+
+	beforeCondition := ""
+	if !before.IsZero() {
+		beforeCondition = fmt.Sprintf(" AND time <= %d", before.UnixNano())
+	}
+
+	getTradesQuery := fmt.Sprintf("SELECT buyOrderID, sellOrderID, buyPubkey, sellPubkey, priceWant, priceHave, amountWant, amountHave, makerSide, time FROM %s WHERE (buyPubkey='%x' OR sellPubkey='%[2]x')%s ORDER BY seq DESC LIMIT %d;", ts.pair.String(), pubkey.SerializeCompressed(), beforeCondition, limit)
+	if trades, err = ts.queryTrades(getTradesQuery); err != nil {
+		err = fmt.Errorf("Error querying trades for GetTradesForPubkey: %s", err)
+		return
+	}
+	return
+}
+
+// queryTrades runs a query that selects trades from the trade table and scans them
+func (ts *SQLTradeStore) queryTrades(query string) (trades []*match.Trade, err error) {
+	var tx *sql.Tx
+	if tx, err = ts.DBHandler.Begin(); err != nil {
+		err = fmt.Errorf("Error beginning transaction while getting trades: \n%s", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Error while getting trades: \n%s", err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("USE " + ts.tradeSchema + ";"); err != nil {
+		err = fmt.Errorf("Error using trade schema for queryTrades: %s", err)
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = tx.Query(query); err != nil {
+		err = fmt.Errorf("Error querying for trades: %s", err)
+		return
+	}
+
+	for rows.Next() {
+		var buyIDBytes, sellIDBytes, buyPubBytes, sellPubBytes []byte
+		var makerSideString string
+		var tradeTime int64
+		trade := &match.Trade{
+			Pair: *ts.pair,
+		}
+		if err = rows.Scan(&buyIDBytes, &sellIDBytes, &buyPubBytes, &sellPubBytes, &trade.Price.AmountWant, &trade.Price.AmountHave, &trade.AmountWant, &trade.AmountHave, &makerSideString, &tradeTime); err != nil {
+			err = fmt.Errorf("Error scanning trade rows: %s", err)
+			rows.Close()
+			return
+		}
+
+		if err = trade.BuyOrderID.UnmarshalText(buyIDBytes); err != nil {
+			err = fmt.Errorf("Error unmarshalling buy order id: %s", err)
+			rows.Close()
+			return
+		}
+
+		if err = trade.SellOrderID.UnmarshalText(sellIDBytes); err != nil {
+			err = fmt.Errorf("Error unmarshalling sell order id: %s", err)
+			rows.Close()
+			return
+		}
+
+		// the pubkeys are stored as hex strings
+		if buyPubBytes, err = hex.DecodeString(string(buyPubBytes)); err != nil {
+			err = fmt.Errorf("Error decoding hex for buy pubkey: %s", err)
+			rows.Close()
+			return
+		}
+
+		if sellPubBytes, err = hex.DecodeString(string(sellPubBytes)); err != nil {
+			err = fmt.Errorf("Error decoding hex for sell pubkey: %s", err)
+			rows.Close()
+			return
+		}
+
+		if err = trade.MakerSide.FromString(makerSideString); err != nil {
+			err = fmt.Errorf("Error getting maker side from string: %s", err)
+			rows.Close()
+			return
+		}
+
+		copy(trade.BuyPubkey[:], buyPubBytes)
+		copy(trade.SellPubkey[:], sellPubBytes)
+		trade.Timestamp = time.Unix(0, tradeTime)
+		trades = append(trades, trade)
+	}
+
+	if err = rows.Close(); err != nil {
+		err = fmt.Errorf("Error closing trade rows: %s", err)
+		return
+	}
+	return
+}
+
+// CreateTradeStoreMap creates a map of pair to trade store, given a list of pairs.
+func CreateTradeStoreMap(pairList []*match.Pair) (storeMap map[match.Pair]cxdb.TradeStore, err error) {
+
+	storeMap = make(map[match.Pair]cxdb.TradeStore)
+	var curTradeStore cxdb.TradeStore
+	for _, pair := range pairList {
+		if curTradeStore, err = CreateTradeStore(pair); err != nil {
+			err = fmt.Errorf("Error creating single trade store while creating trade store map: %s", err)
+			return
+		}
+		storeMap[*pair] = curTradeStore
+	}
+
+	return
+}
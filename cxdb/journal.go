@@ -0,0 +1,375 @@
+package cxdb
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+	"golang.org/x/crypto/sha3"
+)
+
+// JournalEntryType is the type of change a journal entry records
+type JournalEntryType uint8
+
+const (
+	// JournalSettle is an entry that only has settlement executions, like a debit or credit
+	JournalSettle JournalEntryType = iota
+	// JournalPlace is an entry for an order placement, including the settlement executions and
+	// order executions that came from matching the order
+	JournalPlace
+	// JournalCancel is an entry for an order cancellation, including the settlement execution
+	// that gives the user their funds back
+	JournalCancel
+	// JournalAuctionPlace is an entry for an auction order being placed into an auction
+	JournalAuctionPlace
+	// JournalAuctionMatch is an entry for the order executions and settlement executions that came from
+	// matching an auction
+	JournalAuctionMatch
+	// JournalStopPlace is an entry for a stop order being placed, including the settlement execution that
+	// reserves its funds
+	JournalStopPlace
+	// JournalStopCancel is an entry for a stop order being cancelled, including the settlement execution
+	// that gives the user their funds back
+	JournalStopCancel
+	// JournalReplace is an entry for an order being amended, including the settlement execution that changes
+	// how much is reserved for it, and the order executions and settlement executions from matching it
+	JournalReplace
+	// JournalAddPair is an entry for a pair being added while the exchange is running, which has to be
+	// created again before the rest of the journal is replayed
+	JournalAddPair
+	// JournalPairStatus is an entry for the status of a pair being changed by the exchange operator
+	JournalPairStatus
+	// JournalMatch is an entry for the order executions and settlement executions from matching the
+	// orders that crossed while a pair wasn't matching orders, without an order being placed
+	JournalMatch
+	// JournalNonce is an entry for a nonce being used to place a limit order, written before the order is
+	// placed so the order can't be placed again with the same envelope
+	JournalNonce
+)
+
+// String returns the string representation of a journal entry type
+func (jt JournalEntryType) String() string {
+	switch jt {
+	case JournalSettle:
+		return "settle"
+	case JournalPlace:
+		return "place"
+	case JournalCancel:
+		return "cancel"
+	case JournalAuctionPlace:
+		return "auctionplace"
+	case JournalAuctionMatch:
+		return "auctionmatch"
+	case JournalStopPlace:
+		return "stopplace"
+	case JournalStopCancel:
+		return "stopcancel"
+	case JournalReplace:
+		return "replace"
+	case JournalAddPair:
+		return "addpair"
+	case JournalPairStatus:
+		return "pairstatus"
+	case JournalMatch:
+		return "match"
+	case JournalNonce:
+		return "nonce"
+	}
+	return "unknown"
+}
+
+// JournalEntry is a change to the exchange's state, written to the journal before it is applied to the
+// settlement engines, orderbooks and settlement stores.
+// Entries are hash chained, so the journal is also an event log that can be audited from the first entry.
+type JournalEntry struct {
+	// Sequence is set by the journal when the entry is appended
+	Sequence uint64
+	// PrevHash is the hash of the previous entry, or all zeroes for the first entry. This is set by the
+	// journal when the entry is appended
+	PrevHash [32]byte
+	Type     JournalEntryType
+	// Pair is the pair of the order being placed, cancelled, or matched, or the pair being added or
+	// having its status changed
+	Pair match.Pair
+	// PairStatus is what the status of the pair is changed to
+	PairStatus match.PairStatus
+	// SettlementExecs are applied in order, before anything else in the entry
+	SettlementExecs []*match.SettlementExecution
+	// FeeExecs are the settlement executions that charge trading fees for the trades in SettlementExecs,
+	// applied in order right after them
+	FeeExecs []*match.SettlementExecution
+	// PlacedOrder is the order as the matching engine returned it, before any order executions. For a
+	// replace, this is the order after it was amended, and it takes the place of the order with the same ID
+	PlacedOrder *match.LimitOrderIDPair
+	// OrderExecs are the order executions from matching, applied after the placed order
+	OrderExecs []*match.OrderExecution
+	// Trades are the buy orders and sell orders that were matched, which are added to the trade store after
+	// the order executions
+	Trades []*match.Trade
+	// CancelledOrders are the orders the matching engine cancelled after matching, like what is left of an
+	// immediate-or-cancel order, applied after the order executions
+	CancelledOrders []*match.CancelledOrder
+	// CancelledOrder is the order or stop order being cancelled
+	CancelledOrder *match.CancelledOrder
+	// LastPrice is the price of the last trade from the order executions, or nil if nothing traded
+	LastPrice *match.Price
+	// PlacedStopOrder is the stop order being placed
+	PlacedStopOrder *match.StopOrderIDPair
+	// TriggeredStopOrder is the stop order that the placed order came from, if it came from one. The
+	// funds for the order were reserved when the stop order was placed.
+	TriggeredStopOrder *match.OrderID
+	// AuctionID is the auction that an auction order is placed into, or that was matched
+	AuctionID match.AuctionID
+	// PlacedAuctionOrder is the auction order as the auction engine returned it
+	PlacedAuctionOrder *match.AuctionOrderIDPair
+	// AuctionExecution is the price and volume an auction cleared at, which is added to the candles after
+	// the order executions, or nil if nothing cleared
+	AuctionExecution *match.PriceExecution
+	// NonceUse is the nonce from the envelope of the limit order or auction order being placed, which is
+	// added to the nonce store
+	NonceUse *NonceUse
+}
+
+// Hash returns the hash of the serialized entry, which is what the next entry's PrevHash should be
+func (je *JournalEntry) Hash() (hash [32]byte, err error) {
+	var entryBytes []byte
+	if entryBytes, err = je.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing entry for Hash: %s", err)
+		return
+	}
+	hash = sha3.Sum256(entryBytes)
+	return
+}
+
+// Serialize serializes the journal entry in the canonical encoding from match, so the same entry always
+// serializes to the same bytes and hashes to the same hash:
+// [8 byte sequence] [32 byte previous hash] [1 byte type] [2 byte pair] [1 byte pair status]
+// [settlement executions] [fee executions] [optional placed order] [order executions] [trades]
+// [cancelled orders] [optional cancelled order] [optional last price] [optional placed stop order]
+// [optional 32 byte triggered stop order ID] [32 byte auction ID] [optional placed auction order]
+// [optional auction execution] [optional nonce use]
+// Lists are a count followed by each item, and the items and the orders are messages of their own.
+func (je *JournalEntry) Serialize() (buf []byte, err error) {
+	enc := match.NewEncoder(match.JournalEntryMessage)
+	enc.WriteUint64(je.Sequence)
+	enc.WriteFixed(je.PrevHash[:])
+	enc.WriteUint8(uint8(je.Type))
+	enc.WritePair(je.Pair)
+	enc.WriteUint8(uint8(je.PairStatus))
+
+	writeSettlementExecs(enc, je.SettlementExecs)
+	writeSettlementExecs(enc, je.FeeExecs)
+
+	enc.WriteBool(je.PlacedOrder != nil)
+	if je.PlacedOrder != nil {
+		var orderBytes []byte
+		if orderBytes, err = je.PlacedOrder.Serialize(); err != nil {
+			err = fmt.Errorf("Error serializing placed order for journal entry: %s", err)
+			return
+		}
+		enc.WriteBytes(orderBytes)
+	}
+
+	enc.WriteUint32(uint32(len(je.OrderExecs)))
+	for _, orderExec := range je.OrderExecs {
+		enc.WriteBytes(orderExec.Serialize())
+	}
+
+	enc.WriteUint32(uint32(len(je.Trades)))
+	for _, trade := range je.Trades {
+		enc.WriteBytes(trade.Serialize())
+	}
+
+	enc.WriteUint32(uint32(len(je.CancelledOrders)))
+	for _, cancelled := range je.CancelledOrders {
+		writeCancelledOrder(enc, cancelled)
+	}
+
+	enc.WriteBool(je.CancelledOrder != nil)
+	if je.CancelledOrder != nil {
+		writeCancelledOrder(enc, je.CancelledOrder)
+	}
+
+	enc.WriteBool(je.LastPrice != nil)
+	if je.LastPrice != nil {
+		enc.WritePrice(*je.LastPrice)
+	}
+
+	enc.WriteBool(je.PlacedStopOrder != nil)
+	if je.PlacedStopOrder != nil {
+		var stopBytes []byte
+		if stopBytes, err = je.PlacedStopOrder.Serialize(); err != nil {
+			err = fmt.Errorf("Error serializing placed stop order for journal entry: %s", err)
+			return
+		}
+		enc.WriteBytes(stopBytes)
+	}
+
+	enc.WriteBool(je.TriggeredStopOrder != nil)
+	if je.TriggeredStopOrder != nil {
+		enc.WriteFixed(je.TriggeredStopOrder[:])
+	}
+
+	enc.WriteFixed(je.AuctionID[:])
+
+	enc.WriteBool(je.PlacedAuctionOrder != nil)
+	if je.PlacedAuctionOrder != nil {
+		enc.WriteBytes(je.PlacedAuctionOrder.Serialize())
+	}
+
+	enc.WriteBool(je.AuctionExecution != nil)
+	if je.AuctionExecution != nil {
+		enc.WriteBytes(je.AuctionExecution.Serialize())
+	}
+
+	enc.WriteBool(je.NonceUse != nil)
+	if je.NonceUse != nil {
+		enc.WriteFixed(je.NonceUse.Pubkey[:])
+		enc.WriteUint64(je.NonceUse.Nonce)
+		enc.WriteUint64(je.NonceUse.Expiry)
+	}
+
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a journal entry that was serialized with Serialize
+func (je *JournalEntry) Deserialize(data []byte) (err error) {
+	*je = JournalEntry{}
+
+	dec := match.NewDecoder(data, match.JournalEntryMessage)
+	je.Sequence = dec.ReadUint64()
+	dec.ReadFixed(je.PrevHash[:])
+	je.Type = JournalEntryType(dec.ReadUint8())
+	je.Pair = dec.ReadPair()
+	je.PairStatus = match.PairStatus(dec.ReadUint8())
+
+	je.SettlementExecs = readSettlementExecs(dec)
+	je.FeeExecs = readSettlementExecs(dec)
+
+	if dec.ReadBool() {
+		je.PlacedOrder = new(match.LimitOrderIDPair)
+		dec.SetErr(je.PlacedOrder.Deserialize(dec.ReadBytes()))
+	}
+
+	// Each item in a list is at least the 4 byte length of its message
+	for i, count := 0, dec.ReadCount(4); i < count; i++ {
+		orderExec := new(match.OrderExecution)
+		dec.SetErr(orderExec.Deserialize(dec.ReadBytes()))
+		je.OrderExecs = append(je.OrderExecs, orderExec)
+	}
+
+	for i, count := 0, dec.ReadCount(4); i < count; i++ {
+		trade := new(match.Trade)
+		dec.SetErr(trade.Deserialize(dec.ReadBytes()))
+		je.Trades = append(je.Trades, trade)
+	}
+
+	for i, count := 0, dec.ReadCount(1); i < count; i++ {
+		je.CancelledOrders = append(je.CancelledOrders, readCancelledOrder(dec))
+	}
+
+	if dec.ReadBool() {
+		je.CancelledOrder = readCancelledOrder(dec)
+	}
+
+	if dec.ReadBool() {
+		je.LastPrice = new(match.Price)
+		*je.LastPrice = dec.ReadPrice()
+	}
+
+	if dec.ReadBool() {
+		je.PlacedStopOrder = new(match.StopOrderIDPair)
+		dec.SetErr(je.PlacedStopOrder.Deserialize(dec.ReadBytes()))
+	}
+
+	if dec.ReadBool() {
+		je.TriggeredStopOrder = new(match.OrderID)
+		dec.ReadFixed(je.TriggeredStopOrder[:])
+	}
+
+	dec.ReadFixed(je.AuctionID[:])
+
+	if dec.ReadBool() {
+		je.PlacedAuctionOrder = new(match.AuctionOrderIDPair)
+		dec.SetErr(je.PlacedAuctionOrder.Deserialize(dec.ReadBytes()))
+	}
+
+	if dec.ReadBool() {
+		je.AuctionExecution = new(match.PriceExecution)
+		dec.SetErr(je.AuctionExecution.Deserialize(dec.ReadBytes()))
+	}
+
+	if dec.ReadBool() {
+		je.NonceUse = new(NonceUse)
+		dec.ReadFixed(je.NonceUse.Pubkey[:])
+		je.NonceUse.Nonce = dec.ReadUint64()
+		je.NonceUse.Expiry = dec.ReadUint64()
+	}
+
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing journal entry: %s", err)
+		return
+	}
+	return
+}
+
+// writeSettlementExecs writes a list of settlement executions
+func writeSettlementExecs(enc *match.Encoder, setExecs []*match.SettlementExecution) {
+	enc.WriteUint32(uint32(len(setExecs)))
+	for _, setExec := range setExecs {
+		enc.WriteBytes(setExec.Serialize())
+	}
+	return
+}
+
+// readSettlementExecs reads a list of settlement executions written with writeSettlementExecs
+func readSettlementExecs(dec *match.Decoder) (setExecs []*match.SettlementExecution) {
+	for i, count := 0, dec.ReadCount(4); i < count; i++ {
+		setExec := new(match.SettlementExecution)
+		dec.SetErr(setExec.Deserialize(dec.ReadBytes()))
+		setExecs = append(setExecs, setExec)
+	}
+	return
+}
+
+// writeCancelledOrder writes a cancelled order as its optional order ID
+func writeCancelledOrder(enc *match.Encoder, cancelled *match.CancelledOrder) {
+	enc.WriteBool(cancelled != nil && cancelled.OrderID != nil)
+	if cancelled != nil && cancelled.OrderID != nil {
+		enc.WriteFixed(cancelled.OrderID[:])
+	}
+	return
+}
+
+// readCancelledOrder reads a cancelled order written with writeCancelledOrder
+func readCancelledOrder(dec *match.Decoder) (cancelled *match.CancelledOrder) {
+	cancelled = new(match.CancelledOrder)
+	if dec.ReadBool() {
+		cancelled.OrderID = new(match.OrderID)
+		dec.ReadFixed(cancelled.OrderID[:])
+	}
+	return
+}
+
+// VerifyJournal checks that the entries start at the beginning of the journal, have the correct sequence
+// numbers, and that each entry commits to the hash of the one before it. This returns the hash of the last
+// entry, which commits to the entire journal.
+func VerifyJournal(entries []*JournalEntry) (headHash [32]byte, err error) {
+	for i, entry := range entries {
+		if entry.Sequence != uint64(i) {
+			err = fmt.Errorf("Error verifying journal, entry %d has sequence number %d", i, entry.Sequence)
+			return
+		}
+
+		if entry.PrevHash != headHash {
+			err = fmt.Errorf("Error verifying journal, entry %d has previous hash %x but should have %x", i, entry.PrevHash, headHash)
+			return
+		}
+
+		if headHash, err = entry.Hash(); err != nil {
+			err = fmt.Errorf("Error hashing entry %d for VerifyJournal: %s", i, err)
+			return
+		}
+	}
+	return
+}
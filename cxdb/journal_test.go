@@ -0,0 +1,97 @@
+package cxdb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestJournalEntrySerialize serializes an entry with every field set, making sure it deserializes to the
+// same entry and serializes to the same bytes again.
+func TestJournalEntrySerialize(t *testing.T) {
+	var err error
+
+	var pubkey [33]byte
+	pubkey[0] = 0x02
+	pair := match.Pair{AssetWant: match.Asset(0x01), AssetHave: match.Asset(0x02)}
+	timestamp := time.Unix(1600000000, 5)
+	orderID := match.OrderID{0x33}
+	stopID := match.OrderID{0x44}
+	order := &match.LimitOrder{
+		Pubkey:      pubkey,
+		Side:        match.Buy,
+		TradingPair: pair,
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+
+	entry := &JournalEntry{
+		Sequence:   7,
+		PrevHash:   [32]byte{0x11},
+		Type:       JournalPlace,
+		Pair:       pair,
+		PairStatus: match.PairHalted,
+		SettlementExecs: []*match.SettlementExecution{
+			{Pubkey: pubkey, Amount: 100, Asset: pair.AssetWant, Type: match.Debit},
+			{Pubkey: pubkey, Amount: 200, Asset: pair.AssetHave, Type: match.Credit},
+		},
+		FeeExecs:           []*match.SettlementExecution{{Pubkey: pubkey, Amount: 1, Asset: pair.AssetHave, Type: match.Debit}},
+		PlacedOrder:        &match.LimitOrderIDPair{Timestamp: timestamp, Price: match.Price{AmountWant: 2, AmountHave: 1}, OrderID: &orderID, Order: order},
+		OrderExecs:         []*match.OrderExecution{{OrderID: orderID, NewAmountWant: 100, NewAmountHave: 50, Decremented: true}},
+		Trades:             []*match.Trade{{Pair: pair, Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountWant: 50, AmountHave: 100, BuyOrderID: orderID, BuyPubkey: pubkey, MakerSide: match.Sell, Timestamp: timestamp}},
+		CancelledOrders:    []*match.CancelledOrder{{OrderID: &orderID}, {}},
+		CancelledOrder:     &match.CancelledOrder{OrderID: &stopID},
+		LastPrice:          &match.Price{AmountWant: 2, AmountHave: 1},
+		PlacedStopOrder:    &match.StopOrderIDPair{Timestamp: timestamp, OrderID: &stopID, Order: &match.StopOrder{Order: *order, StopPrice: match.Price{AmountWant: 3, AmountHave: 1}}},
+		TriggeredStopOrder: &stopID,
+		AuctionID:          match.AuctionID{0x55},
+		PlacedAuctionOrder: &match.AuctionOrderIDPair{OrderID: orderID, Price: match.Price{AmountWant: 2, AmountHave: 1}, Order: &match.AuctionOrder{Pubkey: pubkey, TradingPair: pair, AmountHave: 10, AmountWant: 20}},
+		AuctionExecution:   &match.PriceExecution{ID: [32]byte{0x66}, Pair: pair, Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountWant: 20, AmountHave: 10, Timestamp: timestamp},
+		NonceUse:           &NonceUse{Pubkey: pubkey, Nonce: 9, Expiry: 1600003600},
+	}
+
+	var entryBytes []byte
+	if entryBytes, err = entry.Serialize(); err != nil {
+		t.Errorf("Error serializing entry for TestJournalEntrySerialize: %s", err)
+		return
+	}
+
+	decoded := new(JournalEntry)
+	if err = decoded.Deserialize(entryBytes); err != nil {
+		t.Errorf("Error deserializing entry for TestJournalEntrySerialize: %s", err)
+		return
+	}
+
+	if !reflect.DeepEqual(decoded, entry) {
+		t.Errorf("Deserialized entry %+v does not equal original %+v for TestJournalEntrySerialize", decoded, entry)
+		return
+	}
+
+	var decodedBytes []byte
+	if decodedBytes, err = decoded.Serialize(); err != nil {
+		t.Errorf("Error serializing deserialized entry for TestJournalEntrySerialize: %s", err)
+		return
+	}
+
+	if !bytes.Equal(decodedBytes, entryBytes) {
+		t.Errorf("Deserialized entry does not serialize to the same bytes for TestJournalEntrySerialize")
+		return
+	}
+
+	// An entry with nothing set is just the fixed fields, counts, and flags
+	var emptyBytes []byte
+	if emptyBytes, err = new(JournalEntry).Serialize(); err != nil {
+		t.Errorf("Error serializing empty entry for TestJournalEntrySerialize: %s", err)
+		return
+	}
+
+	if err = new(JournalEntry).Deserialize(append(emptyBytes, 0x00)); err == nil {
+		t.Errorf("Entry with extra bytes at the end was deserialized for TestJournalEntrySerialize")
+		return
+	}
+
+	return
+}
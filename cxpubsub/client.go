@@ -0,0 +1,175 @@
+package cxpubsub
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxnoise"
+	"github.com/mit-dci/opencx/match"
+)
+
+// Client subscribes to market data from a publisher. It checks the sequence numbers of the messages it
+// gets, and if an update was missed it asks for a new snapshot and ignores the topic's updates until the
+// snapshot comes. It also keeps the book for each pair with a book subscription up to date.
+// A client can't be used concurrently.
+type Client struct {
+	conn net.Conn
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+
+	// subscribed are the topics that have been subscribed to, and synced are the ones that have a
+	// snapshot that every update since has been applied to
+	subscribed map[Topic]bool
+	synced     map[Topic]bool
+	sequences  map[Topic]uint64
+	books      map[match.Pair]*match.Depth
+}
+
+// NewClient creates a client that uses a connection to a publisher
+func NewClient(conn net.Conn) (client *Client) {
+	client = &Client{
+		conn:       conn,
+		enc:        gob.NewEncoder(conn),
+		dec:        gob.NewDecoder(conn),
+		subscribed: make(map[Topic]bool),
+		synced:     make(map[Topic]bool),
+		sequences:  make(map[Topic]uint64),
+		books:      make(map[match.Pair]*match.Depth),
+	}
+	return
+}
+
+// Dial connects to a publisher over the noise protocol
+func Dial(privkey *koblitz.PrivateKey, server string, port uint16) (client *Client, err error) {
+	if privkey == nil {
+		err = fmt.Errorf("Please set the key for the market data client to create a connection")
+		return
+	}
+
+	serverAddr := net.JoinHostPort(server, fmt.Sprintf("%d", port))
+
+	var clientConn *cxnoise.Conn
+	if clientConn, err = cxnoise.Dial(privkey, serverAddr, []byte("opencx"), net.Dial); err != nil {
+		err = fmt.Errorf("Error dialing market data server: %s", err)
+		return
+	}
+
+	client = NewClient(clientConn)
+	return
+}
+
+// Subscribe subscribes to topics. The first message for each of them is a snapshot.
+func (c *Client) Subscribe(topics ...Topic) (err error) {
+	for _, topic := range topics {
+		c.subscribed[topic] = true
+		c.synced[topic] = false
+	}
+
+	if err = c.enc.Encode(&Request{Type: SubscribeRequest, Topics: topics}); err != nil {
+		err = fmt.Errorf("Error sending subscribe request: %s", err)
+		return
+	}
+	return
+}
+
+// Unsubscribe stops getting messages for topics
+func (c *Client) Unsubscribe(topics ...Topic) (err error) {
+	for _, topic := range topics {
+		delete(c.subscribed, topic)
+		delete(c.synced, topic)
+		delete(c.sequences, topic)
+		if topic.Channel == BookChannel {
+			delete(c.books, topic.Pair)
+		}
+	}
+
+	if err = c.enc.Encode(&Request{Type: UnsubscribeRequest, Topics: topics}); err != nil {
+		err = fmt.Errorf("Error sending unsubscribe request: %s", err)
+		return
+	}
+	return
+}
+
+// Next waits for the next snapshot or update that follows the messages before it, and returns it once the
+// client's book has been updated with it. Messages that are out of order are never returned, and an error
+// is returned if the publisher sends one.
+func (c *Client) Next() (msg *Message, err error) {
+	for {
+		msg = new(Message)
+		if err = c.dec.Decode(msg); err != nil {
+			err = fmt.Errorf("Error reading market data message: %s", err)
+			return
+		}
+
+		// Messages can still come for a topic right after unsubscribing from it
+		if msg.Type != ErrorMessage && !c.subscribed[msg.Topic] {
+			continue
+		}
+
+		switch msg.Type {
+		case ErrorMessage:
+			err = fmt.Errorf("Error from market data server for %s: %s", msg.Topic.String(), msg.Error)
+			return
+		case SnapshotMessage:
+			if msg.Topic.Channel == BookChannel {
+				if msg.Depth == nil {
+					err = fmt.Errorf("Book snapshot for %s has no depth", msg.Topic.String())
+					return
+				}
+				c.books[msg.Topic.Pair] = copyDepth(msg.Depth)
+			}
+
+			c.sequences[msg.Topic] = msg.Sequence
+			c.synced[msg.Topic] = true
+			return
+		case UpdateMessage:
+			// Until the snapshot comes, updates can't be applied to anything
+			if !c.synced[msg.Topic] {
+				continue
+			}
+
+			// An update from before the last snapshot
+			if msg.Sequence <= c.sequences[msg.Topic] {
+				continue
+			}
+
+			if msg.Sequence != c.sequences[msg.Topic]+1 {
+				c.synced[msg.Topic] = false
+				if err = c.enc.Encode(&Request{Type: ResyncRequest, Topics: []Topic{msg.Topic}}); err != nil {
+					err = fmt.Errorf("Error sending resync request: %s", err)
+					return
+				}
+				continue
+			}
+
+			if msg.Topic.Channel == BookChannel {
+				applyBookUpdates(c.books[msg.Topic.Pair], msg.BookUpdates)
+			}
+
+			c.sequences[msg.Topic] = msg.Sequence
+			return
+		}
+	}
+}
+
+// Depth returns the client's copy of the book for a pair, which is only up to date if the pair's book
+// has been subscribed to and Next has returned its snapshot.
+func (c *Client) Depth(pair match.Pair) (depth *match.Depth, err error) {
+	var book *match.Depth
+	var ok bool
+	if book, ok = c.books[pair]; !ok || !c.synced[Topic{Pair: pair, Channel: BookChannel}] {
+		err = fmt.Errorf("Book for pair %s is not synced", pair.String())
+		return
+	}
+
+	depth = copyDepth(book)
+	return
+}
+
+// Close closes the connection to the publisher
+func (c *Client) Close() (err error) {
+	err = c.conn.Close()
+	return
+}
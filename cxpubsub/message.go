@@ -0,0 +1,206 @@
+package cxpubsub
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// MessageType is the kind of message the publisher sends
+type MessageType uint8
+
+const (
+	// SnapshotMessage has everything the topic has right now, which updates are applied to. It's sent when
+	// a client subscribes or resyncs.
+	SnapshotMessage MessageType = iota
+	// UpdateMessage has what changed since the message with the sequence number before it
+	UpdateMessage
+	// ErrorMessage is sent when a request for a topic can't be done
+	ErrorMessage
+)
+
+const (
+	snapshotString = "snapshot" // just for string representation
+	updateString   = "update"   // just for string representation
+	errorString    = "error"    // just for string representation
+)
+
+// String returns the string representation of the message type
+func (mt MessageType) String() string {
+	switch mt {
+	case SnapshotMessage:
+		return snapshotString
+	case UpdateMessage:
+		return updateString
+	case ErrorMessage:
+		return errorString
+	}
+	return "unknown"
+}
+
+// MarshalJSON implements the JSON marshalling interface, as the message type's name
+func (mt MessageType) MarshalJSON() (b []byte, err error) {
+	b, err = json.Marshal(mt.String())
+	return
+}
+
+// UnmarshalJSON implements the JSON unmarshalling interface, from the message type's name
+func (mt *MessageType) UnmarshalJSON(b []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(b, &str); err != nil {
+		return
+	}
+	switch str {
+	default:
+		err = fmt.Errorf("Cannot unmarshal message type json, not snapshot, update, or error")
+		return
+	case snapshotString:
+		*mt = SnapshotMessage
+	case updateString:
+		*mt = UpdateMessage
+	case errorString:
+		*mt = ErrorMessage
+	}
+	return
+}
+
+// Message is what the publisher sends to subscribers. Each topic's snapshot and update messages are
+// numbered, and a snapshot has the number of the last update it includes, so the next update for the
+// topic always has the number one more than the message before it. If it doesn't, an update was missed,
+// and the client has to resync to get a new snapshot.
+type Message struct {
+	Type     MessageType `json:"type"`
+	Topic    Topic       `json:"topic"`
+	Sequence uint64      `json:"sequence"`
+
+	// Depth is the depth of the book in a book snapshot
+	Depth *match.Depth `json:"depth,omitempty"`
+	// BookUpdates are the levels that changed in a book update
+	BookUpdates []*BookUpdate `json:"bookupdates,omitempty"`
+	// Executions are the newest trades or auction results in a snapshot, oldest first, or the new ones
+	// in an update
+	Executions []*match.PriceExecution `json:"executions,omitempty"`
+
+	// Error is why the request failed, for error messages
+	Error string `json:"error,omitempty"`
+}
+
+// BookUpdate is a price level on a side of the book that changed. A level with no orders was removed.
+type BookUpdate struct {
+	Side  match.Side       `json:"side"`
+	Level match.DepthLevel `json:"level"`
+}
+
+// RequestType is the kind of request a client sends
+type RequestType uint8
+
+const (
+	// SubscribeRequest subscribes to topics, and gets a snapshot of each of them
+	SubscribeRequest RequestType = iota
+	// UnsubscribeRequest stops sending messages for topics
+	UnsubscribeRequest
+	// ResyncRequest gets a new snapshot of topics that have already been subscribed to
+	ResyncRequest
+)
+
+// Request is what clients send to the publisher
+type Request struct {
+	Type   RequestType
+	Topics []Topic
+}
+
+// diffDepth returns the levels that are different in the new depth than the old depth, which are removed
+// if they aren't in the new depth. Levels are the same if their prices are equal, even if they aren't
+// written the same way.
+func diffDepth(oldDepth *match.Depth, newDepth *match.Depth) (updates []*BookUpdate) {
+	updates = append(updates, diffLevels(match.Buy, oldDepth.Buys, newDepth.Buys)...)
+	updates = append(updates, diffLevels(match.Sell, oldDepth.Sells, newDepth.Sells)...)
+	return
+}
+
+// diffLevels returns the updates for one side of the book
+func diffLevels(side match.Side, oldLevels []*match.DepthLevel, newLevels []*match.DepthLevel) (updates []*BookUpdate) {
+	oldByPrice := make(map[match.Price]*match.DepthLevel)
+	for _, level := range oldLevels {
+		oldByPrice[level.Price.Reduce()] = level
+	}
+
+	newByPrice := make(map[match.Price]*match.DepthLevel)
+	for _, level := range newLevels {
+		price := level.Price.Reduce()
+		newByPrice[price] = level
+
+		if oldLevel, ok := oldByPrice[price]; ok && sameTotals(oldLevel, level) {
+			continue
+		}
+		updates = append(updates, &BookUpdate{Side: side, Level: *level})
+	}
+
+	for _, level := range oldLevels {
+		if _, ok := newByPrice[level.Price.Reduce()]; !ok {
+			updates = append(updates, &BookUpdate{Side: side, Level: match.DepthLevel{Price: level.Price}})
+		}
+	}
+	return
+}
+
+// sameTotals returns true if two levels at the same price have the same amounts and number of orders
+func sameTotals(a *match.DepthLevel, b *match.DepthLevel) bool {
+	return a.AmountHave == b.AmountHave && a.AmountWant == b.AmountWant && a.Orders == b.Orders
+}
+
+// applyBookUpdates applies book updates to a depth, keeping the levels in the order they would be matched
+// in, like NewDepth does
+func applyBookUpdates(depth *match.Depth, updates []*BookUpdate) {
+	for _, update := range updates {
+		if update.Side == match.Buy {
+			depth.Buys = applyLevel(match.Buy, depth.Buys, update.Level)
+		} else {
+			depth.Sells = applyLevel(match.Sell, depth.Sells, update.Level)
+		}
+	}
+	return
+}
+
+// applyLevel replaces, adds, or removes the level with the same price as the updated level
+func applyLevel(side match.Side, levels []*match.DepthLevel, updated match.DepthLevel) (newLevels []*match.DepthLevel) {
+	inserted := updated.Orders == 0
+	for _, level := range levels {
+		cmp := level.Price.Cmp(&updated.Price)
+		if cmp == 0 {
+			continue
+		}
+
+		// Buy prices are matched lowest first and sell prices highest first, like in PrioritizeOrderbookPTP
+		if !inserted && ((side == match.Buy && cmp > 0) || (side == match.Sell && cmp < 0)) {
+			newLevels = append(newLevels, copyLevel(updated))
+			inserted = true
+		}
+		newLevels = append(newLevels, level)
+	}
+
+	if !inserted {
+		newLevels = append(newLevels, copyLevel(updated))
+	}
+	return
+}
+
+// copyLevel returns a pointer to a copy of a level
+func copyLevel(level match.DepthLevel) (levelCopy *match.DepthLevel) {
+	levelCopy = new(match.DepthLevel)
+	*levelCopy = level
+	return
+}
+
+// copyDepth returns a copy of a depth that can be changed without changing the original
+func copyDepth(depth *match.Depth) (depthCopy *match.Depth) {
+	depthCopy = &match.Depth{Pair: depth.Pair}
+	for _, level := range depth.Buys {
+		depthCopy.Buys = append(depthCopy.Buys, copyLevel(*level))
+	}
+	for _, level := range depth.Sells {
+		depthCopy.Sells = append(depthCopy.Sells, copyLevel(*level))
+	}
+	return
+}
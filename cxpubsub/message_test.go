@@ -0,0 +1,83 @@
+package cxpubsub
+
+import (
+	"testing"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// depthsEqual returns true if two depths have the same levels in the same order
+func depthsEqual(a *match.Depth, b *match.Depth) bool {
+	if a.Pair != b.Pair || len(a.Buys) != len(b.Buys) || len(a.Sells) != len(b.Sells) {
+		return false
+	}
+	for i := range a.Buys {
+		if *a.Buys[i] != *b.Buys[i] {
+			return false
+		}
+	}
+	for i := range a.Sells {
+		if *a.Sells[i] != *b.Sells[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiffAndApplyDepth(t *testing.T) {
+	pair := match.Pair{AssetWant: match.BTCTest, AssetHave: match.LTCTest}
+	oldDepth := &match.Depth{
+		Pair: pair,
+		Buys: []*match.DepthLevel{
+			{Price: match.Price{AmountWant: 1, AmountHave: 1}, AmountHave: 10, AmountWant: 10, Orders: 1},
+			{Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountHave: 5, AmountWant: 10, Orders: 2},
+		},
+		Sells: []*match.DepthLevel{
+			{Price: match.Price{AmountWant: 4, AmountHave: 1}, AmountHave: 8, AmountWant: 2, Orders: 1},
+			{Price: match.Price{AmountWant: 3, AmountHave: 1}, AmountHave: 6, AmountWant: 2, Orders: 1},
+		},
+	}
+
+	// One buy level is removed, one is added in the middle, one sell level changes, and the other sell
+	// level is written differently but is the same price, so it shouldn't change
+	newDepth := &match.Depth{
+		Pair: pair,
+		Buys: []*match.DepthLevel{
+			{Price: match.Price{AmountWant: 3, AmountHave: 2}, AmountHave: 4, AmountWant: 6, Orders: 1},
+			{Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountHave: 5, AmountWant: 10, Orders: 2},
+		},
+		Sells: []*match.DepthLevel{
+			{Price: match.Price{AmountWant: 8, AmountHave: 2}, AmountHave: 8, AmountWant: 2, Orders: 1},
+			{Price: match.Price{AmountWant: 3, AmountHave: 1}, AmountHave: 12, AmountWant: 4, Orders: 2},
+		},
+	}
+
+	updates := diffDepth(oldDepth, newDepth)
+	if len(updates) != 3 {
+		t.Errorf("There should have been 3 updates but there were %d", len(updates))
+		return
+	}
+
+	applied := copyDepth(oldDepth)
+	applyBookUpdates(applied, updates)
+
+	// The sell level that was written differently keeps the old price
+	newDepth.Sells[0].Price = match.Price{AmountWant: 4, AmountHave: 1}
+	if !depthsEqual(applied, newDepth) {
+		t.Errorf("Applying the updates to the old depth should have made the new depth")
+		return
+	}
+
+	// The old depth shouldn't have changed
+	if len(oldDepth.Buys) != 2 || oldDepth.Buys[0].AmountHave != 10 {
+		t.Errorf("Applying updates to a copy of the depth changed the original")
+		return
+	}
+
+	if updates = diffDepth(newDepth, newDepth); len(updates) != 0 {
+		t.Errorf("The same depth shouldn't have any updates but had %d", len(updates))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,386 @@
+package cxpubsub
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxnoise"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+const (
+	// defaultDepthLevels is how many prices on each side of the book are published
+	defaultDepthLevels = 50
+	// defaultRecentExecutions is how many trades or auction results are in a snapshot
+	defaultRecentExecutions = 50
+	// defaultQueueSize is how many messages can be waiting to be sent to a subscriber
+	defaultQueueSize = 1024
+)
+
+// Publisher sends market data to clients that subscribe to it. The exchange publishes the book and
+// executions for each pair as they change, and the publisher keeps the latest snapshot and sequence
+// number for each topic so it can send clients a snapshot when they subscribe or resync.
+type Publisher struct {
+	// DepthLevels is how many prices on each side of the book are published, RecentExecutions is how many
+	// trades or auction results are in a snapshot, and QueueSize is how many messages can be waiting to
+	// be sent to a subscriber. Set them before anything is published.
+	DepthLevels      uint64
+	RecentExecutions int
+	QueueSize        int
+
+	// topics are protected by pubMtx
+	topics map[Topic]*topicState
+	pubMtx *sync.Mutex
+
+	listener net.Listener
+}
+
+// topicState is the latest snapshot and sequence number for a topic, and who is subscribed to it
+type topicState struct {
+	sequence uint64
+	// depth is only for book topics, and is replaced rather than changed so snapshots can be sent
+	depth *match.Depth
+	// recent is only for trades and auction topics, oldest first
+	recent      []*match.PriceExecution
+	subscribers map[*subscriber]bool
+}
+
+// subscriber is a connection to a client. Messages are queued so a slow client doesn't hold up the
+// exchange, and if an update doesn't fit in the queue it's dropped, so the client sees a gap and resyncs.
+type subscriber struct {
+	// name is who the client is, for logging
+	name string
+	// send sends a message to the client, and closeConn closes the connection to it
+	send      func(msg *Message) error
+	closeConn func() error
+	queue     chan *Message
+	done      chan bool
+}
+
+// Session is a client's subscriptions, for serving market data over something other than a gob connection.
+// Serve uses one for each connection.
+type Session struct {
+	publisher *Publisher
+	sub       *subscriber
+}
+
+// NewPublisher creates a publisher with no pairs
+func NewPublisher() (publisher *Publisher) {
+	publisher = &Publisher{
+		DepthLevels:      defaultDepthLevels,
+		RecentExecutions: defaultRecentExecutions,
+		QueueSize:        defaultQueueSize,
+		topics:           make(map[Topic]*topicState),
+		pubMtx:           new(sync.Mutex),
+	}
+	return
+}
+
+// AddPair adds every channel for a pair, so clients can subscribe to them. Publishing anything for a pair
+// adds it as well.
+func (p *Publisher) AddPair(pair match.Pair) {
+	p.pubMtx.Lock()
+	p.addPair(pair)
+	p.pubMtx.Unlock()
+	return
+}
+
+// addPair adds every channel for a pair if it hasn't been added. This assumes the publisher mutex is held.
+func (p *Publisher) addPair(pair match.Pair) {
+	for _, channel := range Channels {
+		topic := Topic{Pair: pair, Channel: channel}
+		if _, ok := p.topics[topic]; ok {
+			continue
+		}
+
+		state := &topicState{subscribers: make(map[*subscriber]bool)}
+		if channel == BookChannel {
+			state.depth = &match.Depth{Pair: pair}
+		}
+		p.topics[topic] = state
+	}
+	return
+}
+
+// PublishBook publishes the book for a pair, sending the levels that changed since the book was last
+// published to everyone subscribed to it. Nothing is sent if none of the published levels changed.
+// This should be called with whatever protects the book held, so books are published in the order they
+// changed in.
+func (p *Publisher) PublishBook(pair match.Pair, book map[match.Price][]*match.LimitOrderIDPair) (err error) {
+	var depth *match.Depth
+	if depth, err = match.NewDepth(pair, book, p.DepthLevels); err != nil {
+		err = fmt.Errorf("Error getting depth for PublishBook: %s", err)
+		return
+	}
+
+	p.pubMtx.Lock()
+	p.addPair(pair)
+	state := p.topics[Topic{Pair: pair, Channel: BookChannel}]
+
+	updates := diffDepth(state.depth, depth)
+	state.depth = depth
+	if len(updates) == 0 {
+		p.pubMtx.Unlock()
+		return
+	}
+
+	state.sequence++
+	p.sendToSubscribers(state, &Message{
+		Type:        UpdateMessage,
+		Topic:       Topic{Pair: pair, Channel: BookChannel},
+		Sequence:    state.sequence,
+		BookUpdates: updates,
+	})
+	p.pubMtx.Unlock()
+	return
+}
+
+// PublishExecutions publishes new trades or auction results for a pair to everyone subscribed to them.
+// Like PublishBook, this should be called in the order the executions happened in.
+func (p *Publisher) PublishExecutions(channel Channel, pair match.Pair, execs []*match.PriceExecution) (err error) {
+	if channel != TradesChannel && channel != AuctionChannel {
+		err = fmt.Errorf("Cannot publish executions to the %s channel", channel.String())
+		return
+	}
+
+	for _, exec := range execs {
+		if exec == nil {
+			err = fmt.Errorf("Cannot publish nil execution, please enter valid input")
+			return
+		}
+
+		if exec.Pair != pair {
+			err = fmt.Errorf("Cannot publish execution for pair %s to pair %s", exec.Pair.String(), pair.String())
+			return
+		}
+	}
+
+	if len(execs) == 0 {
+		return
+	}
+
+	p.pubMtx.Lock()
+	p.addPair(pair)
+	topic := Topic{Pair: pair, Channel: channel}
+	state := p.topics[topic]
+
+	state.recent = append(state.recent, execs...)
+	if len(state.recent) > p.RecentExecutions {
+		state.recent = append([]*match.PriceExecution{}, state.recent[len(state.recent)-p.RecentExecutions:]...)
+	}
+
+	state.sequence++
+	p.sendToSubscribers(state, &Message{
+		Type:       UpdateMessage,
+		Topic:      topic,
+		Sequence:   state.sequence,
+		Executions: execs,
+	})
+	p.pubMtx.Unlock()
+	return
+}
+
+// sendToSubscribers queues an update for everyone subscribed to a topic. This assumes the publisher mutex
+// is held.
+func (p *Publisher) sendToSubscribers(state *topicState, msg *Message) {
+	for sub := range state.subscribers {
+		select {
+		case sub.queue <- msg:
+		default:
+			// The client will see the gap in sequence numbers once it catches up
+		}
+	}
+	return
+}
+
+// snapshot returns a snapshot of a topic. This assumes the publisher mutex is held.
+func (p *Publisher) snapshot(topic Topic, state *topicState) (msg *Message) {
+	msg = &Message{
+		Type:     SnapshotMessage,
+		Topic:    topic,
+		Sequence: state.sequence,
+	}
+
+	if topic.Channel == BookChannel {
+		msg.Depth = state.depth
+	} else {
+		msg.Executions = append([]*match.PriceExecution{}, state.recent...)
+	}
+	return
+}
+
+// sendNow queues a snapshot or error for a subscriber. Unlike updates these can't be dropped, since the
+// client would wait for them forever, so the connection is closed if the queue is full.
+// This assumes the publisher mutex is held.
+func (p *Publisher) sendNow(sub *subscriber, msg *Message) {
+	select {
+	case sub.queue <- msg:
+	default:
+		logging.Warnf("Closing market data connection from %s that is too far behind", sub.name)
+		sub.closeConn()
+	}
+	return
+}
+
+// handleRequest subscribes, unsubscribes, or resyncs the topics in a request
+func (p *Publisher) handleRequest(sub *subscriber, req *Request) {
+	p.pubMtx.Lock()
+	for _, topic := range req.Topics {
+		var state *topicState
+		var ok bool
+		if state, ok = p.topics[topic]; !ok {
+			p.sendNow(sub, &Message{Type: ErrorMessage, Topic: topic, Error: fmt.Sprintf("Could not find topic %s", topic.String())})
+			continue
+		}
+
+		switch req.Type {
+		case SubscribeRequest:
+			state.subscribers[sub] = true
+			p.sendNow(sub, p.snapshot(topic, state))
+		case UnsubscribeRequest:
+			delete(state.subscribers, sub)
+		case ResyncRequest:
+			if !state.subscribers[sub] {
+				p.sendNow(sub, &Message{Type: ErrorMessage, Topic: topic, Error: fmt.Sprintf("Cannot resync topic %s without subscribing to it", topic.String())})
+				continue
+			}
+			p.sendNow(sub, p.snapshot(topic, state))
+		default:
+			p.sendNow(sub, &Message{Type: ErrorMessage, Topic: topic, Error: fmt.Sprintf("Unknown request type %d", req.Type)})
+		}
+	}
+	p.pubMtx.Unlock()
+	return
+}
+
+// NewSession creates a session for a client. Messages for the topics it subscribes to are passed to send
+// in order, one at a time, until it's closed. If the client is too far behind, or send returns an error,
+// closeConn is called, which should make whatever is reading the client's requests close the session.
+func (p *Publisher) NewSession(name string, send func(msg *Message) error, closeConn func() error) (session *Session) {
+	session = &Session{
+		publisher: p,
+		sub: &subscriber{
+			name:      name,
+			send:      send,
+			closeConn: closeConn,
+			queue:     make(chan *Message, p.QueueSize),
+			done:      make(chan bool),
+		},
+	}
+
+	go session.sub.write()
+	return
+}
+
+// Request subscribes, unsubscribes, or resyncs the topics in a request. Snapshots and errors for the
+// request are sent like any other message.
+func (s *Session) Request(req *Request) {
+	s.publisher.handleRequest(s.sub, req)
+	return
+}
+
+// Close unsubscribes the session from everything and stops sending it messages. It doesn't close the
+// connection.
+func (s *Session) Close() {
+	// Once it's removed from every topic nothing else is queued
+	s.publisher.pubMtx.Lock()
+	for _, state := range s.publisher.topics {
+		delete(state.subscribers, s.sub)
+	}
+	s.publisher.pubMtx.Unlock()
+
+	close(s.sub.done)
+	return
+}
+
+// Serve reads requests from a connection and sends it the messages for the topics it subscribes to, until
+// the connection is closed. The connection is closed when this returns.
+func (p *Publisher) Serve(conn net.Conn) {
+	enc := gob.NewEncoder(conn)
+	send := func(msg *Message) error {
+		return enc.Encode(msg)
+	}
+	session := p.NewSession(conn.RemoteAddr().String(), send, conn.Close)
+
+	dec := gob.NewDecoder(conn)
+	for {
+		req := new(Request)
+		if err := dec.Decode(req); err != nil {
+			break
+		}
+		session.Request(req)
+	}
+
+	session.Close()
+	conn.Close()
+	return
+}
+
+// write sends queued messages to the client until the session is closed. Only this sends messages, since
+// noise connections can't be written to concurrently.
+func (sub *subscriber) write() {
+	for {
+		select {
+		case msg := <-sub.queue:
+			if err := sub.send(msg); err != nil {
+				sub.closeConn()
+				return
+			}
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+// NoiseListen listens for market data connections on a port with the noise protocol, authenticating the
+// exchange with its key
+func (p *Publisher) NoiseListen(privkey *koblitz.PrivateKey, port uint16) (err error) {
+	if p.listener, err = cxnoise.NewListener(privkey, int(port)); err != nil {
+		err = fmt.Errorf("Error creating noise listener for NoiseListen: %s", err)
+		return
+	}
+	logging.Infof("Publishing market data over noise on %s\n", p.listener.Addr().String())
+
+	go p.accept(p.listener)
+	return
+}
+
+// accept accepts connections until the listener is closed
+func (p *Publisher) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			logging.Infof("Stopped accepting market data connections: %s", err)
+			return
+		}
+
+		go p.Serve(conn)
+	}
+}
+
+// Addr returns the address the publisher is listening on, or nil if it isn't listening
+func (p *Publisher) Addr() (addr net.Addr) {
+	if p.listener == nil {
+		return
+	}
+	addr = p.listener.Addr()
+	return
+}
+
+// Stop stops listening for market data connections
+func (p *Publisher) Stop() (err error) {
+	if p.listener == nil {
+		err = fmt.Errorf("Error, cannot stop a listener that doesn't exist")
+		return
+	}
+
+	if err = p.listener.Close(); err != nil {
+		err = fmt.Errorf("Error closing market data listener: %s", err)
+		return
+	}
+	return
+}
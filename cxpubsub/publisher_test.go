@@ -0,0 +1,225 @@
+package cxpubsub
+
+import (
+	"encoding/gob"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/match"
+)
+
+var testPair = match.Pair{AssetWant: match.BTCTest, AssetHave: match.LTCTest}
+
+// addTestOrder adds an order to a book
+func addTestOrder(book map[match.Price][]*match.LimitOrderIDPair, side match.Side, price match.Price, amountHave uint64) {
+	book[price] = append(book[price], &match.LimitOrderIDPair{
+		Timestamp: time.Now(),
+		Price:     price,
+		OrderID:   new(match.OrderID),
+		Order: &match.LimitOrder{
+			Side:        side,
+			TradingPair: testPair,
+			AmountHave:  amountHave,
+			AmountWant:  amountHave,
+		},
+	})
+	return
+}
+
+func TestPublisherSubscribe(t *testing.T) {
+	var err error
+
+	var privkey *koblitz.PrivateKey
+	if privkey, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating private key: %s", err)
+		return
+	}
+
+	publisher := NewPublisher()
+	book := make(map[match.Price][]*match.LimitOrderIDPair)
+	addTestOrder(book, match.Buy, match.Price{AmountWant: 1, AmountHave: 1}, 100)
+	if err = publisher.PublishBook(testPair, book); err != nil {
+		t.Errorf("Error publishing book: %s", err)
+		return
+	}
+
+	if err = publisher.NoiseListen(privkey, 0); err != nil {
+		t.Errorf("Error listening for market data: %s", err)
+		return
+	}
+	defer publisher.Stop()
+
+	var client *Client
+	if client, err = Dial(privkey, "localhost", uint16(publisher.Addr().(*net.TCPAddr).Port)); err != nil {
+		t.Errorf("Error dialing publisher: %s", err)
+		return
+	}
+	defer client.Close()
+
+	bookTopic := Topic{Pair: testPair, Channel: BookChannel}
+	tradesTopic := Topic{Pair: testPair, Channel: TradesChannel}
+	if err = client.Subscribe(bookTopic, tradesTopic); err != nil {
+		t.Errorf("Error subscribing: %s", err)
+		return
+	}
+
+	// The snapshots have the sequence numbers of the last updates they include
+	var msg *Message
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting book snapshot: %s", err)
+		return
+	}
+	if msg.Type != SnapshotMessage || msg.Topic != bookTopic || msg.Sequence != 1 {
+		t.Errorf("First message should have been the book snapshot at sequence 1 but was %s type %d sequence %d", msg.Topic.String(), msg.Type, msg.Sequence)
+		return
+	}
+
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting trades snapshot: %s", err)
+		return
+	}
+	if msg.Type != SnapshotMessage || msg.Topic != tradesTopic || msg.Sequence != 0 {
+		t.Errorf("Second message should have been the trades snapshot at sequence 0 but was %s type %d sequence %d", msg.Topic.String(), msg.Type, msg.Sequence)
+		return
+	}
+
+	addTestOrder(book, match.Sell, match.Price{AmountWant: 2, AmountHave: 1}, 50)
+	if err = publisher.PublishBook(testPair, book); err != nil {
+		t.Errorf("Error publishing book: %s", err)
+		return
+	}
+
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting book update: %s", err)
+		return
+	}
+	if msg.Type != UpdateMessage || msg.Sequence != 2 || len(msg.BookUpdates) != 1 {
+		t.Errorf("Book update should have had one level at sequence 2 but had %d at sequence %d", len(msg.BookUpdates), msg.Sequence)
+		return
+	}
+
+	var expected *match.Depth
+	if expected, err = match.NewDepth(testPair, book, defaultDepthLevels); err != nil {
+		t.Errorf("Error getting depth: %s", err)
+		return
+	}
+
+	var depth *match.Depth
+	if depth, err = client.Depth(testPair); err != nil {
+		t.Errorf("Error getting client depth: %s", err)
+		return
+	}
+
+	if !depthsEqual(depth, expected) {
+		t.Errorf("Client depth should have been the same as the published book")
+		return
+	}
+
+	exec := &match.PriceExecution{Pair: testPair, Price: match.Price{AmountWant: 2, AmountHave: 1}, AmountWant: 10, AmountHave: 20, Timestamp: time.Now()}
+	if err = publisher.PublishExecutions(TradesChannel, testPair, []*match.PriceExecution{exec}); err != nil {
+		t.Errorf("Error publishing trades: %s", err)
+		return
+	}
+
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting trades update: %s", err)
+		return
+	}
+	if msg.Topic != tradesTopic || msg.Sequence != 1 || len(msg.Executions) != 1 || msg.Executions[0].AmountHave != 20 {
+		t.Errorf("Trades update should have had the trade at sequence 1")
+		return
+	}
+
+	// Pairs that were never added can't be subscribed to
+	if err = client.Subscribe(Topic{Pair: match.Pair{AssetWant: match.VTCTest, AssetHave: match.LTCTest}, Channel: BookChannel}); err != nil {
+		t.Errorf("Error subscribing: %s", err)
+		return
+	}
+
+	if _, err = client.Next(); err == nil {
+		t.Errorf("Subscribing to a pair that wasn't added should have returned an error")
+		return
+	}
+
+	return
+}
+
+func TestClientResyncsOnGap(t *testing.T) {
+	var err error
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	client := NewClient(clientConn)
+	defer client.Close()
+
+	bookTopic := Topic{Pair: testPair, Channel: BookChannel}
+	firstDepth := &match.Depth{Pair: testPair, Buys: []*match.DepthLevel{{Price: match.Price{AmountWant: 1, AmountHave: 1}, AmountHave: 10, AmountWant: 10, Orders: 1}}}
+	secondDepth := &match.Depth{Pair: testPair, Sells: []*match.DepthLevel{{Price: match.Price{AmountWant: 3, AmountHave: 1}, AmountHave: 30, AmountWant: 10, Orders: 3}}}
+
+	// This pretends to be a publisher that drops the update with sequence 2
+	resynced := make(chan bool, 1)
+	go func() {
+		enc := gob.NewEncoder(serverConn)
+		dec := gob.NewDecoder(serverConn)
+
+		req := new(Request)
+		if err := dec.Decode(req); err != nil || req.Type != SubscribeRequest {
+			return
+		}
+
+		enc.Encode(&Message{Type: SnapshotMessage, Topic: bookTopic, Sequence: 1, Depth: firstDepth})
+		enc.Encode(&Message{Type: UpdateMessage, Topic: bookTopic, Sequence: 3, BookUpdates: []*BookUpdate{{Side: match.Buy, Level: match.DepthLevel{Price: match.Price{AmountWant: 1, AmountHave: 1}}}}})
+
+		if err := dec.Decode(req); err != nil || req.Type != ResyncRequest || len(req.Topics) != 1 || req.Topics[0] != bookTopic {
+			resynced <- false
+			return
+		}
+		resynced <- true
+
+		// An update that was queued before the resync is ignored, since the client waits for the snapshot
+		enc.Encode(&Message{Type: UpdateMessage, Topic: bookTopic, Sequence: 4})
+		enc.Encode(&Message{Type: SnapshotMessage, Topic: bookTopic, Sequence: 4, Depth: secondDepth})
+	}()
+
+	if err = client.Subscribe(bookTopic); err != nil {
+		t.Errorf("Error subscribing: %s", err)
+		return
+	}
+
+	var msg *Message
+	if msg, err = client.Next(); err != nil || msg.Type != SnapshotMessage {
+		t.Errorf("First message should have been a snapshot: %v", err)
+		return
+	}
+
+	// The gap means the next message should be the new snapshot
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting message after gap: %s", err)
+		return
+	}
+
+	if !<-resynced {
+		t.Errorf("Client should have asked to resync after the gap")
+		return
+	}
+
+	if msg.Type != SnapshotMessage || msg.Sequence != 4 {
+		t.Errorf("Message after the gap should have been the snapshot at sequence 4 but was type %d sequence %d", msg.Type, msg.Sequence)
+		return
+	}
+
+	var depth *match.Depth
+	if depth, err = client.Depth(testPair); err != nil {
+		t.Errorf("Error getting client depth: %s", err)
+		return
+	}
+
+	if !depthsEqual(depth, secondDepth) {
+		t.Errorf("Client depth should have been the snapshot it resynced to")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,87 @@
+package cxpubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// Channel is a kind of market data that can be subscribed to for a pair
+type Channel uint8
+
+const (
+	// BookChannel is the depth of the orderbook, sent as the levels that changed
+	BookChannel Channel = iota
+	// TradesChannel is every limit order trade, without who was in it
+	TradesChannel
+	// AuctionChannel is the price and volume that each auction cleared at
+	AuctionChannel
+)
+
+const (
+	bookString    = "book"    // just for string representation
+	tradesString  = "trades"  // just for string representation
+	auctionString = "auction" // just for string representation
+)
+
+// Channels are all of the channels, which each pair has
+var Channels = []Channel{BookChannel, TradesChannel, AuctionChannel}
+
+// String returns the string representation of the channel
+func (c Channel) String() string {
+	switch c {
+	case BookChannel:
+		return bookString
+	case TradesChannel:
+		return tradesString
+	case AuctionChannel:
+		return auctionString
+	}
+	return "unknown"
+}
+
+// FromString takes a string and, if valid, sets the Channel to the correct value based on the string
+func (c *Channel) FromString(str string) (err error) {
+	switch strings.ToLower(str) {
+	default:
+		err = fmt.Errorf("Cannot get channel from string, not book, trades, or auction")
+		return
+	case bookString:
+		*c = BookChannel
+	case tradesString:
+		*c = TradesChannel
+	case auctionString:
+		*c = AuctionChannel
+	}
+	return
+}
+
+// MarshalJSON implements the JSON marshalling interface, as the channel's name
+func (c Channel) MarshalJSON() (b []byte, err error) {
+	b, err = json.Marshal(c.String())
+	return
+}
+
+// UnmarshalJSON implements the JSON unmarshalling interface, from the channel's name
+func (c *Channel) UnmarshalJSON(b []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(b, &str); err != nil {
+		return
+	}
+	err = c.FromString(str)
+	return
+}
+
+// Topic is a channel for a pair, which is what clients subscribe to. Every topic has its own sequence of
+// messages.
+type Topic struct {
+	Pair    match.Pair `json:"pair"`
+	Channel Channel    `json:"channel"`
+}
+
+// String returns the string representation of the topic
+func (t Topic) String() string {
+	return fmt.Sprintf("%s:%s", t.Pair.String(), t.Channel.String())
+}
@@ -5,10 +5,14 @@ import (
 
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 )
 
 // RegisterArgs holds the args for register
 type RegisterArgs struct {
+	// Challenge is the nonce of a register challenge from GetChallenge, and Signature is a signature of
+	// the challenge
+	Challenge [32]byte
 	Signature []byte
 }
 
@@ -17,12 +21,14 @@ type RegisterReply struct {
 	// empty
 }
 
-// Register registers a pubkey into the db, verifies that the action was signed by that pubkey. A valid signature for the string "register" is considered a valid registration.
+// Register registers a pubkey into the db, verifies that the action was signed by that pubkey. A valid signature of a register challenge is considered a valid registration.
 func (cl *OpencxRPC) Register(args RegisterArgs, reply *RegisterReply) (err error) {
 
 	var pubkey *koblitz.PublicKey
-	if pubkey, err = cl.Server.RegistrationStringVerify(args.Signature); err != nil {
-		err = fmt.Errorf("Error verifying registration string for register RPC command: %s", err)
+	if pubkey, err = cl.authenticate("Register", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.RegisterChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error answering challenge for register RPC command: %s", err)
 		return
 	}
 
@@ -37,18 +43,22 @@ func (cl *OpencxRPC) Register(args RegisterArgs, reply *RegisterReply) (err erro
 	return
 }
 
-// GetRegistrationStringArgs holds the args for register
-type GetRegistrationStringArgs struct {
-	// empty
+// GetChallengeArgs holds the args for the GetChallenge command
+type GetChallengeArgs struct {
+	Action match.ChallengeAction
 }
 
-// GetRegistrationStringReply holds the data for the register reply
-type GetRegistrationStringReply struct {
-	RegistrationString string
+// GetChallengeReply holds the reply for the GetChallenge command
+type GetChallengeReply struct {
+	Challenge *match.Challenge
 }
 
-// GetRegistrationString returns a string to the client which is a valid string to sign to indicate they want their pubkey to be registered. This is like kinda weird but whatever
-func (cl *OpencxRPC) GetRegistrationString(args GetRegistrationStringArgs, reply *GetRegistrationStringReply) (err error) {
-	reply.RegistrationString = cl.Server.GetRegistrationString()
+// GetChallenge issues a challenge for the client to sign, to prove they have the key for a pubkey. Each
+// challenge can be answered once, on the connection it was issued on, before it expires.
+func (cl *OpencxRPC) GetChallenge(args GetChallengeArgs, reply *GetChallengeReply) (err error) {
+	if reply.Challenge, err = cl.Server.NewChallenge(args.Action, cl.remotePub, cl.remoteAddr); err != nil {
+		err = fmt.Errorf("Error issuing challenge for GetChallenge RPC command: %s", err)
+		return
+	}
 	return
 }
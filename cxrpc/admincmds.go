@@ -0,0 +1,89 @@
+package cxrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// checkAdmin returns an error unless the connection was authenticated over noise with the exchange's key.
+// Admin commands can't be used over an unauthenticated connection.
+func (cl *OpencxRPC) checkAdmin() (err error) {
+	if cl.remotePub == nil {
+		err = fmt.Errorf("Admin commands can only be used over an authenticated connection")
+		return
+	}
+
+	var exchangePub *koblitz.PublicKey
+	if exchangePub, err = cl.Server.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey to check admin: %s", err)
+		return
+	}
+
+	if !cl.remotePub.IsEqual(exchangePub) {
+		err = fmt.Errorf("Admin commands can only be used with the exchange's key")
+		return
+	}
+	return
+}
+
+// AddPairArgs holds the args for the AddPair command
+type AddPairArgs struct {
+	Pair *match.Pair
+}
+
+// AddPairReply holds the reply for the AddPair command
+type AddPairReply struct {
+	// empty
+}
+
+// AddPair adds a trading pair while the exchange is running. This can only be done with the exchange's key.
+func (cl *OpencxRPC) AddPair(args AddPairArgs, reply *AddPairReply) (err error) {
+	if _, err = cl.authenticate("AddPair", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating AddPair RPC command: %s", err)
+		return
+	}
+
+	if err = cl.Server.AddPair(args.Pair); err != nil {
+		err = fmt.Errorf("Error adding pair for AddPair RPC command: %s", err)
+		return
+	}
+
+	logging.Infof("Admin added pair %s", args.Pair.PrettyString())
+	return
+}
+
+// SetPairStatusArgs holds the args for the SetPairStatus command
+type SetPairStatusArgs struct {
+	Pair   *match.Pair
+	Status match.PairStatus
+}
+
+// SetPairStatusReply holds the reply for the SetPairStatus command
+type SetPairStatusReply struct {
+	// empty
+}
+
+// SetPairStatus halts, resumes, or delists a pair, or makes it cancel-only or auction-only. Delisting a pair
+// cancels and refunds every order on it. This can only be done with the exchange's key.
+func (cl *OpencxRPC) SetPairStatus(args SetPairStatusArgs, reply *SetPairStatusReply) (err error) {
+	if _, err = cl.authenticate("SetPairStatus", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating SetPairStatus RPC command: %s", err)
+		return
+	}
+
+	if args.Pair == nil {
+		err = fmt.Errorf("Cannot set status for nil pair, please enter valid input")
+		return
+	}
+
+	if err = cl.Server.SetPairStatus(args.Pair, args.Status); err != nil {
+		err = fmt.Errorf("Error setting pair status for SetPairStatus RPC command: %s", err)
+		return
+	}
+
+	logging.Infof("Admin set status of pair %s to %s", args.Pair.PrettyString(), args.Status.String())
+	return
+}
@@ -0,0 +1,138 @@
+package cxrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+)
+
+// AuthPolicy is how an RPC command finds out which pubkey is using it
+type AuthPolicy uint8
+
+const (
+	// PublicAuth commands can be used by anyone, and don't act for a pubkey
+	PublicAuth AuthPolicy = iota
+	// SignatureAuth commands are authorized by a signature in the args, because what's signed has to be
+	// checkable on its own, like an order. What's signed is an envelope with a nonce, so it can't be
+	// replayed.
+	SignatureAuth
+	// SessionOrSignatureAuth commands are authorized by a signature in the args if there is one. If there
+	// isn't, they're authorized by the key the connection was authenticated with over noise. What's signed
+	// is either an envelope with a nonce or the answer to a challenge, so it can't be replayed.
+	SessionOrSignatureAuth
+	// SessionAuth commands can only be used over a connection authenticated with noise, and are authorized
+	// by the key it was authenticated with. Signatures for these commands would have no nonce, so they
+	// could be replayed if they were accepted on their own.
+	SessionAuth
+	// AdminAuth commands can only be used over a connection authenticated with noise with the exchange's
+	// key
+	AdminAuth
+)
+
+// String returns the string representation of an auth policy
+func (ap AuthPolicy) String() string {
+	switch ap {
+	case PublicAuth:
+		return "public"
+	case SignatureAuth:
+		return "signature"
+	case SessionOrSignatureAuth:
+		return "session or signature"
+	case SessionAuth:
+		return "session"
+	case AdminAuth:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// MethodPolicies is the auth policy for every OpencxRPC command, by method name. Commands that aren't in
+// here can't be authorized.
+var MethodPolicies = map[string]AuthPolicy{
+	"GetChallenge":            PublicAuth,
+	"GetExchangePubkey":       PublicAuth,
+	"GetPairs":                PublicAuth,
+	"GetDepth":                PublicAuth,
+	"GetPrice":                PublicAuth,
+	"GetRecentTrades":         PublicAuth,
+	"GetCandles":              PublicAuth,
+	"GetLitConnection":        PublicAuth,
+	"Register":                SignatureAuth,
+	"SubmitOrder":             SignatureAuth,
+	"SubmitStopOrder":         SignatureAuth,
+	"ReplaceOrder":            SignatureAuth,
+	"GetBalance":              SessionOrSignatureAuth,
+	"GetDepositAddress":       SessionOrSignatureAuth,
+	"GetOrder":                SessionOrSignatureAuth,
+	"GetOrdersForPubkey":      SessionOrSignatureAuth,
+	"GetStopOrders":           SessionOrSignatureAuth,
+	"GetMyFills":              SessionOrSignatureAuth,
+	"CancelOrder":             SessionOrSignatureAuth,
+	"CancelStopOrder":         SessionOrSignatureAuth,
+	"Withdraw":                SessionAuth,
+	"WithdrawToLightningNode": SessionAuth,
+	"AddPair":                 AdminAuth,
+	"SetPairStatus":           AdminAuth,
+	"ViewOrderBook":           AdminAuth,
+	"GetFeeRevenue":           AdminAuth,
+}
+
+// RemotePub returns the key the connection was authenticated with over noise, or nil if the connection
+// isn't authenticated
+func (cl *OpencxRPC) RemotePub() (pubkey *koblitz.PublicKey) {
+	pubkey = cl.remotePub
+	return
+}
+
+// authenticate returns the pubkey that is using method, following the method's policy. The signature is
+// the one in the args, and verifySig checks it and returns the pubkey that made it. Public commands have
+// no pubkey.
+func (cl *OpencxRPC) authenticate(method string, signature []byte, verifySig func() (*koblitz.PublicKey, error)) (pubkey *koblitz.PublicKey, err error) {
+	policy, ok := MethodPolicies[method]
+	if !ok {
+		err = fmt.Errorf("No auth policy for %s, it cannot be authorized", method)
+		return
+	}
+
+	switch policy {
+	case PublicAuth:
+		return
+	case SignatureAuth:
+		pubkey, err = verifySig()
+	case SessionOrSignatureAuth:
+		if len(signature) != 0 {
+			pubkey, err = verifySig()
+			return
+		}
+
+		if cl.remotePub == nil {
+			err = fmt.Errorf("%s needs a signature, or a connection authenticated with noise", method)
+			return
+		}
+		pubkey = cl.remotePub
+	case SessionAuth:
+		if cl.remotePub == nil {
+			err = fmt.Errorf("%s can only be used over a connection authenticated with noise", method)
+			return
+		}
+		pubkey = cl.remotePub
+	case AdminAuth:
+		if err = cl.checkAdmin(); err != nil {
+			return
+		}
+		pubkey = cl.remotePub
+	default:
+		err = fmt.Errorf("Unknown auth policy %d for %s", policy, method)
+		return
+	}
+	return
+}
+
+// recoverSigner returns the pubkey that made a compact signature of hash
+func recoverSigner(signature []byte, hash []byte) (pubkey *koblitz.PublicKey, err error) {
+	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), signature, hash); err != nil {
+		err = fmt.Errorf("Invalid signature: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,211 @@
+package cxrpc
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestMethodPolicies makes sure every RPC command has an auth policy, and every policy is for an RPC command
+func TestMethodPolicies(t *testing.T) {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	rpcType := reflect.TypeOf(&OpencxRPC{})
+
+	commands := make(map[string]bool)
+	for i := 0; i < rpcType.NumMethod(); i++ {
+		method := rpcType.Method(i)
+		// RPC commands take args and a reply, and return an error
+		if method.Type.NumIn() != 3 || method.Type.NumOut() != 1 || method.Type.Out(0) != errorType {
+			continue
+		}
+
+		commands[method.Name] = true
+		if _, ok := MethodPolicies[method.Name]; !ok {
+			t.Errorf("RPC command %s has no auth policy for TestMethodPolicies", method.Name)
+			return
+		}
+	}
+
+	for name := range MethodPolicies {
+		if !commands[name] {
+			t.Errorf("Auth policy for %s is not for an RPC command for TestMethodPolicies", name)
+			return
+		}
+	}
+
+	return
+}
+
+// TestSessionAuth uses commands over a connection authenticated with a key, making sure they act for that
+// key without signatures, and that they can't be used without a signature or session when they need one.
+func TestSessionAuth(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	var testServer *httptest.Server
+	if server, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestSessionAuth: %s", err)
+		return
+	}
+	testServer.Close()
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestSessionAuth: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestSessionAuth: %s", err)
+		return
+	}
+
+	var otherPriv *koblitz.PrivateKey
+	if otherPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating other key for TestSessionAuth: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+			t.Errorf("Error debiting user for TestSessionAuth: %s", err)
+			return
+		}
+	}
+
+	session := &OpencxRPC{Server: server, remotePub: priv.PubKey()}
+	otherSession := &OpencxRPC{Server: server, remotePub: otherPriv.PubKey()}
+	unauthenticated := &OpencxRPC{Server: server}
+
+	balanceReply := new(GetBalanceReply)
+	if err = session.GetBalance(GetBalanceArgs{Asset: coinList[0].Name}, balanceReply); err != nil {
+		t.Errorf("Error getting balance over session for TestSessionAuth: %s", err)
+		return
+	}
+
+	if balanceReply.Amount != 1000 {
+		t.Errorf("Expected balance 1000 over session for TestSessionAuth, got %d", balanceReply.Amount)
+		return
+	}
+
+	if err = unauthenticated.GetBalance(GetBalanceArgs{Asset: coinList[0].Name}, new(GetBalanceReply)); err == nil {
+		t.Errorf("Balance was read without a signature or session for TestSessionAuth")
+		return
+	}
+
+	withdrawArgs := WithdrawArgs{Withdrawal: &match.Withdrawal{Asset: pairList[0].AssetHave, Amount: 1, Address: "address"}}
+	if err = unauthenticated.Withdraw(withdrawArgs, new(WithdrawReply)); err == nil {
+		t.Errorf("Withdrawal was made without a session for TestSessionAuth")
+		return
+	}
+
+	if err = session.AddPair(AddPairArgs{Pair: pairList[0]}, new(AddPairReply)); err == nil {
+		t.Errorf("Admin command was used without the exchange's key for TestSessionAuth")
+		return
+	}
+
+	order := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pairList[0],
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+	var orderID *match.OrderID
+	if orderID, err = server.PlaceOrder(order); err != nil {
+		t.Errorf("Error placing order for TestSessionAuth: %s", err)
+		return
+	}
+
+	ordersReply := new(GetOrdersForPubkeyReply)
+	if err = session.GetOrdersForPubkey(GetOrdersForPubkeyArgs{}, ordersReply); err != nil {
+		t.Errorf("Error getting orders over session for TestSessionAuth: %s", err)
+		return
+	}
+
+	if len(ordersReply.Orders) != 1 || *ordersReply.Orders[0].OrderID != *orderID {
+		t.Errorf("Expected the placed order over session for TestSessionAuth, got %d orders", len(ordersReply.Orders))
+		return
+	}
+
+	var orderIDBytes []byte
+	if orderIDBytes, err = orderID.MarshalText(); err != nil {
+		t.Errorf("Error marshalling order ID for TestSessionAuth: %s", err)
+		return
+	}
+
+	// Another session can't cancel the order
+	if err = otherSession.CancelOrder(CancelOrderArgs{OrderID: string(orderIDBytes)}, new(CancelOrderReply)); err == nil {
+		t.Errorf("Order was cancelled by another session for TestSessionAuth")
+		return
+	}
+
+	if err = session.CancelOrder(CancelOrderArgs{OrderID: string(orderIDBytes)}, new(CancelOrderReply)); err != nil {
+		t.Errorf("Error cancelling order over session for TestSessionAuth: %s", err)
+		return
+	}
+
+	return
+}
+
+// TestAdminAuth makes sure the exchange's fee revenue can only be seen over a connection authenticated with
+// the exchange's key.
+func TestAdminAuth(t *testing.T) {
+	var err error
+
+	if MethodPolicies["GetFeeRevenue"] != AdminAuth {
+		t.Errorf("GetFeeRevenue should be an admin command but is %s", MethodPolicies["GetFeeRevenue"].String())
+		return
+	}
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	var testServer *httptest.Server
+	if server, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestAdminAuth: %s", err)
+		return
+	}
+	testServer.Close()
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestAdminAuth: %s", err)
+		return
+	}
+
+	var exchangePub *koblitz.PublicKey
+	if exchangePub, err = server.GetExchangePubkey(); err != nil {
+		t.Errorf("Error getting exchange pubkey for TestAdminAuth: %s", err)
+		return
+	}
+
+	unauthenticated := &OpencxRPC{Server: server}
+	if err = unauthenticated.GetFeeRevenue(GetFeeRevenueArgs{}, new(GetFeeRevenueReply)); err == nil {
+		t.Errorf("Fee revenue was read without a session for TestAdminAuth")
+		return
+	}
+
+	session := &OpencxRPC{Server: server, remotePub: priv.PubKey()}
+	if err = session.GetFeeRevenue(GetFeeRevenueArgs{}, new(GetFeeRevenueReply)); err == nil {
+		t.Errorf("Fee revenue was read by a key that isn't the exchange's for TestAdminAuth")
+		return
+	}
+
+	admin := &OpencxRPC{Server: server, remotePub: exchangePub}
+	if err = admin.GetFeeRevenue(GetFeeRevenueArgs{}, new(GetFeeRevenueReply)); err != nil {
+		t.Errorf("Error getting fee revenue with the exchange's key for TestAdminAuth: %s", err)
+		return
+	}
+
+	return
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/mit-dci/lit/crypto/koblitz"
 	util "github.com/mit-dci/opencx/chainutils"
 	"github.com/mit-dci/opencx/match"
-	"golang.org/x/crypto/sha3"
 )
 
 // GetBalanceArgs hold the arguments for GetBalance
 type GetBalanceArgs struct {
-	Asset     string
+	Asset string
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
 	Signature []byte
 }
 
@@ -24,14 +26,11 @@ type GetBalanceReply struct {
 // GetBalance is the RPC Interface for GetBalance
 func (cl *OpencxRPC) GetBalance(args GetBalanceArgs, reply *GetBalanceReply) (err error) {
 
-	// e = h(asset)
-	sha3 := sha3.New256()
-	sha3.Write([]byte(args.Asset))
-	e := sha3.Sum(nil)
-
 	var pubkey *koblitz.PublicKey
-	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), args.Signature, e); err != nil {
-		err = fmt.Errorf("Error verifying order, invalid signature: \n%s", err)
+	if pubkey, err = cl.authenticate("GetBalance", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating GetBalance RPC command: %s", err)
 		return
 	}
 
@@ -51,7 +50,10 @@ func (cl *OpencxRPC) GetBalance(args GetBalanceArgs, reply *GetBalanceReply) (er
 
 // GetDepositAddressArgs hold the arguments for GetDepositAddress
 type GetDepositAddressArgs struct {
-	Asset     string
+	Asset string
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
 	Signature []byte
 }
 
@@ -63,14 +65,11 @@ type GetDepositAddressReply struct {
 // GetDepositAddress is the RPC Interface for GetDepositAddress
 func (cl *OpencxRPC) GetDepositAddress(args GetDepositAddressArgs, reply *GetDepositAddressReply) (err error) {
 
-	// e = h(asset)
-	sha3 := sha3.New256()
-	sha3.Write([]byte(args.Asset))
-	e := sha3.Sum(nil)
-
 	var pubkey *koblitz.PublicKey
-	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), args.Signature, e); err != nil {
-		err = fmt.Errorf("Error, invalid signature with GetDepositAddress RPC command: %s", err)
+	if pubkey, err = cl.authenticate("GetDepositAddress", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating GetDepositAddress RPC command: %s", err)
 		return
 	}
 
@@ -91,7 +90,6 @@ func (cl *OpencxRPC) GetDepositAddress(args GetDepositAddressArgs, reply *GetDep
 // WithdrawArgs holds the args for Withdraw
 type WithdrawArgs struct {
 	Withdrawal *match.Withdrawal
-	Signature  []byte
 }
 
 // WithdrawReply holds the reply for Withdraw
@@ -99,22 +97,24 @@ type WithdrawReply struct {
 	Txid string
 }
 
-// Withdraw is the RPC Interface for Withdraw
+// Withdraw is the RPC Interface for Withdraw. It can only be used over a connection authenticated with
+// noise, and withdraws from the account of the key the connection was authenticated with.
 func (cl *OpencxRPC) Withdraw(args WithdrawArgs, reply *WithdrawReply) (err error) {
 
-	// e = h(asset)
-	sha3 := sha3.New256()
-	sha3.Write(args.Withdrawal.Serialize())
-	e := sha3.Sum(nil)
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.authenticate("Withdraw", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating withdraw: \n%s", err)
+		return
+	}
 
-	pubkey, _, err := koblitz.RecoverCompact(koblitz.S256(), args.Signature, e)
-	if err != nil {
-		err = fmt.Errorf("Error verifying order, invalid signature: \n%s", err)
+	if args.Withdrawal == nil {
+		err = fmt.Errorf("Cannot withdraw nil withdrawal, please enter valid input")
 		return
 	}
 
 	var coinType *coinparam.Params
-	if coinType, err = util.GetParamFromName(args.Withdrawal.Asset.String()); err != nil {
+	if coinType, err = args.Withdrawal.Asset.CoinParamFromAsset(); err != nil {
+		err = fmt.Errorf("Error getting coin type for withdrawal asset: %s", err)
 		return
 	}
 
@@ -0,0 +1,46 @@
+package cxrpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// maxCandles is the most candles GetCandles will return
+const maxCandles = 1000
+
+// GetCandlesArgs holds the args for the GetCandles command
+type GetCandlesArgs struct {
+	TradingPair *match.Pair
+	Interval    match.CandleInterval
+	// Candles that start at or after Start and before End are returned. If End is zero there is no end.
+	Start time.Time
+	End   time.Time
+}
+
+// GetCandlesReply holds the reply for the GetCandles command
+type GetCandlesReply struct {
+	// Candles are oldest first. If there are more than 1000, only the latest 1000 are returned, and the
+	// rest can be gotten by asking again with End set to the start of the first candle.
+	Candles []*match.Candle
+}
+
+// GetCandles gets the open, high, low, close, and volume for a pair for every interval in a range of time
+func (cl *OpencxRPC) GetCandles(args GetCandlesArgs, reply *GetCandlesReply) (err error) {
+	if args.TradingPair == nil {
+		err = fmt.Errorf("Cannot get candles for nil pair, please enter valid input")
+		return
+	}
+
+	if reply.Candles, err = cl.Server.GetCandles(args.TradingPair, args.Interval, args.Start, args.End); err != nil {
+		err = fmt.Errorf("Error getting candles for GetCandles RPC command: %s", err)
+		return
+	}
+
+	if len(reply.Candles) > maxCandles {
+		reply.Candles = reply.Candles[len(reply.Candles)-maxCandles:]
+	}
+
+	return
+}
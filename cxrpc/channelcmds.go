@@ -53,7 +53,6 @@ func (cl *OpencxRPC) GetLitConnection(args GetLitConnectionArgs, reply *GetLitCo
 // WithdrawToLightningNodeArgs holds the args for the withdrawtolightning RPC command
 type WithdrawToLightningNodeArgs struct {
 	Withdrawal *match.Withdrawal
-	Signature  []byte
 }
 
 // WithdrawToLightningNodeReply holds the reply for the withdrawtolightning RPC command
@@ -62,7 +61,12 @@ type WithdrawToLightningNodeReply struct {
 }
 
 // WithdrawToLightningNode creates a channel that pushes a certain amount to a lightning node through a lightning channel.
+// Like Withdraw, it can only be used over a connection authenticated with noise.
 func (cl *OpencxRPC) WithdrawToLightningNode(args WithdrawToLightningNodeArgs, reply *WithdrawToLightningNodeReply) (err error) {
+	if _, err = cl.authenticate("WithdrawToLightningNode", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating WithdrawToLightningNode RPC command: %s", err)
+		return
+	}
 
 	return
 }
@@ -3,12 +3,21 @@ package cxrpc
 import (
 	"net"
 
+	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/cxserver"
 )
 
 // OpencxRPC is what is registered and called
 type OpencxRPC struct {
 	Server *cxserver.OpencxServer
+
+	// remotePub is the key the connection was authenticated with over noise, or nil if the connection
+	// isn't authenticated
+	remotePub *koblitz.PublicKey
+
+	// remoteAddr is the address the connection is from, or empty if it isn't known, which limits how
+	// many challenges the connection can have waiting to be answered
+	remoteAddr string
 }
 
 // OpencxRPCCaller is a listener for RPC commands
@@ -0,0 +1,34 @@
+package cxrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetFeeRevenueArgs holds the args for the GetFeeRevenue command
+type GetFeeRevenueArgs struct {
+	// empty
+}
+
+// GetFeeRevenueReply holds the reply for the GetFeeRevenue command
+type GetFeeRevenueReply struct {
+	// Revenue is the total amount of each asset that has been paid to the fee account
+	Revenue map[match.Asset]uint64
+}
+
+// GetFeeRevenue returns how much of each asset the exchange has been paid in trading fees. Only the exchange
+// can see this.
+func (cl *OpencxRPC) GetFeeRevenue(args GetFeeRevenueArgs, reply *GetFeeRevenueReply) (err error) {
+	if _, err = cl.authenticate("GetFeeRevenue", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating GetFeeRevenue RPC command: %s", err)
+		return
+	}
+
+	if reply.Revenue, err = cl.Server.GetFeeRevenue(); err != nil {
+		err = fmt.Errorf("Error getting fee revenue for GetFeeRevenue RPC command: %s", err)
+		return
+	}
+
+	return
+}
@@ -43,17 +43,6 @@ func (rpc1 *OpencxRPCCaller) NoiseListenAsync(doneChan chan bool, errChan chan e
 		return
 	}
 
-	// Start noise rpc server (need to do this since the client is a rpc newclient)
-	noiseRPCServer := rpc.NewServer()
-
-	logging.Infof("Registering RPC API over Noise protocol ...")
-	// Register rpc
-	if err = noiseRPCServer.Register(rpc1.caller); err != nil {
-		errChan <- fmt.Errorf("Error registering RPC Interface: %s", err)
-		close(errChan)
-		return
-	}
-
 	logging.Infof("Starting RPC Server over noise protocol")
 	// Start RPC Server
 	if rpc1.listener, err = cxnoise.NewListener(privkey, int(port)); err != nil {
@@ -63,14 +52,63 @@ func (rpc1 *OpencxRPCCaller) NoiseListenAsync(doneChan chan bool, errChan chan e
 	}
 	logging.Infof("Running RPC-Noise server on %s\n", rpc1.listener.Addr().String())
 
-	// We don't need to do anything fancy here either because the noise protocol
-	// is built in to the listener as well.
-	go noiseRPCServer.Accept(rpc1.listener)
+	// The noise protocol is built in to the listener, but each connection gets its own RPC server so
+	// commands know which key the connection was authenticated with.
+	go rpc1.acceptNoise()
 	doneChan <- true
 	close(doneChan)
 	return
 }
 
+// acceptNoise accepts connections from the noise listener until it's closed, serving each one with an RPC
+// server that has the key the connection was authenticated with
+func (rpc1 *OpencxRPCCaller) acceptNoise() {
+	for {
+		conn, err := rpc1.listener.Accept()
+		if err != nil {
+			logging.Infof("Stopped accepting noise connections: %s", err)
+			return
+		}
+
+		noiseConn, ok := conn.(*cxnoise.Conn)
+		if !ok {
+			logging.Errorf("Noise listener returned a connection that isn't a noise connection")
+			conn.Close()
+			continue
+		}
+
+		connRPCServer := rpc.NewServer()
+		if err = connRPCServer.Register(&OpencxRPC{Server: rpc1.caller.Server, remotePub: noiseConn.RemotePub(), remoteAddr: conn.RemoteAddr().String()}); err != nil {
+			logging.Errorf("Error registering RPC Interface for noise connection: %s", err)
+			conn.Close()
+			continue
+		}
+
+		go connRPCServer.ServeConn(conn)
+	}
+}
+
+// accept accepts connections from the listener until it's closed, serving each one with an RPC server
+// that has the address the connection is from
+func (rpc1 *OpencxRPCCaller) accept() {
+	for {
+		conn, err := rpc1.listener.Accept()
+		if err != nil {
+			logging.Infof("Stopped accepting connections: %s", err)
+			return
+		}
+
+		connRPCServer := rpc.NewServer()
+		if err = connRPCServer.Register(&OpencxRPC{Server: rpc1.caller.Server, remoteAddr: conn.RemoteAddr().String()}); err != nil {
+			logging.Errorf("Error registering RPC Interface for connection: %s", err)
+			conn.Close()
+			continue
+		}
+
+		go connRPCServer.ServeConn(conn)
+	}
+}
+
 // RPCListen is a synchronous version of RPCListenAsync
 func (rpc1 *OpencxRPCCaller) RPCListen(host string, port uint16) (err error) {
 
@@ -94,14 +132,6 @@ func (rpc1 *OpencxRPCCaller) RPCListenAsync(doneChan chan bool, errChan chan err
 		return
 	}
 
-	logging.Infof("Registering RPC API...")
-	// Register rpc
-	if err = rpc.Register(rpc1.caller); err != nil {
-		errChan <- fmt.Errorf("Error registering RPC Interface: %s", err)
-		close(errChan)
-		return
-	}
-
 	logging.Infof("Starting RPC Server")
 	// Start RPC Server
 	serverAddr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
@@ -112,7 +142,8 @@ func (rpc1 *OpencxRPCCaller) RPCListenAsync(doneChan chan bool, errChan chan err
 	}
 	logging.Infof("Running RPC server on %s\n", rpc1.listener.Addr().String())
 
-	go rpc.Accept(rpc1.listener)
+	// Each connection gets its own RPC server so commands know which address the connection is from
+	go rpc1.accept()
 	doneChan <- true
 	close(doneChan)
 	return
@@ -0,0 +1,284 @@
+package cxrpc
+
+// restOpenAPI is the OpenAPI document for the REST gateway, which is served at /v1/openapi.json
+const restOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "OpenCX REST gateway",
+    "version": "1.0.0",
+    "description": "HTTP and JSON access to the opencxd RPC commands. Signatures are hex encoded compact secp256k1 signatures of the sha3-256 hash of what is signed, the same as over RPC, and the pubkey is recovered from them. Failed requests respond with an Error."
+  },
+  "paths": {
+    "/v1/pairs": {
+      "get": {
+        "summary": "Get every pair, with its status and the rules its orders have to follow",
+        "responses": {
+          "200": {"description": "The pairs", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pairs"}}}},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/depth": {
+      "get": {
+        "summary": "Get the orderbook for a pair added up by price, with the best prices on each side first",
+        "parameters": [
+          {"$ref": "#/components/parameters/Pair"},
+          {"name": "levels", "in": "query", "required": false, "description": "The most prices to return on each side, 50 by default and at most 500", "schema": {"type": "integer", "minimum": 0, "maximum": 500}}
+        ],
+        "responses": {
+          "200": {"description": "The depth of the book", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Depth"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/price": {
+      "get": {
+        "summary": "Get the price of a pair, based on the midpoint of the volume of bids and asks",
+        "parameters": [{"$ref": "#/components/parameters/Pair"}],
+        "responses": {
+          "200": {"description": "The price", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Price"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/exchangepubkey": {
+      "get": {
+        "summary": "Get the pubkey of the exchange, which order envelopes have to be for",
+        "responses": {
+          "200": {"description": "The exchange pubkey", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ExchangePubkey"}}}},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/orders": {
+      "post": {
+        "summary": "Submit a signed limit order",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubmitOrderRequest"}}}},
+        "responses": {
+          "200": {"description": "The order was placed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubmitOrderResponse"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/cancel": {
+      "post": {
+        "summary": "Cancel an order, signed by the pubkey that placed it",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CancelOrderRequest"}}}},
+        "responses": {
+          "200": {"description": "The order was cancelled", "content": {"application/json": {"schema": {"type": "object"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/challenge": {
+      "post": {
+        "summary": "Get a challenge to sign, to read balances and deposit addresses. Each challenge can be answered once, before it expires.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ChallengeRequest"}}}},
+        "responses": {
+          "200": {"description": "The challenge", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Challenge"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/balance": {
+      "get": {
+        "summary": "Get the balance of the pubkey that answered a read challenge",
+        "parameters": [
+          {"$ref": "#/components/parameters/Asset"},
+          {"$ref": "#/components/parameters/ChallengeNonce"},
+          {"$ref": "#/components/parameters/ChallengeSignature"}
+        ],
+        "responses": {
+          "200": {"description": "The balance", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Balance"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/depositaddress": {
+      "get": {
+        "summary": "Get the deposit address of the pubkey that answered a read challenge",
+        "parameters": [
+          {"$ref": "#/components/parameters/Asset"},
+          {"$ref": "#/components/parameters/ChallengeNonce"},
+          {"$ref": "#/components/parameters/ChallengeSignature"}
+        ],
+        "responses": {
+          "200": {"description": "The deposit address", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DepositAddress"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "422": {"$ref": "#/components/responses/Failed"}
+        }
+      }
+    },
+    "/v1/openapi.json": {
+      "get": {
+        "summary": "Get this document",
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Pair": {"name": "pair", "in": "query", "required": true, "description": "The pair, as asset1/asset2", "schema": {"type": "string", "example": "btc/ltc"}},
+      "Asset": {"name": "asset", "in": "query", "required": true, "description": "The name of the asset's coin", "schema": {"type": "string", "example": "regtest"}},
+      "ChallengeNonce": {"name": "challenge", "in": "query", "required": true, "description": "The nonce of a read challenge from /v1/challenge", "schema": {"$ref": "#/components/schemas/Nonce"}},
+      "ChallengeSignature": {"name": "signature", "in": "query", "required": true, "description": "Signature of the challenge's encoding", "schema": {"$ref": "#/components/schemas/Signature"}}
+    },
+    "responses": {
+      "BadRequest": {"description": "The request could not be parsed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+      "Failed": {"description": "The request was parsed but the exchange could not do it", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {"error": {"type": "string"}},
+        "required": ["error"]
+      },
+      "Signature": {"type": "string", "pattern": "^[0-9a-fA-F]{130}$", "description": "Hex encoded 65 byte compact signature"},
+      "Nonce": {"type": "string", "pattern": "^[0-9a-fA-F]{64}$", "description": "Hex encoded 32 byte challenge nonce"},
+      "Price": {
+        "type": "object",
+        "properties": {"pair": {"type": "string"}, "price": {"type": "number"}}
+      },
+      "RawPrice": {
+        "type": "object",
+        "description": "A price as AmountWant over AmountHave",
+        "properties": {"AmountWant": {"type": "integer"}, "AmountHave": {"type": "integer"}}
+      },
+      "TradingPair": {
+        "type": "object",
+        "description": "A pair, by the ids of its assets",
+        "properties": {"assetWant": {"type": "integer"}, "assetHave": {"type": "integer"}}
+      },
+      "PairRules": {
+        "type": "object",
+        "description": "The rules orders on a pair have to follow. Rules that are zero aren't enforced.",
+        "properties": {
+          "ticksize": {"$ref": "#/components/schemas/RawPrice"},
+          "lotsize": {"type": "integer"},
+          "minvalue": {"type": "integer"},
+          "maxamounthave": {"type": "integer"}
+        }
+      },
+      "Pairs": {
+        "type": "object",
+        "properties": {
+          "pairs": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "pair": {"type": "string"},
+                "status": {"type": "string", "enum": ["trading", "halted", "cancelonly", "auctiononly", "delisted"]},
+                "rules": {"$ref": "#/components/schemas/PairRules"}
+              }
+            }
+          }
+        }
+      },
+      "DepthLevel": {
+        "type": "object",
+        "properties": {
+          "price": {"$ref": "#/components/schemas/RawPrice"},
+          "amounthave": {"type": "integer"},
+          "amountwant": {"type": "integer"},
+          "orders": {"type": "integer"}
+        }
+      },
+      "Depth": {
+        "type": "object",
+        "properties": {
+          "pair": {"$ref": "#/components/schemas/TradingPair"},
+          "buys": {"type": "array", "items": {"$ref": "#/components/schemas/DepthLevel"}},
+          "sells": {"type": "array", "items": {"$ref": "#/components/schemas/DepthLevel"}}
+        }
+      },
+      "LimitOrder": {
+        "type": "object",
+        "properties": {
+          "pubkey": {"type": "array", "items": {"type": "integer", "minimum": 0, "maximum": 255}, "minItems": 33, "maxItems": 33, "description": "Compressed pubkey of the user, as 33 bytes"},
+          "side": {"type": "string", "enum": ["buy", "sell"]},
+          "pair": {"$ref": "#/components/schemas/TradingPair"},
+          "amounthave": {"type": "integer"},
+          "amountwant": {"type": "integer"},
+          "timeinforce": {"type": "integer", "description": "0 for good till cancel, 1 for immediate or cancel, 2 for fill or kill"},
+          "expiry": {"type": "integer", "description": "Unix time the order is cancelled at if it's still on the book, or 0 for none"},
+          "selftrade": {"type": "integer", "description": "0 to cancel the newest, 1 the oldest, 2 both, 3 to decrement and cancel"}
+        },
+        "required": ["pubkey", "side", "pair", "amounthave", "amountwant"]
+      },
+      "ExchangePubkey": {
+        "type": "object",
+        "properties": {"pubkey": {"type": "string", "description": "Hex encoded compressed pubkey"}}
+      },
+      "OrderEnvelope": {
+        "type": "object",
+        "description": "Commits to an order or a cancel, the exchange it's for, and a nonce the exchange only accepts once from each pubkey",
+        "properties": {
+          "kind": {"type": "integer", "description": "0 for a limit order, 3 for cancelling a limit order"},
+          "exchange": {"type": "array", "items": {"type": "integer", "minimum": 0, "maximum": 255}, "minItems": 33, "maxItems": 33, "description": "Compressed pubkey of the exchange, from /v1/exchangepubkey"},
+          "pair": {"$ref": "#/components/schemas/TradingPair"},
+          "nonce": {"type": "integer", "description": "Can't have been used in another envelope from the same pubkey that hasn't expired, and can be used as a client order ID"},
+          "expiry": {"type": "integer", "description": "Unix time after which the envelope can't be used, at most 24 hours away"},
+          "orderhash": {"type": "array", "items": {"type": "integer", "minimum": 0, "maximum": 255}, "minItems": 32, "maxItems": 32, "description": "sha3-256 hash of the serialized order or cancel request"}
+        },
+        "required": ["kind", "exchange", "pair", "nonce", "expiry", "orderhash"]
+      },
+      "SubmitOrderRequest": {
+        "type": "object",
+        "properties": {
+          "order": {"$ref": "#/components/schemas/LimitOrder"},
+          "envelope": {"$ref": "#/components/schemas/OrderEnvelope"},
+          "signature": {"$ref": "#/components/schemas/Signature"}
+        },
+        "required": ["order", "envelope", "signature"],
+        "description": "The signature is of the envelope's SigHash, the same as for the SubmitOrder RPC command"
+      },
+      "SubmitOrderResponse": {
+        "type": "object",
+        "properties": {"orderid": {"type": "string", "description": "Hex encoded order ID"}}
+      },
+      "CancelOrderRequest": {
+        "type": "object",
+        "properties": {
+          "orderid": {"type": "string", "description": "Hex encoded order ID"},
+          "envelope": {"$ref": "#/components/schemas/OrderEnvelope"},
+          "signature": {"$ref": "#/components/schemas/Signature"}
+        },
+        "required": ["orderid", "envelope", "signature"],
+        "description": "The envelope is for a cancel request for the order, and the signature is of the envelope's SigHash, the same as for the CancelOrder RPC command"
+      },
+      "ChallengeRequest": {
+        "type": "object",
+        "properties": {"action": {"type": "string", "enum": ["register", "read"]}},
+        "required": ["action"]
+      },
+      "Challenge": {
+        "type": "object",
+        "description": "To answer the challenge, sign the sha3-256 hash of its encoding: [1 byte version] [1 byte message type 13] [1 byte action, 0 for register and 1 for read] [32 byte nonce] [8 byte little endian expiry] [33 byte session]",
+        "properties": {
+          "action": {"type": "string", "enum": ["register", "read"]},
+          "nonce": {"$ref": "#/components/schemas/Nonce"},
+          "expiry": {"type": "integer", "description": "Unix time after which the challenge can't be answered"},
+          "session": {"type": "string", "pattern": "^[0-9a-fA-F]{66}$", "description": "Hex encoded key the connection was authenticated with over noise, all zeroes over REST"}
+        }
+      },
+      "Balance": {
+        "type": "object",
+        "properties": {"asset": {"type": "string"}, "amount": {"type": "integer"}}
+      },
+      "DepositAddress": {
+        "type": "object",
+        "properties": {"asset": {"type": "string"}, "address": {"type": "string"}}
+      }
+    }
+  }
+}
+`
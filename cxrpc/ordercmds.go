@@ -2,17 +2,25 @@ package cxrpc
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
-	"golang.org/x/crypto/sha3"
 )
 
-// SubmitOrderArgs holds the args for the submitorder command
+// SubmitOrderArgs holds the args for the submitorder command.
+// The order's TimeInForce decides whether it rests on the book (gtc), has whatever isn't matched right away
+// cancelled and refunded (ioc), or is only matched if it can be filled entirely (fok). Market orders are
+// ioc orders priced at the worst price the user will accept, see match.NewMarketOrder. An order with an
+// Expiry is cancelled and refunded if it's still on the book once the expiry has passed. The order's
+// SelfTrade decides what happens if it would match another order from the same pubkey.
+// The Envelope commits to the order, this exchange's pubkey, and a nonce, and is what gets signed, so the
+// order can't be placed on another exchange or placed twice on this one. See match.NewLimitOrderEnvelope.
 type SubmitOrderArgs struct {
-	Order *match.LimitOrder
-	// Signature is a compact signature so we can do pubkey recovery
+	Order    *match.LimitOrder
+	Envelope *match.OrderEnvelope
+	// Signature is a compact signature of the envelope's SigHash so we can do pubkey recovery
 	Signature []byte
 }
 
@@ -23,20 +31,22 @@ type SubmitOrderReply struct {
 
 // SubmitOrder submits an order to the order book or throws an error
 func (cl *OpencxRPC) SubmitOrder(args SubmitOrderArgs, reply *SubmitOrderReply) (err error) {
-
-	var orderBytes []byte
-	if orderBytes, err = args.Order.Serialize(); err != nil {
-		err = fmt.Errorf("Error serializing order for SubmitOrder RPC command: %s", err)
+	if args.Order == nil || args.Envelope == nil {
+		err = fmt.Errorf("An order and its envelope are required to submit an order")
 		return
 	}
 
-	// hash order.
-	sha3 := sha3.New256()
-	sha3.Write(orderBytes)
-	e := sha3.Sum(nil)
+	if err = cl.checkEnvelope(args.Envelope, func() error {
+		return args.Envelope.CheckLimitOrder(args.Order)
+	}); err != nil {
+		err = fmt.Errorf("Envelope cannot be used for SubmitOrder RPC command: %s", err)
+		return
+	}
 
 	var sigPubKey *koblitz.PublicKey
-	if sigPubKey, _, err = koblitz.RecoverCompact(koblitz.S256(), args.Signature, e); err != nil {
+	if sigPubKey, err = cl.authenticate("SubmitOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		return recoverSigner(args.Signature, args.Envelope.SigHash())
+	}); err != nil {
 		err = fmt.Errorf("Error verifying order, invalid signature: \n%s", err)
 		return
 	}
@@ -53,10 +63,23 @@ func (cl *OpencxRPC) SubmitOrder(args SubmitOrderArgs, reply *SubmitOrderReply)
 		return
 	}
 
-	// possible replay attack: if we're using the same pubkey for two exchanges and this is like a feature on the exchange, then an exchange could have you
-	// place an order on their exchange, even with a nonce, and then send it over to the other exchange. When you submit an order on one exchange,
-	// you essentially submit an order to all of them. But like once we have channels for orders then this isn't a thing anymore because the channel
-	// tx's are signed and funding stuff is published on chain
+	var rules *match.PairRules
+	if rules, err = cl.Server.GetPairRules(&args.Order.TradingPair); err != nil {
+		err = fmt.Errorf("Error getting pair rules for SubmitOrder RPC command: %s", err)
+		return
+	}
+
+	if err = rules.CheckLimitOrder(args.Order); err != nil {
+		err = fmt.Errorf("Order does not follow the rules for pair %s: %s", args.Order.TradingPair.PrettyString(), err)
+		return
+	}
+
+	// The nonce is used before the order is placed, so the envelope can't place another order
+	if err = cl.Server.UseEnvelopeNonce(args.Order.Pubkey, args.Envelope); err != nil {
+		err = fmt.Errorf("Error using envelope nonce for SubmitOrder RPC command: %s", err)
+		return
+	}
+
 	if reply.OrderID, err = cl.Server.PlaceOrder(args.Order); err != nil {
 		err = fmt.Errorf("Error placing order for PlaceOrder RPC command: %s", err)
 		return
@@ -68,8 +91,30 @@ func (cl *OpencxRPC) SubmitOrder(args SubmitOrderArgs, reply *SubmitOrderReply)
 		return
 	}
 
-	logging.Infof("User %x submitted OrderID %s", sigPubKey.SerializeCompressed(), text)
+	logging.Infof("User %x submitted OrderID %s with nonce %d", sigPubKey.SerializeCompressed(), text, args.Envelope.Nonce)
+
+	return
+}
+
+// GetExchangePubkeyArgs holds the args for the getexchangepubkey command
+type GetExchangePubkeyArgs struct {
+}
+
+// GetExchangePubkeyReply holds the reply for the getexchangepubkey command
+type GetExchangePubkeyReply struct {
+	// Pubkey is the compressed pubkey that order envelopes have to be for
+	Pubkey [33]byte
+}
+
+// GetExchangePubkey gets the exchange's pubkey, which goes in the envelope of every order submitted to it
+func (cl *OpencxRPC) GetExchangePubkey(args GetExchangePubkeyArgs, reply *GetExchangePubkeyReply) (err error) {
+	var exchangePubkey *koblitz.PublicKey
+	if exchangePubkey, err = cl.Server.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey for GetExchangePubkey RPC command: %s", err)
+		return
+	}
 
+	copy(reply.Pubkey[:], exchangePubkey.SerializeCompressed())
 	return
 }
 
@@ -80,11 +125,16 @@ type ViewOrderBookArgs struct {
 
 // ViewOrderBookReply holds the reply for the vieworderbook command
 type ViewOrderBookReply struct {
-	Orderbook map[float64][]*match.LimitOrderIDPair
+	Orderbook map[match.Price][]*match.LimitOrderIDPair
 }
 
-// ViewOrderBook handles the vieworderbook command
+// ViewOrderBook handles the vieworderbook command. Every order on the book is returned with the pubkey
+// that placed it, so this can only be done with the exchange's key. Users can see the book with GetDepth.
 func (cl *OpencxRPC) ViewOrderBook(args ViewOrderBookArgs, reply *ViewOrderBookReply) (err error) {
+	if _, err = cl.authenticate("ViewOrderBook", nil, nil); err != nil {
+		err = fmt.Errorf("Error authenticating ViewOrderBook RPC command: %s", err)
+		return
+	}
 
 	if reply.Orderbook, err = cl.Server.ViewOrderbook(args.TradingPair); err != nil {
 		err = fmt.Errorf("Error with server ViewOrderbook for ViewOrderbook RPC command: %s", err)
@@ -94,6 +144,51 @@ func (cl *OpencxRPC) ViewOrderBook(args ViewOrderBookArgs, reply *ViewOrderBookR
 	return
 }
 
+const (
+	// defaultDepthLevels is how many prices on each side GetDepth returns if no limit is given
+	defaultDepthLevels = 50
+	// maxDepthLevels is the most prices on each side GetDepth will return
+	maxDepthLevels = 500
+)
+
+// GetDepthArgs holds the args for the GetDepth command
+type GetDepthArgs struct {
+	TradingPair *match.Pair
+	// Levels is the most prices to return on each side, which is 50 if it's zero and can't be more than 500
+	Levels uint64
+}
+
+// GetDepthReply holds the reply for the GetDepth command
+type GetDepthReply struct {
+	Depth *match.Depth
+}
+
+// GetDepth gets the orderbook for a pair added up by price, with the best prices first, and without
+// the pubkeys or IDs of the orders on it
+func (cl *OpencxRPC) GetDepth(args GetDepthArgs, reply *GetDepthReply) (err error) {
+	if args.TradingPair == nil {
+		err = fmt.Errorf("Cannot get depth for nil pair, please enter valid input")
+		return
+	}
+
+	levels := args.Levels
+	if levels == 0 {
+		levels = defaultDepthLevels
+	}
+
+	if levels > maxDepthLevels {
+		err = fmt.Errorf("Cannot get more than %d levels at once", maxDepthLevels)
+		return
+	}
+
+	if reply.Depth, err = cl.Server.GetDepth(args.TradingPair, levels); err != nil {
+		err = fmt.Errorf("Error getting depth for GetDepth RPC command: %s", err)
+		return
+	}
+
+	return
+}
+
 // GetPriceArgs holds the args for the GetPrice command
 type GetPriceArgs struct {
 	TradingPair *match.Pair
@@ -115,11 +210,45 @@ func (cl *OpencxRPC) GetPrice(args GetPriceArgs, reply *GetPriceReply) (err erro
 	return
 }
 
-// TODO: any man in the middle of a GetOrder communication can replay the same thing as a Cancel communication.
+// checkEnvelope makes sure an envelope is for this exchange and hasn't expired, and uses check to make sure
+// it's for what the command is doing
+func (cl *OpencxRPC) checkEnvelope(envelope *match.OrderEnvelope, check func() error) (err error) {
+	if envelope == nil {
+		err = fmt.Errorf("An envelope is required to sign this command")
+		return
+	}
 
-// CancelOrderArgs holds the args for the CancelOrder command
+	var exchangePubkey *koblitz.PublicKey
+	if exchangePubkey, err = cl.Server.GetExchangePubkey(); err != nil {
+		err = fmt.Errorf("Error getting exchange pubkey to check envelope: %s", err)
+		return
+	}
+
+	var envelopeExchange [33]byte
+	copy(envelopeExchange[:], exchangePubkey.SerializeCompressed())
+	if envelope.Exchange != envelopeExchange {
+		err = fmt.Errorf("Envelope is for exchange %x, not this exchange %x", envelope.Exchange, envelopeExchange)
+		return
+	}
+
+	if err = check(); err != nil {
+		err = fmt.Errorf("Envelope does not match command: %s", err)
+		return
+	}
+
+	if err = envelope.CheckExpiry(time.Now()); err != nil {
+		return
+	}
+	return
+}
+
+// CancelOrderArgs holds the args for the CancelOrder command.
+// The Envelope is for a match.CancelRequest for the order, and Signature is a signature of the envelope's
+// SigHash, so a signed cancel can only be used once, see match.NewCancelEnvelope. Neither are needed over a
+// connection authenticated with noise.
 type CancelOrderArgs struct {
 	OrderID   string
+	Envelope  *match.OrderEnvelope
 	Signature []byte
 }
 
@@ -131,18 +260,6 @@ type CancelOrderReply struct {
 // CancelOrder cancels the order
 func (cl *OpencxRPC) CancelOrder(args CancelOrderArgs, reply *CancelOrderReply) (err error) {
 
-	// hash order.
-	sha3 := sha3.New256()
-	sha3.Write([]byte(args.OrderID))
-	e := sha3.Sum(nil)
-
-	logging.Infof("Checking cancel signature")
-	var sigPubKey *koblitz.PublicKey
-	if sigPubKey, _, err = koblitz.RecoverCompact(koblitz.S256(), args.Signature, e); err != nil {
-		err = fmt.Errorf("Error verifying cancel, invalid signature: \n%s", err)
-		return
-	}
-
 	var unmarshalledOrderID *match.OrderID = new(match.OrderID)
 	if err = unmarshalledOrderID.UnmarshalText([]byte(args.OrderID)); err != nil {
 		err = fmt.Errorf("Error unmarshalling text for Order ID in CancelOrder RPC: %s", err)
@@ -155,6 +272,20 @@ func (cl *OpencxRPC) CancelOrder(args CancelOrderArgs, reply *CancelOrderReply)
 		return
 	}
 
+	var sigPubKey *koblitz.PublicKey
+	if sigPubKey, err = cl.authenticate("CancelOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		cancel := &match.CancelRequest{OrderID: *unmarshalledOrderID}
+		if err := cl.checkEnvelope(args.Envelope, func() error {
+			return args.Envelope.CheckCancel(cancel, false, orderPair.Order.TradingPair)
+		}); err != nil {
+			return nil, err
+		}
+		return recoverSigner(args.Signature, args.Envelope.SigHash())
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating cancel: \n%s", err)
+		return
+	}
+
 	// try to parse the order pubkey into koblitz
 	var orderPubKey *koblitz.PublicKey
 	if orderPubKey, err = koblitz.ParsePubKey(orderPair.Order.Pubkey[:], koblitz.S256()); err != nil {
@@ -173,6 +304,14 @@ func (cl *OpencxRPC) CancelOrder(args CancelOrderArgs, reply *CancelOrderReply)
 		return
 	}
 
+	// A signed cancel uses the envelope's nonce, so it can't be sent again
+	if len(args.Signature) != 0 {
+		if err = cl.Server.UseEnvelopeNonce(orderPair.Order.Pubkey, args.Envelope); err != nil {
+			err = fmt.Errorf("Error using envelope nonce for CancelOrder RPC command: %s", err)
+			return
+		}
+	}
+
 	if err = cl.Server.CancelOrder(orderPair); err != nil {
 		err = fmt.Errorf("Error cancelling order for CancelOrder RPC command: %s", err)
 		return
@@ -181,6 +320,100 @@ func (cl *OpencxRPC) CancelOrder(args CancelOrderArgs, reply *CancelOrderReply)
 	return
 }
 
+// ReplaceOrderArgs holds the args for the ReplaceOrder command.
+// The Envelope is for a match.ReplaceRequest for the order and amounts, and Signature is a compact signature
+// of the envelope's SigHash, so a signed amendment can only be used once, see match.NewReplaceEnvelope.
+type ReplaceOrderArgs struct {
+	OrderID    string
+	AmountHave uint64
+	AmountWant uint64
+	Envelope   *match.OrderEnvelope
+	Signature  []byte
+}
+
+// ReplaceOrderReply holds the reply for the ReplaceOrder command
+type ReplaceOrderReply struct {
+	// Order is the order after it was amended, before it was matched again
+	Order *match.LimitOrderIDPair
+}
+
+// ReplaceOrder amends the amounts of an order on the book, keeping its time priority if only its size is
+// reduced
+func (cl *OpencxRPC) ReplaceOrder(args ReplaceOrderArgs, reply *ReplaceOrderReply) (err error) {
+	var unmarshalledOrderID *match.OrderID = new(match.OrderID)
+	if err = unmarshalledOrderID.UnmarshalText([]byte(args.OrderID)); err != nil {
+		err = fmt.Errorf("Error unmarshalling text for Order ID in ReplaceOrder RPC: %s", err)
+		return
+	}
+
+	var orderPair *match.LimitOrderIDPair
+	if orderPair, err = cl.Server.GetOrder(unmarshalledOrderID); err != nil {
+		err = fmt.Errorf("Error calling GetOrder in ReplaceOrder RPC: %s", err)
+		return
+	}
+
+	var sigPubKey *koblitz.PublicKey
+	if sigPubKey, err = cl.authenticate("ReplaceOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		replace := &match.ReplaceRequest{
+			OrderID:    *unmarshalledOrderID,
+			AmountHave: args.AmountHave,
+			AmountWant: args.AmountWant,
+		}
+		if err := cl.checkEnvelope(args.Envelope, func() error {
+			return args.Envelope.CheckReplace(replace, orderPair.Order.TradingPair)
+		}); err != nil {
+			return nil, err
+		}
+		return recoverSigner(args.Signature, args.Envelope.SigHash())
+	}); err != nil {
+		err = fmt.Errorf("Error verifying replace, invalid signature: \n%s", err)
+		return
+	}
+
+	// try to parse the order pubkey into koblitz
+	var orderPubKey *koblitz.PublicKey
+	if orderPubKey, err = koblitz.ParsePubKey(orderPair.Order.Pubkey[:], koblitz.S256()); err != nil {
+		err = fmt.Errorf("Public Key failed parsing check: \n%s", err)
+		return
+	}
+
+	if !sigPubKey.IsEqual(orderPubKey) {
+		err = fmt.Errorf("Pubkey used with signature not equal to the one passed")
+		return
+	}
+
+	// The amended order has to follow the rules as if it were placed
+	amended := *orderPair.Order
+	amended.AmountHave = args.AmountHave
+	amended.AmountWant = args.AmountWant
+
+	var rules *match.PairRules
+	if rules, err = cl.Server.GetPairRules(&amended.TradingPair); err != nil {
+		err = fmt.Errorf("Error getting pair rules for ReplaceOrder RPC command: %s", err)
+		return
+	}
+
+	if err = rules.CheckLimitOrder(&amended); err != nil {
+		err = fmt.Errorf("Amended order does not follow the rules for pair %s: %s", amended.TradingPair.PrettyString(), err)
+		return
+	}
+
+	// The nonce is used before the order is amended, so the envelope can't amend it again
+	if err = cl.Server.UseEnvelopeNonce(orderPair.Order.Pubkey, args.Envelope); err != nil {
+		err = fmt.Errorf("Error using envelope nonce for ReplaceOrder RPC command: %s", err)
+		return
+	}
+
+	if reply.Order, err = cl.Server.ReplaceOrder(orderPair, args.AmountHave, args.AmountWant); err != nil {
+		err = fmt.Errorf("Error replacing order for ReplaceOrder RPC command: %s", err)
+		return
+	}
+
+	logging.Infof("User %x replaced OrderID %s", sigPubKey.SerializeCompressed(), args.OrderID)
+
+	return
+}
+
 // GetPairsArgs holds the args for the GetPairs command
 type GetPairsArgs struct {
 	// empty
@@ -189,13 +422,31 @@ type GetPairsArgs struct {
 // GetPairsReply holds the reply for the GetPairs command
 type GetPairsReply struct {
 	PairList []string
+	// Rules are the rules orders have to follow for each pair in the PairList, keyed by the same string, so
+	// clients can check orders before submitting them
+	Rules map[string]*match.PairRules
+	// Statuses are whether each pair in the PairList is trading, halted, cancel-only, auction-only, or
+	// delisted, keyed by the same string
+	Statuses map[string]match.PairStatus
 }
 
-// GetPairs gets all the pairs as nice strings
+// GetPairs gets all the pairs as nice strings, and the rules and status for each of them
 func (cl *OpencxRPC) GetPairs(args GetPairsArgs, reply *GetPairsReply) (err error) {
+	reply.Rules = make(map[string]*match.PairRules)
+	reply.Statuses = make(map[string]match.PairStatus)
+
 	// just go through all the pairs and prettily print them
 	for _, pair := range cl.Server.GetPairs() {
 		reply.PairList = append(reply.PairList, pair.PrettyString())
+		if reply.Rules[pair.PrettyString()], err = cl.Server.GetPairRules(pair); err != nil {
+			err = fmt.Errorf("Error getting rules for pair %s for GetPairs RPC command: %s", pair.PrettyString(), err)
+			return
+		}
+
+		if reply.Statuses[pair.PrettyString()], err = cl.Server.GetPairStatus(pair); err != nil {
+			err = fmt.Errorf("Error getting status for pair %s for GetPairs RPC command: %s", pair.PrettyString(), err)
+			return
+		}
 	}
 
 	return
@@ -203,26 +454,28 @@ func (cl *OpencxRPC) GetPairs(args GetPairsArgs, reply *GetPairsReply) (err erro
 
 // GetOrderArgs holds the args for the GetOrder command
 type GetOrderArgs struct {
-	OrderID   string
+	OrderID string
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
 	Signature []byte
 }
 
 // GetOrderReply holds the reply for the GetOrder command
 type GetOrderReply struct {
 	Order *match.LimitOrderIDPair
+	// Expired is true if the order has expired, and will be cancelled the next time the server sweeps for
+	// expired orders
+	Expired bool
 }
 
 // GetOrder gets an order based on orderID
 func (cl *OpencxRPC) GetOrder(args GetOrderArgs, reply *GetOrderReply) (err error) {
-	// hash order id.
-	sha3 := sha3.New256()
-	sha3.Write([]byte(args.OrderID))
-	e := sha3.Sum(nil)
-
-	logging.Infof("Checking getorder signature")
 	var sigPubKey *koblitz.PublicKey
-	if sigPubKey, _, err = koblitz.RecoverCompact(koblitz.S256(), args.Signature, e); err != nil {
-		err = fmt.Errorf("Error verifying getorder, invalid signature: \n%s", err)
+	if sigPubKey, err = cl.authenticate("GetOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating getorder: \n%s", err)
 		return
 	}
 
@@ -249,23 +502,34 @@ func (cl *OpencxRPC) GetOrder(args GetOrderArgs, reply *GetOrderReply) (err erro
 		return
 	}
 
+	reply.Expired = reply.Order.Order.Expired(time.Now())
+
 	return
 }
 
 // GetOrdersForPubkeyArgs holds the args for the GetOrdersForPubkey command
 type GetOrdersForPubkeyArgs struct {
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
 	Signature []byte
 }
 
 // GetOrdersForPubkeyReply holds the reply for the GetOrdersForPubkey command
 type GetOrdersForPubkeyReply struct {
 	Orders []*match.LimitOrderIDPair
+	// Expired are the IDs of the orders that have expired, and will be cancelled the next time the server
+	// sweeps for expired orders
+	Expired []*match.OrderID
 }
 
-// GetOrdersForPubkey gets the orders for the pubkey which has signed the getOrdersString
+// GetOrdersForPubkey gets the orders for the pubkey which has answered a read challenge
 func (cl *OpencxRPC) GetOrdersForPubkey(args GetOrdersForPubkeyArgs, reply *GetOrdersForPubkeyReply) (err error) {
 	var pubkey *koblitz.PublicKey
-	if pubkey, err = cl.Server.GetOrdersStringVerify(args.Signature); err != nil {
+	if pubkey, err = cl.authenticate("GetOrdersForPubkey", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating GetOrdersForPubkey RPC command: %s", err)
 		return
 	}
 
@@ -273,5 +537,12 @@ func (cl *OpencxRPC) GetOrdersForPubkey(args GetOrdersForPubkeyArgs, reply *GetO
 		return
 	}
 
+	now := time.Now()
+	for _, order := range reply.Orders {
+		if order.Order.Expired(now) {
+			reply.Expired = append(reply.Expired, order.OrderID)
+		}
+	}
+
 	return
 }
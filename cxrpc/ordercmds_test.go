@@ -0,0 +1,154 @@
+package cxrpc
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+	"golang.org/x/crypto/sha3"
+)
+
+// TestSignedOrderCommands gets, replaces, and cancels an order with signatures rather than a session,
+// making sure none of the signatures can be used again or for another command.
+func TestSignedOrderCommands(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	var testServer *httptest.Server
+	if server, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestSignedOrderCommands: %s", err)
+		return
+	}
+	testServer.Close()
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+			t.Errorf("Error debiting user for TestSignedOrderCommands: %s", err)
+			return
+		}
+	}
+
+	order := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pairList[0],
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+	var orderID *match.OrderID
+	if orderID, err = server.PlaceOrder(order); err != nil {
+		t.Errorf("Error placing order for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	var orderIDBytes []byte
+	if orderIDBytes, err = orderID.MarshalText(); err != nil {
+		t.Errorf("Error marshalling order ID for TestSignedOrderCommands: %s", err)
+		return
+	}
+	orderIDString := string(orderIDBytes)
+
+	var exchangePub *koblitz.PublicKey
+	if exchangePub, err = server.GetExchangePubkey(); err != nil {
+		t.Errorf("Error getting exchange pubkey for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	var exchangePubkey [33]byte
+	copy(exchangePubkey[:], exchangePub.SerializeCompressed())
+
+	cl := &OpencxRPC{Server: server}
+
+	// Getting an order needs the answer to a read challenge
+	challengeReply := new(GetChallengeReply)
+	if err = cl.GetChallenge(GetChallengeArgs{Action: match.ReadChallenge}, challengeReply); err != nil {
+		t.Errorf("Error getting challenge for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	challengeHash := sha3.Sum256(challengeReply.Challenge.Serialize())
+	var challengeSig []byte
+	if challengeSig, err = koblitz.SignCompact(koblitz.S256(), priv, challengeHash[:], false); err != nil {
+		t.Errorf("Error signing challenge for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	getOrderArgs := GetOrderArgs{OrderID: orderIDString, Challenge: challengeReply.Challenge.Nonce, Signature: challengeSig}
+	if err = cl.GetOrder(getOrderArgs, new(GetOrderReply)); err != nil {
+		t.Errorf("Error getting order for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	if err = cl.GetOrder(getOrderArgs, new(GetOrderReply)); err == nil {
+		t.Errorf("Order was read twice with the same challenge for TestSignedOrderCommands")
+		return
+	}
+
+	// An envelope for cancelling the order can't be used to amend it
+	var cancelEnvelope *match.OrderEnvelope
+	if cancelEnvelope, err = match.NewCancelEnvelope(&match.CancelRequest{OrderID: *orderID}, false, order.TradingPair, exchangePubkey, 1, uint64(time.Now().Add(time.Hour).Unix())); err != nil {
+		t.Errorf("Error creating cancel envelope for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	var cancelSig []byte
+	if cancelSig, err = koblitz.SignCompact(koblitz.S256(), priv, cancelEnvelope.SigHash(), false); err != nil {
+		t.Errorf("Error signing cancel for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	if err = cl.ReplaceOrder(ReplaceOrderArgs{OrderID: orderIDString, AmountHave: 50, AmountWant: 100, Envelope: cancelEnvelope, Signature: cancelSig}, new(ReplaceOrderReply)); err == nil {
+		t.Errorf("Order was amended with a cancel envelope for TestSignedOrderCommands")
+		return
+	}
+
+	var replaceEnvelope *match.OrderEnvelope
+	replace := &match.ReplaceRequest{OrderID: *orderID, AmountHave: 50, AmountWant: 100}
+	if replaceEnvelope, err = match.NewReplaceEnvelope(replace, order.TradingPair, exchangePubkey, 2, uint64(time.Now().Add(time.Hour).Unix())); err != nil {
+		t.Errorf("Error creating replace envelope for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	var replaceSig []byte
+	if replaceSig, err = koblitz.SignCompact(koblitz.S256(), priv, replaceEnvelope.SigHash(), false); err != nil {
+		t.Errorf("Error signing replace for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	replaceArgs := ReplaceOrderArgs{OrderID: orderIDString, AmountHave: 50, AmountWant: 100, Envelope: replaceEnvelope, Signature: replaceSig}
+	if err = cl.ReplaceOrder(replaceArgs, new(ReplaceOrderReply)); err != nil {
+		t.Errorf("Error replacing order for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	if err = cl.ReplaceOrder(replaceArgs, new(ReplaceOrderReply)); err == nil {
+		t.Errorf("Order was amended twice with the same envelope for TestSignedOrderCommands")
+		return
+	}
+
+	if err = cl.CancelOrder(CancelOrderArgs{OrderID: orderIDString, Envelope: cancelEnvelope, Signature: cancelSig}, new(CancelOrderReply)); err != nil {
+		t.Errorf("Error cancelling order for TestSignedOrderCommands: %s", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,476 @@
+package cxrpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// maxRESTBodySize is the largest request body the REST gateway will read
+const maxRESTBodySize = 1 << 20
+
+// RESTGateway serves the RPC commands for pairs, the book, prices, orders, balances, and deposit addresses
+// over HTTP with JSON, so clients that can't use net/rpc can use the exchange. Every request goes through
+// the same RPC command as it would over net/rpc, so signatures are checked the same way. Requests aren't
+// authenticated with noise, so admin commands can't be used. The requests and responses are described
+// by the OpenAPI document served at /v1/openapi.json.
+type RESTGateway struct {
+	caller   *OpencxRPC
+	mux      *http.ServeMux
+	listener net.Listener
+}
+
+// RESTPair is a pair, with its status and the rules its orders have to follow
+type RESTPair struct {
+	Pair   string           `json:"pair"`
+	Status string           `json:"status"`
+	Rules  *match.PairRules `json:"rules"`
+}
+
+// RESTPairsResponse is the response for GET /v1/pairs
+type RESTPairsResponse struct {
+	Pairs []*RESTPair `json:"pairs"`
+}
+
+// RESTPriceResponse is the response for GET /v1/price
+type RESTPriceResponse struct {
+	Pair  string  `json:"pair"`
+	Price float64 `json:"price"`
+}
+
+// RESTExchangePubkeyResponse is the response for GET /v1/exchangepubkey
+type RESTExchangePubkeyResponse struct {
+	// Pubkey is the hex of the compressed pubkey that order envelopes have to be for
+	Pubkey string `json:"pubkey"`
+}
+
+// RESTSubmitOrderRequest is the request for POST /v1/orders. Signature is the hex of a compact signature
+// of the envelope's SigHash, like for the SubmitOrder RPC command.
+type RESTSubmitOrderRequest struct {
+	Order     *match.LimitOrder    `json:"order"`
+	Envelope  *match.OrderEnvelope `json:"envelope"`
+	Signature string               `json:"signature"`
+}
+
+// RESTSubmitOrderResponse is the response for POST /v1/orders
+type RESTSubmitOrderResponse struct {
+	OrderID *match.OrderID `json:"orderid"`
+}
+
+// RESTCancelOrderRequest is the request for POST /v1/cancel. Signature is the hex of a compact signature
+// of the envelope's SigHash, like for the CancelOrder RPC command.
+type RESTCancelOrderRequest struct {
+	OrderID   string               `json:"orderid"`
+	Envelope  *match.OrderEnvelope `json:"envelope"`
+	Signature string               `json:"signature"`
+}
+
+// RESTChallengeRequest is the request for POST /v1/challenge, with the action as "register" or "read"
+type RESTChallengeRequest struct {
+	Action match.ChallengeAction `json:"action"`
+}
+
+// RESTChallengeResponse is the response for POST /v1/challenge. The nonce and session are hex. To answer
+// the challenge, a client encodes the challenge itself and signs its sha3 hash, then sends the nonce and
+// the hex of the compact signature.
+type RESTChallengeResponse struct {
+	Action  match.ChallengeAction `json:"action"`
+	Nonce   string                `json:"nonce"`
+	Expiry  uint64                `json:"expiry"`
+	Session string                `json:"session"`
+}
+
+// RESTBalanceResponse is the response for GET /v1/balance
+type RESTBalanceResponse struct {
+	Asset  string `json:"asset"`
+	Amount uint64 `json:"amount"`
+}
+
+// RESTDepositAddressResponse is the response for GET /v1/depositaddress
+type RESTDepositAddressResponse struct {
+	Asset   string `json:"asset"`
+	Address string `json:"address"`
+}
+
+// RESTError is the response for any request that fails
+type RESTError struct {
+	Error string `json:"error"`
+}
+
+// CreateRESTGateway creates a REST gateway for a server
+func CreateRESTGateway(server *cxserver.OpencxServer) (gateway *RESTGateway, err error) {
+	if server == nil {
+		err = fmt.Errorf("Cannot create REST gateway for nil server, please enter valid input")
+		return
+	}
+
+	gateway = &RESTGateway{
+		caller: &OpencxRPC{Server: server},
+		mux:    http.NewServeMux(),
+	}
+
+	gateway.mux.HandleFunc("/v1/openapi.json", gateway.handleOpenAPI)
+	gateway.mux.HandleFunc("/v1/pairs", gateway.handlePairs)
+	gateway.mux.HandleFunc("/v1/depth", gateway.handleDepth)
+	gateway.mux.HandleFunc("/v1/price", gateway.handlePrice)
+	gateway.mux.HandleFunc("/v1/exchangepubkey", gateway.handleExchangePubkey)
+	gateway.mux.HandleFunc("/v1/orders", gateway.handleSubmitOrder)
+	gateway.mux.HandleFunc("/v1/cancel", gateway.handleCancelOrder)
+	gateway.mux.HandleFunc("/v1/challenge", gateway.handleChallenge)
+	gateway.mux.HandleFunc("/v1/balance", gateway.handleBalance)
+	gateway.mux.HandleFunc("/v1/depositaddress", gateway.handleDepositAddress)
+	return
+}
+
+// ServeHTTP serves a REST request
+func (g *RESTGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+	return
+}
+
+// RESTListen listens for REST requests on host and port
+func (g *RESTGateway) RESTListen(host string, port uint16) (err error) {
+	serverAddr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	if g.listener, err = net.Listen("tcp", serverAddr); err != nil {
+		err = fmt.Errorf("Error listening for RESTListen: %s", err)
+		return
+	}
+	logging.Infof("Running REST gateway on %s\n", g.listener.Addr().String())
+
+	go func() {
+		if err := http.Serve(g.listener, g); err != nil {
+			logging.Infof("Stopped serving REST requests: %s", err)
+		}
+	}()
+	return
+}
+
+// Stop closes the REST listener
+func (g *RESTGateway) Stop() (err error) {
+	if g.listener == nil {
+		err = fmt.Errorf("Error, cannot stop a listener that doesn't exist")
+		return
+	}
+
+	if err = g.listener.Close(); err != nil {
+		err = fmt.Errorf("Error closing REST listener: %s", err)
+		return
+	}
+	return
+}
+
+// handleOpenAPI serves the OpenAPI document for the gateway
+func (g *RESTGateway) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(restOpenAPI))
+	return
+}
+
+// handlePairs serves GET /v1/pairs
+func (g *RESTGateway) handlePairs(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	reply := new(GetPairsReply)
+	if err := g.caller.GetPairs(GetPairsArgs{}, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	resp := &RESTPairsResponse{Pairs: []*RESTPair{}}
+	for _, pairString := range reply.PairList {
+		resp.Pairs = append(resp.Pairs, &RESTPair{
+			Pair:   pairString,
+			Status: reply.Statuses[pairString].String(),
+			Rules:  reply.Rules[pairString],
+		})
+	}
+
+	writeRESTResponse(w, resp)
+	return
+}
+
+// handleDepth serves GET /v1/depth?pair=asset1/asset2&levels=n
+func (g *RESTGateway) handleDepth(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	args := GetDepthArgs{TradingPair: new(match.Pair)}
+	if err := args.TradingPair.FromString(r.URL.Query().Get("pair")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error parsing pair: %s", err))
+		return
+	}
+
+	if levels := r.URL.Query().Get("levels"); levels != "" {
+		var err error
+		if args.Levels, err = strconv.ParseUint(levels, 10, 64); err != nil {
+			writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error parsing levels: %s", err))
+			return
+		}
+	}
+
+	reply := new(GetDepthReply)
+	if err := g.caller.GetDepth(args, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, reply.Depth)
+	return
+}
+
+// handlePrice serves GET /v1/price?pair=asset1/asset2
+func (g *RESTGateway) handlePrice(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	args := GetPriceArgs{TradingPair: new(match.Pair)}
+	if err := args.TradingPair.FromString(r.URL.Query().Get("pair")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error parsing pair: %s", err))
+		return
+	}
+
+	reply := new(GetPriceReply)
+	if err := g.caller.GetPrice(args, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTPriceResponse{Pair: args.TradingPair.PrettyString(), Price: reply.Price})
+	return
+}
+
+// handleExchangePubkey serves GET /v1/exchangepubkey
+func (g *RESTGateway) handleExchangePubkey(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	reply := new(GetExchangePubkeyReply)
+	if err := g.caller.GetExchangePubkey(GetExchangePubkeyArgs{}, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTExchangePubkeyResponse{Pubkey: hex.EncodeToString(reply.Pubkey[:])})
+	return
+}
+
+// handleSubmitOrder serves POST /v1/orders
+func (g *RESTGateway) handleSubmitOrder(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	req := new(RESTSubmitOrderRequest)
+	if err := readRESTRequest(w, r, req); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Order == nil || req.Envelope == nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Cannot submit nil order or envelope, please enter valid input"))
+		return
+	}
+
+	args := SubmitOrderArgs{Order: req.Order, Envelope: req.Envelope}
+	var err error
+	if args.Signature, err = hex.DecodeString(req.Signature); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error decoding signature: %s", err))
+		return
+	}
+
+	reply := new(SubmitOrderReply)
+	if err = g.caller.SubmitOrder(args, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTSubmitOrderResponse{OrderID: reply.OrderID})
+	return
+}
+
+// handleCancelOrder serves POST /v1/cancel
+func (g *RESTGateway) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	req := new(RESTCancelOrderRequest)
+	if err := readRESTRequest(w, r, req); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	args := CancelOrderArgs{OrderID: req.OrderID, Envelope: req.Envelope}
+	var err error
+	if args.Signature, err = hex.DecodeString(req.Signature); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error decoding signature: %s", err))
+		return
+	}
+
+	if err = g.caller.CancelOrder(args, new(CancelOrderReply)); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, struct{}{})
+	return
+}
+
+// handleChallenge serves POST /v1/challenge
+func (g *RESTGateway) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	req := new(RESTChallengeRequest)
+	if err := readRESTRequest(w, r, req); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Requests aren't authenticated, so the challenges each address can have waiting are limited
+	caller := &OpencxRPC{Server: g.caller.Server, remoteAddr: r.RemoteAddr}
+	reply := new(GetChallengeReply)
+	if err := caller.GetChallenge(GetChallengeArgs{Action: req.Action}, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTChallengeResponse{
+		Action:  reply.Challenge.Action,
+		Nonce:   hex.EncodeToString(reply.Challenge.Nonce[:]),
+		Expiry:  reply.Challenge.Expiry,
+		Session: hex.EncodeToString(reply.Challenge.Session[:]),
+	})
+	return
+}
+
+// handleBalance serves GET /v1/balance?asset=name&challenge=hex&signature=hex
+func (g *RESTGateway) handleBalance(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	args := GetBalanceArgs{Asset: r.URL.Query().Get("asset")}
+	var err error
+	if args.Challenge, err = decodeChallengeNonce(r.URL.Query().Get("challenge")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if args.Signature, err = hex.DecodeString(r.URL.Query().Get("signature")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error decoding signature: %s", err))
+		return
+	}
+
+	reply := new(GetBalanceReply)
+	if err = g.caller.GetBalance(args, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTBalanceResponse{Asset: args.Asset, Amount: reply.Amount})
+	return
+}
+
+// handleDepositAddress serves GET /v1/depositaddress?asset=name&challenge=hex&signature=hex
+func (g *RESTGateway) handleDepositAddress(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	args := GetDepositAddressArgs{Asset: r.URL.Query().Get("asset")}
+	var err error
+	if args.Challenge, err = decodeChallengeNonce(r.URL.Query().Get("challenge")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if args.Signature, err = hex.DecodeString(r.URL.Query().Get("signature")); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("Error decoding signature: %s", err))
+		return
+	}
+
+	reply := new(GetDepositAddressReply)
+	if err = g.caller.GetDepositAddress(args, reply); err != nil {
+		writeRESTError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRESTResponse(w, &RESTDepositAddressResponse{Asset: args.Asset, Address: reply.Address})
+	return
+}
+
+// decodeChallengeNonce decodes the hex nonce of a challenge
+func decodeChallengeNonce(nonceHex string) (nonce [32]byte, err error) {
+	var nonceBytes []byte
+	if nonceBytes, err = hex.DecodeString(nonceHex); err != nil {
+		err = fmt.Errorf("Error decoding challenge: %s", err)
+		return
+	}
+
+	if len(nonceBytes) != len(nonce) {
+		err = fmt.Errorf("Challenge must be %d bytes, not %d", len(nonce), len(nonceBytes))
+		return
+	}
+
+	copy(nonce[:], nonceBytes)
+	return
+}
+
+// checkMethod returns true if the request uses the method, and responds with an error if it doesn't
+func checkMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeRESTError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s not allowed, use %s", r.Method, method))
+	return false
+}
+
+// readRESTRequest decodes the JSON body of a request, rejecting fields that aren't in the request
+func readRESTRequest(w http.ResponseWriter, r *http.Request, req interface{}) (err error) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRESTBodySize))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(req); err != nil {
+		err = fmt.Errorf("Error decoding request body: %s", err)
+		return
+	}
+	return
+}
+
+// writeRESTResponse writes a successful JSON response
+func writeRESTResponse(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Errorf("Error writing REST response: %s", err)
+	}
+	return
+}
+
+// writeRESTError writes an error as a JSON response with a status code
+func writeRESTError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if encErr := json.NewEncoder(w).Encode(&RESTError{Error: err.Error()}); encErr != nil {
+		logging.Errorf("Error writing REST error: %s", encErr)
+	}
+	return
+}
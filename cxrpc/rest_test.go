@@ -0,0 +1,482 @@
+package cxrpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+	"golang.org/x/crypto/sha3"
+)
+
+// createRESTServer creates a server with memory stores and a random exchange key for a list of coins, and a
+// test server for its REST gateway
+func createRESTServer(coinList []*coinparam.Params) (server *cxserver.OpencxServer, testServer *httptest.Server, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		return
+	}
+
+	var setEngines map[*coinparam.Params]match.SettlementEngine
+	if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+		return
+	}
+
+	var mengines map[match.Pair]match.LimitEngine
+	if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+		return
+	}
+
+	var limBooks map[match.Pair]match.LimitOrderbook
+	if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+		return
+	}
+
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		return
+	}
+
+	var depositStores map[*coinparam.Params]cxdb.DepositStore
+	if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+		return
+	}
+
+	var setStores map[*coinparam.Params]cxdb.SettlementStore
+	if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+		return
+	}
+
+	if server, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ""); err != nil {
+		return
+	}
+
+	var exchangePriv *koblitz.PrivateKey
+	if exchangePriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		return
+	}
+
+	var exchangeKey [32]byte
+	copy(exchangeKey[:], exchangePriv.Serialize())
+	if err = server.SetupServerKeys(&exchangeKey); err != nil {
+		return
+	}
+
+	var gateway *RESTGateway
+	if gateway, err = CreateRESTGateway(server); err != nil {
+		return
+	}
+
+	testServer = httptest.NewServer(gateway)
+	return
+}
+
+// signREST returns the hex of a compact signature of the sha3 hash of some bytes, like REST clients send
+func signREST(priv *koblitz.PrivateKey, data []byte) (sig string, err error) {
+	sha3 := sha3.New256()
+	sha3.Write(data)
+	e := sha3.Sum(nil)
+
+	var compactSig []byte
+	if compactSig, err = koblitz.SignCompact(koblitz.S256(), priv, e, false); err != nil {
+		return
+	}
+
+	sig = hex.EncodeToString(compactSig)
+	return
+}
+
+// signRESTEnvelope returns the hex of a compact signature of an order envelope, like REST clients send
+func signRESTEnvelope(priv *koblitz.PrivateKey, envelope *match.OrderEnvelope) (sig string, err error) {
+	var compactSig []byte
+	if compactSig, err = koblitz.SignCompact(koblitz.S256(), priv, envelope.SigHash(), false); err != nil {
+		return
+	}
+
+	sig = hex.EncodeToString(compactSig)
+	return
+}
+
+// doREST sends a request to the test server, checks the status code, and decodes the response into resp
+func doREST(testServer *httptest.Server, method string, path string, body interface{}, status int, resp interface{}) (err error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err = json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return
+		}
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest(method, testServer.URL+path, &reqBody); err != nil {
+		return
+	}
+
+	var httpResp *http.Response
+	if httpResp, err = testServer.Client().Do(req); err != nil {
+		return
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != status {
+		restErr := new(RESTError)
+		json.NewDecoder(httpResp.Body).Decode(restErr)
+		err = fmt.Errorf("Expected status %d for %s %s, got %d: %s", status, method, path, httpResp.StatusCode, restErr.Error)
+		return
+	}
+
+	if resp != nil {
+		if err = json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// TestRESTGateway gets pairs and depth, places and cancels a signed order, and answers a challenge to get a balance through
+// the REST gateway, making sure the book changes and bad requests are rejected.
+func TestRESTGateway(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	var testServer *httptest.Server
+	if server, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestRESTGateway: %s", err)
+		return
+	}
+	defer testServer.Close()
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestRESTGateway: %s", err)
+		return
+	}
+	pair := pairList[0]
+	pairQuery := url.QueryEscape(pair.PrettyString())
+
+	pairsResp := new(RESTPairsResponse)
+	if err = doREST(testServer, http.MethodGet, "/v1/pairs", nil, http.StatusOK, pairsResp); err != nil {
+		t.Errorf("Error getting pairs for TestRESTGateway: %s", err)
+		return
+	}
+
+	if len(pairsResp.Pairs) != len(pairList) {
+		t.Errorf("Expected %d pairs for TestRESTGateway, got %d", len(pairList), len(pairsResp.Pairs))
+		return
+	}
+
+	for _, restPair := range pairsResp.Pairs {
+		if restPair.Status != match.PairTrading.String() {
+			t.Errorf("Expected pair %s to be %s for TestRESTGateway, got %s", restPair.Pair, match.PairTrading.String(), restPair.Status)
+			return
+		}
+	}
+
+	if err = doREST(testServer, http.MethodGet, "/v1/depth?pair=notapair", nil, http.StatusBadRequest, nil); err != nil {
+		t.Errorf("Bad pair was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	if err = doREST(testServer, http.MethodPost, "/v1/depth?pair="+pairQuery, nil, http.StatusMethodNotAllowed, nil); err != nil {
+		t.Errorf("Wrong method was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestRESTGateway: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+			t.Errorf("Error debiting user for TestRESTGateway: %s", err)
+			return
+		}
+	}
+
+	order := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+	exchangeResp := new(RESTExchangePubkeyResponse)
+	if err = doREST(testServer, http.MethodGet, "/v1/exchangepubkey", nil, http.StatusOK, exchangeResp); err != nil {
+		t.Errorf("Error getting exchange pubkey for TestRESTGateway: %s", err)
+		return
+	}
+
+	var exchangeBytes []byte
+	if exchangeBytes, err = hex.DecodeString(exchangeResp.Pubkey); err != nil {
+		t.Errorf("Error decoding exchange pubkey for TestRESTGateway: %s", err)
+		return
+	}
+
+	var exchangePubkey [33]byte
+	copy(exchangePubkey[:], exchangeBytes)
+
+	expiry := uint64(time.Now().Add(time.Hour).Unix())
+	var envelope *match.OrderEnvelope
+	if envelope, err = match.NewLimitOrderEnvelope(order, exchangePubkey, 1, expiry); err != nil {
+		t.Errorf("Error creating envelope for TestRESTGateway: %s", err)
+		return
+	}
+
+	var orderSig string
+	if orderSig, err = signRESTEnvelope(priv, envelope); err != nil {
+		t.Errorf("Error signing order for TestRESTGateway: %s", err)
+		return
+	}
+
+	// A signature from someone else recovers a different pubkey than the order's
+	var otherPriv *koblitz.PrivateKey
+	if otherPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating other key for TestRESTGateway: %s", err)
+		return
+	}
+
+	var badSig string
+	if badSig, err = signRESTEnvelope(otherPriv, envelope); err != nil {
+		t.Errorf("Error signing order with other key for TestRESTGateway: %s", err)
+		return
+	}
+
+	if err = doREST(testServer, http.MethodPost, "/v1/orders", &RESTSubmitOrderRequest{Order: order, Envelope: envelope, Signature: badSig}, http.StatusUnprocessableEntity, nil); err != nil {
+		t.Errorf("Order signed by the wrong key was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	// An envelope signed for another exchange can't be replayed on this one
+	var otherEnvelope *match.OrderEnvelope
+	copy(exchangePubkey[:], otherPriv.PubKey().SerializeCompressed())
+	if otherEnvelope, err = match.NewLimitOrderEnvelope(order, exchangePubkey, 1, expiry); err != nil {
+		t.Errorf("Error creating envelope for other exchange for TestRESTGateway: %s", err)
+		return
+	}
+
+	var otherExchangeSig string
+	if otherExchangeSig, err = signRESTEnvelope(priv, otherEnvelope); err != nil {
+		t.Errorf("Error signing order for other exchange for TestRESTGateway: %s", err)
+		return
+	}
+
+	if err = doREST(testServer, http.MethodPost, "/v1/orders", &RESTSubmitOrderRequest{Order: order, Envelope: otherEnvelope, Signature: otherExchangeSig}, http.StatusUnprocessableEntity, nil); err != nil {
+		t.Errorf("Order for another exchange was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	submitResp := new(RESTSubmitOrderResponse)
+	if err = doREST(testServer, http.MethodPost, "/v1/orders", &RESTSubmitOrderRequest{Order: order, Envelope: envelope, Signature: orderSig}, http.StatusOK, submitResp); err != nil {
+		t.Errorf("Error submitting order for TestRESTGateway: %s", err)
+		return
+	}
+
+	// The same signed order can only be placed once
+	if err = doREST(testServer, http.MethodPost, "/v1/orders", &RESTSubmitOrderRequest{Order: order, Envelope: envelope, Signature: orderSig}, http.StatusUnprocessableEntity, nil); err != nil {
+		t.Errorf("Replayed order was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	if submitResp.OrderID == nil {
+		t.Errorf("No order ID returned for TestRESTGateway")
+		return
+	}
+
+	depth := new(match.Depth)
+	if err = doREST(testServer, http.MethodGet, "/v1/depth?pair="+pairQuery, nil, http.StatusOK, depth); err != nil {
+		t.Errorf("Error getting depth for TestRESTGateway: %s", err)
+		return
+	}
+
+	if len(depth.Buys) != 1 || len(depth.Sells) != 0 {
+		t.Errorf("Expected 1 buy level and 0 sell levels for TestRESTGateway, got %d and %d", len(depth.Buys), len(depth.Sells))
+		return
+	}
+
+	if depth.Buys[0].AmountHave != order.AmountHave || depth.Buys[0].Orders != 1 {
+		t.Errorf("Expected buy level with %d amount have and 1 order for TestRESTGateway, got %d and %d", order.AmountHave, depth.Buys[0].AmountHave, depth.Buys[0].Orders)
+		return
+	}
+
+	challengeResp := new(RESTChallengeResponse)
+	if err = doREST(testServer, http.MethodPost, "/v1/challenge", &RESTChallengeRequest{Action: match.ReadChallenge}, http.StatusOK, challengeResp); err != nil {
+		t.Errorf("Error getting challenge for TestRESTGateway: %s", err)
+		return
+	}
+
+	// The client encodes the challenge itself rather than signing a hash from the exchange
+	challenge := &match.Challenge{Action: challengeResp.Action, Expiry: challengeResp.Expiry}
+	var nonceBytes []byte
+	var sessionBytes []byte
+	if nonceBytes, err = hex.DecodeString(challengeResp.Nonce); err != nil {
+		t.Errorf("Error decoding challenge nonce for TestRESTGateway: %s", err)
+		return
+	}
+	if sessionBytes, err = hex.DecodeString(challengeResp.Session); err != nil {
+		t.Errorf("Error decoding challenge session for TestRESTGateway: %s", err)
+		return
+	}
+	copy(challenge.Nonce[:], nonceBytes)
+	copy(challenge.Session[:], sessionBytes)
+
+	var balanceSig string
+	if balanceSig, err = signREST(priv, challenge.Serialize()); err != nil {
+		t.Errorf("Error signing challenge for TestRESTGateway: %s", err)
+		return
+	}
+
+	balanceAsset := coinList[0].Name
+	balanceQuery := "/v1/balance?asset=" + url.QueryEscape(balanceAsset) + "&challenge=" + challengeResp.Nonce + "&signature=" + balanceSig
+	balanceResp := new(RESTBalanceResponse)
+	if err = doREST(testServer, http.MethodGet, balanceQuery, nil, http.StatusOK, balanceResp); err != nil {
+		t.Errorf("Error getting balance for TestRESTGateway: %s", err)
+		return
+	}
+
+	// A challenge can only be answered once
+	if err = doREST(testServer, http.MethodGet, balanceQuery, nil, http.StatusUnprocessableEntity, nil); err != nil {
+		t.Errorf("Answering a challenge twice did not fail for TestRESTGateway: %s", err)
+		return
+	}
+
+	var expectedBalance uint64
+	if expectedBalance, err = server.GetBalance(priv.PubKey(), coinList[0]); err != nil {
+		t.Errorf("Error getting balance from server for TestRESTGateway: %s", err)
+		return
+	}
+
+	if balanceResp.Amount != expectedBalance {
+		t.Errorf("Expected balance %d for TestRESTGateway, got %d", expectedBalance, balanceResp.Amount)
+		return
+	}
+
+	var orderIDBytes []byte
+	if orderIDBytes, err = submitResp.OrderID.MarshalText(); err != nil {
+		t.Errorf("Error marshalling order ID for TestRESTGateway: %s", err)
+		return
+	}
+	orderIDString := string(orderIDBytes)
+
+	// The signature for getting an order can't be used to cancel it, cancels are signed in envelopes
+	var orderIDSig string
+	if orderIDSig, err = signREST(priv, []byte(orderIDString)); err != nil {
+		t.Errorf("Error signing order ID for TestRESTGateway: %s", err)
+		return
+	}
+
+	if err = doREST(testServer, http.MethodPost, "/v1/cancel", &RESTCancelOrderRequest{OrderID: orderIDString, Signature: orderIDSig}, http.StatusUnprocessableEntity, nil); err != nil {
+		t.Errorf("Cancel without an envelope was not rejected for TestRESTGateway: %s", err)
+		return
+	}
+
+	var cancelEnvelope *match.OrderEnvelope
+	if cancelEnvelope, err = match.NewCancelEnvelope(&match.CancelRequest{OrderID: *submitResp.OrderID}, false, *pair, envelope.Exchange, 2, expiry); err != nil {
+		t.Errorf("Error creating cancel envelope for TestRESTGateway: %s", err)
+		return
+	}
+
+	var cancelSig string
+	if cancelSig, err = signRESTEnvelope(priv, cancelEnvelope); err != nil {
+		t.Errorf("Error signing cancel for TestRESTGateway: %s", err)
+		return
+	}
+
+	if err = doREST(testServer, http.MethodPost, "/v1/cancel", &RESTCancelOrderRequest{OrderID: orderIDString, Envelope: cancelEnvelope, Signature: cancelSig}, http.StatusOK, nil); err != nil {
+		t.Errorf("Error cancelling order for TestRESTGateway: %s", err)
+		return
+	}
+
+	depth = new(match.Depth)
+	if err = doREST(testServer, http.MethodGet, "/v1/depth?pair="+pairQuery, nil, http.StatusOK, depth); err != nil {
+		t.Errorf("Error getting depth after cancel for TestRESTGateway: %s", err)
+		return
+	}
+
+	if len(depth.Buys) != 0 || len(depth.Sells) != 0 {
+		t.Errorf("Expected empty book after cancel for TestRESTGateway, got %d buy levels and %d sell levels", len(depth.Buys), len(depth.Sells))
+		return
+	}
+
+	return
+}
+
+// TestRESTOpenAPI makes sure the OpenAPI document is valid JSON and describes every route the gateway serves
+func TestRESTOpenAPI(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var testServer *httptest.Server
+	if _, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestRESTOpenAPI: %s", err)
+		return
+	}
+	defer testServer.Close()
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err = doREST(testServer, http.MethodGet, "/v1/openapi.json", nil, http.StatusOK, &doc); err != nil {
+		t.Errorf("Error getting OpenAPI document for TestRESTOpenAPI: %s", err)
+		return
+	}
+
+	routes := map[string]string{
+		"/v1/openapi.json":   "get",
+		"/v1/pairs":          "get",
+		"/v1/depth":          "get",
+		"/v1/price":          "get",
+		"/v1/exchangepubkey": "get",
+		"/v1/orders":         "post",
+		"/v1/cancel":         "post",
+		"/v1/challenge":      "post",
+		"/v1/balance":        "get",
+		"/v1/depositaddress": "get",
+	}
+
+	if len(doc.Paths) != len(routes) {
+		t.Errorf("Expected %d paths in OpenAPI document for TestRESTOpenAPI, got %d", len(routes), len(doc.Paths))
+		return
+	}
+
+	for path, method := range routes {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("OpenAPI document has no %s %s for TestRESTOpenAPI", method, path)
+			return
+		}
+
+		// Every documented route is served with that method
+		if err = doREST(testServer, http.MethodPut, path, nil, http.StatusMethodNotAllowed, nil); err != nil {
+			t.Errorf("Route %s is not served for TestRESTOpenAPI: %s", path, err)
+			return
+		}
+	}
+
+	return
+}
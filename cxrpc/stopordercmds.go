@@ -0,0 +1,201 @@
+package cxrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SubmitStopOrderArgs holds the args for the SubmitStopOrder command.
+// The Envelope commits to the stop order, this exchange's pubkey, and a nonce, and is what gets signed, so
+// the stop order can't be placed on another exchange or placed twice on this one. See
+// match.NewStopOrderEnvelope.
+type SubmitStopOrderArgs struct {
+	Order    *match.StopOrder
+	Envelope *match.OrderEnvelope
+	// Signature is a compact signature of the envelope's SigHash so we can do pubkey recovery
+	Signature []byte
+}
+
+// SubmitStopOrderReply holds the reply for the SubmitStopOrder command
+type SubmitStopOrderReply struct {
+	OrderID *match.OrderID
+}
+
+// SubmitStopOrder submits a stop order, which the exchange holds until the last trade price reaches the stop price
+func (cl *OpencxRPC) SubmitStopOrder(args SubmitStopOrderArgs, reply *SubmitStopOrderReply) (err error) {
+	if args.Order == nil || args.Envelope == nil {
+		err = fmt.Errorf("A stop order and its envelope are required to submit a stop order")
+		return
+	}
+
+	if err = cl.checkEnvelope(args.Envelope, func() error {
+		return args.Envelope.CheckStopOrder(args.Order)
+	}); err != nil {
+		err = fmt.Errorf("Envelope cannot be used for SubmitStopOrder RPC command: %s", err)
+		return
+	}
+
+	var sigPubKey *koblitz.PublicKey
+	if sigPubKey, err = cl.authenticate("SubmitStopOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		return recoverSigner(args.Signature, args.Envelope.SigHash())
+	}); err != nil {
+		err = fmt.Errorf("Error verifying stop order, invalid signature: \n%s", err)
+		return
+	}
+
+	// try to parse the order pubkey into koblitz
+	var orderPubkey *koblitz.PublicKey
+	if orderPubkey, err = koblitz.ParsePubKey(args.Order.Order.Pubkey[:], koblitz.S256()); err != nil {
+		err = fmt.Errorf("Public Key failed parsing check: \n%s", err)
+		return
+	}
+
+	if !sigPubKey.IsEqual(orderPubkey) {
+		err = fmt.Errorf("Pubkey used with signature not equal to the one passed")
+		return
+	}
+
+	var rules *match.PairRules
+	if rules, err = cl.Server.GetPairRules(&args.Order.Order.TradingPair); err != nil {
+		err = fmt.Errorf("Error getting pair rules for SubmitStopOrder RPC command: %s", err)
+		return
+	}
+
+	// A stop-loss order doesn't have a price until it's triggered, so only its AmountHave can be checked
+	if args.Order.Market {
+		err = rules.CheckAmountHave(args.Order.Order.AmountHave)
+	} else {
+		err = rules.CheckLimitOrder(&args.Order.Order)
+	}
+	if err != nil {
+		err = fmt.Errorf("Stop order does not follow the rules for pair %s: %s", args.Order.Order.TradingPair.PrettyString(), err)
+		return
+	}
+
+	// The nonce is used before the stop order is placed, so the envelope can't place another one
+	if err = cl.Server.UseEnvelopeNonce(args.Order.Order.Pubkey, args.Envelope); err != nil {
+		err = fmt.Errorf("Error using envelope nonce for SubmitStopOrder RPC command: %s", err)
+		return
+	}
+
+	if reply.OrderID, err = cl.Server.PlaceStopOrder(args.Order); err != nil {
+		err = fmt.Errorf("Error placing stop order for SubmitStopOrder RPC command: %s", err)
+		return
+	}
+
+	var text []byte
+	if text, err = reply.OrderID.MarshalText(); err != nil {
+		err = fmt.Errorf("Could not marshal text for some reason: %s", err)
+		return
+	}
+
+	logging.Infof("User %x submitted stop OrderID %s with nonce %d", sigPubKey.SerializeCompressed(), text, args.Envelope.Nonce)
+
+	return
+}
+
+// GetStopOrdersArgs holds the args for the GetStopOrders command
+type GetStopOrdersArgs struct {
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
+	Signature []byte
+}
+
+// GetStopOrdersReply holds the reply for the GetStopOrders command
+type GetStopOrdersReply struct {
+	Orders []*match.StopOrderIDPair
+}
+
+// GetStopOrders gets the stop orders that haven't been triggered or cancelled yet for the pubkey which has
+// answered a read challenge
+func (cl *OpencxRPC) GetStopOrders(args GetStopOrdersArgs, reply *GetStopOrdersReply) (err error) {
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.authenticate("GetStopOrders", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating GetStopOrders RPC command: %s", err)
+		return
+	}
+
+	if reply.Orders, err = cl.Server.GetStopOrdersForPubkey(pubkey); err != nil {
+		err = fmt.Errorf("Error getting stop orders for GetStopOrders RPC command: %s", err)
+		return
+	}
+
+	return
+}
+
+// CancelStopOrderArgs holds the args for the CancelStopOrder command.
+// The Envelope is for a match.CancelRequest for the stop order, and Signature is a signature of the
+// envelope's SigHash, see match.NewCancelEnvelope. Neither are needed over a connection authenticated with
+// noise.
+type CancelStopOrderArgs struct {
+	OrderID   string
+	Envelope  *match.OrderEnvelope
+	Signature []byte
+}
+
+// CancelStopOrderReply holds the reply for the CancelStopOrder command
+type CancelStopOrderReply struct {
+	// empty
+}
+
+// CancelStopOrder cancels a stop order that hasn't been triggered yet, giving back its funds
+func (cl *OpencxRPC) CancelStopOrder(args CancelStopOrderArgs, reply *CancelStopOrderReply) (err error) {
+	var unmarshalledOrderID *match.OrderID = new(match.OrderID)
+	if err = unmarshalledOrderID.UnmarshalText([]byte(args.OrderID)); err != nil {
+		err = fmt.Errorf("Error unmarshalling text for Order ID in CancelStopOrder RPC: %s", err)
+		return
+	}
+
+	var stop *match.StopOrderIDPair
+	if stop, err = cl.Server.GetStopOrder(unmarshalledOrderID); err != nil {
+		err = fmt.Errorf("Error calling GetStopOrder in CancelStopOrder RPC: %s", err)
+		return
+	}
+
+	var sigPubKey *koblitz.PublicKey
+	if sigPubKey, err = cl.authenticate("CancelStopOrder", args.Signature, func() (*koblitz.PublicKey, error) {
+		cancel := &match.CancelRequest{OrderID: *unmarshalledOrderID}
+		if err := cl.checkEnvelope(args.Envelope, func() error {
+			return args.Envelope.CheckCancel(cancel, true, stop.Order.Order.TradingPair)
+		}); err != nil {
+			return nil, err
+		}
+		return recoverSigner(args.Signature, args.Envelope.SigHash())
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating stop order cancel: \n%s", err)
+		return
+	}
+
+	// try to parse the order pubkey into koblitz
+	var orderPubKey *koblitz.PublicKey
+	if orderPubKey, err = koblitz.ParsePubKey(stop.Order.Order.Pubkey[:], koblitz.S256()); err != nil {
+		err = fmt.Errorf("Public Key failed parsing check: \n%s", err)
+		return
+	}
+
+	if !sigPubKey.IsEqual(orderPubKey) {
+		err = fmt.Errorf("Pubkey used with signature not equal to the one passed")
+		return
+	}
+
+	// A signed cancel uses the envelope's nonce, so it can't be sent again
+	if len(args.Signature) != 0 {
+		if err = cl.Server.UseEnvelopeNonce(stop.Order.Order.Pubkey, args.Envelope); err != nil {
+			err = fmt.Errorf("Error using envelope nonce for CancelStopOrder RPC command: %s", err)
+			return
+		}
+	}
+
+	if err = cl.Server.CancelStopOrder(stop); err != nil {
+		err = fmt.Errorf("Error cancelling stop order for CancelStopOrder RPC command: %s", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,122 @@
+package cxrpc
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestSubmitStopOrder submits a stop order in an envelope, making sure the same envelope can't place it
+// again and that it can't be submitted without one.
+func TestSubmitStopOrder(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	var testServer *httptest.Server
+	if server, testServer, err = createRESTServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestSubmitStopOrder: %s", err)
+		return
+	}
+	testServer.Close()
+
+	// Stop orders are only placed on servers with a journal
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+			t.Errorf("Error debiting user for TestSubmitStopOrder: %s", err)
+			return
+		}
+	}
+
+	stop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pairList[0],
+			AmountHave:  100,
+			AmountWant:  100,
+		},
+		StopPrice: match.Price{AmountWant: 1, AmountHave: 2},
+	}
+	copy(stop.Order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+	var exchangePub *koblitz.PublicKey
+	if exchangePub, err = server.GetExchangePubkey(); err != nil {
+		t.Errorf("Error getting exchange pubkey for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	var exchangePubkey [33]byte
+	copy(exchangePubkey[:], exchangePub.SerializeCompressed())
+
+	var envelope *match.OrderEnvelope
+	if envelope, err = match.NewStopOrderEnvelope(stop, exchangePubkey, 1, uint64(time.Now().Add(time.Hour).Unix())); err != nil {
+		t.Errorf("Error creating envelope for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	var sig []byte
+	if sig, err = koblitz.SignCompact(koblitz.S256(), priv, envelope.SigHash(), false); err != nil {
+		t.Errorf("Error signing envelope for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	cl := &OpencxRPC{Server: server}
+	if err = cl.SubmitStopOrder(SubmitStopOrderArgs{Order: stop, Envelope: envelope, Signature: sig}, new(SubmitStopOrderReply)); err != nil {
+		t.Errorf("Error submitting stop order for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	if err = cl.SubmitStopOrder(SubmitStopOrderArgs{Order: stop, Envelope: envelope, Signature: sig}, new(SubmitStopOrderReply)); err == nil {
+		t.Errorf("Stop order was placed twice with the same envelope for TestSubmitStopOrder")
+		return
+	}
+
+	if err = cl.SubmitStopOrder(SubmitStopOrderArgs{Order: stop, Signature: sig}, new(SubmitStopOrderReply)); err == nil {
+		t.Errorf("Stop order was placed without an envelope for TestSubmitStopOrder")
+		return
+	}
+
+	var stopOrders []*match.StopOrderIDPair
+	if stopOrders, err = server.GetStopOrdersForPubkey(priv.PubKey()); err != nil {
+		t.Errorf("Error getting stop orders for TestSubmitStopOrder: %s", err)
+		return
+	}
+
+	if len(stopOrders) != 1 {
+		t.Errorf("Expected 1 stop order for TestSubmitStopOrder, got %d", len(stopOrders))
+		return
+	}
+
+	return
+}
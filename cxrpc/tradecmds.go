@@ -0,0 +1,109 @@
+package cxrpc
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/match"
+)
+
+const (
+	// defaultRecentTrades is how many trades GetRecentTrades returns if no limit is given
+	defaultRecentTrades = 50
+	// maxRecentTrades is the most trades GetRecentTrades will return
+	maxRecentTrades = 1000
+	// defaultFills is how many trades GetMyFills returns if no limit is given
+	defaultFills = 50
+	// maxFills is the most trades GetMyFills will return, and the most it will skip for a cursor
+	maxFills = 1000
+)
+
+// GetRecentTradesArgs holds the args for the GetRecentTrades command
+type GetRecentTradesArgs struct {
+	TradingPair *match.Pair
+	// Limit is the most trades to return, which is 50 if it's zero and can't be more than 1000
+	Limit uint64
+}
+
+// GetRecentTradesReply holds the reply for the GetRecentTrades command
+type GetRecentTradesReply struct {
+	Trades []*match.Trade
+}
+
+// GetRecentTrades gets the latest trades on a pair, newest first
+func (cl *OpencxRPC) GetRecentTrades(args GetRecentTradesArgs, reply *GetRecentTradesReply) (err error) {
+	if args.TradingPair == nil {
+		err = fmt.Errorf("Cannot get trades for nil pair, please enter valid input")
+		return
+	}
+
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultRecentTrades
+	}
+
+	if limit > maxRecentTrades {
+		err = fmt.Errorf("Cannot get more than %d trades at once", maxRecentTrades)
+		return
+	}
+
+	if reply.Trades, err = cl.Server.GetRecentTrades(args.TradingPair, limit); err != nil {
+		err = fmt.Errorf("Error getting recent trades for GetRecentTrades RPC command: %s", err)
+		return
+	}
+
+	return
+}
+
+// GetMyFillsArgs holds the args for the GetMyFills command
+type GetMyFillsArgs struct {
+	// Challenge is the nonce of a read challenge from GetChallenge, and Signature is a signature of the
+	// challenge
+	Challenge [32]byte
+	Signature []byte
+	// Limit is the most trades to return, which is 50 if it's zero and can't be more than 1000
+	Limit uint64
+	// Cursor is the Next of the reply for the last page, or nil for the newest trades
+	Cursor *match.FillsCursor
+}
+
+// GetMyFillsReply holds the reply for the GetMyFills command
+type GetMyFillsReply struct {
+	Trades []*match.Trade
+	// Next is the cursor for the next page, which is nil if there are no more trades
+	Next *match.FillsCursor
+}
+
+// GetMyFills gets the trades on every pair that the orders of the pubkey which has answered a read
+// challenge were in, newest first, a page at a time
+func (cl *OpencxRPC) GetMyFills(args GetMyFillsArgs, reply *GetMyFillsReply) (err error) {
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultFills
+	}
+
+	if limit > maxFills {
+		err = fmt.Errorf("Cannot get more than %d fills at once", maxFills)
+		return
+	}
+
+	if args.Cursor != nil && args.Cursor.Skip > maxFills {
+		err = fmt.Errorf("Cannot skip more than %d fills for a cursor", maxFills)
+		return
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = cl.authenticate("GetMyFills", args.Signature, func() (*koblitz.PublicKey, error) {
+		return cl.Server.AnswerChallenge(match.ReadChallenge, cl.remotePub, args.Challenge, args.Signature)
+	}); err != nil {
+		err = fmt.Errorf("Error authenticating GetMyFills RPC command: %s", err)
+		return
+	}
+
+	if reply.Trades, reply.Next, err = cl.Server.GetTradesForPubkey(pubkey, args.Cursor, limit); err != nil {
+		err = fmt.Errorf("Error getting fills for GetMyFills RPC command: %s", err)
+		return
+	}
+
+	return
+}
@@ -12,23 +12,25 @@ import (
 func (server *OpencxServer) GetBalance(pubkey *koblitz.PublicKey, coin *coinparam.Params) (amount uint64, err error) {
 
 	// First get the settlement store
-	// TODO: There should be two locks, one for critical things such as the matching and settlement
-	// engine, and another for just the stuff we view. (like the orderbooks and stores)
-	server.dbLock.Lock()
+	if err = server.lockAsset(coin); err != nil {
+		err = fmt.Errorf("Error locking asset for GetBalance: %s", err)
+		return
+	}
+
 	var currSettlementStore cxdb.SettlementStore
 	var ok bool
 	if currSettlementStore, ok = server.SettlementStores[coin]; !ok {
 		err = fmt.Errorf("Cannot find the settlement store for GetBalance")
-		server.dbLock.Unlock()
+		server.unlockAsset(coin)
 		return
 	}
 
 	if amount, err = currSettlementStore.GetBalance(pubkey); err != nil {
 		err = fmt.Errorf("Could not get balance for pubkey for GetBalance: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(coin)
 		return
 	}
-	server.dbLock.Unlock()
+	server.unlockAsset(coin)
 
 	return
 }
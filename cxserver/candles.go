@@ -0,0 +1,40 @@
+package cxserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetCandles returns the candles for a pair and interval that start at or after start and before end,
+// oldest first. If end is zero there is no end.
+func (server *OpencxServer) GetCandles(pair *match.Pair, interval match.CandleInterval, start time.Time, end time.Time) (candles []*match.Candle, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot get candles for nil pair, please enter valid input")
+		return
+	}
+
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for GetCandles: %s", err)
+		return
+	}
+
+	var currCandleStore cxdb.CandleStore
+	var ok bool
+	if currCandleStore, ok = server.CandleStores[*pair]; !ok {
+		err = fmt.Errorf("Could not find candle store for trading pair for GetCandles")
+		server.unlockBook(*pair)
+		return
+	}
+
+	if candles, err = currCandleStore.GetCandles(interval, start, end); err != nil {
+		err = fmt.Errorf("Error getting candles for server GetCandles: %s", err)
+		server.unlockBook(*pair)
+		return
+	}
+
+	server.unlockBook(*pair)
+	return
+}
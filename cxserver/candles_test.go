@@ -0,0 +1,159 @@
+package cxserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestCandles matches orders at two prices and makes sure every interval has a candle with both trades in
+// it, and that a server replaying the journal has the same candles.
+func TestCandles(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestCandles: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestCandles: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestCandles: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestCandles: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 2; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestCandles: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+
+		for _, coin := range coinList {
+			if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestCandles: %s", err)
+				return
+			}
+		}
+	}
+
+	// The maker's sells are the trade prices, first 1 and then 2
+	prices := []match.Price{{AmountWant: 100, AmountHave: 100}, {AmountWant: 200, AmountHave: 100}}
+	for _, price := range prices {
+		sellOrder := &match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  price.AmountHave,
+			AmountWant:  price.AmountWant,
+		}
+		copy(sellOrder.Pubkey[:], pubs[0].SerializeCompressed())
+
+		if _, err = server.PlaceOrder(sellOrder); err != nil {
+			t.Errorf("Error placing sell order for TestCandles: %s", err)
+			return
+		}
+
+		buyOrder := &match.LimitOrder{
+			Side:        match.Buy,
+			TradingPair: *pair,
+			AmountHave:  price.AmountHave,
+			AmountWant:  price.AmountWant,
+		}
+		copy(buyOrder.Pubkey[:], pubs[1].SerializeCompressed())
+
+		if _, err = server.PlaceOrder(buyOrder); err != nil {
+			t.Errorf("Error placing buy order for TestCandles: %s", err)
+			return
+		}
+	}
+
+	var trades []*match.Trade
+	if trades, err = server.GetRecentTrades(pair, 10); err != nil {
+		t.Errorf("Error getting trades for TestCandles: %s", err)
+		return
+	}
+
+	if len(trades) != 2 {
+		t.Errorf("There should have been 2 trades but there were %d", len(trades))
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestCandles: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestCandles: %s", err)
+		return
+	}
+
+	for _, interval := range match.CandleIntervals {
+		var candles []*match.Candle
+		if candles, err = server.GetCandles(pair, interval, time.Time{}, time.Time{}); err != nil {
+			t.Errorf("Error getting %s candles for TestCandles: %s", interval.String(), err)
+			return
+		}
+
+		// The trades could be on either side of the start of a minute
+		var executions uint64
+		var volume uint64
+		for _, candle := range candles {
+			executions += candle.Executions
+			volume += candle.Volume
+		}
+
+		if executions != 2 || volume != trades[0].AmountWant+trades[1].AmountWant {
+			t.Errorf("The %s candles should have had both trades but had %d executions and %d volume", interval.String(), executions, volume)
+			return
+		}
+
+		if candles[0].Open != trades[1].Price || candles[len(candles)-1].Close != trades[0].Price {
+			t.Errorf("The %s candles should have opened at %s and closed at %s", interval.String(), trades[1].Price.String(), trades[0].Price.String())
+			return
+		}
+
+		var replayedCandles []*match.Candle
+		if replayedCandles, err = replayServer.GetCandles(pair, interval, time.Time{}, time.Time{}); err != nil {
+			t.Errorf("Error getting replayed %s candles for TestCandles: %s", interval.String(), err)
+			return
+		}
+
+		if len(replayedCandles) != len(candles) {
+			t.Errorf("Replayed server should have had %d %s candles but had %d", len(candles), interval.String(), len(replayedCandles))
+			return
+		}
+
+		for i := range candles {
+			if *replayedCandles[i] != *candles[i] {
+				t.Errorf("Replayed %s candle %d should have been %s but was %s", interval.String(), i, candles[i].String(), replayedCandles[i].String())
+				return
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,188 @@
+package cxserver
+
+import (
+	"container/list"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/match"
+)
+
+// ChallengeLifetime is how long a client has to answer a challenge after it's issued
+const ChallengeLifetime = 5 * time.Minute
+
+// maxChallenges is the most challenges that can be waiting to be answered at once, so asking for
+// challenges can't use up the exchange's memory. Once there are this many, the oldest one is forgotten to
+// make room for a new one.
+const maxChallenges = 1 << 16
+
+// maxChallengesPerRequester is the most challenges that can be waiting to be answered for one requester.
+// Once a requester has this many, their oldest one is forgotten to make room for a new one, so one client
+// asking for a lot of challenges only pushes out their own.
+const maxChallengesPerRequester = 16
+
+// issuedChallenge is a challenge that's waiting to be answered, with who asked for it and where it is in
+// the order challenges were issued in
+type issuedChallenge struct {
+	challenge *match.Challenge
+	requester string
+	element   *list.Element
+}
+
+// challengeRequester returns who a challenge is for, for limiting how many challenges each one can have
+// waiting. It's the session if the connection was authenticated with noise, otherwise the host of the
+// remote address.
+func challengeRequester(session *koblitz.PublicKey, remoteAddr string) (requester string) {
+	if session != nil {
+		requester = fmt.Sprintf("session %x", session.SerializeCompressed())
+		return
+	}
+
+	requester = remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		requester = host
+	}
+	requester = "address " + requester
+	return
+}
+
+// NewChallenge issues a challenge for action. The session is the key the connection asking for it was
+// authenticated with over noise, or nil if it wasn't, and the challenge can only be answered on a
+// connection with the same session. The remote address is where the connection asking for it is from,
+// and is used to limit how many challenges a connection without a session can have waiting.
+func (server *OpencxServer) NewChallenge(action match.ChallengeAction, session *koblitz.PublicKey, remoteAddr string) (challenge *match.Challenge, err error) {
+	if action != match.RegisterChallenge && action != match.ReadChallenge {
+		err = fmt.Errorf("Cannot issue challenge for unknown action %d", action)
+		return
+	}
+
+	now := time.Now()
+	challenge = &match.Challenge{
+		Action: action,
+		Expiry: uint64(now.Add(ChallengeLifetime).Unix()),
+	}
+
+	if session != nil {
+		copy(challenge.Session[:], session.SerializeCompressed())
+	}
+
+	if _, err = rand.Read(challenge.Nonce[:]); err != nil {
+		err = fmt.Errorf("Error creating nonce for NewChallenge: %s", err)
+		return
+	}
+
+	requester := challengeRequester(session, remoteAddr)
+
+	server.challengeMtx.Lock()
+	if requested := server.challengesByRequester[requester]; len(requested) >= maxChallengesPerRequester {
+		server.forgetChallenge(requested[0])
+	}
+
+	if len(server.challenges) >= maxChallenges {
+		server.pruneChallenges(now)
+	}
+
+	if len(server.challenges) >= maxChallenges {
+		server.forgetChallenge(server.challengeOrder.Front().Value.([32]byte))
+	}
+
+	server.challenges[challenge.Nonce] = &issuedChallenge{
+		challenge: challenge,
+		requester: requester,
+		element:   server.challengeOrder.PushBack(challenge.Nonce),
+	}
+	server.challengesByRequester[requester] = append(server.challengesByRequester[requester], challenge.Nonce)
+	server.challengeMtx.Unlock()
+	return
+}
+
+// AnswerChallenge checks a signature answering the challenge with nonce for action, on a connection with
+// session, and returns the pubkey that signed it. A challenge can only be answered once, so it can't be
+// answered again even if this returns an error.
+func (server *OpencxServer) AnswerChallenge(action match.ChallengeAction, session *koblitz.PublicKey, nonce [32]byte, sig []byte) (pubkey *koblitz.PublicKey, err error) {
+	server.challengeMtx.Lock()
+	issued, ok := server.challenges[nonce]
+	server.forgetChallenge(nonce)
+	server.challengeMtx.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("Challenge %x was never issued, has already been answered, or was forgotten to make room for newer ones", nonce)
+		return
+	}
+	challenge := issued.challenge
+
+	if challenge.Action != action {
+		err = fmt.Errorf("Challenge was issued to %s, not to %s", challenge.Action.String(), action.String())
+		return
+	}
+
+	if uint64(time.Now().Unix()) > challenge.Expiry {
+		err = fmt.Errorf("Challenge expired at %d", challenge.Expiry)
+		return
+	}
+
+	var sessionBytes [33]byte
+	if session != nil {
+		copy(sessionBytes[:], session.SerializeCompressed())
+	}
+
+	if challenge.Session != sessionBytes {
+		err = fmt.Errorf("Challenge was issued on another connection, it can only be answered on the connection it was issued on")
+		return
+	}
+
+	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), sig, challenge.SigHash()); err != nil {
+		err = fmt.Errorf("Error verifying challenge, invalid signature: %s", err)
+		return
+	}
+	return
+}
+
+// PruneChallenges forgets the challenges that have expired at the time now
+func (server *OpencxServer) PruneChallenges(now time.Time) {
+	server.challengeMtx.Lock()
+	server.pruneChallenges(now)
+	server.challengeMtx.Unlock()
+	return
+}
+
+// pruneChallenges forgets the challenges that have expired at the time now. This should be called while
+// holding challengeMtx.
+func (server *OpencxServer) pruneChallenges(now time.Time) {
+	for nonce, issued := range server.challenges {
+		if uint64(now.Unix()) > issued.challenge.Expiry {
+			server.forgetChallenge(nonce)
+		}
+	}
+	return
+}
+
+// forgetChallenge forgets the challenge with nonce, if it's waiting to be answered. This should be called
+// while holding challengeMtx.
+func (server *OpencxServer) forgetChallenge(nonce [32]byte) {
+	issued, ok := server.challenges[nonce]
+	if !ok {
+		return
+	}
+
+	delete(server.challenges, nonce)
+	server.challengeOrder.Remove(issued.element)
+
+	requested := server.challengesByRequester[issued.requester]
+	for i, requestedNonce := range requested {
+		if requestedNonce == nonce {
+			requested = append(requested[:i], requested[i+1:]...)
+			break
+		}
+	}
+
+	if len(requested) == 0 {
+		delete(server.challengesByRequester, issued.requester)
+	} else {
+		server.challengesByRequester[issued.requester] = requested
+	}
+	return
+}
@@ -0,0 +1,173 @@
+package cxserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestAnswerChallenge answers challenges, making sure a challenge can only be answered once, only for the
+// action and session it was issued for, and only before it expires.
+func TestAnswerChallenge(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	var sessionPriv *koblitz.PrivateKey
+	if sessionPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating session key for TestAnswerChallenge: %s", err)
+		return
+	}
+	session := sessionPriv.PubKey()
+
+	// issue gets a challenge and signs it
+	issue := func(action match.ChallengeAction, session *koblitz.PublicKey) (challenge *match.Challenge, sig []byte, err error) {
+		if challenge, err = server.NewChallenge(action, session, "127.0.0.1:1234"); err != nil {
+			return
+		}
+		sig, err = koblitz.SignCompact(koblitz.S256(), priv, challenge.SigHash(), false)
+		return
+	}
+
+	var challenge *match.Challenge
+	var sig []byte
+	if challenge, sig, err = issue(match.ReadChallenge, session); err != nil {
+		t.Errorf("Error issuing challenge for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	var pubkey *koblitz.PublicKey
+	if pubkey, err = server.AnswerChallenge(match.ReadChallenge, session, challenge.Nonce, sig); err != nil {
+		t.Errorf("Error answering challenge for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	if !pubkey.IsEqual(priv.PubKey()) {
+		t.Errorf("Answering challenge recovered the wrong pubkey for TestAnswerChallenge")
+		return
+	}
+
+	if _, err = server.AnswerChallenge(match.ReadChallenge, session, challenge.Nonce, sig); err == nil {
+		t.Errorf("Challenge was answered twice for TestAnswerChallenge")
+		return
+	}
+
+	// A read challenge can't be used to register
+	if challenge, sig, err = issue(match.ReadChallenge, session); err != nil {
+		t.Errorf("Error issuing challenge for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	if _, err = server.AnswerChallenge(match.RegisterChallenge, session, challenge.Nonce, sig); err == nil {
+		t.Errorf("Read challenge was answered for register for TestAnswerChallenge")
+		return
+	}
+
+	// A challenge can't be answered on another connection
+	if challenge, sig, err = issue(match.ReadChallenge, session); err != nil {
+		t.Errorf("Error issuing challenge for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	if _, err = server.AnswerChallenge(match.ReadChallenge, nil, challenge.Nonce, sig); err == nil {
+		t.Errorf("Challenge was answered on another connection for TestAnswerChallenge")
+		return
+	}
+
+	// A challenge can't be answered after it expires, and is pruned
+	if challenge, sig, err = issue(match.RegisterChallenge, nil); err != nil {
+		t.Errorf("Error issuing challenge for TestAnswerChallenge: %s", err)
+		return
+	}
+
+	server.PruneChallenges(time.Now().Add(ChallengeLifetime + time.Minute))
+
+	if _, err = server.AnswerChallenge(match.RegisterChallenge, nil, challenge.Nonce, sig); err == nil {
+		t.Errorf("Challenge was answered after it expired for TestAnswerChallenge")
+		return
+	}
+
+	return
+}
+
+// TestChallengeLimit asks for more challenges than one requester can have waiting, making sure only the
+// oldest ones from that requester are forgotten.
+func TestChallengeLimit(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestChallengeLimit: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestChallengeLimit: %s", err)
+		return
+	}
+
+	var other *match.Challenge
+	if other, err = server.NewChallenge(match.ReadChallenge, nil, "127.0.0.2:1234"); err != nil {
+		t.Errorf("Error issuing challenge for other address for TestChallengeLimit: %s", err)
+		return
+	}
+
+	// The same host on different ports is the same requester
+	var challenges []*match.Challenge
+	for i := 0; i < maxChallengesPerRequester+1; i++ {
+		var challenge *match.Challenge
+		if challenge, err = server.NewChallenge(match.ReadChallenge, nil, fmt.Sprintf("127.0.0.1:%d", 1000+i)); err != nil {
+			t.Errorf("Error issuing challenge %d for TestChallengeLimit: %s", i, err)
+			return
+		}
+		challenges = append(challenges, challenge)
+	}
+
+	// answer signs a challenge and answers it
+	answer := func(challenge *match.Challenge) (err error) {
+		var sig []byte
+		if sig, err = koblitz.SignCompact(koblitz.S256(), priv, challenge.SigHash(), false); err != nil {
+			return
+		}
+		_, err = server.AnswerChallenge(match.ReadChallenge, nil, challenge.Nonce, sig)
+		return
+	}
+
+	if err = answer(challenges[0]); err == nil {
+		t.Errorf("Oldest challenge was not forgotten when the requester had too many for TestChallengeLimit")
+		return
+	}
+
+	for i, challenge := range challenges[1:] {
+		if err = answer(challenge); err != nil {
+			t.Errorf("Error answering challenge %d for TestChallengeLimit: %s", i+1, err)
+			return
+		}
+	}
+
+	if err = answer(other); err != nil {
+		t.Errorf("Challenge for another address was forgotten for TestChallengeLimit: %s", err)
+		return
+	}
+
+	return
+}
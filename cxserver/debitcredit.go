@@ -11,7 +11,7 @@ import (
 
 // DebitUser adds to the balance of the pubkey by issuing a settlement exec and bringing it through
 // all of the required data stores.
-// DebitUser acquires dbLock so it can just be called.
+// DebitUser acquires the lock for the asset so it can just be called.
 func (server *OpencxServer) DebitUser(pubkey *koblitz.PublicKey, amount uint64, param *coinparam.Params) (err error) {
 
 	var assetToDebit match.Asset
@@ -21,21 +21,23 @@ func (server *OpencxServer) DebitUser(pubkey *koblitz.PublicKey, amount uint64,
 	}
 
 	// Lock!
-	server.dbLock.Lock()
+	if err = server.lockAsset(param); err != nil {
+		err = fmt.Errorf("Error locking asset for DebitUser: %s", err)
+		return
+	}
 
 	// Get the settle store and the settle engine for the coin
-	var currSettleStore cxdb.SettlementStore
 	var ok bool
-	if currSettleStore, ok = server.SettlementStores[param]; !ok {
+	if _, ok = server.SettlementStores[param]; !ok {
 		err = fmt.Errorf("Could not find settlement store for cointype %s", param.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
 	var currSettleEngine match.SettlementEngine
 	if currSettleEngine, ok = server.SettlementEngines[param]; !ok {
 		err = fmt.Errorf("Could not find settlement engine for cointype %s", param.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
@@ -49,39 +51,40 @@ func (server *OpencxServer) DebitUser(pubkey *koblitz.PublicKey, amount uint64,
 	var valid bool
 	if valid, err = currSettleEngine.CheckValid(setExecForPush); err != nil {
 		err = fmt.Errorf("Error checking valid exec for DebitUser: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
-	var setRes *match.SettlementResult
-	var settlementResults []*match.SettlementResult
-	if valid {
-		if setRes, err = currSettleEngine.ApplySettlementExecution(setExecForPush); err != nil {
-			err = fmt.Errorf("Error applying settlement exec for DebitUser: %s", err)
-			server.dbLock.Unlock()
-			return
-		}
-	} else {
+	if !valid {
 		err = fmt.Errorf("Error, invalid settlement exec for DebitUser")
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
-	settlementResults = append(settlementResults, setRes)
+	settleEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalSettle,
+		SettlementExecs: []*match.SettlementExecution{setExecForPush},
+	}
 
-	if err = currSettleStore.UpdateBalances(settlementResults); err != nil {
-		err = fmt.Errorf("Error updating balances for DebitUser: %s", err)
-		server.dbLock.Unlock()
+	if err = server.writeJournal(settleEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for DebitUser: %s", err)
+		server.unlockAsset(param)
 		return
 	}
 
-	server.dbLock.Unlock()
+	if err = server.applyJournalEntry(settleEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for DebitUser: %s", err)
+		server.unlockAsset(param)
+		return
+	}
+
+	server.unlockAsset(param)
 	return
 }
 
 // CreditUser subtracts the balance of the pubkey by issuing a settlement exec and bringing it through
 // all of the required data stores.
-// CreditUser acquires dbLock so it can just be called.
+// CreditUser acquires the lock for the asset so it can just be called.
 func (server *OpencxServer) CreditUser(pubkey *koblitz.PublicKey, amount uint64, param *coinparam.Params) (err error) {
 
 	var assetToCredit match.Asset
@@ -91,21 +94,23 @@ func (server *OpencxServer) CreditUser(pubkey *koblitz.PublicKey, amount uint64,
 	}
 
 	// Lock!
-	server.dbLock.Lock()
+	if err = server.lockAsset(param); err != nil {
+		err = fmt.Errorf("Error locking asset for CreditUser: %s", err)
+		return
+	}
 
 	// Get the settle store and the settle engine for the coin
-	var currSettleStore cxdb.SettlementStore
 	var ok bool
-	if currSettleStore, ok = server.SettlementStores[param]; !ok {
+	if _, ok = server.SettlementStores[param]; !ok {
 		err = fmt.Errorf("Could not find settlement store for cointype %s", param.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
 	var currSettleEngine match.SettlementEngine
 	if currSettleEngine, ok = server.SettlementEngines[param]; !ok {
 		err = fmt.Errorf("Could not find settlement engine for cointype %s", param.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
@@ -119,31 +124,33 @@ func (server *OpencxServer) CreditUser(pubkey *koblitz.PublicKey, amount uint64,
 	var valid bool
 	if valid, err = currSettleEngine.CheckValid(setExecForPush); err != nil {
 		err = fmt.Errorf("Error checking valid exec for CreditUser: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
 		return
 	}
 
-	var setRes *match.SettlementResult
-	var settlementResults []*match.SettlementResult
-	if valid {
-		if setRes, err = currSettleEngine.ApplySettlementExecution(setExecForPush); err != nil {
-			err = fmt.Errorf("Error applying settlement exec for CreditUser: %s", err)
-			server.dbLock.Unlock()
-			return
-		}
-	} else {
+	if !valid {
 		err = fmt.Errorf("Error, invalid settlement exec for CreditUser")
-		server.dbLock.Unlock()
+		server.unlockAsset(param)
+		return
+	}
+
+	settleEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalSettle,
+		SettlementExecs: []*match.SettlementExecution{setExecForPush},
+	}
+
+	if err = server.writeJournal(settleEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for CreditUser: %s", err)
+		server.unlockAsset(param)
 		return
 	}
-	settlementResults = append(settlementResults, setRes)
 
-	if err = currSettleStore.UpdateBalances(settlementResults); err != nil {
-		err = fmt.Errorf("Error updating balances for CreditUser: %s", err)
-		server.dbLock.Unlock()
+	if err = server.applyJournalEntry(settleEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for CreditUser: %s", err)
+		server.unlockAsset(param)
 		return
 	}
 
-	server.dbLock.Unlock()
+	server.unlockAsset(param)
 	return
 }
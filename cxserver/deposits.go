@@ -13,34 +13,35 @@ import (
 func (server *OpencxServer) RegisterUser(pubkey *koblitz.PublicKey) (err error) {
 
 	// go through all params in settlement layer
+	// The wallets have their own lock, so we don't need any of the asset locks to get addresses
 	addrMap := make(map[*coinparam.Params]string)
-	server.dbLock.Lock()
 	for param, _ := range server.SettlementEngines {
 		if addrMap[param], err = server.GetAddrForCoin(param, pubkey); err != nil {
 			err = fmt.Errorf("Error getting address for pubkey and coin for RegisterUser: %s", err)
-			server.dbLock.Unlock()
 			return
 		}
 	}
-	server.dbLock.Unlock()
 
-	// TODO: might not need such a generous use of locks here
-	// Should separate deposit/stores from the engines
 	var currDepositStore cxdb.DepositStore
 	var ok bool
 	for param, addr := range addrMap {
-		server.dbLock.Lock()
+		if err = server.lockAsset(param); err != nil {
+			err = fmt.Errorf("Error locking asset for RegisterUser: %s", err)
+			return
+		}
+
 		if currDepositStore, ok = server.DepositStores[param]; !ok {
 			err = fmt.Errorf("Could not find deposit store for %s coin", param.Name)
-			server.dbLock.Unlock()
+			server.unlockAsset(param)
 			return
 		}
-		server.dbLock.Unlock()
 
 		if err = currDepositStore.RegisterUser(pubkey, addr); err != nil {
 			err = fmt.Errorf("Error registering user for deposit address for ingestChannelFund: %s", err)
+			server.unlockAsset(param)
 			return
 		}
+		server.unlockAsset(param)
 
 		if err = server.DebitUser(pubkey, 0, param); err != nil {
 			err = fmt.Errorf("Error giving user a balance of zero for RegisterUser: %s", err)
@@ -55,22 +56,26 @@ func (server *OpencxServer) RegisterUser(pubkey *koblitz.PublicKey) (err error)
 // in the server
 func (server *OpencxServer) GetDepositAddress(pubkey *koblitz.PublicKey, coin *coinparam.Params) (address string, err error) {
 
-	server.dbLock.Lock()
+	if err = server.lockAsset(coin); err != nil {
+		err = fmt.Errorf("Error locking asset for GetDepositAddress: %s", err)
+		return
+	}
+
 	// first get the deposit store for the boi
 	var currDepositStore cxdb.DepositStore
 	var ok bool
 	if currDepositStore, ok = server.DepositStores[coin]; !ok {
 		err = fmt.Errorf("Could not find DepositStore for %s for GetDepositAddress", coin.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(coin)
 		return
 	}
 
 	if address, err = currDepositStore.GetDepositAddress(pubkey); err != nil {
 		err = fmt.Errorf("Error getting deposit address from store for GetDepositAddress: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(coin)
 		return
 	}
-	server.dbLock.Unlock()
+	server.unlockAsset(coin)
 
 	return
 }
@@ -0,0 +1,28 @@
+package cxserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetDepth returns the orderbook for a pair added up by price, with at most levels prices on each side,
+// so users can see the book without seeing whose orders are on it
+func (server *OpencxServer) GetDepth(pair *match.Pair, levels uint64) (depth *match.Depth, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot get depth for nil pair, please enter valid input")
+		return
+	}
+
+	var book map[match.Price][]*match.LimitOrderIDPair
+	if book, err = server.ViewOrderbook(pair); err != nil {
+		err = fmt.Errorf("Error viewing orderbook for GetDepth: %s", err)
+		return
+	}
+
+	if depth, err = match.NewDepth(*pair, book, levels); err != nil {
+		err = fmt.Errorf("Error adding up orderbook for GetDepth: %s", err)
+		return
+	}
+	return
+}
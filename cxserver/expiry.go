@@ -0,0 +1,71 @@
+package cxserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// StartExpirySweeper cancels the orders that have expired every interval, and forgets the nonces of
+// envelopes and the challenges that have expired, until the server shuts down. Expired orders can still be matched until
+// they're swept, so the interval should be short.
+func (server *OpencxServer) StartExpirySweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for now := range ticker.C {
+			if err := server.SweepExpiredOrders(now); err != nil {
+				logging.Errorf("Error sweeping expired orders: %s", err)
+			}
+
+			if err := server.PruneNonces(now); err != nil {
+				logging.Errorf("Error pruning expired nonces: %s", err)
+			}
+
+			server.PruneChallenges(now)
+		}
+	}()
+}
+
+// SweepExpiredOrders cancels every order on the books that has expired at the time now, giving back the
+// funds that haven't been traded yet. Each pair is swept while it's locked, so orders can't be placed on it
+// in the middle of a sweep. Pairs that orders can't be cancelled on aren't swept.
+func (server *OpencxServer) SweepExpiredOrders(now time.Time) (err error) {
+	for _, pair := range server.pairList() {
+		if err = server.lockPair(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for SweepExpiredOrders: %s", err)
+			return
+		}
+
+		currOrderbook, ok := server.Orderbooks[pair]
+		if !ok || !server.pairStatus[pair].CanCancel() {
+			server.unlockPair(pair)
+			continue
+		}
+
+		var book map[match.Price][]*match.LimitOrderIDPair
+		if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+			err = fmt.Errorf("Error viewing orderbook for SweepExpiredOrders: %s", err)
+			server.unlockPair(pair)
+			return
+		}
+
+		for _, orders := range book {
+			for _, order := range orders {
+				if !order.Order.Expired(now) {
+					continue
+				}
+
+				if err = server.cancelOrder(order); err != nil {
+					err = fmt.Errorf("Error cancelling expired order %x for SweepExpiredOrders: %s", order.OrderID[:], err)
+					server.unlockPair(pair)
+					return
+				}
+				logging.Infof("Cancelled order %x, it expired at %s", order.OrderID[:], time.Unix(int64(order.Order.Expiry), 0).String())
+			}
+		}
+		server.unlockPair(pair)
+	}
+	return
+}
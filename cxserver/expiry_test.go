@@ -0,0 +1,149 @@
+package cxserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestSweepExpiredOrders places an order that expires and one that doesn't, and makes sure only the one
+// that expired is cancelled and refunded by the sweep.
+func TestSweepExpiredOrders(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestSweepExpiredOrders: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	// Sell orders are funded in the asset want
+	var wantParam *coinparam.Params
+	if wantParam, err = pair.AssetWant.CoinParamFromAsset(); err != nil {
+		t.Errorf("Error getting param for asset want for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestSweepExpiredOrders: %s", err)
+		return
+	}
+	pub := priv.PubKey()
+
+	if err = server.DebitUser(pub, 1000, wantParam); err != nil {
+		t.Errorf("Error debiting user for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	expiry := time.Now().Add(time.Hour)
+
+	expiringOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+		Expiry:      uint64(expiry.Unix()),
+	}
+	copy(expiringOrder.Pubkey[:], pub.SerializeCompressed())
+
+	var expiringID *match.OrderID
+	if expiringID, err = server.PlaceOrder(expiringOrder); err != nil {
+		t.Errorf("Error placing expiring order for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	restingOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+	copy(restingOrder.Pubkey[:], pub.SerializeCompressed())
+
+	var restingID *match.OrderID
+	if restingID, err = server.PlaceOrder(restingOrder); err != nil {
+		t.Errorf("Error placing resting order for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	// Nothing has expired yet
+	if err = server.SweepExpiredOrders(expiry.Add(-time.Second)); err != nil {
+		t.Errorf("Error sweeping before expiry for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	if _, err = server.GetOrder(expiringID); err != nil {
+		t.Errorf("Order should still be on the book before it expires: %s", err)
+		return
+	}
+
+	if err = server.SweepExpiredOrders(expiry); err != nil {
+		t.Errorf("Error sweeping at expiry for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	if _, err = server.GetOrder(expiringID); err == nil {
+		t.Errorf("Expired order should have been cancelled by the sweep")
+		return
+	}
+
+	if _, err = server.GetOrder(restingID); err != nil {
+		t.Errorf("Order without an expiry should still be on the book: %s", err)
+		return
+	}
+
+	var bal uint64
+	if bal, err = server.GetBalance(pub, wantParam); err != nil {
+		t.Errorf("Error getting balance for TestSweepExpiredOrders: %s", err)
+		return
+	}
+
+	// Only the order without an expiry should still be holding funds
+	if bal != 1000-50 {
+		t.Errorf("Balance after sweep should have been %d but was %d", 1000-50, bal)
+		return
+	}
+
+	// Orders that have already expired can't be placed
+	expiredOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  10,
+		AmountWant:  10,
+		Expiry:      uint64(time.Now().Add(-time.Minute).Unix()),
+	}
+	copy(expiredOrder.Pubkey[:], pub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(expiredOrder); err == nil {
+		t.Errorf("Placing an order that already expired should have failed")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,44 @@
+package cxserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetFees sets the account that fees are paid to and the fee schedule for each pair. Pairs without a fee
+// schedule don't charge fees.
+func (server *OpencxServer) SetFees(account [33]byte, schedules map[match.Pair]*match.FeeSchedule) (err error) {
+	for pair, schedule := range schedules {
+		if schedule == nil {
+			err = fmt.Errorf("Fee schedule for %s cannot be nil, please enter valid input", pair.String())
+			return
+		}
+
+		if err = schedule.Valid(); err != nil {
+			err = fmt.Errorf("Invalid fee schedule for %s for SetFees: %s", pair.String(), err)
+			return
+		}
+	}
+
+	server.lockAll()
+	server.FeeAccount = account
+	server.FeeSchedules = make(map[match.Pair]*match.FeeSchedule)
+	for pair, schedule := range schedules {
+		server.FeeSchedules[pair] = schedule
+	}
+	server.unlockAll()
+	return
+}
+
+// GetFeeRevenue returns the total amount of each asset that has been paid in fees
+func (server *OpencxServer) GetFeeRevenue() (revenue map[match.Asset]uint64, err error) {
+	revenue = make(map[match.Asset]uint64)
+
+	server.feeMtx.Lock()
+	for asset, amount := range server.feeRevenue {
+		revenue[asset] = amount
+	}
+	server.feeMtx.Unlock()
+	return
+}
@@ -0,0 +1,171 @@
+package cxserver
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestFees matches orders on a pair with a fee schedule, and makes sure the maker, taker, and tiered fees
+// are paid to the fee account, and that a server replaying the journal ends up with the same fee revenue.
+func TestFees(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestFees: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestFees: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestFees: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestFees: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 4; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestFees: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	makerPub, tieredPub, takerPub, feePub := pubs[0], pubs[1], pubs[2], pubs[3]
+
+	// Settlements from matching can credit either asset, so every user gets both
+	for _, pub := range []*koblitz.PublicKey{makerPub, tieredPub, takerPub} {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 100000, coin); err != nil {
+				t.Errorf("Error debiting user for TestFees: %s", err)
+				return
+			}
+		}
+	}
+
+	var feeAccount [33]byte
+	copy(feeAccount[:], feePub.SerializeCompressed())
+
+	schedule := &match.FeeSchedule{
+		MakerFee: 10,
+		TakerFee: 20,
+		Tiers: map[string]match.FeeTier{
+			hex.EncodeToString(tieredPub.SerializeCompressed()): {MakerFee: 0, TakerFee: 5},
+		},
+	}
+	if err = server.SetFees(feeAccount, map[match.Pair]*match.FeeSchedule{*pair: schedule}); err != nil {
+		t.Errorf("Error setting fees for TestFees: %s", err)
+		return
+	}
+
+	// The maker pays 10 and the taker pays 20 for the first trade, and the tiered maker pays nothing for
+	// the second
+	for _, makerPubkey := range []*koblitz.PublicKey{makerPub, tieredPub} {
+		sellOrder := &match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  10000,
+			AmountWant:  10000,
+		}
+		copy(sellOrder.Pubkey[:], makerPubkey.SerializeCompressed())
+
+		if _, err = server.PlaceOrder(sellOrder); err != nil {
+			t.Errorf("Error placing sell order for TestFees: %s", err)
+			return
+		}
+
+		buyOrder := &match.LimitOrder{
+			Side:        match.Buy,
+			TradingPair: *pair,
+			AmountHave:  10000,
+			AmountWant:  10000,
+		}
+		copy(buyOrder.Pubkey[:], takerPub.SerializeCompressed())
+
+		if _, err = server.PlaceOrder(buyOrder); err != nil {
+			t.Errorf("Error placing buy order for TestFees: %s", err)
+			return
+		}
+	}
+
+	var revenue map[match.Asset]uint64
+	if revenue, err = server.GetFeeRevenue(); err != nil {
+		t.Errorf("Error getting fee revenue for TestFees: %s", err)
+		return
+	}
+
+	var totalRevenue uint64
+	var totalBalance uint64
+	for _, coin := range coinList {
+		var asset match.Asset
+		if asset, err = match.AssetFromCoinParam(coin); err != nil {
+			t.Errorf("Error getting asset for TestFees: %s", err)
+			return
+		}
+		totalRevenue += revenue[asset]
+
+		var bal uint64
+		if bal, err = server.GetBalance(feePub, coin); err != nil {
+			t.Errorf("Error getting fee account balance for TestFees: %s", err)
+			return
+		}
+		totalBalance += bal
+	}
+
+	if totalRevenue != 10+20+20 {
+		t.Errorf("Fee revenue should have been %d but was %d", 10+20+20, totalRevenue)
+		return
+	}
+
+	if totalBalance != totalRevenue {
+		t.Errorf("Fee account balance should have been %d but was %d", totalRevenue, totalBalance)
+		return
+	}
+
+	// Fees are in the journal, so replaying it without a fee schedule gets the same revenue
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestFees: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestFees: %s", err)
+		return
+	}
+
+	var replayedRevenue map[match.Asset]uint64
+	if replayedRevenue, err = replayServer.GetFeeRevenue(); err != nil {
+		t.Errorf("Error getting replayed fee revenue for TestFees: %s", err)
+		return
+	}
+
+	for asset, amount := range revenue {
+		if replayedRevenue[asset] != amount {
+			t.Errorf("Replayed fee revenue for %s should have been %d but was %d", asset.String(), amount, replayedRevenue[asset])
+			return
+		}
+	}
+
+	return
+}
@@ -23,13 +23,17 @@ func (server *OpencxServer) ingestTransactionListAndHeight(txList []*wire.MsgTx,
 	// check if the receiver is us
 	// if so, add the deposit to the table, create a # of confirmations past the height at which it was received
 
-	server.dbLock.Lock()
+	if err = server.lockAsset(coinType); err != nil {
+		err = fmt.Errorf("Error locking asset for ingestTransactionListAndHeight: %s", err)
+		return
+	}
+
 	// First get the correct deposit store. We don't need the others just yet
 	var currDepositStore cxdb.DepositStore
 	var ok bool
 	if currDepositStore, ok = server.DepositStores[coinType]; !ok {
 		err = fmt.Errorf("Could not find deposit store for cointype %s: %s", coinType.Name, err)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
 		return
 	}
 
@@ -37,10 +41,10 @@ func (server *OpencxServer) ingestTransactionListAndHeight(txList []*wire.MsgTx,
 	if addressesWeOwn, err = currDepositStore.GetDepositAddressMap(); err != nil {
 		// if errors out, unlock
 		err = fmt.Errorf("Error getting deposit address map: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
 		return
 	}
-	server.dbLock.Unlock()
+	server.unlockAsset(coinType)
 
 	var deposits []match.Deposit
 
@@ -129,68 +133,79 @@ func (server *OpencxServer) ingestChannelConfirm(state *qln.StatCom, pubkey *kob
 // updateDepositsAtHeight acquires locks and does all of the required actions to update the exchange
 // when deposits come in at a certain block for a certain coin
 func (server *OpencxServer) updateDepositsAtHeight(deposits []match.Deposit, height uint64, coinType *coinparam.Params) (err error) {
-	server.dbLock.Lock()
+	if err = server.lockAsset(coinType); err != nil {
+		err = fmt.Errorf("Error locking asset for updateDepositsAtHeight: %s", err)
+		return
+	}
+
 	// First get the correct deposit store, settlement engine, and settlement store
 	var currDepositStore cxdb.DepositStore
 	var ok bool
 	if currDepositStore, ok = server.DepositStores[coinType]; !ok {
 		err = fmt.Errorf("Could not find deposit store for cointype %s", coinType.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
 		return
 	}
 
-	var currSettleStore cxdb.SettlementStore
-	if currSettleStore, ok = server.SettlementStores[coinType]; !ok {
+	if _, ok = server.SettlementStores[coinType]; !ok {
 		err = fmt.Errorf("Could not find settlement store for cointype %s", coinType.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
 		return
 	}
 
 	var currSettleEngine match.SettlementEngine
 	if currSettleEngine, ok = server.SettlementEngines[coinType]; !ok {
 		err = fmt.Errorf("Could not find settlement engine for cointype %s", coinType.Name)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
 		return
 	}
 	var depositExecs []*match.SettlementExecution
 	if depositExecs, err = currDepositStore.UpdateDeposits(deposits, height); err != nil {
 		// if errors out, unlock
 		err = fmt.Errorf("Error updating deposits for updateDepositsAtHeight: %s", err)
-		server.dbLock.Unlock()
+		server.unlockAsset(coinType)
+		return
+	}
+
+	// Nothing confirmed at this height, so there's nothing to journal
+	if len(depositExecs) == 0 {
+		server.unlockAsset(coinType)
 		return
 	}
 
-	var settlementResults []*match.SettlementResult
+	// We always check validity first
 	for _, setExec := range depositExecs {
-		// We always check validity first
 		var valid bool
 		if valid, err = currSettleEngine.CheckValid(setExec); err != nil {
 			err = fmt.Errorf("Error checking exec validity for updateDepositsAtHeight: %s", err)
-			server.dbLock.Unlock()
+			server.unlockAsset(coinType)
 			return
 		}
 
-		if valid {
-			var setRes *match.SettlementResult
-			if setRes, err = currSettleEngine.ApplySettlementExecution(setExec); err != nil {
-				err = fmt.Errorf("Error applying settlement exec for updateDepositsAtHeight: %s", err)
-				server.dbLock.Unlock()
-				return
-			}
-			settlementResults = append(settlementResults, setRes)
-		} else {
+		if !valid {
 			err = fmt.Errorf("Error, invalid settlement exec for updateDepositsAtHeight")
-			server.dbLock.Unlock()
+			server.unlockAsset(coinType)
 			return
 		}
 	}
 
-	if err = currSettleStore.UpdateBalances(settlementResults); err != nil {
-		err = fmt.Errorf("Error updating balances for updateDepositsAtHeight: %s", err)
-		server.dbLock.Unlock()
+	depositEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalSettle,
+		SettlementExecs: depositExecs,
+	}
+
+	if err = server.writeJournal(depositEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for updateDepositsAtHeight: %s", err)
+		server.unlockAsset(coinType)
 		return
 	}
-	server.dbLock.Unlock()
+
+	if err = server.applyJournalEntry(depositEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for updateDepositsAtHeight: %s", err)
+		server.unlockAsset(coinType)
+		return
+	}
+	server.unlockAsset(coinType)
 	return
 }
 
@@ -207,21 +222,27 @@ func (server *OpencxServer) ingestChannelFund(state *qln.StatCom, pubkey *koblit
 
 	logging.Infof("Registering user with pubkey %x\n", pubkey.SerializeCompressed())
 
-	server.dbLock.Lock()
 	var currDepositStore cxdb.DepositStore
 	var ok bool
 	for param, addr := range addrMap {
+		if err = server.lockAsset(param); err != nil {
+			err = fmt.Errorf("Error locking asset for ingestChannelFund: %s", err)
+			return
+		}
+
 		if currDepositStore, ok = server.DepositStores[param]; !ok {
 			err = fmt.Errorf("Could not find deposit store for %s coin", param.Name)
+			server.unlockAsset(param)
 			return
 		}
 
 		if err = currDepositStore.RegisterUser(pubkey, addr); err != nil {
 			err = fmt.Errorf("Error registering user for deposit address for ingestChannelFund: %s", err)
+			server.unlockAsset(param)
 			return
 		}
+		server.unlockAsset(param)
 	}
-	server.dbLock.Unlock()
 
 	if err = server.SetupFundBack(pubkey, coinType, server.defaultCapacity); err != nil {
 		err = fmt.Errorf("Error setting up fund back for ingestChannelConfirm: %s", err)
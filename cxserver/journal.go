@@ -0,0 +1,363 @@
+package cxserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// ReplayJournal rebuilds the settlement engines, matching engines, orderbooks, stop order stores, trade
+// stores, last trade prices, pair statuses, used nonces, and settlement stores from the entries in a journal, and then
+// writes every change the server makes after this to the journal. Pairs that were added with AddPair are
+// created with the PairStoreCreator.
+// This expects the engines, orderbooks, and stores to be empty, like they are when they are in memory.
+// Every entry in the journal is applied, even if the server crashed while applying it the first time,
+// since the journal is written before anything else.
+func (server *OpencxServer) ReplayJournal(journal cxdb.Journal) (err error) {
+	if journal == nil {
+		err = fmt.Errorf("Cannot replay nil journal, please enter valid input")
+		return
+	}
+
+	var entries []*cxdb.JournalEntry
+	if entries, err = journal.Entries(); err != nil {
+		err = fmt.Errorf("Error getting journal entries for ReplayJournal: %s", err)
+		return
+	}
+
+	// Make sure nothing was changed or left out before we trust the journal
+	var headHash [32]byte
+	if headHash, err = cxdb.VerifyJournal(entries); err != nil {
+		err = fmt.Errorf("Error verifying journal for ReplayJournal: %s", err)
+		return
+	}
+
+	// Pairs that were added while the exchange was running have to exist before anything on them is applied
+	server.pairsMtx.Lock()
+	for _, entry := range entries {
+		if entry.Type != cxdb.JournalAddPair {
+			continue
+		}
+
+		// The pair could have been created at startup since it was added
+		if _, ok := server.pairLocks[entry.Pair]; ok {
+			continue
+		}
+
+		var matchEngine match.LimitEngine
+		var book match.LimitOrderbook
+		var stopStore cxdb.StopOrderStore
+		var tradeStore cxdb.TradeStore
+		var candleStore cxdb.CandleStore
+		if matchEngine, book, stopStore, tradeStore, candleStore, err = server.createPairStores(entry.Pair); err != nil {
+			err = fmt.Errorf("Error creating stores for added pair %s for ReplayJournal: %s", entry.Pair.String(), err)
+			server.pairsMtx.Unlock()
+			return
+		}
+		server.insertPair(entry.Pair, matchEngine, book, stopStore, tradeStore, candleStore)
+	}
+	server.pairsMtx.Unlock()
+
+	server.lockAll()
+
+	for _, entry := range entries {
+		if err = server.applyJournalEntry(entry); err != nil {
+			err = fmt.Errorf("Error applying %s entry %d for ReplayJournal: %s", entry.Type.String(), entry.Sequence, err)
+			server.unlockAll()
+			return
+		}
+	}
+
+	// The orderbooks now have every order that is still open, with the amounts they have after all of their
+	// executions. Those are exactly the orders the matching engines should have.
+	var restored uint64
+	for pair, currOrderbook := range server.Orderbooks {
+		var currMatchEng match.LimitEngine
+		var ok bool
+		if currMatchEng, ok = server.MatchingEngines[pair]; !ok {
+			err = fmt.Errorf("Could not find matching engine for trading pair %s for ReplayJournal", pair.String())
+			server.unlockAll()
+			return
+		}
+
+		var book map[match.Price][]*match.LimitOrderIDPair
+		if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+			err = fmt.Errorf("Error viewing orderbook for ReplayJournal: %s", err)
+			server.unlockAll()
+			return
+		}
+
+		for _, orderList := range book {
+			for _, order := range orderList {
+				if err = currMatchEng.RestoreLimitOrder(order); err != nil {
+					err = fmt.Errorf("Error restoring order to matching engine for ReplayJournal: %s", err)
+					server.unlockAll()
+					return
+				}
+				restored++
+			}
+		}
+	}
+
+	server.journal = journal
+	server.unlockAll()
+
+	logging.Infof("Replayed %d journal entries up to %x, restored %d open orders", len(entries), headHash, restored)
+	return
+}
+
+// writeJournal durably writes the entry to the journal, if the server has one.
+// This should be called with the locks for everything the entry changes held, before the entry is applied.
+func (server *OpencxServer) writeJournal(entry *cxdb.JournalEntry) (err error) {
+	if server.journal == nil {
+		return
+	}
+
+	if err = server.journal.AppendEntry(entry); err != nil {
+		err = fmt.Errorf("Error appending %s entry to journal: %s", entry.Type.String(), err)
+		return
+	}
+	return
+}
+
+// applyJournalEntry applies the settlement and fee executions in an entry to the settlement engines, then the order
+// placement, replacement, executions, or cancellation to the orderbook or stop order store, then the trades
+// to the trade store and candle store, then updates the settlement stores, and finally publishes the
+// book and trades if the server has a publisher.
+// The matching engines are not changed, since they are what the entry came from.
+// This should be called with the locks for everything the entry changes held, so entries that change the
+// same pair or asset are applied in the same order they are in the journal.
+func (server *OpencxServer) applyJournalEntry(entry *cxdb.JournalEntry) (err error) {
+	if entry == nil {
+		err = fmt.Errorf("Cannot apply nil journal entry, please enter valid input")
+		return
+	}
+
+	// Keep the results for each coin so they go to the correct settlement store
+	settlementResults := make(map[*coinparam.Params][]*match.SettlementResult)
+	allSetExecs := append(append([]*match.SettlementExecution{}, entry.SettlementExecs...), entry.FeeExecs...)
+	for _, setExec := range allSetExecs {
+		var thisCoin *coinparam.Params
+		if thisCoin, err = setExec.Asset.CoinParamFromAsset(); err != nil {
+			err = fmt.Errorf("Error getting coin param from asset to find correct engine: %s", err)
+			return
+		}
+
+		var thisAssetEngine match.SettlementEngine
+		var ok bool
+		if thisAssetEngine, ok = server.SettlementEngines[thisCoin]; !ok {
+			err = fmt.Errorf("Could not find settlement engine for cointype %s", thisCoin.Name)
+			return
+		}
+
+		var valid bool
+		if valid, err = thisAssetEngine.CheckValid(setExec); err != nil {
+			err = fmt.Errorf("Error checking valid settlement exec: %s", err)
+			return
+		}
+
+		if !valid {
+			err = fmt.Errorf("Error, invalid settlement exec: \n%s", setExec.String())
+			return
+		}
+
+		var setRes *match.SettlementResult
+		if setRes, err = thisAssetEngine.ApplySettlementExecution(setExec); err != nil {
+			err = fmt.Errorf("Error applying settlement execution: %s", err)
+			return
+		}
+		settlementResults[thisCoin] = append(settlementResults[thisCoin], setRes)
+	}
+
+	// Every debit in the fee executions is a fee paid to the fee account
+	server.feeMtx.Lock()
+	for _, feeExec := range entry.FeeExecs {
+		if feeExec.Type == match.Debit {
+			server.feeRevenue[feeExec.Asset] += feeExec.Amount
+		}
+	}
+	server.feeMtx.Unlock()
+
+	switch entry.Type {
+	case cxdb.JournalPlace, cxdb.JournalReplace, cxdb.JournalMatch:
+		var currOrderbook match.LimitOrderbook
+		var ok bool
+		if currOrderbook, ok = server.Orderbooks[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find orderbook for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		// Matching what crossed while the pair wasn't matching orders doesn't place anything
+		if entry.Type != cxdb.JournalMatch {
+			if entry.PlacedOrder == nil {
+				err = fmt.Errorf("Cannot place nil order on orderbook")
+				return
+			}
+
+			// The amended order takes the place of the old one
+			if entry.Type == cxdb.JournalReplace {
+				if err = currOrderbook.UpdateBookCancel(&match.CancelledOrder{OrderID: entry.PlacedOrder.OrderID}); err != nil {
+					err = fmt.Errorf("Error removing replaced order from orderbook: %s", err)
+					return
+				}
+			}
+
+			if err = currOrderbook.UpdateBookPlace(entry.PlacedOrder); err != nil {
+				err = fmt.Errorf("Error placing order on orderbook: %s", err)
+				return
+			}
+		}
+
+		for _, orderExec := range entry.OrderExecs {
+			if err = currOrderbook.UpdateBookExec(orderExec); err != nil {
+				err = fmt.Errorf("Error updating orderbook execution: %s", err)
+				return
+			}
+		}
+
+		if len(entry.Trades) != 0 {
+			var currTradeStore cxdb.TradeStore
+			if currTradeStore, ok = server.TradeStores[entry.Pair]; !ok {
+				err = fmt.Errorf("Could not find trade store for trading pair %s", entry.Pair.String())
+				return
+			}
+
+			if err = currTradeStore.AddTrades(entry.Trades); err != nil {
+				err = fmt.Errorf("Error adding trades to trade store: %s", err)
+				return
+			}
+
+			var currCandleStore cxdb.CandleStore
+			if currCandleStore, ok = server.CandleStores[entry.Pair]; !ok {
+				err = fmt.Errorf("Could not find candle store for trading pair %s", entry.Pair.String())
+				return
+			}
+
+			var execs []*match.PriceExecution
+			for _, trade := range entry.Trades {
+				execs = append(execs, trade.PriceExecution())
+			}
+
+			if err = currCandleStore.AddExecutions(execs); err != nil {
+				err = fmt.Errorf("Error adding executions to candle store: %s", err)
+				return
+			}
+		}
+
+		for _, cancelled := range entry.CancelledOrders {
+			if err = currOrderbook.UpdateBookCancel(cancelled); err != nil {
+				err = fmt.Errorf("Error updating orderbook cancel: %s", err)
+				return
+			}
+		}
+
+		// The stop order is gone once the order it reserved funds for is placed
+		if entry.TriggeredStopOrder != nil {
+			var currStopStore cxdb.StopOrderStore
+			if currStopStore, ok = server.StopOrderStores[entry.Pair]; !ok {
+				err = fmt.Errorf("Could not find stop order store for trading pair %s", entry.Pair.String())
+				return
+			}
+
+			if err = currStopStore.RemoveStopOrder(entry.TriggeredStopOrder); err != nil {
+				err = fmt.Errorf("Error removing triggered stop order: %s", err)
+				return
+			}
+		}
+
+		if entry.LastPrice != nil {
+			var lastPrice *match.Price
+			if lastPrice, ok = server.lastPrices[entry.Pair]; !ok {
+				err = fmt.Errorf("Could not find last price for trading pair %s", entry.Pair.String())
+				return
+			}
+			*lastPrice = *entry.LastPrice
+		}
+	case cxdb.JournalCancel:
+		var currOrderbook match.LimitOrderbook
+		var ok bool
+		if currOrderbook, ok = server.Orderbooks[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find orderbook for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		if err = currOrderbook.UpdateBookCancel(entry.CancelledOrder); err != nil {
+			err = fmt.Errorf("Error updating orderbook cancel: %s", err)
+			return
+		}
+	case cxdb.JournalAddPair:
+		// Pairs are added before their entry is applied, and before the journal is replayed
+		if _, ok := server.pairStatus[entry.Pair]; !ok {
+			err = fmt.Errorf("Pair %s was not added before its entry was applied", entry.Pair.String())
+			return
+		}
+	case cxdb.JournalPairStatus:
+		var status *match.PairStatus
+		var ok bool
+		if status, ok = server.pairStatus[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find status for trading pair %s", entry.Pair.String())
+			return
+		}
+		*status = entry.PairStatus
+	case cxdb.JournalStopPlace:
+		var currStopStore cxdb.StopOrderStore
+		var ok bool
+		if currStopStore, ok = server.StopOrderStores[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find stop order store for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		if err = currStopStore.AddStopOrder(entry.PlacedStopOrder); err != nil {
+			err = fmt.Errorf("Error adding stop order to store: %s", err)
+			return
+		}
+	case cxdb.JournalStopCancel:
+		var currStopStore cxdb.StopOrderStore
+		var ok bool
+		if currStopStore, ok = server.StopOrderStores[entry.Pair]; !ok {
+			err = fmt.Errorf("Could not find stop order store for trading pair %s", entry.Pair.String())
+			return
+		}
+
+		if entry.CancelledOrder == nil {
+			err = fmt.Errorf("Cannot cancel stop order without an order ID")
+			return
+		}
+
+		if err = currStopStore.RemoveStopOrder(entry.CancelledOrder.OrderID); err != nil {
+			err = fmt.Errorf("Error removing cancelled stop order: %s", err)
+			return
+		}
+	case cxdb.JournalNonce:
+		if entry.NonceUse == nil {
+			err = fmt.Errorf("Cannot use nonce without a nonce")
+			return
+		}
+
+		if err = server.nonceStore.UseNonce(entry.NonceUse); err != nil {
+			err = fmt.Errorf("Error adding nonce to nonce store: %s", err)
+			return
+		}
+	}
+
+	// update what the client sees
+	for thisCoin, results := range settlementResults {
+		var currSetStore cxdb.SettlementStore
+		var ok bool
+		if currSetStore, ok = server.SettlementStores[thisCoin]; !ok {
+			err = fmt.Errorf("Could not find settlement store for cointype %s", thisCoin.Name)
+			return
+		}
+
+		if err = currSetStore.UpdateBalances(results); err != nil {
+			err = fmt.Errorf("Error updating balances with settlement results: %s", err)
+			return
+		}
+	}
+
+	server.publishEntry(entry)
+	return
+}
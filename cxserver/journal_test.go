@@ -0,0 +1,393 @@
+package cxserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// createMemoryServer creates a server with everything in memory, for the coins given
+func createMemoryServer(coinList []*coinparam.Params) (server *OpencxServer, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		return
+	}
+
+	var setEngines map[*coinparam.Params]match.SettlementEngine
+	if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+		return
+	}
+
+	var mengines map[match.Pair]match.LimitEngine
+	if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+		return
+	}
+
+	var limBooks map[match.Pair]match.LimitOrderbook
+	if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+		return
+	}
+
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		return
+	}
+
+	var depositStores map[*coinparam.Params]cxdb.DepositStore
+	if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+		return
+	}
+
+	var setStores map[*coinparam.Params]cxdb.SettlementStore
+	if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+		return
+	}
+
+	server, err = InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, "")
+	return
+}
+
+// TestReplayJournal places and cancels orders on a server, then makes sure that a new server replaying
+// the journal ends up with the same balances and orders.
+func TestReplayJournal(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestReplayJournal: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestReplayJournal: %s", err)
+		return
+	}
+
+	// The journal is empty, so this just makes the server start writing to it
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestReplayJournal: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestReplayJournal: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var haveParam *coinparam.Params
+	if haveParam, err = pair.AssetHave.CoinParamFromAsset(); err != nil {
+		t.Errorf("Error getting param for asset have for TestReplayJournal: %s", err)
+		return
+	}
+
+	var buyerPriv *koblitz.PrivateKey
+	if buyerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating buyer key for TestReplayJournal: %s", err)
+		return
+	}
+	buyerPub := buyerPriv.PubKey()
+
+	var sellerPriv *koblitz.PrivateKey
+	if sellerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating seller key for TestReplayJournal: %s", err)
+		return
+	}
+	sellerPub := sellerPriv.PubKey()
+
+	// Settlements from matching can credit either asset, so both users get both
+	for _, pub := range []*koblitz.PublicKey{buyerPub, sellerPub} {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestReplayJournal: %s", err)
+				return
+			}
+		}
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var buyID *match.OrderID
+	if buyID, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestReplayJournal: %s", err)
+		return
+	}
+
+	// This only fills half of the buy order
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+	copy(sellOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestReplayJournal: %s", err)
+		return
+	}
+
+	cancelOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  10,
+		AmountWant:  20,
+	}
+	copy(cancelOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var cancelID *match.OrderID
+	if cancelID, err = server.PlaceOrder(cancelOrder); err != nil {
+		t.Errorf("Error placing order to cancel for TestReplayJournal: %s", err)
+		return
+	}
+
+	if err = server.CancelOrder(&match.LimitOrderIDPair{OrderID: cancelID, Order: cancelOrder}); err != nil {
+		t.Errorf("Error cancelling order for TestReplayJournal: %s", err)
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestReplayJournal: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestReplayJournal: %s", err)
+		return
+	}
+
+	for _, pub := range []*koblitz.PublicKey{buyerPub, sellerPub} {
+		for _, coin := range coinList {
+			var expectedBal uint64
+			if expectedBal, err = server.GetBalance(pub, coin); err != nil {
+				t.Errorf("Error getting balance for TestReplayJournal: %s", err)
+				return
+			}
+
+			var replayedBal uint64
+			if replayedBal, err = replayServer.GetBalance(pub, coin); err != nil {
+				t.Errorf("Error getting replayed balance for TestReplayJournal: %s", err)
+				return
+			}
+
+			if expectedBal != replayedBal {
+				t.Errorf("Replayed %s balance should have been %d but was %d", coin.Name, expectedBal, replayedBal)
+				return
+			}
+		}
+	}
+
+	var replayedBuy *match.LimitOrderIDPair
+	if replayedBuy, err = replayServer.GetOrder(buyID); err != nil {
+		t.Errorf("Error getting replayed buy order for TestReplayJournal: %s", err)
+		return
+	}
+
+	if replayedBuy.Order.AmountHave != 50 || replayedBuy.Order.AmountWant != 50 {
+		t.Errorf("Replayed buy order should have been 50/50 but was %d/%d", replayedBuy.Order.AmountWant, replayedBuy.Order.AmountHave)
+		return
+	}
+
+	var balBeforeCancel uint64
+	if balBeforeCancel, err = replayServer.GetBalance(buyerPub, haveParam); err != nil {
+		t.Errorf("Error getting buyer balance before cancel for TestReplayJournal: %s", err)
+		return
+	}
+
+	// The matching engine should have the order too, so we can cancel it
+	if err = replayServer.CancelOrder(replayedBuy); err != nil {
+		t.Errorf("Error cancelling replayed buy order for TestReplayJournal: %s", err)
+		return
+	}
+
+	var balAfterCancel uint64
+	if balAfterCancel, err = replayServer.GetBalance(buyerPub, haveParam); err != nil {
+		t.Errorf("Error getting buyer balance after cancel for TestReplayJournal: %s", err)
+		return
+	}
+
+	// The buyer gets back what was left of the order
+	if balAfterCancel != balBeforeCancel+50 {
+		t.Errorf("Buyer balance after cancel should have been %d but was %d", balBeforeCancel+50, balAfterCancel)
+		return
+	}
+
+	return
+}
+
+// failingJournal is a journal that can be made to fail to write entries, or only match entries or entries
+// for triggered stop orders
+type failingJournal struct {
+	cxdb.Journal
+	fail          bool
+	failMatch     bool
+	failTriggered bool
+}
+
+// AppendEntry fails if the journal is set to fail, and otherwise appends the entry
+func (fj *failingJournal) AppendEntry(entry *cxdb.JournalEntry) (err error) {
+	if fj.fail || (fj.failMatch && entry.Type == cxdb.JournalMatch) || (fj.failTriggered && entry.TriggeredStopOrder != nil) {
+		err = fmt.Errorf("Journal set to fail")
+		return
+	}
+	return fj.Journal.AppendEntry(entry)
+}
+
+// TestJournalWriteFailure makes a journal fail to write while placing and cancelling orders, making sure
+// the matching engine is rolled back so the orders that were on the book trade like nothing happened.
+func TestJournalWriteFailure(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	journal := new(failingJournal)
+	if journal.Journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestJournalWriteFailure: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var buyerPriv *koblitz.PrivateKey
+	if buyerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating buyer key for TestJournalWriteFailure: %s", err)
+		return
+	}
+	buyerPub := buyerPriv.PubKey()
+
+	var sellerPriv *koblitz.PrivateKey
+	if sellerPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating seller key for TestJournalWriteFailure: %s", err)
+		return
+	}
+	sellerPub := sellerPriv.PubKey()
+
+	for _, pub := range []*koblitz.PublicKey{buyerPub, sellerPub} {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestJournalWriteFailure: %s", err)
+				return
+			}
+		}
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var buyID *match.OrderID
+	if buyID, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+	copy(sellOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	// The sell order would fill half of the buy order, but it's never journaled
+	journal.fail = true
+	if _, err = server.PlaceOrder(sellOrder); err == nil {
+		t.Errorf("Order was placed without being journaled for TestJournalWriteFailure")
+		return
+	}
+
+	if err = server.CancelOrder(&match.LimitOrderIDPair{OrderID: buyID, Order: buyOrder}); err == nil {
+		t.Errorf("Order was cancelled without being journaled for TestJournalWriteFailure")
+		return
+	}
+	journal.fail = false
+
+	var sellerOrders []*match.LimitOrderIDPair
+	if sellerOrders, err = server.GetOrdersForPubkey(sellerPub); err != nil {
+		t.Errorf("Error getting seller orders for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	if len(sellerOrders) != 0 {
+		t.Errorf("Seller should have no orders after failed placement, but had %d", len(sellerOrders))
+		return
+	}
+
+	// If the failed placement was left in the matching engine, this would only fill half of what's left
+	// of the buy order instead of all of it
+	fillOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(fillOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(fillOrder); err != nil {
+		t.Errorf("Error placing fill order for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	if _, err = server.GetOrder(buyID); err == nil {
+		t.Errorf("Buy order should have been filled for TestJournalWriteFailure")
+		return
+	}
+
+	if sellerOrders, err = server.GetOrdersForPubkey(sellerPub); err != nil {
+		t.Errorf("Error getting seller orders for TestJournalWriteFailure: %s", err)
+		return
+	}
+
+	if len(sellerOrders) != 0 {
+		t.Errorf("Fill order should have been filled, but seller had %d orders", len(sellerOrders))
+		return
+	}
+
+	return
+}
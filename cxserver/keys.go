@@ -5,11 +5,18 @@ import (
 
 	"github.com/mit-dci/lit/btcutil/hdkeychain"
 	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
 )
 
 // SetupServerKeys just loads a private key from a file wallet
 func (server *OpencxServer) SetupServerKeys(privkey *[32]byte) (err error) {
 
+	// the exchange is administered by whoever has this key
+	_, pubkey := koblitz.PrivKeyFromBytes(koblitz.S256(), privkey[:])
+	server.privKeyMtx.Lock()
+	server.exchangePubkey = pubkey
+	server.privKeyMtx.Unlock()
+
 	// for all settlement engines that we have, make keys
 	for param, _ := range server.SettlementEngines {
 		if err = server.SetupSingleKey(privkey, param); err != nil {
@@ -33,3 +40,17 @@ func (server *OpencxServer) SetupSingleKey(privkey *[32]byte, param *coinparam.P
 
 	return
 }
+
+// GetExchangePubkey returns the pubkey for the exchange's private key, which is the only key that can
+// administer the exchange
+func (server *OpencxServer) GetExchangePubkey() (pubkey *koblitz.PublicKey, err error) {
+	server.privKeyMtx.Lock()
+	if server.exchangePubkey == nil {
+		err = fmt.Errorf("The exchange's keys have not been set up")
+		server.privKeyMtx.Unlock()
+		return
+	}
+	pubkey = server.exchangePubkey
+	server.privKeyMtx.Unlock()
+	return
+}
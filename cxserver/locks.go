@@ -0,0 +1,244 @@
+package cxserver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// The server has a lock for each pair, which protects the matching engine, orderbook, stop order store,
+// trade store, candle store, and last trade price for that pair, and a lock for each asset, which protects the settlement engine,
+// settlement store, and deposit store for that asset. So nothing can deadlock, locks are always acquired
+// in the same order: pair locks before asset locks, pair locks sorted by pair string, and asset locks
+// sorted by coin name. Nothing ever holds more than one pair lock except lockAll.
+// Holding a pair lock also holds a read lock on the maps of everything for each pair, since adding a pair
+// changes them. A pair can only be added while no pair lock is held, and nothing that holds a pair lock
+// can try to read lock the maps again, or it could deadlock with a pair being added.
+
+// createPairLocks creates a lock for every pair that has a matching engine, orderbook, or stop order store
+func createPairLocks(matchEngines map[match.Pair]match.LimitEngine, books map[match.Pair]match.LimitOrderbook, stopStores map[match.Pair]cxdb.StopOrderStore) (pairLocks map[match.Pair]*sync.Mutex) {
+	pairLocks = make(map[match.Pair]*sync.Mutex)
+	for pair := range matchEngines {
+		pairLocks[pair] = new(sync.Mutex)
+	}
+	for pair := range books {
+		pairLocks[pair] = new(sync.Mutex)
+	}
+	for pair := range stopStores {
+		pairLocks[pair] = new(sync.Mutex)
+	}
+	return
+}
+
+// createLastPrices creates an empty last trade price for every pair that has a matching engine, orderbook,
+// or stop order store. The map is never changed after this, only the prices it points to, so it can be read
+// while holding just one pair lock.
+func createLastPrices(matchEngines map[match.Pair]match.LimitEngine, books map[match.Pair]match.LimitOrderbook, stopStores map[match.Pair]cxdb.StopOrderStore) (lastPrices map[match.Pair]*match.Price) {
+	lastPrices = make(map[match.Pair]*match.Price)
+	for pair := range createPairLocks(matchEngines, books, stopStores) {
+		lastPrices[pair] = new(match.Price)
+	}
+	return
+}
+
+// createPairStatuses creates a status for every pair that has a matching engine, orderbook, or stop order
+// store, which starts out as trading. Like the last prices, only the statuses the map points to change.
+func createPairStatuses(matchEngines map[match.Pair]match.LimitEngine, books map[match.Pair]match.LimitOrderbook, stopStores map[match.Pair]cxdb.StopOrderStore) (statuses map[match.Pair]*match.PairStatus) {
+	statuses = make(map[match.Pair]*match.PairStatus)
+	for pair := range createPairLocks(matchEngines, books, stopStores) {
+		statuses[pair] = new(match.PairStatus)
+	}
+	return
+}
+
+// createAssetLocks creates a lock for every coin that has a settlement engine, settlement store, or deposit store
+func createAssetLocks(setEngines map[*coinparam.Params]match.SettlementEngine, settleStores map[*coinparam.Params]cxdb.SettlementStore, depositStores map[*coinparam.Params]cxdb.DepositStore) (assetLocks map[*coinparam.Params]*sync.Mutex) {
+	assetLocks = make(map[*coinparam.Params]*sync.Mutex)
+	for coin := range setEngines {
+		assetLocks[coin] = new(sync.Mutex)
+	}
+	for coin := range settleStores {
+		assetLocks[coin] = new(sync.Mutex)
+	}
+	for coin := range depositStores {
+		assetLocks[coin] = new(sync.Mutex)
+	}
+	return
+}
+
+// lockBook acquires the lock for the matching engine and orderbook of a pair, without any asset locks.
+// This is for things that only read or change the orderbook.
+func (server *OpencxServer) lockBook(pair match.Pair) (err error) {
+	server.pairsMtx.RLock()
+	var pairLock *sync.Mutex
+	var ok bool
+	if pairLock, ok = server.pairLocks[pair]; !ok {
+		err = fmt.Errorf("Could not find lock for trading pair %s", pair.String())
+		server.pairsMtx.RUnlock()
+		return
+	}
+
+	pairLock.Lock()
+	return
+}
+
+// unlockBook releases a lock acquired with lockBook
+func (server *OpencxServer) unlockBook(pair match.Pair) {
+	server.pairLocks[pair].Unlock()
+	server.pairsMtx.RUnlock()
+	return
+}
+
+// lockPair acquires the lock for the matching engine and orderbook of a pair, and then the locks for
+// both of the pair's assets, since placing, matching, and cancelling orders settles in both assets.
+func (server *OpencxServer) lockPair(pair match.Pair) (err error) {
+	var coins []*coinparam.Params
+	if coins, err = pairCoins(pair); err != nil {
+		err = fmt.Errorf("Error getting coins for pair for lockPair: %s", err)
+		return
+	}
+
+	if err = server.lockBook(pair); err != nil {
+		return
+	}
+
+	if err = server.lockAssets(coins...); err != nil {
+		server.unlockBook(pair)
+		return
+	}
+	return
+}
+
+// unlockPair releases the locks acquired with lockPair
+func (server *OpencxServer) unlockPair(pair match.Pair) {
+	// This can't fail, since lockPair succeeded
+	coins, _ := pairCoins(pair)
+	server.unlockAssets(coins...)
+	server.unlockBook(pair)
+	return
+}
+
+// lockAsset acquires the lock for the settlement engine, settlement store, and deposit store of a coin
+func (server *OpencxServer) lockAsset(coin *coinparam.Params) (err error) {
+	err = server.lockAssets(coin)
+	return
+}
+
+// unlockAsset releases a lock acquired with lockAsset
+func (server *OpencxServer) unlockAsset(coin *coinparam.Params) {
+	server.unlockAssets(coin)
+	return
+}
+
+// lockAssets acquires the locks for all of the coins, in order. Either all of the locks are acquired or
+// none of them are.
+func (server *OpencxServer) lockAssets(coins ...*coinparam.Params) (err error) {
+	coins = sortCoins(coins)
+	for _, coin := range coins {
+		if _, ok := server.assetLocks[coin]; !ok {
+			err = fmt.Errorf("Could not find lock for coin %s", coin.Name)
+			return
+		}
+	}
+
+	for _, coin := range coins {
+		server.assetLocks[coin].Lock()
+	}
+	return
+}
+
+// unlockAssets releases locks acquired with lockAssets
+func (server *OpencxServer) unlockAssets(coins ...*coinparam.Params) {
+	for _, coin := range sortCoins(coins) {
+		server.assetLocks[coin].Unlock()
+	}
+	return
+}
+
+// lockAll acquires every pair lock and then every asset lock, for things like replaying the journal that
+// change everything at once.
+func (server *OpencxServer) lockAll() {
+	server.pairsMtx.RLock()
+	var pairs []match.Pair
+	for pair := range server.pairLocks {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+
+	for _, pair := range pairs {
+		server.pairLocks[pair].Lock()
+	}
+
+	var coins []*coinparam.Params
+	for coin := range server.assetLocks {
+		coins = append(coins, coin)
+	}
+
+	for _, coin := range sortCoins(coins) {
+		server.assetLocks[coin].Lock()
+	}
+	return
+}
+
+// unlockAll releases the locks acquired with lockAll
+func (server *OpencxServer) unlockAll() {
+	for _, assetLock := range server.assetLocks {
+		assetLock.Unlock()
+	}
+
+	for _, pairLock := range server.pairLocks {
+		pairLock.Unlock()
+	}
+	server.pairsMtx.RUnlock()
+	return
+}
+
+// pairList returns every pair the server has a lock for, sorted by pair string. This is for going through
+// every pair one at a time, locking each one while it's used, since the maps for each pair can't be
+// iterated over without holding a read lock on them.
+func (server *OpencxServer) pairList() (pairs []match.Pair) {
+	server.pairsMtx.RLock()
+	for pair := range server.pairLocks {
+		pairs = append(pairs, pair)
+	}
+	server.pairsMtx.RUnlock()
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+	return
+}
+
+// pairCoins returns the coins for both assets in a pair
+func pairCoins(pair match.Pair) (coins []*coinparam.Params, err error) {
+	var wantCoin *coinparam.Params
+	if wantCoin, err = pair.AssetWant.CoinParamFromAsset(); err != nil {
+		err = fmt.Errorf("Error getting coin param for asset want: %s", err)
+		return
+	}
+
+	var haveCoin *coinparam.Params
+	if haveCoin, err = pair.AssetHave.CoinParamFromAsset(); err != nil {
+		err = fmt.Errorf("Error getting coin param for asset have: %s", err)
+		return
+	}
+
+	coins = []*coinparam.Params{wantCoin, haveCoin}
+	return
+}
+
+// sortCoins returns the coins sorted by name without duplicates, which is the order asset locks are
+// acquired in
+func sortCoins(coins []*coinparam.Params) (sorted []*coinparam.Params) {
+	seen := make(map[*coinparam.Params]bool)
+	for _, coin := range coins {
+		if !seen[coin] {
+			seen[coin] = true
+			sorted = append(sorted, coin)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return
+}
@@ -0,0 +1,86 @@
+package cxserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetNonceStore sets the store that the nonces used in order envelopes are kept in. The nonces are kept in
+// memory by default, which only keeps orders from being replayed after a restart if the journal is replayed.
+func (server *OpencxServer) SetNonceStore(store cxdb.NonceStore) (err error) {
+	if store == nil {
+		err = fmt.Errorf("Cannot set nil nonce store, please enter valid input")
+		return
+	}
+
+	server.nonceMtx.Lock()
+	server.nonceStore = store
+	server.nonceMtx.Unlock()
+	return
+}
+
+// UseEnvelopeNonce records that a pubkey used the nonce in an envelope, returning an error if it already
+// has. This should be done before the order in the envelope is placed, so each envelope can only place
+// one order. The nonce stays used even if the order can't be placed.
+func (server *OpencxServer) UseEnvelopeNonce(pubkey [33]byte, envelope *match.OrderEnvelope) (err error) {
+	if envelope == nil {
+		err = fmt.Errorf("Cannot use nonce of nil envelope, please enter valid input")
+		return
+	}
+
+	server.nonceMtx.Lock()
+
+	var used bool
+	if used, err = server.nonceStore.NonceUsed(pubkey, envelope.Nonce); err != nil {
+		err = fmt.Errorf("Error checking nonce for UseEnvelopeNonce: %s", err)
+		server.nonceMtx.Unlock()
+		return
+	}
+
+	if used {
+		err = fmt.Errorf("Nonce %d has already been used by pubkey %x, an order can only be placed once", envelope.Nonce, pubkey)
+		server.nonceMtx.Unlock()
+		return
+	}
+
+	nonceEntry := &cxdb.JournalEntry{
+		Type: cxdb.JournalNonce,
+		Pair: envelope.Pair,
+		NonceUse: &cxdb.NonceUse{
+			Pubkey: pubkey,
+			Nonce:  envelope.Nonce,
+			Expiry: envelope.Expiry,
+		},
+	}
+
+	if err = server.writeJournal(nonceEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for UseEnvelopeNonce: %s", err)
+		server.nonceMtx.Unlock()
+		return
+	}
+
+	if err = server.applyJournalEntry(nonceEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for UseEnvelopeNonce: %s", err)
+		server.nonceMtx.Unlock()
+		return
+	}
+
+	server.nonceMtx.Unlock()
+	return
+}
+
+// PruneNonces forgets the nonces of envelopes that have expired at the time now. They can't be replayed
+// since expired envelopes aren't accepted.
+func (server *OpencxServer) PruneNonces(now time.Time) (err error) {
+	server.nonceMtx.Lock()
+	if err = server.nonceStore.PruneNonces(uint64(now.Unix())); err != nil {
+		err = fmt.Errorf("Error pruning nonces for PruneNonces: %s", err)
+		server.nonceMtx.Unlock()
+		return
+	}
+	server.nonceMtx.Unlock()
+	return
+}
@@ -0,0 +1,102 @@
+package cxserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestUseEnvelopeNonce uses a nonce, makes sure it can't be used again even after the journal is replayed
+// into a new server, and that it can be used once it's pruned.
+func TestUseEnvelopeNonce(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	now := time.Now()
+	pubkey := [33]byte{0x02, 0x01}
+	envelope := &match.OrderEnvelope{
+		Kind:   match.LimitOrderKind,
+		Pair:   *pairList[0],
+		Nonce:  7,
+		Expiry: uint64(now.Add(time.Hour).Unix()),
+	}
+
+	if err = server.UseEnvelopeNonce(pubkey, envelope); err != nil {
+		t.Errorf("Error using nonce for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	if err = server.UseEnvelopeNonce(pubkey, envelope); err == nil {
+		t.Errorf("Nonce was used twice for TestUseEnvelopeNonce")
+		return
+	}
+
+	// Another pubkey has its own nonces
+	otherPubkey := [33]byte{0x03, 0x01}
+	if err = server.UseEnvelopeNonce(otherPubkey, envelope); err != nil {
+		t.Errorf("Error using nonce for other pubkey for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	if err = replayServer.UseEnvelopeNonce(pubkey, envelope); err == nil {
+		t.Errorf("Nonce was used again after the journal was replayed for TestUseEnvelopeNonce")
+		return
+	}
+
+	// Once the envelope has expired, its nonce is forgotten
+	if err = replayServer.PruneNonces(now.Add(2 * time.Hour)); err != nil {
+		t.Errorf("Error pruning nonces for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	var used bool
+	if used, err = replayServer.nonceStore.NonceUsed(pubkey, envelope.Nonce); err != nil {
+		t.Errorf("Error checking nonce for TestUseEnvelopeNonce: %s", err)
+		return
+	}
+
+	if used {
+		t.Errorf("Nonce was not pruned after its envelope expired for TestUseEnvelopeNonce")
+		return
+	}
+	return
+}
@@ -2,10 +2,12 @@ package cxserver
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/mit-dci/lit/coinparam"
 	"github.com/mit-dci/lit/crypto/koblitz"
 	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 )
 
@@ -13,20 +15,31 @@ import (
 func (server *OpencxServer) GetOrder(orderID *match.OrderID) (order *match.LimitOrderIDPair, err error) {
 
 	// We just go through everything, checking the limit orderbook, seeing if we get a match
-	server.dbLock.Lock()
-	for _, limBook := range server.Orderbooks {
+	// Only one orderbook is locked at a time
+	for _, pair := range server.pairList() {
+		if err = server.lockBook(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for GetOrder: %s", err)
+			return
+		}
+
+		limBook, ok := server.Orderbooks[pair]
+		if !ok {
+			server.unlockBook(pair)
+			continue
+		}
+
 		if order, err = limBook.GetOrder(orderID); err != nil && order == nil {
 			err = fmt.Errorf("Error getting order from a limit orderbook: %s", err)
-			server.dbLock.Unlock()
+			server.unlockBook(pair)
 			return
 		} else if err == nil && order != nil {
-			server.dbLock.Unlock()
+			server.unlockBook(pair)
 			return
 		}
+		server.unlockBook(pair)
 	}
 
 	err = fmt.Errorf("Could not find order with that order ID")
-	server.dbLock.Unlock()
 	return
 }
 
@@ -52,52 +65,74 @@ func (server *OpencxServer) PlaceOrder(order *match.LimitOrder) (orderID *match.
 		return
 	}
 
-	// make sure that putting it in the db will be an accurate and good idea because calculating prices is frustrating
-	var pr float64
-	if pr, err = order.Price(); err != nil {
+	// make sure the order has a price. Prices are exact fractions, so there is no need to limit how high
+	// or low they can be.
+	if _, err = order.Price(); err != nil {
 		err = fmt.Errorf("Error calculating price while Placing: %s", err)
 		return
 	}
 
-	// TODO: this is to protect the database, this is why switching to a better price system would be a good idea
-	if pr > float64(10000000000000000000000) {
-		err = fmt.Errorf("Price too high, complain online if you want the maximum price increased, or lower your price")
+	if order.TimeInForce > match.FillOrKill {
+		err = fmt.Errorf("Unknown time in force %d for PlaceOrder", order.TimeInForce)
 		return
 	}
-	if pr < float64(1)/float64(1000000) {
-		err = fmt.Errorf("Price too low, complain online if you want the minimum price decreased, or increase your price")
+
+	if order.SelfTrade > match.DecrementAndCancel {
+		err = fmt.Errorf("Unknown self-trade prevention mode %d for PlaceOrder", order.SelfTrade)
 		return
 	}
 
-	server.dbLock.Lock()
+	if order.Expired(time.Now()) {
+		err = fmt.Errorf("Order expired at %s, not placing", time.Unix(int64(order.Expiry), 0).String())
+		return
+	}
+
+	if err = server.lockPair(order.TradingPair); err != nil {
+		err = fmt.Errorf("Error locking pair for PlaceOrder: %s", err)
+		return
+	}
+
+	status := *server.pairStatus[order.TradingPair]
+	if !status.CanPlace() {
+		err = fmt.Errorf("Cannot place orders on %s while it is %s", order.TradingPair.PrettyString(), status.String())
+		server.unlockPair(order.TradingPair)
+		return
+	}
+
+	// Orders on an auction-only pair aren't matched until it's resumed, so they have to be able to rest
+	if status == match.PairAuctionOnly && order.TimeInForce.IsImmediate() {
+		err = fmt.Errorf("Cannot place %s orders on %s while it is %s", order.TimeInForce.String(), order.TradingPair.PrettyString(), status.String())
+		server.unlockPair(order.TradingPair)
+		return
+	}
 
 	// first we need to get the settlement engine, limit engine, orderbook, and settlement store
 	var currSetEng match.SettlementEngine
 	var ok bool
 	if currSetEng, ok = server.SettlementEngines[param]; !ok {
 		err = fmt.Errorf("Could not find correct settlement engine for PlaceOrder")
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
 	var currMatchEng match.LimitEngine
 	if currMatchEng, ok = server.MatchingEngines[order.TradingPair]; !ok {
 		err = fmt.Errorf("Could not find matching engine for trading pair for PlaceOrder")
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
-	var currOrderbook match.LimitOrderbook
-	if currOrderbook, ok = server.Orderbooks[order.TradingPair]; !ok {
+	// The orderbook and settlement store are used when the journal entry is applied, but we make sure
+	// they exist before anything is placed in the matching engine.
+	if _, ok = server.Orderbooks[order.TradingPair]; !ok {
 		err = fmt.Errorf("Could not find orderbooks for trading pair for PlaceOrder")
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
-	var currSetStore cxdb.SettlementStore
-	if currSetStore, ok = server.SettlementStores[param]; !ok {
+	if _, ok = server.SettlementStores[param]; !ok {
 		err = fmt.Errorf("Could not find settlement store for asset for PlaceOrder")
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
@@ -107,149 +142,315 @@ func (server *OpencxServer) PlaceOrder(order *match.LimitOrder) (orderID *match.
 		Asset:  assetToCredit,
 		Amount: order.AmountHave,
 	}
-	// Let's hope that since they're both [33]byte their value can just be copied over through assignment
-	// copy(orderCreditExec.Pubkey[:], order.Pubkey[:])
 
 	// Okay now that we have these, check the validity
 	var valid bool
 	if valid, err = currSetEng.CheckValid(orderCreditExec); err != nil {
 		err = fmt.Errorf("Error checking valid settlement exec: %s", err)
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
 	if !valid {
 		err = fmt.Errorf("Error placing order, not enough balance or you are not allowed to place orders")
-		server.dbLock.Unlock()
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
-	// Now we do these two operations. !!! IMPORTANT: THESE TWO CALLS NEED TO BE ATOMIC !!!
-	// TODO: ensure atomicity. Currently the matching engine is the one thing that must either be
-	// redundant or resistant to crashes / failure.
-
-	// While at this point we can replay things, if the apply settlement execution succeeds
-	// but the place limit order does not, then we'll have asymmetry.
-	// One way to fix this is develop a clever fault-tolerant way of replaying these messages
-	// if we detect a crash.
-
-	// Long story short, distributed systems are hard.
-	var settlementResults []*match.SettlementResult
-	var setRes *match.SettlementResult
-	if setRes, err = currSetEng.ApplySettlementExecution(orderCreditExec); err != nil {
-		err = fmt.Errorf("Error applying settlement execution when placing order: %s", err)
-		server.dbLock.Unlock()
+	var idRes *match.LimitOrderIDPair
+	if idRes, err = server.placeAndMatch(currMatchEng, order, orderCreditExec, nil); err != nil {
+		err = fmt.Errorf("Error placing order for PlaceOrder: %s", err)
+		server.unlockPair(order.TradingPair)
 		return
 	}
 
-	settlementResults = append(settlementResults, setRes)
+	// Anything that traded could have triggered stop orders. The order is already journaled, so it's placed
+	// even if this fails, and the stop orders are triggered the next time they're checked.
+	if err = server.triggerStopOrders(order.TradingPair); err != nil {
+		logging.Errorf("Error triggering stop orders after placing order %x: %s", idRes.OrderID[:], err)
+		err = nil
+	}
 
-	var idRes *match.LimitOrderIDPair
+	server.unlockPair(order.TradingPair)
+
+	// Now we return thing
+	orderID = idRes.OrderID
+	return
+}
+
+// placeAndMatch places an order in the matching engine, matches it, and then journals and applies the
+// placement. The reserve is the settlement execution that takes the funds for the order from the user, which
+// is nil if they were already taken when a stop order was placed, and triggeredStop is the stop order the
+// order came from, if there is one.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) placeAndMatch(currMatchEng match.LimitEngine, order *match.LimitOrder, reserve *match.SettlementExecution, triggeredStop *match.OrderID) (idRes *match.LimitOrderIDPair, err error) {
+	// The matching engine goes first, since it gives us the order ID and executions that go in the
+	// journal. If we crash before the journal entry is written, the order was never placed as far as the
+	// user, the settlement engines, and the orderbook are concerned.
 	if idRes, err = currMatchEng.PlaceLimitOrder(order); err != nil {
-		err = fmt.Errorf("Error placing limit order for limit matching engine for PlaceOrder: %s", err)
-		server.dbLock.Unlock()
+		err = fmt.Errorf("Error placing limit order for limit matching engine: %s", err)
 		return
 	}
 
-	// This may not need to be atomic because we can rebuild the previous state using the messages
-	// we have, we can worry less now about things crashing but should still worry
+	if err = server.matchAndJournal(cxdb.JournalPlace, currMatchEng, idRes, reserve, triggeredStop); err != nil {
+		err = fmt.Errorf("Error matching placed order: %s", err)
+		return
+	}
+	return
+}
 
+// matchAndJournal matches the orders in the matching engine after an order was placed or replaced in it, and
+// then journals and applies the placement or replacement along with what came from matching. The reserve is
+// the settlement execution that changes how much of the user's balance is held for the order, if that
+// changed. Nothing is matched if the pair is auction-only. Writing the journal entry is what commits the
+// change, so if anything fails before then the matching engine is rolled back.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) matchAndJournal(entryType cxdb.JournalEntryType, currMatchEng match.LimitEngine, idRes *match.LimitOrderIDPair, reserve *match.SettlementExecution, triggeredStop *match.OrderID) (err error) {
 	var orderExecs []*match.OrderExecution
 	var settlementExecs []*match.SettlementExecution
-	if orderExecs, settlementExecs, err = currMatchEng.MatchLimitOrders(); err != nil {
-		err = fmt.Errorf("Error matching orders for limit matching engine for PlaceOrder: %s", err)
-		server.dbLock.Unlock()
+	var cancelledOrders []*match.CancelledOrder
+	var trades []*match.Trade
+
+	// The matching engine was already changed for the placed or replaced order, and matching changes it
+	// more. Until the entry is in the journal none of that happened, so the engine is rolled back if
+	// anything fails before then.
+	var committed bool
+	defer func() {
+		if err == nil || committed {
+			return
+		}
+		affected := []*match.OrderID{idRes.OrderID}
+		for _, orderExec := range orderExecs {
+			affected = append(affected, &orderExec.OrderID)
+		}
+		for _, cancelledOrder := range cancelledOrders {
+			affected = append(affected, cancelledOrder.OrderID)
+		}
+		if rollbackErr := server.rollbackEngine(currMatchEng, idRes.Order.TradingPair, affected); rollbackErr != nil {
+			err = fmt.Errorf("%s, and error rolling back matching engine: %s", err, rollbackErr)
+			return
+		}
+	}()
+
+	if *server.pairStatus[idRes.Order.TradingPair] != match.PairAuctionOnly {
+		if orderExecs, settlementExecs, cancelledOrders, trades, err = currMatchEng.MatchLimitOrders(); err != nil {
+			err = fmt.Errorf("Error matching orders for limit matching engine: %s", err)
+			return
+		}
+	}
+	stampTrades(trades, time.Now())
+
+	var lastPrice *match.Price
+	if lastPrice, err = server.lastTradePrice(idRes.Order.TradingPair, idRes, orderExecs); err != nil {
+		err = fmt.Errorf("Error getting last trade price: %s", err)
+		return
+	}
+
+	var feeExecs []*match.SettlementExecution
+	if schedule, ok := server.FeeSchedules[idRes.Order.TradingPair]; ok {
+		if feeExecs, err = schedule.LimitFeeExecutions(tradeSettlements(orderExecs, settlementExecs, cancelledOrders), idRes.Order.Pubkey, server.FeeAccount); err != nil {
+			err = fmt.Errorf("Error getting fee executions: %s", err)
+			return
+		}
+	}
+
+	// The change to what's reserved for the order has to be applied before any of the settlements from
+	// matching
+	if reserve != nil {
+		settlementExecs = append([]*match.SettlementExecution{reserve}, settlementExecs...)
+	}
+
+	placeEntry := &cxdb.JournalEntry{
+		Type:               entryType,
+		Pair:               idRes.Order.TradingPair,
+		SettlementExecs:    settlementExecs,
+		FeeExecs:           feeExecs,
+		PlacedOrder:        idRes,
+		OrderExecs:         orderExecs,
+		Trades:             trades,
+		CancelledOrders:    cancelledOrders,
+		LastPrice:          lastPrice,
+		TriggeredStopOrder: triggeredStop,
+	}
+
+	// Once this is written, the placement will happen even if we crash, because the journal is replayed
+	if err = server.writeJournal(placeEntry); err != nil {
+		err = fmt.Errorf("Error writing journal: %s", err)
+		return
+	}
+	committed = true
+
+	if err = server.applyJournalEntry(placeEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry: %s", err)
+		return
+	}
+	return
+}
+
+// ReplaceOrder amends the amounts of an order that's on the book, in place of cancelling it and placing a
+// new one. The order keeps its ID, and keeps its time priority if only its size is reduced. Only the
+// difference in the amount the order holds is taken from or given back to the user.
+func (server *OpencxServer) ReplaceOrder(order *match.LimitOrderIDPair, amountHave uint64, amountWant uint64) (replaced *match.LimitOrderIDPair, err error) {
+	if order == nil || order.OrderID == nil || order.Order == nil {
+		err = fmt.Errorf("Cannot replace nil order, please enter valid input")
 		return
 	}
 
-	for _, setExec := range settlementExecs {
+	pair := order.Order.TradingPair
+	if err = server.lockPair(pair); err != nil {
+		err = fmt.Errorf("Error locking pair for ReplaceOrder: %s", err)
+		return
+	}
+
+	if status := *server.pairStatus[pair]; !status.CanPlace() {
+		err = fmt.Errorf("Cannot replace orders on %s while it is %s", pair.PrettyString(), status.String())
+		server.unlockPair(pair)
+		return
+	}
+
+	var currMatchEng match.LimitEngine
+	var ok bool
+	if currMatchEng, ok = server.MatchingEngines[pair]; !ok {
+		err = fmt.Errorf("Could not find matching engine for trading pair for ReplaceOrder")
+		server.unlockPair(pair)
+		return
+	}
+
+	var currOrderbook match.LimitOrderbook
+	if currOrderbook, ok = server.Orderbooks[pair]; !ok {
+		err = fmt.Errorf("Could not find orderbooks for trading pair for ReplaceOrder")
+		server.unlockPair(pair)
+		return
+	}
+
+	// Get the order again now that the pair is locked, it could have traded since the caller got it
+	var current *match.LimitOrderIDPair
+	if current, err = currOrderbook.GetOrder(order.OrderID); err != nil {
+		err = fmt.Errorf("Error getting order to replace for ReplaceOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	// Make sure the user can cover a bigger order before anything is changed in the matching engine
+	var reserveChange *match.SettlementExecution
+	if _, reserveChange, err = current.Replace(amountHave, amountWant, time.Now()); err != nil {
+		err = fmt.Errorf("Error replacing order for ReplaceOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
 
-		var thisCoin *coinparam.Params
-		if thisCoin, err = setExec.Asset.CoinParamFromAsset(); err != nil {
-			err = fmt.Errorf("Error getting coin param from asset to find correct engine: %s", err)
-			server.dbLock.Unlock()
+	if reserveChange != nil {
+		var param *coinparam.Params
+		if param, err = reserveChange.Asset.CoinParamFromAsset(); err != nil {
+			err = fmt.Errorf("Could not turn order asset into coin param for ReplaceOrder: %s", err)
+			server.unlockPair(pair)
 			return
 		}
 
-		var thisAssetEngine match.SettlementEngine
-		if thisAssetEngine, ok = server.SettlementEngines[thisCoin]; !ok {
-			err = fmt.Errorf("Could not find correct settlement engine for PlaceOrder")
-			server.dbLock.Unlock()
+		var currSetEng match.SettlementEngine
+		if currSetEng, ok = server.SettlementEngines[param]; !ok {
+			err = fmt.Errorf("Could not find correct settlement engine for ReplaceOrder")
+			server.unlockPair(pair)
 			return
 		}
 
-		// We reuse valid.
-		if valid, err = thisAssetEngine.CheckValid(setExec); err != nil {
-			err = fmt.Errorf("Error checking valid settlement exec after match for PlaceOrder: %s", err)
-			server.dbLock.Unlock()
+		if _, ok = server.SettlementStores[param]; !ok {
+			err = fmt.Errorf("Could not find settlement store for asset for ReplaceOrder")
+			server.unlockPair(pair)
 			return
 		}
 
-		if !valid {
-			err = fmt.Errorf("Error with matching engine output settlement validity, exec: \n%s", setExec.String())
-			server.dbLock.Unlock()
+		var valid bool
+		if valid, err = currSetEng.CheckValid(reserveChange); err != nil {
+			err = fmt.Errorf("Error checking valid settlement exec for ReplaceOrder: %s", err)
+			server.unlockPair(pair)
 			return
 		}
 
-		// We reuse setRes.
-		if setRes, err = thisAssetEngine.ApplySettlementExecution(setExec); err != nil {
-			err = fmt.Errorf("Error applying settlement execution after match for PlaceOrder: %s", err)
-			server.dbLock.Unlock()
+		if !valid {
+			err = fmt.Errorf("Error replacing order, not enough balance or you are not allowed to place orders")
+			server.unlockPair(pair)
 			return
 		}
-		settlementResults = append(settlementResults, setRes)
 	}
 
-	// Now we don't worry any more. The matching engine and settlement engine have both responded.
-	// If we needed to we could rebuild the state.
+	if replaced, reserveChange, err = currMatchEng.ReplaceLimitOrder(order.OrderID, amountHave, amountWant); err != nil {
+		err = fmt.Errorf("Error replacing limit order for limit matching engine for ReplaceOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
 
-	// update orderbook
-	if err = currOrderbook.UpdateBookPlace(idRes); err != nil {
-		err = fmt.Errorf("Error placing order on orderbook for PlaceOrder: %s", err)
-		server.dbLock.Unlock()
+	// A new price can cross the book
+	if err = server.matchAndJournal(cxdb.JournalReplace, currMatchEng, replaced, reserveChange, nil); err != nil {
+		err = fmt.Errorf("Error matching replaced order for ReplaceOrder: %s", err)
+		server.unlockPair(pair)
 		return
 	}
 
-	for _, orderExec := range orderExecs {
-		if err = currOrderbook.UpdateBookExec(orderExec); err != nil {
-			err = fmt.Errorf("Error updating orderbook execution for PlaceOrder: %s", err)
-			server.dbLock.Unlock()
-			return
-		}
+	// The replacement is already journaled, so it happened even if triggering stop orders fails
+	if err = server.triggerStopOrders(pair); err != nil {
+		logging.Errorf("Error triggering stop orders after replacing order %x: %s", replaced.OrderID[:], err)
+		err = nil
 	}
 
-	// update what the client sees
-	if err = currSetStore.UpdateBalances(settlementResults); err != nil {
-		err = fmt.Errorf("Error updating balances with settlement results for PlaceOrder: %s", err)
-		server.dbLock.Unlock()
+	server.unlockPair(pair)
+	return
+}
+
+// lastTradePrice returns the price of the last trade in the order executions from matching a placed order,
+// or nil if nothing traded. Orders are matched at the price of the order that was on the book first, so
+// this is the price of the last order that was already on the book and got executed. If nothing was placed,
+// this is the price of the last order that got executed.
+// This should be called with the pair lock held, before the order executions are applied to the orderbook.
+func (server *OpencxServer) lastTradePrice(pair match.Pair, placed *match.LimitOrderIDPair, orderExecs []*match.OrderExecution) (lastPrice *match.Price, err error) {
+	var currOrderbook match.LimitOrderbook
+	var ok bool
+	if currOrderbook, ok = server.Orderbooks[pair]; !ok {
+		err = fmt.Errorf("Could not find orderbook for trading pair %s", pair.String())
 		return
 	}
 
-	server.dbLock.Unlock()
+	for i := len(orderExecs) - 1; i >= 0; i-- {
+		// Decremented orders were changed by self-trade prevention, so they may not have traded
+		if (placed != nil && orderExecs[i].OrderID == *placed.OrderID) || orderExecs[i].Decremented {
+			continue
+		}
 
-	// Now we return thing
-	orderID = idRes.OrderID
+		var maker *match.LimitOrderIDPair
+		if maker, err = currOrderbook.GetOrder(&orderExecs[i].OrderID); err != nil {
+			err = fmt.Errorf("Error getting executed order from orderbook: %s", err)
+			return
+		}
+
+		lastPrice = new(match.Price)
+		*lastPrice = maker.Price
+		return
+	}
 	return
 }
 
-// ViewOrderbook returns a view of the orderbook for the user
-func (server *OpencxServer) ViewOrderbook(pair *match.Pair) (book map[float64][]*match.LimitOrderIDPair, err error) {
+// ViewOrderbook returns every order on the book for a pair, including the pubkey that placed it, so it
+// should only be shown to the exchange operator. GetDepth is the view of the book for users.
+func (server *OpencxServer) ViewOrderbook(pair *match.Pair) (book map[match.Price][]*match.LimitOrderIDPair, err error) {
 
-	server.dbLock.Lock()
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for ViewOrderbook: %s", err)
+		return
+	}
 	var currOrderbook match.LimitOrderbook
 	var ok bool
 	if currOrderbook, ok = server.Orderbooks[*pair]; !ok {
 		err = fmt.Errorf("Could not find orderbooks for trading pair for ViewOrderbook")
-		server.dbLock.Unlock()
+		server.unlockBook(*pair)
 		return
 	}
 
 	if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
 		err = fmt.Errorf("Error viewing limit orderbook for server for ViewOrderbook: %s", err)
-		server.dbLock.Unlock()
+		server.unlockBook(*pair)
 		return
 	}
-	server.dbLock.Unlock()
+	server.unlockBook(*pair)
 
 	return
 }
@@ -257,31 +458,87 @@ func (server *OpencxServer) ViewOrderbook(pair *match.Pair) (book map[float64][]
 // GetOrdersForPubkey returns orders for a specific pubkey and pair
 func (server *OpencxServer) GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders []*match.LimitOrderIDPair, err error) {
 
-	server.dbLock.Lock()
-	var currOrderMap map[float64][]*match.LimitOrderIDPair
-	for _, currOrderbook := range server.Orderbooks {
+	var currOrderMap map[match.Price][]*match.LimitOrderIDPair
+	for _, pair := range server.pairList() {
+		if err = server.lockBook(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for GetOrdersForPubkey: %s", err)
+			return
+		}
+
+		currOrderbook, ok := server.Orderbooks[pair]
+		if !ok {
+			server.unlockBook(pair)
+			continue
+		}
+
 		// get the orders in map form
 		// TODO: determine if the map return type of this API is really necessary
 		if currOrderMap, err = currOrderbook.GetOrdersForPubkey(pubkey); err != nil {
 			err = fmt.Errorf("Error getting book orders for pubkey for server GetOrdersForPubkey: %s", err)
-			server.dbLock.Unlock()
+			server.unlockBook(pair)
 			return
 		}
+		server.unlockBook(pair)
 
 		// now add them to the list
 		for _, returnedOrders := range currOrderMap {
 			orders = append(orders, returnedOrders...)
 		}
 	}
-	server.dbLock.Unlock()
 
 	return
 }
 
-// CancelOrder places an order by first checking if we can credit the user, then calling the appropriate
-// database calls
+// CancelOrder cancels an order that's on the book, giving back the funds that haven't been traded yet
 func (server *OpencxServer) CancelOrder(order *match.LimitOrderIDPair) (err error) {
 
+	if err = server.lockPair(order.Order.TradingPair); err != nil {
+		err = fmt.Errorf("Error locking pair for CancelOrder: %s", err)
+		return
+	}
+
+	if status := *server.pairStatus[order.Order.TradingPair]; !status.CanCancel() {
+		err = fmt.Errorf("Cannot cancel orders on %s while it is %s", order.Order.TradingPair.PrettyString(), status.String())
+		server.unlockPair(order.Order.TradingPair)
+		return
+	}
+
+	if err = server.cancelOrder(order); err != nil {
+		err = fmt.Errorf("Error cancelling order for CancelOrder: %s", err)
+		server.unlockPair(order.Order.TradingPair)
+		return
+	}
+
+	server.unlockPair(order.Order.TradingPair)
+	return
+}
+
+// tradeSettlements returns the settlement executions from matching that came from trades, leaving out the
+// refunds for cancelled and decremented orders, which come after them and aren't charged fees
+func tradeSettlements(orderExecs []*match.OrderExecution, settlementExecs []*match.SettlementExecution, cancelledOrders []*match.CancelledOrder) (trades []*match.SettlementExecution) {
+	refunds := len(cancelledOrders)
+	for _, orderExec := range orderExecs {
+		if orderExec.Decremented {
+			refunds++
+		}
+	}
+
+	trades = settlementExecs[:len(settlementExecs)-refunds]
+	return
+}
+
+// stampTrades sets the time of the trades from matching, which is when the exchange matched them
+func stampTrades(trades []*match.Trade, now time.Time) {
+	for _, trade := range trades {
+		trade.Timestamp = now
+	}
+	return
+}
+
+// cancelOrder cancels an order in the matching engine, and then journals and applies the cancel.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) cancelOrder(order *match.LimitOrderIDPair) (err error) {
+
 	var assetToDebit match.Asset
 	// If we are buy then we want to credit assethave
 	// If we are sell then we want to credit assetwant
@@ -294,104 +551,100 @@ func (server *OpencxServer) CancelOrder(order *match.LimitOrderIDPair) (err erro
 	// if we can't turn the asset into coinparams then lol rip
 	var param *coinparam.Params
 	if param, err = assetToDebit.CoinParamFromAsset(); err != nil {
-		err = fmt.Errorf("Could not turn order asset into coin param for CancelOrder: %s", err)
+		err = fmt.Errorf("Could not turn order asset into coin param: %s", err)
 		return
 	}
 
-	server.dbLock.Lock()
-
 	// first we need to get the settlement engine, limit engine, orderbook, and settlement store
-	var currSetEng match.SettlementEngine
 	var ok bool
-	if currSetEng, ok = server.SettlementEngines[param]; !ok {
-		err = fmt.Errorf("Could not find correct settlement engine for CancelOrder")
-		server.dbLock.Unlock()
+	if _, ok = server.SettlementEngines[param]; !ok {
+		err = fmt.Errorf("Could not find correct settlement engine")
 		return
 	}
 
 	var currMatchEng match.LimitEngine
 	if currMatchEng, ok = server.MatchingEngines[order.Order.TradingPair]; !ok {
-		err = fmt.Errorf("Could not find matching engine for trading pair for CancelOrder")
-		server.dbLock.Unlock()
+		err = fmt.Errorf("Could not find matching engine for trading pair")
 		return
 	}
 
-	var currOrderbook match.LimitOrderbook
-	if currOrderbook, ok = server.Orderbooks[order.Order.TradingPair]; !ok {
-		err = fmt.Errorf("Could not find orderbooks for trading pair for CancelOrder")
-		server.dbLock.Unlock()
+	// The orderbook and settlement store are used when the journal entry is applied, but we make sure
+	// they exist before anything is cancelled in the matching engine.
+	if _, ok = server.Orderbooks[order.Order.TradingPair]; !ok {
+		err = fmt.Errorf("Could not find orderbooks for trading pair")
 		return
 	}
 
-	var currSetStore cxdb.SettlementStore
-	if currSetStore, ok = server.SettlementStores[param]; !ok {
-		err = fmt.Errorf("Could not find settlement store for asset for CancelOrder")
-		server.dbLock.Unlock()
+	if _, ok = server.SettlementStores[param]; !ok {
+		err = fmt.Errorf("Could not find settlement store for asset")
 		return
 	}
 
-	// Now we do these two operations. !!! IMPORTANT: THESE TWO CALLS NEED TO BE ATOMIC !!!
-	// TODO: ensure atomicity. Currently the matching engine is the one thing that must either be
-	// redundant or resistant to crashes / failure.
-
-	// While at this point we can replay things, if the apply settlement execution succeeds
-	// but the place limit order does not, then we'll have asymmetry.
-	// One way to fix this is develop a clever fault-tolerant way of replaying these messages
-	// if we detect a crash.
-
-	// Long story short, distributed systems are hard.
 	var cancelled *match.CancelledOrder
 	var cancelSettlement *match.SettlementExecution
 	if cancelled, cancelSettlement, err = currMatchEng.CancelLimitOrder(order.OrderID); err != nil {
-		err = fmt.Errorf("Error cancelling limit order for limit matching engine for CancelOrder: %s", err)
-		server.dbLock.Unlock()
+		err = fmt.Errorf("Error cancelling limit order for limit matching engine: %s", err)
 		return
 	}
 
-	var settlementExecs []*match.SettlementExecution
-	settlementExecs = append(settlementExecs, cancelSettlement)
-	var settlementResults []*match.SettlementResult
-	var setRes *match.SettlementResult
-	var valid bool
-	for _, setExec := range settlementExecs {
-
-		if valid, err = currSetEng.CheckValid(setExec); err != nil {
-			err = fmt.Errorf("Error checking valid settlement exec after match for CancelOrder: %s", err)
-			server.dbLock.Unlock()
-			return
-		}
-
-		if !valid {
-			err = fmt.Errorf("Error with matching engine output settlement validity, exec: \n%s", setExec.String())
-			server.dbLock.Unlock()
-			return
-		}
+	cancelEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalCancel,
+		Pair:            order.Order.TradingPair,
+		SettlementExecs: []*match.SettlementExecution{cancelSettlement},
+		CancelledOrder:  cancelled,
+	}
 
-		if setRes, err = currSetEng.ApplySettlementExecution(setExec); err != nil {
-			err = fmt.Errorf("Error applying settlement execution after match for CancelOrder: %s", err)
-			server.dbLock.Unlock()
+	// Once this is written, the cancel will happen even if we crash, because the journal is replayed. If it
+	// isn't, the order goes back in the matching engine.
+	if err = server.writeJournal(cancelEntry); err != nil {
+		err = fmt.Errorf("Error writing journal: %s", err)
+		if rollbackErr := server.rollbackEngine(currMatchEng, order.Order.TradingPair, []*match.OrderID{order.OrderID}); rollbackErr != nil {
+			err = fmt.Errorf("%s, and error rolling back matching engine: %s", err, rollbackErr)
 			return
 		}
-		settlementResults = append(settlementResults, setRes)
+		return
 	}
 
-	// Now we don't worry any more. The matching engine and settlement engine have both responded.
-	// If we needed to we could rebuild the state.
-
-	// update orderbook
-	if err = currOrderbook.UpdateBookCancel(cancelled); err != nil {
-		err = fmt.Errorf("Error updating orderbook cancel for CancelOrder: %s", err)
-		server.dbLock.Unlock()
+	if err = server.applyJournalEntry(cancelEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry: %s", err)
 		return
 	}
+	return
+}
 
-	// update what the client sees
-	if err = currSetStore.UpdateBalances(settlementResults); err != nil {
-		err = fmt.Errorf("Error updating balances with settlement results for CancelOrder: %s", err)
-		server.dbLock.Unlock()
+// rollbackEngine puts the orders given back in the matching engine the way they are on the orderbook, after
+// the engine was changed for a journal entry that was never written. The orderbook is only changed when an
+// entry is applied, so it still has the orders the way they were before the engine was changed. Orders
+// that aren't on the orderbook are taken out of the engine.
+// This should be called with the pair lock held.
+func (server *OpencxServer) rollbackEngine(currMatchEng match.LimitEngine, pair match.Pair, orderIDs []*match.OrderID) (err error) {
+	var currOrderbook match.LimitOrderbook
+	var ok bool
+	if currOrderbook, ok = server.Orderbooks[pair]; !ok {
+		err = fmt.Errorf("Could not find orderbook for trading pair for rollbackEngine")
 		return
 	}
 
-	server.dbLock.Unlock()
+	rolledBack := make(map[match.OrderID]bool)
+	for _, orderID := range orderIDs {
+		if rolledBack[*orderID] {
+			continue
+		}
+		rolledBack[*orderID] = true
+
+		// The order might not be in the engine anymore, if it was filled or cancelled when matching
+		currMatchEng.CancelLimitOrder(orderID)
+
+		var bookOrder *match.LimitOrderIDPair
+		var getErr error
+		if bookOrder, getErr = currOrderbook.GetOrder(orderID); getErr != nil {
+			continue
+		}
+
+		if err = currMatchEng.RestoreLimitOrder(bookOrder); err != nil {
+			err = fmt.Errorf("Error restoring order %x for rollbackEngine: %s", orderID[:], err)
+			return
+		}
+	}
 	return
 }
@@ -0,0 +1,352 @@
+package cxserver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// PairStoreCreator creates the matching engine, orderbook, stop order store, trade store, and candle store
+// for a pair that's added while the exchange is running
+type PairStoreCreator func(pair *match.Pair) (matchEngine match.LimitEngine, book match.LimitOrderbook, stopStore cxdb.StopOrderStore, tradeStore cxdb.TradeStore, candleStore cxdb.CandleStore, err error)
+
+// AddPair adds a pair while the exchange is running, which starts out trading. Both of the pair's assets
+// have to be registered and have settlement engines, and the pair can't already exist either way around.
+// Pairs can only be added when the exchange has a journal.
+func (server *OpencxServer) AddPair(pair *match.Pair) (err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot add nil pair, please enter valid input")
+		return
+	}
+
+	// Pairs are only added with the journal, so without one the pair and everything on it would be lost
+	// when the exchange restarts
+	if server.journal == nil {
+		err = fmt.Errorf("Cannot add pairs without a journal to keep them across restarts")
+		return
+	}
+
+	var matchEngine match.LimitEngine
+	var book match.LimitOrderbook
+	var stopStore cxdb.StopOrderStore
+	var tradeStore cxdb.TradeStore
+	var candleStore cxdb.CandleStore
+	if matchEngine, book, stopStore, tradeStore, candleStore, err = server.createPairStores(*pair); err != nil {
+		err = fmt.Errorf("Error creating stores for AddPair: %s", err)
+		return
+	}
+
+	// Nothing can hold a pair lock while the maps for each pair are changed
+	server.pairsMtx.Lock()
+
+	reversed := match.Pair{AssetWant: pair.AssetHave, AssetHave: pair.AssetWant}
+	for _, existing := range []match.Pair{*pair, reversed} {
+		if _, ok := server.pairLocks[existing]; ok {
+			err = fmt.Errorf("Pair %s already exists, cannot add %s", existing.PrettyString(), pair.PrettyString())
+			server.pairsMtx.Unlock()
+			return
+		}
+	}
+
+	addEntry := &cxdb.JournalEntry{
+		Type: cxdb.JournalAddPair,
+		Pair: *pair,
+	}
+
+	// Once this is written, the pair will be created again when the journal is replayed
+	if err = server.writeJournal(addEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for AddPair: %s", err)
+		server.pairsMtx.Unlock()
+		return
+	}
+
+	server.insertPair(*pair, matchEngine, book, stopStore, tradeStore, candleStore)
+
+	if err = server.applyJournalEntry(addEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for AddPair: %s", err)
+		server.pairsMtx.Unlock()
+		return
+	}
+
+	server.pairsMtx.Unlock()
+
+	logging.Infof("Added pair %s", pair.PrettyString())
+	return
+}
+
+// createPairStores checks that a pair can be added, and creates its matching engine, orderbook, stop order
+// store, trade store, and candle store with the PairStoreCreator
+func (server *OpencxServer) createPairStores(pair match.Pair) (matchEngine match.LimitEngine, book match.LimitOrderbook, stopStore cxdb.StopOrderStore, tradeStore cxdb.TradeStore, candleStore cxdb.CandleStore, err error) {
+	if server.PairStoreCreator == nil {
+		err = fmt.Errorf("Pairs cannot be added, the server has no way to create stores for them")
+		return
+	}
+
+	if pair.AssetWant == pair.AssetHave {
+		err = fmt.Errorf("Pair cannot have the same asset on both sides")
+		return
+	}
+
+	var coins []*coinparam.Params
+	if coins, err = pairCoins(pair); err != nil {
+		err = fmt.Errorf("Error getting coins for pair %s: %s", pair.String(), err)
+		return
+	}
+
+	// The settlement engines and asset locks never change, so they don't need to be locked to read
+	for _, coin := range coins {
+		if _, ok := server.SettlementEngines[coin]; !ok {
+			err = fmt.Errorf("Coin %s is not supported by the exchange", coin.Name)
+			return
+		}
+
+		if _, ok := server.assetLocks[coin]; !ok {
+			err = fmt.Errorf("Could not find lock for coin %s", coin.Name)
+			return
+		}
+	}
+
+	if matchEngine, book, stopStore, tradeStore, candleStore, err = server.PairStoreCreator(&pair); err != nil {
+		err = fmt.Errorf("Error creating stores for pair %s: %s", pair.String(), err)
+		return
+	}
+	return
+}
+
+// insertPair adds everything for a pair to the server's maps.
+// This should be called with pairsMtx locked for writing.
+func (server *OpencxServer) insertPair(pair match.Pair, matchEngine match.LimitEngine, book match.LimitOrderbook, stopStore cxdb.StopOrderStore, tradeStore cxdb.TradeStore, candleStore cxdb.CandleStore) {
+	server.MatchingEngines[pair] = matchEngine
+	server.Orderbooks[pair] = book
+	server.StopOrderStores[pair] = stopStore
+	server.TradeStores[pair] = tradeStore
+	server.CandleStores[pair] = candleStore
+	server.pairLocks[pair] = new(sync.Mutex)
+	server.lastPrices[pair] = new(match.Price)
+	server.pairStatus[pair] = new(match.PairStatus)
+	return
+}
+
+// SetPairStatus changes what can be done on a pair. Delisting a pair cancels every order and stop order on
+// it and refunds them, and when a pair is set back to trading, the orders that crossed while it wasn't
+// matching orders are matched. Statuses can only be set when the exchange has a journal.
+func (server *OpencxServer) SetPairStatus(pair *match.Pair, status match.PairStatus) (err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot set status for nil pair, please enter valid input")
+		return
+	}
+
+	if status > match.PairDelisted {
+		err = fmt.Errorf("Unknown pair status %d for SetPairStatus", status)
+		return
+	}
+
+	if err = server.lockPair(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for SetPairStatus: %s", err)
+		return
+	}
+
+	// Pair statuses are only kept with the journal, so without one the pair would go back to trading when
+	// the exchange restarts
+	if server.journal == nil {
+		err = fmt.Errorf("Cannot set pair status without a journal to keep it across restarts")
+		server.unlockPair(*pair)
+		return
+	}
+
+	statusEntry := &cxdb.JournalEntry{
+		Type:       cxdb.JournalPairStatus,
+		Pair:       *pair,
+		PairStatus: status,
+	}
+
+	// The status goes first, so if we crash in the middle of delisting, the pair is still delisted when the
+	// journal is replayed
+	if err = server.writeJournal(statusEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for SetPairStatus: %s", err)
+		server.unlockPair(*pair)
+		return
+	}
+
+	if err = server.applyJournalEntry(statusEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for SetPairStatus: %s", err)
+		server.unlockPair(*pair)
+		return
+	}
+
+	if status == match.PairDelisted {
+		if err = server.cancelAllOrders(*pair); err != nil {
+			err = fmt.Errorf("Error cancelling orders for SetPairStatus: %s", err)
+			server.unlockPair(*pair)
+			return
+		}
+	}
+
+	if status == match.PairTrading {
+		if err = server.matchCrossedOrders(*pair); err != nil {
+			err = fmt.Errorf("Error matching crossed orders for SetPairStatus: %s", err)
+			server.unlockPair(*pair)
+			return
+		}
+
+		if err = server.triggerStopOrders(*pair); err != nil {
+			err = fmt.Errorf("Error triggering stop orders for SetPairStatus: %s", err)
+			server.unlockPair(*pair)
+			return
+		}
+	}
+
+	server.unlockPair(*pair)
+
+	logging.Infof("Set status of pair %s to %s", pair.PrettyString(), status.String())
+	return
+}
+
+// GetPairStatus returns the status of a pair
+func (server *OpencxServer) GetPairStatus(pair *match.Pair) (status match.PairStatus, err error) {
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for GetPairStatus: %s", err)
+		return
+	}
+
+	status = *server.pairStatus[*pair]
+
+	server.unlockBook(*pair)
+	return
+}
+
+// cancelAllOrders cancels and refunds every order and stop order on a pair.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) cancelAllOrders(pair match.Pair) (err error) {
+	var currOrderbook match.LimitOrderbook
+	var ok bool
+	if currOrderbook, ok = server.Orderbooks[pair]; !ok {
+		err = fmt.Errorf("Could not find orderbook for trading pair %s", pair.String())
+		return
+	}
+
+	var book map[match.Price][]*match.LimitOrderIDPair
+	if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+		err = fmt.Errorf("Error viewing orderbook: %s", err)
+		return
+	}
+
+	for _, orders := range book {
+		for _, order := range orders {
+			if err = server.cancelOrder(order); err != nil {
+				err = fmt.Errorf("Error cancelling order %x: %s", order.OrderID[:], err)
+				return
+			}
+		}
+	}
+
+	var currStopStore cxdb.StopOrderStore
+	if currStopStore, ok = server.StopOrderStores[pair]; !ok {
+		// Pairs without a stop order store can't have stop orders
+		return
+	}
+
+	var stops []*match.StopOrderIDPair
+	if stops, err = currStopStore.ViewStopOrders(); err != nil {
+		err = fmt.Errorf("Error viewing stop orders: %s", err)
+		return
+	}
+
+	for _, stop := range stops {
+		if err = server.cancelStopOrder(stop); err != nil {
+			err = fmt.Errorf("Error cancelling stop order %x: %s", stop.OrderID[:], err)
+			return
+		}
+	}
+	return
+}
+
+// matchCrossedOrders matches the orders on a pair that crossed while it wasn't matching orders, and then
+// journals and applies what came from matching. Every order was resting on the book, so they're all
+// charged the maker fee.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) matchCrossedOrders(pair match.Pair) (err error) {
+	var currMatchEng match.LimitEngine
+	var ok bool
+	if currMatchEng, ok = server.MatchingEngines[pair]; !ok {
+		err = fmt.Errorf("Could not find matching engine for trading pair %s", pair.String())
+		return
+	}
+
+	var orderExecs []*match.OrderExecution
+	var settlementExecs []*match.SettlementExecution
+	var cancelledOrders []*match.CancelledOrder
+	var trades []*match.Trade
+
+	// Matching changes the engine, but until the entry is in the journal none of that happened, so the
+	// engine is rolled back if anything fails before then
+	var committed bool
+	defer func() {
+		if err == nil || committed {
+			return
+		}
+		var affected []*match.OrderID
+		for _, orderExec := range orderExecs {
+			affected = append(affected, &orderExec.OrderID)
+		}
+		for _, cancelledOrder := range cancelledOrders {
+			affected = append(affected, cancelledOrder.OrderID)
+		}
+		if rollbackErr := server.rollbackEngine(currMatchEng, pair, affected); rollbackErr != nil {
+			err = fmt.Errorf("%s, and error rolling back matching engine: %s", err, rollbackErr)
+			return
+		}
+	}()
+
+	if orderExecs, settlementExecs, cancelledOrders, trades, err = currMatchEng.MatchLimitOrders(); err != nil {
+		err = fmt.Errorf("Error matching orders for limit matching engine: %s", err)
+		return
+	}
+	stampTrades(trades, time.Now())
+
+	if len(orderExecs) == 0 && len(cancelledOrders) == 0 {
+		return
+	}
+
+	var lastPrice *match.Price
+	if lastPrice, err = server.lastTradePrice(pair, nil, orderExecs); err != nil {
+		err = fmt.Errorf("Error getting last trade price: %s", err)
+		return
+	}
+
+	var feeExecs []*match.SettlementExecution
+	if schedule, ok := server.FeeSchedules[pair]; ok {
+		if feeExecs, err = schedule.LimitFeeExecutions(tradeSettlements(orderExecs, settlementExecs, cancelledOrders), [33]byte{}, server.FeeAccount); err != nil {
+			err = fmt.Errorf("Error getting fee executions: %s", err)
+			return
+		}
+	}
+
+	matchEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalMatch,
+		Pair:            pair,
+		SettlementExecs: settlementExecs,
+		FeeExecs:        feeExecs,
+		OrderExecs:      orderExecs,
+		Trades:          trades,
+		CancelledOrders: cancelledOrders,
+		LastPrice:       lastPrice,
+	}
+
+	if err = server.writeJournal(matchEntry); err != nil {
+		err = fmt.Errorf("Error writing journal: %s", err)
+		return
+	}
+	committed = true
+
+	if err = server.applyJournalEntry(matchEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,445 @@
+package cxserver
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// createMemoryPairStores creates the stores for a pair in memory, for servers that pairs are added to
+func createMemoryPairStores(pair *match.Pair) (matchEngine match.LimitEngine, book match.LimitOrderbook, stopStore cxdb.StopOrderStore, tradeStore cxdb.TradeStore, candleStore cxdb.CandleStore, err error) {
+	if matchEngine, err = cxdbmemory.CreateLimitEngine(pair); err != nil {
+		return
+	}
+
+	if book, err = cxdbmemory.CreateLimitOrderbook(pair); err != nil {
+		return
+	}
+
+	if stopStore, err = cxdbmemory.CreateStopOrderStore(pair); err != nil {
+		return
+	}
+
+	if tradeStore, err = cxdbmemory.CreateTradeStore(pair); err != nil {
+		return
+	}
+
+	candleStore, err = cxdbmemory.CreateCandleStore(pair)
+	return
+}
+
+// TestPairAdministration adds a pair to a running server, moves it through every status, and then makes
+// sure a server replaying the journal has the pair with the same status and balances.
+func TestPairAdministration(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams, &coinparam.TestNet3Params}
+
+	// Only the first two coins are traded against each other when the server starts
+	var startPairs []*match.Pair
+	if startPairs, err = match.GenerateAssetPairs(coinList[:2]); err != nil {
+		t.Errorf("Error generating pairs for TestPairAdministration: %s", err)
+		return
+	}
+
+	original := match.GetAssetRegistry()
+	defer match.SetAssetRegistry(original)
+
+	var registry *match.AssetRegistry
+	if registry, err = match.CreateAssetRegistry(original.Assets(), startPairs); err != nil {
+		t.Errorf("Error creating asset registry for TestPairAdministration: %s", err)
+		return
+	}
+
+	if err = match.SetAssetRegistry(registry); err != nil {
+		t.Errorf("Error setting asset registry for TestPairAdministration: %s", err)
+		return
+	}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestPairAdministration: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestPairAdministration: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestPairAdministration: %s", err)
+		return
+	}
+
+	pair := &match.Pair{AssetWant: match.BTC, AssetHave: match.BTCTest}
+
+	// A server without a journal would forget added pairs and statuses when it restarts
+	var unjournaled *OpencxServer
+	if unjournaled, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server without journal for TestPairAdministration: %s", err)
+		return
+	}
+	unjournaled.PairStoreCreator = createMemoryPairStores
+
+	if err = unjournaled.AddPair(pair); err == nil {
+		t.Errorf("Pair was added without a journal for TestPairAdministration")
+		return
+	}
+
+	if err = unjournaled.SetPairStatus(startPairs[0], match.PairHalted); err == nil {
+		t.Errorf("Pair status was set without a journal for TestPairAdministration")
+		return
+	}
+
+	if err = server.AddPair(pair); err == nil {
+		t.Errorf("Pair should not have been added without a way to create its stores")
+		return
+	}
+
+	server.PairStoreCreator = createMemoryPairStores
+	if err = server.AddPair(pair); err != nil {
+		t.Errorf("Error adding pair for TestPairAdministration: %s", err)
+		return
+	}
+
+	if err = server.AddPair(&match.Pair{AssetWant: pair.AssetHave, AssetHave: pair.AssetWant}); err == nil {
+		t.Errorf("Pair that already exists the other way around should not have been added")
+		return
+	}
+
+	if len(server.GetPairs()) != 2 {
+		t.Errorf("Server should have had 2 pairs after adding one but had %d", len(server.GetPairs()))
+		return
+	}
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 3; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestPairAdministration: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	buyerPub, sellerPub, delistedPub := pubs[0], pubs[1], pubs[2]
+
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestPairAdministration: %s", err)
+				return
+			}
+		}
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var buyID *match.OrderID
+	if buyID, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestPairAdministration: %s", err)
+		return
+	}
+
+	var buyIDPair *match.LimitOrderIDPair
+	if buyIDPair, err = server.GetOrder(buyID); err != nil {
+		t.Errorf("Error getting buy order for TestPairAdministration: %s", err)
+		return
+	}
+
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(sellOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	if err = server.SetPairStatus(pair, match.PairHalted); err != nil {
+		t.Errorf("Error halting pair for TestPairAdministration: %s", err)
+		return
+	}
+
+	if _, err = server.PlaceOrder(sellOrder); err == nil {
+		t.Errorf("Order should not have been placed on a halted pair")
+		return
+	}
+
+	if err = server.CancelOrder(buyIDPair); err == nil {
+		t.Errorf("Order should not have been cancelled on a halted pair")
+		return
+	}
+
+	if err = server.SetPairStatus(pair, match.PairCancelOnly); err != nil {
+		t.Errorf("Error setting pair to cancel-only for TestPairAdministration: %s", err)
+		return
+	}
+
+	if _, err = server.PlaceOrder(sellOrder); err == nil {
+		t.Errorf("Order should not have been placed on a cancel-only pair")
+		return
+	}
+
+	if err = server.SetPairStatus(pair, match.PairAuctionOnly); err != nil {
+		t.Errorf("Error setting pair to auction-only for TestPairAdministration: %s", err)
+		return
+	}
+
+	marketOrder := *sellOrder
+	marketOrder.TimeInForce = match.ImmediateOrCancel
+	if _, err = server.PlaceOrder(&marketOrder); err == nil {
+		t.Errorf("Immediate-or-cancel order should not have been placed on an auction-only pair")
+		return
+	}
+
+	// The sell crosses the buy, but isn't matched until the pair is resumed
+	if _, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Error placing sell order on auction-only pair for TestPairAdministration: %s", err)
+		return
+	}
+
+	if _, err = server.GetOrder(buyID); err != nil {
+		t.Errorf("Buy order should still be on the book of an auction-only pair: %s", err)
+		return
+	}
+
+	if err = server.SetPairStatus(pair, match.PairTrading); err != nil {
+		t.Errorf("Error resuming pair for TestPairAdministration: %s", err)
+		return
+	}
+
+	if _, err = server.GetOrder(buyID); err == nil {
+		t.Errorf("Buy order should have been filled when the pair was resumed")
+		return
+	}
+
+	if *server.lastPrices[*pair] != (match.Price{AmountWant: 1, AmountHave: 1}) {
+		t.Errorf("Last price should have been 1 after the pair was resumed but was %s", server.lastPrices[*pair].String())
+		return
+	}
+
+	// Delisting gives back everything held for orders and stop orders
+	restingOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  25,
+	}
+	copy(restingOrder.Pubkey[:], delistedPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(restingOrder); err != nil {
+		t.Errorf("Error placing resting order for TestPairAdministration: %s", err)
+		return
+	}
+
+	stop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  30,
+			AmountWant:  30,
+		},
+		StopPrice: match.Price{AmountWant: 1, AmountHave: 2},
+	}
+	copy(stop.Order.Pubkey[:], delistedPub.SerializeCompressed())
+
+	if _, err = server.PlaceStopOrder(stop); err != nil {
+		t.Errorf("Error placing stop order for TestPairAdministration: %s", err)
+		return
+	}
+
+	if err = server.SetPairStatus(pair, match.PairDelisted); err != nil {
+		t.Errorf("Error delisting pair for TestPairAdministration: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		var balance uint64
+		if balance, err = server.GetBalance(delistedPub, coin); err != nil {
+			t.Errorf("Error getting balance for TestPairAdministration: %s", err)
+			return
+		}
+
+		if balance != 1000 {
+			t.Errorf("Everything should have been refunded when the pair was delisted, but %s balance was %d", coin.Name, balance)
+			return
+		}
+	}
+
+	var stops []*match.StopOrderIDPair
+	if stops, err = server.GetStopOrdersForPubkey(delistedPub); err != nil {
+		t.Errorf("Error getting stop orders for TestPairAdministration: %s", err)
+		return
+	}
+
+	if len(stops) != 0 {
+		t.Errorf("Stop orders should have been cancelled when the pair was delisted, but there were %d", len(stops))
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestPairAdministration: %s", err)
+		return
+	}
+	replayServer.PairStoreCreator = createMemoryPairStores
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestPairAdministration: %s", err)
+		return
+	}
+
+	var status match.PairStatus
+	if status, err = replayServer.GetPairStatus(pair); err != nil {
+		t.Errorf("Error getting replayed pair status for TestPairAdministration: %s", err)
+		return
+	}
+
+	if status != match.PairDelisted {
+		t.Errorf("Replayed pair should have been delisted but was %s", status.String())
+		return
+	}
+
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			var balance, replayedBalance uint64
+			if balance, err = server.GetBalance(pub, coin); err != nil {
+				t.Errorf("Error getting balance for TestPairAdministration: %s", err)
+				return
+			}
+
+			if replayedBalance, err = replayServer.GetBalance(pub, coin); err != nil {
+				t.Errorf("Error getting replayed balance for TestPairAdministration: %s", err)
+				return
+			}
+
+			if balance != replayedBalance {
+				t.Errorf("Replayed %s balance should have been %d but was %d", coin.Name, balance, replayedBalance)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// TestResumeJournalFailure makes the journal fail to write the match when a pair with crossed orders is
+// resumed, making sure the matching engine is rolled back so the orders are still matched when the pair is
+// resumed again.
+func TestResumeJournalFailure(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	journal := new(failingJournal)
+	if journal.Journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestResumeJournalFailure: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	if err = server.SetPairStatus(pair, match.PairAuctionOnly); err != nil {
+		t.Errorf("Error setting pair to auction-only for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	// The buy and sell cross, but aren't matched until the pair is resumed
+	var orderIDs []*match.OrderID
+	for _, side := range []match.Side{match.Buy, match.Sell} {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestResumeJournalFailure: %s", err)
+			return
+		}
+
+		for _, coin := range coinList {
+			if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestResumeJournalFailure: %s", err)
+				return
+			}
+		}
+
+		order := &match.LimitOrder{
+			Side:        side,
+			TradingPair: *pair,
+			AmountHave:  100,
+			AmountWant:  100,
+		}
+		copy(order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+		var orderID *match.OrderID
+		if orderID, err = server.PlaceOrder(order); err != nil {
+			t.Errorf("Error placing order for TestResumeJournalFailure: %s", err)
+			return
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+
+	journal.failMatch = true
+	if err = server.SetPairStatus(pair, match.PairTrading); err == nil {
+		t.Errorf("Crossed orders were matched without being journaled for TestResumeJournalFailure")
+		return
+	}
+	journal.failMatch = false
+
+	for _, orderID := range orderIDs {
+		if _, err = server.GetOrder(orderID); err != nil {
+			t.Errorf("Order should still be on the book after the match wasn't journaled: %s", err)
+			return
+		}
+	}
+
+	// If the failed match was left in the matching engine, the orders wouldn't be in it to match again
+	if err = server.SetPairStatus(pair, match.PairTrading); err != nil {
+		t.Errorf("Error resuming pair again for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	for _, orderID := range orderIDs {
+		if _, err = server.GetOrder(orderID); err == nil {
+			t.Errorf("Order should have been filled when the pair was resumed again")
+			return
+		}
+	}
+
+	var trades []*match.Trade
+	if trades, err = server.GetRecentTrades(pair, 10); err != nil {
+		t.Errorf("Error getting trades for TestResumeJournalFailure: %s", err)
+		return
+	}
+
+	if len(trades) != 1 {
+		t.Errorf("There should have been 1 trade after the pair was resumed again but there were %d", len(trades))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+package cxserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetPairRules sets the rules that orders have to follow for each pair. Pairs without rules accept any order.
+func (server *OpencxServer) SetPairRules(rules map[match.Pair]*match.PairRules) (err error) {
+	for pair, pairRules := range rules {
+		if pairRules == nil {
+			err = fmt.Errorf("Rules for %s cannot be nil, please enter valid input", pair.String())
+			return
+		}
+
+		if err = pairRules.Valid(); err != nil {
+			err = fmt.Errorf("Invalid rules for %s for SetPairRules: %s", pair.String(), err)
+			return
+		}
+	}
+
+	server.lockAll()
+	server.PairRules = make(map[match.Pair]*match.PairRules)
+	for pair, pairRules := range rules {
+		server.PairRules[pair] = pairRules
+	}
+	server.unlockAll()
+	return
+}
+
+// GetPairRules returns the rules for a pair, which are all zero if the pair doesn't have any
+func (server *OpencxServer) GetPairRules(pair *match.Pair) (rules *match.PairRules, err error) {
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for GetPairRules: %s", err)
+		return
+	}
+
+	rules = new(match.PairRules)
+	if pairRules, ok := server.PairRules[*pair]; ok {
+		*rules = *pairRules
+	}
+
+	server.unlockBook(*pair)
+	return
+}
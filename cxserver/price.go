@@ -7,20 +7,24 @@ import (
 )
 
 func (server *OpencxServer) GetPrice(pair *match.Pair) (price float64, err error) {
-	server.dbLock.Lock()
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for GetPrice: %s", err)
+		return
+	}
+
 	var currOrderbook match.LimitOrderbook
 	var ok bool
 	if currOrderbook, ok = server.Orderbooks[*pair]; !ok {
 		err = fmt.Errorf("Could not find orderbooks for trading pair for GetPrice")
-		server.dbLock.Unlock()
+		server.unlockBook(*pair)
 		return
 	}
 
 	if price, err = currOrderbook.CalculatePrice(); err != nil {
 		err = fmt.Errorf("Error calculating price for server GetPrice: %s", err)
-		server.dbLock.Unlock()
+		server.unlockBook(*pair)
 		return
 	}
-	server.dbLock.Unlock()
+	server.unlockBook(*pair)
 	return
 }
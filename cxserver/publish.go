@@ -0,0 +1,115 @@
+package cxserver
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// SetPublisher sets the publisher that the book and trades for each pair are sent to as they change, and
+// publishes the book and recent trades every pair has right now.
+func (server *OpencxServer) SetPublisher(publisher *cxpubsub.Publisher) (err error) {
+	if publisher == nil {
+		err = fmt.Errorf("Cannot set nil publisher, please enter valid input")
+		return
+	}
+
+	server.lockAll()
+	for pair, currOrderbook := range server.Orderbooks {
+		publisher.AddPair(pair)
+
+		var book map[match.Price][]*match.LimitOrderIDPair
+		if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+			err = fmt.Errorf("Error viewing orderbook for SetPublisher: %s", err)
+			server.unlockAll()
+			return
+		}
+
+		if err = publisher.PublishBook(pair, book); err != nil {
+			err = fmt.Errorf("Error publishing book for SetPublisher: %s", err)
+			server.unlockAll()
+			return
+		}
+
+		var currTradeStore cxdb.TradeStore
+		var ok bool
+		if currTradeStore, ok = server.TradeStores[pair]; !ok {
+			continue
+		}
+
+		var trades []*match.Trade
+		if trades, err = currTradeStore.GetRecentTrades(uint64(publisher.RecentExecutions)); err != nil {
+			err = fmt.Errorf("Error getting recent trades for SetPublisher: %s", err)
+			server.unlockAll()
+			return
+		}
+
+		// Recent trades are newest first, but they're published oldest first
+		var execs []*match.PriceExecution
+		for i := len(trades) - 1; i >= 0; i-- {
+			execs = append(execs, trades[i].PriceExecution())
+		}
+
+		if err = publisher.PublishExecutions(cxpubsub.TradesChannel, pair, execs); err != nil {
+			err = fmt.Errorf("Error publishing trades for SetPublisher: %s", err)
+			server.unlockAll()
+			return
+		}
+	}
+
+	server.publisher = publisher
+	server.unlockAll()
+	return
+}
+
+// publishEntry publishes the book and trades for the pair a journal entry changed, if the server has a
+// publisher. The entry has already been applied, so anything that can't be published is logged instead
+// of returned.
+// This should be called with the same locks held as applyJournalEntry.
+func (server *OpencxServer) publishEntry(entry *cxdb.JournalEntry) {
+	if server.publisher == nil {
+		return
+	}
+
+	switch entry.Type {
+	case cxdb.JournalAddPair:
+		server.publisher.AddPair(entry.Pair)
+		return
+	case cxdb.JournalPlace, cxdb.JournalReplace, cxdb.JournalMatch, cxdb.JournalCancel:
+	default:
+		return
+	}
+
+	var currOrderbook match.LimitOrderbook
+	var ok bool
+	if currOrderbook, ok = server.Orderbooks[entry.Pair]; !ok {
+		logging.Errorf("Could not find orderbook for trading pair %s to publish", entry.Pair.String())
+		return
+	}
+
+	var err error
+	var book map[match.Price][]*match.LimitOrderIDPair
+	if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+		logging.Errorf("Error viewing orderbook to publish: %s", err)
+		return
+	}
+
+	if err = server.publisher.PublishBook(entry.Pair, book); err != nil {
+		logging.Errorf("Error publishing book: %s", err)
+		return
+	}
+
+	var execs []*match.PriceExecution
+	for _, trade := range entry.Trades {
+		execs = append(execs, trade.PriceExecution())
+	}
+
+	if err = server.publisher.PublishExecutions(cxpubsub.TradesChannel, entry.Pair, execs); err != nil {
+		logging.Errorf("Error publishing trades: %s", err)
+		return
+	}
+	return
+}
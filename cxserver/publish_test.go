@@ -0,0 +1,162 @@
+package cxserver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestPublish subscribes to the book and trades of a pair, then places an order and matches it, making
+// sure the subscriber gets each change and ends up with the same depth as the server.
+func TestPublish(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestPublish: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestPublish: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	publisher := cxpubsub.NewPublisher()
+	if err = server.SetPublisher(publisher); err != nil {
+		t.Errorf("Error setting publisher for TestPublish: %s", err)
+		return
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go publisher.Serve(serverConn)
+	client := cxpubsub.NewClient(clientConn)
+	defer client.Close()
+
+	bookTopic := cxpubsub.Topic{Pair: *pair, Channel: cxpubsub.BookChannel}
+	tradesTopic := cxpubsub.Topic{Pair: *pair, Channel: cxpubsub.TradesChannel}
+	if err = client.Subscribe(bookTopic, tradesTopic); err != nil {
+		t.Errorf("Error subscribing for TestPublish: %s", err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err = client.Next(); err != nil {
+			t.Errorf("Error getting snapshot for TestPublish: %s", err)
+			return
+		}
+	}
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 2; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestPublish: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+
+		for _, coin := range coinList {
+			if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestPublish: %s", err)
+				return
+			}
+		}
+	}
+
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(sellOrder.Pubkey[:], pubs[0].SerializeCompressed())
+
+	if _, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestPublish: %s", err)
+		return
+	}
+
+	var msg *cxpubsub.Message
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting book update for TestPublish: %s", err)
+		return
+	}
+
+	if msg.Topic != bookTopic || msg.Sequence != 1 || len(msg.BookUpdates) != 1 {
+		t.Errorf("Placing an order should have sent one level at sequence 1, got %d at sequence %d", len(msg.BookUpdates), msg.Sequence)
+		return
+	}
+
+	// Nobody should be able to tell who placed the order
+	var depth *match.Depth
+	if depth, err = client.Depth(*pair); err != nil {
+		t.Errorf("Error getting client depth for TestPublish: %s", err)
+		return
+	}
+
+	if len(depth.Sells) != 1 || depth.Sells[0].AmountHave != 100 || depth.Sells[0].Orders != 1 {
+		t.Errorf("Client depth should have had the sell order")
+		return
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  200,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], pubs[1].SerializeCompressed())
+
+	if _, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestPublish: %s", err)
+		return
+	}
+
+	// The book changes and then the trade comes
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting book update for TestPublish: %s", err)
+		return
+	}
+
+	if msg.Topic != bookTopic || msg.Sequence != 2 {
+		t.Errorf("Matching should have sent a book update at sequence 2, got %s at sequence %d", msg.Topic.String(), msg.Sequence)
+		return
+	}
+
+	if msg, err = client.Next(); err != nil {
+		t.Errorf("Error getting trades update for TestPublish: %s", err)
+		return
+	}
+
+	if msg.Topic != tradesTopic || msg.Sequence != 1 || len(msg.Executions) != 1 {
+		t.Errorf("Matching should have sent one trade at sequence 1, got %d at sequence %d", len(msg.Executions), msg.Sequence)
+		return
+	}
+
+	var serverDepth *match.Depth
+	if serverDepth, err = server.GetDepth(pair, 50); err != nil {
+		t.Errorf("Error getting server depth for TestPublish: %s", err)
+		return
+	}
+
+	if depth, err = client.Depth(*pair); err != nil {
+		t.Errorf("Error getting client depth for TestPublish: %s", err)
+		return
+	}
+
+	if len(depth.Buys) != len(serverDepth.Buys) || len(depth.Sells) != len(serverDepth.Sells) {
+		t.Errorf("Client depth has %d buys and %d sells, but server has %d buys and %d sells", len(depth.Buys), len(depth.Sells), len(serverDepth.Buys), len(serverDepth.Sells))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,182 @@
+package cxserver
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestReplaceOrder reduces an order and makes sure it keeps its place in the queue, increases it and makes
+// sure it loses its place, and checks that a server replaying the journal ends up with the same orders.
+func TestReplaceOrder(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestReplaceOrder: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	// Sell orders are funded in the asset want
+	var wantParam *coinparam.Params
+	if wantParam, err = pair.AssetWant.CoinParamFromAsset(); err != nil {
+		t.Errorf("Error getting param for asset want for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 3; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestReplaceOrder: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	firstPub, secondPub, buyerPub := pubs[0], pubs[1], pubs[2]
+
+	// Settlements from matching can credit either asset, so every user gets both
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestReplaceOrder: %s", err)
+				return
+			}
+		}
+	}
+
+	var sellIDs []*match.OrderID
+	for _, pub := range []*koblitz.PublicKey{firstPub, secondPub} {
+		sellOrder := &match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  100,
+			AmountWant:  100,
+		}
+		copy(sellOrder.Pubkey[:], pub.SerializeCompressed())
+
+		var sellID *match.OrderID
+		if sellID, err = server.PlaceOrder(sellOrder); err != nil {
+			t.Errorf("Error placing sell order for TestReplaceOrder: %s", err)
+			return
+		}
+		sellIDs = append(sellIDs, sellID)
+	}
+
+	var firstSell *match.LimitOrderIDPair
+	if firstSell, err = server.GetOrder(sellIDs[0]); err != nil {
+		t.Errorf("Error getting first sell order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	// Reducing the first order should give back the difference and keep it first in line
+	if _, err = server.ReplaceOrder(firstSell, 60, 60); err != nil {
+		t.Errorf("Error reducing first sell order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var bal uint64
+	if bal, err = server.GetBalance(firstPub, wantParam); err != nil {
+		t.Errorf("Error getting balance for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if bal != 1000-60 {
+		t.Errorf("Balance after reducing order should have been %d but was %d", 1000-60, bal)
+		return
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  30,
+		AmountWant:  30,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing first buy order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if firstSell, err = server.GetOrder(sellIDs[0]); err != nil {
+		t.Errorf("Error getting first sell order after buy for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if firstSell.Order.AmountHave != 30 {
+		t.Errorf("Reduced order should still have been first in line and have 30 left, but had %d", firstSell.Order.AmountHave)
+		return
+	}
+
+	// Increasing it should put it behind the second order
+	if _, err = server.ReplaceOrder(firstSell, 80, 80); err != nil {
+		t.Errorf("Error increasing first sell order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if _, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing second buy order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var secondSell *match.LimitOrderIDPair
+	if secondSell, err = server.GetOrder(sellIDs[1]); err != nil {
+		t.Errorf("Error getting second sell order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if secondSell.Order.AmountHave != 70 {
+		t.Errorf("Second order should have been first in line after the first was increased, but had %d left", secondSell.Order.AmountHave)
+		return
+	}
+
+	// Only the amounts that are still on the book should be left after replaying
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestReplaceOrder: %s", err)
+		return
+	}
+
+	var replayedSell *match.LimitOrderIDPair
+	if replayedSell, err = replayServer.GetOrder(sellIDs[0]); err != nil {
+		t.Errorf("Error getting replayed first sell order for TestReplaceOrder: %s", err)
+		return
+	}
+
+	if replayedSell.Order.AmountHave != 80 || replayedSell.Timestamp.Before(secondSell.Timestamp) {
+		t.Errorf("Replayed first sell order should have had 80 left behind the second order, but had %d", replayedSell.Order.AmountHave)
+		return
+	}
+
+	return
+}
@@ -1,13 +1,12 @@
 package cxserver
 
 import (
+	"container/list"
 	"fmt"
 	"sync"
 
 	"github.com/mit-dci/lit/crypto/koblitz"
 
-	"golang.org/x/crypto/sha3"
-
 	"github.com/mit-dci/lit/uspv"
 
 	"github.com/mit-dci/lit/btcutil/hdkeychain"
@@ -18,6 +17,8 @@ import (
 	"github.com/mit-dci/lit/wire"
 
 	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/cxpubsub"
 	"github.com/mit-dci/opencx/logging"
 	"github.com/mit-dci/opencx/match"
 )
@@ -28,12 +29,65 @@ type OpencxServer struct {
 	SettlementEngines map[*coinparam.Params]match.SettlementEngine
 	MatchingEngines   map[match.Pair]match.LimitEngine
 	Orderbooks        map[match.Pair]match.LimitOrderbook
+	StopOrderStores   map[match.Pair]cxdb.StopOrderStore
+	TradeStores       map[match.Pair]cxdb.TradeStore
+	CandleStores      map[match.Pair]cxdb.CandleStore
 	DepositStores     map[*coinparam.Params]cxdb.DepositStore
 	SettlementStores  map[*coinparam.Params]cxdb.SettlementStore
-	dbLock            *sync.Mutex
 
-	registrationString string
-	getOrdersString    string
+	// pairLocks protect the matching engine, orderbook, stop order store, trade store, and candle store for
+	// each pair, and assetLocks protect the settlement engine, settlement store, and deposit store for each
+	// coin. See locks.go for the order they have to be acquired in. pairsMtx protects the maps of everything
+	// for each pair, which only change when a pair is added.
+	pairLocks  map[match.Pair]*sync.Mutex
+	assetLocks map[*coinparam.Params]*sync.Mutex
+	pairsMtx   *sync.RWMutex
+
+	// lastPrices is the price of the last trade for each pair, which triggers stop orders. The price for
+	// a pair is protected by its pair lock, and is zero if nothing has traded.
+	lastPrices map[match.Pair]*match.Price
+
+	// pairStatus is the status of each pair, which decides whether orders can be placed, matched, or
+	// cancelled. The status for a pair is protected by its pair lock. Change it with SetPairStatus.
+	pairStatus map[match.Pair]*match.PairStatus
+
+	// PairStoreCreator creates the matching engine, orderbook, stop order store, trade store, and candle
+	// store for pairs that are added with AddPair. Pairs can't be added if it isn't set.
+	PairStoreCreator PairStoreCreator
+
+	// journal is written to before any change is applied, if it is set. Set it with ReplayJournal.
+	journal cxdb.Journal
+
+	// publisher is sent the book and trades for each pair whenever they change, if it is set. Set it with
+	// SetPublisher.
+	publisher *cxpubsub.Publisher
+
+	// FeeSchedules are the maker and taker fees for each pair, and FeeAccount is the pubkey the fees are
+	// paid to. Pairs without a fee schedule don't charge fees. Set them with SetFees.
+	FeeSchedules map[match.Pair]*match.FeeSchedule
+	FeeAccount   [33]byte
+
+	// PairRules are the tick size, lot size, min value, and max size rules for each pair, which are
+	// protected by the pair lock. Pairs without rules accept any order. Set them with SetPairRules.
+	PairRules map[match.Pair]*match.PairRules
+
+	// nonceStore keeps the nonces each pubkey has used in order envelopes, in memory unless it's set with
+	// SetNonceStore. nonceMtx is held while a nonce is checked and used, so it can only be used once.
+	nonceStore cxdb.NonceStore
+	nonceMtx   *sync.Mutex
+
+	// feeRevenue is the total amount of each asset that has been paid in fees, protected by feeMtx
+	feeRevenue map[match.Asset]uint64
+	feeMtx     *sync.Mutex
+
+	// challenges are the challenges that have been issued and not answered or expired yet, by nonce.
+	// challengeOrder has their nonces in the order they were issued, oldest first, and
+	// challengesByRequester has the nonces for each requester in the same order. All are protected by
+	// challengeMtx.
+	challenges            map[[32]byte]*issuedChallenge
+	challengeOrder        *list.List
+	challengesByRequester map[string][][32]byte
+	challengeMtx          *sync.Mutex
 
 	ExchangeNode *qln.LitNode
 
@@ -52,6 +106,10 @@ type OpencxServer struct {
 	PrivKeyMap map[*coinparam.Params]*hdkeychain.ExtendedKey
 	privKeyMtx *sync.Mutex
 
+	// exchangePubkey is the pubkey for the exchange's private key, which is the only key that can
+	// administer the exchange. It is set with SetupServerKeys and protected by privKeyMtx.
+	exchangePubkey *koblitz.PublicKey
+
 	// default Capacity is the default capacity that we send back to people.
 	// remove this when we have some sense of how much money the exchange has and/or some fancy
 	// algorithms to determine this number based on reputation or something
@@ -59,21 +117,35 @@ type OpencxServer struct {
 }
 
 // InitServer creates a new server
-func InitServer(setEngines map[*coinparam.Params]match.SettlementEngine, matchEngines map[match.Pair]match.LimitEngine, books map[match.Pair]match.LimitOrderbook, depositStores map[*coinparam.Params]cxdb.DepositStore, settleStores map[*coinparam.Params]cxdb.SettlementStore, rootDir string) (server *OpencxServer, err error) {
+func InitServer(setEngines map[*coinparam.Params]match.SettlementEngine, matchEngines map[match.Pair]match.LimitEngine, books map[match.Pair]match.LimitOrderbook, stopStores map[match.Pair]cxdb.StopOrderStore, tradeStores map[match.Pair]cxdb.TradeStore, candleStores map[match.Pair]cxdb.CandleStore, depositStores map[*coinparam.Params]cxdb.DepositStore, settleStores map[*coinparam.Params]cxdb.SettlementStore, rootDir string) (server *OpencxServer, err error) {
 	server = &OpencxServer{
 		SettlementEngines: setEngines,
 		MatchingEngines:   matchEngines,
 		Orderbooks:        books,
+		StopOrderStores:   stopStores,
+		TradeStores:       tradeStores,
+		CandleStores:      candleStores,
 		DepositStores:     depositStores,
 		SettlementStores:  settleStores,
-		dbLock:            new(sync.Mutex),
+		pairLocks:         createPairLocks(matchEngines, books, stopStores),
+		lastPrices:        createLastPrices(matchEngines, books, stopStores),
+		pairStatus:        createPairStatuses(matchEngines, books, stopStores),
+		assetLocks:        createAssetLocks(setEngines, settleStores, depositStores),
+		pairsMtx:          new(sync.RWMutex),
+		FeeSchedules:      make(map[match.Pair]*match.FeeSchedule),
+		PairRules:         make(map[match.Pair]*match.PairRules),
+		feeRevenue:        make(map[match.Asset]uint64),
+		feeMtx:            new(sync.Mutex),
+		nonceMtx:          new(sync.Mutex),
 		OpencxRoot:        rootDir,
 
-		registrationString: "opencx-register",
-		getOrdersString:    "opencx-getorders",
-		ingestMutex:        *new(sync.Mutex),
-		BlockChanMap:       make(map[int]chan *wire.MsgBlock),
-		HeightEventChanMap: make(map[int]chan lnutil.HeightEvent),
+		challenges:            make(map[[32]byte]*issuedChallenge),
+		challengeOrder:        list.New(),
+		challengesByRequester: make(map[string][][32]byte),
+		challengeMtx:          new(sync.Mutex),
+		ingestMutex:           *new(sync.Mutex),
+		BlockChanMap:          make(map[int]chan *wire.MsgBlock),
+		HeightEventChanMap:    make(map[int]chan lnutil.HeightEvent),
 
 		HookMap:    make(map[*coinparam.Params]*uspv.ChainHook),
 		WalletMap:  make(map[*coinparam.Params]*wallit.Wallit),
@@ -86,6 +158,11 @@ func InitServer(setEngines map[*coinparam.Params]match.SettlementEngine, matchEn
 		defaultCapacity: 1000000,
 	}
 
+	if server.nonceStore, err = cxdbmemory.CreateNonceStore(); err != nil {
+		err = fmt.Errorf("Error creating nonce store for InitServer: %s", err)
+		return
+	}
+
 	return
 }
 
@@ -105,48 +182,6 @@ func (server *OpencxServer) StartChainhookHandlers(wallet *wallit.Wallit) {
 
 }
 
-// GetRegistrationString gets a string that should be signed in order for a client to be registered
-func (server *OpencxServer) GetRegistrationString() (regStr string) {
-	regStr = server.registrationString
-	return
-}
-
-// RegistrationStringVerify verifies a signature for a registration string and returns a pubkey
-func (server *OpencxServer) RegistrationStringVerify(sig []byte) (pubkey *koblitz.PublicKey, err error) {
-	// e = h(registrationstring)
-	sha3 := sha3.New256()
-	sha3.Write([]byte(server.GetRegistrationString()))
-	e := sha3.Sum(nil)
-
-	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), sig, e); err != nil {
-		err = fmt.Errorf("Error verifying registration string, invalid signature: \n%s", err)
-		return
-	}
-
-	return
-}
-
-// GetOrdersString gets a string that should be signed in order for a client to be registered
-func (server *OpencxServer) GetOrdersString() (getOrderStr string) {
-	getOrderStr = server.getOrdersString
-	return
-}
-
-// GetOrdersStringVerify verifies a signature for the getOrdersString
-func (server *OpencxServer) GetOrdersStringVerify(sig []byte) (pubkey *koblitz.PublicKey, err error) {
-	// e = h(getOrders)
-	sha3 := sha3.New256()
-	sha3.Write([]byte(server.GetOrdersString()))
-	e := sha3.Sum(nil)
-
-	if pubkey, _, err = koblitz.RecoverCompact(koblitz.S256(), sig, e); err != nil {
-		err = fmt.Errorf("Error verifying getOrders string, invalid signature: \n%s", err)
-		return
-	}
-
-	return
-}
-
 // GetAddressMap gets an address map for a pubkey. This is so we can register multiple ways.
 func (server *OpencxServer) GetAddressMap(pubkey *koblitz.PublicKey) (addrMap map[*coinparam.Params]string, err error) {
 	// go through each enabled wallet in the server and create a new address for them.
@@ -162,16 +197,16 @@ func (server *OpencxServer) GetAddressMap(pubkey *koblitz.PublicKey) (addrMap ma
 // GetPairs just iterates throug the matching engine map, getting their pair keys and appending
 // to a list
 func (server *OpencxServer) GetPairs() (pairs []*match.Pair) {
-	server.dbLock.Lock()
 	var currPair *match.Pair
+	server.pairsMtx.RLock()
 	for pair, _ := range server.MatchingEngines {
 		currPair = new(match.Pair)
 		*currPair = pair
 		pairs = append(pairs, currPair)
 	}
+	server.pairsMtx.RUnlock()
 	for _, p := range pairs {
 		logging.Infof("pair: %s", p.PrettyString())
 	}
-	server.dbLock.Unlock()
 	return
 }
@@ -0,0 +1,322 @@
+package cxserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
+)
+
+// PlaceStopOrder holds a stop order until the last trade price for its pair reaches the stop price. The
+// funds for the order are taken from the user now, and given back if the stop order is cancelled.
+func (server *OpencxServer) PlaceStopOrder(stop *match.StopOrder) (orderID *match.OrderID, err error) {
+	if stop == nil {
+		err = fmt.Errorf("Cannot place nil stop order, please enter valid input")
+		return
+	}
+
+	if err = stop.Valid(); err != nil {
+		err = fmt.Errorf("Invalid stop order for PlaceStopOrder: %s", err)
+		return
+	}
+
+	var reserveExec *match.SettlementExecution
+	if reserveExec, err = stop.ReserveExecution(); err != nil {
+		err = fmt.Errorf("Error getting reserve for PlaceStopOrder: %s", err)
+		return
+	}
+
+	var param *coinparam.Params
+	if param, err = reserveExec.Asset.CoinParamFromAsset(); err != nil {
+		err = fmt.Errorf("Could not turn order asset into coin param for PlaceStopOrder: %s", err)
+		return
+	}
+
+	pair := stop.Order.TradingPair
+	if err = server.lockPair(pair); err != nil {
+		err = fmt.Errorf("Error locking pair for PlaceStopOrder: %s", err)
+		return
+	}
+
+	// Stop orders are only kept in memory, so without a journal they and the funds reserved for them
+	// would be lost when the exchange restarts
+	if server.journal == nil {
+		err = fmt.Errorf("Cannot place stop orders without a journal to keep them across restarts")
+		server.unlockPair(pair)
+		return
+	}
+
+	// A stop order can be triggered as soon as it's placed, so the pair has to be matching orders
+	if status := *server.pairStatus[pair]; status != match.PairTrading {
+		err = fmt.Errorf("Cannot place stop orders on %s while it is %s", pair.PrettyString(), status.String())
+		server.unlockPair(pair)
+		return
+	}
+
+	var currSetEng match.SettlementEngine
+	var ok bool
+	if currSetEng, ok = server.SettlementEngines[param]; !ok {
+		err = fmt.Errorf("Could not find correct settlement engine for PlaceStopOrder")
+		server.unlockPair(pair)
+		return
+	}
+
+	// The matching engine and orderbook are needed when the stop order is triggered
+	if _, ok = server.MatchingEngines[pair]; !ok {
+		err = fmt.Errorf("Could not find matching engine for trading pair for PlaceStopOrder")
+		server.unlockPair(pair)
+		return
+	}
+
+	if _, ok = server.StopOrderStores[pair]; !ok {
+		err = fmt.Errorf("Could not find stop order store for trading pair for PlaceStopOrder")
+		server.unlockPair(pair)
+		return
+	}
+
+	var valid bool
+	if valid, err = currSetEng.CheckValid(reserveExec); err != nil {
+		err = fmt.Errorf("Error checking valid settlement exec: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	if !valid {
+		err = fmt.Errorf("Error placing stop order, not enough balance or you are not allowed to place orders")
+		server.unlockPair(pair)
+		return
+	}
+
+	var idPair *match.StopOrderIDPair
+	if idPair, err = stop.IDPair(time.Now()); err != nil {
+		err = fmt.Errorf("Error creating stop order ID for PlaceStopOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	stopEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalStopPlace,
+		Pair:            pair,
+		SettlementExecs: []*match.SettlementExecution{reserveExec},
+		PlacedStopOrder: idPair,
+	}
+
+	if err = server.writeJournal(stopEntry); err != nil {
+		err = fmt.Errorf("Error writing journal for PlaceStopOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	if err = server.applyJournalEntry(stopEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry for PlaceStopOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	// The last trade might already be past the stop price. The stop order is already journaled, so it's
+	// placed even if this fails.
+	if err = server.triggerStopOrders(pair); err != nil {
+		logging.Errorf("Error triggering stop orders after placing stop order %x: %s", idPair.OrderID[:], err)
+		err = nil
+	}
+
+	server.unlockPair(pair)
+
+	orderID = idPair.OrderID
+	return
+}
+
+// GetStopOrder gets a stop order that hasn't been triggered or cancelled yet
+func (server *OpencxServer) GetStopOrder(orderID *match.OrderID) (stop *match.StopOrderIDPair, err error) {
+	for _, pair := range server.pairList() {
+		if err = server.lockBook(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for GetStopOrder: %s", err)
+			return
+		}
+
+		currStopStore, ok := server.StopOrderStores[pair]
+		if !ok {
+			server.unlockBook(pair)
+			continue
+		}
+
+		if stop, err = currStopStore.GetStopOrder(orderID); err == nil {
+			server.unlockBook(pair)
+			return
+		}
+		server.unlockBook(pair)
+	}
+
+	err = fmt.Errorf("Could not find stop order %x", orderID[:])
+	return
+}
+
+// GetStopOrdersForPubkey returns the stop orders for a pubkey that haven't been triggered or cancelled yet
+func (server *OpencxServer) GetStopOrdersForPubkey(pubkey *koblitz.PublicKey) (stops []*match.StopOrderIDPair, err error) {
+	var currStops []*match.StopOrderIDPair
+	for _, pair := range server.pairList() {
+		if err = server.lockBook(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for GetStopOrdersForPubkey: %s", err)
+			return
+		}
+
+		currStopStore, ok := server.StopOrderStores[pair]
+		if !ok {
+			server.unlockBook(pair)
+			continue
+		}
+
+		if currStops, err = currStopStore.GetStopOrdersForPubkey(pubkey); err != nil {
+			err = fmt.Errorf("Error getting stop orders for pubkey for server GetStopOrdersForPubkey: %s", err)
+			server.unlockBook(pair)
+			return
+		}
+		server.unlockBook(pair)
+
+		stops = append(stops, currStops...)
+	}
+
+	return
+}
+
+// CancelStopOrder cancels a stop order that hasn't been triggered yet, and gives the user back the funds
+// that were reserved for it
+func (server *OpencxServer) CancelStopOrder(stop *match.StopOrderIDPair) (err error) {
+	if stop == nil || stop.OrderID == nil || stop.Order == nil {
+		err = fmt.Errorf("Cannot cancel nil stop order, please enter valid input")
+		return
+	}
+
+	pair := stop.Order.Order.TradingPair
+	if err = server.lockPair(pair); err != nil {
+		err = fmt.Errorf("Error locking pair for CancelStopOrder: %s", err)
+		return
+	}
+
+	if status := *server.pairStatus[pair]; !status.CanCancel() {
+		err = fmt.Errorf("Cannot cancel stop orders on %s while it is %s", pair.PrettyString(), status.String())
+		server.unlockPair(pair)
+		return
+	}
+
+	if err = server.cancelStopOrder(stop); err != nil {
+		err = fmt.Errorf("Error cancelling stop order for CancelStopOrder: %s", err)
+		server.unlockPair(pair)
+		return
+	}
+
+	server.unlockPair(pair)
+	return
+}
+
+// cancelStopOrder journals and applies the cancellation of a stop order, refunding what was reserved for it.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) cancelStopOrder(stop *match.StopOrderIDPair) (err error) {
+	pair := stop.Order.Order.TradingPair
+
+	var currStopStore cxdb.StopOrderStore
+	var ok bool
+	if currStopStore, ok = server.StopOrderStores[pair]; !ok {
+		err = fmt.Errorf("Could not find stop order store for trading pair %s", pair.String())
+		return
+	}
+
+	// Make sure it's still there, it could have been triggered or cancelled since the caller got it
+	if _, err = currStopStore.GetStopOrder(stop.OrderID); err != nil {
+		err = fmt.Errorf("Error getting stop order to cancel: %s", err)
+		return
+	}
+
+	var refund *match.SettlementExecution
+	if refund, err = stop.Order.Order.RefundExecution(stop.Order.Order.AmountHave); err != nil {
+		err = fmt.Errorf("Error getting refund for stop order: %s", err)
+		return
+	}
+
+	cancelEntry := &cxdb.JournalEntry{
+		Type:            cxdb.JournalStopCancel,
+		Pair:            pair,
+		SettlementExecs: []*match.SettlementExecution{refund},
+		CancelledOrder:  &match.CancelledOrder{OrderID: stop.OrderID},
+	}
+
+	if err = server.writeJournal(cancelEntry); err != nil {
+		err = fmt.Errorf("Error writing journal: %s", err)
+		return
+	}
+
+	if err = server.applyJournalEntry(cancelEntry); err != nil {
+		err = fmt.Errorf("Error applying journal entry: %s", err)
+		return
+	}
+	return
+}
+
+// triggerStopOrders places every stop order for the pair that the last trade price has triggered, one at a
+// time, since each order that gets placed can trade and trigger more stop orders. A stop-loss order that
+// can't be placed because the other side of the book is empty is cancelled.
+// This should be called with the pair lock and the asset locks for the pair held.
+func (server *OpencxServer) triggerStopOrders(pair match.Pair) (err error) {
+	var currStopStore cxdb.StopOrderStore
+	var ok bool
+	if currStopStore, ok = server.StopOrderStores[pair]; !ok {
+		// Pairs without a stop order store can't have stop orders
+		return
+	}
+
+	var currMatchEng match.LimitEngine
+	if currMatchEng, ok = server.MatchingEngines[pair]; !ok {
+		err = fmt.Errorf("Could not find matching engine for trading pair %s", pair.String())
+		return
+	}
+
+	var currOrderbook match.LimitOrderbook
+	if currOrderbook, ok = server.Orderbooks[pair]; !ok {
+		err = fmt.Errorf("Could not find orderbook for trading pair %s", pair.String())
+		return
+	}
+
+	for {
+		lastPrice := server.lastPrices[pair]
+		if lastPrice == nil || lastPrice.AmountWant == 0 || lastPrice.AmountHave == 0 {
+			return
+		}
+
+		var triggered []*match.StopOrderIDPair
+		if triggered, err = currStopStore.GetTriggeredStopOrders(lastPrice); err != nil {
+			err = fmt.Errorf("Error getting triggered stop orders: %s", err)
+			return
+		}
+
+		if len(triggered) == 0 {
+			return
+		}
+
+		stop := triggered[0]
+
+		var book map[match.Price][]*match.LimitOrderIDPair
+		if book, err = currOrderbook.ViewLimitOrderBook(); err != nil {
+			err = fmt.Errorf("Error viewing orderbook for triggered stop order: %s", err)
+			return
+		}
+
+		var order *match.LimitOrder
+		if order, err = stop.Order.TriggeredOrder(book); err != nil {
+			logging.Infof("Cancelling stop order %x, could not create order when it was triggered: %s", stop.OrderID[:], err)
+			if err = server.cancelStopOrder(stop); err != nil {
+				err = fmt.Errorf("Error cancelling triggered stop order: %s", err)
+				return
+			}
+			continue
+		}
+
+		if _, err = server.placeAndMatch(currMatchEng, order, nil, stop.OrderID); err != nil {
+			err = fmt.Errorf("Error placing triggered stop order: %s", err)
+			return
+		}
+	}
+}
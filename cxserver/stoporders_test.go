@@ -0,0 +1,379 @@
+package cxserver
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestStopOrderTrigger places a stop order that a trade triggers and one that it doesn't, then makes sure
+// a server replaying the journal has the same stop orders.
+func TestStopOrderTrigger(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestStopOrderTrigger: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	// Sell orders are funded in the asset want
+	var wantParam *coinparam.Params
+	if wantParam, err = pair.AssetWant.CoinParamFromAsset(); err != nil {
+		t.Errorf("Error getting param for asset want for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 3; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestStopOrderTrigger: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	buyerPub, sellerPub, stopperPub := pubs[0], pubs[1], pubs[2]
+
+	// Settlements from matching can credit either asset, so every user gets both
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestStopOrderTrigger: %s", err)
+				return
+			}
+		}
+	}
+
+	// This sell stop triggers once something trades at a price of 1 or lower
+	triggeredStop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  40,
+			AmountWant:  40,
+		},
+		StopPrice: match.Price{AmountWant: 1, AmountHave: 1},
+	}
+	copy(triggeredStop.Order.Pubkey[:], stopperPub.SerializeCompressed())
+
+	// A server without a journal would lose the stop order when it restarts
+	var unjournaled *OpencxServer
+	if unjournaled, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server without journal for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if _, err = unjournaled.PlaceStopOrder(triggeredStop); err == nil {
+		t.Errorf("Stop order was placed without a journal for TestStopOrderTrigger")
+		return
+	}
+
+	if _, err = server.PlaceStopOrder(triggeredStop); err != nil {
+		t.Errorf("Error placing stop order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	// This one doesn't trigger unless something trades at a price of 1/2 or lower
+	waitingStop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  30,
+			AmountWant:  30,
+		},
+		StopPrice: match.Price{AmountWant: 1, AmountHave: 2},
+	}
+	copy(waitingStop.Order.Pubkey[:], stopperPub.SerializeCompressed())
+
+	var waitingID *match.OrderID
+	if waitingID, err = server.PlaceStopOrder(waitingStop); err != nil {
+		t.Errorf("Error placing second stop order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	// The funds for both stop orders should be reserved
+	var reservedBal uint64
+	if reservedBal, err = server.GetBalance(stopperPub, wantParam); err != nil {
+		t.Errorf("Error getting stopper balance for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if reservedBal != 1000-40-30 {
+		t.Errorf("Stopper balance should have been %d after placing stop orders but was %d", 1000-40-30, reservedBal)
+		return
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var buyID *match.OrderID
+	if buyID, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	// This trades at a price of 1, which triggers the first stop order, which then fills 40 more of the buy
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+	copy(sellOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Error placing sell order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var remainingBuy *match.LimitOrderIDPair
+	if remainingBuy, err = server.GetOrder(buyID); err != nil {
+		t.Errorf("Error getting buy order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if remainingBuy.Order.AmountHave != 10 {
+		t.Errorf("Buy order should have had 10 left after the stop order was triggered but had %d", remainingBuy.Order.AmountHave)
+		return
+	}
+
+	var stops []*match.StopOrderIDPair
+	if stops, err = server.GetStopOrdersForPubkey(stopperPub); err != nil {
+		t.Errorf("Error getting stop orders for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if len(stops) != 1 || *stops[0].OrderID != *waitingID {
+		t.Errorf("Only the stop order that wasn't triggered should be left, but there were %d", len(stops))
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if *replayServer.lastPrices[*pair] != *server.lastPrices[*pair] {
+		t.Errorf("Replayed last price should have been %s but was %s", server.lastPrices[*pair].String(), replayServer.lastPrices[*pair].String())
+		return
+	}
+
+	var replayedStop *match.StopOrderIDPair
+	if replayedStop, err = replayServer.GetStopOrder(waitingID); err != nil {
+		t.Errorf("Error getting replayed stop order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var balBeforeCancel uint64
+	if balBeforeCancel, err = replayServer.GetBalance(stopperPub, wantParam); err != nil {
+		t.Errorf("Error getting stopper balance before cancel for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	if err = replayServer.CancelStopOrder(replayedStop); err != nil {
+		t.Errorf("Error cancelling replayed stop order for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	var balAfterCancel uint64
+	if balAfterCancel, err = replayServer.GetBalance(stopperPub, wantParam); err != nil {
+		t.Errorf("Error getting stopper balance after cancel for TestStopOrderTrigger: %s", err)
+		return
+	}
+
+	// The stopper only gets back what was reserved for the stop order that was cancelled
+	if balAfterCancel != balBeforeCancel+30 {
+		t.Errorf("Stopper balance after cancel should have been %d but was %d", balBeforeCancel+30, balAfterCancel)
+		return
+	}
+
+	if _, err = replayServer.GetStopOrder(waitingID); err == nil {
+		t.Errorf("Cancelled stop order should not be found")
+		return
+	}
+
+	return
+}
+
+// TestStopOrderTriggerFailure makes the journal fail to write a triggered stop order, making sure the order
+// that triggered it is still placed, and the stop order is triggered by the next trade.
+func TestStopOrderTriggerFailure(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	journal := new(failingJournal)
+	if journal.Journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 3; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestStopOrderTriggerFailure: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	buyerPub, sellerPub, stopperPub := pubs[0], pubs[1], pubs[2]
+
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestStopOrderTriggerFailure: %s", err)
+				return
+			}
+		}
+	}
+
+	// This sell stop triggers once something trades at a price of 1 or lower
+	stop := &match.StopOrder{
+		Order: match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  40,
+			AmountWant:  40,
+		},
+		StopPrice: match.Price{AmountWant: 1, AmountHave: 1},
+	}
+	copy(stop.Order.Pubkey[:], stopperPub.SerializeCompressed())
+
+	if _, err = server.PlaceStopOrder(stop); err != nil {
+		t.Errorf("Error placing stop order for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	buyOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  100,
+	}
+	copy(buyOrder.Pubkey[:], buyerPub.SerializeCompressed())
+
+	var buyID *match.OrderID
+	if buyID, err = server.PlaceOrder(buyOrder); err != nil {
+		t.Errorf("Error placing buy order for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	// This trades at a price of 1, but the stop order it triggers can't be journaled
+	sellOrder := &match.LimitOrder{
+		Side:        match.Sell,
+		TradingPair: *pair,
+		AmountHave:  50,
+		AmountWant:  50,
+	}
+	copy(sellOrder.Pubkey[:], sellerPub.SerializeCompressed())
+
+	journal.failTriggered = true
+	var sellID *match.OrderID
+	if sellID, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Order should have been placed even though the stop order it triggered wasn't: %s", err)
+		return
+	}
+	journal.failTriggered = false
+
+	if sellID == nil {
+		t.Errorf("Placed order should have had an ID for TestStopOrderTriggerFailure")
+		return
+	}
+
+	var remainingBuy *match.LimitOrderIDPair
+	if remainingBuy, err = server.GetOrder(buyID); err != nil {
+		t.Errorf("Error getting buy order for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	if remainingBuy.Order.AmountHave != 50 {
+		t.Errorf("Buy order should have had 50 left after the stop order failed to trigger but had %d", remainingBuy.Order.AmountHave)
+		return
+	}
+
+	var stops []*match.StopOrderIDPair
+	if stops, err = server.GetStopOrdersForPubkey(stopperPub); err != nil {
+		t.Errorf("Error getting stop orders for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	if len(stops) != 1 {
+		t.Errorf("Stop order should still be waiting after it failed to trigger, but there were %d", len(stops))
+		return
+	}
+
+	// The next trade triggers the stop order, which fills 40 more of the buy
+	sellOrder.AmountHave = 5
+	sellOrder.AmountWant = 5
+	if _, err = server.PlaceOrder(sellOrder); err != nil {
+		t.Errorf("Error placing second sell order for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	if remainingBuy, err = server.GetOrder(buyID); err != nil {
+		t.Errorf("Error getting buy order for TestStopOrderTriggerFailure: %s", err)
+		return
+	}
+
+	if remainingBuy.Order.AmountHave != 5 {
+		t.Errorf("Buy order should have had 5 left after the stop order was triggered but had %d", remainingBuy.Order.AmountHave)
+		return
+	}
+
+	return
+}
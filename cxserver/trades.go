@@ -0,0 +1,117 @@
+package cxserver
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/match"
+)
+
+// GetRecentTrades returns the latest trades on a pair, newest first, and at most limit of them
+func (server *OpencxServer) GetRecentTrades(pair *match.Pair, limit uint64) (trades []*match.Trade, err error) {
+	if pair == nil {
+		err = fmt.Errorf("Cannot get trades for nil pair, please enter valid input")
+		return
+	}
+
+	if err = server.lockBook(*pair); err != nil {
+		err = fmt.Errorf("Error locking pair for GetRecentTrades: %s", err)
+		return
+	}
+
+	var currTradeStore cxdb.TradeStore
+	var ok bool
+	if currTradeStore, ok = server.TradeStores[*pair]; !ok {
+		err = fmt.Errorf("Could not find trade store for trading pair for GetRecentTrades")
+		server.unlockBook(*pair)
+		return
+	}
+
+	if trades, err = currTradeStore.GetRecentTrades(limit); err != nil {
+		err = fmt.Errorf("Error getting recent trades for server GetRecentTrades: %s", err)
+		server.unlockBook(*pair)
+		return
+	}
+
+	server.unlockBook(*pair)
+	return
+}
+
+// GetTradesForPubkey returns the trades that an order placed by a pubkey was in, on every pair, newest
+// first, and at most limit of them. If cursor isn't nil the trades start where the page it came from
+// ended. If there are more trades, next is the cursor for them, otherwise it's nil.
+func (server *OpencxServer) GetTradesForPubkey(pubkey *koblitz.PublicKey, cursor *match.FillsCursor, limit uint64) (trades []*match.Trade, next *match.FillsCursor, err error) {
+	if pubkey == nil {
+		err = fmt.Errorf("Cannot get trades for nil pubkey, please enter valid input")
+		return
+	}
+
+	if limit == 0 {
+		err = fmt.Errorf("Cannot get trades for pubkey with a limit of zero, please enter valid input")
+		return
+	}
+
+	var before time.Time
+	var skip uint64
+	if cursor != nil {
+		before = cursor.Before
+		skip = cursor.Skip
+	}
+
+	// Every pair could have all of the trades on the page, the skipped ones, and one more that shows
+	// there's another page
+	var currTrades []*match.Trade
+	for _, pair := range server.pairList() {
+		if err = server.lockBook(pair); err != nil {
+			err = fmt.Errorf("Error locking pair for GetTradesForPubkey: %s", err)
+			return
+		}
+
+		currTradeStore, ok := server.TradeStores[pair]
+		if !ok {
+			server.unlockBook(pair)
+			continue
+		}
+
+		if currTrades, err = currTradeStore.GetTradesForPubkey(pubkey, before, limit+skip+1); err != nil {
+			err = fmt.Errorf("Error getting trades for pubkey for server GetTradesForPubkey: %s", err)
+			server.unlockBook(pair)
+			return
+		}
+		server.unlockBook(pair)
+
+		trades = append(trades, currTrades...)
+	}
+
+	// Each store has its trades newest first, and the pairs are in order, so trades made at the same time
+	// stay in the same order
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Timestamp.After(trades[j].Timestamp)
+	})
+
+	// The trades made at before come first, and the first skip of them were on earlier pages
+	for i := uint64(0); i < skip && len(trades) > 0 && trades[0].Timestamp.Equal(before); i++ {
+		trades = trades[1:]
+	}
+
+	if uint64(len(trades)) <= limit {
+		return
+	}
+
+	trades = trades[:limit]
+	next = &match.FillsCursor{Before: trades[limit-1].Timestamp}
+	for _, trade := range trades {
+		if trade.Timestamp.Equal(next.Before) {
+			next.Skip++
+		}
+	}
+
+	if next.Before.Equal(before) {
+		next.Skip += skip
+	}
+
+	return
+}
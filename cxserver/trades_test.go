@@ -0,0 +1,249 @@
+package cxserver
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/match"
+)
+
+// TestTrades matches orders and makes sure the trades are recorded with the right orders, pubkeys, and
+// maker side, that users only get their own fills, and that a server replaying the journal has the same trades.
+func TestTrades(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var journal cxdb.Journal
+	if journal, err = cxdbmemory.CreateJournal(); err != nil {
+		t.Errorf("Error creating journal for TestTrades: %s", err)
+		return
+	}
+
+	var server *OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestTrades: %s", err)
+		return
+	}
+
+	if err = server.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying empty journal for TestTrades: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestTrades: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	var pubs []*koblitz.PublicKey
+	for i := 0; i < 3; i++ {
+		var priv *koblitz.PrivateKey
+		if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+			t.Errorf("Error creating key for TestTrades: %s", err)
+			return
+		}
+		pubs = append(pubs, priv.PubKey())
+	}
+	makerPub, takerPub, otherPub := pubs[0], pubs[1], pubs[2]
+
+	for _, pub := range pubs {
+		for _, coin := range coinList {
+			if err = server.DebitUser(pub, 1000, coin); err != nil {
+				t.Errorf("Error debiting user for TestTrades: %s", err)
+				return
+			}
+		}
+	}
+
+	// The maker sells twice, and the taker buys both of them
+	var sellIDs []*match.OrderID
+	for i := 0; i < 2; i++ {
+		sellOrder := &match.LimitOrder{
+			Side:        match.Sell,
+			TradingPair: *pair,
+			AmountHave:  100,
+			AmountWant:  100,
+		}
+		copy(sellOrder.Pubkey[:], makerPub.SerializeCompressed())
+
+		var sellID *match.OrderID
+		if sellID, err = server.PlaceOrder(sellOrder); err != nil {
+			t.Errorf("Error placing sell order for TestTrades: %s", err)
+			return
+		}
+		sellIDs = append(sellIDs, sellID)
+
+		buyOrder := &match.LimitOrder{
+			Side:        match.Buy,
+			TradingPair: *pair,
+			AmountHave:  100,
+			AmountWant:  100,
+		}
+		copy(buyOrder.Pubkey[:], takerPub.SerializeCompressed())
+
+		if _, err = server.PlaceOrder(buyOrder); err != nil {
+			t.Errorf("Error placing buy order for TestTrades: %s", err)
+			return
+		}
+	}
+
+	// This one doesn't cross, so it never trades
+	restingOrder := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(restingOrder.Pubkey[:], otherPub.SerializeCompressed())
+
+	if _, err = server.PlaceOrder(restingOrder); err != nil {
+		t.Errorf("Error placing resting order for TestTrades: %s", err)
+		return
+	}
+
+	var trades []*match.Trade
+	if trades, err = server.GetRecentTrades(pair, 10); err != nil {
+		t.Errorf("Error getting recent trades for TestTrades: %s", err)
+		return
+	}
+
+	if len(trades) != 2 {
+		t.Errorf("There should have been 2 trades but there were %d", len(trades))
+		return
+	}
+
+	var makerPubkey, takerPubkey [33]byte
+	copy(makerPubkey[:], makerPub.SerializeCompressed())
+	copy(takerPubkey[:], takerPub.SerializeCompressed())
+
+	// Trades are newest first
+	for i, trade := range trades {
+		if trade.SellOrderID != *sellIDs[len(sellIDs)-1-i] {
+			t.Errorf("Trade %d should have been with sell order %x but was with %x", i, sellIDs[len(sellIDs)-1-i][:], trade.SellOrderID[:])
+			return
+		}
+
+		if trade.SellPubkey != makerPubkey || trade.BuyPubkey != takerPubkey {
+			t.Errorf("Trade %d had the wrong pubkeys", i)
+			return
+		}
+
+		if trade.MakerSide != match.Sell {
+			t.Errorf("The sell order was on the book first, so it should have been the maker")
+			return
+		}
+
+		if trade.AmountWant != 100 || trade.AmountHave != 100 {
+			t.Errorf("Trade %d should have been for 100 of each asset but was for %d and %d", i, trade.AmountWant, trade.AmountHave)
+			return
+		}
+
+		if trade.Timestamp.IsZero() {
+			t.Errorf("Trade %d should have had a timestamp", i)
+			return
+		}
+	}
+
+	if trades, err = server.GetRecentTrades(pair, 1); err != nil {
+		t.Errorf("Error getting limited recent trades for TestTrades: %s", err)
+		return
+	}
+
+	if len(trades) != 1 {
+		t.Errorf("Only 1 trade should have been returned but there were %d", len(trades))
+		return
+	}
+
+	var fills []*match.Trade
+	var next *match.FillsCursor
+	if fills, next, err = server.GetTradesForPubkey(takerPub, nil, 10); err != nil {
+		t.Errorf("Error getting taker fills for TestTrades: %s", err)
+		return
+	}
+
+	if len(fills) != 2 || next != nil {
+		t.Errorf("Taker should have had 2 fills and no more pages but had %d", len(fills))
+		return
+	}
+
+	// Paging through one fill at a time gets the same fills in the same order
+	var page []*match.Trade
+	var cursor *match.FillsCursor
+	for i, fill := range fills {
+		if page, next, err = server.GetTradesForPubkey(takerPub, cursor, 1); err != nil {
+			t.Errorf("Error getting page %d of taker fills for TestTrades: %s", i, err)
+			return
+		}
+
+		if len(page) != 1 || *page[0] != *fill {
+			t.Errorf("Page %d of taker fills should have been fill %d", i, i)
+			return
+		}
+
+		if (next == nil) != (i == len(fills)-1) {
+			t.Errorf("Only the last page of taker fills should have had no cursor, but page %d had %+v", i, next)
+			return
+		}
+		cursor = next
+	}
+
+	if fills, _, err = server.GetTradesForPubkey(otherPub, nil, 10); err != nil {
+		t.Errorf("Error getting fills for pubkey that didn't trade for TestTrades: %s", err)
+		return
+	}
+
+	if len(fills) != 0 {
+		t.Errorf("Pubkey that didn't trade should have had no fills but had %d", len(fills))
+		return
+	}
+
+	var replayServer *OpencxServer
+	if replayServer, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating replay server for TestTrades: %s", err)
+		return
+	}
+
+	if err = replayServer.ReplayJournal(journal); err != nil {
+		t.Errorf("Error replaying journal for TestTrades: %s", err)
+		return
+	}
+
+	if trades, err = server.GetRecentTrades(pair, 10); err != nil {
+		t.Errorf("Error getting recent trades for TestTrades: %s", err)
+		return
+	}
+
+	var replayedTrades []*match.Trade
+	if replayedTrades, err = replayServer.GetRecentTrades(pair, 10); err != nil {
+		t.Errorf("Error getting replayed recent trades for TestTrades: %s", err)
+		return
+	}
+
+	if len(replayedTrades) != len(trades) {
+		t.Errorf("Replayed server should have had %d trades but had %d", len(trades), len(replayedTrades))
+		return
+	}
+
+	for i := range trades {
+		// The journal doesn't keep the monotonic clock reading, so times are compared with Equal
+		replayed := *replayedTrades[i]
+		if !replayed.Timestamp.Equal(trades[i].Timestamp) {
+			t.Errorf("Replayed trade %d should have been at %s but was at %s", i, trades[i].Timestamp.String(), replayed.Timestamp.String())
+			return
+		}
+		replayed.Timestamp = trades[i].Timestamp
+
+		if replayed != *trades[i] {
+			t.Errorf("Replayed trade %d should have been %s but was %s", i, trades[i].String(), replayedTrades[i].String())
+			return
+		}
+	}
+
+	return
+}
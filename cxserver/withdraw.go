@@ -8,6 +8,7 @@ import (
 	"github.com/mit-dci/lit/lnp2p"
 	"github.com/mit-dci/lit/qln"
 	"github.com/mit-dci/opencx/logging"
+	"github.com/mit-dci/opencx/match"
 
 	"github.com/mit-dci/lit/lnutil"
 
@@ -34,6 +35,24 @@ func (server *OpencxServer) WithdrawCoins(address string, pubkey *koblitz.Public
 		return
 	}
 
+	// Outputs below the dust limit wouldn't be relayed
+	var asset match.Asset
+	if asset, err = match.AssetFromCoinParam(params); err != nil {
+		err = fmt.Errorf("Error getting asset for withdrawal: %s", err)
+		return
+	}
+
+	var info *match.AssetInfo
+	if info, err = asset.Info(); err != nil {
+		err = fmt.Errorf("Error getting asset info for withdrawal: %s", err)
+		return
+	}
+
+	if amount < info.DustLimit {
+		err = fmt.Errorf("Cannot withdraw %d, which is below the dust limit of %d for %s", amount, info.DustLimit, info.Name)
+		return
+	}
+
 	// Create the function, basically make sure the wallet stuff is alright
 	var withdrawFunction func(string, *koblitz.PublicKey, uint64) (string, error)
 	if withdrawFunction, err = server.withdrawFromChain(params); err != nil {
@@ -0,0 +1,91 @@
+package cxwebsocket
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/mit-dci/opencx/cxpubsub"
+)
+
+// messageMethod is the method of the notifications that carry market data
+const messageMethod = "MarketData.Message"
+
+// conn turns a websocket into a stream, so a JSON-RPC codec can be used with it. Each write is sent as its
+// own text message, and messages are read one after another.
+type conn struct {
+	ws *websocket.Conn
+	// reader is the message being read, or nil if the next one hasn't been started
+	reader io.Reader
+	// writeMtx is held for each write, since RPC replies and market data are written from different
+	// goroutines and websockets can't be written to concurrently
+	writeMtx *sync.Mutex
+}
+
+// notification is a JSON-RPC 1.0 request with no ID, which doesn't get a response
+type notification struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     interface{}   `json:"id"`
+}
+
+// newConn creates a stream for a websocket
+func newConn(ws *websocket.Conn) (c *conn) {
+	c = &conn{
+		ws:       ws,
+		writeMtx: new(sync.Mutex),
+	}
+	return
+}
+
+// Read reads from the current message, starting the next one when it runs out
+func (c *conn) Read(p []byte) (n int, err error) {
+	for {
+		if c.reader == nil {
+			if _, c.reader, err = c.ws.NextReader(); err != nil {
+				return
+			}
+		}
+
+		n, err = c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			err = nil
+			if n == 0 {
+				continue
+			}
+		}
+		return
+	}
+}
+
+// Write sends p as a text message
+func (c *conn) Write(p []byte) (n int, err error) {
+	c.writeMtx.Lock()
+	if err = c.ws.WriteMessage(websocket.TextMessage, p); err != nil {
+		c.writeMtx.Unlock()
+		return
+	}
+	c.writeMtx.Unlock()
+
+	n = len(p)
+	return
+}
+
+// Close closes the websocket
+func (c *conn) Close() (err error) {
+	err = c.ws.Close()
+	return
+}
+
+// sendMessage sends market data as a notification
+func (c *conn) sendMessage(msg *cxpubsub.Message) (err error) {
+	var notificationBytes []byte
+	if notificationBytes, err = json.Marshal(&notification{Method: messageMethod, Params: []interface{}{msg}}); err != nil {
+		return
+	}
+
+	_, err = c.Write(notificationBytes)
+	return
+}
@@ -0,0 +1,130 @@
+package cxwebsocket
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	"github.com/gorilla/websocket"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/logging"
+)
+
+// maxMessageSize is the largest message the gateway will read
+const maxMessageSize = 1 << 20
+
+// Gateway serves the commands of an RPC receiver, like cxrpc's OpencxRPC or cxauctionrpc's OpencxAuctionRPC,
+// as JSON-RPC 1.0 over websockets, so browsers can use the exchange. Commands are called the same way as
+// over net/rpc, for example OpencxRPC.SubmitOrder with the SubmitOrderArgs as the only param, so they're
+// authorized by the same signatures. Connections aren't authenticated with noise, so admin commands can't
+// be used. Each connection can also subscribe to market data with the MarketData commands.
+type Gateway struct {
+	receiver  interface{}
+	publisher *cxpubsub.Publisher
+	upgrader  websocket.Upgrader
+	listener  net.Listener
+}
+
+// CreateGateway creates a gateway for an RPC receiver, and the publisher that market data comes from,
+// which can be nil if market data isn't published
+func CreateGateway(receiver interface{}, publisher *cxpubsub.Publisher) (gateway *Gateway, err error) {
+	if receiver == nil {
+		err = fmt.Errorf("Cannot create websocket gateway for nil receiver, please enter valid input")
+		return
+	}
+
+	// Make sure the receiver can be registered before any connections come in
+	if err = rpc.NewServer().Register(receiver); err != nil {
+		err = fmt.Errorf("Error registering receiver for CreateGateway: %s", err)
+		return
+	}
+
+	gateway = &Gateway{
+		receiver:  receiver,
+		publisher: publisher,
+		upgrader: websocket.Upgrader{
+			// Nothing is authorized by cookies, every command that needs it is signed, so pages from any
+			// origin can connect
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+	return
+}
+
+// ServeHTTP upgrades a request to a websocket and serves it until it's closed
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with an error
+		logging.Debugf("Error upgrading websocket connection from %s: %s", r.RemoteAddr, err)
+		return
+	}
+	ws.SetReadLimit(maxMessageSize)
+
+	c := newConn(ws)
+
+	// Each connection gets its own RPC server, so market data commands know which connection to send to
+	connRPCServer := rpc.NewServer()
+	if err = connRPCServer.Register(g.receiver); err != nil {
+		logging.Errorf("Error registering RPC Interface for websocket connection: %s", err)
+		c.Close()
+		return
+	}
+
+	marketData := new(MarketData)
+	if g.publisher != nil {
+		marketData.session = g.publisher.NewSession(r.RemoteAddr, c.sendMessage, c.Close)
+	}
+
+	if err = connRPCServer.Register(marketData); err != nil {
+		logging.Errorf("Error registering market data for websocket connection: %s", err)
+		if marketData.session != nil {
+			marketData.session.Close()
+		}
+		c.Close()
+		return
+	}
+
+	// This returns once the connection is closed
+	connRPCServer.ServeCodec(jsonrpc.NewServerCodec(c))
+
+	if marketData.session != nil {
+		marketData.session.Close()
+	}
+	return
+}
+
+// WebSocketListen listens for websocket connections on host and port
+func (g *Gateway) WebSocketListen(host string, port uint16) (err error) {
+	serverAddr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	if g.listener, err = net.Listen("tcp", serverAddr); err != nil {
+		err = fmt.Errorf("Error listening for WebSocketListen: %s", err)
+		return
+	}
+	logging.Infof("Running websocket gateway on %s\n", g.listener.Addr().String())
+
+	go func() {
+		if err := http.Serve(g.listener, g); err != nil {
+			logging.Infof("Stopped serving websocket connections: %s", err)
+		}
+	}()
+	return
+}
+
+// Stop closes the websocket listener
+func (g *Gateway) Stop() (err error) {
+	if g.listener == nil {
+		err = fmt.Errorf("Error, cannot stop a listener that doesn't exist")
+		return
+	}
+
+	if err = g.listener.Close(); err != nil {
+		err = fmt.Errorf("Error closing websocket listener: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,381 @@
+package cxwebsocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mit-dci/lit/coinparam"
+	"github.com/mit-dci/lit/crypto/koblitz"
+	"github.com/mit-dci/opencx/cxdb"
+	"github.com/mit-dci/opencx/cxdb/cxdbmemory"
+	"github.com/mit-dci/opencx/cxpubsub"
+	"github.com/mit-dci/opencx/cxrpc"
+	"github.com/mit-dci/opencx/cxserver"
+	"github.com/mit-dci/opencx/match"
+)
+
+// createMemoryServer creates a server with memory stores and a random exchange key for a list of coins
+func createMemoryServer(coinList []*coinparam.Params) (server *cxserver.OpencxServer, err error) {
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		return
+	}
+
+	var setEngines map[*coinparam.Params]match.SettlementEngine
+	if setEngines, err = cxdbmemory.CreateSettlementEngineMap(coinList); err != nil {
+		return
+	}
+
+	var mengines map[match.Pair]match.LimitEngine
+	if mengines, err = cxdbmemory.CreateLimitEngineMap(pairList); err != nil {
+		return
+	}
+
+	var limBooks map[match.Pair]match.LimitOrderbook
+	if limBooks, err = cxdbmemory.CreateLimitOrderbookMap(pairList); err != nil {
+		return
+	}
+
+	var stopStores map[match.Pair]cxdb.StopOrderStore
+	if stopStores, err = cxdbmemory.CreateStopOrderStoreMap(pairList); err != nil {
+		return
+	}
+
+	var tradeStores map[match.Pair]cxdb.TradeStore
+	if tradeStores, err = cxdbmemory.CreateTradeStoreMap(pairList); err != nil {
+		return
+	}
+
+	var candleStores map[match.Pair]cxdb.CandleStore
+	if candleStores, err = cxdbmemory.CreateCandleStoreMap(pairList); err != nil {
+		return
+	}
+
+	var depositStores map[*coinparam.Params]cxdb.DepositStore
+	if depositStores, err = cxdbmemory.CreateDepositStoreMap(coinList); err != nil {
+		return
+	}
+
+	var setStores map[*coinparam.Params]cxdb.SettlementStore
+	if setStores, err = cxdbmemory.CreateSettlementStoreMap(coinList); err != nil {
+		return
+	}
+
+	if server, err = cxserver.InitServer(setEngines, mengines, limBooks, stopStores, tradeStores, candleStores, depositStores, setStores, ""); err != nil {
+		return
+	}
+
+	var exchangePriv *koblitz.PrivateKey
+	if exchangePriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		return
+	}
+
+	var exchangeKey [32]byte
+	copy(exchangeKey[:], exchangePriv.Serialize())
+	err = server.SetupServerKeys(&exchangeKey)
+	return
+}
+
+// rpcMessage is a JSON-RPC 1.0 response or notification from the gateway
+type rpcMessage struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  *string           `json:"error"`
+}
+
+// testClient is a JSON-RPC client for the gateway, which keeps the notifications it reads while waiting
+// for responses
+type testClient struct {
+	ws            *websocket.Conn
+	nextID        uint64
+	notifications []*cxpubsub.Message
+}
+
+// read reads the next message from the gateway, waiting at most a few seconds
+func (tc *testClient) read() (msg *rpcMessage, err error) {
+	tc.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg = new(rpcMessage)
+	if err = tc.ws.ReadJSON(msg); err != nil {
+		return
+	}
+
+	if msg.ID != nil {
+		return
+	}
+
+	if msg.Method != messageMethod || len(msg.Params) != 1 {
+		err = fmt.Errorf("Unexpected notification %s with %d params", msg.Method, len(msg.Params))
+		return
+	}
+
+	marketMsg := new(cxpubsub.Message)
+	if err = json.Unmarshal(msg.Params[0], marketMsg); err != nil {
+		return
+	}
+	tc.notifications = append(tc.notifications, marketMsg)
+	return
+}
+
+// call calls a command and decodes its result into reply. An error response is returned as an error.
+func (tc *testClient) call(method string, args interface{}, reply interface{}) (err error) {
+	tc.nextID++
+	id := tc.nextID
+	if err = tc.ws.WriteJSON(map[string]interface{}{"method": method, "params": []interface{}{args}, "id": id}); err != nil {
+		return
+	}
+
+	for {
+		var msg *rpcMessage
+		if msg, err = tc.read(); err != nil {
+			return
+		}
+
+		if msg.ID == nil || *msg.ID != id {
+			continue
+		}
+
+		if msg.Error != nil {
+			err = fmt.Errorf("%s", *msg.Error)
+			return
+		}
+
+		if reply != nil {
+			err = json.Unmarshal(msg.Result, reply)
+		}
+		return
+	}
+}
+
+// nextMessage returns the next market data notification
+func (tc *testClient) nextMessage() (marketMsg *cxpubsub.Message, err error) {
+	for len(tc.notifications) == 0 {
+		if _, err = tc.read(); err != nil {
+			return
+		}
+	}
+
+	marketMsg = tc.notifications[0]
+	tc.notifications = tc.notifications[1:]
+	return
+}
+
+// signEnvelope returns a compact signature of an order envelope, like SubmitOrder checks
+func signEnvelope(priv *koblitz.PrivateKey, envelope *match.OrderEnvelope) (sig []byte, err error) {
+	sig, err = koblitz.SignCompact(koblitz.S256(), priv, envelope.SigHash(), false)
+	return
+}
+
+// TestGateway subscribes to a book over a websocket, then places a signed order with a JSON-RPC command,
+// making sure the order is pushed as a book update, and that bad signatures and admin commands are
+// rejected.
+func TestGateway(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestGateway: %s", err)
+		return
+	}
+
+	var pairList []*match.Pair
+	if pairList, err = match.GenerateAssetPairs(coinList); err != nil {
+		t.Errorf("Error generating pairs for TestGateway: %s", err)
+		return
+	}
+	pair := pairList[0]
+
+	publisher := cxpubsub.NewPublisher()
+	if err = server.SetPublisher(publisher); err != nil {
+		t.Errorf("Error setting publisher for TestGateway: %s", err)
+		return
+	}
+
+	var gateway *Gateway
+	if gateway, err = CreateGateway(&cxrpc.OpencxRPC{Server: server}, publisher); err != nil {
+		t.Errorf("Error creating gateway for TestGateway: %s", err)
+		return
+	}
+
+	testServer := httptest.NewServer(gateway)
+	defer testServer.Close()
+
+	var ws *websocket.Conn
+	if ws, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(testServer.URL, "http"), nil); err != nil {
+		t.Errorf("Error dialing gateway for TestGateway: %s", err)
+		return
+	}
+	defer ws.Close()
+	client := &testClient{ws: ws}
+
+	pairsReply := new(cxrpc.GetPairsReply)
+	if err = client.call("OpencxRPC.GetPairs", cxrpc.GetPairsArgs{}, pairsReply); err != nil {
+		t.Errorf("Error getting pairs for TestGateway: %s", err)
+		return
+	}
+
+	if len(pairsReply.PairList) != len(pairList) {
+		t.Errorf("Expected %d pairs for TestGateway, got %d", len(pairList), len(pairsReply.PairList))
+		return
+	}
+
+	bookTopic := cxpubsub.Topic{Pair: *pair, Channel: cxpubsub.BookChannel}
+	if err = client.call("MarketData.Subscribe", SubscribeArgs{Topics: []cxpubsub.Topic{bookTopic}}, nil); err != nil {
+		t.Errorf("Error subscribing for TestGateway: %s", err)
+		return
+	}
+
+	var marketMsg *cxpubsub.Message
+	if marketMsg, err = client.nextMessage(); err != nil {
+		t.Errorf("Error getting snapshot for TestGateway: %s", err)
+		return
+	}
+
+	if marketMsg.Type != cxpubsub.SnapshotMessage || marketMsg.Topic != bookTopic {
+		t.Errorf("Expected book snapshot for %s for TestGateway, got %s for %s", bookTopic.String(), marketMsg.Type.String(), marketMsg.Topic.String())
+		return
+	}
+	snapshotSequence := marketMsg.Sequence
+
+	var priv *koblitz.PrivateKey
+	if priv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating key for TestGateway: %s", err)
+		return
+	}
+
+	for _, coin := range coinList {
+		if err = server.DebitUser(priv.PubKey(), 1000, coin); err != nil {
+			t.Errorf("Error debiting user for TestGateway: %s", err)
+			return
+		}
+	}
+
+	order := &match.LimitOrder{
+		Side:        match.Buy,
+		TradingPair: *pair,
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+	copy(order.Pubkey[:], priv.PubKey().SerializeCompressed())
+
+	exchangeReply := new(cxrpc.GetExchangePubkeyReply)
+	if err = client.call("OpencxRPC.GetExchangePubkey", cxrpc.GetExchangePubkeyArgs{}, exchangeReply); err != nil {
+		t.Errorf("Error getting exchange pubkey for TestGateway: %s", err)
+		return
+	}
+
+	var envelope *match.OrderEnvelope
+	if envelope, err = match.NewLimitOrderEnvelope(order, exchangeReply.Pubkey, 1, uint64(time.Now().Add(time.Hour).Unix())); err != nil {
+		t.Errorf("Error creating envelope for TestGateway: %s", err)
+		return
+	}
+
+	// A signature from someone else recovers a different pubkey than the order's
+	var otherPriv *koblitz.PrivateKey
+	if otherPriv, err = koblitz.NewPrivateKey(koblitz.S256()); err != nil {
+		t.Errorf("Error creating other key for TestGateway: %s", err)
+		return
+	}
+
+	var badSig []byte
+	if badSig, err = signEnvelope(otherPriv, envelope); err != nil {
+		t.Errorf("Error signing order with other key for TestGateway: %s", err)
+		return
+	}
+
+	if err = client.call("OpencxRPC.SubmitOrder", cxrpc.SubmitOrderArgs{Order: order, Envelope: envelope, Signature: badSig}, nil); err == nil {
+		t.Errorf("Order signed by the wrong key was not rejected for TestGateway")
+		return
+	}
+
+	var orderSig []byte
+	if orderSig, err = signEnvelope(priv, envelope); err != nil {
+		t.Errorf("Error signing order for TestGateway: %s", err)
+		return
+	}
+
+	submitReply := new(cxrpc.SubmitOrderReply)
+	if err = client.call("OpencxRPC.SubmitOrder", cxrpc.SubmitOrderArgs{Order: order, Envelope: envelope, Signature: orderSig}, submitReply); err != nil {
+		t.Errorf("Error submitting order for TestGateway: %s", err)
+		return
+	}
+
+	if submitReply.OrderID == nil {
+		t.Errorf("No order ID returned for TestGateway")
+		return
+	}
+
+	if marketMsg, err = client.nextMessage(); err != nil {
+		t.Errorf("Error getting book update for TestGateway: %s", err)
+		return
+	}
+
+	if marketMsg.Type != cxpubsub.UpdateMessage || marketMsg.Sequence != snapshotSequence+1 {
+		t.Errorf("Expected book update %d for TestGateway, got %s %d", snapshotSequence+1, marketMsg.Type.String(), marketMsg.Sequence)
+		return
+	}
+
+	if len(marketMsg.BookUpdates) != 1 || marketMsg.BookUpdates[0].Side != match.Buy || marketMsg.BookUpdates[0].Level.AmountHave != order.AmountHave {
+		t.Errorf("Expected one buy level with %d amount have for TestGateway, got %d updates", order.AmountHave, len(marketMsg.BookUpdates))
+		return
+	}
+
+	// Websocket connections aren't authenticated, so they can't be the exchange
+	if err = client.call("OpencxRPC.ViewOrderBook", cxrpc.ViewOrderBookArgs{TradingPair: pair}, nil); err == nil {
+		t.Errorf("Admin command was not rejected for TestGateway")
+		return
+	}
+
+	return
+}
+
+// TestGatewayNoPublisher makes sure market data commands fail without a publisher, and other commands
+// still work
+func TestGatewayNoPublisher(t *testing.T) {
+	var err error
+
+	coinList := []*coinparam.Params{&coinparam.BitcoinParams, &coinparam.VertcoinParams}
+
+	var server *cxserver.OpencxServer
+	if server, err = createMemoryServer(coinList); err != nil {
+		t.Errorf("Error creating server for TestGatewayNoPublisher: %s", err)
+		return
+	}
+
+	var gateway *Gateway
+	if gateway, err = CreateGateway(&cxrpc.OpencxRPC{Server: server}, nil); err != nil {
+		t.Errorf("Error creating gateway for TestGatewayNoPublisher: %s", err)
+		return
+	}
+
+	testServer := httptest.NewServer(gateway)
+	defer testServer.Close()
+
+	var ws *websocket.Conn
+	if ws, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(testServer.URL, "http"), nil); err != nil {
+		t.Errorf("Error dialing gateway for TestGatewayNoPublisher: %s", err)
+		return
+	}
+	defer ws.Close()
+	client := &testClient{ws: ws}
+
+	if err = client.call("MarketData.Subscribe", SubscribeArgs{}, nil); err == nil {
+		t.Errorf("Subscribe without a publisher was not rejected for TestGatewayNoPublisher")
+		return
+	}
+
+	if err = client.call("OpencxRPC.GetPairs", cxrpc.GetPairsArgs{}, new(cxrpc.GetPairsReply)); err != nil {
+		t.Errorf("Error getting pairs for TestGatewayNoPublisher: %s", err)
+		return
+	}
+
+	return
+}
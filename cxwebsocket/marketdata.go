@@ -0,0 +1,76 @@
+package cxwebsocket
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencx/cxpubsub"
+)
+
+// MarketData is registered for each connection, so the connection can subscribe to the book, trades, and
+// auction results of pairs. The snapshots and updates for the topics it subscribes to are sent as
+// MarketData.Message notifications, numbered like they are for a cxpubsub client, so if one is missed the
+// client should call Resync.
+type MarketData struct {
+	// session is nil if the exchange doesn't publish market data
+	session *cxpubsub.Session
+}
+
+// SubscribeArgs holds the args for the Subscribe command
+type SubscribeArgs struct {
+	Topics []cxpubsub.Topic
+}
+
+// SubscribeReply holds the reply for the Subscribe command
+type SubscribeReply struct {
+}
+
+// Subscribe subscribes to topics, sending a snapshot of each of them
+func (md *MarketData) Subscribe(args SubscribeArgs, reply *SubscribeReply) (err error) {
+	if md.session == nil {
+		err = fmt.Errorf("Market data is not published by this exchange")
+		return
+	}
+
+	md.session.Request(&cxpubsub.Request{Type: cxpubsub.SubscribeRequest, Topics: args.Topics})
+	return
+}
+
+// UnsubscribeArgs holds the args for the Unsubscribe command
+type UnsubscribeArgs struct {
+	Topics []cxpubsub.Topic
+}
+
+// UnsubscribeReply holds the reply for the Unsubscribe command
+type UnsubscribeReply struct {
+}
+
+// Unsubscribe stops sending messages for topics
+func (md *MarketData) Unsubscribe(args UnsubscribeArgs, reply *UnsubscribeReply) (err error) {
+	if md.session == nil {
+		err = fmt.Errorf("Market data is not published by this exchange")
+		return
+	}
+
+	md.session.Request(&cxpubsub.Request{Type: cxpubsub.UnsubscribeRequest, Topics: args.Topics})
+	return
+}
+
+// ResyncArgs holds the args for the Resync command
+type ResyncArgs struct {
+	Topics []cxpubsub.Topic
+}
+
+// ResyncReply holds the reply for the Resync command
+type ResyncReply struct {
+}
+
+// Resync sends a new snapshot of topics that have already been subscribed to
+func (md *MarketData) Resync(args ResyncArgs, reply *ResyncReply) (err error) {
+	if md.session == nil {
+		err = fmt.Errorf("Market data is not published by this exchange")
+		return
+	}
+
+	md.session.Request(&cxpubsub.Request{Type: cxpubsub.ResyncRequest, Topics: args.Topics})
+	return
+}
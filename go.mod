@@ -13,6 +13,7 @@ require (
 	github.com/ethereum/go-ethereum v1.10.17
 	github.com/fatih/color v1.9.0
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/jackpal/gateway v1.0.6 // indirect
 	github.com/jessevdk/go-flags v1.4.1-0.20181221193153-c0795c8afcf4
 	github.com/minio/highwayhash v1.0.0
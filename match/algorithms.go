@@ -2,48 +2,52 @@ package match
 
 import (
 	"fmt"
-	"math"
 )
 
 // AuctionOrderIDPair is a pair of order ID and auction order, used for generating executions in the auction matching algorithm
 type AuctionOrderIDPair struct {
 	OrderID OrderID
-	Price   float64
+	Price   Price
 	Order   *AuctionOrder
 }
 
 // CalculateClearingPrice calculates the clearing price for orders based on their intersections.
-// In the future the error will be used potentially since the divide operation at the end might be real bad.
-func CalculateClearingPrice(book map[float64][]*AuctionOrderIDPair) (clearingPrice float64, err error) {
+// If there are no intersecting orders, the clearing price will have an AmountHave of zero, and no orders will clear.
+func CalculateClearingPrice(book map[Price][]*AuctionOrderIDPair) (clearingPrice Price, err error) {
 
-	// price that is the lowest sell price so far
-	var lowestIntersectingPrice float64
-	lowestIntersectingPrice = math.MaxFloat64
+	// price that is the lowest buy price so far
+	var lowestIntersectingPrice Price
+	var foundLowest bool
 
-	// price that is the highest buy price so far
-	var highestIntersectingPrice float64
-	highestIntersectingPrice = 0
+	// price that is the highest sell price so far
+	var highestIntersectingPrice Price
+	var foundHighest bool
 
-	// Now go through every price in the orderbook, finding the lowest sell order and highest buy order
+	// Now go through every price in the orderbook, finding the lowest buy order and highest sell order
 	for pr, orderPairList := range book {
+		// we need a copy of the key so we can take its address
+		currPrice := pr
 		for _, orderPair := range orderPairList {
-			// make sure that we keep track of the highest buy order price
+			// make sure that we keep track of the lowest buy order price
 			if orderPair.Order.IsBuySide() {
-				if pr < lowestIntersectingPrice {
-					lowestIntersectingPrice = pr
+				if !foundLowest || currPrice.Cmp(&lowestIntersectingPrice) < 0 {
+					lowestIntersectingPrice = currPrice
+					foundLowest = true
 				}
-				// make sure we keep track of the lowest sell order price
+				// make sure we keep track of the highest sell order price
 			} else if orderPair.Order.IsSellSide() {
-				if pr > highestIntersectingPrice {
-					highestIntersectingPrice = pr
+				if !foundHighest || currPrice.Cmp(&highestIntersectingPrice) > 0 {
+					highestIntersectingPrice = currPrice
+					foundHighest = true
 				}
 			}
 		}
 	}
 
-	// sellClearAmount and buyClearAmount are uint64's, and technically should be amounts of tokens (Issue #22).
-	var sellClearAmount uint64
-	var buyClearAmount uint64
+	// If one side is empty then nothing intersects
+	if !foundLowest || !foundHighest {
+		return
+	}
 
 	// same with totalBuyWant
 	var totalBuyWant uint64
@@ -54,42 +58,50 @@ func CalculateClearingPrice(book map[float64][]*AuctionOrderIDPair) (clearingPri
 	var totalSellHave uint64
 	// now that we have the prices, we go through the book again to calculate the clearing price
 	for pr, orderPairList := range book {
+		currPrice := pr
 		// if there is an intersecting price, calculate clearing amounts for the price.
+		if currPrice.Cmp(&highestIntersectingPrice) > 0 || currPrice.Cmp(&lowestIntersectingPrice) < 0 {
+			continue
+		}
 		for _, orderPair := range orderPairList {
-			if pr <= highestIntersectingPrice && pr >= lowestIntersectingPrice {
-				// for all intersecting prices in the orderbook, we add the amounts
-				if orderPair.Order.IsBuySide() {
-					buyClearAmount += orderPair.Order.AmountHave
-					totalBuyHave += orderPair.Order.AmountHave
-					totalBuyWant += orderPair.Order.AmountWant
-				} else if orderPair.Order.IsSellSide() {
-					sellClearAmount += orderPair.Order.AmountHave
-					totalSellHave += orderPair.Order.AmountHave
-					totalSellWant += orderPair.Order.AmountWant
-				}
+			// for all intersecting prices in the orderbook, we add the amounts
+			if orderPair.Order.IsBuySide() {
+				totalBuyHave += orderPair.Order.AmountHave
+				totalBuyWant += orderPair.Order.AmountWant
+			} else if orderPair.Order.IsSellSide() {
+				totalSellHave += orderPair.Order.AmountHave
+				totalSellWant += orderPair.Order.AmountWant
 			}
 		}
 	}
 
-	// Uncomment if looking for a slightly fancier but probably incorrect matching algorithm
-	// clearingPrice = (float64(totalBuyWant)/float64(totalBuyHave) + float64(totalSellWant)/float64(totalSellHave)) / 2
-
-	// TODO: this should be changed, I really don't like this floating point math (See Issue #6 and TODOs in match about price.)
-	clearingPrice = float64(totalBuyWant+totalSellWant) / float64(totalBuyHave+totalSellHave)
+	// The clearing price is the total amount wanted over the total amount had, which is exact since it is a
+	// fraction rather than a float.
+	clearingPrice = Price{
+		AmountWant: totalBuyWant + totalSellWant,
+		AmountHave: totalBuyHave + totalSellHave,
+	}
+	clearingPrice = clearingPrice.Reduce()
 
 	return
 }
 
 // GenerateClearingExecs goes through an orderbook with a clearing price, and generates executions
 // based on the clearing matching algorithm
-func GenerateClearingExecs(book map[float64][]*AuctionOrderIDPair, clearingPrice float64) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error) {
+func GenerateClearingExecs(book map[Price][]*AuctionOrderIDPair, clearingPrice *Price) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error) {
+
+	// If there's no clearing price then nothing clears
+	if clearingPrice == nil || clearingPrice.AmountWant == 0 || clearingPrice.AmountHave == 0 {
+		return
+	}
 
 	var resOrderExec *OrderExecution
 	var resSetExec []*SettlementExecution
 	// go through all orders and figure out which ones to match
 	for price, orderPairList := range book {
+		currPrice := price
 		for _, orderPair := range orderPairList {
-			if (orderPair.Order.IsBuySide() && price <= clearingPrice) || (orderPair.Order.IsSellSide() && price >= clearingPrice) {
+			if (orderPair.Order.IsBuySide() && currPrice.Cmp(clearingPrice) <= 0) || (orderPair.Order.IsSellSide() && currPrice.Cmp(clearingPrice) >= 0) {
 				// Um so this is needed because of some weird memory issue TODO: remove this fix
 				// and put in another fix if you understand pointer black magic
 				resOrderExec = new(OrderExecution)
@@ -109,15 +121,15 @@ func GenerateClearingExecs(book map[float64][]*AuctionOrderIDPair, clearingPrice
 
 // MatchClearingAlgorithm runs the matching algorithm based on a uniform clearing price, first calculating the
 // clearing price and then generating executions based on it.
-func MatchClearingAlgorithm(book map[float64][]*AuctionOrderIDPair) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error) {
+func MatchClearingAlgorithm(book map[Price][]*AuctionOrderIDPair) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error) {
 
-	var clearingPrice float64
+	var clearingPrice Price
 	if clearingPrice, err = CalculateClearingPrice(book); err != nil {
 		err = fmt.Errorf("Error calculating clearing price while running clearing matching algorithm: %s", err)
 		return
 	}
 
-	if orderExecs, settlementExecs, err = GenerateClearingExecs(book, clearingPrice); err != nil {
+	if orderExecs, settlementExecs, err = GenerateClearingExecs(book, &clearingPrice); err != nil {
 		err = fmt.Errorf("Error generating clearing execs while running match clearing algorithm: %s", err)
 		return
 	}
@@ -126,9 +138,40 @@ func MatchClearingAlgorithm(book map[float64][]*AuctionOrderIDPair) (orderExecs
 }
 
 // NumberOfOrders computes the number of order pairs in a map representation of an orderbook
-func NumberOfOrders(book map[float64][]*AuctionOrderIDPair) (numberOfOrders uint64) {
+func NumberOfOrders(book map[Price][]*AuctionOrderIDPair) (numberOfOrders uint64) {
 	for _, orderPairList := range book {
 		numberOfOrders += uint64(len(orderPairList))
 	}
 	return
 }
+
+// Serialize serializes the auction order and its ID in the canonical encoding:
+// [32 byte order ID] [8 byte price amountWant] [8 byte price amountHave] [optional auction order]
+func (a *AuctionOrderIDPair) Serialize() (buf []byte) {
+	enc := NewEncoder(AuctionOrderIDPairMessage)
+	enc.WriteFixed(a.OrderID[:])
+	enc.WritePrice(a.Price)
+	enc.WriteBool(a.Order != nil)
+	if a.Order != nil {
+		enc.WriteBytes(a.Order.Serialize())
+	}
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes an auction order and its ID that were serialized with Serialize
+func (a *AuctionOrderIDPair) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, AuctionOrderIDPairMessage)
+	dec.ReadFixed(a.OrderID[:])
+	a.Price = dec.ReadPrice()
+	a.Order = nil
+	if dec.ReadBool() {
+		a.Order = new(AuctionOrder)
+		dec.SetErr(a.Order.Deserialize(dec.ReadBytes()))
+	}
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing auction order ID pair: %s", err)
+		return
+	}
+	return
+}
@@ -61,7 +61,7 @@ var (
 )
 
 // generateLargeClearingBook puts a bunch of sell orders on the side that should be cleared, and a bunch of buy orders on the side that should be cleared
-func generateLargeClearingBook(midpoint float64, radius uint64) (book map[float64][]*AuctionOrderIDPair, err error) {
+func generateLargeClearingBook(midpoint float64, radius uint64) (book map[Price][]*AuctionOrderIDPair, err error) {
 	floatIncrement := midpoint / float64(radius)
 	if floatIncrement <= float64(0) {
 		err = fmt.Errorf("floatIncrement would not have been enough. Try again with different parameters")
@@ -97,16 +97,17 @@ func generateLargeClearingBook(midpoint float64, radius uint64) (book map[float6
 	return
 }
 
-func createBookFromOrders(orders []*AuctionOrder) (book map[float64][]*AuctionOrderIDPair, err error) {
-	book = make(map[float64][]*AuctionOrderIDPair)
-	var pr float64
+func createBookFromOrders(orders []*AuctionOrder) (book map[Price][]*AuctionOrderIDPair, err error) {
+	book = make(map[Price][]*AuctionOrderIDPair)
+	var pr *Price
 	for _, order := range orders {
 		if pr, err = order.Price(); err != nil {
 			err = fmt.Errorf("Error getting price from order while creating book from orders: %s", err)
 			return
 		}
-		book[pr] = append(book[pr], &AuctionOrderIDPair{
+		book[*pr] = append(book[*pr], &AuctionOrderIDPair{
 			OrderID: sha3.Sum256(order.SerializeSignable()),
+			Price:   *pr,
 			Order:   order,
 		})
 	}
@@ -116,7 +117,7 @@ func createBookFromOrders(orders []*AuctionOrder) (book map[float64][]*AuctionOr
 func runLargeClearingBookTest(midpoint float64, orderRadius uint64, t *testing.T) {
 	var err error
 
-	var fakeNeutralBook map[float64][]*AuctionOrderIDPair
+	var fakeNeutralBook map[Price][]*AuctionOrderIDPair
 	if fakeNeutralBook, err = generateLargeClearingBook(midpoint, orderRadius); err != nil {
 		t.Errorf("Error creating book from orders for test: %s", err)
 		return
@@ -163,7 +164,7 @@ func TestClearingPriceSamePriceBook(t *testing.T) {
 
 	ordersToInsert := []*AuctionOrder{onePriceBuy, onePriceSell}
 
-	var fakeNeutralBook map[float64][]*AuctionOrderIDPair
+	var fakeNeutralBook map[Price][]*AuctionOrderIDPair
 	if fakeNeutralBook, err = createBookFromOrders(ordersToInsert); err != nil {
 		t.Errorf("Error creating book from orders for test: %s", err)
 		return
@@ -215,7 +216,7 @@ func TestClearingTrivial(t *testing.T) {
 
 	ordersToInsert := []*AuctionOrder{trivialQuarterBuy, trivialQuarterSell}
 
-	var fakeNeutralBook map[float64][]*AuctionOrderIDPair
+	var fakeNeutralBook map[Price][]*AuctionOrderIDPair
 	if fakeNeutralBook, err = createBookFromOrders(ordersToInsert); err != nil {
 		t.Errorf("Error creating book from orders for test: %s", err)
 		return
@@ -279,7 +280,7 @@ func BenchmarkAlgorithmsClearingPrice(b *testing.B) {
 	midpointForClearingBook := float64(150)
 	orderRadiusForBook := uint64(10000)
 
-	var fakeNeutralBook map[float64][]*AuctionOrderIDPair
+	var fakeNeutralBook map[Price][]*AuctionOrderIDPair
 	if fakeNeutralBook, err = generateLargeClearingBook(midpointForClearingBook, orderRadiusForBook); err != nil {
 		b.Fatalf("Error creating book from orders for test: %s", err)
 		return
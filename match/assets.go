@@ -0,0 +1,247 @@
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mit-dci/lit/coinparam"
+)
+
+// AssetInfo is what the exchange knows about an asset
+type AssetInfo struct {
+	// ID is the byte that represents the asset in pairs, orders, and settlements
+	ID Asset `json:"id"`
+	// Coin is the name of the lit coin params for the asset's chain, like "regtest"
+	Coin string `json:"coin"`
+	// Name is what the asset is shown as to users, like "BTC"
+	Name string `json:"name"`
+	// Decimals is how many decimal places the smallest unit of the asset is, so 8 for satoshis
+	Decimals uint8 `json:"decimals"`
+	// DustLimit is the smallest amount of the asset that can be withdrawn on chain
+	DustLimit uint64 `json:"dustlimit"`
+	// Host is the full node to connect to for the asset's chain, if the exchange should connect to one
+	Host string `json:"host"`
+
+	// Params are the coin params for the asset's chain, which are set from Coin when the registry is created
+	Params *coinparam.Params `json:"-"`
+}
+
+// AssetRegistry is the assets the exchange supports and the pairs they are traded in. Asset IDs are turned
+// into coin params and names using the registry set with SetAssetRegistry.
+type AssetRegistry struct {
+	assets  map[Asset]*AssetInfo
+	byParam map[*coinparam.Params]*AssetInfo
+	byCoin  map[string]*AssetInfo
+	// pairs are the enabled pairs, or nil if every pair of assets is enabled
+	pairs []*Pair
+}
+
+// CreateAssetRegistry creates an asset registry from a list of assets and the pairs that are enabled. If the
+// pairs are nil, every pair of assets is enabled. The coin params for each asset are looked up by name from
+// the chains lit supports.
+func CreateAssetRegistry(assets []*AssetInfo, pairs []*Pair) (registry *AssetRegistry, err error) {
+	registry = &AssetRegistry{
+		assets:  make(map[Asset]*AssetInfo),
+		byParam: make(map[*coinparam.Params]*AssetInfo),
+		byCoin:  make(map[string]*AssetInfo),
+	}
+
+	for _, asset := range assets {
+		if asset == nil {
+			err = fmt.Errorf("Asset cannot be nil, please enter valid input")
+			return
+		}
+
+		info := new(AssetInfo)
+		*info = *asset
+		if info.Params == nil {
+			if info.Params, err = litCoinParams(info.Coin); err != nil {
+				err = fmt.Errorf("Error getting coin params for asset %d: %s", info.ID, err)
+				return
+			}
+		}
+		info.Coin = info.Params.Name
+
+		if _, ok := registry.assets[info.ID]; ok {
+			err = fmt.Errorf("Asset ID %d is registered more than once", info.ID)
+			return
+		}
+
+		if _, ok := registry.byParam[info.Params]; ok {
+			err = fmt.Errorf("Coin %s is registered more than once", info.Coin)
+			return
+		}
+
+		registry.assets[info.ID] = info
+		registry.byParam[info.Params] = info
+		registry.byCoin[info.Coin] = info
+	}
+
+	if pairs == nil {
+		return
+	}
+
+	registry.pairs = []*Pair{}
+	for _, pair := range pairs {
+		if _, ok := registry.assets[pair.AssetWant]; !ok {
+			err = fmt.Errorf("Asset %d in enabled pair is not registered", pair.AssetWant)
+			return
+		}
+
+		if _, ok := registry.assets[pair.AssetHave]; !ok {
+			err = fmt.Errorf("Asset %d in enabled pair is not registered", pair.AssetHave)
+			return
+		}
+
+		if pair.AssetWant == pair.AssetHave {
+			err = fmt.Errorf("Enabled pair cannot have the same asset on both sides")
+			return
+		}
+
+		pairCopy := new(Pair)
+		*pairCopy = *pair
+		registry.pairs = append(registry.pairs, pairCopy)
+	}
+	return
+}
+
+// AssetConfig is the assets and enabled pairs for an exchange, as they are written in an asset file
+type AssetConfig struct {
+	Assets []*AssetInfo `json:"assets"`
+	// Pairs are the pairs that are enabled, written the way users write them, like "regtest/litereg". If
+	// this is left out, every pair of assets is enabled.
+	Pairs []string `json:"pairs"`
+}
+
+// ParseAssetConfig parses a json asset config into an asset registry
+func ParseAssetConfig(data []byte) (registry *AssetRegistry, err error) {
+	config := new(AssetConfig)
+	if err = json.Unmarshal(data, config); err != nil {
+		err = fmt.Errorf("Error unmarshalling asset config: %s", err)
+		return
+	}
+
+	// Pairs are written with coin names, which are only known once the assets are registered
+	if registry, err = CreateAssetRegistry(config.Assets, nil); err != nil {
+		err = fmt.Errorf("Error creating asset registry for asset config: %s", err)
+		return
+	}
+
+	if config.Pairs == nil {
+		return
+	}
+
+	var pairs []*Pair
+	for _, pairString := range config.Pairs {
+		coins := strings.Split(pairString, "/")
+		if len(coins) != 2 {
+			err = fmt.Errorf("Pair %s should be two coin names separated by a slash", pairString)
+			return
+		}
+
+		pair := new(Pair)
+		for i, asset := range []*Asset{&pair.AssetWant, &pair.AssetHave} {
+			info, ok := registry.byCoin[coins[i]]
+			if !ok {
+				err = fmt.Errorf("Coin %s in pair %s is not in the asset config", coins[i], pairString)
+				return
+			}
+			*asset = info.ID
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if registry, err = CreateAssetRegistry(config.Assets, pairs); err != nil {
+		err = fmt.Errorf("Error creating asset registry for asset config: %s", err)
+		return
+	}
+	return
+}
+
+// Assets returns the assets in the registry, sorted by ID
+func (ar *AssetRegistry) Assets() (assets []*AssetInfo) {
+	for _, info := range ar.assets {
+		infoCopy := new(AssetInfo)
+		*infoCopy = *info
+		assets = append(assets, infoCopy)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].ID < assets[j].ID })
+	return
+}
+
+// litCoinParams returns the coin params that lit has registered with the name
+func litCoinParams(name string) (params *coinparam.Params, err error) {
+	for _, registered := range coinparam.RegisteredNets {
+		if registered.Name == name {
+			params = registered
+			return
+		}
+	}
+	err = fmt.Errorf("Coin %s is not a chain lit supports", name)
+	return
+}
+
+var (
+	// assetRegistry is what asset IDs are looked up in, protected by assetRegistryMtx
+	assetRegistry    = defaultAssetRegistry()
+	assetRegistryMtx = new(sync.RWMutex)
+)
+
+// defaultAssetRegistry is the registry of the assets the exchange has always supported, with every pair
+// enabled
+func defaultAssetRegistry() (registry *AssetRegistry) {
+	var err error
+	if registry, err = CreateAssetRegistry([]*AssetInfo{
+		{ID: BTC, Params: &coinparam.BitcoinParams, Name: "BTC", Decimals: 8},
+		{ID: VTC, Params: &coinparam.VertcoinParams, Name: "VTC", Decimals: 8},
+		{ID: BTCTest, Params: &coinparam.TestNet3Params, Name: "tBTC", Decimals: 8},
+		{ID: VTCTest, Params: &coinparam.VertcoinTestNetParams, Name: "tVTC", Decimals: 8},
+		{ID: LTCTest, Params: &coinparam.LiteCoinTestNet4Params, Name: "tLTC", Decimals: 8},
+		{ID: BTCReg, Params: &coinparam.RegressionNetParams, Name: "rBTC", Decimals: 8},
+		{ID: VTCReg, Params: &coinparam.VertcoinRegTestParams, Name: "rVTC", Decimals: 8},
+		{ID: LTCReg, Params: &coinparam.LiteRegNetParams, Name: "rLTC", Decimals: 8},
+	}, nil); err != nil {
+		panic(fmt.Sprintf("Error creating default asset registry: %s", err))
+	}
+	return
+}
+
+// SetAssetRegistry sets the registry that assets are looked up in. This should be done before anything
+// creates pairs, engines, or orders, since assets that aren't in the registry can't be used.
+func SetAssetRegistry(registry *AssetRegistry) (err error) {
+	if registry == nil {
+		err = fmt.Errorf("Asset registry cannot be nil, please enter valid input")
+		return
+	}
+
+	assetRegistryMtx.Lock()
+	assetRegistry = registry
+	assetRegistryMtx.Unlock()
+	return
+}
+
+// GetAssetRegistry returns the registry that assets are looked up in
+func GetAssetRegistry() (registry *AssetRegistry) {
+	assetRegistryMtx.RLock()
+	registry = assetRegistry
+	assetRegistryMtx.RUnlock()
+	return
+}
+
+// Info returns what the registry knows about the asset
+func (a Asset) Info() (info *AssetInfo, err error) {
+	assetRegistryMtx.RLock()
+	registered, ok := assetRegistry.assets[a]
+	assetRegistryMtx.RUnlock()
+	if !ok {
+		err = fmt.Errorf("Asset %d is not registered", a)
+		return
+	}
+
+	info = new(AssetInfo)
+	*info = *registered
+	return
+}
@@ -0,0 +1,103 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/mit-dci/lit/coinparam"
+)
+
+func TestParseAssetConfig(t *testing.T) {
+	var err error
+
+	// bc2 is a chain lit supports that isn't one of the built in assets
+	config := []byte(`{
+		"assets": [
+			{"id": 6, "coin": "regtest", "name": "rBTC", "decimals": 8, "dustlimit": 546},
+			{"id": 8, "coin": "litereg", "name": "rLTC", "decimals": 8},
+			{"id": 32, "coin": "bc2", "name": "BC2", "decimals": 6}
+		],
+		"pairs": ["bc2/regtest"]
+	}`)
+
+	var registry *AssetRegistry
+	if registry, err = ParseAssetConfig(config); err != nil {
+		t.Errorf("Error parsing asset config: %s", err)
+		return
+	}
+
+	defer SetAssetRegistry(defaultAssetRegistry())
+	if err = SetAssetRegistry(registry); err != nil {
+		t.Errorf("Error setting asset registry for TestParseAssetConfig: %s", err)
+		return
+	}
+
+	var bc2 Asset
+	if bc2, err = AssetFromString("bc2"); err != nil {
+		t.Errorf("Error getting asset for configured coin: %s", err)
+		return
+	}
+
+	var info *AssetInfo
+	if info, err = bc2.Info(); err != nil {
+		t.Errorf("Error getting info for configured asset: %s", err)
+		return
+	}
+
+	if info.ID != 32 || info.Params != &coinparam.BC2NetParams || info.Name != "BC2" || info.Decimals != 6 {
+		t.Errorf("Configured asset info was not from the config: %+v", info)
+		return
+	}
+
+	// Built in assets that aren't in the config can't be used
+	if _, err = AssetFromCoinParam(&coinparam.TestNet3Params); err == nil {
+		t.Errorf("Asset that isn't in the config should not have been found")
+		return
+	}
+
+	// Only the enabled pair is generated, even though there are three coins
+	var pairList []*Pair
+	if pairList, err = GenerateAssetPairs([]*coinparam.Params{&coinparam.RegressionNetParams, &coinparam.LiteRegNetParams, &coinparam.BC2NetParams}); err != nil {
+		t.Errorf("Error generating asset pairs for TestParseAssetConfig: %s", err)
+		return
+	}
+
+	if len(pairList) != 1 || pairList[0].PrettyString() != "bc2/regtest" {
+		t.Errorf("Only bc2/regtest should have been generated, but %d pairs were", len(pairList))
+		return
+	}
+
+	// The pair isn't generated if one of its coins isn't in the list
+	if pairList, err = GenerateAssetPairs([]*coinparam.Params{&coinparam.RegressionNetParams, &coinparam.LiteRegNetParams}); err != nil {
+		t.Errorf("Error generating asset pairs for TestParseAssetConfig: %s", err)
+		return
+	}
+
+	if len(pairList) != 0 {
+		t.Errorf("No pairs should have been generated, but %d were", len(pairList))
+		return
+	}
+
+	return
+}
+
+func TestParseAssetConfigInvalid(t *testing.T) {
+	var tests = []struct {
+		name   string
+		config string
+	}{
+		{name: "unknown coin", config: `{"assets": [{"id": 1, "coin": "notacoin"}]}`},
+		{name: "duplicate id", config: `{"assets": [{"id": 1, "coin": "regtest"}, {"id": 1, "coin": "litereg"}]}`},
+		{name: "duplicate coin", config: `{"assets": [{"id": 1, "coin": "regtest"}, {"id": 2, "coin": "regtest"}]}`},
+		{name: "unregistered pair", config: `{"assets": [{"id": 1, "coin": "regtest"}], "pairs": ["regtest/litereg"]}`},
+		{name: "same asset pair", config: `{"assets": [{"id": 1, "coin": "regtest"}], "pairs": ["regtest/regtest"]}`},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseAssetConfig([]byte(tt.config)); err == nil {
+			t.Errorf("Asset config with %s should have returned an error", tt.name)
+			return
+		}
+	}
+
+	return
+}
@@ -1,7 +1,6 @@
 package match
 
 import (
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 
@@ -30,8 +29,12 @@ type AuctionOrder struct {
 	AuctionID AuctionID `json:"auctionid"`
 	// 2 byte nonce (So there can be max 2^16 of the same-looking orders by the same pubkey in the same batch)
 	// This is used to protect against the exchange trying to replay a bunch of orders
-	Nonce     [2]byte `json:"nonce"`
-	Signature []byte  `json:"signature"`
+	Nonce [2]byte `json:"nonce"`
+	// Envelope commits to the order, the exchange, and a nonce the exchange will only accept once. It's
+	// encrypted with the rest of the order, so nobody can tell what the order is from it before the auction.
+	Envelope *OrderEnvelope `json:"envelope"`
+	// Signature is a compact signature of the envelope's SigHash
+	Signature []byte `json:"signature"`
 }
 
 // TODO: create an order ID method that hashes the Nonce and Signature? People should be able to verify the signature whenever, even if partially filled.
@@ -70,13 +73,15 @@ func (a *AuctionOrder) OppositeSide() (sideStr Side) {
 	return
 }
 
-// Price gets a float price for the order. This determines how it will get matched. The exchange should figure out if it can take some of the
-func (a *AuctionOrder) Price() (price float64, err error) {
+// Price gets the price for the order. This determines how it will get matched. The price is reduced, so orders
+// with the same price will have the same representation.
+func (a *AuctionOrder) Price() (price *Price, err error) {
 	if a.AmountWant == 0 || a.AmountHave == 0 {
 		err = fmt.Errorf("The amount requested in the order is 0, so no price can be calculated")
 		return
 	}
-	price = float64(a.AmountWant) / float64(a.AmountHave)
+	price = new(Price)
+	*price = (&Price{AmountWant: a.AmountWant, AmountHave: a.AmountHave}).Reduce()
 	return
 }
 
@@ -84,14 +89,14 @@ func (a *AuctionOrder) Price() (price float64, err error) {
 // This does not assume anything about the price of the order, as we can't infer what price the order was
 // placed at.
 // TODO: Figure out whether or not these should be pointers
-func (a *AuctionOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (orderExec OrderExecution, setExecs []*SettlementExecution, err error) {
+func (a *AuctionOrder) GenerateOrderFill(orderID *OrderID, execPrice *Price) (orderExec OrderExecution, setExecs []*SettlementExecution, err error) {
 
 	if a.AmountHave == 0 {
 		err = fmt.Errorf("Error generating order fill: empty order, the AmountHave cannot be 0")
 		return
 	}
 
-	if execPrice == float64(0) {
+	if execPrice == nil || execPrice.AmountWant == 0 || execPrice.AmountHave == 0 {
 		err = fmt.Errorf("Error generating order fill: price cannot be zero")
 		return
 	}
@@ -109,7 +114,10 @@ func (a *AuctionOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (o
 		err = fmt.Errorf("Error generating order fill from price, order is not buy or sell side, it's %s side", a.Side.String())
 		return
 	}
-	amountToDebit = uint64(float64(a.AmountHave) * execPrice)
+	if amountToDebit, err = execPrice.MulAmount(a.AmountHave); err != nil {
+		err = fmt.Errorf("Error generating order fill, could not calculate amount to debit: %s", err)
+		return
+	}
 
 	// IMPORTANT! These lines:
 	// > OrderID: make([]byte, len(orderID),
@@ -148,7 +156,7 @@ func (a *AuctionOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (o
 // in as a parameter. The order ID will be filled in, as it's being passed as a parameter.
 // This returns a fillRemainder, which is the amount that is left over from amountToFill after
 // filling orderID at execPrice and amountToFill
-func (a *AuctionOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice float64, amountToFill uint64) (orderExec OrderExecution, setExecs []*SettlementExecution, fillRemainder uint64, err error) {
+func (a *AuctionOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice *Price, amountToFill uint64) (orderExec OrderExecution, setExecs []*SettlementExecution, fillRemainder uint64, err error) {
 	// If it's a buy side, AmountWant is assetWant, and AmountHave is assetHave - but price is something different, price is want/have.
 	// So to convert from amountWant (amountToFill) to amountHave we need to multiple amountToFill by 1/execPrice
 	var amountWantToFill uint64
@@ -164,7 +172,14 @@ func (a *AuctionOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice fl
 		err = fmt.Errorf("Error generating execution from price, order is not buy or sell side, it's %s side", a.Side.String())
 		return
 	}
-	amountWantToFill = uint64(float64(amountToFill) * execPrice)
+	if execPrice == nil {
+		err = fmt.Errorf("Error generating execution from price, price cannot be nil")
+		return
+	}
+	if amountWantToFill, err = execPrice.MulAmount(amountToFill); err != nil {
+		err = fmt.Errorf("Error generating execution from price, could not calculate amount to fill: %s", err)
+		return
+	}
 
 	// Now that we have this value, we'll generate the execution.
 	// What should we do if the amountWantToFill is greater than the amountHave?
@@ -207,127 +222,63 @@ func (a *AuctionOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice fl
 	return
 }
 
-// Serialize serializes an order, possible replay attacks here since this is what you're signing?
-// but anyways this is the order: [33 byte pubkey] pair amountHave amountWant <length side> side [32 byte auctionid]
+// Serialize serializes an order in the canonical encoding, including its signature and envelope. This is
+// what gets encrypted in the order's puzzle:
+// [33 byte pubkey] [1 byte side] [2 byte pair] [8 byte amountHave] [8 byte amountWant] [32 byte auctionID]
+// [2 byte nonce] [signature] [optional envelope]
 func (a *AuctionOrder) Serialize() (buf []byte) {
-	// serializable fields:
-	// public key (compressed) [33 bytes]
-	// trading pair [2 bytes]
-	// amounthave [8 bytes]
-	// amountwant [8 bytes]
-	// len side [8 bytes]
-	// side [len side]
-	// auctionID [32 bytes]
-	// nonce [2 bytes]
-	// len sig [8 bytes]
-	// sig [len sig bytes]
-	buf = append(buf, a.Pubkey[:]...)
-	buf = append(buf, a.TradingPair.Serialize()...)
-
-	amountHaveBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountHaveBytes, a.AmountHave)
-	buf = append(buf, amountHaveBytes[:]...)
-
-	amountWantBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountWantBytes, a.AmountWant)
-	buf = append(buf, amountWantBytes[:]...)
-
-	var sideByte byte = 0x00
-	if a.Side == Buy {
-		sideByte = 0x01
+	enc := NewEncoder(AuctionOrderMessage)
+	a.writeSignable(enc)
+	enc.WriteBytes(a.Signature)
+	enc.WriteBool(a.Envelope != nil)
+	if a.Envelope != nil {
+		enc.WriteBytes(a.Envelope.Serialize())
 	}
-	buf = append(buf, sideByte)
-	buf = append(buf, a.AuctionID[:]...)
-	buf = append(buf, a.Nonce[:]...)
-
-	lenSigBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(lenSigBytes, uint64(len(a.Signature)))
-	buf = append(buf, lenSigBytes[:]...)
-
-	buf = append(buf, a.Signature[:]...)
+	buf = enc.Bytes()
 	return
 }
 
-// SerializeSignable serializes the fields that are hashable, and will be signed. These are also
-// what would get verified.
+// SerializeSignable serializes the fields that are hashed into the order's envelope, which is what gets
+// signed. It's the same as Serialize without the signature and envelope, with its own message type.
 func (a *AuctionOrder) SerializeSignable() (buf []byte) {
-	// serializable fields:
-	// public key (compressed) [33 bytes]
-	// trading pair [2 bytes]
-	// amounthave [8 bytes]
-	// amountwant [8 bytes]
-	// len side [8 bytes]
-	// side [len side]
-	// auctionID [32 bytes]
-	// nonce [2 bytes]
-	buf = append(buf, a.Pubkey[:]...)
-	buf = append(buf, a.TradingPair.Serialize()...)
-
-	amountHaveBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountHaveBytes, a.AmountHave)
-	buf = append(buf, amountHaveBytes[:]...)
-
-	amountWantBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountWantBytes, a.AmountWant)
-	buf = append(buf, amountWantBytes[:]...)
+	enc := NewEncoder(AuctionOrderSignableMessage)
+	a.writeSignable(enc)
+	buf = enc.Bytes()
+	return
+}
 
-	var sideByte byte = 0x00
-	if a.Side == Buy {
-		sideByte = 0x01
-	}
-	buf = append(buf, sideByte)
-	buf = append(buf, a.AuctionID[:]...)
-	buf = append(buf, a.Nonce[:]...)
+// writeSignable writes the fields of the order that are signed
+func (a *AuctionOrder) writeSignable(enc *Encoder) {
+	enc.WriteFixed(a.Pubkey[:])
+	enc.WriteSide(a.Side)
+	enc.WritePair(a.TradingPair)
+	enc.WriteUint64(a.AmountHave)
+	enc.WriteUint64(a.AmountWant)
+	enc.WriteFixed(a.AuctionID[:])
+	enc.WriteFixed(a.Nonce[:])
 	return
 }
 
 // Deserialize deserializes an order into the struct ptr it's being called on
 func (a *AuctionOrder) Deserialize(data []byte) (err error) {
-	// 33 for pubkey, 26 for the rest, 8 for len side, 4 for min side ("sell" is 4 bytes), 32 for auctionID, 2 for nonce, 8 for siglen
-	// bucket is where we put all of the non byte stuff so we can get their length
-
-	// TODO: remove all of this serialization code entirely and use protobufs or something else
-	minimumDataLength := len(a.Nonce) +
-		len(a.AuctionID) +
-		binary.Size(a.AmountWant) +
-		binary.Size(a.AmountHave) +
-		2 + // trading pair size
-		len(a.Pubkey)
-	if len(data) < minimumDataLength {
-		err = fmt.Errorf("Auction order cannot be less than %d bytes: %s", len(data), err)
-		return
+	dec := NewDecoder(data, AuctionOrderMessage)
+	dec.ReadFixed(a.Pubkey[:])
+	a.Side = dec.ReadSide()
+	a.TradingPair = dec.ReadPair()
+	a.AmountHave = dec.ReadUint64()
+	a.AmountWant = dec.ReadUint64()
+	dec.ReadFixed(a.AuctionID[:])
+	dec.ReadFixed(a.Nonce[:])
+	a.Signature = dec.ReadBytes()
+	a.Envelope = nil
+	if dec.ReadBool() {
+		a.Envelope = new(OrderEnvelope)
+		dec.SetErr(a.Envelope.Deserialize(dec.ReadBytes()))
 	}
-
-	copy(a.Pubkey[:], data[:33])
-	data = data[33:]
-	var tradingPairBytes [2]byte
-	copy(tradingPairBytes[:], data[:2])
-	if err = a.TradingPair.Deserialize(tradingPairBytes[:]); err != nil {
-		err = fmt.Errorf("Could not deserialize trading pair while deserializing auction order: %s", err)
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing auction order: %s", err)
 		return
 	}
-	data = data[2:]
-	a.AmountHave = binary.LittleEndian.Uint64(data[:8])
-	data = data[8:]
-	a.AmountWant = binary.LittleEndian.Uint64(data[:8])
-	data = data[8:]
-
-	// Side is a single byte so at this point we just get the next bit
-	var sideByte byte = data[0]
-	// If the byte is a zero value then it stays false, otherwise true
-	a.Side = Side(sideByte != 0x00)
-
-	// shift data one bit to the left
-	data = data[1:]
-	copy(a.AuctionID[:], data[:32])
-	data = data[32:]
-	copy(a.Nonce[:], data[:2])
-	data = data[2:]
-	sigLen := binary.LittleEndian.Uint64(data[:8])
-	data = data[8:]
-	a.Signature = data[:sigLen]
-	data = data[sigLen:]
-
 	return
 }
 
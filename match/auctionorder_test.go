@@ -136,7 +136,7 @@ func TestGenerateEasyExecutionFromPrice(t *testing.T) {
 	var resExec OrderExecution
 	var setExecs []*SettlementExecution
 	var fillRemainder uint64
-	if resExec, setExecs, fillRemainder, err = origOrder.GenerateExecutionFromPrice(&origOrderID, float64(1), 100000000); err != nil {
+	if resExec, setExecs, fillRemainder, err = origOrder.GenerateExecutionFromPrice(&origOrderID, &Price{AmountWant: 1, AmountHave: 1}, 100000000); err != nil {
 		t.Errorf("Error generating execution from price, should not error: %s", err)
 		return
 	}
@@ -180,7 +180,7 @@ func TestGenerateDoubleFill(t *testing.T) {
 	// this should fill the order completely. this is the trivial case.
 	var resExec OrderExecution
 	var setExecs []*SettlementExecution
-	if resExec, setExecs, err = origOrder.GenerateOrderFill(&origOrderID, float64(2)); err != nil {
+	if resExec, setExecs, err = origOrder.GenerateOrderFill(&origOrderID, &Price{AmountWant: 2, AmountHave: 1}); err != nil {
 		t.Errorf("Error generating execution from price, should not error: %s", err)
 		return
 	}
@@ -218,7 +218,7 @@ func TestGenerateEasyFillFromPrice(t *testing.T) {
 	// this should fill the order completely. this is the trivial case.
 	var resExec OrderExecution
 	var setExecs []*SettlementExecution
-	if resExec, setExecs, err = origOrder.GenerateOrderFill(&origOrderID, float64(1)); err != nil {
+	if resExec, setExecs, err = origOrder.GenerateOrderFill(&origOrderID, &Price{AmountWant: 1, AmountHave: 1}); err != nil {
 		t.Errorf("Error generating execution from price, should not error: %s", err)
 		return
 	}
@@ -260,7 +260,7 @@ func TestGenerateBadPriceFill(t *testing.T) {
 	// this should just error
 	var resExec OrderExecution
 	var setExecs []*SettlementExecution
-	if resExec, setExecs, err = badOrder.GenerateOrderFill(&origOrderID, float64(0)); err == nil {
+	if resExec, setExecs, err = badOrder.GenerateOrderFill(&origOrderID, &Price{AmountWant: 0, AmountHave: 1}); err == nil {
 		t.Errorf("There was no error trying to generate an order fill for a price of zero")
 		return
 	}
@@ -290,7 +290,7 @@ func TestGenerateEasyPriceFillAmounts(t *testing.T) {
 	// this should just error
 	var resExec OrderExecution
 	var setExecs []*SettlementExecution
-	if resExec, setExecs, err = zeroPriceOrder.GenerateOrderFill(&origOrderID, float64(1)); err != nil {
+	if resExec, setExecs, err = zeroPriceOrder.GenerateOrderFill(&origOrderID, &Price{AmountWant: 1, AmountHave: 1}); err != nil {
 		t.Errorf("Error generating execution from price, should not error: %s", err)
 		return
 	}
@@ -326,32 +326,32 @@ func TestGenerateEasyPriceFillAmounts(t *testing.T) {
 func TestSimplePriceValidBuy(t *testing.T) {
 	var err error
 
-	var retPriceOne float64
+	var retPriceOne *Price
 	if retPriceOne, err = origOrder.Price(); err != nil {
 		t.Errorf("Calculating price for origOrder should not have failed, here's the err: %s", err)
 		return
 	}
 
-	expectedPrice := 1.0
-	if retPriceOne != expectedPrice {
-		t.Errorf("Price for origOrder should have been %f but was %f", expectedPrice, retPriceOne)
+	expectedPrice := &Price{AmountWant: 1, AmountHave: 1}
+	if retPriceOne.Cmp(expectedPrice) != 0 {
+		t.Errorf("Price for origOrder should have been %s but was %s", expectedPrice, retPriceOne)
 		return
 	}
 
-	var retPriceOneCounter float64
+	var retPriceOneCounter *Price
 	if retPriceOneCounter, err = origOrderCounter.Price(); err != nil {
 		t.Errorf("Calculating price for origOrderCounter should not have failed, here's the err: %s", err)
 		return
 	}
 
-	expectedPriceCounter := 1.0
-	if retPriceOneCounter != expectedPriceCounter {
-		t.Errorf("Price for origOrderCounter should have been %f but was %f", expectedPriceCounter, retPriceOneCounter)
+	expectedPriceCounter := &Price{AmountWant: 1, AmountHave: 1}
+	if retPriceOneCounter.Cmp(expectedPriceCounter) != 0 {
+		t.Errorf("Price for origOrderCounter should have been %s but was %s", expectedPriceCounter, retPriceOneCounter)
 		return
 	}
 
-	if retPriceOneCounter != retPriceOne {
-		t.Errorf("The price for retPriceOne, which was %f, should have been the same as retPriceOneCounter, which was %f", retPriceOne, retPriceOneCounter)
+	if *retPriceOneCounter != *retPriceOne {
+		t.Errorf("The price for retPriceOne, which was %s, should have been the same as retPriceOneCounter, which was %s", retPriceOne, retPriceOneCounter)
 		return
 	}
 
@@ -422,17 +422,17 @@ var (
 )
 
 // validPriceTest runs a test to make sure the order has price expectedPrice
-func validPriceTest(order *AuctionOrder, expectedPrice float64, t *testing.T) {
+func validPriceTest(order *AuctionOrder, expectedPrice *Price, t *testing.T) {
 	var err error
 
-	var origPrice float64
+	var origPrice *Price
 	if origPrice, err = order.Price(); err != nil {
 		t.Errorf("Error getting price for order: %s", err)
 		return
 	}
 
-	if origPrice != expectedPrice {
-		t.Errorf("Test failed: price should have been %f but was %f", expectedPrice, origPrice)
+	if origPrice.Cmp(expectedPrice) != 0 {
+		t.Errorf("Test failed: price should have been %s but was %s", expectedPrice, origPrice)
 		return
 	}
 
@@ -443,9 +443,9 @@ func validPriceTest(order *AuctionOrder, expectedPrice float64, t *testing.T) {
 func errorPriceTest(order *AuctionOrder, t *testing.T) {
 	var err error
 
-	var origPrice float64
+	var origPrice *Price
 	if origPrice, err = order.Price(); err == nil {
-		t.Errorf("There was no error while calculating price for order, instead a price of %f was returned", origPrice)
+		t.Errorf("There was no error while calculating price for order, instead a price of %s was returned", origPrice)
 		return
 	}
 
@@ -453,17 +453,17 @@ func errorPriceTest(order *AuctionOrder, t *testing.T) {
 }
 
 func TestPriceOneEasy(t *testing.T) {
-	validPriceTest(origOrder, float64(1), t)
+	validPriceTest(origOrder, &Price{AmountWant: 1, AmountHave: 1}, t)
 	return
 }
 
 func TestPriceTwoBuy(t *testing.T) {
-	validPriceTest(priceTwoBuy, float64(2), t)
+	validPriceTest(priceTwoBuy, &Price{AmountWant: 2, AmountHave: 1}, t)
 	return
 }
 
 func TestPriceTwoSell(t *testing.T) {
-	validPriceTest(priceTwoSell, float64(2), t)
+	validPriceTest(priceTwoSell, &Price{AmountWant: 2, AmountHave: 1}, t)
 	return
 }
 
@@ -483,15 +483,15 @@ func TestErrorBoth(t *testing.T) {
 }
 
 func TestAuctionOrderSerializeEmpty(t *testing.T) {
-	// so empty order should be:
-	// 33 + 2 + 8 + 8 + 1 + 32 + 2 + 8 + 0 = 94
-	emptyExpectedBuf := [94]byte{}
+	// so empty order should be the version and type, then all zeroes:
+	// 2 + 33 + 1 + 2 + 8 + 8 + 32 + 2 + 4 + 1 = 93
+	emptyExpectedBuf := [93]byte{EncodingVersion, byte(AuctionOrderMessage)}
 	emptyActualBuf := emptyOrder.Serialize()
-	if len(emptyActualBuf) != 94 {
+	if len(emptyActualBuf) != 93 {
 		t.Errorf("Empty order does not serialize to correct size, it instead serializes to a size of %d", len(emptyActualBuf))
 		return
 	}
-	emptyActualArr := [94]byte{}
+	emptyActualArr := [93]byte{}
 	copy(emptyActualArr[:], emptyActualBuf[:])
 	if emptyActualArr != emptyExpectedBuf {
 		t.Errorf("Empty order actual serialization does not serialize to correct value: \nExpected serialization: %8x\nActual serialization: %8x", emptyExpectedBuf, emptyActualArr[:])
@@ -18,11 +18,12 @@ type LimitOrderbook interface {
 	// GetOrder gets an order from an OrderID
 	GetOrder(orderID *OrderID) (limOrder *LimitOrderIDPair, err error)
 	// CalculatePrice takes in a pair and returns the calculated price based on the orderbook.
+	// This is only an indication of the price, so unlike the prices used for matching it is a float.
 	CalculatePrice() (price float64, err error)
 	// GetOrdersForPubkey gets orders for a specific pubkey.
-	GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[float64][]*LimitOrderIDPair, err error)
+	GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[Price][]*LimitOrderIDPair, err error)
 	// ViewLimitOrderbook takes in a trading pair and returns the orderbook as a map
-	ViewLimitOrderBook() (book map[float64][]*LimitOrderIDPair, err error)
+	ViewLimitOrderBook() (book map[Price][]*LimitOrderIDPair, err error)
 }
 
 // AuctionOrderbook is the interface for an auction order book.
@@ -38,10 +39,11 @@ type AuctionOrderbook interface {
 	// GetOrder gets an order from an OrderID
 	GetOrder(orderID *OrderID) (limOrder *AuctionOrderIDPair, err error)
 	// CalculatePrice takes in a pair and returns the calculated price based on the orderbook.
-	// This only works for a specific auction
+	// This only works for a specific auction. This is only an indication of the price, so unlike the prices
+	// used for matching it is a float.
 	CalculatePrice(auctionID *AuctionID) (price float64, err error)
 	// GetOrdersForPubkey gets orders for a specific pubkey.
-	GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[float64][]*AuctionOrderIDPair, err error)
+	GetOrdersForPubkey(pubkey *koblitz.PublicKey) (orders map[Price][]*AuctionOrderIDPair, err error)
 	// ViewAuctionOrderBook takes in a trading pair and returns the orderbook as a map
-	ViewAuctionOrderBook() (book map[float64][]*AuctionOrderIDPair, err error)
+	ViewAuctionOrderBook() (book map[Price][]*AuctionOrderIDPair, err error)
 }
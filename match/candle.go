@@ -0,0 +1,271 @@
+package match
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// PriceExecution is something that traded on a pair at a price, either a trade between two limit orders
+// or the orders that cleared in an auction. Candles are made from these.
+type PriceExecution struct {
+	// ID identifies what traded, so the same execution isn't added to the candles twice
+	ID   [32]byte `json:"id"`
+	Pair Pair     `json:"pair"`
+	// Price is what the execution traded at, in the same units as the price of an order
+	Price Price `json:"price"`
+	// AmountWant is how much of the pair's AssetWant traded, and AmountHave is how much of the pair's
+	// AssetHave traded
+	AmountWant uint64    `json:"amountwant"`
+	AmountHave uint64    `json:"amounthave"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// PriceExecution returns the trade as a price execution, at the maker's price. The ID is the hash of the
+// buy order ID and sell order ID.
+func (t *Trade) PriceExecution() (exec *PriceExecution) {
+	exec = &PriceExecution{
+		ID:         sha3.Sum256(append(t.BuyOrderID[:], t.SellOrderID[:]...)),
+		Pair:       t.Pair,
+		Price:      t.Price,
+		AmountWant: t.AmountWant,
+		AmountHave: t.AmountHave,
+		Timestamp:  t.Timestamp,
+	}
+	return
+}
+
+// NewAuctionExecution creates the price execution for an auction, from the settlement executions that came
+// from matching it. Every order in an auction clears at the same price, so the price is the total of the
+// pair's AssetWant that was given out over the total of the pair's AssetHave that was given out. If nothing
+// cleared, exec is nil.
+func NewAuctionExecution(pair Pair, auctionID AuctionID, settlementExecs []*SettlementExecution, timestamp time.Time) (exec *PriceExecution, err error) {
+	var amountWant uint64
+	var amountHave uint64
+	for _, setExec := range settlementExecs {
+		if setExec == nil {
+			err = fmt.Errorf("Cannot create auction execution from nil settlement execution")
+			return
+		}
+
+		if setExec.Type != Debit {
+			continue
+		}
+
+		if setExec.Asset == pair.AssetWant {
+			amountWant += setExec.Amount
+		} else if setExec.Asset == pair.AssetHave {
+			amountHave += setExec.Amount
+		}
+	}
+
+	if amountWant == 0 || amountHave == 0 {
+		return
+	}
+
+	exec = &PriceExecution{
+		ID:         auctionID,
+		Pair:       pair,
+		Price:      (&Price{AmountWant: amountWant, AmountHave: amountHave}).Reduce(),
+		AmountWant: amountWant,
+		AmountHave: amountHave,
+		Timestamp:  timestamp,
+	}
+	return
+}
+
+// CandleInterval is how much time a candle covers
+type CandleInterval uint8
+
+const (
+	// OneMinute candles start on the minute
+	OneMinute CandleInterval = iota
+	// FiveMinutes candles start every five minutes, on the hour and every five minutes after
+	FiveMinutes
+	// OneHour candles start on the hour
+	OneHour
+	// OneDay candles start at midnight UTC
+	OneDay
+)
+
+// CandleIntervals are the intervals the exchange keeps candles for
+var CandleIntervals = []CandleInterval{OneMinute, FiveMinutes, OneHour, OneDay}
+
+const (
+	oneMinuteString   = "1m" // just for string representation
+	fiveMinutesString = "5m" // just for string representation
+	oneHourString     = "1h" // just for string representation
+	oneDayString      = "1d" // just for string representation
+)
+
+// String returns the string representation of the candle interval
+func (ci CandleInterval) String() string {
+	switch ci {
+	case OneMinute:
+		return oneMinuteString
+	case FiveMinutes:
+		return fiveMinutesString
+	case OneHour:
+		return oneHourString
+	case OneDay:
+		return oneDayString
+	}
+	return "unknown"
+}
+
+// FromString takes a string and, if valid, sets the CandleInterval to the correct value based on the string
+func (ci *CandleInterval) FromString(str string) (err error) {
+	switch strings.ToLower(str) {
+	default:
+		err = fmt.Errorf("Cannot get candle interval from string, not 1m, 5m, 1h, or 1d")
+		return
+	case oneMinuteString:
+		*ci = OneMinute
+	case fiveMinutesString:
+		*ci = FiveMinutes
+	case oneHourString:
+		*ci = OneHour
+	case oneDayString:
+		*ci = OneDay
+	}
+	return
+}
+
+// Duration returns how much time a candle with this interval covers, or zero if the interval isn't valid
+func (ci CandleInterval) Duration() time.Duration {
+	switch ci {
+	case OneMinute:
+		return time.Minute
+	case FiveMinutes:
+		return 5 * time.Minute
+	case OneHour:
+		return time.Hour
+	case OneDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Start returns the start of the candle with this interval that t is in, in UTC
+func (ci CandleInterval) Start(t time.Time) time.Time {
+	return t.UTC().Truncate(ci.Duration())
+}
+
+// Candle is the open, high, low, and close price, and the volume, of what traded on a pair during an interval
+type Candle struct {
+	Pair     Pair           `json:"pair"`
+	Interval CandleInterval `json:"interval"`
+	// Start is when the interval starts, and the candle has everything that traded from then until the
+	// start of the next interval
+	Start time.Time `json:"start"`
+	Open  Price     `json:"open"`
+	High  Price     `json:"high"`
+	Low   Price     `json:"low"`
+	Close Price     `json:"close"`
+	// Volume is how much of the pair's AssetWant traded, and QuoteVolume is how much of the pair's AssetHave traded
+	Volume      uint64 `json:"volume"`
+	QuoteVolume uint64 `json:"quotevolume"`
+	// Executions is how many trades and auctions the candle was made from
+	Executions uint64 `json:"executions"`
+}
+
+// NewCandle creates the candle with an interval for the first execution in it
+func NewCandle(interval CandleInterval, exec *PriceExecution) (candle *Candle, err error) {
+	if exec == nil {
+		err = fmt.Errorf("Cannot create candle from nil execution")
+		return
+	}
+
+	if interval.Duration() == 0 {
+		err = fmt.Errorf("Cannot create candle with invalid interval %d", interval)
+		return
+	}
+
+	candle = &Candle{
+		Pair:        exec.Pair,
+		Interval:    interval,
+		Start:       interval.Start(exec.Timestamp),
+		Open:        exec.Price,
+		High:        exec.Price,
+		Low:         exec.Price,
+		Close:       exec.Price,
+		Volume:      exec.AmountWant,
+		QuoteVolume: exec.AmountHave,
+		Executions:  1,
+	}
+	return
+}
+
+// AddExecution updates the candle with an execution that came after everything already in it. The
+// execution has to be in the candle's interval.
+func (c *Candle) AddExecution(exec *PriceExecution) (err error) {
+	if exec == nil {
+		err = fmt.Errorf("Cannot add nil execution to candle")
+		return
+	}
+
+	if exec.Pair != c.Pair {
+		err = fmt.Errorf("Cannot add execution for pair %s to candle for pair %s", exec.Pair.String(), c.Pair.String())
+		return
+	}
+
+	if !c.Interval.Start(exec.Timestamp).Equal(c.Start) {
+		err = fmt.Errorf("Cannot add execution at %s to %s candle starting at %s", exec.Timestamp.String(), c.Interval.String(), c.Start.String())
+		return
+	}
+
+	if exec.Price.Cmp(&c.High) > 0 {
+		c.High = exec.Price
+	}
+	if exec.Price.Cmp(&c.Low) < 0 {
+		c.Low = exec.Price
+	}
+	c.Close = exec.Price
+	c.Volume += exec.AmountWant
+	c.QuoteVolume += exec.AmountHave
+	c.Executions++
+	return
+}
+
+// End returns when the next candle starts
+func (c *Candle) End() time.Time {
+	return c.Start.Add(c.Interval.Duration())
+}
+
+// String returns a readable representation of the candle
+func (c *Candle) String() string {
+	return fmt.Sprintf("{\n\tpair: %s\n\tinterval: %s\n\tstart: %s\n\topen: %s\n\thigh: %s\n\tlow: %s\n\tclose: %s\n\tvolume: %d\n\tquotevolume: %d\n\texecutions: %d\n}", c.Pair.PrettyString(), c.Interval.String(), c.Start.String(), c.Open.String(), c.High.String(), c.Low.String(), c.Close.String(), c.Volume, c.QuoteVolume, c.Executions)
+}
+
+// Serialize serializes the price execution in the canonical encoding:
+// [32 byte ID] [2 byte pair] [8 byte price amountWant] [8 byte price amountHave] [8 byte amountWant]
+// [8 byte amountHave] [time]
+func (pe *PriceExecution) Serialize() (buf []byte) {
+	enc := NewEncoder(PriceExecutionMessage)
+	enc.WriteFixed(pe.ID[:])
+	enc.WritePair(pe.Pair)
+	enc.WritePrice(pe.Price)
+	enc.WriteUint64(pe.AmountWant)
+	enc.WriteUint64(pe.AmountHave)
+	enc.WriteTime(pe.Timestamp)
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a price execution that was serialized with Serialize
+func (pe *PriceExecution) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, PriceExecutionMessage)
+	dec.ReadFixed(pe.ID[:])
+	pe.Pair = dec.ReadPair()
+	pe.Price = dec.ReadPrice()
+	pe.AmountWant = dec.ReadUint64()
+	pe.AmountHave = dec.ReadUint64()
+	pe.Timestamp = dec.ReadTime()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing price execution: %s", err)
+		return
+	}
+	return
+}
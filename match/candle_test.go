@@ -0,0 +1,121 @@
+package match
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCandleAddExecution(t *testing.T) {
+	var err error
+
+	var pair Pair
+	pair.AssetHave = Asset(0)
+	pair.AssetWant = Asset(1)
+
+	start := time.Date(2019, 6, 1, 12, 5, 0, 0, time.UTC)
+	execs := []*PriceExecution{
+		{Pair: pair, Price: Price{AmountWant: 2, AmountHave: 1}, AmountWant: 200, AmountHave: 100, Timestamp: start.Add(10 * time.Second)},
+		{Pair: pair, Price: Price{AmountWant: 3, AmountHave: 1}, AmountWant: 300, AmountHave: 100, Timestamp: start.Add(20 * time.Second)},
+		{Pair: pair, Price: Price{AmountWant: 1, AmountHave: 1}, AmountWant: 100, AmountHave: 100, Timestamp: start.Add(30 * time.Second)},
+		{Pair: pair, Price: Price{AmountWant: 3, AmountHave: 2}, AmountWant: 150, AmountHave: 100, Timestamp: start.Add(40 * time.Second)},
+	}
+
+	var candle *Candle
+	if candle, err = NewCandle(OneMinute, execs[0]); err != nil {
+		t.Errorf("Error creating candle: %s", err)
+		return
+	}
+
+	for _, exec := range execs[1:] {
+		if err = candle.AddExecution(exec); err != nil {
+			t.Errorf("Error adding execution to candle: %s", err)
+			return
+		}
+	}
+
+	if !candle.Start.Equal(start) || !candle.End().Equal(start.Add(time.Minute)) {
+		t.Errorf("Candle should have been from %s to %s but was from %s to %s", start.String(), start.Add(time.Minute).String(), candle.Start.String(), candle.End().String())
+		return
+	}
+
+	if candle.Open != execs[0].Price || candle.High != execs[1].Price || candle.Low != execs[2].Price || candle.Close != execs[3].Price {
+		t.Errorf("Candle had the wrong prices: %s", candle.String())
+		return
+	}
+
+	if candle.Volume != 750 || candle.QuoteVolume != 400 || candle.Executions != 4 {
+		t.Errorf("Candle had the wrong volume: %s", candle.String())
+		return
+	}
+
+	// The next minute is in the next candle
+	if err = candle.AddExecution(&PriceExecution{Pair: pair, Price: Price{AmountWant: 1, AmountHave: 1}, Timestamp: start.Add(time.Minute)}); err == nil {
+		t.Errorf("Execution in the next minute should not have been added to the candle")
+		return
+	}
+
+	var intervalTests = []struct {
+		interval CandleInterval
+		start    time.Time
+	}{
+		{OneMinute, time.Date(2019, 6, 1, 12, 7, 0, 0, time.UTC)},
+		{FiveMinutes, time.Date(2019, 6, 1, 12, 5, 0, 0, time.UTC)},
+		{OneHour, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{OneDay, time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// Candles start in UTC no matter what time zone the execution is in
+	execTime := time.Date(2019, 6, 1, 12, 7, 30, 0, time.UTC).In(time.FixedZone("test", -5*60*60))
+	for _, tt := range intervalTests {
+		if !tt.interval.Start(execTime).Equal(tt.start) {
+			t.Errorf("%s candle for %s should have started at %s but started at %s", tt.interval.String(), execTime.String(), tt.start.String(), tt.interval.Start(execTime).String())
+			return
+		}
+
+		var parsed CandleInterval
+		if err = parsed.FromString(tt.interval.String()); err != nil || parsed != tt.interval {
+			t.Errorf("Interval %s did not parse back to itself", tt.interval.String())
+			return
+		}
+	}
+}
+
+func TestNewAuctionExecution(t *testing.T) {
+	var err error
+
+	var pair Pair
+	pair.AssetHave = Asset(0)
+	pair.AssetWant = Asset(1)
+
+	// Two buys get 100 of AssetWant each, and a sell gets 50 of AssetHave
+	setExecs := []*SettlementExecution{
+		{Pubkey: [33]byte{0xa}, Amount: 100, Asset: pair.AssetWant, Type: Debit},
+		{Pubkey: [33]byte{0xa}, Amount: 25, Asset: pair.AssetHave, Type: Credit},
+		{Pubkey: [33]byte{0xb}, Amount: 100, Asset: pair.AssetWant, Type: Debit},
+		{Pubkey: [33]byte{0xb}, Amount: 25, Asset: pair.AssetHave, Type: Credit},
+		{Pubkey: [33]byte{0xc}, Amount: 50, Asset: pair.AssetHave, Type: Debit},
+		{Pubkey: [33]byte{0xc}, Amount: 200, Asset: pair.AssetWant, Type: Credit},
+	}
+
+	var exec *PriceExecution
+	if exec, err = NewAuctionExecution(pair, AuctionID{0x1}, setExecs, time.Now()); err != nil {
+		t.Errorf("Error creating auction execution: %s", err)
+		return
+	}
+
+	if exec == nil || exec.AmountWant != 200 || exec.AmountHave != 50 || exec.Price != (Price{AmountWant: 4, AmountHave: 1}) || exec.ID != (AuctionID{0x1}) {
+		t.Errorf("Auction execution should have been 200 for 50 at 4/1 but was %+v", exec)
+		return
+	}
+
+	// Nothing cleared, so there's no execution
+	if exec, err = NewAuctionExecution(pair, AuctionID{0x2}, nil, time.Now()); err != nil {
+		t.Errorf("Error creating empty auction execution: %s", err)
+		return
+	}
+
+	if exec != nil {
+		t.Errorf("Auction where nothing cleared should not have had an execution")
+		return
+	}
+}
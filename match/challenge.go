@@ -0,0 +1,106 @@
+package match
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ChallengeAction is what answering a challenge lets a key do, so a challenge issued for one kind of
+// command can't be answered for another
+type ChallengeAction uint8
+
+const (
+	// RegisterChallenge challenges are answered to register a pubkey
+	RegisterChallenge ChallengeAction = iota
+	// ReadChallenge challenges are answered to read a pubkey's orders, fills, balances, or deposit addresses
+	ReadChallenge
+)
+
+const (
+	registerString = "register" // just for string representation
+	readString     = "read"     // just for string representation
+)
+
+// String returns the string representation of a challenge action
+func (ca ChallengeAction) String() string {
+	switch ca {
+	case RegisterChallenge:
+		return registerString
+	case ReadChallenge:
+		return readString
+	}
+	return "unknown"
+}
+
+// MarshalText implements the TextMarshaler interface, as "register" or "read"
+func (ca ChallengeAction) MarshalText() (text []byte, err error) {
+	switch ca {
+	case RegisterChallenge, ReadChallenge:
+		text = []byte(ca.String())
+	default:
+		err = fmt.Errorf("Unknown challenge action %d", ca)
+	}
+	return
+}
+
+// UnmarshalText implements the TextUnmarshaler interface
+func (ca *ChallengeAction) UnmarshalText(text []byte) (err error) {
+	switch string(text) {
+	case registerString:
+		*ca = RegisterChallenge
+	case readString:
+		*ca = ReadChallenge
+	default:
+		err = fmt.Errorf("Challenge action must be %s or %s, not %s", registerString, readString, string(text))
+	}
+	return
+}
+
+// Challenge is issued by the exchange for a client to sign, proving they have the private key for a
+// pubkey. The exchange only accepts one answer for each challenge, and only until it expires, so a
+// signature can't be replayed by someone who sees it.
+type Challenge struct {
+	Action ChallengeAction `json:"action"`
+	// Nonce is random, and identifies the challenge when it's answered
+	Nonce [32]byte `json:"nonce"`
+	// Expiry is the unix time, in seconds, after which the challenge can't be answered
+	Expiry uint64 `json:"expiry"`
+	// Session is the key the connection the challenge was issued on was authenticated with over noise, so
+	// the challenge can only be answered on a connection with the same key. It's all zeroes if the
+	// connection wasn't authenticated.
+	Session [33]byte `json:"session"`
+}
+
+// SigHash is the hash that gets signed to answer the challenge, the sha3 hash of the serialized challenge
+func (c *Challenge) SigHash() (hash []byte) {
+	sigHash := sha3.Sum256(c.Serialize())
+	hash = sigHash[:]
+	return
+}
+
+// Serialize serializes the challenge in the canonical encoding: [1 byte action] [32 byte nonce]
+// [8 byte expiry] [33 byte session]
+func (c *Challenge) Serialize() (buf []byte) {
+	enc := NewEncoder(ChallengeMessage)
+	enc.WriteUint8(uint8(c.Action))
+	enc.WriteFixed(c.Nonce[:])
+	enc.WriteUint64(c.Expiry)
+	enc.WriteFixed(c.Session[:])
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a challenge that was serialized with Serialize
+func (c *Challenge) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, ChallengeMessage)
+	c.Action = ChallengeAction(dec.ReadUint8())
+	dec.ReadFixed(c.Nonce[:])
+	c.Expiry = dec.ReadUint64()
+	dec.ReadFixed(c.Session[:])
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing challenge: %s", err)
+		return
+	}
+	return
+}
@@ -1,8 +1,6 @@
 package match
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
 )
 
@@ -13,44 +11,36 @@ type CommitResponse struct {
 	PuzzleAnswerReveal SolutionOrder `json:"puzzleanswer"`
 }
 
-// Serialize uses gob encoding to turn the commit response into bytes.
+// Serialize turns the commit response into bytes with the canonical
+// encoding: [65 byte sig] [solution order]
 func (cr *CommitResponse) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
-
-	// register commit response interface
-	gob.Register(CommitResponse{})
-
-	// create a new encoder writing to the buffer
-	enc := gob.NewEncoder(&b)
-
-	// encode the commit response in the buffer
-	if err = enc.Encode(cr); err != nil {
+	var answerBytes []byte
+	if answerBytes, err = cr.PuzzleAnswerReveal.Serialize(); err != nil {
 		err = fmt.Errorf("Error encoding commit response: %s", err)
 		return
 	}
 
-	// Get the bytes from the buffer
-	raw = b.Bytes()
+	enc := NewEncoder(CommitResponseMessage)
+	enc.WriteFixed(cr.CommResponseSig[:])
+	enc.WriteBytes(answerBytes)
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize turns the commit response from bytes into a usable
 // struct.
 func (cr *CommitResponse) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
-
-	// register CommitResponse
-	gob.Register(CommitResponse{})
-
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
-
-	// decode the commit response in the buffer
-	if err = dec.Decode(cr); err != nil {
+	dec := NewDecoder(raw, CommitResponseMessage)
+	dec.ReadFixed(cr.CommResponseSig[:])
+	answerBytes := dec.ReadBytes()
+	if err = dec.Finish(); err != nil {
 		err = fmt.Errorf("Error decoding commitresponse: %s", err)
 		return
 	}
 
+	if err = cr.PuzzleAnswerReveal.Deserialize(answerBytes); err != nil {
+		err = fmt.Errorf("Error decoding commitresponse: %s", err)
+		return
+	}
 	return
 }
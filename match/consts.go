@@ -5,9 +5,10 @@ import (
 	"fmt"
 
 	"github.com/mit-dci/lit/coinparam"
-	"github.com/mit-dci/opencx/chainutils"
 )
 
+// These are the IDs of the assets in the default asset registry. Other assets can be added with an asset
+// config, see ParseAssetConfig.
 const (
 	// BTC is a constant used to represent a BTC token
 	BTC Asset = 0x00
@@ -37,75 +38,50 @@ func largeAssetList() []Asset {
 	return []Asset{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a}
 }
 
-// AssetFromCoinParam gets a byte representation of an asset from a coinparam
+// AssetFromCoinParam gets a byte representation of an asset from a coinparam, using the asset registry
 func AssetFromCoinParam(cpm *coinparam.Params) (a Asset, err error) {
-
-	// create map for that O(1) access
-	assetMap := map[*coinparam.Params]Asset{
-		&coinparam.BitcoinParams:  BTC,
-		&coinparam.VertcoinParams: VTC,
-		// &coinparam.LitecoinParams: LTC,
-		&coinparam.TestNet3Params:         BTCTest,
-		&coinparam.VertcoinTestNetParams:  VTCTest,
-		&coinparam.LiteCoinTestNet4Params: LTCTest,
-		&coinparam.RegressionNetParams:    BTCReg,
-		&coinparam.VertcoinRegTestParams:  VTCReg,
-		&coinparam.LiteRegNetParams:       LTCReg,
-	}
-
-	// grab from map
-	var found bool
-	if a, found = assetMap[cpm]; !found {
+	assetRegistryMtx.RLock()
+	info, found := assetRegistry.byParam[cpm]
+	assetRegistryMtx.RUnlock()
+	if !found {
 		err = fmt.Errorf("Could not get an asset for that coin param")
 		return
 	}
 
+	a = info.ID
 	return
 }
 
-// CoinParamFromAsset is the reverse of AssetFromCoinParam. TODO: change the coinparam so it has an inherent ID anyways?
+// CoinParamFromAsset is the reverse of AssetFromCoinParam
 func (a Asset) CoinParamFromAsset() (coinType *coinparam.Params, err error) {
-	// create map for that O(1) access
-	assetMap := map[Asset]*coinparam.Params{
-		BTC: &coinparam.BitcoinParams,
-		VTC: &coinparam.VertcoinParams,
-		// LTC: &coinparam.LitecoinParams,
-		BTCTest: &coinparam.TestNet3Params,
-		VTCTest: &coinparam.VertcoinTestNetParams,
-		LTCTest: &coinparam.LiteCoinTestNet4Params,
-		BTCReg:  &coinparam.RegressionNetParams,
-		LTCReg:  &coinparam.LiteRegNetParams,
-		VTCReg:  &coinparam.VertcoinRegTestParams,
-	}
-
-	// grab from map
-	var found bool
-	if coinType, found = assetMap[a]; !found {
+	assetRegistryMtx.RLock()
+	info, found := assetRegistry.assets[a]
+	assetRegistryMtx.RUnlock()
+	if !found {
 		err = fmt.Errorf("Could not get a coin param for that asset")
 		return
 	}
 
+	coinType = info.Params
 	return
-
 }
 
-// AssetFromString returns an asset from a string
+// AssetFromString returns an asset from the name of its coin params, like "regtest"
 func AssetFromString(name string) (a Asset, err error) {
-	// we do this to enforce consistency sorta. I don't want this asset thing and the coin params that we support to be separated. They are right now, though.
-	// the main reason they're separated is that I want the coin params to have their own unique byte so the client only sends a byte to indicate which asset
-	// they want. Like an asset ID. TODO: Change this to HDCoinType, or magic bytes in the future. We need an asset id.
-	var cpm *coinparam.Params
-	if cpm, err = chainutils.GetParamFromName(name); err != nil {
-		return
-	}
-
-	if a, err = AssetFromCoinParam(cpm); err != nil {
+	assetRegistryMtx.RLock()
+	info, found := assetRegistry.byCoin[name]
+	assetRegistryMtx.RUnlock()
+	if !found {
+		err = fmt.Errorf("Coin not found when trying to get from name, maybe it's not supported yet")
 		return
 	}
 
+	a = info.ID
 	return
 }
 
+// String returns the name of the asset's coin params, which is what pairs and databases use. The name that
+// users are shown is in the asset's Info.
 func (a Asset) String() string {
 	var err error
 	var coinType *coinparam.Params
@@ -0,0 +1,82 @@
+package match
+
+import (
+	"fmt"
+)
+
+// DepthLevel is every order on one side of the book at one price, added up so it doesn't show whose
+// orders they are
+type DepthLevel struct {
+	Price Price `json:"price"`
+	// AmountHave and AmountWant are the totals of what the orders at this price have and want
+	AmountHave uint64 `json:"amounthave"`
+	AmountWant uint64 `json:"amountwant"`
+	// Orders is how many orders are at this price
+	Orders uint64 `json:"orders"`
+}
+
+// Depth is the orderbook for a pair added up by price, with the best price on each side first
+type Depth struct {
+	Pair  Pair          `json:"pair"`
+	Buys  []*DepthLevel `json:"buys"`
+	Sells []*DepthLevel `json:"sells"`
+}
+
+// NewDepth adds up the orders in a book by price, keeping at most levels prices on each side. The best
+// prices are the ones that would be matched first, so the levels are in the same order as the orders are
+// in PrioritizeOrderbookPTP.
+func NewDepth(pair Pair, book map[Price][]*LimitOrderIDPair, levels uint64) (depth *Depth, err error) {
+	var buyOrders []*LimitOrderIDPair
+	var sellOrders []*LimitOrderIDPair
+	if buyOrders, sellOrders, err = PrioritizeOrderbookPTP(book); err != nil {
+		err = fmt.Errorf("Error prioritizing orders for NewDepth: %s", err)
+		return
+	}
+
+	depth = &Depth{
+		Pair:  pair,
+		Buys:  depthLevels(buyOrders, levels),
+		Sells: depthLevels(sellOrders, levels),
+	}
+	return
+}
+
+// depthLevels adds up prioritized orders from one side of the book into at most levels price levels
+func depthLevels(orders []*LimitOrderIDPair, levels uint64) (depthLevels []*DepthLevel) {
+	var currLevel *DepthLevel
+	for _, order := range orders {
+		if currLevel == nil || order.Price.Cmp(&currLevel.Price) != 0 {
+			if uint64(len(depthLevels)) == levels {
+				return
+			}
+
+			currLevel = &DepthLevel{Price: order.Price}
+			depthLevels = append(depthLevels, currLevel)
+		}
+
+		currLevel.AmountHave += order.Order.AmountHave
+		currLevel.AmountWant += order.Order.AmountWant
+		currLevel.Orders++
+	}
+	return
+}
+
+// BestPrice returns the price of the first level on a side of the depth, which is the price that would be
+// matched first.
+func (d *Depth) BestPrice(side Side) (best *Price, err error) {
+	var sideLevels []*DepthLevel
+	if side == Buy {
+		sideLevels = d.Buys
+	} else {
+		sideLevels = d.Sells
+	}
+
+	if len(sideLevels) == 0 {
+		err = fmt.Errorf("There are no %s orders in the book", side.String())
+		return
+	}
+
+	best = new(Price)
+	*best = sideLevels[0].Price
+	return
+}
@@ -0,0 +1,118 @@
+package match
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDepth(t *testing.T) {
+	var err error
+
+	pair := Pair{AssetWant: BTCTest, AssetHave: LTCTest}
+	cheap := Price{AmountWant: 1, AmountHave: 1}
+	mid := Price{AmountWant: 2, AmountHave: 1}
+	high := Price{AmountWant: 3, AmountHave: 1}
+
+	book := make(map[Price][]*LimitOrderIDPair)
+	addOrder := func(side Side, price Price, amountHave uint64, amountWant uint64) {
+		idPair := &LimitOrderIDPair{
+			Timestamp: time.Now(),
+			Price:     price,
+			Order: &LimitOrder{
+				Pubkey:      [33]byte{0x02, byte(len(book))},
+				Side:        side,
+				TradingPair: pair,
+				AmountHave:  amountHave,
+				AmountWant:  amountWant,
+			},
+		}
+		book[price] = append(book[price], idPair)
+	}
+
+	addOrder(Buy, cheap, 100, 100)
+	addOrder(Buy, cheap, 50, 50)
+	addOrder(Buy, mid, 10, 20)
+	addOrder(Sell, high, 30, 10)
+	addOrder(Sell, mid, 40, 20)
+	addOrder(Sell, mid, 20, 10)
+	addOrder(Sell, cheap, 5, 5)
+
+	var depth *Depth
+	if depth, err = NewDepth(pair, book, 2); err != nil {
+		t.Errorf("Error getting depth for TestNewDepth: %s", err)
+		return
+	}
+
+	if depth.Pair != pair {
+		t.Errorf("Depth pair should have been %s but was %s", pair.String(), depth.Pair.String())
+		return
+	}
+
+	// The levels should be in the order they would be matched in
+	expectedBuys := []DepthLevel{
+		{Price: cheap, AmountHave: 150, AmountWant: 150, Orders: 2},
+		{Price: mid, AmountHave: 10, AmountWant: 20, Orders: 1},
+	}
+	expectedSells := []DepthLevel{
+		{Price: high, AmountHave: 30, AmountWant: 10, Orders: 1},
+		{Price: mid, AmountHave: 60, AmountWant: 30, Orders: 2},
+	}
+
+	if len(depth.Buys) != len(expectedBuys) {
+		t.Errorf("There should have been %d buy levels but there were %d", len(expectedBuys), len(depth.Buys))
+		return
+	}
+	for i, level := range depth.Buys {
+		if *level != expectedBuys[i] {
+			t.Errorf("Buy level %d should have been %+v but was %+v", i, expectedBuys[i], *level)
+			return
+		}
+	}
+
+	// The third sell price should be left out
+	if len(depth.Sells) != len(expectedSells) {
+		t.Errorf("There should have been %d sell levels but there were %d", len(expectedSells), len(depth.Sells))
+		return
+	}
+	for i, level := range depth.Sells {
+		if *level != expectedSells[i] {
+			t.Errorf("Sell level %d should have been %+v but was %+v", i, expectedSells[i], *level)
+			return
+		}
+	}
+
+	var best *Price
+	if best, err = depth.BestPrice(Sell); err != nil {
+		t.Errorf("Error getting best sell price from depth: %s", err)
+		return
+	}
+
+	if *best != high {
+		t.Errorf("Best sell price should have been %s but was %s", high.String(), best.String())
+		return
+	}
+
+	return
+}
+
+func TestNewDepthEmptySide(t *testing.T) {
+	var err error
+
+	var depth *Depth
+	if depth, err = NewDepth(Pair{AssetWant: BTCTest, AssetHave: LTCTest}, make(map[Price][]*LimitOrderIDPair), 10); err != nil {
+		t.Errorf("Error getting depth for TestNewDepthEmptySide: %s", err)
+		return
+	}
+
+	if len(depth.Buys) != 0 || len(depth.Sells) != 0 {
+		t.Errorf("Depth of an empty book should be empty")
+		return
+	}
+
+	if _, err = depth.BestPrice(Buy); err == nil {
+		t.Errorf("Best price of an empty side should have returned an error")
+		return
+	}
+
+	return
+}
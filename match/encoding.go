@@ -0,0 +1,396 @@
+package match
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// EncodingVersion is the version of the encoding used for every message that is hashed or signed. It's
+// the first byte of every encoded message, so the encoding can change without old and new messages
+// being mistaken for each other.
+const EncodingVersion uint8 = 1
+
+// MessageType is the second byte of every encoded message. Each kind of message has its own type, so the
+// encoding of one kind of message is never the encoding of another, and a signature for one kind of
+// message can't be used as a signature for another.
+type MessageType uint8
+
+// These values are part of the encoding, so they can't be changed or reused
+const (
+	LimitOrderMessage             MessageType = 1
+	AuctionOrderMessage           MessageType = 2
+	AuctionOrderSignableMessage   MessageType = 3
+	OrderEnvelopeMessage          MessageType = 4
+	StopOrderMessage              MessageType = 5
+	WithdrawalMessage             MessageType = 6
+	SolutionOrderMessage          MessageType = 7
+	CommitResponseMessage         MessageType = 8
+	EncryptedSolutionOrderMessage MessageType = 9
+	SignedEncSolOrderMessage      MessageType = 10
+	TranscriptMessage             MessageType = 11
+	EncryptedAuctionOrderMessage  MessageType = 12
+	ChallengeMessage              MessageType = 13
+	CancelRequestMessage          MessageType = 14
+	ReplaceRequestMessage         MessageType = 15
+	SettlementExecutionMessage    MessageType = 16
+	OrderExecutionMessage         MessageType = 17
+	LimitOrderIDPairMessage       MessageType = 18
+	TradeMessage                  MessageType = 19
+	StopOrderIDPairMessage        MessageType = 20
+	AuctionOrderIDPairMessage     MessageType = 21
+	PriceExecutionMessage         MessageType = 22
+	JournalEntryMessage           MessageType = 23
+)
+
+// String returns the string representation of a message type
+func (mt MessageType) String() string {
+	switch mt {
+	case LimitOrderMessage:
+		return "limitorder"
+	case AuctionOrderMessage:
+		return "auctionorder"
+	case AuctionOrderSignableMessage:
+		return "auctionordersignable"
+	case OrderEnvelopeMessage:
+		return "orderenvelope"
+	case StopOrderMessage:
+		return "stoporder"
+	case WithdrawalMessage:
+		return "withdrawal"
+	case SolutionOrderMessage:
+		return "solutionorder"
+	case CommitResponseMessage:
+		return "commitresponse"
+	case EncryptedSolutionOrderMessage:
+		return "encryptedsolutionorder"
+	case SignedEncSolOrderMessage:
+		return "signedencsolorder"
+	case TranscriptMessage:
+		return "transcript"
+	case EncryptedAuctionOrderMessage:
+		return "encryptedauctionorder"
+	case ChallengeMessage:
+		return "challenge"
+	case CancelRequestMessage:
+		return "cancelrequest"
+	case ReplaceRequestMessage:
+		return "replacerequest"
+	case SettlementExecutionMessage:
+		return "settlementexecution"
+	case OrderExecutionMessage:
+		return "orderexecution"
+	case LimitOrderIDPairMessage:
+		return "limitorderidpair"
+	case TradeMessage:
+		return "trade"
+	case StopOrderIDPairMessage:
+		return "stoporderidpair"
+	case AuctionOrderIDPairMessage:
+		return "auctionorderidpair"
+	case PriceExecutionMessage:
+		return "priceexecution"
+	case JournalEntryMessage:
+		return "journalentry"
+	}
+	return "unknown"
+}
+
+// Encoder writes a message in the canonical encoding:
+//   - Every message starts with [1 byte version] [1 byte message type].
+//   - Integers are fixed width and little endian. Enums and bools are 1 byte, and bools are 0 or 1.
+//   - Fixed length byte arrays, like pubkeys and IDs, are written as they are.
+//   - Variable length byte strings and strings are [4 byte length] [bytes].
+//   - Big integers are non-negative, and written as a byte string of their big endian bytes with no
+//     leading zeros, so zero is empty.
+//   - Messages inside of messages are written as a byte string of their own encoding.
+//   - Lists are [4 byte count] followed by each item, and optional fields are [1 byte present] [field].
+//   - Times are [8 byte unix seconds] [4 byte nanoseconds], without a time zone.
+//
+// Every field has exactly one encoding, so the same message always encodes to the same bytes. Packages
+// outside of match, like the journal, use it too, with their message types listed with the others so
+// they're never mistaken for each other.
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder creates an encoder, writing the version and message type
+func NewEncoder(msgType MessageType) (enc *Encoder) {
+	enc = &Encoder{
+		buf: []byte{EncodingVersion, uint8(msgType)},
+	}
+	return
+}
+
+// WriteUint8 writes a 1 byte integer or enum
+func (enc *Encoder) WriteUint8(v uint8) {
+	enc.buf = append(enc.buf, v)
+}
+
+// WriteBool writes a bool as 0 or 1
+func (enc *Encoder) WriteBool(v bool) {
+	if v {
+		enc.WriteUint8(1)
+		return
+	}
+	enc.WriteUint8(0)
+}
+
+// WriteUint32 writes a 4 byte integer, like the count of a list
+func (enc *Encoder) WriteUint32(v uint32) {
+	var vBytes [4]byte
+	binary.LittleEndian.PutUint32(vBytes[:], v)
+	enc.buf = append(enc.buf, vBytes[:]...)
+}
+
+// WriteUint64 writes an 8 byte integer
+func (enc *Encoder) WriteUint64(v uint64) {
+	var vBytes [8]byte
+	binary.LittleEndian.PutUint64(vBytes[:], v)
+	enc.buf = append(enc.buf, vBytes[:]...)
+}
+
+// WriteFixed writes bytes that have a fixed length, without a length
+func (enc *Encoder) WriteFixed(b []byte) {
+	enc.buf = append(enc.buf, b...)
+}
+
+// WriteBytes writes a length and then the bytes, like a message inside of this one
+func (enc *Encoder) WriteBytes(b []byte) {
+	enc.WriteUint32(uint32(len(b)))
+	enc.buf = append(enc.buf, b...)
+}
+
+// WriteString writes a length and then the string
+func (enc *Encoder) WriteString(s string) {
+	enc.WriteBytes([]byte(s))
+}
+
+// WriteBigInt writes a non-negative big integer. Nil is written as zero.
+func (enc *Encoder) WriteBigInt(n *big.Int) (err error) {
+	if n == nil {
+		enc.WriteBytes(nil)
+		return
+	}
+	if n.Sign() < 0 {
+		err = fmt.Errorf("Cannot encode negative integer %s", n.String())
+		return
+	}
+	enc.WriteBytes(n.Bytes())
+	return
+}
+
+// WriteSide writes a side as a bool
+func (enc *Encoder) WriteSide(s Side) {
+	enc.WriteBool(bool(s))
+}
+
+// WritePair writes a pair
+func (enc *Encoder) WritePair(p Pair) {
+	enc.WriteFixed(p.Serialize())
+}
+
+// WritePrice writes a price
+func (enc *Encoder) WritePrice(p Price) {
+	enc.WriteUint64(p.AmountWant)
+	enc.WriteUint64(p.AmountHave)
+}
+
+// WriteTime writes a time without its time zone
+func (enc *Encoder) WriteTime(t time.Time) {
+	enc.WriteUint64(uint64(t.Unix()))
+	enc.WriteUint32(uint32(t.Nanosecond()))
+}
+
+// Bytes returns the encoded message
+func (enc *Encoder) Bytes() []byte {
+	return enc.buf
+}
+
+// Decoder reads a message in the canonical encoding. The first error is kept, and everything read after
+// it is the zero value, so a whole message can be read before checking the error with Finish.
+type Decoder struct {
+	data []byte
+	err  error
+}
+
+// NewDecoder creates a decoder for data, checking the version and message type
+func NewDecoder(data []byte, msgType MessageType) (dec *Decoder) {
+	dec = &Decoder{data: data}
+	if len(data) < 2 {
+		dec.err = fmt.Errorf("Message is %d bytes, too short to have a version and type", len(data))
+		return
+	}
+	if data[0] != EncodingVersion {
+		dec.err = fmt.Errorf("Message has encoding version %d, only version %d can be decoded", data[0], EncodingVersion)
+		return
+	}
+	if MessageType(data[1]) != msgType {
+		dec.err = fmt.Errorf("Message is a %s, not a %s", MessageType(data[1]).String(), msgType.String())
+		return
+	}
+	dec.data = data[2:]
+	return
+}
+
+// next returns the next n bytes, or nil if there aren't n bytes left
+func (dec *Decoder) next(n uint64) (b []byte) {
+	if dec.err != nil {
+		return
+	}
+	if uint64(len(dec.data)) < n {
+		dec.err = fmt.Errorf("Message ended early, needed %d more bytes but only %d were left", n, len(dec.data))
+		return
+	}
+	b = dec.data[:n]
+	dec.data = dec.data[n:]
+	return
+}
+
+// ReadUint8 reads a 1 byte integer or enum
+func (dec *Decoder) ReadUint8() (v uint8) {
+	if b := dec.next(1); b != nil {
+		v = b[0]
+	}
+	return
+}
+
+// ReadBool reads a bool, making sure it's 0 or 1
+func (dec *Decoder) ReadBool() (v bool) {
+	switch b := dec.ReadUint8(); b {
+	case 0:
+	case 1:
+		v = true
+	default:
+		if dec.err == nil {
+			dec.err = fmt.Errorf("Bool must be encoded as 0 or 1, not %d", b)
+		}
+	}
+	return
+}
+
+// ReadUint32 reads a 4 byte integer
+func (dec *Decoder) ReadUint32() (v uint32) {
+	if b := dec.next(4); b != nil {
+		v = binary.LittleEndian.Uint32(b)
+	}
+	return
+}
+
+// ReadUint64 reads an 8 byte integer
+func (dec *Decoder) ReadUint64() (v uint64) {
+	if b := dec.next(8); b != nil {
+		v = binary.LittleEndian.Uint64(b)
+	}
+	return
+}
+
+// ReadFixed reads len(dst) bytes into dst
+func (dec *Decoder) ReadFixed(dst []byte) {
+	if b := dec.next(uint64(len(dst))); b != nil {
+		copy(dst, b)
+	}
+}
+
+// ReadBytes reads a length and then a copy of the bytes. Empty byte strings are read as nil.
+func (dec *Decoder) ReadBytes() (v []byte) {
+	length := dec.ReadUint32()
+	if b := dec.next(uint64(length)); len(b) != 0 {
+		v = make([]byte, len(b))
+		copy(v, b)
+	}
+	return
+}
+
+// ReadString reads a length and then the string
+func (dec *Decoder) ReadString() (v string) {
+	v = string(dec.ReadBytes())
+	return
+}
+
+// ReadBigInt reads a big integer, making sure it has no leading zeros
+func (dec *Decoder) ReadBigInt() (n *big.Int) {
+	b := dec.ReadBytes()
+	if len(b) != 0 && b[0] == 0 {
+		if dec.err == nil {
+			dec.err = fmt.Errorf("Integer cannot be encoded with leading zeros")
+		}
+		return
+	}
+	n = new(big.Int).SetBytes(b)
+	return
+}
+
+// ReadSide reads a side
+func (dec *Decoder) ReadSide() (s Side) {
+	s = Side(dec.ReadBool())
+	return
+}
+
+// ReadPair reads a pair
+func (dec *Decoder) ReadPair() (p Pair) {
+	var pairBytes [2]byte
+	dec.ReadFixed(pairBytes[:])
+	if err := p.Deserialize(pairBytes[:]); err != nil && dec.err == nil {
+		dec.err = err
+	}
+	return
+}
+
+// ReadPrice reads a price
+func (dec *Decoder) ReadPrice() (p Price) {
+	p.AmountWant = dec.ReadUint64()
+	p.AmountHave = dec.ReadUint64()
+	return
+}
+
+// ReadTime reads a time, making sure the nanoseconds are less than a second
+func (dec *Decoder) ReadTime() (t time.Time) {
+	seconds := int64(dec.ReadUint64())
+	nanoseconds := dec.ReadUint32()
+	if nanoseconds >= uint32(time.Second) {
+		dec.SetErr(fmt.Errorf("Time cannot have %d nanoseconds, it must be less than a second", nanoseconds))
+		return
+	}
+	if dec.err != nil {
+		return
+	}
+	t = time.Unix(seconds, int64(nanoseconds))
+	return
+}
+
+// ReadCount reads the count of a list. Each item is at least minItemSize bytes, so counts that couldn't
+// fit in what's left of the message are rejected before anything is allocated for them.
+func (dec *Decoder) ReadCount(minItemSize uint64) (count int) {
+	n := dec.ReadUint32()
+	if dec.err != nil {
+		return
+	}
+	if uint64(n)*minItemSize > uint64(len(dec.data)) {
+		dec.err = fmt.Errorf("List of %d items cannot fit in the %d bytes left in the message", n, len(dec.data))
+		return
+	}
+	count = int(n)
+	return
+}
+
+// SetErr sets the error if there hasn't been one yet, for errors from decoding messages inside of this one
+func (dec *Decoder) SetErr(err error) {
+	if dec.err == nil {
+		dec.err = err
+	}
+}
+
+// Finish returns the first error from decoding, or an error if there are bytes left over
+func (dec *Decoder) Finish() (err error) {
+	if dec.err != nil {
+		err = dec.err
+		return
+	}
+	if len(dec.data) != 0 {
+		err = fmt.Errorf("Message has %d extra bytes at the end", len(dec.data))
+		return
+	}
+	return
+}
@@ -0,0 +1,296 @@
+package match
+
+import (
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/opencx/crypto/rsw"
+)
+
+// canonicalMessage is anything with a canonical encoding
+type canonicalMessage interface {
+	Deserialize(data []byte) error
+}
+
+// serializeMessage serializes any message with a canonical encoding, since some Serialize methods can't
+// return an error
+func serializeMessage(msg canonicalMessage) (buf []byte, err error) {
+	switch m := msg.(type) {
+	case *AuctionOrder:
+		buf = m.Serialize()
+	case *OrderEnvelope:
+		buf = m.Serialize()
+	case *Withdrawal:
+		buf = m.Serialize()
+	case *Challenge:
+		buf = m.Serialize()
+	case *CancelRequest:
+		buf = m.Serialize()
+	case *ReplaceRequest:
+		buf = m.Serialize()
+	case *SettlementExecution:
+		buf = m.Serialize()
+	case *OrderExecution:
+		buf = m.Serialize()
+	case *Trade:
+		buf = m.Serialize()
+	case *AuctionOrderIDPair:
+		buf = m.Serialize()
+	case *PriceExecution:
+		buf = m.Serialize()
+	case interface{ Serialize() ([]byte, error) }:
+		buf, err = m.Serialize()
+	}
+	return
+}
+
+// repeatedBytes is n copies of b, for filling in fixed length fields in test messages
+func repeatedBytes(b byte, n int) []byte {
+	return []byte(strings.Repeat(string([]byte{b}), n))
+}
+
+var (
+	vectorPubkey   [33]byte
+	vectorExchange [33]byte
+	vectorID       [32]byte
+	vectorSig      = repeatedBytes(0x5a, 65)
+
+	vectorPair = Pair{AssetWant: Asset(0x01), AssetHave: Asset(0x02)}
+
+	vectorLimitOrder = &LimitOrder{
+		Side:        Buy,
+		TradingPair: vectorPair,
+		AmountHave:  100000000,
+		AmountWant:  50000,
+		TimeInForce: ImmediateOrCancel,
+		Expiry:      1600000000,
+		SelfTrade:   CancelOldest,
+	}
+
+	vectorAuctionOrder = &AuctionOrder{
+		Side:        Sell,
+		TradingPair: vectorPair,
+		AmountHave:  3000,
+		AmountWant:  4000,
+		Nonce:       [2]byte{0xbe, 0xef},
+		Signature:   vectorSig,
+	}
+
+	vectorEnvelope = &OrderEnvelope{
+		Kind:   LimitOrderKind,
+		Pair:   vectorPair,
+		Nonce:  7,
+		Expiry: 1600003600,
+	}
+
+	vectorPuzzle = rsw.PuzzleRSW{
+		N:  big.NewInt(3233),
+		A:  big.NewInt(2),
+		T:  big.NewInt(10000),
+		CK: big.NewInt(0xabcdef),
+	}
+
+	vectorSolution = SolutionOrder{
+		P: big.NewInt(61),
+		Q: big.NewInt(53),
+	}
+
+	vectorEncSolOrder = EncryptedSolutionOrder{
+		OrderCiphertext: []byte{0xc1, 0xc2, 0xc3},
+		OrderPuzzle:     vectorPuzzle,
+		IntendedPair:    vectorPair,
+	}
+)
+
+func init() {
+	vectorPubkey[0] = 0x02
+	copy(vectorPubkey[1:], repeatedBytes(0x11, 32))
+	vectorExchange[0] = 0x03
+	copy(vectorExchange[1:], repeatedBytes(0x22, 32))
+	copy(vectorID[:], repeatedBytes(0x33, 32))
+
+	vectorLimitOrder.Pubkey = vectorPubkey
+	vectorAuctionOrder.Pubkey = vectorPubkey
+	vectorAuctionOrder.AuctionID = vectorID
+	vectorEnvelope.Exchange = vectorExchange
+	vectorEnvelope.OrderHash = vectorID
+	vectorEncSolOrder.IntendedAuction = vectorID
+}
+
+// encodingVectors are the golden vectors for the canonical encoding. These can't change without changing
+// EncodingVersion, since other clients sign messages encoded like this.
+func encodingVectors() []struct {
+	name   string
+	msg    canonicalMessage
+	golden string
+} {
+	auctionWithEnvelope := *vectorAuctionOrder
+	auctionWithEnvelope.Envelope = &OrderEnvelope{
+		Kind:      AuctionOrderKind,
+		Exchange:  vectorExchange,
+		Pair:      vectorPair,
+		Nonce:     8,
+		Expiry:    1600003600,
+		OrderHash: vectorID,
+	}
+
+	vectorTime := time.Unix(1600000000, 5)
+	vectorOrderID := OrderID(vectorID)
+	vectorPrice := Price{AmountWant: 1, AmountHave: 2}
+	vectorStopOrder := &StopOrder{Order: *vectorLimitOrder, StopPrice: Price{AmountWant: 2, AmountHave: 3}, Market: true, Slippage: 50}
+
+	return []struct {
+		name   string
+		msg    canonicalMessage
+		golden string
+	}{
+		{name: "limit order", msg: vectorLimitOrder, golden: "010102111111111111111111111111111111111111111111111111111111111111111101010200e1f5050000000050c30000000000000100105e5f0000000001"},
+		{name: "auction order", msg: vectorAuctionOrder, golden: "0102021111111111111111111111111111111111111111111111111111111111111111000102b80b000000000000a00f0000000000003333333333333333333333333333333333333333333333333333333333333333beef410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a00"},
+		{name: "auction order with envelope", msg: &auctionWithEnvelope, golden: "0102021111111111111111111111111111111111111111111111111111111111111111000102b80b000000000000a00f0000000000003333333333333333333333333333333333333333333333333333333333333333beef410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a015600000001040103222222222222222222222222222222222222222222222222222222222222222201020800000000000000101e5e5f000000003333333333333333333333333333333333333333333333333333333333333333"},
+		{name: "order envelope", msg: vectorEnvelope, golden: "01040003222222222222222222222222222222222222222222222222222222222222222201020700000000000000101e5e5f000000003333333333333333333333333333333333333333333333333333333333333333"},
+		{name: "stop order", msg: &StopOrder{Order: *vectorLimitOrder, StopPrice: Price{AmountWant: 2, AmountHave: 3}, Market: true, Slippage: 50}, golden: "010540000000010102111111111111111111111111111111111111111111111111111111111111111101010200e1f5050000000050c30000000000000100105e5f000000000102000000000000000300000000000000013200000000000000"},
+		{name: "withdrawal", msg: &Withdrawal{Asset: Asset(0x01), Amount: 12345, Address: "bcrt1qexample", Lightning: true}, golden: "01060139300000000000000d0000006263727431716578616d706c6501"},
+		{name: "solution order", msg: &vectorSolution, golden: "0107010000003d0100000035"},
+		{name: "commit response", msg: &CommitResponse{PuzzleAnswerReveal: vectorSolution}, golden: "010800000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000c0000000107010000003d0100000035"},
+		{name: "encrypted solution order", msg: &vectorEncSolOrder, golden: "010903000000c1c2c3020000000ca1010000000202000000271003000000abcdef33333333333333333333333333333333333333333333333333333333333333330102"},
+		{name: "signed encrypted solution order", msg: &SignedEncSolOrder{EncSolOrder: vectorEncSolOrder, Signature: vectorSig}, golden: "010a43000000010903000000c1c2c3020000000ca1010000000202000000271003000000abcdef33333333333333333333333333333333333333333333333333333333333333330102410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a"},
+		{name: "encrypted auction order", msg: &EncryptedAuctionOrder{OrderCiphertext: []byte{0xc1, 0xc2, 0xc3}, OrderPuzzle: &vectorPuzzle, IntendedAuction: vectorID, IntendedPair: vectorPair}, golden: "010c03000000c1c2c3020000000ca1010000000202000000271003000000abcdef33333333333333333333333333333333333333333333333333333333333333330102"},
+		{name: "challenge", msg: &Challenge{Action: ReadChallenge, Nonce: vectorID, Expiry: 1600000300, Session: vectorExchange}, golden: "010d0133333333333333333333333333333333333333333333333333333333333333332c115e5f00000000032222222222222222222222222222222222222222222222222222222222222222"},
+		{name: "cancel request", msg: &CancelRequest{OrderID: vectorID}, golden: "010e3333333333333333333333333333333333333333333333333333333333333333"},
+		{name: "replace request", msg: &ReplaceRequest{OrderID: vectorID, AmountHave: 7, AmountWant: 8}, golden: "010f333333333333333333333333333333333333333333333333333333333333333307000000000000000800000000000000"},
+		{name: "settlement execution", msg: &SettlementExecution{Pubkey: vectorPubkey, Amount: 12345, Asset: Asset(0x01), Type: Debit}, golden: "011002111111111111111111111111111111111111111111111111111111111111111139300000000000000101"},
+		{name: "order execution", msg: &OrderExecution{OrderID: vectorID, NewAmountWant: 7, NewAmountHave: 8, Filled: true}, golden: "01113333333333333333333333333333333333333333333333333333333333333333070000000000000008000000000000000100"},
+		{name: "limit order ID pair", msg: &LimitOrderIDPair{Timestamp: vectorTime, Price: vectorPrice, OrderID: &vectorOrderID, Order: vectorLimitOrder}, golden: "011200105e5f0000000005000000010000000000000002000000000000000133333333333333333333333333333333333333333333333333333333333333330140000000010102111111111111111111111111111111111111111111111111111111111111111101010200e1f5050000000050c30000000000000100105e5f0000000001"},
+		{name: "trade", msg: &Trade{Pair: vectorPair, Price: vectorPrice, AmountWant: 3, AmountHave: 4, BuyOrderID: vectorID, SellOrderID: vectorID, BuyPubkey: vectorPubkey, SellPubkey: vectorExchange, MakerSide: Sell, Timestamp: vectorTime}, golden: "011301020100000000000000020000000000000003000000000000000400000000000000333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333333330211111111111111111111111111111111111111111111111111111111111111110322222222222222222222222222222222222222222222222222222222222222220000105e5f0000000005000000"},
+		{name: "stop order ID pair", msg: &StopOrderIDPair{Timestamp: vectorTime, OrderID: &vectorOrderID, Order: vectorStopOrder}, golden: "011400105e5f0000000005000000013333333333333333333333333333333333333333333333333333333333333333015f000000010540000000010102111111111111111111111111111111111111111111111111111111111111111101010200e1f5050000000050c30000000000000100105e5f000000000102000000000000000300000000000000013200000000000000"},
+		{name: "auction order ID pair", msg: &AuctionOrderIDPair{OrderID: vectorID, Price: vectorPrice, Order: vectorAuctionOrder}, golden: "0115333333333333333333333333333333333333333333333333333333333333333301000000000000000200000000000000019e0000000102021111111111111111111111111111111111111111111111111111111111111111000102b80b000000000000a00f0000000000003333333333333333333333333333333333333333333333333333333333333333beef410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a00"},
+		{name: "price execution", msg: &PriceExecution{ID: vectorID, Pair: vectorPair, Price: vectorPrice, AmountWant: 3, AmountHave: 4, Timestamp: vectorTime}, golden: "011633333333333333333333333333333333333333333333333333333333333333330102010000000000000002000000000000000300000000000000040000000000000000105e5f0000000005000000"},
+		{
+			name: "transcript",
+			msg: &Transcript{
+				BatchId:       vectorID,
+				BatchIdSig:    vectorSig,
+				PuzzledOrders: []SignedEncSolOrder{{EncSolOrder: vectorEncSolOrder, Signature: vectorSig}},
+				Commitment:    vectorID,
+				CommitSig:     vectorSig,
+				Responses:     []CommitResponse{{PuzzleAnswerReveal: vectorSolution}},
+				Solutions:     []AuctionOrder{*vectorAuctionOrder},
+			},
+			golden: "010b3333333333333333333333333333333333333333333333333333333333333333410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a010000008e000000010a43000000010903000000c1c2c3020000000ca1010000000202000000271003000000abcdef33333333333333333333333333333333333333333333333333333333333333330102410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a3333333333333333333333333333333333333333333333333333333333333333410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a0100000053000000010800000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000c0000000107010000003d0100000035010000009e0000000102021111111111111111111111111111111111111111111111111111111111111111000102b80b000000000000a00f0000000000003333333333333333333333333333333333333333333333333333333333333333beef410000005a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a00",
+		},
+	}
+}
+
+func TestEncodingGoldenVectors(t *testing.T) {
+	var err error
+	for _, vector := range encodingVectors() {
+		var encoded []byte
+		if encoded, err = serializeMessage(vector.msg); err != nil {
+			t.Errorf("Error serializing %s: %s", vector.name, err)
+			return
+		}
+
+		if hex.EncodeToString(encoded) != vector.golden {
+			t.Errorf("Serialized %s does not match golden vector:\nExpected: %s\nActual:   %x", vector.name, vector.golden, encoded)
+			return
+		}
+
+		var golden []byte
+		if golden, err = hex.DecodeString(vector.golden); err != nil {
+			t.Errorf("Golden vector for %s is not hex: %s", vector.name, err)
+			return
+		}
+
+		decoded := reflect.New(reflect.TypeOf(vector.msg).Elem()).Interface().(canonicalMessage)
+		if err = decoded.Deserialize(golden); err != nil {
+			t.Errorf("Error deserializing golden vector for %s: %s", vector.name, err)
+			return
+		}
+
+		if !reflect.DeepEqual(decoded, vector.msg) {
+			t.Errorf("Deserialized %s %+v does not equal original %+v", vector.name, decoded, vector.msg)
+			return
+		}
+	}
+	return
+}
+
+// TestEnvelopeSigHashVector makes sure the hash that gets signed for an envelope doesn't change
+func TestEnvelopeSigHashVector(t *testing.T) {
+	var err error
+
+	var envelope *OrderEnvelope
+	if envelope, err = NewLimitOrderEnvelope(vectorLimitOrder, vectorExchange, 7, 1600003600); err != nil {
+		t.Errorf("Error creating envelope for limit order: %s", err)
+		return
+	}
+
+	expectedOrderHash := "5f215859765737e26551f00ac6d06864997608820bcc7c1da27586de84aa961c"
+	if hex.EncodeToString(envelope.OrderHash[:]) != expectedOrderHash {
+		t.Errorf("Envelope order hash does not match:\nExpected: %s\nActual:   %x", expectedOrderHash, envelope.OrderHash)
+		return
+	}
+
+	expectedSigHash := "fbe96a06345e7b39c88cb6c70bf59ce6e0c4bf1a7eec450539cab55501541f33"
+	if hex.EncodeToString(envelope.SigHash()) != expectedSigHash {
+		t.Errorf("Envelope sighash does not match:\nExpected: %s\nActual:   %x", expectedSigHash, envelope.SigHash())
+		return
+	}
+	return
+}
+
+func TestEncodingRejectsNonCanonical(t *testing.T) {
+	var err error
+
+	var limitBytes []byte
+	if limitBytes, err = vectorLimitOrder.Serialize(); err != nil {
+		t.Errorf("Error serializing limit order: %s", err)
+		return
+	}
+
+	withdrawalBytes := (&Withdrawal{Asset: Asset(0x01), Amount: 12345, Address: "bcrt1qexample", Lightning: true}).Serialize()
+
+	var solutionBytes []byte
+	if solutionBytes, err = vectorSolution.Serialize(); err != nil {
+		t.Errorf("Error serializing solution order: %s", err)
+		return
+	}
+
+	priceExecBytes := (&PriceExecution{ID: vectorID, Pair: vectorPair, Timestamp: time.Unix(1600000000, 5)}).Serialize()
+
+	// change a byte of a copy of the encoding
+	modified := func(buf []byte, i int, b byte) []byte {
+		cpy := append([]byte{}, buf...)
+		cpy[i] = b
+		return cpy
+	}
+
+	var tests = []struct {
+		name string
+		msg  canonicalMessage
+		data []byte
+	}{
+		{name: "empty message", msg: new(LimitOrder), data: nil},
+		{name: "unknown version", msg: new(LimitOrder), data: modified(limitBytes, 0, EncodingVersion+1)},
+		{name: "wrong message type", msg: new(AuctionOrder), data: limitBytes},
+		{name: "truncated message", msg: new(LimitOrder), data: limitBytes[:len(limitBytes)-1]},
+		{name: "extra bytes", msg: new(LimitOrder), data: append(append([]byte{}, limitBytes...), 0x00)},
+		{name: "bool that isn't 0 or 1", msg: new(Withdrawal), data: modified(withdrawalBytes, len(withdrawalBytes)-1, 0x02)},
+		{name: "length longer than message", msg: new(Withdrawal), data: modified(withdrawalBytes, 2+1+8, 0xff)},
+		// P is 61, so its length is 1 and it's the 7th byte. Make it 2 bytes with a leading zero instead.
+		{name: "time with a second of nanoseconds", msg: new(PriceExecution), data: append(append([]byte{}, priceExecBytes[:len(priceExecBytes)-4]...), 0x00, 0xca, 0x9a, 0x3b)},
+		{name: "integer with leading zeros", msg: new(SolutionOrder), data: append(append(modified(solutionBytes[:6], 2, 0x02), 0x00), solutionBytes[6:]...)},
+	}
+
+	for _, tt := range tests {
+		if err = tt.msg.Deserialize(tt.data); err == nil {
+			t.Errorf("Deserializing %s should have failed but didn't", tt.name)
+			return
+		}
+	}
+	return
+}
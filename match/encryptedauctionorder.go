@@ -1,12 +1,9 @@
 package match
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
 
 	"github.com/mit-dci/opencx/crypto"
-	"github.com/mit-dci/opencx/crypto/hashtimelock"
 	"github.com/mit-dci/opencx/crypto/rsw"
 	"github.com/mit-dci/opencx/crypto/timelockencoders"
 )
@@ -44,68 +41,43 @@ func SolveRC5AuctionOrderAsync(e *EncryptedAuctionOrder, puzzleResChan chan *Ord
 	return
 }
 
-// Serialize serializes the encrypted order using gob
+// Serialize serializes the encrypted order with the canonical encoding: [ciphertext] [puzzle N] [puzzle A]
+// [puzzle T] [puzzle CK] [32 byte intended auction] [2 byte intended pair]. This is what gets hashed into the
+// commitment to an auction's orders. Orders are encrypted with RSW puzzles, so that's the only kind of puzzle
+// that can be serialized.
 func (e *EncryptedAuctionOrder) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
-
-	// register the rsw puzzle and hashtimelock puzzle
-	gob.Register(new(rsw.PuzzleRSW))
-
-	// register the hashtimelock (puzzle and timelock are same thing)
-	gob.Register(new(hashtimelock.HashTimelock))
-
-	// register the pair
-	gob.Register(new(Pair))
-
-	// register the puzzle interface
-	gob.RegisterName("puzzle", new(crypto.Puzzle))
-
-	// register the encrypted auction order interface with gob
-	gob.RegisterName("order", new(EncryptedAuctionOrder))
-
-	// create a new encoder writing to our buffer
-	enc := gob.NewEncoder(&b)
-
-	// encode the encrypted auction order in the buffer
-	if err = enc.Encode(e); err != nil {
-		err = fmt.Errorf("Error encoding encrypted auction order :%s", err)
+	pz, ok := e.OrderPuzzle.(*rsw.PuzzleRSW)
+	if !ok || pz == nil {
+		err = fmt.Errorf("Error encoding encrypted auction order: puzzle must be an RSW puzzle, not %T", e.OrderPuzzle)
 		return
 	}
 
-	// Get the bytes finally
-	raw = b.Bytes()
-
+	enc := NewEncoder(EncryptedAuctionOrderMessage)
+	enc.WriteBytes(e.OrderCiphertext)
+	if err = writeRSWPuzzle(enc, pz); err != nil {
+		err = fmt.Errorf("Error encoding encrypted auction order: %s", err)
+		return
+	}
+	enc.WriteFixed(e.IntendedAuction[:])
+	enc.WritePair(e.IntendedPair)
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize deserializes the raw bytes into the encrypted auction order receiver
 func (e *EncryptedAuctionOrder) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
-
-	// register the rsw puzzle and hashtimelock puzzle
-	gob.Register(new(rsw.PuzzleRSW))
-
-	// register the hashtimelock (puzzle and timelock are same thing)
-	gob.Register(new(hashtimelock.HashTimelock))
-
-	// register the pair
-	gob.Register(new(Pair))
-
-	// register the puzzle interface
-	gob.RegisterName("puzzle", new(crypto.Puzzle))
-
-	// register the encrypted auction order interface with gob
-	gob.RegisterName("order", new(EncryptedAuctionOrder))
-
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
-
-	// decode the encrypted auction order in the buffer
-	if err = dec.Decode(e); err != nil {
+	pz := new(rsw.PuzzleRSW)
+
+	dec := NewDecoder(raw, EncryptedAuctionOrderMessage)
+	e.OrderCiphertext = dec.ReadBytes()
+	readRSWPuzzle(dec, pz)
+	dec.ReadFixed(e.IntendedAuction[:])
+	e.IntendedPair = dec.ReadPair()
+	if err = dec.Finish(); err != nil {
 		err = fmt.Errorf("Error decoding encrypted auction order: %s", err)
 		return
 	}
 
+	e.OrderPuzzle = pz
 	return
 }
@@ -1,9 +1,8 @@
 package match
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
+	"math/big"
 
 	"github.com/mit-dci/opencx/crypto/rsw"
 )
@@ -23,88 +22,88 @@ type SignedEncSolOrder struct {
 	Signature   []byte                 `json:"signature"`
 }
 
-// Serialize uses gob encoding to turn the encrypted solution order
-// into bytes.
+// Serialize turns the encrypted solution order into bytes with the
+// canonical encoding: [ciphertext] [puzzle N] [puzzle A] [puzzle T]
+// [puzzle CK] [32 byte intended auction] [2 byte intended pair]. This
+// is what the user signs.
 func (es *EncryptedSolutionOrder) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
-
-	// register SolutionOrder interface
-	gob.Register(EncryptedSolutionOrder{})
-
-	// create a new encoder writing to the buffer
-	enc := gob.NewEncoder(&b)
-
-	// encode the puzzle in the buffer
-	if err = enc.Encode(es); err != nil {
+	enc := NewEncoder(EncryptedSolutionOrderMessage)
+	enc.WriteBytes(es.OrderCiphertext)
+	if err = writeRSWPuzzle(enc, &es.OrderPuzzle); err != nil {
 		err = fmt.Errorf("Error encoding encryptedsolutionorder: %s", err)
 		return
 	}
-
-	// Get the bytes from the buffer
-	raw = b.Bytes()
+	enc.WriteFixed(es.IntendedAuction[:])
+	enc.WritePair(es.IntendedPair)
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize turns the encrypted solution order from bytes into a
 // usable struct.
 func (es *EncryptedSolutionOrder) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
-
-	// register SolutionOrder
-	gob.Register(EncryptedSolutionOrder{})
-
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
-
-	// decode the solutionorder in the buffer
-	if err = dec.Decode(es); err != nil {
+	dec := NewDecoder(raw, EncryptedSolutionOrderMessage)
+	es.OrderCiphertext = dec.ReadBytes()
+	readRSWPuzzle(dec, &es.OrderPuzzle)
+	dec.ReadFixed(es.IntendedAuction[:])
+	es.IntendedPair = dec.ReadPair()
+	if err = dec.Finish(); err != nil {
 		err = fmt.Errorf("Error decoding encryptedsolutionorder: %s", err)
 		return
 	}
-
 	return
 }
 
-// Serialize uses gob encoding to turn the encrypted solution order
-// into bytes.
+// Serialize turns the signed encrypted solution order into bytes with
+// the canonical encoding: [encrypted solution order] [signature]
 func (se *SignedEncSolOrder) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
-
-	// register SolutionOrder interface
-	gob.Register(SignedEncSolOrder{})
-
-	// create a new encoder writing to the buffer
-	enc := gob.NewEncoder(&b)
-
-	// encode the puzzle in the buffer
-	if err = enc.Encode(se); err != nil {
+	var encSolOrderBytes []byte
+	if encSolOrderBytes, err = se.EncSolOrder.Serialize(); err != nil {
 		err = fmt.Errorf("Error encoding encsolorder: %s", err)
 		return
 	}
 
-	// Get the bytes from the buffer
-	raw = b.Bytes()
+	enc := NewEncoder(SignedEncSolOrderMessage)
+	enc.WriteBytes(encSolOrderBytes)
+	enc.WriteBytes(se.Signature)
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize turns the signed encrypted solution order from bytes
 // into a usable struct.
 func (se *SignedEncSolOrder) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
-
-	// register SolutionOrder
-	gob.Register(SignedEncSolOrder{})
-
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
+	dec := NewDecoder(raw, SignedEncSolOrderMessage)
+	encSolOrderBytes := dec.ReadBytes()
+	se.Signature = dec.ReadBytes()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error decoding encsolorder: %s", err)
+		return
+	}
 
-	// decode the solutionorder in the buffer
-	if err = dec.Decode(se); err != nil {
+	if err = se.EncSolOrder.Deserialize(encSolOrderBytes); err != nil {
 		err = fmt.Errorf("Error decoding encsolorder: %s", err)
 		return
 	}
+	return
+}
+
+// writeRSWPuzzle writes the N, A, T, and CK of an RSW puzzle
+func writeRSWPuzzle(enc *Encoder, pz *rsw.PuzzleRSW) (err error) {
+	for _, n := range []*big.Int{pz.N, pz.A, pz.T, pz.CK} {
+		if err = enc.WriteBigInt(n); err != nil {
+			err = fmt.Errorf("Error encoding rsw puzzle: %s", err)
+			return
+		}
+	}
+	return
+}
 
+// readRSWPuzzle reads an RSW puzzle written with writeRSWPuzzle
+func readRSWPuzzle(dec *Decoder, pz *rsw.PuzzleRSW) {
+	pz.N = dec.ReadBigInt()
+	pz.A = dec.ReadBigInt()
+	pz.T = dec.ReadBigInt()
+	pz.CK = dec.ReadBigInt()
 	return
 }
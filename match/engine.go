@@ -6,7 +6,18 @@ package match
 type LimitEngine interface {
 	PlaceLimitOrder(order *LimitOrder) (idRes *LimitOrderIDPair, err error)
 	CancelLimitOrder(id *OrderID) (cancelled *CancelledOrder, cancelSettlement *SettlementExecution, err error)
-	MatchLimitOrders() (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error)
+	// MatchLimitOrders matches the orders in the engine. Whatever is left of immediate-or-cancel and
+	// fill-or-kill orders afterwards is cancelled, and so are orders that self-trade prevention cancels.
+	// The settlement executions refunding them come after the settlements from matching, see MatchLimitBook.
+	// The trades are every buy order and sell order that were matched, without a timestamp.
+	MatchLimitOrders() (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, cancelled []*CancelledOrder, trades []*Trade, err error)
+	// RestoreLimitOrder puts an order that was already placed back into the engine, keeping its ID, price,
+	// timestamp and amounts. This does not match orders, and is used to rebuild the engine from a journal.
+	RestoreLimitOrder(idPair *LimitOrderIDPair) (err error)
+	// ReplaceLimitOrder amends the amounts of an order in the engine, returning the amended order and the
+	// settlement execution that changes how much is reserved for it, see LimitOrderIDPair.Replace. This does
+	// not match orders.
+	ReplaceLimitOrder(id *OrderID, amountHave uint64, amountWant uint64) (replaced *LimitOrderIDPair, reserveChange *SettlementExecution, err error)
 }
 
 // The AuctionEngine is the interface for the internal matching engine. This should be the lowest level
@@ -0,0 +1,388 @@
+package match
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// OrderKind is the kind of order an envelope is for, so a signature for one kind of order can't be used
+// for another
+type OrderKind uint8
+
+const (
+	// LimitOrderKind is the kind of envelope for a LimitOrder
+	LimitOrderKind OrderKind = iota
+	// AuctionOrderKind is the kind of envelope for an AuctionOrder
+	AuctionOrderKind
+	// StopOrderKind is the kind of envelope for a StopOrder
+	StopOrderKind
+	// CancelOrderKind is the kind of envelope for a CancelRequest for a limit order
+	CancelOrderKind
+	// CancelStopOrderKind is the kind of envelope for a CancelRequest for a stop order
+	CancelStopOrderKind
+	// ReplaceOrderKind is the kind of envelope for a ReplaceRequest
+	ReplaceOrderKind
+)
+
+// String returns the string representation of an order kind
+func (k OrderKind) String() string {
+	switch k {
+	case LimitOrderKind:
+		return "limit"
+	case AuctionOrderKind:
+		return "auction"
+	case StopOrderKind:
+		return "stop"
+	case CancelOrderKind:
+		return "cancel"
+	case CancelStopOrderKind:
+		return "cancelstop"
+	case ReplaceOrderKind:
+		return "replace"
+	}
+	return "unknown"
+}
+
+// MaxEnvelopeLifetime is how far in the future the expiry of an envelope can be. Exchanges have to
+// remember every nonce until its envelope expires, so envelopes can't be valid forever.
+const MaxEnvelopeLifetime = 24 * time.Hour
+
+// OrderEnvelope is what users sign to place an order. It commits to the order, and also to the exchange
+// it's for, so it can't be replayed on another exchange, and to a nonce, so the exchange places it at
+// most once. Exchanges remember each nonce a pubkey uses until the envelope expires, and envelopes aren't
+// accepted after they expire, so a nonce only has to be unique among a pubkey's envelopes that haven't
+// expired. The nonce can be used as the client's ID for the order.
+type OrderEnvelope struct {
+	Kind OrderKind `json:"kind"`
+	// Exchange is the exchange's pubkey, the one its noise connections are authenticated with
+	Exchange [33]byte `json:"exchange"`
+	Pair     Pair     `json:"pair"`
+	Nonce    uint64   `json:"nonce"`
+	// Expiry is the unix time, in seconds, after which the envelope can't be used to place the order
+	Expiry uint64 `json:"expiry"`
+	// OrderHash is the sha3 hash of the serialized order, or the serialized cancel or replace request
+	OrderHash [32]byte `json:"orderhash"`
+}
+
+// NewLimitOrderEnvelope creates an envelope for a limit order on an exchange
+func NewLimitOrderEnvelope(order *LimitOrder, exchange [33]byte, nonce uint64, expiry uint64) (envelope *OrderEnvelope, err error) {
+	if order == nil {
+		err = fmt.Errorf("Cannot create envelope for nil order, please enter valid input")
+		return
+	}
+
+	var orderHash [32]byte
+	if orderHash, err = hashLimitOrder(order); err != nil {
+		err = fmt.Errorf("Error hashing order for NewLimitOrderEnvelope: %s", err)
+		return
+	}
+
+	envelope = &OrderEnvelope{
+		Kind:      LimitOrderKind,
+		Exchange:  exchange,
+		Pair:      order.TradingPair,
+		Nonce:     nonce,
+		Expiry:    expiry,
+		OrderHash: orderHash,
+	}
+	return
+}
+
+// NewAuctionOrderEnvelope creates an envelope for an auction order on an exchange
+func NewAuctionOrderEnvelope(order *AuctionOrder, exchange [33]byte, nonce uint64, expiry uint64) (envelope *OrderEnvelope, err error) {
+	if order == nil {
+		err = fmt.Errorf("Cannot create envelope for nil order, please enter valid input")
+		return
+	}
+
+	envelope = &OrderEnvelope{
+		Kind:      AuctionOrderKind,
+		Exchange:  exchange,
+		Pair:      order.TradingPair,
+		Nonce:     nonce,
+		Expiry:    expiry,
+		OrderHash: sha3.Sum256(order.SerializeSignable()),
+	}
+	return
+}
+
+// NewStopOrderEnvelope creates an envelope for a stop order on an exchange
+func NewStopOrderEnvelope(stop *StopOrder, exchange [33]byte, nonce uint64, expiry uint64) (envelope *OrderEnvelope, err error) {
+	if stop == nil {
+		err = fmt.Errorf("Cannot create envelope for nil stop order, please enter valid input")
+		return
+	}
+
+	var orderHash [32]byte
+	if orderHash, err = hashStopOrder(stop); err != nil {
+		err = fmt.Errorf("Error hashing stop order for NewStopOrderEnvelope: %s", err)
+		return
+	}
+
+	envelope = &OrderEnvelope{
+		Kind:      StopOrderKind,
+		Exchange:  exchange,
+		Pair:      stop.Order.TradingPair,
+		Nonce:     nonce,
+		Expiry:    expiry,
+		OrderHash: orderHash,
+	}
+	return
+}
+
+// NewCancelEnvelope creates an envelope for cancelling a limit order, or a stop order if stop is true, on a
+// pair on an exchange
+func NewCancelEnvelope(cancel *CancelRequest, stop bool, pair Pair, exchange [33]byte, nonce uint64, expiry uint64) (envelope *OrderEnvelope, err error) {
+	if cancel == nil {
+		err = fmt.Errorf("Cannot create envelope for nil cancel request, please enter valid input")
+		return
+	}
+
+	kind := CancelOrderKind
+	if stop {
+		kind = CancelStopOrderKind
+	}
+
+	envelope = &OrderEnvelope{
+		Kind:      kind,
+		Exchange:  exchange,
+		Pair:      pair,
+		Nonce:     nonce,
+		Expiry:    expiry,
+		OrderHash: sha3.Sum256(cancel.Serialize()),
+	}
+	return
+}
+
+// NewReplaceEnvelope creates an envelope for amending an order on a pair on an exchange
+func NewReplaceEnvelope(replace *ReplaceRequest, pair Pair, exchange [33]byte, nonce uint64, expiry uint64) (envelope *OrderEnvelope, err error) {
+	if replace == nil {
+		err = fmt.Errorf("Cannot create envelope for nil replace request, please enter valid input")
+		return
+	}
+
+	envelope = &OrderEnvelope{
+		Kind:      ReplaceOrderKind,
+		Exchange:  exchange,
+		Pair:      pair,
+		Nonce:     nonce,
+		Expiry:    expiry,
+		OrderHash: sha3.Sum256(replace.Serialize()),
+	}
+	return
+}
+
+// hashLimitOrder returns the hash of a serialized limit order
+func hashLimitOrder(order *LimitOrder) (orderHash [32]byte, err error) {
+	var orderBytes []byte
+	if orderBytes, err = order.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing limit order: %s", err)
+		return
+	}
+	orderHash = sha3.Sum256(orderBytes)
+	return
+}
+
+// hashStopOrder returns the hash of a serialized stop order
+func hashStopOrder(stop *StopOrder) (orderHash [32]byte, err error) {
+	var stopBytes []byte
+	if stopBytes, err = stop.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing stop order: %s", err)
+		return
+	}
+	orderHash = sha3.Sum256(stopBytes)
+	return
+}
+
+// SigHash is the hash that gets signed for the envelope, the sha3 hash of the serialized envelope. The
+// message type in the encoding keeps envelope signatures from being signatures of anything else.
+func (e *OrderEnvelope) SigHash() (hash []byte) {
+	sigHash := sha3.Sum256(e.Serialize())
+	hash = sigHash[:]
+	return
+}
+
+// CheckLimitOrder returns an error if the envelope isn't for the limit order
+func (e *OrderEnvelope) CheckLimitOrder(order *LimitOrder) (err error) {
+	if order == nil {
+		err = fmt.Errorf("Cannot check envelope against nil order, please enter valid input")
+		return
+	}
+
+	if e.Kind != LimitOrderKind {
+		err = fmt.Errorf("Envelope is for a %s order, not a limit order", e.Kind.String())
+		return
+	}
+
+	if e.Pair != order.TradingPair {
+		err = fmt.Errorf("Envelope is for pair %s, not %s", e.Pair.String(), order.TradingPair.String())
+		return
+	}
+
+	var orderHash [32]byte
+	if orderHash, err = hashLimitOrder(order); err != nil {
+		err = fmt.Errorf("Error hashing order for CheckLimitOrder: %s", err)
+		return
+	}
+
+	if e.OrderHash != orderHash {
+		err = fmt.Errorf("Envelope is not for this order, order hash %x does not match %x", orderHash, e.OrderHash)
+		return
+	}
+	return
+}
+
+// CheckAuctionOrder returns an error if the envelope isn't for the auction order
+func (e *OrderEnvelope) CheckAuctionOrder(order *AuctionOrder) (err error) {
+	if order == nil {
+		err = fmt.Errorf("Cannot check envelope against nil order, please enter valid input")
+		return
+	}
+
+	if e.Kind != AuctionOrderKind {
+		err = fmt.Errorf("Envelope is for a %s order, not an auction order", e.Kind.String())
+		return
+	}
+
+	if e.Pair != order.TradingPair {
+		err = fmt.Errorf("Envelope is for pair %s, not %s", e.Pair.String(), order.TradingPair.String())
+		return
+	}
+
+	if orderHash := sha3.Sum256(order.SerializeSignable()); e.OrderHash != orderHash {
+		err = fmt.Errorf("Envelope is not for this order, order hash %x does not match %x", orderHash, e.OrderHash)
+		return
+	}
+	return
+}
+
+// CheckStopOrder returns an error if the envelope isn't for the stop order
+func (e *OrderEnvelope) CheckStopOrder(stop *StopOrder) (err error) {
+	if stop == nil {
+		err = fmt.Errorf("Cannot check envelope against nil stop order, please enter valid input")
+		return
+	}
+
+	if e.Kind != StopOrderKind {
+		err = fmt.Errorf("Envelope is for a %s order, not a stop order", e.Kind.String())
+		return
+	}
+
+	if e.Pair != stop.Order.TradingPair {
+		err = fmt.Errorf("Envelope is for pair %s, not %s", e.Pair.String(), stop.Order.TradingPair.String())
+		return
+	}
+
+	var orderHash [32]byte
+	if orderHash, err = hashStopOrder(stop); err != nil {
+		err = fmt.Errorf("Error hashing stop order for CheckStopOrder: %s", err)
+		return
+	}
+
+	if e.OrderHash != orderHash {
+		err = fmt.Errorf("Envelope is not for this stop order, order hash %x does not match %x", orderHash, e.OrderHash)
+		return
+	}
+	return
+}
+
+// CheckCancel returns an error if the envelope isn't for cancelling the order on the pair, or the stop order
+// if stop is true
+func (e *OrderEnvelope) CheckCancel(cancel *CancelRequest, stop bool, pair Pair) (err error) {
+	if cancel == nil {
+		err = fmt.Errorf("Cannot check envelope against nil cancel request, please enter valid input")
+		return
+	}
+
+	kind := CancelOrderKind
+	if stop {
+		kind = CancelStopOrderKind
+	}
+
+	if e.Kind != kind {
+		err = fmt.Errorf("Envelope is for a %s order, not a %s order", e.Kind.String(), kind.String())
+		return
+	}
+
+	if e.Pair != pair {
+		err = fmt.Errorf("Envelope is for pair %s, not %s", e.Pair.String(), pair.String())
+		return
+	}
+
+	if orderHash := sha3.Sum256(cancel.Serialize()); e.OrderHash != orderHash {
+		err = fmt.Errorf("Envelope is not for this cancel, hash %x does not match %x", orderHash, e.OrderHash)
+		return
+	}
+	return
+}
+
+// CheckReplace returns an error if the envelope isn't for amending the order on the pair
+func (e *OrderEnvelope) CheckReplace(replace *ReplaceRequest, pair Pair) (err error) {
+	if replace == nil {
+		err = fmt.Errorf("Cannot check envelope against nil replace request, please enter valid input")
+		return
+	}
+
+	if e.Kind != ReplaceOrderKind {
+		err = fmt.Errorf("Envelope is for a %s order, not a replace order", e.Kind.String())
+		return
+	}
+
+	if e.Pair != pair {
+		err = fmt.Errorf("Envelope is for pair %s, not %s", e.Pair.String(), pair.String())
+		return
+	}
+
+	if orderHash := sha3.Sum256(replace.Serialize()); e.OrderHash != orderHash {
+		err = fmt.Errorf("Envelope is not for this replace, hash %x does not match %x", orderHash, e.OrderHash)
+		return
+	}
+	return
+}
+
+// CheckExpiry returns an error if the envelope has expired at the time now, or expires further in the
+// future than MaxEnvelopeLifetime
+func (e *OrderEnvelope) CheckExpiry(now time.Time) (err error) {
+	if e.Expiry <= uint64(now.Unix()) {
+		err = fmt.Errorf("Envelope expired at %d", e.Expiry)
+		return
+	}
+
+	if maxExpiry := uint64(now.Add(MaxEnvelopeLifetime).Unix()); e.Expiry > maxExpiry {
+		err = fmt.Errorf("Envelope expiry %d is more than %s away, it can be at most %d", e.Expiry, MaxEnvelopeLifetime.String(), maxExpiry)
+		return
+	}
+	return
+}
+
+// Serialize serializes the envelope in the canonical encoding: [1 byte kind] [33 byte exchange] [2 byte pair]
+// [8 byte nonce] [8 byte expiry] [32 byte order hash]
+func (e *OrderEnvelope) Serialize() (buf []byte) {
+	enc := NewEncoder(OrderEnvelopeMessage)
+	enc.WriteUint8(uint8(e.Kind))
+	enc.WriteFixed(e.Exchange[:])
+	enc.WritePair(e.Pair)
+	enc.WriteUint64(e.Nonce)
+	enc.WriteUint64(e.Expiry)
+	enc.WriteFixed(e.OrderHash[:])
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes an envelope that was serialized with Serialize
+func (e *OrderEnvelope) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, OrderEnvelopeMessage)
+	e.Kind = OrderKind(dec.ReadUint8())
+	dec.ReadFixed(e.Exchange[:])
+	e.Pair = dec.ReadPair()
+	e.Nonce = dec.ReadUint64()
+	e.Expiry = dec.ReadUint64()
+	dec.ReadFixed(e.OrderHash[:])
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing envelope: %s", err)
+		return
+	}
+	return
+}
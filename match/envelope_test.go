@@ -0,0 +1,256 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLimitOrderEnvelope(t *testing.T) {
+	var err error
+
+	order := &LimitOrder{
+		Pubkey:      [33]byte{0x02, 0xab},
+		Side:        Buy,
+		TradingPair: Pair{AssetWant: Asset(0), AssetHave: Asset(1)},
+		AmountHave:  100,
+		AmountWant:  200,
+	}
+
+	var envelope *OrderEnvelope
+	if envelope, err = NewLimitOrderEnvelope(order, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating envelope: %s", err)
+		return
+	}
+
+	if err = envelope.CheckLimitOrder(order); err != nil {
+		t.Errorf("Envelope should have matched its order but didn't: %s", err)
+		return
+	}
+
+	// Changing any part of the order means the envelope isn't for it anymore
+	changedOrder := *order
+	changedOrder.AmountWant = 201
+	if err = envelope.CheckLimitOrder(&changedOrder); err == nil {
+		t.Errorf("Envelope should not have matched a changed order")
+		return
+	}
+
+	deserialized := new(OrderEnvelope)
+	if err = deserialized.Deserialize(envelope.Serialize()); err != nil {
+		t.Errorf("Error deserializing envelope: %s", err)
+		return
+	}
+
+	if !reflect.DeepEqual(envelope, deserialized) {
+		t.Errorf("Deserialized envelope %+v does not equal original %+v", deserialized, envelope)
+		return
+	}
+
+	// Anything in the envelope changes what gets signed
+	otherExchange := *envelope
+	otherExchange.Exchange[1] = 0xce
+	otherNonce := *envelope
+	otherNonce.Nonce++
+	otherKind := *envelope
+	otherKind.Kind = AuctionOrderKind
+	for _, other := range []*OrderEnvelope{&otherExchange, &otherNonce, &otherKind} {
+		if reflect.DeepEqual(envelope.SigHash(), other.SigHash()) {
+			t.Errorf("Envelope %+v has the same sighash as %+v", other, envelope)
+			return
+		}
+	}
+
+	// A limit order envelope can't be used for an auction order
+	auctionOrder := &AuctionOrder{
+		Pubkey:      order.Pubkey,
+		Side:        order.Side,
+		TradingPair: order.TradingPair,
+		AmountHave:  order.AmountHave,
+		AmountWant:  order.AmountWant,
+	}
+	if err = envelope.CheckAuctionOrder(auctionOrder); err == nil {
+		t.Errorf("Limit order envelope should not have matched an auction order")
+		return
+	}
+
+	return
+}
+
+func TestStopOrderEnvelope(t *testing.T) {
+	var err error
+
+	stop := &StopOrder{
+		Order: LimitOrder{
+			Pubkey:      [33]byte{0x02, 0xab},
+			Side:        Sell,
+			TradingPair: Pair{AssetWant: Asset(0), AssetHave: Asset(1)},
+			AmountHave:  100,
+			AmountWant:  200,
+		},
+		StopPrice: Price{AmountWant: 1, AmountHave: 2},
+	}
+
+	var envelope *OrderEnvelope
+	if envelope, err = NewStopOrderEnvelope(stop, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating envelope: %s", err)
+		return
+	}
+
+	if err = envelope.CheckStopOrder(stop); err != nil {
+		t.Errorf("Envelope should have matched its stop order but didn't: %s", err)
+		return
+	}
+
+	// Changing the stop price means the envelope isn't for it anymore
+	changedStop := *stop
+	changedStop.StopPrice.AmountHave = 3
+	if err = envelope.CheckStopOrder(&changedStop); err == nil {
+		t.Errorf("Envelope should not have matched a changed stop order")
+		return
+	}
+
+	// A stop order envelope can't be used for the limit order it places, or the other way around
+	if err = envelope.CheckLimitOrder(&stop.Order); err == nil {
+		t.Errorf("Stop order envelope should not have matched a limit order")
+		return
+	}
+
+	var limitEnvelope *OrderEnvelope
+	if limitEnvelope, err = NewLimitOrderEnvelope(&stop.Order, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating limit order envelope: %s", err)
+		return
+	}
+
+	if err = limitEnvelope.CheckStopOrder(stop); err == nil {
+		t.Errorf("Limit order envelope should not have matched a stop order")
+		return
+	}
+
+	return
+}
+
+func TestCancelReplaceEnvelope(t *testing.T) {
+	var err error
+
+	pair := Pair{AssetWant: Asset(0), AssetHave: Asset(1)}
+	cancel := &CancelRequest{OrderID: OrderID{0x01}}
+
+	var cancelEnvelope *OrderEnvelope
+	if cancelEnvelope, err = NewCancelEnvelope(cancel, false, pair, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating cancel envelope: %s", err)
+		return
+	}
+
+	if err = cancelEnvelope.CheckCancel(cancel, false, pair); err != nil {
+		t.Errorf("Envelope should have matched its cancel but didn't: %s", err)
+		return
+	}
+
+	// A cancel for an order can't cancel another order, or a stop order with the same ID
+	if err = cancelEnvelope.CheckCancel(&CancelRequest{OrderID: OrderID{0x02}}, false, pair); err == nil {
+		t.Errorf("Cancel envelope should not have matched another order")
+		return
+	}
+
+	if err = cancelEnvelope.CheckCancel(cancel, true, pair); err == nil {
+		t.Errorf("Cancel envelope should not have matched a stop order cancel")
+		return
+	}
+
+	replace := &ReplaceRequest{OrderID: OrderID{0x01}, AmountHave: 100, AmountWant: 200}
+
+	var replaceEnvelope *OrderEnvelope
+	if replaceEnvelope, err = NewReplaceEnvelope(replace, pair, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating replace envelope: %s", err)
+		return
+	}
+
+	if err = replaceEnvelope.CheckReplace(replace, pair); err != nil {
+		t.Errorf("Envelope should have matched its replace but didn't: %s", err)
+		return
+	}
+
+	// A signature for one amendment can't be used for another
+	otherReplace := *replace
+	otherReplace.AmountWant = 201
+	if err = replaceEnvelope.CheckReplace(&otherReplace, pair); err == nil {
+		t.Errorf("Replace envelope should not have matched another amendment")
+		return
+	}
+
+	if err = cancelEnvelope.CheckReplace(replace, pair); err == nil {
+		t.Errorf("Cancel envelope should not have matched a replace")
+		return
+	}
+
+	return
+}
+
+func TestEnvelopeCheckExpiry(t *testing.T) {
+	now := time.Unix(1000000, 0)
+
+	var tests = []struct {
+		name   string
+		expiry uint64
+		valid  bool
+	}{
+		{name: "expired", expiry: 999999, valid: false},
+		{name: "expires now", expiry: 1000000, valid: false},
+		{name: "expires soon", expiry: 1000001, valid: true},
+		{name: "max lifetime", expiry: uint64(now.Add(MaxEnvelopeLifetime).Unix()), valid: true},
+		{name: "past max lifetime", expiry: uint64(now.Add(MaxEnvelopeLifetime).Unix()) + 1, valid: false},
+	}
+
+	for _, tt := range tests {
+		err := (&OrderEnvelope{Expiry: tt.expiry}).CheckExpiry(now)
+		if tt.valid && err != nil {
+			t.Errorf("Envelope that %s should have been valid but wasn't: %s", tt.name, err)
+			return
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Envelope that %s should have been invalid but wasn't", tt.name)
+			return
+		}
+	}
+
+	return
+}
+
+func TestAuctionOrderEnvelopeSerialize(t *testing.T) {
+	var err error
+
+	order := &AuctionOrder{
+		Pubkey:      [33]byte{0x02, 0xab},
+		Side:        Sell,
+		TradingPair: Pair{AssetWant: Asset(0), AssetHave: Asset(1)},
+		AmountHave:  100,
+		AmountWant:  200,
+		AuctionID:   [32]byte{0x01},
+		Signature:   []byte{0x01, 0x02, 0x03},
+	}
+
+	if order.Envelope, err = NewAuctionOrderEnvelope(order, [33]byte{0x03, 0xcd}, 42, 1000); err != nil {
+		t.Errorf("Error creating envelope: %s", err)
+		return
+	}
+
+	// The envelope is encrypted along with the rest of the order
+	deserialized := new(AuctionOrder)
+	if err = deserialized.Deserialize(order.Serialize()); err != nil {
+		t.Errorf("Error deserializing auction order: %s", err)
+		return
+	}
+
+	if !reflect.DeepEqual(order, deserialized) {
+		t.Errorf("Deserialized order %s does not equal original %s", deserialized.String(), order.String())
+		return
+	}
+
+	if err = deserialized.Envelope.CheckAuctionOrder(deserialized); err != nil {
+		t.Errorf("Deserialized envelope should have matched its order but didn't: %s", err)
+		return
+	}
+
+	return
+}
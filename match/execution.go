@@ -2,6 +2,7 @@ package match
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 /*
@@ -69,6 +70,9 @@ type OrderExecution struct {
 	NewAmountWant uint64  `json:"newamtwant"`
 	NewAmountHave uint64  `json:"newamthave"`
 	Filled        bool    `json:"filled"`
+	// Decremented is true if self-trade prevention took some of the order off the book without it trading.
+	// Whatever was taken off is refunded.
+	Decremented bool `json:"decremented"`
 }
 
 // String returns a json representation of the OrderExecution
@@ -92,5 +96,36 @@ func (oe *OrderExecution) Equal(otherExec *OrderExecution) bool {
 	if oe.Filled != otherExec.Filled {
 		return false
 	}
+	if oe.Decremented != otherExec.Decremented {
+		return false
+	}
 	return true
 }
+
+// Serialize serializes the order execution in the canonical encoding:
+// [32 byte order ID] [8 byte new amountWant] [8 byte new amountHave] [1 byte filled] [1 byte decremented]
+func (oe *OrderExecution) Serialize() (buf []byte) {
+	enc := NewEncoder(OrderExecutionMessage)
+	enc.WriteFixed(oe.OrderID[:])
+	enc.WriteUint64(oe.NewAmountWant)
+	enc.WriteUint64(oe.NewAmountHave)
+	enc.WriteBool(oe.Filled)
+	enc.WriteBool(oe.Decremented)
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes an order execution that was serialized with Serialize
+func (oe *OrderExecution) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, OrderExecutionMessage)
+	dec.ReadFixed(oe.OrderID[:])
+	oe.NewAmountWant = dec.ReadUint64()
+	oe.NewAmountHave = dec.ReadUint64()
+	oe.Filled = dec.ReadBool()
+	oe.Decremented = dec.ReadBool()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing order execution: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,185 @@
+package match
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// feeDenominator is what fees are measured in, so a fee of 10 is 0.1%
+const feeDenominator = 10000
+
+// FeeTier is the maker and taker fee for a specific pubkey, in basis points
+type FeeTier struct {
+	MakerFee uint64 `json:"maker"`
+	TakerFee uint64 `json:"taker"`
+}
+
+// FeeSchedule is the maker and taker fee for a pair, in basis points of what the user receives from a
+// trade. The maker is the order that was already on the book, and the taker is the order that matched it.
+type FeeSchedule struct {
+	MakerFee uint64 `json:"maker"`
+	TakerFee uint64 `json:"taker"`
+	// Tiers are the fees for specific pubkeys, keyed by the hex of the compressed pubkey, which are used
+	// instead of MakerFee and TakerFee
+	Tiers map[string]FeeTier `json:"tiers"`
+}
+
+// Valid returns an error if any of the fees are more than 100%
+func (fs *FeeSchedule) Valid() (err error) {
+	if fs.MakerFee > feeDenominator || fs.TakerFee > feeDenominator {
+		err = fmt.Errorf("Fees cannot be more than %d basis points", feeDenominator)
+		return
+	}
+
+	for pubkeyHex, tier := range fs.Tiers {
+		var pubkeyBytes []byte
+		if pubkeyBytes, err = hex.DecodeString(pubkeyHex); err != nil || len(pubkeyBytes) != 33 {
+			err = fmt.Errorf("Fee tier pubkey %s is not a hex compressed pubkey", pubkeyHex)
+			return
+		}
+
+		if tier.MakerFee > feeDenominator || tier.TakerFee > feeDenominator {
+			err = fmt.Errorf("Fees for %s cannot be more than %d basis points", pubkeyHex, feeDenominator)
+			return
+		}
+	}
+	return
+}
+
+// Rate returns the fee in basis points for the pubkey as a maker or taker
+func (fs *FeeSchedule) Rate(pubkey [33]byte, maker bool) (rate uint64) {
+	tier := FeeTier{MakerFee: fs.MakerFee, TakerFee: fs.TakerFee}
+	if pubkeyTier, ok := fs.Tiers[hex.EncodeToString(pubkey[:])]; ok {
+		tier = pubkeyTier
+	}
+
+	if maker {
+		return tier.MakerFee
+	}
+	return tier.TakerFee
+}
+
+// LimitFeeExecutions returns the settlement executions that charge fees for the settlement executions from
+// matching a limit order. Every debit is something a user received from a trade, and the fee is taken out
+// of it and given to the fee account. Orders are matched right after the taker is placed, so debits to the
+// taker's pubkey are charged the taker fee, and the rest are charged the maker fee.
+// The settlement executions should not include refunds, since those aren't trades.
+func (fs *FeeSchedule) LimitFeeExecutions(settlementExecs []*SettlementExecution, taker [33]byte, feeAccount [33]byte) (feeExecs []*SettlementExecution, err error) {
+	for _, setExec := range settlementExecs {
+		var execFees []*SettlementExecution
+		if execFees, err = fs.feeExecutions(setExec, setExec.Pubkey != taker, feeAccount); err != nil {
+			err = fmt.Errorf("Error charging fee for LimitFeeExecutions: %s", err)
+			return
+		}
+		feeExecs = append(feeExecs, execFees...)
+	}
+	return
+}
+
+// AuctionFeeExecutions returns the settlement executions that charge fees for the settlement executions from
+// matching an auction. No order is on the book before an auction is matched, so every debit is charged the
+// taker fee.
+func (fs *FeeSchedule) AuctionFeeExecutions(settlementExecs []*SettlementExecution, feeAccount [33]byte) (feeExecs []*SettlementExecution, err error) {
+	for _, setExec := range settlementExecs {
+		var execFees []*SettlementExecution
+		if execFees, err = fs.feeExecutions(setExec, false, feeAccount); err != nil {
+			err = fmt.Errorf("Error charging fee for AuctionFeeExecutions: %s", err)
+			return
+		}
+		feeExecs = append(feeExecs, execFees...)
+	}
+	return
+}
+
+// feeExecutions returns the credit that takes the fee from the user and the debit that gives it to the fee
+// account, or nothing if the settlement execution isn't a debit or the fee rounds down to zero.
+func (fs *FeeSchedule) feeExecutions(received *SettlementExecution, maker bool, feeAccount [33]byte) (feeExecs []*SettlementExecution, err error) {
+	if received.Type != Debit {
+		return
+	}
+
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(received.Amount), new(big.Int).SetUint64(fs.Rate(received.Pubkey, maker)))
+	fee.Quo(fee, big.NewInt(feeDenominator))
+	if !fee.IsUint64() {
+		err = fmt.Errorf("Fee %s does not fit in a uint64", fee)
+		return
+	}
+
+	if fee.Uint64() == 0 {
+		return
+	}
+
+	feeExecs = []*SettlementExecution{
+		{
+			Pubkey: received.Pubkey,
+			Amount: fee.Uint64(),
+			Asset:  received.Asset,
+			Type:   Credit,
+		},
+		{
+			Pubkey: feeAccount,
+			Amount: fee.Uint64(),
+			Asset:  received.Asset,
+			Type:   Debit,
+		},
+	}
+	return
+}
+
+// FeeConfig is the fee account and fee schedules for an exchange, as they are written in a fee file
+type FeeConfig struct {
+	// Account is the hex of the compressed pubkey that fees are paid to
+	Account string `json:"account"`
+	// Default is the fee schedule for pairs that aren't in Pairs, if it's set
+	Default *FeeSchedule `json:"default"`
+	// Pairs are the fee schedules for each pair, keyed by the pair as users write it, like "regtest/litereg"
+	Pairs map[string]*FeeSchedule `json:"pairs"`
+}
+
+// ParseFeeConfig parses a json fee config, returning the fee account and the fee schedule for each pair in
+// the pair list that has fees
+func ParseFeeConfig(data []byte, pairList []*Pair) (account [33]byte, schedules map[Pair]*FeeSchedule, err error) {
+	config := new(FeeConfig)
+	if err = json.Unmarshal(data, config); err != nil {
+		err = fmt.Errorf("Error unmarshalling fee config: %s", err)
+		return
+	}
+
+	var accountBytes []byte
+	if accountBytes, err = hex.DecodeString(config.Account); err != nil || len(accountBytes) != 33 {
+		err = fmt.Errorf("Fee account %s is not a hex compressed pubkey", config.Account)
+		return
+	}
+	copy(account[:], accountBytes)
+
+	schedules = make(map[Pair]*FeeSchedule)
+	for _, pair := range pairList {
+		if config.Default != nil {
+			schedules[*pair] = config.Default
+		}
+	}
+
+	for pairString, schedule := range config.Pairs {
+		var pair Pair
+		if pair, err = pairFromConfigString(pairString); err != nil {
+			err = fmt.Errorf("Error getting pair for fee config: %s", err)
+			return
+		}
+
+		if schedule == nil {
+			err = fmt.Errorf("Fee schedule for %s cannot be empty", pairString)
+			return
+		}
+		schedules[pair] = schedule
+	}
+
+	for pair, schedule := range schedules {
+		if err = schedule.Valid(); err != nil {
+			err = fmt.Errorf("Invalid fee schedule for %s: %s", pair.String(), err)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package match
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestLimitFeeExecutions(t *testing.T) {
+	var err error
+
+	maker := [33]byte{2, 1}
+	taker := [33]byte{2, 2}
+	tiered := [33]byte{2, 3}
+	feeAccount := [33]byte{2, 4}
+
+	schedule := &FeeSchedule{
+		MakerFee: 10,
+		TakerFee: 25,
+		Tiers: map[string]FeeTier{
+			hex.EncodeToString(tiered[:]): {MakerFee: 0, TakerFee: 5},
+		},
+	}
+
+	if err = schedule.Valid(); err != nil {
+		t.Errorf("Fee schedule should have been valid: %s", err)
+		return
+	}
+
+	settlementExecs := []*SettlementExecution{
+		{Pubkey: maker, Amount: 1000, Asset: BTCTest, Type: Debit},
+		{Pubkey: maker, Amount: 1000, Asset: VTCTest, Type: Credit},
+		{Pubkey: taker, Amount: 1000, Asset: VTCTest, Type: Debit},
+		{Pubkey: tiered, Amount: 1000, Asset: BTCTest, Type: Debit},
+		// 39 * 25 / 10000 rounds down to nothing
+		{Pubkey: taker, Amount: 39, Asset: VTCTest, Type: Debit},
+	}
+
+	var feeExecs []*SettlementExecution
+	if feeExecs, err = schedule.LimitFeeExecutions(settlementExecs, taker, feeAccount); err != nil {
+		t.Errorf("Error getting fee executions for TestLimitFeeExecutions: %s", err)
+		return
+	}
+
+	// The maker pays 1, the taker pays 2, and the tiered maker pays nothing
+	expected := []*SettlementExecution{
+		{Pubkey: maker, Amount: 1, Asset: BTCTest, Type: Credit},
+		{Pubkey: feeAccount, Amount: 1, Asset: BTCTest, Type: Debit},
+		{Pubkey: taker, Amount: 2, Asset: VTCTest, Type: Credit},
+		{Pubkey: feeAccount, Amount: 2, Asset: VTCTest, Type: Debit},
+	}
+
+	if len(feeExecs) != len(expected) {
+		t.Errorf("Should have had %d fee executions but had %d", len(expected), len(feeExecs))
+		return
+	}
+
+	for i, feeExec := range feeExecs {
+		if !feeExec.Equal(expected[i]) {
+			t.Errorf("Fee execution %d should have been %s but was %s", i, expected[i].String(), feeExec.String())
+			return
+		}
+	}
+
+	return
+}
+
+func TestParseFeeConfig(t *testing.T) {
+	var err error
+
+	pairList := []*Pair{
+		{AssetWant: BTCTest, AssetHave: VTCTest},
+		{AssetWant: BTCTest, AssetHave: LTCTest},
+	}
+
+	account := [33]byte{2, 4}
+	config := fmt.Sprintf(`{"account": "%x", "default": {"maker": 5, "taker": 10}, "pairs": {"%s": {"maker": 0, "taker": 20}}}`, account, pairList[0].PrettyString())
+
+	var parsedAccount [33]byte
+	var schedules map[Pair]*FeeSchedule
+	if parsedAccount, schedules, err = ParseFeeConfig([]byte(config), pairList); err != nil {
+		t.Errorf("Error parsing fee config for TestParseFeeConfig: %s", err)
+		return
+	}
+
+	if parsedAccount != account {
+		t.Errorf("Fee account should have been %x but was %x", account, parsedAccount)
+		return
+	}
+
+	if schedule := schedules[*pairList[0]]; schedule == nil || schedule.MakerFee != 0 || schedule.TakerFee != 20 {
+		t.Errorf("Pair %s should have had its own fee schedule", pairList[0].String())
+		return
+	}
+
+	if schedule := schedules[*pairList[1]]; schedule == nil || schedule.MakerFee != 5 || schedule.TakerFee != 10 {
+		t.Errorf("Pair %s should have had the default fee schedule", pairList[1].String())
+		return
+	}
+
+	// More than 100% is not a fee
+	tooHigh := fmt.Sprintf(`{"account": "%x", "default": {"maker": 10001, "taker": 10}}`, account)
+	if _, _, err = ParseFeeConfig([]byte(tooHigh), pairList); err == nil {
+		t.Errorf("Fee config with a fee over 100%% should have been invalid")
+		return
+	}
+
+	return
+}
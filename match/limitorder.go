@@ -1,9 +1,8 @@
 package match
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"time"
 )
 
 // TODO: Order, Side, Price, User abstraction: The Price should really be the pair {amountHave,amountWant}, and we should be comparing Prices by doing fraction comparison.
@@ -18,27 +17,64 @@ type LimitOrder struct {
 	AmountHave uint64 `json:"amounthave"`
 	// amount of assetWant the user wants for their assetHave
 	AmountWant uint64 `json:"amountwant"`
+	// TimeInForce is how long the order can rest on the book, good till cancel by default
+	TimeInForce TimeInForce `json:"timeinforce"`
+	// Expiry is the unix time, in seconds, after which the order is cancelled if it's still on the book.
+	// Zero means the order doesn't expire.
+	Expiry uint64 `json:"expiry"`
+	// SelfTrade is what happens if the order would match another order with the same pubkey, cancel newest
+	// by default
+	SelfTrade SelfTradePrevention `json:"selftrade"`
 }
 
-// Price gets a float price for the order. This determines how it will get matched. The exchange should figure out if it can take some of the
-func (l *LimitOrder) Price() (price float64, err error) {
+// Expired returns true if the order has an expiry and it has passed at the time now
+func (l *LimitOrder) Expired(now time.Time) bool {
+	return l.Expiry != 0 && uint64(now.Unix()) >= l.Expiry
+}
+
+// Price gets the price for the order. This determines how it will get matched. The price is reduced, so orders
+// with the same price will have the same representation.
+func (l *LimitOrder) Price() (price *Price, err error) {
 	if l.AmountWant == 0 || l.AmountHave == 0 {
 		err = fmt.Errorf("Cannot calculate price if AmountWant or AmountHave is 0")
 		return
 	}
-	price = float64(l.AmountWant) / float64(l.AmountHave)
+	price = new(Price)
+	*price = (&Price{AmountWant: l.AmountWant, AmountHave: l.AmountHave}).Reduce()
 	return
 }
 
-// Serialize serializes an order, possible replay attacks here since this is what you're signing?
+// Serialize serializes an order in the canonical encoding, which is hashed into the envelope that gets
+// signed for it:
+// [33 byte pubkey] [1 byte side] [2 byte pair] [8 byte amountHave] [8 byte amountWant] [1 byte time in force]
+// [8 byte expiry] [1 byte self trade prevention]
 func (l *LimitOrder) Serialize() (buf []byte, err error) {
-	intermediate := new(bytes.Buffer)
-	if err = binary.Write(intermediate, binary.LittleEndian, *l); err != nil {
-		err = fmt.Errorf("Error writing limit order to binary for serialize: %s", err)
-		return
-	}
-	if _, err = intermediate.Read(buf); err != nil {
-		err = fmt.Errorf("Error reading from intermediate into buffer for serialize: %s", err)
+	enc := NewEncoder(LimitOrderMessage)
+	enc.WriteFixed(l.Pubkey[:])
+	enc.WriteSide(l.Side)
+	enc.WritePair(l.TradingPair)
+	enc.WriteUint64(l.AmountHave)
+	enc.WriteUint64(l.AmountWant)
+	enc.WriteUint8(uint8(l.TimeInForce))
+	enc.WriteUint64(l.Expiry)
+	enc.WriteUint8(uint8(l.SelfTrade))
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes an order that was serialized with Serialize
+func (l *LimitOrder) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, LimitOrderMessage)
+	dec.ReadFixed(l.Pubkey[:])
+	l.Side = dec.ReadSide()
+	l.TradingPair = dec.ReadPair()
+	l.AmountHave = dec.ReadUint64()
+	l.AmountWant = dec.ReadUint64()
+	l.TimeInForce = TimeInForce(dec.ReadUint8())
+	l.Expiry = dec.ReadUint64()
+	l.SelfTrade = SelfTradePrevention(dec.ReadUint8())
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing limit order: %s", err)
 		return
 	}
 	return
@@ -48,14 +84,14 @@ func (l *LimitOrder) Serialize() (buf []byte, err error) {
 // This does not assume anything about the price of the order, as we can't infer what price the order was
 // placed at.
 // TODO: Figure out whether or not these should be pointers
-func (l *LimitOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (orderExec OrderExecution, setExecs []*SettlementExecution, err error) {
+func (l *LimitOrder) GenerateOrderFill(orderID *OrderID, execPrice *Price) (orderExec OrderExecution, setExecs []*SettlementExecution, err error) {
 
 	if l.AmountHave == 0 {
 		err = fmt.Errorf("Error generating order fill: empty order, the AmountHave cannot be 0")
 		return
 	}
 
-	if execPrice == float64(0) {
+	if execPrice == nil || execPrice.AmountWant == 0 || execPrice.AmountHave == 0 {
 		err = fmt.Errorf("Error generating order fill: price cannot be zero")
 		return
 	}
@@ -73,7 +109,10 @@ func (l *LimitOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (ord
 		err = fmt.Errorf("Error generating order fill from price, order is not buy or sell side, it's %s side", l.Side.String())
 		return
 	}
-	amountToDebit = uint64(float64(l.AmountHave) * execPrice)
+	if amountToDebit, err = execPrice.MulAmount(l.AmountHave); err != nil {
+		err = fmt.Errorf("Error generating order fill, could not calculate amount to debit: %s", err)
+		return
+	}
 
 	// IMPORTANT! These lines:
 	// > OrderID: make([]byte, len(orderID),
@@ -112,7 +151,7 @@ func (l *LimitOrder) GenerateOrderFill(orderID *OrderID, execPrice float64) (ord
 // in as a parameter. The order ID will be filled in, as it's being passed as a parameter.
 // This returns a fillRemainder, which is the amount that is left over from amountToFill after
 // filling orderID at execPrice and amountToFill
-func (l *LimitOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice float64, amountToFill uint64) (orderExec OrderExecution, setExecs []*SettlementExecution, fillRemainder uint64, err error) {
+func (l *LimitOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice *Price, amountToFill uint64) (orderExec OrderExecution, setExecs []*SettlementExecution, fillRemainder uint64, err error) {
 	// If it's a buy side, AmountWant is assetWant, and AmountHave is assetHave - but price is something different, price is want/have.
 	// So to convert from amountWant (amountToFill) to amountHave we need to multiple amountToFill by 1/execPrice
 	var amountWantToFill uint64
@@ -128,7 +167,14 @@ func (l *LimitOrder) GenerateExecutionFromPrice(orderID *OrderID, execPrice floa
 		err = fmt.Errorf("Error generating execution from price, order is not buy or sell side, it's %s side", l.Side.String())
 		return
 	}
-	amountWantToFill = uint64(float64(amountToFill) * execPrice)
+	if execPrice == nil {
+		err = fmt.Errorf("Error generating execution from price, price cannot be nil")
+		return
+	}
+	if amountWantToFill, err = execPrice.MulAmount(amountToFill); err != nil {
+		err = fmt.Errorf("Error generating execution from price, could not calculate amount to fill: %s", err)
+		return
+	}
 
 	// Now that we have this value, we'll generate the execution.
 	// What should we do if the amountWantToFill is greater than the amountHave?
@@ -1,11 +1,112 @@
 package match
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-// LimitOrderIDPair is order ID, order, price, and time, used for generating executions in limit order matching algorithms
+// LimitOrderIDPair is order ID, order, price, and time, used for generating executions in limit order matching algorithms.
+// The price is the reduced price of the order when it was placed.
 type LimitOrderIDPair struct {
 	Timestamp time.Time   `json:"timestamp"`
-	Price     float64     `json:"price"`
+	Price     Price       `json:"price"`
 	OrderID   *OrderID    `json:"orderid"`
 	Order     *LimitOrder `json:"limitorder"`
 }
+
+// Replace returns a copy of the order amended to the new amounts, and the settlement execution that changes
+// how much of the user's balance is reserved for the order, which is nil if that doesn't change.
+// The order keeps its ID. It only keeps its time priority if the price stays the same and the size doesn't
+// go up, otherwise it goes to the back of the queue as if it was placed at now.
+func (l *LimitOrderIDPair) Replace(amountHave uint64, amountWant uint64, now time.Time) (replaced *LimitOrderIDPair, reserveChange *SettlementExecution, err error) {
+	if l.Order == nil || l.OrderID == nil {
+		err = fmt.Errorf("Cannot replace nil order, please enter valid input")
+		return
+	}
+
+	replaced = &LimitOrderIDPair{
+		Timestamp: l.Timestamp,
+		OrderID:   new(OrderID),
+		Order:     new(LimitOrder),
+	}
+	*replaced.OrderID = *l.OrderID
+	*replaced.Order = *l.Order
+	replaced.Order.AmountHave = amountHave
+	replaced.Order.AmountWant = amountWant
+
+	var newPrice *Price
+	if newPrice, err = replaced.Order.Price(); err != nil {
+		err = fmt.Errorf("Error getting price of replaced order: %s", err)
+		replaced = nil
+		return
+	}
+	replaced.Price = *newPrice
+
+	if replaced.Price != l.Price || amountHave > l.Order.AmountHave {
+		replaced.Timestamp = now
+	}
+
+	if amountHave < l.Order.AmountHave {
+		// give back what isn't reserved anymore
+		if reserveChange, err = l.Order.RefundExecution(l.Order.AmountHave - amountHave); err != nil {
+			err = fmt.Errorf("Error getting refund for replaced order: %s", err)
+			replaced = nil
+			return
+		}
+	} else if amountHave > l.Order.AmountHave {
+		// reserve the extra amount, this is the opposite of a refund
+		if reserveChange, err = l.Order.RefundExecution(amountHave - l.Order.AmountHave); err != nil {
+			err = fmt.Errorf("Error getting reserve for replaced order: %s", err)
+			replaced = nil
+			return
+		}
+		reserveChange.Type = Credit
+	}
+
+	return
+}
+
+// Serialize serializes the order and its ID in the canonical encoding:
+// [time] [8 byte price amountWant] [8 byte price amountHave] [optional 32 byte order ID] [optional limit order]
+func (l *LimitOrderIDPair) Serialize() (buf []byte, err error) {
+	enc := NewEncoder(LimitOrderIDPairMessage)
+	enc.WriteTime(l.Timestamp)
+	enc.WritePrice(l.Price)
+	enc.WriteBool(l.OrderID != nil)
+	if l.OrderID != nil {
+		enc.WriteFixed(l.OrderID[:])
+	}
+	enc.WriteBool(l.Order != nil)
+	if l.Order != nil {
+		var orderBytes []byte
+		if orderBytes, err = l.Order.Serialize(); err != nil {
+			err = fmt.Errorf("Error serializing order for limit order ID pair: %s", err)
+			return
+		}
+		enc.WriteBytes(orderBytes)
+	}
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes an order and its ID that were serialized with Serialize
+func (l *LimitOrderIDPair) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, LimitOrderIDPairMessage)
+	l.Timestamp = dec.ReadTime()
+	l.Price = dec.ReadPrice()
+	l.OrderID = nil
+	if dec.ReadBool() {
+		l.OrderID = new(OrderID)
+		dec.ReadFixed(l.OrderID[:])
+	}
+	l.Order = nil
+	if dec.ReadBool() {
+		l.Order = new(LimitOrder)
+		dec.SetErr(l.Order.Deserialize(dec.ReadBytes()))
+	}
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing limit order ID pair: %s", err)
+		return
+	}
+	return
+}
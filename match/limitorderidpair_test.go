@@ -0,0 +1,81 @@
+package match
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplaceKeepsPriorityWhenReduced(t *testing.T) {
+	var err error
+
+	placed := time.Unix(1000, 0)
+	now := time.Unix(2000, 0)
+	order := &LimitOrderIDPair{
+		Timestamp: placed,
+		Price:     Price{AmountWant: 2, AmountHave: 1},
+		OrderID:   &OrderID{1},
+		Order: &LimitOrder{
+			Side:        Sell,
+			TradingPair: Pair{AssetWant: BTCTest, AssetHave: VTCTest},
+			AmountHave:  100,
+			AmountWant:  200,
+		},
+	}
+
+	// Same price, smaller size, so it keeps its place and gets the difference back
+	var replaced *LimitOrderIDPair
+	var reserveChange *SettlementExecution
+	if replaced, reserveChange, err = order.Replace(60, 120, now); err != nil {
+		t.Errorf("Error reducing order for TestReplaceKeepsPriorityWhenReduced: %s", err)
+		return
+	}
+
+	if !replaced.Timestamp.Equal(placed) {
+		t.Errorf("Reduced order should have kept its timestamp %s but had %s", placed, replaced.Timestamp)
+		return
+	}
+
+	if reserveChange == nil || reserveChange.Type != Debit || reserveChange.Amount != 40 || reserveChange.Asset != BTCTest {
+		t.Errorf("Reduced sell order should have given back 40 of the asset want, got %v", reserveChange)
+		return
+	}
+
+	if *replaced.OrderID != *order.OrderID || order.Order.AmountHave != 100 {
+		t.Errorf("Replace should keep the order ID and not modify the original order")
+		return
+	}
+
+	// Bigger size, so it goes to the back of the queue and reserves the difference
+	if replaced, reserveChange, err = order.Replace(150, 300, now); err != nil {
+		t.Errorf("Error increasing order for TestReplaceKeepsPriorityWhenReduced: %s", err)
+		return
+	}
+
+	if !replaced.Timestamp.Equal(now) {
+		t.Errorf("Increased order should have lost its priority")
+		return
+	}
+
+	if reserveChange == nil || reserveChange.Type != Credit || reserveChange.Amount != 50 {
+		t.Errorf("Increased order should have reserved 50 more, got %v", reserveChange)
+		return
+	}
+
+	// New price, so it loses priority even though nothing needs to be reserved
+	if replaced, reserveChange, err = order.Replace(100, 300, now); err != nil {
+		t.Errorf("Error repricing order for TestReplaceKeepsPriorityWhenReduced: %s", err)
+		return
+	}
+
+	if !replaced.Timestamp.Equal(now) || replaced.Price != (Price{AmountWant: 3, AmountHave: 1}) {
+		t.Errorf("Repriced order should have lost its priority and had a price of 3, got %s", replaced.Price.String())
+		return
+	}
+
+	if reserveChange != nil {
+		t.Errorf("Repriced order with the same size shouldn't change what's reserved")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,113 @@
+package match
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// slippageDenominator is what slippage is measured in, so a slippage of 100 is 1%
+const slippageDenominator = 10000
+
+// BestPrice returns the price of the order on the side of the book that would be matched first.
+func BestPrice(book map[Price][]*LimitOrderIDPair, side Side) (best *Price, err error) {
+	var buyOrders []*LimitOrderIDPair
+	var sellOrders []*LimitOrderIDPair
+	if buyOrders, sellOrders, err = PrioritizeOrderbookPTP(book); err != nil {
+		err = fmt.Errorf("Error prioritizing orders for BestPrice: %s", err)
+		return
+	}
+
+	var sideOrders []*LimitOrderIDPair
+	if side == Buy {
+		sideOrders = buyOrders
+	} else {
+		sideOrders = sellOrders
+	}
+
+	if len(sideOrders) == 0 {
+		err = fmt.Errorf("There are no %s orders in the book", side.String())
+		return
+	}
+
+	best = new(Price)
+	*best = sideOrders[0].Price
+	return
+}
+
+// MarketOrderPrice returns the worst price a market order on the side should be matched at, given the best
+// price on the opposite side of the book and the slippage in basis points. A buy order matches sell orders
+// with prices at or above its own, and a sell order matches buy orders with prices at or below its own.
+func MarketOrderPrice(side Side, bestOpposite *Price, slippage uint64) (worst Price, err error) {
+	if bestOpposite == nil || bestOpposite.AmountWant == 0 || bestOpposite.AmountHave == 0 {
+		err = fmt.Errorf("Cannot calculate market order price without a best price")
+		return
+	}
+
+	var factor uint64
+	if side == Buy {
+		if slippage >= slippageDenominator {
+			err = fmt.Errorf("Slippage for a buy order must be less than %d basis points", slippageDenominator)
+			return
+		}
+		factor = slippageDenominator - slippage
+	} else {
+		factor = slippageDenominator + slippage
+	}
+
+	want := new(big.Int).Mul(new(big.Int).SetUint64(bestOpposite.AmountWant), new(big.Int).SetUint64(factor))
+	have := new(big.Int).Mul(new(big.Int).SetUint64(bestOpposite.AmountHave), big.NewInt(slippageDenominator))
+	gcd := new(big.Int).GCD(nil, nil, want, have)
+	want.Quo(want, gcd)
+	have.Quo(have, gcd)
+
+	if !want.IsUint64() || !have.IsUint64() {
+		err = fmt.Errorf("Market order price %s/%s does not fit in a uint64", want, have)
+		return
+	}
+
+	worst = Price{
+		AmountWant: want.Uint64(),
+		AmountHave: have.Uint64(),
+	}
+	return
+}
+
+// NewMarketOrder creates an immediate-or-cancel order that sweeps the opposite side of the book, from the
+// best price on that side down to the worst price allowed by the slippage, in basis points. Whatever can't
+// be matched within the slippage is refunded.
+func NewMarketOrder(pubkey [33]byte, side Side, pair Pair, amountHave uint64, bestOpposite *Price, slippage uint64) (order *LimitOrder, err error) {
+	var worst Price
+	if worst, err = MarketOrderPrice(side, bestOpposite, slippage); err != nil {
+		err = fmt.Errorf("Error getting worst price for NewMarketOrder: %s", err)
+		return
+	}
+
+	var amountWant uint64
+	if amountWant, err = worst.MulAmount(amountHave); err != nil {
+		err = fmt.Errorf("Error calculating amount want for NewMarketOrder: %s", err)
+		return
+	}
+
+	// Rounding down makes the sell price lower, which is still within the slippage. A buy price has to
+	// round up to stay within the slippage.
+	if side == Buy {
+		if roundedPrice := (&Price{AmountWant: amountWant, AmountHave: amountHave}); roundedPrice.Cmp(&worst) < 0 {
+			amountWant++
+		}
+	}
+
+	if amountWant == 0 {
+		err = fmt.Errorf("Market order for %d is too small to want anything at price %s", amountHave, worst.String())
+		return
+	}
+
+	order = &LimitOrder{
+		Pubkey:      pubkey,
+		Side:        side,
+		TradingPair: pair,
+		AmountHave:  amountHave,
+		AmountWant:  amountWant,
+		TimeInForce: ImmediateOrCancel,
+	}
+	return
+}
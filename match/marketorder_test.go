@@ -0,0 +1,83 @@
+package match
+
+import (
+	"testing"
+)
+
+func TestMarketOrderPriceBuy(t *testing.T) {
+	var err error
+
+	// 1% slippage off of a best sell price of 2 should be 1.98
+	var worst Price
+	if worst, err = MarketOrderPrice(Buy, &Price{AmountWant: 2, AmountHave: 1}, 100); err != nil {
+		t.Errorf("Error getting market order price for TestMarketOrderPriceBuy: %s", err)
+		return
+	}
+
+	if expected := (&Price{AmountWant: 198, AmountHave: 100}).Reduce(); worst != expected {
+		t.Errorf("Market buy price should have been %s but was %s", expected.String(), worst.String())
+		return
+	}
+
+	// 100% slippage would be a price of 0
+	if _, err = MarketOrderPrice(Buy, &Price{AmountWant: 2, AmountHave: 1}, 10000); err == nil {
+		t.Errorf("Market buy with 100%% slippage should have returned an error")
+		return
+	}
+
+	return
+}
+
+func TestMarketOrderPriceSell(t *testing.T) {
+	var err error
+
+	// 1% slippage off of a best buy price of 2 should be 2.02
+	var worst Price
+	if worst, err = MarketOrderPrice(Sell, &Price{AmountWant: 2, AmountHave: 1}, 100); err != nil {
+		t.Errorf("Error getting market order price for TestMarketOrderPriceSell: %s", err)
+		return
+	}
+
+	if expected := (&Price{AmountWant: 202, AmountHave: 100}).Reduce(); worst != expected {
+		t.Errorf("Market sell price should have been %s but was %s", expected.String(), worst.String())
+		return
+	}
+
+	return
+}
+
+func TestNewMarketOrderRoundsWithinSlippage(t *testing.T) {
+	var err error
+
+	bestSell := &Price{AmountWant: 3, AmountHave: 1}
+
+	var worst Price
+	if worst, err = MarketOrderPrice(Buy, bestSell, 1); err != nil {
+		t.Errorf("Error getting market order price for TestNewMarketOrderRoundsWithinSlippage: %s", err)
+		return
+	}
+
+	var order *LimitOrder
+	if order, err = NewMarketOrder([33]byte{0x02}, Buy, Pair{}, 7, bestSell, 1); err != nil {
+		t.Errorf("Error creating market order for TestNewMarketOrderRoundsWithinSlippage: %s", err)
+		return
+	}
+
+	if order.TimeInForce != ImmediateOrCancel {
+		t.Errorf("Market order should be immediate or cancel, but was %s", order.TimeInForce.String())
+		return
+	}
+
+	var orderPrice *Price
+	if orderPrice, err = order.Price(); err != nil {
+		t.Errorf("Error getting market order price for TestNewMarketOrderRoundsWithinSlippage: %s", err)
+		return
+	}
+
+	if orderPrice.Cmp(&worst) < 0 {
+		t.Errorf("Market buy price %s should not be lower than the worst price %s", orderPrice.String(), worst.String())
+		return
+	}
+
+	return
+}
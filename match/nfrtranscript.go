@@ -2,7 +2,6 @@ package match
 
 import (
 	"bytes"
-	"encoding/gob"
 	"fmt"
 	"math/big"
 	"sync"
@@ -362,44 +361,84 @@ func trapdoor(p, q *big.Int, encOrder EncryptedSolutionOrder) (order AuctionOrde
 	return
 }
 
-// Serialize uses gob encoding to turn the transcript into bytes.
+// Serialize turns the transcript into bytes with the canonical
+// encoding: [32 byte batch id] [batch id sig] [puzzled orders]
+// [32 byte commitment] [commit sig] [responses] [solutions], where
+// the puzzled orders, responses, and solutions are lists.
 func (tr *Transcript) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
+	enc := NewEncoder(TranscriptMessage)
+	enc.WriteFixed(tr.BatchId[:])
+	enc.WriteBytes(tr.BatchIdSig)
 
-	// register Transcript interface
-	gob.Register(Transcript{})
+	enc.WriteUint32(uint32(len(tr.PuzzledOrders)))
+	for _, pzOrder := range tr.PuzzledOrders {
+		var pzBytes []byte
+		if pzBytes, err = pzOrder.Serialize(); err != nil {
+			err = fmt.Errorf("Error encoding transcript: %s", err)
+			return
+		}
+		enc.WriteBytes(pzBytes)
+	}
 
-	// create a new encoder writing to the buffer
-	enc := gob.NewEncoder(&b)
+	enc.WriteFixed(tr.Commitment[:])
+	enc.WriteBytes(tr.CommitSig)
 
-	// encode the transcript in the buffer
-	if err = enc.Encode(tr); err != nil {
-		err = fmt.Errorf("Error encoding transcript: %s", err)
-		return
+	enc.WriteUint32(uint32(len(tr.Responses)))
+	for _, response := range tr.Responses {
+		var responseBytes []byte
+		if responseBytes, err = response.Serialize(); err != nil {
+			err = fmt.Errorf("Error encoding transcript: %s", err)
+			return
+		}
+		enc.WriteBytes(responseBytes)
 	}
 
-	// Get the bytes from the buffer
-	raw = b.Bytes()
+	enc.WriteUint32(uint32(len(tr.Solutions)))
+	for _, solution := range tr.Solutions {
+		enc.WriteBytes(solution.Serialize())
+	}
+
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize turns the transcript from bytes into a usable
 // struct.
 func (tr *Transcript) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
+	// every item in a list is at least a length and a version and type
+	const minItemSize = 4 + 2
+
+	dec := NewDecoder(raw, TranscriptMessage)
+	dec.ReadFixed(tr.BatchId[:])
+	tr.BatchIdSig = dec.ReadBytes()
+
+	tr.PuzzledOrders = nil
+	for i, count := 0, dec.ReadCount(minItemSize); i < count; i++ {
+		var pzOrder SignedEncSolOrder
+		dec.SetErr(pzOrder.Deserialize(dec.ReadBytes()))
+		tr.PuzzledOrders = append(tr.PuzzledOrders, pzOrder)
+	}
 
-	// register Transcript
-	gob.Register(Transcript{})
+	dec.ReadFixed(tr.Commitment[:])
+	tr.CommitSig = dec.ReadBytes()
 
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
+	tr.Responses = nil
+	for i, count := 0, dec.ReadCount(minItemSize); i < count; i++ {
+		var response CommitResponse
+		dec.SetErr(response.Deserialize(dec.ReadBytes()))
+		tr.Responses = append(tr.Responses, response)
+	}
 
-	// decode the transcript in the buffer
-	if err = dec.Decode(tr); err != nil {
+	tr.Solutions = nil
+	for i, count := 0, dec.ReadCount(minItemSize); i < count; i++ {
+		var solution AuctionOrder
+		dec.SetErr(solution.Deserialize(dec.ReadBytes()))
+		tr.Solutions = append(tr.Solutions, solution)
+	}
+
+	if err = dec.Finish(); err != nil {
 		err = fmt.Errorf("Error decoding transcript: %s", err)
 		return
 	}
-
 	return
 }
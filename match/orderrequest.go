@@ -0,0 +1,62 @@
+package match
+
+import (
+	"fmt"
+)
+
+// CancelRequest is a request to cancel an order or a stop order. It's put in an envelope, which is what gets
+// signed, so a signature for cancelling an order can't be used to do anything else, or be used again.
+type CancelRequest struct {
+	OrderID OrderID `json:"orderid"`
+}
+
+// Serialize serializes the cancel request in the canonical encoding: [32 byte order ID]
+func (c *CancelRequest) Serialize() (buf []byte) {
+	enc := NewEncoder(CancelRequestMessage)
+	enc.WriteFixed(c.OrderID[:])
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a cancel request that was serialized with Serialize
+func (c *CancelRequest) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, CancelRequestMessage)
+	dec.ReadFixed(c.OrderID[:])
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing cancel request: %s", err)
+		return
+	}
+	return
+}
+
+// ReplaceRequest is a request to amend the amounts of an order that's on the book. It's put in an envelope,
+// which is what gets signed, so a signature for one amendment can't be used for another, or be used again.
+type ReplaceRequest struct {
+	OrderID    OrderID `json:"orderid"`
+	AmountHave uint64  `json:"amounthave"`
+	AmountWant uint64  `json:"amountwant"`
+}
+
+// Serialize serializes the replace request in the canonical encoding: [32 byte order ID]
+// [8 byte amountHave] [8 byte amountWant]
+func (r *ReplaceRequest) Serialize() (buf []byte) {
+	enc := NewEncoder(ReplaceRequestMessage)
+	enc.WriteFixed(r.OrderID[:])
+	enc.WriteUint64(r.AmountHave)
+	enc.WriteUint64(r.AmountWant)
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a replace request that was serialized with Serialize
+func (r *ReplaceRequest) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, ReplaceRequestMessage)
+	dec.ReadFixed(r.OrderID[:])
+	r.AmountHave = dec.ReadUint64()
+	r.AmountWant = dec.ReadUint64()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing replace request: %s", err)
+		return
+	}
+	return
+}
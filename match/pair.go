@@ -33,8 +33,40 @@ func (p *Pair) PrettyString() string {
 	return p.AssetWant.String() + "/" + p.AssetHave.String()
 }
 
-// GenerateAssetPairs generates unique asset pairs based on the coinparams you pass it
+// GenerateAssetPairs generates unique asset pairs based on the coinparams you pass it. If the asset registry
+// only enables some pairs, those are the only pairs that are generated, in the order they were enabled.
 func GenerateAssetPairs(coinList []*coinparam.Params) (pairList []*Pair, err error) {
+	assetRegistryMtx.RLock()
+	enabledPairs := assetRegistry.pairs
+	assetRegistryMtx.RUnlock()
+
+	if enabledPairs != nil {
+		coins := make(map[*coinparam.Params]bool)
+		for _, coin := range coinList {
+			coins[coin] = true
+		}
+
+		pairList = []*Pair{}
+		for _, pair := range enabledPairs {
+			var wantCoin *coinparam.Params
+			if wantCoin, err = pair.AssetWant.CoinParamFromAsset(); err != nil {
+				return
+			}
+
+			var haveCoin *coinparam.Params
+			if haveCoin, err = pair.AssetHave.CoinParamFromAsset(); err != nil {
+				return
+			}
+
+			if coins[wantCoin] && coins[haveCoin] {
+				pairCopy := new(Pair)
+				*pairCopy = *pair
+				pairList = append(pairList, pairCopy)
+			}
+		}
+		return
+	}
+
 	coinListLen := len(coinList)
 	numPairIndeces := coinListLen * (coinListLen - 1) / 2
 	pairList = make([]*Pair, numPairIndeces)
@@ -70,6 +102,10 @@ func (p *Pair) String() string {
 // FromString creates a pair object from a string. This is for user input only, hence the slash
 func (p *Pair) FromString(pairString string) (err error) {
 	strSplit := strings.Split(pairString, "/")
+	if len(strSplit) != 2 {
+		err = fmt.Errorf("Pair %s should be two assets separated by a slash", pairString)
+		return
+	}
 
 	if p.AssetWant, err = AssetFromString(strSplit[0]); err != nil {
 		return
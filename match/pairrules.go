@@ -0,0 +1,168 @@
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// PairRules are the rules that orders on a pair have to follow. A rule that is zero isn't enforced.
+type PairRules struct {
+	// TickSize is the step that an order's price, AmountWant over AmountHave, has to be a multiple of.
+	// Immediate-or-cancel and fill-or-kill orders never rest on the book, so they don't have to be on a
+	// tick, which lets market orders be priced at the worst price the user will accept.
+	TickSize Price `json:"ticksize"`
+	// LotSize is the step that an order's AmountHave has to be a multiple of
+	LotSize uint64 `json:"lotsize"`
+	// MinValue is the smallest value an order can have. The value of an order is how much of the pair's
+	// AssetHave it gives or gets, so the AmountHave of a buy order and the AmountWant of a sell order.
+	MinValue uint64 `json:"minvalue"`
+	// MaxAmountHave is the largest AmountHave an order can have
+	MaxAmountHave uint64 `json:"maxamounthave"`
+}
+
+// Valid returns an error if the rules can't be enforced
+func (pr *PairRules) Valid() (err error) {
+	if (pr.TickSize.AmountWant == 0) != (pr.TickSize.AmountHave == 0) {
+		err = fmt.Errorf("Tick size %s must either be zero or have a nonzero AmountWant and AmountHave", pr.TickSize.String())
+		return
+	}
+
+	if pr.MaxAmountHave != 0 && pr.MaxAmountHave < pr.LotSize {
+		err = fmt.Errorf("Max AmountHave %d is smaller than the lot size %d, so no order could be placed", pr.MaxAmountHave, pr.LotSize)
+		return
+	}
+	return
+}
+
+// CheckAmountHave returns an error if AmountHave isn't a multiple of the lot size or is more than the max
+func (pr *PairRules) CheckAmountHave(amountHave uint64) (err error) {
+	if pr.LotSize != 0 && amountHave%pr.LotSize != 0 {
+		err = fmt.Errorf("AmountHave %d is not a multiple of the lot size %d", amountHave, pr.LotSize)
+		return
+	}
+
+	if pr.MaxAmountHave != 0 && amountHave > pr.MaxAmountHave {
+		err = fmt.Errorf("AmountHave %d is more than the max of %d", amountHave, pr.MaxAmountHave)
+		return
+	}
+	return
+}
+
+// CheckOrder returns an error if an order with the side and amounts breaks any of the rules. Orders that
+// rest on the book also have to be priced on a tick.
+func (pr *PairRules) CheckOrder(side Side, amountHave uint64, amountWant uint64, rests bool) (err error) {
+	if amountHave == 0 || amountWant == 0 {
+		err = fmt.Errorf("Cannot check rules for an order with an AmountHave or AmountWant of 0")
+		return
+	}
+
+	if err = pr.CheckAmountHave(amountHave); err != nil {
+		return
+	}
+
+	var value uint64
+	if side == Buy {
+		value = amountHave
+	} else if side == Sell {
+		value = amountWant
+	} else {
+		err = fmt.Errorf("Cannot check rules for an order that is %s side", side.String())
+		return
+	}
+
+	if value < pr.MinValue {
+		err = fmt.Errorf("Order value %d is less than the min of %d", value, pr.MinValue)
+		return
+	}
+
+	if !rests || pr.TickSize.AmountWant == 0 || pr.TickSize.AmountHave == 0 {
+		return
+	}
+
+	// want / have is a multiple of tickWant / tickHave if (want * tickHave) / (have * tickWant) is a
+	// whole number
+	numerator := new(big.Int).Mul(new(big.Int).SetUint64(amountWant), new(big.Int).SetUint64(pr.TickSize.AmountHave))
+	denominator := new(big.Int).Mul(new(big.Int).SetUint64(amountHave), new(big.Int).SetUint64(pr.TickSize.AmountWant))
+	if new(big.Int).Rem(numerator, denominator).Sign() != 0 {
+		err = fmt.Errorf("Price %d/%d is not a multiple of the tick size %s", amountWant, amountHave, pr.TickSize.String())
+		return
+	}
+	return
+}
+
+// CheckLimitOrder returns an error if the limit order breaks any of the rules
+func (pr *PairRules) CheckLimitOrder(order *LimitOrder) (err error) {
+	err = pr.CheckOrder(order.Side, order.AmountHave, order.AmountWant, !order.TimeInForce.IsImmediate())
+	return
+}
+
+// CheckAuctionOrder returns an error if the auction order breaks any of the rules. Auction orders are
+// priced like orders on the book, so they have to be on a tick.
+func (pr *PairRules) CheckAuctionOrder(order *AuctionOrder) (err error) {
+	err = pr.CheckOrder(order.Side, order.AmountHave, order.AmountWant, true)
+	return
+}
+
+// PairRulesConfig is the rules for each pair on an exchange, as they are written in a pair file
+type PairRulesConfig struct {
+	// Default is the rules for pairs that aren't in Pairs, if it's set
+	Default *PairRules `json:"default"`
+	// Pairs are the rules for each pair, keyed by the pair as users write it, like "regtest/litereg"
+	Pairs map[string]*PairRules `json:"pairs"`
+}
+
+// ParsePairRulesConfig parses a json pair rules config, returning the rules for each pair in the pair list
+// that has rules
+func ParsePairRulesConfig(data []byte, pairList []*Pair) (rules map[Pair]*PairRules, err error) {
+	config := new(PairRulesConfig)
+	if err = json.Unmarshal(data, config); err != nil {
+		err = fmt.Errorf("Error unmarshalling pair rules config: %s", err)
+		return
+	}
+
+	rules = make(map[Pair]*PairRules)
+	for _, pair := range pairList {
+		if config.Default != nil {
+			rules[*pair] = config.Default
+		}
+	}
+
+	for pairString, pairRules := range config.Pairs {
+		var pair Pair
+		if pair, err = pairFromConfigString(pairString); err != nil {
+			err = fmt.Errorf("Error getting pair for pair rules config: %s", err)
+			return
+		}
+
+		if pairRules == nil {
+			err = fmt.Errorf("Rules for %s cannot be empty", pairString)
+			return
+		}
+		rules[pair] = pairRules
+	}
+
+	for pair, pairRules := range rules {
+		if err = pairRules.Valid(); err != nil {
+			err = fmt.Errorf("Invalid rules for %s: %s", pair.String(), err)
+			return
+		}
+	}
+	return
+}
+
+// pairFromConfigString gets the pair from a pair written the way users write it in config files, like
+// "regtest/litereg"
+func pairFromConfigString(pairString string) (pair Pair, err error) {
+	if strings.Count(pairString, "/") != 1 {
+		err = fmt.Errorf("Pair %s should be two coin names separated by a slash", pairString)
+		return
+	}
+
+	if err = pair.FromString(pairString); err != nil {
+		err = fmt.Errorf("Error getting pair from string %s: %s", pairString, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package match
+
+import (
+	"testing"
+)
+
+func TestPairRulesCheckOrder(t *testing.T) {
+	rules := &PairRules{
+		TickSize:      Price{AmountWant: 1, AmountHave: 100},
+		LotSize:       10,
+		MinValue:      100,
+		MaxAmountHave: 100000,
+	}
+
+	var tests = []struct {
+		name       string
+		side       Side
+		amountHave uint64
+		amountWant uint64
+		rests      bool
+		valid      bool
+	}{
+		{name: "on tick", side: Buy, amountHave: 1000, amountWant: 30, rests: true, valid: true},
+		{name: "off tick", side: Buy, amountHave: 1000, amountWant: 33, rests: true, valid: false},
+		// 3/1000 is 0.3 ticks
+		{name: "less than a tick", side: Buy, amountHave: 1000, amountWant: 3, rests: true, valid: false},
+		{name: "off tick immediate", side: Buy, amountHave: 1000, amountWant: 33, rests: false, valid: true},
+		{name: "off lot", side: Buy, amountHave: 1005, amountWant: 33, rests: false, valid: false},
+		{name: "too big", side: Buy, amountHave: 100010, amountWant: 1000, rests: false, valid: false},
+		{name: "buy below min value", side: Buy, amountHave: 90, amountWant: 1, rests: false, valid: false},
+		// a sell order's value is its AmountWant
+		{name: "sell below min value", side: Sell, amountHave: 1000, amountWant: 99, rests: false, valid: false},
+		{name: "sell at min value", side: Sell, amountHave: 1000, amountWant: 100, rests: false, valid: true},
+	}
+
+	for _, tt := range tests {
+		err := rules.CheckOrder(tt.side, tt.amountHave, tt.amountWant, tt.rests)
+		if tt.valid && err != nil {
+			t.Errorf("Order %s should have followed the rules but didn't: %s", tt.name, err)
+			return
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Order %s should have broken the rules but didn't", tt.name)
+			return
+		}
+	}
+
+	// Zero rules accept anything
+	if err := new(PairRules).CheckOrder(Sell, 1, 1, true); err != nil {
+		t.Errorf("Zero rules should accept any order but returned: %s", err)
+		return
+	}
+
+	return
+}
+
+func TestParsePairRulesConfig(t *testing.T) {
+	var err error
+
+	var pairList []*Pair
+	for _, pairString := range []string{"regtest/litereg", "regtest/vtcreg"} {
+		pair := new(Pair)
+		if err = pair.FromString(pairString); err != nil {
+			t.Errorf("Error getting pair from string for TestParsePairRulesConfig: %s", err)
+			return
+		}
+		pairList = append(pairList, pair)
+	}
+
+	config := []byte(`{"default": {"lotsize": 1000}, "pairs": {"regtest/litereg": {"ticksize": {"AmountWant": 1, "AmountHave": 100}, "minvalue": 5}}}`)
+
+	var rules map[Pair]*PairRules
+	if rules, err = ParsePairRulesConfig(config, pairList); err != nil {
+		t.Errorf("Error parsing pair rules config: %s", err)
+		return
+	}
+
+	if rules[*pairList[0]].TickSize != (Price{AmountWant: 1, AmountHave: 100}) || rules[*pairList[0]].MinValue != 5 || rules[*pairList[0]].LotSize != 0 {
+		t.Errorf("Rules for %s should have been from the config but were %+v", pairList[0].PrettyString(), rules[*pairList[0]])
+		return
+	}
+
+	if rules[*pairList[1]].LotSize != 1000 {
+		t.Errorf("Rules for %s should have been the default but were %+v", pairList[1].PrettyString(), rules[*pairList[1]])
+		return
+	}
+
+	// A tick size with only one side set can't be enforced
+	if _, err = ParsePairRulesConfig([]byte(`{"pairs": {"regtest/litereg": {"ticksize": {"AmountWant": 1}}}}`), pairList); err == nil {
+		t.Errorf("Pair rules config with an invalid tick size should have returned an error")
+		return
+	}
+
+	if _, err = ParsePairRulesConfig([]byte(`{"pairs": {"regtest": {"lotsize": 1}}}`), pairList); err == nil {
+		t.Errorf("Pair rules config with an invalid pair should have returned an error")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,80 @@
+package match
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PairStatus is what can be done on a pair right now, which the exchange operator can change while the
+// exchange is running
+type PairStatus uint8
+
+const (
+	// PairTrading pairs accept and match orders like normal. This is the default.
+	PairTrading PairStatus = iota
+	// PairHalted pairs don't accept, match, or cancel orders. Everything on the book stays there until
+	// the pair is resumed or delisted.
+	PairHalted
+	// PairCancelOnly pairs only let users cancel orders
+	PairCancelOnly
+	// PairAuctionOnly pairs accept orders that rest on the book, but don't match them. Whatever crosses
+	// is matched all at once when the pair goes back to trading.
+	PairAuctionOnly
+	// PairDelisted pairs have had every order cancelled and refunded, and don't accept anything
+	PairDelisted
+)
+
+const (
+	tradingString     = "trading"     // just for string representation
+	haltedString      = "halted"      // just for string representation
+	cancelOnlyString  = "cancelonly"  // just for string representation
+	auctionOnlyString = "auctiononly" // just for string representation
+	delistedString    = "delisted"    // just for string representation
+)
+
+// String returns the string representation of the pair status
+func (ps PairStatus) String() string {
+	switch ps {
+	case PairTrading:
+		return tradingString
+	case PairHalted:
+		return haltedString
+	case PairCancelOnly:
+		return cancelOnlyString
+	case PairAuctionOnly:
+		return auctionOnlyString
+	case PairDelisted:
+		return delistedString
+	}
+	return "unknown"
+}
+
+// FromString takes a string and, if valid, sets the PairStatus to the correct value based on the string
+func (ps *PairStatus) FromString(str string) (err error) {
+	switch strings.ToLower(str) {
+	default:
+		err = fmt.Errorf("Cannot get pair status from string, not trading, halted, cancelonly, auctiononly, or delisted")
+		return
+	case tradingString:
+		*ps = PairTrading
+	case haltedString:
+		*ps = PairHalted
+	case cancelOnlyString:
+		*ps = PairCancelOnly
+	case auctionOnlyString:
+		*ps = PairAuctionOnly
+	case delistedString:
+		*ps = PairDelisted
+	}
+	return
+}
+
+// CanPlace returns true if orders can be placed on or replaced in the book of a pair with this status
+func (ps PairStatus) CanPlace() bool {
+	return ps == PairTrading || ps == PairAuctionOnly
+}
+
+// CanCancel returns true if orders can be cancelled on a pair with this status
+func (ps PairStatus) CanCancel() bool {
+	return ps == PairTrading || ps == PairCancelOnly || ps == PairAuctionOnly
+}
@@ -49,3 +49,51 @@ func (p *Price) Cmp(otherPrice *Price) (compIndicator int) {
 	compIndicator = numeratorOne.Cmp(numeratorTwo)
 	return
 }
+
+// Reduce returns the price with AmountWant and AmountHave divided by their greatest common divisor.
+// Two prices that are equal will have the same reduced form, so reduced prices can be used as map keys.
+func (p *Price) Reduce() (reduced Price) {
+	if p.AmountWant == 0 || p.AmountHave == 0 {
+		reduced = *p
+		return
+	}
+
+	a, b := p.AmountWant, p.AmountHave
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	reduced = Price{
+		AmountWant: p.AmountWant / a,
+		AmountHave: p.AmountHave / a,
+	}
+	return
+}
+
+// MulAmount multiplies amount by the price, rounding down. The multiplication is done with big
+// integers so no precision is lost to floating point rounding, and an error is returned if the result
+// does not fit in a uint64.
+func (p *Price) MulAmount(amount uint64) (result uint64, err error) {
+	if p.AmountHave == 0 {
+		err = fmt.Errorf("AmountHave cannot be 0 to multiply by price")
+		return
+	}
+
+	// amount * (want / have) = (amount * want) / have
+	product := new(big.Int).SetUint64(amount)
+	product.Mul(product, new(big.Int).SetUint64(p.AmountWant))
+	product.Quo(product, new(big.Int).SetUint64(p.AmountHave))
+
+	if !product.IsUint64() {
+		err = fmt.Errorf("Result of multiplying %d by price %s does not fit in a uint64", amount, p)
+		return
+	}
+
+	result = product.Uint64()
+	return
+}
+
+// String returns the price as a fraction, AmountWant/AmountHave
+func (p *Price) String() string {
+	return fmt.Sprintf("%d/%d", p.AmountWant, p.AmountHave)
+}
@@ -1,6 +1,7 @@
 package match
 
 import (
+	"math"
 	"testing"
 )
 
@@ -256,3 +257,67 @@ func BenchmarkPriceCompare(b *testing.B) {
 	}
 	return
 }
+
+// TestPriceReduce tests that two equal prices with different amounts
+// reduce to the same representation
+func TestPriceReduce(t *testing.T) {
+	priceOne := &Price{
+		AmountWant: 200000000,
+		AmountHave: 100000000,
+	}
+	priceTwo := &Price{
+		AmountWant: 6,
+		AmountHave: 3,
+	}
+
+	if priceOne.Reduce() != priceTwo.Reduce() {
+		t.Errorf("Reduced prices should be the same, but %s reduced to %v and %s reduced to %v", priceOne, priceOne.Reduce(), priceTwo, priceTwo.Reduce())
+		return
+	}
+
+	expected := Price{AmountWant: 2, AmountHave: 1}
+	if priceOne.Reduce() != expected {
+		t.Errorf("Price %s should have reduced to %v but reduced to %v", priceOne, expected, priceOne.Reduce())
+		return
+	}
+	return
+}
+
+// TestPriceMulAmountExact tests that multiplying an amount by a price
+// that cannot be represented exactly as a float does not lose any
+// precision
+func TestPriceMulAmountExact(t *testing.T) {
+	var err error
+	thirdPrice := &Price{
+		AmountWant: 1,
+		AmountHave: 3,
+	}
+
+	var result uint64
+	if result, err = thirdPrice.MulAmount(300000000000000003); err != nil {
+		t.Errorf("Error multiplying amount by price: %s", err)
+		return
+	}
+
+	if result != 100000000000000001 {
+		t.Errorf("Multiplying by 1/3 should have given 100000000000000001 but gave %d", result)
+		return
+	}
+	return
+}
+
+// TestPriceMulAmountOverflow tests that multiplying an amount by a
+// price will return an error if the result does not fit in a uint64
+func TestPriceMulAmountOverflow(t *testing.T) {
+	var err error
+	bigPrice := &Price{
+		AmountWant: 3,
+		AmountHave: 1,
+	}
+
+	if _, err = bigPrice.MulAmount(math.MaxUint64); err == nil {
+		t.Errorf("Should have an error when the result of multiplying by a price does not fit in a uint64")
+		return
+	}
+	return
+}
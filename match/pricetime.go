@@ -2,6 +2,7 @@ package match
 
 import (
 	"fmt"
+	"sort"
 )
 
 // TODO: rather than writing this algorithm functionally (with
@@ -10,10 +11,71 @@ import (
 
 // MatchPrioritizedOrders matches separated buy and sell orders that are properly sorted in price-time priority.
 // These are the orders that should match.
+// Orders from the same pubkey are never matched with each other. Instead, self-trade prevention cancels or
+// decrements them, and they are refunded. The refunds for cancelled orders are the first len(cancelled)
+// refunds, in the same order, and then there is one refund for each order execution that is Decremented.
+// There is a trade for every buy order and sell order that were matched, in the order they were matched.
 // This should never return a list of order executions containing the same ID for more than one execution
-func MatchPrioritizedOrders(buyOrders []*LimitOrderIDPair, sellOrders []*LimitOrderIDPair) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, err error) {
+func MatchPrioritizedOrders(buyOrders []*LimitOrderIDPair, sellOrders []*LimitOrderIDPair) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, cancelled []*CancelledOrder, refunds []*SettlementExecution, trades []*Trade, err error) {
+	// partial is the latest execution for orders that have been matched or decremented but not filled,
+	// which can only be the first order on either side
+	partial := make(map[OrderID]*OrderExecution)
+
+	// decremented is how much of each order self-trade prevention has taken off, in the order they were
+	// first decremented
+	decremented := make(map[OrderID]uint64)
+	var decrementedOrders []*LimitOrderIDPair
+
 	// Lists should be in priority order starting at 0
-	for len(buyOrders) > 0 && len(sellOrders) > 0 && buyOrders[0].Price <= sellOrders[0].Price {
+	for len(buyOrders) > 0 && len(sellOrders) > 0 && buyOrders[0].Price.Cmp(&sellOrders[0].Price) <= 0 {
+		if buyOrders[0].Order.Pubkey == sellOrders[0].Order.Pubkey {
+			var cancelBuy, cancelSell bool
+			var decrementHave uint64
+			if cancelBuy, cancelSell, decrementHave, err = preventSelfTrade(buyOrders[0], sellOrders[0]); err != nil {
+				err = fmt.Errorf("Error preventing self-trade: %s", err)
+				return
+			}
+
+			for _, lp := range []*LimitOrderIDPair{buyOrders[0], sellOrders[0]} {
+				if (lp.Order.Side == Buy && cancelBuy) || (lp.Order.Side == Sell && cancelSell) {
+					// The order may have been matched before this, and whatever is left of it is refunded
+					if orderExec, ok := partial[*lp.OrderID]; ok {
+						orderExecs = append(orderExecs, orderExec)
+						delete(partial, *lp.OrderID)
+					}
+
+					var refund *SettlementExecution
+					if refund, err = lp.Order.RefundExecution(lp.Order.AmountHave); err != nil {
+						err = fmt.Errorf("Error refunding self-trade cancellation: %s", err)
+						return
+					}
+
+					cancelledID := new(OrderID)
+					*cancelledID = *lp.OrderID
+					cancelled = append(cancelled, &CancelledOrder{OrderID: cancelledID})
+					refunds = append(refunds, refund)
+				} else if decrementHave != 0 {
+					if _, ok := decremented[*lp.OrderID]; !ok {
+						decrementedOrders = append(decrementedOrders, lp)
+					}
+					decremented[*lp.OrderID] += decrementHave
+					partial[*lp.OrderID] = &OrderExecution{
+						OrderID:       *lp.OrderID,
+						NewAmountHave: lp.Order.AmountHave,
+						NewAmountWant: lp.Order.AmountWant,
+					}
+				}
+			}
+
+			if cancelSell {
+				sellOrders = sellOrders[1:]
+			}
+			if cancelBuy {
+				buyOrders = buyOrders[1:]
+			}
+			continue
+		}
+
 		// Ahh whatever we can be a little inefficient space-wise, just add em all to the list
 		// and optimize later
 
@@ -46,6 +108,14 @@ func MatchPrioritizedOrders(buyOrders []*LimitOrderIDPair, sellOrders []*LimitOr
 			return
 		}
 
+		// The trade has to be created before the amounts of the orders change
+		var trade *Trade
+		if trade, err = newTrade(buyOrders[0], sellOrders[0], prelimSettlementExecs); err != nil {
+			err = fmt.Errorf("Error creating trade for matched orders: %s", err)
+			return
+		}
+		trades = append(trades, trade)
+
 		// Set new amounts because we either want final amounts (when loop conds won't satisfy)
 		// or we want a fill
 		buyOrders[0].Order.AmountHave = prBuyExec.NewAmountHave
@@ -54,36 +124,96 @@ func MatchPrioritizedOrders(buyOrders []*LimitOrderIDPair, sellOrders []*LimitOr
 		sellOrders[0].Order.AmountHave = prSellExec.NewAmountHave
 		sellOrders[0].Order.AmountWant = prSellExec.NewAmountWant
 
-		if len(buyOrders) == 0 || len(sellOrders) == 0 || buyOrders[0].Price > sellOrders[0].Price {
-			// If we will be done, make sure to add the result
-			orderExecs = append(orderExecs, []*OrderExecution{&prBuyExec, &prSellExec}...)
+		// Filled orders are done, so add them and take them off the lists. Orders that aren't filled
+		// are added once we're done, otherwise they would be added for every match.
+		delete(partial, prSellExec.OrderID)
+		delete(partial, prBuyExec.OrderID)
+		if prSellExec.Filled {
+			sellOrders = sellOrders[1:]
+			orderExecs = append(orderExecs, &prSellExec)
 		} else {
-			// If we're gonna keep going, then only add if we're filled.
-			if prSellExec.Filled {
-				sellOrders = sellOrders[1:]
-				orderExecs = append(orderExecs, &prSellExec)
-			}
-			if prBuyExec.Filled {
-				buyOrders = buyOrders[1:]
-				orderExecs = append(orderExecs, &prBuyExec)
-			}
+			partial[prSellExec.OrderID] = &prSellExec
+		}
+		if prBuyExec.Filled {
+			buyOrders = buyOrders[1:]
+			orderExecs = append(orderExecs, &prBuyExec)
+		} else {
+			partial[prBuyExec.OrderID] = &prBuyExec
 		}
 
 		// we keep all of the settlements no matter what because the rates may be
 		// changing (due to time priority)
 		settlementExecs = append(settlementExecs, prelimSettlementExecs...)
 	}
+
+	// Only the first order on each side can be partially matched
+	if len(sellOrders) > 0 {
+		if orderExec, ok := partial[*sellOrders[0].OrderID]; ok {
+			orderExecs = append(orderExecs, orderExec)
+		}
+	}
+	if len(buyOrders) > 0 {
+		if orderExec, ok := partial[*buyOrders[0].OrderID]; ok {
+			orderExecs = append(orderExecs, orderExec)
+		}
+	}
+
+	for _, orderExec := range orderExecs {
+		if _, ok := decremented[orderExec.OrderID]; ok {
+			orderExec.Decremented = true
+		}
+	}
+
+	for _, lp := range decrementedOrders {
+		var refund *SettlementExecution
+		if refund, err = lp.Order.RefundExecution(decremented[*lp.OrderID]); err != nil {
+			err = fmt.Errorf("Error refunding self-trade decrement: %s", err)
+			return
+		}
+		refunds = append(refunds, refund)
+	}
 	return
 }
 
 // PrioritizeOrderbookPTP prioritizes orders in a map representation of an orderbook by price-time priority.
 // It then separates that into buy and sell lists, which get returned.
 // This makes it easy to put in to the MatchPrioritizedOrders algorithm.
-// TODO: Implement this. It's not really necessary but helpful
-// func PrioritizeOrderbookPTP(book map[float64][]*LimitOrderIDPair) (buyOrders []*LimitOrderIDPair, sellOrders []*LimitOrderIDPair, err error) {
-// 	logging.Fatalf("UNIMPLEMENTED!!!")
-// 	return
-// }
+// Buy orders are sorted by price ascending, and sell orders are sorted by price descending. Both are then
+// sorted by time ascending.
+func PrioritizeOrderbookPTP(book map[Price][]*LimitOrderIDPair) (buyOrders []*LimitOrderIDPair, sellOrders []*LimitOrderIDPair, err error) {
+	for _, orderPairList := range book {
+		for _, orderPair := range orderPairList {
+			if orderPair.Order == nil {
+				err = fmt.Errorf("Error prioritizing orderbook, order cannot be nil")
+				return
+			}
+			if orderPair.Order.Side == Buy {
+				buyOrders = append(buyOrders, orderPair)
+			} else if orderPair.Order.Side == Sell {
+				sellOrders = append(sellOrders, orderPair)
+			} else {
+				err = fmt.Errorf("Error prioritizing orderbook, order is not buy or sell side, it's %s side", orderPair.Order.Side.String())
+				return
+			}
+		}
+	}
+
+	sort.SliceStable(buyOrders, func(i, j int) bool {
+		if cmp := buyOrders[i].Price.Cmp(&buyOrders[j].Price); cmp != 0 {
+			return cmp < 0
+		}
+		return buyOrders[i].Timestamp.Before(buyOrders[j].Timestamp)
+	})
+
+	sort.SliceStable(sellOrders, func(i, j int) bool {
+		if cmp := sellOrders[i].Price.Cmp(&sellOrders[j].Price); cmp != 0 {
+			return cmp > 0
+		}
+		return sellOrders[i].Timestamp.Before(sellOrders[j].Timestamp)
+	})
+
+	return
+}
 
 // MatchTwo matches a buy order order with the sell order supplied as an argument, giving this order priority.
 func MatchTwoOpposite(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (buyExec OrderExecution, sellExec OrderExecution, settlementExecs []*SettlementExecution, err error) {
@@ -106,13 +236,13 @@ func MatchTwoOpposite(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (buyExe
 		// order at the sell order's price
 		if sellLp.Order.AmountHave >= buyLp.Order.AmountWant {
 			var sellSetExecs []*SettlementExecution
-			if sellExec, sellSetExecs, _, err = sellLp.Order.GenerateExecutionFromPrice(sellLp.OrderID, sellLp.Price, buyLp.Order.AmountHave); err != nil {
+			if sellExec, sellSetExecs, _, err = sellLp.Order.GenerateExecutionFromPrice(sellLp.OrderID, &sellLp.Price, buyLp.Order.AmountHave); err != nil {
 				err = fmt.Errorf("Error generating exec from price for sell MatchTwoOpposite: %s", err)
 				return
 			}
 			// so we have this sell order exec, now we fill the buy order at the sell price
 			var buySetExecs []*SettlementExecution
-			if buyExec, buySetExecs, err = buyLp.Order.GenerateOrderFill(buyLp.OrderID, sellLp.Price); err != nil {
+			if buyExec, buySetExecs, err = buyLp.Order.GenerateOrderFill(buyLp.OrderID, &sellLp.Price); err != nil {
 				err = fmt.Errorf("Error generating fill from price for buy MatchTwoOpposite: %s", err)
 				return
 			}
@@ -128,13 +258,13 @@ func MatchTwoOpposite(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (buyExe
 		// otherwise the sell order will be filled by the buy order, so we generate a fill for the sell order.
 		// The sellOrder.AmountHave < buyOrder.AmountWant, so we will generate a fill for the sell order
 		var sellSetExecs []*SettlementExecution
-		if sellExec, sellSetExecs, err = sellLp.Order.GenerateOrderFill(sellLp.OrderID, sellLp.Price); err != nil {
+		if sellExec, sellSetExecs, err = sellLp.Order.GenerateOrderFill(sellLp.OrderID, &sellLp.Price); err != nil {
 			err = fmt.Errorf("Error generating fill for sell order for MatchTwoOpposite: %s", err)
 			return
 		}
 
 		var buySetExecs []*SettlementExecution
-		if buyExec, buySetExecs, _, err = buyLp.Order.GenerateExecutionFromPrice(buyLp.OrderID, sellLp.Price, sellLp.Order.AmountHave); err != nil {
+		if buyExec, buySetExecs, _, err = buyLp.Order.GenerateExecutionFromPrice(buyLp.OrderID, &sellLp.Price, sellLp.Order.AmountHave); err != nil {
 			err = fmt.Errorf("Error generating buy exec from price for MatchTwoOpposite: %s", err)
 			return
 		}
@@ -150,13 +280,13 @@ func MatchTwoOpposite(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (buyExe
 	if buyLp.Order.AmountHave >= sellLp.Order.AmountWant {
 		// The sell order can be filled so we generate a fill for that and a normal, leftover exec for the buy order
 		var sellSetExecs []*SettlementExecution
-		if sellExec, sellSetExecs, err = sellLp.Order.GenerateOrderFill(sellLp.OrderID, buyLp.Price); err != nil {
+		if sellExec, sellSetExecs, err = sellLp.Order.GenerateOrderFill(sellLp.OrderID, &buyLp.Price); err != nil {
 			err = fmt.Errorf("Error generating fill for sell order for MatchTwoOpposite: %s", err)
 			return
 		}
 
 		var buySetExecs []*SettlementExecution
-		if buyExec, buySetExecs, _, err = buyLp.Order.GenerateExecutionFromPrice(buyLp.OrderID, buyLp.Price, sellLp.Order.AmountHave); err != nil {
+		if buyExec, buySetExecs, _, err = buyLp.Order.GenerateExecutionFromPrice(buyLp.OrderID, &buyLp.Price, sellLp.Order.AmountHave); err != nil {
 			err = fmt.Errorf("Error generating buy exec from price for MatchTwoOpposite: %s", err)
 			return
 		}
@@ -170,13 +300,13 @@ func MatchTwoOpposite(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (buyExe
 	}
 	// The sell order > buy order so we generate partial fill for sell and full for buy
 	var sellSetExecs []*SettlementExecution
-	if sellExec, sellSetExecs, _, err = sellLp.Order.GenerateExecutionFromPrice(sellLp.OrderID, buyLp.Price, buyLp.Order.AmountHave); err != nil {
+	if sellExec, sellSetExecs, _, err = sellLp.Order.GenerateExecutionFromPrice(sellLp.OrderID, &buyLp.Price, buyLp.Order.AmountHave); err != nil {
 		err = fmt.Errorf("Error generating exec from price for sell MatchTwoOpposite: %s", err)
 		return
 	}
 	// so we have this sell order exec, now we fill the buy order at the sell price
 	var buySetExecs []*SettlementExecution
-	if buyExec, buySetExecs, err = buyLp.Order.GenerateOrderFill(buyLp.OrderID, buyLp.Price); err != nil {
+	if buyExec, buySetExecs, err = buyLp.Order.GenerateOrderFill(buyLp.OrderID, &buyLp.Price); err != nil {
 		err = fmt.Errorf("Error generating fill from price for buy MatchTwoOpposite: %s", err)
 		return
 	}
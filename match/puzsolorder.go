@@ -1,10 +1,8 @@
 package match
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/gob"
 	"fmt"
 	"math/big"
 
@@ -57,44 +55,31 @@ func (so *SolutionOrder) EncryptSolutionOrder(auctionOrder AuctionOrder, t uint6
 	return
 }
 
-// Serialize uses gob encoding to turn the solution order into bytes.
+// Serialize turns the solution order into bytes with the canonical
+// encoding: [p] [q]
 func (so *SolutionOrder) Serialize() (raw []byte, err error) {
-	var b bytes.Buffer
-
-	// register SolutionOrder interface
-	gob.Register(SolutionOrder{})
-
-	// create a new encoder writing to the buffer
-	enc := gob.NewEncoder(&b)
-
-	// encode the puzzle in the buffer
-	if err = enc.Encode(so); err != nil {
-		err = fmt.Errorf("Error encoding solutionorder: %s", err)
+	enc := NewEncoder(SolutionOrderMessage)
+	if err = enc.WriteBigInt(so.P); err != nil {
+		err = fmt.Errorf("Error encoding p of solutionorder: %s", err)
 		return
 	}
-
-	// Get the bytes from the buffer
-	raw = b.Bytes()
+	if err = enc.WriteBigInt(so.Q); err != nil {
+		err = fmt.Errorf("Error encoding q of solutionorder: %s", err)
+		return
+	}
+	raw = enc.Bytes()
 	return
 }
 
 // Deserialize turns the solution order from bytes into a usable
 // struct.
 func (so *SolutionOrder) Deserialize(raw []byte) (err error) {
-	var b *bytes.Buffer
-	b = bytes.NewBuffer(raw)
-
-	// register SolutionOrder
-	gob.Register(SolutionOrder{})
-
-	// create a new decoder writing to the buffer
-	dec := gob.NewDecoder(b)
-
-	// decode the solutionorder in the buffer
-	if err = dec.Decode(so); err != nil {
+	dec := NewDecoder(raw, SolutionOrderMessage)
+	so.P = dec.ReadBigInt()
+	so.Q = dec.ReadBigInt()
+	if err = dec.Finish(); err != nil {
 		err = fmt.Errorf("Error decoding solutionorder: %s", err)
 		return
 	}
-
 	return
 }
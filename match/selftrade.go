@@ -0,0 +1,137 @@
+package match
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SelfTradePrevention determines what happens when an order would match another order from the same pubkey.
+// The mode of the newer of the two orders is the one that is used.
+type SelfTradePrevention uint8
+
+const (
+	// CancelNewest cancels the newer of the two orders, and leaves the older one on the book. This is the
+	// default.
+	CancelNewest SelfTradePrevention = iota
+	// CancelOldest cancels the older of the two orders, so the newer one can keep matching.
+	CancelOldest
+	// CancelBoth cancels both orders.
+	CancelBoth
+	// DecrementAndCancel cancels the smaller of the two orders, and takes the size of the smaller order off
+	// of the larger one. If they are the same size both are cancelled.
+	DecrementAndCancel
+)
+
+const (
+	cnString = "cn" // just for string representation
+	coString = "co" // just for string representation
+	cbString = "cb" // just for string representation
+	dcString = "dc" // just for string representation
+)
+
+// String returns the string representation of the self-trade prevention mode
+func (stp SelfTradePrevention) String() string {
+	switch stp {
+	case CancelNewest:
+		return cnString
+	case CancelOldest:
+		return coString
+	case CancelBoth:
+		return cbString
+	case DecrementAndCancel:
+		return dcString
+	}
+	return "unknown"
+}
+
+// FromString takes a string and, if valid, sets the SelfTradePrevention to the correct value based on the string
+func (stp *SelfTradePrevention) FromString(str string) (err error) {
+	switch strings.ToLower(str) {
+	default:
+		err = fmt.Errorf("Cannot get self-trade prevention from string, not cn, co, cb, or dc")
+		return
+	case cnString:
+		*stp = CancelNewest
+	case coString:
+		*stp = CancelOldest
+	case cbString:
+		*stp = CancelBoth
+	case dcString:
+		*stp = DecrementAndCancel
+	}
+	return
+}
+
+// preventSelfTrade decides what to do with a buy and sell order from the same pubkey that would match,
+// using the mode of the newer order. If one of the orders is decremented instead of cancelled, its amounts
+// are changed and decrementHave is how much of its AmountHave was taken off.
+func preventSelfTrade(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair) (cancelBuy bool, cancelSell bool, decrementHave uint64, err error) {
+	if buyLp.Order.Side != Buy || sellLp.Order.Side != Sell {
+		err = fmt.Errorf("Invalid input, buy LimitOrderIDPair was not buy or sell LimitOrderIDPair was not sell")
+		return
+	}
+
+	// Same comparison as MatchTwoOpposite, so ties go the same way
+	buyIsNewest := buyLp.Timestamp.UnixNano() > sellLp.Timestamp.UnixNano()
+	newest := sellLp
+	if buyIsNewest {
+		newest = buyLp
+	}
+
+	switch newest.Order.SelfTrade {
+	case CancelNewest:
+		cancelBuy, cancelSell = buyIsNewest, !buyIsNewest
+		return
+	case CancelOldest:
+		cancelBuy, cancelSell = !buyIsNewest, buyIsNewest
+		return
+	case CancelBoth:
+		cancelBuy, cancelSell = true, true
+		return
+	case DecrementAndCancel:
+	default:
+		err = fmt.Errorf("Unknown self-trade prevention mode %s", newest.Order.SelfTrade.String())
+		return
+	}
+
+	// The buy order wants the same asset the sell order has, so that's what we compare sizes in
+	if buyLp.Order.AmountWant > sellLp.Order.AmountHave {
+		newAmountWant := buyLp.Order.AmountWant - sellLp.Order.AmountHave
+		newAmountHave := scaleAmount(buyLp.Order.AmountHave, newAmountWant, buyLp.Order.AmountWant)
+		if newAmountHave == 0 {
+			cancelBuy, cancelSell = true, true
+			return
+		}
+
+		decrementHave = buyLp.Order.AmountHave - newAmountHave
+		buyLp.Order.AmountHave = newAmountHave
+		buyLp.Order.AmountWant = newAmountWant
+		cancelSell = true
+		return
+	}
+
+	if sellLp.Order.AmountHave > buyLp.Order.AmountWant {
+		newAmountHave := sellLp.Order.AmountHave - buyLp.Order.AmountWant
+		newAmountWant := scaleAmount(sellLp.Order.AmountWant, newAmountHave, sellLp.Order.AmountHave)
+		if newAmountWant == 0 {
+			cancelBuy, cancelSell = true, true
+			return
+		}
+
+		decrementHave = sellLp.Order.AmountHave - newAmountHave
+		sellLp.Order.AmountHave = newAmountHave
+		sellLp.Order.AmountWant = newAmountWant
+		cancelBuy = true
+		return
+	}
+
+	cancelBuy, cancelSell = true, true
+	return
+}
+
+// scaleAmount returns amount * numerator / denominator, rounded down, without overflowing
+func scaleAmount(amount uint64, numerator uint64, denominator uint64) (scaled uint64) {
+	product := new(big.Int).Mul(new(big.Int).SetUint64(amount), new(big.Int).SetUint64(numerator))
+	return product.Quo(product, new(big.Int).SetUint64(denominator)).Uint64()
+}
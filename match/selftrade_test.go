@@ -0,0 +1,181 @@
+package match
+
+import (
+	"testing"
+	"time"
+)
+
+// selfTradeBook creates a book from the orders, giving each order an ID equal to its position in the list
+// and a timestamp one second after the order before it
+func selfTradeBook(orders []*LimitOrder) (book map[Price][]*LimitOrderIDPair, err error) {
+	book = make(map[Price][]*LimitOrderIDPair)
+	start := time.Unix(1000000, 0)
+	for i, order := range orders {
+		var price *Price
+		if price, err = order.Price(); err != nil {
+			return
+		}
+
+		idPair := &LimitOrderIDPair{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Price:     *price,
+			OrderID:   &OrderID{byte(i + 1)},
+			Order:     order,
+		}
+		book[*price] = append(book[*price], idPair)
+	}
+	return
+}
+
+func TestSelfTradeModes(t *testing.T) {
+	var err error
+
+	var pair Pair
+	pair.AssetHave = Asset(0)
+	pair.AssetWant = Asset(1)
+
+	var tests = []struct {
+		mode      SelfTradePrevention
+		cancelled []OrderID
+		refunds   []uint64
+		// newerFills is true if the newer order trades with the other user's order
+		newerFills bool
+	}{
+		{mode: CancelNewest, cancelled: []OrderID{{2}}, refunds: []uint64{100}},
+		{mode: CancelOldest, cancelled: []OrderID{{1}}, refunds: []uint64{100}, newerFills: true},
+		{mode: CancelBoth, cancelled: []OrderID{{2}, {1}}, refunds: []uint64{100, 100}},
+	}
+
+	for _, tt := range tests {
+		// The first order is an old sell from A, the second is a newer buy from A, and the third is a sell
+		// from B that's behind A's sell.
+		orders := []*LimitOrder{
+			{Pubkey: [33]byte{0xa}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+			{Pubkey: [33]byte{0xa}, Side: Buy, TradingPair: pair, AmountHave: 100, AmountWant: 100, SelfTrade: tt.mode},
+			{Pubkey: [33]byte{0xb}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+		}
+
+		var book map[Price][]*LimitOrderIDPair
+		if book, err = selfTradeBook(orders); err != nil {
+			t.Errorf("Error creating book for TestSelfTradeModes: %s", err)
+			return
+		}
+
+		var orderExecs []*OrderExecution
+		var settlementExecs []*SettlementExecution
+		var cancelled []*CancelledOrder
+		if orderExecs, settlementExecs, cancelled, _, err = MatchLimitBook(book); err != nil {
+			t.Errorf("Error matching book for %s: %s", tt.mode.String(), err)
+			return
+		}
+
+		if len(cancelled) != len(tt.cancelled) {
+			t.Errorf("%s should have cancelled %d orders but cancelled %d", tt.mode.String(), len(tt.cancelled), len(cancelled))
+			return
+		}
+
+		for i, cancelledOrder := range cancelled {
+			if *cancelledOrder.OrderID != tt.cancelled[i] {
+				t.Errorf("%s cancelled order %d should have been %x but was %x", tt.mode.String(), i, tt.cancelled[i], *cancelledOrder.OrderID)
+				return
+			}
+		}
+
+		// Refunds are the last settlements, in the same order as the cancelled orders
+		refunds := settlementExecs[len(settlementExecs)-len(tt.refunds):]
+		for i, refund := range refunds {
+			if refund.Amount != tt.refunds[i] || refund.Pubkey != orders[0].Pubkey || refund.Type != Debit {
+				t.Errorf("%s refund %d should have been a debit of %d to A but was %s", tt.mode.String(), i, tt.refunds[i], refund.String())
+				return
+			}
+		}
+
+		if tt.newerFills {
+			if len(orderExecs) != 2 || !orderExecs[0].Filled || !orderExecs[1].Filled {
+				t.Errorf("%s should have filled the newer order and the other user's order, got %d order executions", tt.mode.String(), len(orderExecs))
+				return
+			}
+			continue
+		}
+
+		if len(orderExecs) != 0 || len(settlementExecs) != len(tt.refunds) {
+			t.Errorf("%s should not have matched anything, got %d order executions and %d settlement executions", tt.mode.String(), len(orderExecs), len(settlementExecs))
+			return
+		}
+	}
+
+	return
+}
+
+func TestSelfTradeDecrementAndCancel(t *testing.T) {
+	var err error
+
+	var pair Pair
+	pair.AssetHave = Asset(0)
+	pair.AssetWant = Asset(1)
+
+	// The newer buy is smaller than the older sell, so the buy is cancelled and the sell is decremented
+	orders := []*LimitOrder{
+		{Pubkey: [33]byte{0xa}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+		{Pubkey: [33]byte{0xa}, Side: Buy, TradingPair: pair, AmountHave: 40, AmountWant: 40, SelfTrade: DecrementAndCancel},
+	}
+
+	var book map[Price][]*LimitOrderIDPair
+	if book, err = selfTradeBook(orders); err != nil {
+		t.Errorf("Error creating book for TestSelfTradeDecrementAndCancel: %s", err)
+		return
+	}
+
+	var orderExecs []*OrderExecution
+	var settlementExecs []*SettlementExecution
+	var cancelled []*CancelledOrder
+	if orderExecs, settlementExecs, cancelled, _, err = MatchLimitBook(book); err != nil {
+		t.Errorf("Error matching book for TestSelfTradeDecrementAndCancel: %s", err)
+		return
+	}
+
+	if len(cancelled) != 1 || *cancelled[0].OrderID != (OrderID{2}) {
+		t.Errorf("Only the buy order should have been cancelled, but %d orders were cancelled", len(cancelled))
+		return
+	}
+
+	if len(orderExecs) != 1 {
+		t.Errorf("There should have been 1 order execution but there were %d", len(orderExecs))
+		return
+	}
+
+	expectedExec := &OrderExecution{
+		OrderID:       OrderID{1},
+		NewAmountHave: 60,
+		NewAmountWant: 60,
+		Decremented:   true,
+	}
+	if !orderExecs[0].Equal(expectedExec) {
+		t.Errorf("Sell order should have been decremented to 60, but execution was %s", orderExecs[0].String())
+		return
+	}
+
+	// The cancelled buy is refunded first, then what was taken off of the sell
+	if len(settlementExecs) != 2 {
+		t.Errorf("There should have been 2 refunds but there were %d settlement executions", len(settlementExecs))
+		return
+	}
+
+	if settlementExecs[0].Amount != 40 || settlementExecs[0].Asset != pair.AssetHave {
+		t.Errorf("Buy refund should have been 40 of %s but was %s", pair.AssetHave.String(), settlementExecs[0].String())
+		return
+	}
+
+	if settlementExecs[1].Amount != 40 || settlementExecs[1].Asset != pair.AssetWant {
+		t.Errorf("Sell decrement refund should have been 40 of %s but was %s", pair.AssetWant.String(), settlementExecs[1].String())
+		return
+	}
+
+	// The book shouldn't have been modified
+	if orders[0].AmountHave != 100 {
+		t.Errorf("Matching should not modify the orders in the book")
+		return
+	}
+
+	return
+}
@@ -3,6 +3,7 @@ package match
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 )
 
 // TODO: replace with this once ready
@@ -39,3 +40,29 @@ func (se *SettlementExecution) Equal(otherExec *SettlementExecution) bool {
 	}
 	return true
 }
+
+// Serialize serializes the settlement execution in the canonical encoding:
+// [33 byte pubkey] [8 byte amount] [1 byte asset] [1 byte settle type, 1 for debit]
+func (se *SettlementExecution) Serialize() (buf []byte) {
+	enc := NewEncoder(SettlementExecutionMessage)
+	enc.WriteFixed(se.Pubkey[:])
+	enc.WriteUint64(se.Amount)
+	enc.WriteUint8(uint8(se.Asset))
+	enc.WriteBool(bool(se.Type))
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a settlement execution that was serialized with Serialize
+func (se *SettlementExecution) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, SettlementExecutionMessage)
+	dec.ReadFixed(se.Pubkey[:])
+	se.Amount = dec.ReadUint64()
+	se.Asset = Asset(dec.ReadUint8())
+	se.Type = SettleType(dec.ReadBool())
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing settlement execution: %s", err)
+		return
+	}
+	return
+}
@@ -1,6 +1,9 @@
 package match
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 var (
 	buySide               = Buy
@@ -194,3 +197,27 @@ func TestSideUnmarshalBinaryManyWrongValue(t *testing.T) {
 
 	return
 }
+
+// TestSideJSONRoundTrip makes sure both sides unmarshal back from their JSON
+func TestSideJSONRoundTrip(t *testing.T) {
+	var err error
+	for _, side := range []Side{Buy, Sell} {
+		var sideJSON []byte
+		if sideJSON, err = json.Marshal(side); err != nil {
+			t.Errorf("Error marshalling %s side json: %s", side.String(), err)
+			return
+		}
+
+		var unmarshalled Side
+		if err = json.Unmarshal(sideJSON, &unmarshalled); err != nil {
+			t.Errorf("Error unmarshalling %s side json %s: %s", side.String(), sideJSON, err)
+			return
+		}
+
+		if unmarshalled != side {
+			t.Errorf("Side json round trip incorrect: Expected %s, got %s", side.String(), unmarshalled.String())
+			return
+		}
+	}
+	return
+}
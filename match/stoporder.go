@@ -0,0 +1,242 @@
+package match
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// StopOrder is an order that the exchange holds until the last trade price for its pair reaches the stop
+// price, and then places. The funds for the order are reserved when the stop order is placed, so the
+// order that gets placed is never short on balance.
+type StopOrder struct {
+	// Order is what gets placed when the stop order is triggered. A stop-limit order places this as is.
+	// A stop-loss order only uses the Pubkey, Side, TradingPair, and AmountHave, and places a market order.
+	Order LimitOrder `json:"order"`
+	// StopPrice is compared to the last trade price the same way the matching engine compares prices.
+	// Buy stops trigger when the last trade price is at or above the stop price, and sell stops trigger
+	// when the last trade price is at or below the stop price.
+	StopPrice Price `json:"stopprice"`
+	// Market is true for stop-loss orders, which are placed as market orders when they are triggered
+	Market bool `json:"market"`
+	// Slippage is how far from the best price, in basis points, a stop-loss order can be matched
+	Slippage uint64 `json:"slippage"`
+}
+
+// StopOrderIDPair is a stop order with the ID and time it was placed with
+type StopOrderIDPair struct {
+	Timestamp time.Time  `json:"timestamp"`
+	OrderID   *OrderID   `json:"orderid"`
+	Order     *StopOrder `json:"stoporder"`
+}
+
+// Valid returns an error if the stop order could never be placed when it's triggered
+func (s *StopOrder) Valid() (err error) {
+	if s.StopPrice.AmountWant == 0 || s.StopPrice.AmountHave == 0 {
+		err = fmt.Errorf("Stop price cannot be zero")
+		return
+	}
+
+	if s.Order.AmountHave == 0 {
+		err = fmt.Errorf("Stop order AmountHave cannot be zero")
+		return
+	}
+
+	if s.Market {
+		if s.Order.Side == Buy && s.Slippage >= slippageDenominator {
+			err = fmt.Errorf("Slippage for a buy stop-loss order must be less than %d basis points", slippageDenominator)
+			return
+		}
+		return
+	}
+
+	if _, err = s.Order.Price(); err != nil {
+		err = fmt.Errorf("Error calculating price for stop-limit order: %s", err)
+		return
+	}
+
+	if s.Order.TimeInForce > FillOrKill {
+		err = fmt.Errorf("Unknown time in force %d for stop-limit order", s.Order.TimeInForce)
+		return
+	}
+
+	if s.Order.SelfTrade > DecrementAndCancel {
+		err = fmt.Errorf("Unknown self-trade prevention mode %d for stop-limit order", s.Order.SelfTrade)
+		return
+	}
+	return
+}
+
+// Triggered returns true if the stop order should be placed, given the last trade price for its pair
+func (s *StopOrder) Triggered(lastPrice *Price) bool {
+	if lastPrice == nil || lastPrice.AmountWant == 0 || lastPrice.AmountHave == 0 {
+		return false
+	}
+
+	if s.Order.Side == Buy {
+		return lastPrice.Cmp(&s.StopPrice) >= 0
+	}
+	return lastPrice.Cmp(&s.StopPrice) <= 0
+}
+
+// TriggeredOrder returns the order to place when the stop order is triggered. The book is only used for
+// stop-loss orders, to find the best price on the other side.
+func (s *StopOrder) TriggeredOrder(book map[Price][]*LimitOrderIDPair) (order *LimitOrder, err error) {
+	if !s.Market {
+		order = new(LimitOrder)
+		*order = s.Order
+		return
+	}
+
+	oppositeSide := Buy
+	if s.Order.Side == Buy {
+		oppositeSide = Sell
+	}
+
+	var bestOpposite *Price
+	if bestOpposite, err = BestPrice(book, oppositeSide); err != nil {
+		err = fmt.Errorf("Error getting best price for stop-loss order: %s", err)
+		return
+	}
+
+	if order, err = NewMarketOrder(s.Order.Pubkey, s.Order.Side, s.Order.TradingPair, s.Order.AmountHave, bestOpposite, s.Slippage); err != nil {
+		err = fmt.Errorf("Error creating market order for stop-loss order: %s", err)
+		return
+	}
+	return
+}
+
+// Serialize serializes the stop order in the canonical encoding, this is what gets signed:
+// [limit order] [8 byte stop price amountWant] [8 byte stop price amountHave] [1 byte market] [8 byte slippage]
+func (s *StopOrder) Serialize() (buf []byte, err error) {
+	var orderBytes []byte
+	if orderBytes, err = s.Order.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing order for stop order serialize: %s", err)
+		return
+	}
+
+	enc := NewEncoder(StopOrderMessage)
+	enc.WriteBytes(orderBytes)
+	enc.WriteUint64(s.StopPrice.AmountWant)
+	enc.WriteUint64(s.StopPrice.AmountHave)
+	enc.WriteBool(s.Market)
+	enc.WriteUint64(s.Slippage)
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a stop order that was serialized with Serialize
+func (s *StopOrder) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, StopOrderMessage)
+	orderBytes := dec.ReadBytes()
+	s.StopPrice.AmountWant = dec.ReadUint64()
+	s.StopPrice.AmountHave = dec.ReadUint64()
+	s.Market = dec.ReadBool()
+	s.Slippage = dec.ReadUint64()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing stop order: %s", err)
+		return
+	}
+
+	if err = s.Order.Deserialize(orderBytes); err != nil {
+		err = fmt.Errorf("Error deserializing order for stop order: %s", err)
+		return
+	}
+	return
+}
+
+// IDPair creates the ID for a stop order placed at placementTime, and returns it with the stop order
+func (s *StopOrder) IDPair(placementTime time.Time) (idPair *StopOrderIDPair, err error) {
+	var orderBytes []byte
+	if orderBytes, err = s.Serialize(); err != nil {
+		err = fmt.Errorf("Error serializing stop order for IDPair: %s", err)
+		return
+	}
+
+	// The placement time is hashed in as well, since the same stop order can be placed more than once
+	var timeBytes [8]byte
+	binary.LittleEndian.PutUint64(timeBytes[:], uint64(placementTime.UnixNano()))
+
+	hasher := sha3.New256()
+	hasher.Write(orderBytes)
+	hasher.Write(timeBytes[:])
+
+	idPair = &StopOrderIDPair{
+		Timestamp: placementTime,
+		OrderID:   new(OrderID),
+		Order:     s,
+	}
+
+	if err = idPair.OrderID.UnmarshalBinary(hasher.Sum(nil)); err != nil {
+		err = fmt.Errorf("Could not unmarshal order id for IDPair: %s", err)
+		return
+	}
+	return
+}
+
+// ReserveExecution returns the settlement execution that takes the funds for the stop order from the user
+// when it's placed
+func (s *StopOrder) ReserveExecution() (reserve *SettlementExecution, err error) {
+	var creditAsset Asset
+	if s.Order.Side == Buy {
+		creditAsset = s.Order.TradingPair.AssetHave
+	} else if s.Order.Side == Sell {
+		creditAsset = s.Order.TradingPair.AssetWant
+	} else {
+		err = fmt.Errorf("Error generating reserve, order is not buy or sell side, it's %s side", s.Order.Side.String())
+		return
+	}
+
+	reserve = &SettlementExecution{
+		Pubkey: s.Order.Pubkey,
+		Amount: s.Order.AmountHave,
+		Asset:  creditAsset,
+		Type:   Credit,
+	}
+	return
+}
+
+// Serialize serializes the stop order and its ID in the canonical encoding:
+// [time] [optional 32 byte order ID] [optional stop order]
+func (s *StopOrderIDPair) Serialize() (buf []byte, err error) {
+	enc := NewEncoder(StopOrderIDPairMessage)
+	enc.WriteTime(s.Timestamp)
+	enc.WriteBool(s.OrderID != nil)
+	if s.OrderID != nil {
+		enc.WriteFixed(s.OrderID[:])
+	}
+	enc.WriteBool(s.Order != nil)
+	if s.Order != nil {
+		var orderBytes []byte
+		if orderBytes, err = s.Order.Serialize(); err != nil {
+			err = fmt.Errorf("Error serializing stop order for stop order ID pair: %s", err)
+			return
+		}
+		enc.WriteBytes(orderBytes)
+	}
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a stop order and its ID that were serialized with Serialize
+func (s *StopOrderIDPair) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, StopOrderIDPairMessage)
+	s.Timestamp = dec.ReadTime()
+	s.OrderID = nil
+	if dec.ReadBool() {
+		s.OrderID = new(OrderID)
+		dec.ReadFixed(s.OrderID[:])
+	}
+	s.Order = nil
+	if dec.ReadBool() {
+		s.Order = new(StopOrder)
+		dec.SetErr(s.Order.Deserialize(dec.ReadBytes()))
+	}
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing stop order ID pair: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,199 @@
+package match
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TimeInForce determines how long an order stays on the book if it can't be matched right away
+type TimeInForce uint8
+
+const (
+	// GoodTillCancel orders rest on the book until they are filled or cancelled. This is the default.
+	GoodTillCancel TimeInForce = iota
+	// ImmediateOrCancel orders are matched as much as they can be when they are placed, and whatever is
+	// left is cancelled.
+	ImmediateOrCancel
+	// FillOrKill orders are only matched if they can be filled entirely when they are placed, otherwise
+	// the whole order is cancelled.
+	FillOrKill
+)
+
+const (
+	gtcString = "gtc" // just for string representation
+	iocString = "ioc" // just for string representation
+	fokString = "fok" // just for string representation
+)
+
+// String returns the string representation of the time in force
+func (tif TimeInForce) String() string {
+	switch tif {
+	case GoodTillCancel:
+		return gtcString
+	case ImmediateOrCancel:
+		return iocString
+	case FillOrKill:
+		return fokString
+	}
+	return "unknown"
+}
+
+// FromString takes a string and, if valid, sets the TimeInForce to the correct value based on the string
+func (tif *TimeInForce) FromString(str string) (err error) {
+	switch strings.ToLower(str) {
+	default:
+		err = fmt.Errorf("Cannot get time in force from string, not gtc, ioc, or fok")
+		return
+	case gtcString:
+		*tif = GoodTillCancel
+	case iocString:
+		*tif = ImmediateOrCancel
+	case fokString:
+		*tif = FillOrKill
+	}
+	return
+}
+
+// IsImmediate returns true if orders with this time in force should never rest on the book
+func (tif TimeInForce) IsImmediate() bool {
+	return tif == ImmediateOrCancel || tif == FillOrKill
+}
+
+// MatchLimitBook matches the orders in the book by price-time priority, honoring the time in force of
+// every order. Fill-or-kill orders that can't be filled entirely are not matched at all, and whatever is
+// left of immediate-or-cancel and fill-or-kill orders after matching is cancelled.
+// Orders from the same pubkey that would match are handled by self-trade prevention instead.
+// The settlement executions that refund the cancelled orders come after the settlements from matching, one
+// for each cancelled order in the same order, followed by one for each order execution that is Decremented.
+// The trades are every buy order and sell order that were matched, see MatchPrioritizedOrders.
+// The orders in the book are not modified.
+func MatchLimitBook(book map[Price][]*LimitOrderIDPair) (orderExecs []*OrderExecution, settlementExecs []*SettlementExecution, cancelled []*CancelledOrder, trades []*Trade, err error) {
+	// If any fill-or-kill orders aren't filled, they're killed and we match again without them
+	killed := make(map[OrderID]bool)
+	var refunds []*SettlementExecution
+	for {
+		var buyOrders []*LimitOrderIDPair
+		var sellOrders []*LimitOrderIDPair
+		if buyOrders, sellOrders, err = PrioritizeOrderbookPTP(copyBookExcept(book, killed)); err != nil {
+			err = fmt.Errorf("Error prioritizing orders for MatchLimitBook: %s", err)
+			return
+		}
+
+		if orderExecs, settlementExecs, cancelled, refunds, trades, err = MatchPrioritizedOrders(buyOrders, sellOrders); err != nil {
+			err = fmt.Errorf("Error matching prioritized orders for MatchLimitBook: %s", err)
+			return
+		}
+
+		filled := make(map[OrderID]bool)
+		for _, orderExec := range orderExecs {
+			if orderExec.Filled {
+				filled[orderExec.OrderID] = true
+			}
+		}
+
+		newKills := false
+		for _, orderList := range book {
+			for _, order := range orderList {
+				if order.Order.TimeInForce == FillOrKill && !filled[*order.OrderID] && !killed[*order.OrderID] {
+					killed[*order.OrderID] = true
+					newKills = true
+				}
+			}
+		}
+
+		if !newKills {
+			break
+		}
+	}
+
+	// Orders cancelled by self-trade prevention have already been refunded
+	stpCancelled := make(map[OrderID]bool)
+	for _, cancelledOrder := range cancelled {
+		stpCancelled[*cancelledOrder.OrderID] = true
+	}
+	stpRefunds := len(cancelled)
+	settlementExecs = append(settlementExecs, refunds[:stpRefunds]...)
+
+	// Whatever is left of an immediate order after its last execution gets refunded
+	remaining := make(map[OrderID]*OrderExecution)
+	for _, orderExec := range orderExecs {
+		remaining[orderExec.OrderID] = orderExec
+	}
+
+	for _, orderList := range book {
+		for _, order := range orderList {
+			if !order.Order.TimeInForce.IsImmediate() || stpCancelled[*order.OrderID] {
+				continue
+			}
+
+			refundAmount := order.Order.AmountHave
+			if orderExec, ok := remaining[*order.OrderID]; ok {
+				if orderExec.Filled {
+					continue
+				}
+				refundAmount = orderExec.NewAmountHave
+			}
+
+			var cancelSettlement *SettlementExecution
+			if cancelSettlement, err = order.Order.RefundExecution(refundAmount); err != nil {
+				err = fmt.Errorf("Error creating refund for MatchLimitBook: %s", err)
+				return
+			}
+
+			cancelledID := new(OrderID)
+			*cancelledID = *order.OrderID
+			cancelled = append(cancelled, &CancelledOrder{OrderID: cancelledID})
+			settlementExecs = append(settlementExecs, cancelSettlement)
+		}
+	}
+
+	// The refunds for decremented orders go last
+	settlementExecs = append(settlementExecs, refunds[stpRefunds:]...)
+	return
+}
+
+// RefundExecution returns the settlement execution that gives amountHave of the order back to the user,
+// for when the order is cancelled.
+func (l *LimitOrder) RefundExecution(amountHave uint64) (refund *SettlementExecution, err error) {
+	var debitAsset Asset
+	if l.Side == Buy {
+		debitAsset = l.TradingPair.AssetHave
+	} else if l.Side == Sell {
+		debitAsset = l.TradingPair.AssetWant
+	} else {
+		err = fmt.Errorf("Error generating refund, order is not buy or sell side, it's %s side", l.Side.String())
+		return
+	}
+
+	refund = &SettlementExecution{
+		Pubkey: l.Pubkey,
+		Amount: amountHave,
+		Asset:  debitAsset,
+		Type:   Debit,
+	}
+	return
+}
+
+// copyBookExcept copies every order in the book that isn't in the except set, since matching modifies
+// the orders it's given
+func copyBookExcept(book map[Price][]*LimitOrderIDPair, except map[OrderID]bool) (bookCopy map[Price][]*LimitOrderIDPair) {
+	bookCopy = make(map[Price][]*LimitOrderIDPair)
+	for price, orderList := range book {
+		for _, order := range orderList {
+			if except[*order.OrderID] {
+				continue
+			}
+
+			orderCopy := &LimitOrderIDPair{
+				Timestamp: order.Timestamp,
+				Price:     order.Price,
+				OrderID:   new(OrderID),
+				Order:     new(LimitOrder),
+			}
+			*orderCopy.OrderID = *order.OrderID
+			*orderCopy.Order = *order.Order
+			bookCopy[price] = append(bookCopy[price], orderCopy)
+		}
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package match
+
+import (
+	"fmt"
+	"time"
+)
+
+// Trade is a match between a buy order and a sell order, which is kept so users can see what traded
+type Trade struct {
+	Pair Pair `json:"pair"`
+	// Price is the price of the maker order, which is the price the orders matched at
+	Price Price `json:"price"`
+	// AmountWant is what the buy order got from the trade, and AmountHave is what the sell order got
+	AmountWant  uint64   `json:"amountwant"`
+	AmountHave  uint64   `json:"amounthave"`
+	BuyOrderID  OrderID  `json:"buyorderid"`
+	SellOrderID OrderID  `json:"sellorderid"`
+	BuyPubkey   [33]byte `json:"buypubkey"`
+	SellPubkey  [33]byte `json:"sellpubkey"`
+	// MakerSide is the side of the order that was on the book first. The other order is the taker.
+	MakerSide Side `json:"makerside"`
+	// Timestamp is when the orders were matched. Matching doesn't set this, the exchange does once it
+	// knows the trade is going to happen.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// newTrade creates the trade for a buy order and sell order that were matched, from the settlement
+// executions that came from matching them. Orders are matched at the price of the order that was placed
+// first, so that's the maker.
+func newTrade(buyLp *LimitOrderIDPair, sellLp *LimitOrderIDPair, settlementExecs []*SettlementExecution) (trade *Trade, err error) {
+	if buyLp.Order.Pubkey == sellLp.Order.Pubkey {
+		err = fmt.Errorf("Cannot create trade for orders from the same pubkey")
+		return
+	}
+
+	trade = &Trade{
+		Pair:        buyLp.Order.TradingPair,
+		BuyOrderID:  *buyLp.OrderID,
+		SellOrderID: *sellLp.OrderID,
+		BuyPubkey:   buyLp.Order.Pubkey,
+		SellPubkey:  sellLp.Order.Pubkey,
+		MakerSide:   Buy,
+		Price:       buyLp.Price,
+	}
+
+	// This is the same comparison MatchTwoOpposite uses to pick the price
+	if buyLp.Timestamp.UnixNano() > sellLp.Timestamp.UnixNano() {
+		trade.MakerSide = Sell
+		trade.Price = sellLp.Price
+	}
+
+	for _, setExec := range settlementExecs {
+		if setExec.Type != Debit {
+			continue
+		}
+
+		if setExec.Pubkey == buyLp.Order.Pubkey {
+			trade.AmountWant += setExec.Amount
+		} else if setExec.Pubkey == sellLp.Order.Pubkey {
+			trade.AmountHave += setExec.Amount
+		}
+	}
+	return
+}
+
+// TakerSide returns the side of the order that matched with the order on the book
+func (t *Trade) TakerSide() (side Side) {
+	if t.MakerSide == Buy {
+		side = Sell
+		return
+	}
+	side = Buy
+	return
+}
+
+// HasPubkey returns true if either order in the trade was placed by the pubkey
+func (t *Trade) HasPubkey(pubkey [33]byte) bool {
+	return t.BuyPubkey == pubkey || t.SellPubkey == pubkey
+}
+
+// String returns a readable representation of the trade
+func (t *Trade) String() string {
+	return fmt.Sprintf("{\n\tpair: %s\n\tprice: %s\n\tamountwant: %d\n\tamounthave: %d\n\tbuyorderid: %x\n\tsellorderid: %x\n\tmaker: %s\n\ttime: %s\n}", t.Pair.PrettyString(), t.Price.String(), t.AmountWant, t.AmountHave, t.BuyOrderID[:], t.SellOrderID[:], t.MakerSide.String(), t.Timestamp.String())
+}
+
+// Serialize serializes the trade in the canonical encoding:
+// [2 byte pair] [8 byte price amountWant] [8 byte price amountHave] [8 byte amountWant] [8 byte amountHave]
+// [32 byte buy order ID] [32 byte sell order ID] [33 byte buy pubkey] [33 byte sell pubkey]
+// [1 byte maker side] [time]
+func (t *Trade) Serialize() (buf []byte) {
+	enc := NewEncoder(TradeMessage)
+	enc.WritePair(t.Pair)
+	enc.WritePrice(t.Price)
+	enc.WriteUint64(t.AmountWant)
+	enc.WriteUint64(t.AmountHave)
+	enc.WriteFixed(t.BuyOrderID[:])
+	enc.WriteFixed(t.SellOrderID[:])
+	enc.WriteFixed(t.BuyPubkey[:])
+	enc.WriteFixed(t.SellPubkey[:])
+	enc.WriteSide(t.MakerSide)
+	enc.WriteTime(t.Timestamp)
+	buf = enc.Bytes()
+	return
+}
+
+// Deserialize deserializes a trade that was serialized with Serialize
+func (t *Trade) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, TradeMessage)
+	t.Pair = dec.ReadPair()
+	t.Price = dec.ReadPrice()
+	t.AmountWant = dec.ReadUint64()
+	t.AmountHave = dec.ReadUint64()
+	dec.ReadFixed(t.BuyOrderID[:])
+	dec.ReadFixed(t.SellOrderID[:])
+	dec.ReadFixed(t.BuyPubkey[:])
+	dec.ReadFixed(t.SellPubkey[:])
+	t.MakerSide = dec.ReadSide()
+	t.Timestamp = dec.ReadTime()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing trade: %s", err)
+		return
+	}
+	return
+}
+
+// FillsCursor is where a page of the trades for a pubkey ends. Trades made at the same time are in a
+// fixed order, so the next page starts with the trades made at Before, minus the first Skip of them, which
+// were on earlier pages.
+type FillsCursor struct {
+	Before time.Time `json:"before"`
+	Skip   uint64    `json:"skip"`
+}
@@ -0,0 +1,90 @@
+package match
+
+import (
+	"testing"
+)
+
+func TestMatchLimitBookTrades(t *testing.T) {
+	var err error
+
+	var pair Pair
+	pair.AssetHave = Asset(0)
+	pair.AssetWant = Asset(1)
+
+	var tests = []struct {
+		orders []*LimitOrder
+		// trades are the buy and sell order IDs that should trade, with the maker side
+		trades []Trade
+	}{
+		// The buy is on the book first, so it's the maker
+		{
+			orders: []*LimitOrder{
+				{Pubkey: [33]byte{0xa}, Side: Buy, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+				{Pubkey: [33]byte{0xb}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+			},
+			trades: []Trade{{BuyOrderID: OrderID{1}, SellOrderID: OrderID{2}, MakerSide: Buy}},
+		},
+		// The sell is filled by two buys that come after it
+		{
+			orders: []*LimitOrder{
+				{Pubkey: [33]byte{0xa}, Side: Sell, TradingPair: pair, AmountHave: 200, AmountWant: 200},
+				{Pubkey: [33]byte{0xb}, Side: Buy, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+				{Pubkey: [33]byte{0xc}, Side: Buy, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+			},
+			trades: []Trade{
+				{BuyOrderID: OrderID{2}, SellOrderID: OrderID{1}, MakerSide: Sell},
+				{BuyOrderID: OrderID{3}, SellOrderID: OrderID{1}, MakerSide: Sell},
+			},
+		},
+		// Self-trade prevention cancels A's sell, so A's buy trades with B's sell and nothing else does
+		{
+			orders: []*LimitOrder{
+				{Pubkey: [33]byte{0xa}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+				{Pubkey: [33]byte{0xa}, Side: Buy, TradingPair: pair, AmountHave: 100, AmountWant: 100, SelfTrade: CancelOldest},
+				{Pubkey: [33]byte{0xb}, Side: Sell, TradingPair: pair, AmountHave: 100, AmountWant: 100},
+			},
+			trades: []Trade{{BuyOrderID: OrderID{2}, SellOrderID: OrderID{3}, MakerSide: Buy}},
+		},
+	}
+
+	for i, tt := range tests {
+		var book map[Price][]*LimitOrderIDPair
+		if book, err = selfTradeBook(tt.orders); err != nil {
+			t.Errorf("Error creating book for test %d: %s", i, err)
+			return
+		}
+
+		var trades []*Trade
+		if _, _, _, trades, err = MatchLimitBook(book); err != nil {
+			t.Errorf("Error matching book for test %d: %s", i, err)
+			return
+		}
+
+		if len(trades) != len(tt.trades) {
+			t.Errorf("Test %d should have had %d trades but had %d", i, len(tt.trades), len(trades))
+			return
+		}
+
+		for j, trade := range trades {
+			if trade.BuyOrderID != tt.trades[j].BuyOrderID || trade.SellOrderID != tt.trades[j].SellOrderID {
+				t.Errorf("Test %d trade %d should have been between %x and %x but was between %x and %x", i, j, tt.trades[j].BuyOrderID[:1], tt.trades[j].SellOrderID[:1], trade.BuyOrderID[:1], trade.SellOrderID[:1])
+				return
+			}
+
+			if trade.MakerSide != tt.trades[j].MakerSide {
+				t.Errorf("Test %d trade %d maker should have been %s but was %s", i, j, tt.trades[j].MakerSide.String(), trade.MakerSide.String())
+				return
+			}
+
+			if trade.Pair != pair || trade.Price != (Price{AmountWant: 1, AmountHave: 1}) {
+				t.Errorf("Test %d trade %d had the wrong pair or price", i, j)
+				return
+			}
+
+			if trade.AmountWant != 100 || trade.AmountHave != 100 {
+				t.Errorf("Test %d trade %d should have been for 100 of each asset but was for %d and %d", i, j, trade.AmountWant, trade.AmountHave)
+				return
+			}
+		}
+	}
+}
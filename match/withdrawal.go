@@ -1,8 +1,8 @@
 package match
 
-import "encoding/binary"
+import "fmt"
 
-// Withdrawal is a representation of a withdrawal. This is because withdrawals are now signed.
+// Withdrawal is a representation of a withdrawal
 type Withdrawal struct {
 	Asset   Asset
 	Amount  uint64
@@ -11,23 +11,28 @@ type Withdrawal struct {
 	Lightning bool
 }
 
-// Serialize serializes the withdrawal
+// Serialize serializes the withdrawal in the canonical encoding:
+// [1 byte asset] [8 byte amount] [address] [1 byte lightning]
 func (w *Withdrawal) Serialize() (buf []byte) {
-	// bool yes or no
-	// Asset [1 byte]
-	// Amount [64 bytes]
-	// len(address)
-	// Address [len(address)]
+	enc := NewEncoder(WithdrawalMessage)
+	enc.WriteUint8(uint8(w.Asset))
+	enc.WriteUint64(w.Amount)
+	enc.WriteString(w.Address)
+	enc.WriteBool(w.Lightning)
+	buf = enc.Bytes()
+	return
+}
 
-	var oneorzero byte
-	if w.Lightning {
-		oneorzero = 0xff
+// Deserialize deserializes a withdrawal that was serialized with Serialize
+func (w *Withdrawal) Deserialize(data []byte) (err error) {
+	dec := NewDecoder(data, WithdrawalMessage)
+	w.Asset = Asset(dec.ReadUint8())
+	w.Amount = dec.ReadUint64()
+	w.Address = dec.ReadString()
+	w.Lightning = dec.ReadBool()
+	if err = dec.Finish(); err != nil {
+		err = fmt.Errorf("Error deserializing withdrawal: %s", err)
+		return
 	}
-	buf = make([]byte, 66+len(w.Address))
-	buf = append(buf, oneorzero)
-	buf = append(buf, byte(w.Asset))
-	binary.LittleEndian.PutUint64(buf, w.Amount)
-	binary.LittleEndian.PutUint64(buf, uint64(len(w.Address)))
-	buf = append(buf, []byte(w.Address)...)
 	return
 }